@@ -0,0 +1,173 @@
+// Package alerting dispatches operator alerts to notification sinks such as email, Slack or webhooks.
+//
+// Chaincode event consumers and monitoring jobs turn what they observe into Alerts, e.g. with
+// FromChaincodeEvent, FilterLoadAlert or an EndorsementFailureMonitor, and hand them to a Dispatcher,
+// which routes each alert to the sinks accepting its severity and drops repeats within a dedup window.
+package alerting
+
+import (
+	"fmt"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Severity orders alerts by urgency
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityCritical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityCritical:
+		return "critical"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
+
+// MarshalText encodes the severity by name
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// ParseSeverity decodes a severity name, e.g. from configuration
+func ParseSeverity(name string) (Severity, error) {
+	for _, severity := range []Severity{SeverityInfo, SeverityWarning, SeverityCritical} {
+		if strings.EqualFold(name, severity.String()) {
+			return severity, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q", name)
+}
+
+// Alert kinds raised by this package
+const (
+	KindFilterNearlyFull    = "filter-nearly-full"
+	KindRekeyPerformed      = "rekey-performed"
+	KindRegistryFrozen      = "registry-frozen"
+	KindRegistryUnfrozen    = "registry-unfrozen"
+	KindEndorsementFailures = "endorsement-failures"
+	KindPeerDivergence      = "peer-divergence"
+	KindResizePlanned       = "resize-planned"
+	KindResizeCompleted     = "resize-completed"
+	KindResizeFailed        = "resize-failed"
+)
+
+// Alert is an operator notification
+type Alert struct {
+	Kind     string            `json:"kind"`
+	Severity Severity          `json:"severity"`
+	Summary  string            `json:"summary"`
+	Details  map[string]string `json:"details,omitempty"`
+	Time     time.Time         `json:"time"`
+	DedupKey string            `json:"-"` // Alerts with the same key are sent once per dedup window, defaults to Kind
+}
+
+func (a *Alert) dedupKey() string {
+	if a.DedupKey != "" {
+		return a.DedupKey
+	}
+	return a.Kind
+}
+
+// Text renders the alert as a single message, for sinks that take plain text
+func (a *Alert) Text() string {
+	var text strings.Builder
+	fmt.Fprintf(&text, "[%s] %s: %s", strings.ToUpper(a.Severity.String()), a.Kind, a.Summary)
+	keys := make([]string, 0, len(a.Details))
+	for key := range a.Details {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&text, "\n%s: %s", key, a.Details[key])
+	}
+	return text.String()
+}
+
+// Sink delivers alerts to one notification channel
+type Sink interface {
+	Notify(alert Alert) error
+}
+
+// SinkFunc adapts a function to the Sink interface
+type SinkFunc func(alert Alert) error
+
+// Notify calls f(alert)
+func (f SinkFunc) Notify(alert Alert) error {
+	return f(alert)
+}
+
+// Route sends alerts of at least MinSeverity to a sink
+type Route struct {
+	Name        string
+	Sink        Sink
+	MinSeverity Severity
+}
+
+// DefaultDedupWindow is the dedup window of a Dispatcher that does not set one
+const DefaultDedupWindow = 15 * time.Minute
+
+// Dispatcher routes alerts to sinks, sending the same alert at most once per dedup window
+type Dispatcher struct {
+	Routes      []Route
+	DedupWindow time.Duration // Defaults to DefaultDedupWindow, negative disables deduplication
+	Clock       clock.Clock   // Optional, defaults to clock.System
+
+	mu       sync.Mutex
+	lastSent map[string]time.Time
+}
+
+// Dispatch sends an alert to every route accepting its severity. It returns false when the alert was
+// suppressed as a duplicate, and the first error after every route was tried. Failed deliveries do not
+// count as sent, so the alert is retried when raised again.
+func (d *Dispatcher) Dispatch(alert Alert) (bool, error) {
+	now := clock.Or(d.Clock).Now()
+	if alert.Time.IsZero() {
+		alert.Time = now.UTC()
+	}
+	window := d.DedupWindow
+	if window == 0 {
+		window = DefaultDedupWindow
+	}
+
+	d.mu.Lock()
+	if d.lastSent == nil {
+		d.lastSent = make(map[string]time.Time)
+	}
+	key := alert.dedupKey()
+	if last, ok := d.lastSent[key]; ok && window > 0 && now.Sub(last) < window {
+		d.mu.Unlock()
+		return false, nil
+	}
+	d.mu.Unlock()
+
+	var firstErr error
+	for _, route := range d.Routes {
+		if alert.Severity < route.MinSeverity {
+			continue
+		}
+		if err := route.Sink.Notify(alert); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("error sending %s alert to %s: %v", alert.Kind, route.Name, err)
+		}
+	}
+	if firstErr != nil {
+		return true, firstErr
+	}
+
+	d.mu.Lock()
+	d.lastSent[key] = now
+	d.mu.Unlock()
+	return true, nil
+}
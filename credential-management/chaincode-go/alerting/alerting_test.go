@@ -0,0 +1,159 @@
+package alerting_test
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/pherbke/credential-management/chaincode-go/alerting"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// recordingSink keeps every alert it receives
+type recordingSink struct {
+	alerts []alerting.Alert
+	err    error
+}
+
+func (s *recordingSink) Notify(alert alerting.Alert) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.alerts = append(s.alerts, alert)
+	return nil
+}
+
+func TestDispatcher_RoutesBySeverity(t *testing.T) {
+	oncall, channel := &recordingSink{}, &recordingSink{}
+	dispatcher := &alerting.Dispatcher{Routes: []alerting.Route{
+		{Name: "oncall", Sink: oncall, MinSeverity: alerting.SeverityCritical},
+		{Name: "channel", Sink: channel},
+	}}
+
+	sent, err := dispatcher.Dispatch(alerting.Alert{Kind: "a", Severity: alerting.SeverityWarning, Summary: "warning"})
+	require.NoError(t, err)
+	require.True(t, sent)
+	sent, err = dispatcher.Dispatch(alerting.Alert{Kind: "b", Severity: alerting.SeverityCritical, Summary: "critical"})
+	require.NoError(t, err)
+	require.True(t, sent)
+
+	require.Len(t, oncall.alerts, 1)
+	require.Equal(t, "critical", oncall.alerts[0].Summary)
+	require.Len(t, channel.alerts, 2)
+	require.False(t, channel.alerts[0].Time.IsZero())
+}
+
+func TestDispatcher_Deduplicates(t *testing.T) {
+	now := clock.NewManual(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	sink := &recordingSink{}
+	dispatcher := &alerting.Dispatcher{
+		Routes:      []alerting.Route{{Name: "sink", Sink: sink}},
+		DedupWindow: time.Minute,
+		Clock:       now,
+	}
+	alert := alerting.Alert{Kind: alerting.KindFilterNearlyFull, Severity: alerting.SeverityWarning}
+
+	sent, err := dispatcher.Dispatch(alert)
+	require.NoError(t, err)
+	require.True(t, sent)
+	sent, err = dispatcher.Dispatch(alert)
+	require.NoError(t, err)
+	require.False(t, sent)
+
+	now.Advance(time.Minute)
+	sent, err = dispatcher.Dispatch(alert)
+	require.NoError(t, err)
+	require.True(t, sent)
+	require.Len(t, sink.alerts, 2)
+}
+
+func TestDispatcher_RetriesFailedDeliveries(t *testing.T) {
+	sink := &recordingSink{err: errors.New("unavailable")}
+	dispatcher := &alerting.Dispatcher{Routes: []alerting.Route{{Name: "sink", Sink: sink}}}
+	alert := alerting.Alert{Kind: alerting.KindRekeyPerformed}
+
+	_, err := dispatcher.Dispatch(alert)
+	require.Error(t, err)
+
+	sink.err = nil
+	sent, err := dispatcher.Dispatch(alert)
+	require.NoError(t, err)
+	require.True(t, sent)
+}
+
+func TestWebhookAndSlackSinks(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+
+	alert := alerting.Alert{
+		Kind:     alerting.KindRegistryFrozen,
+		Severity: alerting.SeverityCritical,
+		Summary:  "registry is frozen",
+		Details:  map[string]string{"reason": "incident"},
+	}
+	require.NoError(t, (&alerting.WebhookSink{URL: server.URL}).Notify(alert))
+	require.NoError(t, (&alerting.SlackSink{WebhookURL: server.URL}).Notify(alert))
+
+	require.Len(t, bodies, 2)
+	var posted map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(bodies[0]), &posted))
+	require.Equal(t, "critical", posted["severity"])
+	require.JSONEq(t, `{"text":"[CRITICAL] registry-frozen: registry is frozen\nreason: incident"}`, bodies[1])
+}
+
+func TestFromChaincodeEvent(t *testing.T) {
+	payload, _ := json.Marshal(cuckoofilter.RegistryFreeze{Frozen: true, Reason: "incident"})
+	alert, err := alerting.FromChaincodeEvent(cuckoofilter.RegistryFreezeChangedEvent, payload)
+	require.NoError(t, err)
+	require.Equal(t, alerting.KindRegistryFrozen, alert.Kind)
+	require.Equal(t, alerting.SeverityCritical, alert.Severity)
+	require.Equal(t, "incident", alert.Details["reason"])
+
+	alert, err = alerting.FromChaincodeEvent(cuckoofilter.RegistryConfigChangedEvent, []byte("{}"))
+	require.NoError(t, err)
+	require.Nil(t, alert)
+}
+
+func TestFilterLoadAlert(t *testing.T) {
+	filter := cuckoofilter.NewFilter(8, 4, cuckoofilter.FingerPrintSize)
+	require.Nil(t, alerting.FilterLoadAlert(filter, 0))
+
+	filter.Count = 26
+	alert := alerting.FilterLoadAlert(filter, 0)
+	require.NotNil(t, alert)
+	require.Equal(t, alerting.SeverityWarning, alert.Severity)
+
+	filter.Count = 31
+	require.Equal(t, alerting.SeverityCritical, alerting.FilterLoadAlert(filter, 0).Severity)
+}
+
+func TestEndorsementFailureMonitor(t *testing.T) {
+	monitor := &alerting.EndorsementFailureMonitor{Threshold: 3, Window: time.Minute}
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	require.Nil(t, monitor.RecordFailure(start, nil))
+	require.Nil(t, monitor.RecordFailure(start.Add(30*time.Second), nil))
+	// The first failure left the window
+	require.Nil(t, monitor.RecordFailure(start.Add(70*time.Second), nil))
+	alert := monitor.RecordFailure(start.Add(80*time.Second), errors.New("endorsement policy failure"))
+	require.NotNil(t, alert)
+	require.Equal(t, "3", alert.Details["failures"])
+	require.Equal(t, "endorsement policy failure", alert.Details["lastError"])
+}
+
+func TestParseSeverity(t *testing.T) {
+	severity, err := alerting.ParseSeverity("Warning")
+	require.NoError(t, err)
+	require.Equal(t, alerting.SeverityWarning, severity)
+	_, err = alerting.ParseSeverity("urgent")
+	require.Error(t, err)
+}
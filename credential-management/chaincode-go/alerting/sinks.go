@@ -0,0 +1,83 @@
+package alerting
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// defaultHTTPClient is used by sinks without a client of their own
+var defaultHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// WebhookSink posts every alert as JSON to a URL
+type WebhookSink struct {
+	URL    string
+	Client *http.Client // Optional, defaults to a client with a 10s timeout
+}
+
+// Notify posts the alert
+func (s *WebhookSink) Notify(alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	return postJSON(s.Client, s.URL, body)
+}
+
+// SlackSink posts alerts to a Slack incoming webhook
+type SlackSink struct {
+	WebhookURL string
+	Client     *http.Client // Optional, defaults to a client with a 10s timeout
+}
+
+// Notify posts the alert as a Slack message
+func (s *SlackSink) Notify(alert Alert) error {
+	body, err := json.Marshal(map[string]string{"text": alert.Text()})
+	if err != nil {
+		return err
+	}
+	return postJSON(s.Client, s.WebhookURL, body)
+}
+
+func postJSON(client *http.Client, url string, body []byte) error {
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	response, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", response.Status)
+	}
+	return nil
+}
+
+// EmailSink mails alerts through an SMTP server
+type EmailSink struct {
+	Addr string    // SMTP server as host:port
+	Auth smtp.Auth // Optional
+	From string
+	To   []string
+}
+
+// Notify mails the alert as a plain text message
+func (s *EmailSink) Notify(alert Alert) error {
+	if len(s.To) == 0 {
+		return fmt.Errorf("email sink has no recipients")
+	}
+	var message strings.Builder
+	fmt.Fprintf(&message, "From: %s\r\n", s.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&message, "Subject: [%s] %s\r\n", alert.Severity, alert.Summary)
+	fmt.Fprintf(&message, "Date: %s\r\n", alert.Time.Format(time.RFC1123Z))
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	message.WriteString(strings.ReplaceAll(alert.Text(), "\n", "\r\n"))
+	message.WriteString("\r\n")
+	return smtp.SendMail(s.Addr, s.Auth, s.From, s.To, []byte(message.String()))
+}
@@ -0,0 +1,187 @@
+package alerting
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+)
+
+// DefaultFilterLoadThreshold is the filter load at which FilterLoadAlert warns
+const DefaultFilterLoadThreshold = 0.8
+
+// FromChaincodeEvent turns a chaincode event of the registry into an alert.
+// It returns nil for events that need no operator attention.
+func FromChaincodeEvent(eventName string, payload []byte) (*Alert, error) {
+	switch eventName {
+	case cuckoofilter.RegistryFreezeChangedEvent:
+		var freeze cuckoofilter.RegistryFreeze
+		if err := json.Unmarshal(payload, &freeze); err != nil {
+			return nil, fmt.Errorf("error decoding %s event: %v", eventName, err)
+		}
+		if !freeze.Frozen {
+			return &Alert{Kind: KindRegistryUnfrozen, Severity: SeverityInfo, Summary: "registry accepts writes again", Time: freeze.Since, DedupKey: KindRegistryFrozen + "/unfrozen"}, nil
+		}
+		return &Alert{
+			Kind:     KindRegistryFrozen,
+			Severity: SeverityCritical,
+			Summary:  "registry is frozen, all writes fail",
+			Details:  map[string]string{"reason": freeze.Reason},
+			Time:     freeze.Since,
+			DedupKey: KindRegistryFrozen + "/frozen",
+		}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// FilterLoadAlert returns a warning when the filter holds at least threshold of its capacity,
+// escalated to critical above 95 %, and nil otherwise
+func FilterLoadAlert(filter *cuckoofilter.Filter, threshold float64) *Alert {
+	capacity := filter.Capacity()
+	if capacity == 0 {
+		return nil
+	}
+	if threshold <= 0 {
+		threshold = DefaultFilterLoadThreshold
+	}
+	load := float64(filter.Count) / float64(capacity)
+	if load < threshold {
+		return nil
+	}
+	severity := SeverityWarning
+	if load >= 0.95 {
+		severity = SeverityCritical
+	}
+	return &Alert{
+		Kind:     KindFilterNearlyFull,
+		Severity: severity,
+		Summary:  fmt.Sprintf("revocation filter is %.0f %% full", load*100),
+		Details: map[string]string{
+			"count":    fmt.Sprint(filter.Count),
+			"capacity": fmt.Sprint(capacity),
+		},
+		DedupKey: KindFilterNearlyFull + "/" + severity.String(),
+	}
+}
+
+// RekeyAlert reports a completed rekey of the registry signing key
+func RekeyAlert(keyID string, performedAt time.Time) *Alert {
+	return &Alert{
+		Kind:     KindRekeyPerformed,
+		Severity: SeverityInfo,
+		Summary:  "registry signing key was rotated",
+		Details:  map[string]string{"keyId": keyID},
+		Time:     performedAt,
+		DedupKey: KindRekeyPerformed + "/" + keyID,
+	}
+}
+
+// PeerDivergenceAlert reports peers whose answer to a quorum read differed from the other peers,
+// a sign of a corrupted or forked peer state
+func PeerDivergenceAlert(function string, peers []string) *Alert {
+	return &Alert{
+		Kind:     KindPeerDivergence,
+		Severity: SeverityCritical,
+		Summary:  fmt.Sprintf("%d peers diverged from the quorum on %s", len(peers), function),
+		Details: map[string]string{
+			"function": function,
+			"peers":    strings.Join(peers, ","),
+		},
+		DedupKey: KindPeerDivergence + "/" + strings.Join(peers, ","),
+	}
+}
+
+// ResizePlannedAlert reports a resize of the registry filter due at the start of the next maintenance window
+func ResizePlannedAlert(buckets uint, targetBuckets uint, loadFactor float64, window time.Time) *Alert {
+	return &Alert{
+		Kind:     KindResizePlanned,
+		Severity: SeverityWarning,
+		Summary:  fmt.Sprintf("revocation filter is %.0f %% full, resize planned for %s", loadFactor*100, window.Format(time.RFC3339)),
+		Details: map[string]string{
+			"buckets":       fmt.Sprint(buckets),
+			"targetBuckets": fmt.Sprint(targetBuckets),
+		},
+		DedupKey: fmt.Sprintf("%s/%d", KindResizePlanned, targetBuckets),
+	}
+}
+
+// ResizeCompletedAlert reports a verified resize of the registry filter
+func ResizeCompletedAlert(buckets uint, targetBuckets uint, loadFactor float64) *Alert {
+	return &Alert{
+		Kind:     KindResizeCompleted,
+		Severity: SeverityInfo,
+		Summary:  fmt.Sprintf("revocation filter resized to %d buckets, now %.0f %% full", targetBuckets, loadFactor*100),
+		Details: map[string]string{
+			"buckets":       fmt.Sprint(buckets),
+			"targetBuckets": fmt.Sprint(targetBuckets),
+		},
+		DedupKey: fmt.Sprintf("%s/%d", KindResizeCompleted, targetBuckets),
+	}
+}
+
+// ResizeFailedAlert reports a failed resize of the registry filter. A registry left frozen needs an
+// operator to check the filter state before unfreezing it.
+func ResizeFailedAlert(targetBuckets uint, frozen bool, err error) *Alert {
+	summary := "revocation filter resize failed"
+	if frozen {
+		summary += ", registry stays frozen"
+	}
+	return &Alert{
+		Kind:     KindResizeFailed,
+		Severity: SeverityCritical,
+		Summary:  summary,
+		Details: map[string]string{
+			"targetBuckets": fmt.Sprint(targetBuckets),
+			"error":         err.Error(),
+		},
+		DedupKey: fmt.Sprintf("%s/%d", KindResizeFailed, targetBuckets),
+	}
+}
+
+// EndorsementFailureMonitor raises an alert when at least Threshold endorsement failures
+// are recorded within Window
+type EndorsementFailureMonitor struct {
+	Threshold int
+	Window    time.Duration
+
+	mu       sync.Mutex
+	failures []time.Time
+}
+
+// RecordFailure records an endorsement failure and returns an alert when the failures within the window reach the threshold
+func (m *EndorsementFailureMonitor) RecordFailure(at time.Time, err error) *Alert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.failures = append(m.failures, at)
+	cutoff := at.Add(-m.Window)
+	kept := m.failures[:0]
+	for _, failure := range m.failures {
+		if failure.After(cutoff) {
+			kept = append(kept, failure)
+		}
+	}
+	m.failures = kept
+
+	if m.Threshold <= 0 || len(m.failures) < m.Threshold {
+		return nil
+	}
+	details := map[string]string{
+		"failures": fmt.Sprint(len(m.failures)),
+		"window":   m.Window.String(),
+	}
+	if err != nil {
+		details["lastError"] = err.Error()
+	}
+	return &Alert{
+		Kind:     KindEndorsementFailures,
+		Severity: SeverityWarning,
+		Summary:  fmt.Sprintf("%d endorsement failures within %s", len(m.failures), m.Window),
+		Details:  details,
+		Time:     at,
+	}
+}
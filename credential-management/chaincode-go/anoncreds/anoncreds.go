@@ -0,0 +1,247 @@
+// Package anoncreds bridges Hyperledger AnonCreds revocation registries and the cuckoo filter registry,
+// so deployments issuing both AnonCreds and W3C credentials keep one on-chain revocation source.
+//
+// An AnonCreds credential is identified by its revocation registry and its credential revocation index
+// (credRevId, starting at 1). Each one maps to a FingerprintV1 registry entry of the registry's issuer and
+// "<revRegDefId>#<credRevId>", so imported entries also pass strict mode.
+//
+// Import applies a revocation registry delta to the registry. Export produces the revocation status list
+// of the AnonCreds specification, the revocation state a holder combines with the tails file to build
+// its non-revocation proof. The tails file itself and the accumulator are CL-signature material derived
+// from the issuer's private registry key; they cannot be computed from the registry and stay with the
+// AnonCreds issuer.
+package anoncreds
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pherbke/credential-management/chaincode-go/client"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"strconv"
+)
+
+// lookupChunkSize is the number of fingerprints looked up per BatchLookup evaluation
+const lookupChunkSize = 1000
+
+// Registry is an AnonCreds revocation registry definition, reduced to what the mapping needs
+type Registry struct {
+	RevRegDefID string `json:"revRegDefId"`
+	IssuerID    string `json:"issuerId"`
+	MaxCredNum  uint32 `json:"maxCredNum"`
+}
+
+// RegistryDelta is an AnonCreds revocation registry delta, as published to an Indy ledger
+type RegistryDelta struct {
+	Ver   string     `json:"ver"`
+	Value DeltaValue `json:"value"`
+}
+
+// DeltaValue lists the credential revocation indexes a delta issued (unrevoked) and revoked
+type DeltaValue struct {
+	PrevAccum string   `json:"prevAccum,omitempty"`
+	Accum     string   `json:"accum"`
+	Issued    []uint32 `json:"issued,omitempty"`
+	Revoked   []uint32 `json:"revoked,omitempty"`
+}
+
+// StatusList is an AnonCreds revocation status list. RevocationList holds one entry per credential
+// revocation index, 1 if revoked: credRevId n is at position n-1.
+type StatusList struct {
+	RevRegDefID        string `json:"revRegDefId"`
+	IssuerID           string `json:"issuerId"`
+	RevocationList     []int  `json:"revocationList"`
+	CurrentAccumulator string `json:"currentAccumulator,omitempty"` // Only the AnonCreds issuer can compute it
+	Timestamp          int64  `json:"timestamp"`
+}
+
+// Fingerprint returns the registry entry of a credential of the registry
+func (r *Registry) Fingerprint(credRevID uint32) (string, error) {
+	if credRevID == 0 || credRevID > r.MaxCredNum {
+		return "", fmt.Errorf("credential revocation index %d is outside 1..%d of %s", credRevID, r.MaxCredNum, r.RevRegDefID)
+	}
+	return cuckoofilter.ComputeFingerprint(cuckoofilter.FingerprintV1, r.IssuerID, r.RevRegDefID+"#"+strconv.FormatUint(uint64(credRevID), 10))
+}
+
+// Plan is the registry change a delta asks for
+type Plan struct {
+	Revoke   []string `json:"revoke"`   // Fingerprints to insert
+	Unrevoke []string `json:"unrevoke"` // Fingerprints to delete
+}
+
+// Plan maps a delta to registry fingerprints. An index both issued and revoked is rejected, the delta
+// is ambiguous.
+func (r *Registry) Plan(delta *RegistryDelta) (*Plan, error) {
+	revoked := make(map[uint32]bool, len(delta.Value.Revoked))
+	for _, index := range delta.Value.Revoked {
+		revoked[index] = true
+	}
+	plan := &Plan{Revoke: []string{}, Unrevoke: []string{}}
+	for _, index := range delta.Value.Issued {
+		if revoked[index] {
+			return nil, fmt.Errorf("credential revocation index %d is both issued and revoked", index)
+		}
+		fingerprint, err := r.Fingerprint(index)
+		if err != nil {
+			return nil, err
+		}
+		plan.Unrevoke = append(plan.Unrevoke, fingerprint)
+	}
+	for _, index := range delta.Value.Revoked {
+		fingerprint, err := r.Fingerprint(index)
+		if err != nil {
+			return nil, err
+		}
+		plan.Revoke = append(plan.Revoke, fingerprint)
+	}
+	return plan, nil
+}
+
+// ImportResult counts what an import changed
+type ImportResult struct {
+	Revoked   int `json:"revoked"`
+	Unrevoked int `json:"unrevoked"`
+	Unchanged int `json:"unchanged"` // Entries already in the requested state
+}
+
+// Ledger reads and writes the cuckoo filter registry on behalf of the adapter
+type Ledger struct {
+	FilterID string                                               // Filter the AnonCreds entries go to, empty for the default filter
+	Lookup   func(fingerprints []string) (map[string]bool, error) // Revocation status per fingerprint, e.g. by evaluating BatchLookup
+	Submit   client.SubmitFunc
+}
+
+// Import applies a delta to the registry. Entries already in the requested state are left alone, so
+// importing a delta again, or a delta overlapping an earlier one, changes nothing.
+func (l *Ledger) Import(registry *Registry, delta *RegistryDelta) (*ImportResult, error) {
+	plan, err := registry.Plan(delta)
+	if err != nil {
+		return nil, err
+	}
+	status, err := l.lookup(append(append([]string{}, plan.Revoke...), plan.Unrevoke...))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{}
+	revoke := []string{}
+	for _, fingerprint := range plan.Revoke {
+		if status[fingerprint] {
+			result.Unchanged++
+		} else {
+			revoke = append(revoke, fingerprint)
+		}
+	}
+	unrevoke := []string{}
+	for _, fingerprint := range plan.Unrevoke {
+		if status[fingerprint] {
+			unrevoke = append(unrevoke, fingerprint)
+		} else {
+			result.Unchanged++
+		}
+	}
+
+	if len(revoke) > 0 {
+		revokeJSON, err := json.Marshal(revoke)
+		if err != nil {
+			return nil, err
+		}
+		if _, _, err := l.Submit("BatchInsert", l.FilterID, string(revokeJSON)); err != nil {
+			return nil, fmt.Errorf("error revoking %d credentials: %v", len(revoke), err)
+		}
+		result.Revoked = len(revoke)
+	}
+	if len(unrevoke) > 0 {
+		unrevokeJSON, err := json.Marshal(unrevoke)
+		if err != nil {
+			return nil, err
+		}
+		// Not atomic, credentials revoked by another client are reported as unauthorized
+		_, payload, err := l.Submit("BatchDelete", l.FilterID, string(unrevokeJSON), "false")
+		if err != nil {
+			return nil, fmt.Errorf("error unrevoking %d credentials: %v", len(unrevoke), err)
+		}
+		var deleted cuckoofilter.BatchDeleteResult
+		if err := json.Unmarshal(payload, &deleted); err != nil {
+			return nil, fmt.Errorf("error decoding BatchDelete result: %v", err)
+		}
+		if deleted.Unauthorized > 0 {
+			return nil, fmt.Errorf("%d credentials were revoked by another client and could not be unrevoked", deleted.Unauthorized)
+		}
+		result.Unrevoked = deleted.Deleted
+		result.Unchanged += deleted.NotFound
+	}
+	return result, nil
+}
+
+// Export builds the revocation status list of a registry from the cuckoo filter registry. Like every
+// filter lookup it may report a credential that was never revoked as revoked, with the false positive
+// rate of the filter, but never the reverse.
+func (l *Ledger) Export(registry *Registry, timestamp int64) (*StatusList, error) {
+	fingerprints := make([]string, registry.MaxCredNum)
+	for i := range fingerprints {
+		fingerprint, err := registry.Fingerprint(uint32(i + 1))
+		if err != nil {
+			return nil, err
+		}
+		fingerprints[i] = fingerprint
+	}
+	status, err := l.lookup(fingerprints)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &StatusList{
+		RevRegDefID:    registry.RevRegDefID,
+		IssuerID:       registry.IssuerID,
+		RevocationList: make([]int, registry.MaxCredNum),
+		Timestamp:      timestamp,
+	}
+	for i, fingerprint := range fingerprints {
+		if status[fingerprint] {
+			list.RevocationList[i] = 1
+		}
+	}
+	return list, nil
+}
+
+// lookup looks up fingerprints in chunks of lookupChunkSize
+func (l *Ledger) lookup(fingerprints []string) (map[string]bool, error) {
+	status := make(map[string]bool, len(fingerprints))
+	for start := 0; start < len(fingerprints); start += lookupChunkSize {
+		end := start + lookupChunkSize
+		if end > len(fingerprints) {
+			end = len(fingerprints)
+		}
+		chunk, err := l.Lookup(fingerprints[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("error looking up revocation status: %v", err)
+		}
+		for fingerprint, revoked := range chunk {
+			status[fingerprint] = revoked
+		}
+	}
+	return status, nil
+}
+
+// Delta returns the registry delta that turns the status list from into to, for AnonCreds consumers
+// that process deltas instead of full lists. Accumulators are left empty.
+func Delta(from, to *StatusList) (*RegistryDelta, error) {
+	if from.RevRegDefID != to.RevRegDefID {
+		return nil, fmt.Errorf("status lists of %s and %s cannot be compared", from.RevRegDefID, to.RevRegDefID)
+	}
+	if len(from.RevocationList) != len(to.RevocationList) {
+		return nil, fmt.Errorf("status lists have %d and %d entries", len(from.RevocationList), len(to.RevocationList))
+	}
+	delta := &RegistryDelta{Ver: "1.0"}
+	for i := range to.RevocationList {
+		if from.RevocationList[i] == to.RevocationList[i] {
+			continue
+		}
+		if to.RevocationList[i] == 1 {
+			delta.Value.Revoked = append(delta.Value.Revoked, uint32(i+1))
+		} else {
+			delta.Value.Issued = append(delta.Value.Issued, uint32(i+1))
+		}
+	}
+	return delta, nil
+}
@@ -0,0 +1,101 @@
+package anoncreds_test
+
+import (
+	"encoding/json"
+	"github.com/pherbke/credential-management/chaincode-go/anoncreds"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+var testRegistry = &anoncreds.Registry{
+	RevRegDefID: "did:indy:sovrin:staging:WgWxqztrNooG92RXvxSTWv/anoncreds/v0/REV_REG_DEF/75206/default/CL_ACCUM/tag",
+	IssuerID:    "did:indy:sovrin:staging:WgWxqztrNooG92RXvxSTWv",
+	MaxCredNum:  8,
+}
+
+// newLedger deploys the registry in strict mode and returns a Ledger submitting as an issuer
+func newLedger(t *testing.T) (*anoncreds.Ledger, *int) {
+	sim, err := simulator.New("credential-management", &cuckoofilter.SmartContract{})
+	require.NoError(t, err)
+	admin, err := simulator.NewIdentityWithAttributes("Org1MSP", "registry-admin", map[string]string{cuckoofilter.RegistryAdminAttribute: "true"})
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "Init", "", "100", "4", "0")
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "SetStrictMode", "true")
+	require.NoError(t, err)
+
+	issuer, err := simulator.NewIdentityWithAttributes("Org1MSP", "anoncreds-issuer", map[string]string{cuckoofilter.DIDAttribute: testRegistry.IssuerID})
+	require.NoError(t, err)
+	submissions := 0
+	return &anoncreds.Ledger{
+		Lookup: func(fingerprints []string) (map[string]bool, error) {
+			fingerprintsJSON, err := json.Marshal(fingerprints)
+			require.NoError(t, err)
+			payload, err := sim.Evaluate(issuer, "BatchLookup", "", string(fingerprintsJSON))
+			if err != nil {
+				return nil, err
+			}
+			var status map[string]bool
+			return status, json.Unmarshal(payload, &status)
+		},
+		Submit: func(function string, args ...string) (string, []byte, error) {
+			submissions++
+			tx, err := sim.Submit(issuer, function, args...)
+			if err != nil {
+				return "", nil, err
+			}
+			return tx.ID, tx.Payload, nil
+		},
+	}, &submissions
+}
+
+func TestImport(t *testing.T) {
+	ledger, submissions := newLedger(t)
+
+	result, err := ledger.Import(testRegistry, &anoncreds.RegistryDelta{Ver: "1.0", Value: anoncreds.DeltaValue{Revoked: []uint32{2, 5, 7}}})
+	require.NoError(t, err)
+	require.Equal(t, &anoncreds.ImportResult{Revoked: 3}, result)
+
+	list, err := ledger.Export(testRegistry, 1700000000)
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 1, 0, 0, 1, 0, 1, 0}, list.RevocationList)
+	require.Equal(t, testRegistry.RevRegDefID, list.RevRegDefID)
+	require.Equal(t, testRegistry.IssuerID, list.IssuerID)
+	require.Equal(t, int64(1700000000), list.Timestamp)
+
+	// Importing a delta again changes nothing and submits nothing
+	result, err = ledger.Import(testRegistry, &anoncreds.RegistryDelta{Ver: "1.0", Value: anoncreds.DeltaValue{Revoked: []uint32{2, 5}, Issued: []uint32{3}}})
+	require.NoError(t, err)
+	require.Equal(t, &anoncreds.ImportResult{Unchanged: 3}, result)
+	require.Equal(t, 1, *submissions)
+
+	result, err = ledger.Import(testRegistry, &anoncreds.RegistryDelta{Ver: "1.0", Value: anoncreds.DeltaValue{Revoked: []uint32{8}, Issued: []uint32{5}}})
+	require.NoError(t, err)
+	require.Equal(t, &anoncreds.ImportResult{Revoked: 1, Unrevoked: 1}, result)
+
+	next, err := ledger.Export(testRegistry, 1700000060)
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 1, 0, 0, 0, 0, 1, 1}, next.RevocationList)
+
+	// The exported lists convert back into the delta that was imported
+	delta, err := anoncreds.Delta(list, next)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{8}, delta.Value.Revoked)
+	require.Equal(t, []uint32{5}, delta.Value.Issued)
+}
+
+func TestPlan_Invalid(t *testing.T) {
+	_, err := testRegistry.Plan(&anoncreds.RegistryDelta{Value: anoncreds.DeltaValue{Revoked: []uint32{9}}})
+	require.EqualError(t, err, "credential revocation index 9 is outside 1..8 of "+testRegistry.RevRegDefID)
+	_, err = testRegistry.Plan(&anoncreds.RegistryDelta{Value: anoncreds.DeltaValue{Revoked: []uint32{3}, Issued: []uint32{3}}})
+	require.EqualError(t, err, "credential revocation index 3 is both issued and revoked")
+
+	// Fingerprints are canonical FingerprintV1 fingerprints of the registry entry
+	fingerprint, err := testRegistry.Fingerprint(1)
+	require.NoError(t, err)
+	expected, err := cuckoofilter.ComputeFingerprint(cuckoofilter.FingerprintV1, testRegistry.IssuerID, testRegistry.RevRegDefID+"#1")
+	require.NoError(t, err)
+	require.Equal(t, expected, fingerprint)
+}
@@ -0,0 +1,373 @@
+// Package bulk reads revocation lists from CSV and JSONL files and writes the registry's revocations
+// back out in the same formats, for revocctl import and export.
+//
+// Each row of an import names one credential in one of three ways: its registry fingerprint, the
+// issuer DID and credential id it is derived from, or the JWT credential itself. Rows are converted to
+// fingerprints the way the chaincode does, and rows that cannot be converted are reported with their
+// line number instead of stopping the import, so one pass lists every problem of a file.
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pherbke/credential-management/chaincode-go/core"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+)
+
+// File formats
+const (
+	FormatCSV   = "csv"   // Header row with the column names, then one row per credential
+	FormatJSONL = "jsonl" // One JSON object per line, the column names are its fields
+)
+
+// Kinds of the value column of an import
+const (
+	KindFingerprint  = "fingerprint"   // Canonical FingerprintV1 fingerprint
+	KindCredentialID = "credential-id" // Credential id, fingerprinted with the issuer of the row
+	KindJWT          = "jwt"           // JWT credential, fingerprinted with the normalizer
+)
+
+// Mapping tells Read which columns hold the credential and how to turn it into a fingerprint
+type Mapping struct {
+	Column       string                            // Column holding the credential
+	Kind         string                            // Kind of the column values
+	IssuerColumn string                            // Column of the issuer DID, for KindCredentialID
+	Issuer       string                            // Issuer DID of rows without an issuer column or value
+	ReasonColumn string                            // Optional column of the revocation reason
+	Normalizer   cuckoofilter.CredentialNormalizer // Derives the identity of JWT credentials, for KindJWT
+}
+
+// Validate checks that the mapping can fingerprint rows
+func (m *Mapping) Validate() error {
+	if m.Column == "" {
+		return errors.New("no credential column given")
+	}
+	switch m.Kind {
+	case KindFingerprint:
+	case KindCredentialID:
+		if m.IssuerColumn == "" && m.Issuer == "" {
+			return errors.New("credential ids need an issuer column or an issuer")
+		}
+	case KindJWT:
+		if m.Normalizer == nil {
+			return errors.New("JWT credentials need a normalizer")
+		}
+	default:
+		return fmt.Errorf("unknown column kind %q, want %s, %s or %s", m.Kind, KindFingerprint, KindCredentialID, KindJWT)
+	}
+	return nil
+}
+
+// Row is a row of an import converted to a fingerprint
+type Row struct {
+	Line        int    `json:"line"`
+	Value       string `json:"value"`
+	Fingerprint string `json:"fingerprint"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// RowError is a row of an import that was rejected
+type RowError struct {
+	Line  int
+	Value string
+	Err   error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// Report is the result of reading an import file
+type Report struct {
+	Rows   []Row       // Rows that were converted, in file order
+	Errors []*RowError // Rows that were rejected, in file order
+}
+
+// Read converts the rows of an import file to fingerprints. Rows with a missing or invalid value, and
+// rows whose fingerprint an earlier row already has, are added to the errors of the report. An error
+// is only returned if the file itself cannot be read.
+func Read(r io.Reader, format string, mapping Mapping) (*Report, error) {
+	if err := mapping.Validate(); err != nil {
+		return nil, err
+	}
+	report := &Report{Rows: []Row{}, Errors: []*RowError{}}
+	lines := map[string]int{} // Line of the first row of each fingerprint
+	add := func(line int, fields map[string]string) {
+		row, err := mapping.convert(line, fields)
+		if err == nil {
+			if first, ok := lines[row.Fingerprint]; ok {
+				err = fmt.Errorf("duplicate of line %d", first)
+			}
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, &RowError{Line: line, Value: fields[mapping.Column], Err: err})
+			return
+		}
+		lines[row.Fingerprint] = line
+		report.Rows = append(report.Rows, *row)
+	}
+
+	switch format {
+	case FormatCSV:
+		return report, readCSV(r, mapping, add)
+	case FormatJSONL:
+		return report, readJSONL(r, add)
+	default:
+		return nil, fmt.Errorf("unknown format %q, want %s or %s", format, FormatCSV, FormatJSONL)
+	}
+}
+
+// readCSV passes the rows of a CSV file with a header row to add, keyed by column name
+func readCSV(r io.Reader, mapping Mapping, add func(line int, fields map[string]string)) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading CSV header: %v", err)
+	}
+	columns := map[string]bool{}
+	for i, name := range header {
+		header[i] = strings.TrimSpace(name)
+		columns[header[i]] = true
+	}
+	for _, column := range []string{mapping.Column, mapping.IssuerColumn, mapping.ReasonColumn} {
+		if column != "" && !columns[column] {
+			return fmt.Errorf("CSV header has no column %q", column)
+		}
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return fmt.Errorf("error reading CSV: %v", err)
+			}
+			add(parseErr.Line, map[string]string{"": parseErr.Err.Error()})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		fields := map[string]string{}
+		for i, value := range record {
+			if i < len(header) && header[i] != "" {
+				fields[header[i]] = value
+			}
+		}
+		add(line, fields)
+	}
+}
+
+// readJSONL passes the objects of a JSONL file to add. Blank lines are skipped, string, number and
+// boolean fields are kept as their text.
+func readJSONL(r io.Reader, add func(line int, fields map[string]string)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var object map[string]interface{}
+		if err := json.Unmarshal(text, &object); err != nil {
+			add(line, map[string]string{"": fmt.Sprintf("invalid JSON: %v", err)})
+			continue
+		}
+		fields := map[string]string{}
+		for name, value := range object {
+			if name == "" {
+				continue
+			}
+			switch value := value.(type) {
+			case string:
+				fields[name] = value
+			case float64, bool:
+				fields[name] = fmt.Sprint(value)
+			}
+		}
+		add(line, fields)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading JSONL: %v", err)
+	}
+	return nil
+}
+
+// convert fingerprints the credential of a row. Rows the reader could not parse carry the problem
+// under the empty column name.
+func (m *Mapping) convert(line int, fields map[string]string) (*Row, error) {
+	if problem, ok := fields[""]; ok {
+		return nil, errors.New(problem)
+	}
+	value := strings.TrimSpace(fields[m.Column])
+	if value == "" {
+		return nil, fmt.Errorf("column %q is empty", m.Column)
+	}
+	row := &Row{Line: line, Value: value, Reason: strings.TrimSpace(fields[m.ReasonColumn])}
+
+	switch m.Kind {
+	case KindFingerprint:
+		if err := checkFingerprint(value); err != nil {
+			return nil, err
+		}
+		row.Fingerprint = value
+	case KindCredentialID:
+		issuer := strings.TrimSpace(fields[m.IssuerColumn])
+		if issuer == "" {
+			issuer = m.Issuer
+		}
+		if issuer == "" {
+			return nil, fmt.Errorf("column %q is empty", m.IssuerColumn)
+		}
+		status, err := cuckoofilter.NewCredentialStatus(issuer, value)
+		if err != nil {
+			return nil, err
+		}
+		row.Fingerprint = status.Fingerprint
+	case KindJWT:
+		status, err := cuckoofilter.NewTokenCredentialStatus(m.Normalizer, value)
+		if err != nil {
+			return nil, err
+		}
+		row.Fingerprint = status.Fingerprint
+	}
+	return row, nil
+}
+
+// checkFingerprint checks that a value is a canonical FingerprintV1 fingerprint
+func checkFingerprint(value string) error {
+	decoded, err := hex.DecodeString(value)
+	if err != nil || len(decoded) != core.FingerprintV1Length || hex.EncodeToString(decoded) != value {
+		return fmt.Errorf("%q is not a canonical %s fingerprint of %d lowercase hex characters", value, core.FingerprintV1, 2*core.FingerprintV1Length)
+	}
+	return nil
+}
+
+// Batch is a BatchInsert invocation in the form of the peer CLI: Args for -c and Transient, base64
+// encoded, for --transient
+type Batch struct {
+	Args      []string          `json:"Args"`
+	Transient map[string]string `json:"transient,omitempty"`
+}
+
+// Batches splits the converted rows into BatchInsert invocations on a filter of at most size items.
+// Consecutive rows with the same reason share a batch, which records the reason in the revocation
+// audit.
+func (r *Report) Batches(filterID string, size int) ([]Batch, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("batch size %d must be positive", size)
+	}
+	batches := []Batch{}
+	for start := 0; start < len(r.Rows); {
+		reason := r.Rows[start].Reason
+		end := start
+		for end < len(r.Rows) && end-start < size && r.Rows[end].Reason == reason {
+			end++
+		}
+		fingerprints := make([]string, 0, end-start)
+		for _, row := range r.Rows[start:end] {
+			fingerprints = append(fingerprints, row.Fingerprint)
+		}
+		fingerprintsJSON, err := json.Marshal(fingerprints)
+		if err != nil {
+			return nil, err
+		}
+		batch := Batch{Args: []string{"BatchInsert", filterID, string(fingerprintsJSON)}}
+		if reason != "" {
+			batch.Transient = map[string]string{cuckoofilter.RevocationReasonTransientKey: base64.StdEncoding.EncodeToString([]byte(reason))}
+		}
+		batches = append(batches, batch)
+		start = end
+	}
+	return batches, nil
+}
+
+// ReadRevocations reads the pages returned by evaluating ListRevocations, e.g. the outputs of several
+// peer chaincode query calls appended to one file
+func ReadRevocations(r io.Reader) ([]cuckoofilter.Revocation, error) {
+	decoder := json.NewDecoder(r)
+	revocations := []cuckoofilter.Revocation{}
+	for {
+		var page cuckoofilter.RevocationsPage
+		if err := decoder.Decode(&page); err == io.EOF {
+			return revocations, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("error decoding revocations page: %v", err)
+		}
+		revocations = append(revocations, page.Revocations...)
+	}
+}
+
+// exportColumns are the CSV columns and JSONL fields of an export
+var exportColumns = []string{"credentialId", "filterId", "namespace", "issuerMspId", "issuerDid", "revokedAt", "reason"}
+
+// Export writes revocations as CSV or JSONL. Exported files import again with the credentialId column
+// of kind KindFingerprint and the reason column.
+func Export(w io.Writer, format string, revocations []cuckoofilter.Revocation) error {
+	switch format {
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(exportColumns); err != nil {
+			return err
+		}
+		for _, revocation := range revocations {
+			if err := writer.Write(exportRecord(revocation)); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	case FormatJSONL:
+		encoder := json.NewEncoder(w)
+		for _, revocation := range revocations {
+			object := map[string]string{}
+			for i, value := range exportRecord(revocation) {
+				if value != "" {
+					object[exportColumns[i]] = value
+				}
+			}
+			if err := encoder.Encode(object); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown format %q, want %s or %s", format, FormatCSV, FormatJSONL)
+	}
+}
+
+// exportRecord returns the values of the exportColumns of a revocation
+func exportRecord(revocation cuckoofilter.Revocation) []string {
+	revokedAt := ""
+	if !revocation.RevokedAt.IsZero() {
+		revokedAt = revocation.RevokedAt.UTC().Format(time.RFC3339)
+	}
+	return []string{
+		revocation.CredentialID,
+		revocation.FilterID,
+		revocation.Namespace,
+		revocation.Issuer.MSPID,
+		revocation.Issuer.DID,
+		revokedAt,
+		revocation.Reason,
+	}
+}
@@ -0,0 +1,133 @@
+package bulk_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pherbke/credential-management/chaincode-go/bulk"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func fingerprint(t *testing.T, issuer string, credentialID string) string {
+	status, err := cuckoofilter.NewCredentialStatus(issuer, credentialID)
+	require.NoError(t, err)
+	return status.Fingerprint
+}
+
+func TestRead_CSVCredentialIDs(t *testing.T) {
+	file := "jti,issuer,reason\n" +
+		"urn:uuid:1,did:key:issuer,key compromise\n" +
+		"urn:uuid:2,,\n" +
+		",did:key:issuer,\n" +
+		"urn:uuid:1,did:key:issuer,again\n"
+	report, err := bulk.Read(strings.NewReader(file), bulk.FormatCSV, bulk.Mapping{
+		Column:       "jti",
+		Kind:         bulk.KindCredentialID,
+		IssuerColumn: "issuer",
+		Issuer:       "did:key:default",
+		ReasonColumn: "reason",
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, []bulk.Row{
+		{Line: 2, Value: "urn:uuid:1", Fingerprint: fingerprint(t, "did:key:issuer", "urn:uuid:1"), Reason: "key compromise"},
+		{Line: 3, Value: "urn:uuid:2", Fingerprint: fingerprint(t, "did:key:default", "urn:uuid:2")},
+	}, report.Rows)
+	require.Len(t, report.Errors, 2)
+	require.Equal(t, "line 4: column \"jti\" is empty", report.Errors[0].Error())
+	require.Equal(t, "line 5: duplicate of line 2", report.Errors[1].Error())
+}
+
+func TestRead_CSVMissingColumn(t *testing.T) {
+	_, err := bulk.Read(strings.NewReader("id\nurn:uuid:1\n"), bulk.FormatCSV, bulk.Mapping{Column: "jti", Kind: bulk.KindFingerprint})
+	require.ErrorContains(t, err, `no column "jti"`)
+}
+
+func TestRead_JSONLFingerprintsAndJWTs(t *testing.T) {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"iss": "did:key:issuer", "jti": "urn:uuid:3"}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+	canonical := fingerprint(t, "did:key:issuer", "urn:uuid:1")
+	file := `{"value": "` + canonical + `"}` + "\n" +
+		"\n" +
+		`{"value": "` + strings.ToUpper(canonical) + `"}` + "\n" +
+		`{"value": 42}` + "\n" +
+		"not json\n"
+	report, err := bulk.Read(strings.NewReader(file), bulk.FormatJSONL, bulk.Mapping{Column: "value", Kind: bulk.KindFingerprint})
+	require.NoError(t, err)
+	require.Equal(t, []bulk.Row{{Line: 1, Value: canonical, Fingerprint: canonical}}, report.Rows)
+	require.Len(t, report.Errors, 3)
+	require.Equal(t, []int{3, 4, 5}, []int{report.Errors[0].Line, report.Errors[1].Line, report.Errors[2].Line})
+
+	normalizer, err := cuckoofilter.LookupNormalizer("")
+	require.NoError(t, err)
+	report, err = bulk.Read(strings.NewReader(`{"token": "`+token+`"}`), bulk.FormatJSONL, bulk.Mapping{Column: "token", Kind: bulk.KindJWT, Normalizer: normalizer})
+	require.NoError(t, err)
+	require.Empty(t, report.Errors)
+	require.Equal(t, fingerprint(t, "did:key:issuer", "urn:uuid:3"), report.Rows[0].Fingerprint)
+}
+
+func TestRead_InvalidMapping(t *testing.T) {
+	for name, mapping := range map[string]bulk.Mapping{
+		"no column":             {Kind: bulk.KindFingerprint},
+		"unknown kind":          {Column: "id", Kind: "hash"},
+		"credential-id, issuer": {Column: "id", Kind: bulk.KindCredentialID},
+		"jwt, normalizer":       {Column: "id", Kind: bulk.KindJWT},
+	} {
+		_, err := bulk.Read(strings.NewReader(""), bulk.FormatCSV, mapping)
+		require.Error(t, err, name)
+	}
+	_, err := bulk.Read(strings.NewReader(""), "xlsx", bulk.Mapping{Column: "id", Kind: bulk.KindFingerprint})
+	require.Error(t, err)
+}
+
+func TestReport_Batches(t *testing.T) {
+	report := &bulk.Report{Rows: []bulk.Row{
+		{Fingerprint: "a"}, {Fingerprint: "b"}, {Fingerprint: "c"}, {Fingerprint: "d", Reason: "superseded"},
+	}}
+	batches, err := report.Batches("tenant-a", 2)
+	require.NoError(t, err)
+	require.Equal(t, []bulk.Batch{
+		{Args: []string{"BatchInsert", "tenant-a", `["a","b"]`}},
+		{Args: []string{"BatchInsert", "tenant-a", `["c"]`}},
+		{Args: []string{"BatchInsert", "tenant-a", `["d"]`}, Transient: map[string]string{cuckoofilter.RevocationReasonTransientKey: "c3VwZXJzZWRlZA=="}},
+	}, batches)
+
+	_, err = report.Batches("", 0)
+	require.Error(t, err)
+}
+
+func TestExport_RoundTrip(t *testing.T) {
+	credentialID := fingerprint(t, "did:key:issuer", "urn:uuid:1")
+	page, err := json.Marshal(cuckoofilter.RevocationsPage{Revocations: []cuckoofilter.Revocation{{
+		CredentialID: credentialID,
+		Issuer:       cuckoofilter.Inserter{MSPID: "Org1MSP", DID: "did:key:issuer"},
+		RevokedAt:    time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		Reason:       "key compromise, reported",
+	}}})
+	require.NoError(t, err)
+	revocations, err := bulk.ReadRevocations(bytes.NewReader(append(append(page, '\n'), page...)))
+	require.NoError(t, err)
+	require.Len(t, revocations, 2)
+
+	var csvExport bytes.Buffer
+	require.NoError(t, bulk.Export(&csvExport, bulk.FormatCSV, revocations[:1]))
+	require.Equal(t, "credentialId,filterId,namespace,issuerMspId,issuerDid,revokedAt,reason\n"+
+		credentialID+",,,Org1MSP,did:key:issuer,2024-03-01T12:00:00Z,\"key compromise, reported\"\n", csvExport.String())
+
+	var jsonlExport bytes.Buffer
+	require.NoError(t, bulk.Export(&jsonlExport, bulk.FormatJSONL, revocations[:1]))
+	require.JSONEq(t, `{"credentialId": "`+credentialID+`", "issuerMspId": "Org1MSP", "issuerDid": "did:key:issuer",
+		"revokedAt": "2024-03-01T12:00:00Z", "reason": "key compromise, reported"}`, jsonlExport.String())
+
+	// Exports import again as fingerprints
+	for format, export := range map[string]*bytes.Buffer{bulk.FormatCSV: &csvExport, bulk.FormatJSONL: &jsonlExport} {
+		report, err := bulk.Read(export, format, bulk.Mapping{Column: "credentialId", Kind: bulk.KindFingerprint, ReasonColumn: "reason"})
+		require.NoError(t, err)
+		require.Empty(t, report.Errors, format)
+		require.Equal(t, []bulk.Row{{Line: report.Rows[0].Line, Value: credentialID, Fingerprint: credentialID, Reason: "key compromise, reported"}}, report.Rows)
+	}
+}
@@ -0,0 +1,228 @@
+// Package ceremony generates the registry signing keys in a key ceremony: every key is split with Shamir
+// secret sharing across custodians, and each share is encrypted to its custodian's P-256 key, so no single
+// person ever holds a signing key. A quorum of custodians reconstructs a key for a signing operation.
+//
+// A share is encrypted with ECIES: an ephemeral P-256 ECDH key agreement with the custodian key, SHA-256 of
+// the shared secret and both public keys as the AES-256-GCM key, and the key role and custodian name as
+// additional data, so shares cannot be swapped between keys or custodians unnoticed.
+package ceremony
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Roles of the registry signing keys
+const (
+	SnapshotSigningKey = "snapshot-signing" // Signs published filter snapshots
+	CoSigningKey       = "co-signing"       // Co-signs endorsements and revocation certificates
+)
+
+// Roles lists the keys a ceremony generates
+var Roles = []string{SnapshotSigningKey, CoSigningKey}
+
+// ErrKeyMismatch is returned when reconstructed shares do not yield the key recorded at the ceremony
+var ErrKeyMismatch = errors.New("reconstructed key does not match the ceremony public key")
+
+// Custodian holds shares of the registry signing keys
+type Custodian struct {
+	Name      string
+	PublicKey *ecdh.PublicKey
+}
+
+// EncryptedShare is a share of a signing key encrypted to a custodian
+type EncryptedShare struct {
+	Role       string    `json:"role"`
+	Custodian  string    `json:"custodian"`
+	X          byte      `json:"x"`
+	Total      int       `json:"total"`
+	Threshold  int       `json:"threshold"`
+	PublicKey  []byte    `json:"publicKey"` // PKIX encoded public key of the shared signing key
+	Ephemeral  []byte    `json:"ephemeral"` // Uncompressed ephemeral ECDH public key
+	Nonce      []byte    `json:"nonce"`
+	Ciphertext []byte    `json:"ciphertext"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// Result is the outcome of a key ceremony: the public signing keys and the shares to hand to the custodians
+type Result struct {
+	PublicKeys map[string]*ecdsa.PublicKey // By role
+	Shares     []*EncryptedShare
+}
+
+// Run generates a P-256 signing key for every role and splits it across the custodians, any threshold of
+// whom can reconstruct it. The private keys are discarded once split.
+func Run(custodians []Custodian, threshold int, roles []string, now time.Time) (*Result, error) {
+	names := make(map[string]bool)
+	for _, custodian := range custodians {
+		if custodian.Name == "" || names[custodian.Name] {
+			return nil, fmt.Errorf("custodian names must be unique and not empty")
+		}
+		if custodian.PublicKey == nil || custodian.PublicKey.Curve() != ecdh.P256() {
+			return nil, fmt.Errorf("custodian '%s' must have a P-256 key", custodian.Name)
+		}
+		names[custodian.Name] = true
+	}
+
+	result := &Result{PublicKeys: make(map[string]*ecdsa.PublicKey)}
+	for _, role := range roles {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("error generating %s key: %v", role, err)
+		}
+		keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding %s key: %v", role, err)
+		}
+		publicKeyDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding %s public key: %v", role, err)
+		}
+		shares, err := Split(keyDER, len(custodians), threshold)
+		if err != nil {
+			return nil, err
+		}
+		for i, share := range shares {
+			encrypted, err := encryptShare(role, custodians[i], share, publicKeyDER, now)
+			if err != nil {
+				return nil, err
+			}
+			result.Shares = append(result.Shares, encrypted)
+		}
+		result.PublicKeys[role] = &key.PublicKey
+	}
+	return result, nil
+}
+
+// Decrypt decrypts the share with the custodian's private key
+func (s *EncryptedShare) Decrypt(custodianKey *ecdh.PrivateKey) (*Share, error) {
+	ephemeral, err := ecdh.P256().NewPublicKey(s.Ephemeral)
+	if err != nil {
+		return nil, fmt.Errorf("share of %s has an invalid ephemeral key: %v", s.Custodian, err)
+	}
+	shared, err := custodianKey.ECDH(ephemeral)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting share of %s: %v", s.Custodian, err)
+	}
+	aead, err := shareCipher(shared, s.Ephemeral, custodianKey.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	y, err := aead.Open(nil, s.Nonce, s.Ciphertext, s.additionalData())
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting share of %s: wrong custodian key or tampered share", s.Custodian)
+	}
+	return &Share{X: s.X, Y: y, Total: s.Total, Threshold: s.Threshold}, nil
+}
+
+// Reconstruct decrypts the shares of a signing key, the i-th with the i-th custodian key, combines them
+// and checks the key against the public key recorded at the ceremony
+func Reconstruct(encrypted []*EncryptedShare, custodianKeys []*ecdh.PrivateKey) (*ecdsa.PrivateKey, error) {
+	if len(encrypted) == 0 {
+		return nil, ErrQuorumNotMet
+	}
+	if len(custodianKeys) != len(encrypted) {
+		return nil, fmt.Errorf("every share needs the key of its custodian")
+	}
+	shares := make([]*Share, len(encrypted))
+	for i, share := range encrypted {
+		if share.Role != encrypted[0].Role || !bytes.Equal(share.PublicKey, encrypted[0].PublicKey) {
+			return nil, fmt.Errorf("shares do not belong to the same key")
+		}
+		decrypted, err := share.Decrypt(custodianKeys[i])
+		if err != nil {
+			return nil, err
+		}
+		shares[i] = decrypted
+	}
+	keyDER, err := Combine(shares)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(keyDER)
+	if err != nil {
+		return nil, ErrKeyMismatch
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, ErrKeyMismatch
+	}
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil || !bytes.Equal(publicKeyDER, encrypted[0].PublicKey) {
+		return nil, ErrKeyMismatch
+	}
+	return key, nil
+}
+
+// Marshal encodes the share for storage
+func (s *EncryptedShare) Marshal() ([]byte, error) {
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// UnmarshalShare decodes a stored share
+func UnmarshalShare(shareJSON []byte) (*EncryptedShare, error) {
+	var share EncryptedShare
+	if err := json.Unmarshal(shareJSON, &share); err != nil {
+		return nil, fmt.Errorf("error decoding share: %v", err)
+	}
+	return &share, nil
+}
+
+func encryptShare(role string, custodian Custodian, share *Share, publicKeyDER []byte, now time.Time) (*EncryptedShare, error) {
+	ephemeral, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("error generating ephemeral key: %v", err)
+	}
+	shared, err := ephemeral.ECDH(custodian.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("error encrypting share for %s: %v", custodian.Name, err)
+	}
+	encrypted := &EncryptedShare{
+		Role:      role,
+		Custodian: custodian.Name,
+		X:         share.X,
+		Total:     share.Total,
+		Threshold: share.Threshold,
+		PublicKey: publicKeyDER,
+		Ephemeral: ephemeral.PublicKey().Bytes(),
+		Nonce:     make([]byte, 12),
+		CreatedAt: now.UTC(),
+	}
+	aead, err := shareCipher(shared, encrypted.Ephemeral, custodian.PublicKey.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(encrypted.Nonce); err != nil {
+		return nil, fmt.Errorf("error generating nonce: %v", err)
+	}
+	encrypted.Ciphertext = aead.Seal(nil, encrypted.Nonce, share.Y, encrypted.additionalData())
+	return encrypted, nil
+}
+
+// additionalData binds the ciphertext to the key role, the custodian and the share coordinate
+func (s *EncryptedShare) additionalData() []byte {
+	return []byte(fmt.Sprintf("%s\n%s\n%d\n%d\n%x", s.Role, s.Custodian, s.X, s.Threshold, s.PublicKey))
+}
+
+func shareCipher(shared []byte, ephemeral []byte, custodianKey []byte) (cipher.AEAD, error) {
+	hash := sha256.New()
+	hash.Write(shared)
+	hash.Write(ephemeral)
+	hash.Write(custodianKey)
+	block, err := aes.NewCipher(hash.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,93 @@
+package ceremony_test
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"github.com/pherbke/credential-management/chaincode-go/ceremony"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func newCustodians(t *testing.T, names ...string) ([]ceremony.Custodian, map[string]*ecdh.PrivateKey) {
+	var custodians []ceremony.Custodian
+	keys := make(map[string]*ecdh.PrivateKey)
+	for _, name := range names {
+		key, err := ecdh.P256().GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		custodians = append(custodians, ceremony.Custodian{Name: name, PublicKey: key.PublicKey()})
+		keys[name] = key
+	}
+	return custodians, keys
+}
+
+func sharesOf(result *ceremony.Result, role string) []*ceremony.EncryptedShare {
+	var shares []*ceremony.EncryptedShare
+	for _, share := range result.Shares {
+		if share.Role == role {
+			shares = append(shares, share)
+		}
+	}
+	return shares
+}
+
+func TestRun_QuorumReconstructsSigningKey(t *testing.T) {
+	custodians, keys := newCustodians(t, "alice", "bob", "carol")
+	result, err := ceremony.Run(custodians, 2, ceremony.Roles, time.Now())
+	require.NoError(t, err)
+	require.Len(t, result.Shares, 6)
+
+	shares := sharesOf(result, ceremony.SnapshotSigningKey)
+	require.Len(t, shares, 3)
+
+	// Stored shares survive a round trip
+	stored, err := shares[2].Marshal()
+	require.NoError(t, err)
+	carolShare, err := ceremony.UnmarshalShare(stored)
+	require.NoError(t, err)
+
+	key, err := ceremony.Reconstruct([]*ceremony.EncryptedShare{shares[0], carolShare}, []*ecdh.PrivateKey{keys["alice"], keys["carol"]})
+	require.NoError(t, err)
+	hash := sha256.Sum256([]byte("manifest"))
+	signature, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	require.NoError(t, err)
+	require.True(t, ecdsa.VerifyASN1(result.PublicKeys[ceremony.SnapshotSigningKey], hash[:], signature))
+
+	// One custodian alone cannot sign
+	_, err = ceremony.Reconstruct(shares[:1], []*ecdh.PrivateKey{keys["alice"]})
+	require.ErrorIs(t, err, ceremony.ErrQuorumNotMet)
+}
+
+func TestReconstruct_RejectsForeignShares(t *testing.T) {
+	custodians, keys := newCustodians(t, "alice", "bob", "carol")
+	result, err := ceremony.Run(custodians, 2, ceremony.Roles, time.Now())
+	require.NoError(t, err)
+	snapshotShares := sharesOf(result, ceremony.SnapshotSigningKey)
+	coSigningShares := sharesOf(result, ceremony.CoSigningKey)
+
+	// Shares of different keys do not combine
+	_, err = ceremony.Reconstruct([]*ceremony.EncryptedShare{snapshotShares[0], coSigningShares[1]}, []*ecdh.PrivateKey{keys["alice"], keys["bob"]})
+	require.Error(t, err)
+
+	// A share only decrypts with the key of its custodian
+	_, err = snapshotShares[0].Decrypt(keys["bob"])
+	require.Error(t, err)
+
+	// A share relabelled for another custodian fails authentication
+	relabelled := *snapshotShares[0]
+	relabelled.Custodian = "bob"
+	_, err = relabelled.Decrypt(keys["alice"])
+	require.Error(t, err)
+}
+
+func TestRun_InvalidCustodians(t *testing.T) {
+	custodians, _ := newCustodians(t, "alice", "alice")
+	_, err := ceremony.Run(custodians, 2, ceremony.Roles, time.Now())
+	require.Error(t, err)
+
+	custodians, _ = newCustodians(t, "alice", "bob")
+	_, err = ceremony.Run(custodians, 3, ceremony.Roles, time.Now())
+	require.Error(t, err)
+}
@@ -0,0 +1,124 @@
+package ceremony
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// MaxShares is the largest number of shares a secret can be split into
+const MaxShares = 255
+
+// ErrQuorumNotMet is returned when fewer shares than the threshold are combined
+var ErrQuorumNotMet = errors.New("not enough shares to reach the quorum")
+
+// Share is one Shamir share of a secret: the evaluations of a random polynomial per secret byte at X
+type Share struct {
+	X         byte   `json:"x"`
+	Y         []byte `json:"y"`
+	Total     int    `json:"total"`     // Number of shares the secret was split into
+	Threshold int    `json:"threshold"` // Number of shares needed to combine the secret
+}
+
+// exp and log tables of GF(2^8) with the AES polynomial x^8+x^4+x^3+x+1 and generator 3
+var gfExp, gfLog = func() ([512]byte, [256]byte) {
+	var exp [512]byte
+	var log [256]byte
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		exp[i] = x
+		log[x] = byte(i)
+		// Multiply by the generator 3: x*2 xor x
+		doubled := x << 1
+		if x&0x80 != 0 {
+			doubled ^= 0x1b
+		}
+		x ^= doubled
+	}
+	for i := 255; i < len(exp); i++ {
+		exp[i] = exp[i-255]
+	}
+	return exp, log
+}()
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+255-int(gfLog[b])]
+}
+
+// Split splits a secret into total shares, any threshold of which combine to the secret
+func Split(secret []byte, total int, threshold int) ([]*Share, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("secret must not be empty")
+	}
+	if threshold < 2 || threshold > total || total > MaxShares {
+		return nil, fmt.Errorf("threshold must be between 2 and the number of shares, which must be at most %d", MaxShares)
+	}
+
+	shares := make([]*Share, total)
+	for i := range shares {
+		shares[i] = &Share{X: byte(i + 1), Y: make([]byte, len(secret)), Total: total, Threshold: threshold}
+	}
+	coefficients := make([]byte, threshold)
+	for i, b := range secret {
+		coefficients[0] = b
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, fmt.Errorf("error generating polynomial: %v", err)
+		}
+		for _, share := range shares {
+			// Horner's method
+			var y byte
+			for j := threshold - 1; j >= 0; j-- {
+				y = gfMul(y, share.X) ^ coefficients[j]
+			}
+			share.Y[i] = y
+		}
+	}
+	return shares, nil
+}
+
+// Combine recovers the secret from at least the threshold of its shares by Lagrange interpolation at 0
+func Combine(shares []*Share) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, ErrQuorumNotMet
+	}
+	first := shares[0]
+	seen := make(map[byte]bool)
+	for _, share := range shares {
+		if share.X == 0 || len(share.Y) != len(first.Y) || share.Threshold != first.Threshold {
+			return nil, fmt.Errorf("shares do not belong to the same secret")
+		}
+		if seen[share.X] {
+			return nil, fmt.Errorf("share %d given twice", share.X)
+		}
+		seen[share.X] = true
+	}
+	if len(shares) < first.Threshold {
+		return nil, ErrQuorumNotMet
+	}
+
+	shares = shares[:first.Threshold]
+	secret := make([]byte, len(first.Y))
+	for i, share := range shares {
+		// Lagrange basis polynomial of share i evaluated at 0; subtraction is xor in GF(2^8)
+		basis := byte(1)
+		for j, other := range shares {
+			if i != j {
+				basis = gfMul(basis, gfDiv(other.X, other.X^share.X))
+			}
+		}
+		for k := range secret {
+			secret[k] ^= gfMul(basis, share.Y[k])
+		}
+	}
+	return secret, nil
+}
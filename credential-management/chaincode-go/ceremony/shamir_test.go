@@ -0,0 +1,37 @@
+package ceremony_test
+
+import (
+	"github.com/pherbke/credential-management/chaincode-go/ceremony"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSplitAndCombine(t *testing.T) {
+	secret := []byte("registry signing key material")
+	shares, err := ceremony.Split(secret, 5, 3)
+	require.NoError(t, err)
+	require.Len(t, shares, 5)
+
+	// Any quorum recovers the secret
+	for _, quorum := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}} {
+		var selected []*ceremony.Share
+		for _, i := range quorum {
+			selected = append(selected, shares[i])
+		}
+		combined, err := ceremony.Combine(selected)
+		require.NoError(t, err)
+		require.Equal(t, secret, combined, quorum)
+	}
+
+	_, err = ceremony.Combine(shares[:2])
+	require.ErrorIs(t, err, ceremony.ErrQuorumNotMet)
+	_, err = ceremony.Combine([]*ceremony.Share{shares[0], shares[0], shares[1]})
+	require.Error(t, err)
+}
+
+func TestSplit_InvalidThreshold(t *testing.T) {
+	for _, c := range []struct{ total, threshold int }{{3, 1}, {3, 4}, {256, 2}} {
+		_, err := ceremony.Split([]byte("secret"), c.total, c.threshold)
+		require.Error(t, err, c)
+	}
+}
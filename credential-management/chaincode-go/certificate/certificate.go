@@ -0,0 +1,116 @@
+// Package certificate signs revocation certificates read from the registry and renders them for print,
+// so a revocation can be evidenced in legal and compliance processes without access to the ledger.
+//
+// A signed certificate is a JSON bundle holding the certificate as returned by GetRevocationCertificate
+// and an ASN.1 ECDSA P-256 signature of the registry over the SHA-256 of exactly those bytes.
+package certificate
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+)
+
+// ErrInvalidSignature is returned by Verify when the registry signature does not match the certificate
+var ErrInvalidSignature = errors.New("revocation certificate signature is invalid")
+
+// Signed is a revocation certificate bundled with the registry signature
+type Signed struct {
+	Certificate json.RawMessage `json:"certificate"` // Signed bytes, kept verbatim so re-encoding cannot break the signature
+	Signature   []byte          `json:"signature"`
+	SignedAt    time.Time       `json:"signedAt"`
+}
+
+// Sign signs a certificate as returned by GetRevocationCertificate with the registry key
+func Sign(certificateJSON []byte, key *ecdsa.PrivateKey, signedAt time.Time) (*Signed, error) {
+	var certificate cuckoofilter.RevocationCertificate
+	if err := json.Unmarshal(certificateJSON, &certificate); err != nil {
+		return nil, fmt.Errorf("error decoding revocation certificate: %v", err)
+	}
+	if certificate.RequestID == "" || certificate.CredentialID == "" {
+		return nil, fmt.Errorf("revocation certificate must contain a request and credential ID")
+	}
+
+	hash := sha256.Sum256(certificateJSON)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		return nil, fmt.Errorf("error signing revocation certificate: %v", err)
+	}
+	return &Signed{Certificate: certificateJSON, Signature: signature, SignedAt: signedAt.UTC()}, nil
+}
+
+// Verify checks the registry signature and returns the certificate
+func (s *Signed) Verify(key *ecdsa.PublicKey) (*cuckoofilter.RevocationCertificate, error) {
+	hash := sha256.Sum256(s.Certificate)
+	if !ecdsa.VerifyASN1(key, hash[:], s.Signature) {
+		return nil, ErrInvalidSignature
+	}
+	var certificate cuckoofilter.RevocationCertificate
+	if err := json.Unmarshal(s.Certificate, &certificate); err != nil {
+		return nil, fmt.Errorf("error decoding revocation certificate: %v", err)
+	}
+	return &certificate, nil
+}
+
+// WriteText renders the certificate as a printable document. The signature is printed in hex,
+// so a printed copy can be checked against the JSON bundle.
+func (s *Signed) WriteText(w io.Writer) error {
+	var certificate cuckoofilter.RevocationCertificate
+	if err := json.Unmarshal(s.Certificate, &certificate); err != nil {
+		return fmt.Errorf("error decoding revocation certificate: %v", err)
+	}
+	hash := sha256.Sum256(s.Certificate)
+
+	fmt.Fprintln(w, "REVOCATION CERTIFICATE")
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	rows := [][2]string{
+		{"Credential ID", certificate.CredentialID},
+		{"Issuer", certificate.IssuerDID},
+		{"Holder", certificate.HolderDID},
+		{"Reason", certificate.Reason},
+		{"Requested at", certificate.RequestedAt.UTC().Format(time.RFC3339)},
+		{"Revoked at", certificate.RevokedAt.UTC().Format(time.RFC3339)},
+		{"Request ID", certificate.RequestID},
+		{"Revocation transaction", certificate.RevocationTx},
+		{"Channel", certificate.Channel},
+		{"Provenance", certificate.Provenance},
+		{"Signed at", s.SignedAt.UTC().Format(time.RFC3339)},
+		{"Certificate SHA-256", fmt.Sprintf("%x", hash)},
+		{"Registry signature", fmt.Sprintf("%x", s.Signature)},
+	}
+	for _, row := range rows {
+		fmt.Fprintf(tw, "%s:\t%s\n", row[0], row[1])
+	}
+	return tw.Flush()
+}
+
+// ParsePrivateKey decodes a PEM encoded EC or PKCS #8 registry signing key
+func ParsePrivateKey(keyPEM []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in key")
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing private key: %v", err)
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is not an ECDSA key")
+	}
+	return ecKey, nil
+}
@@ -0,0 +1,102 @@
+package certificate_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/pherbke/credential-management/chaincode-go/certificate"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func testCertificateJSON(t *testing.T) []byte {
+	certificateJSON, err := json.Marshal(cuckoofilter.RevocationCertificate{
+		RequestID:    "tx1",
+		CredentialID: "0a1b2c3d4e5f6a7b",
+		IssuerDID:    "did:key:issuer",
+		HolderDID:    "did:key:holder",
+		Reason:       "lost device",
+		RequestedAt:  time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+		RevokedAt:    time.Date(2024, 3, 2, 10, 0, 0, 0, time.UTC),
+		RevocationTx: "tx2",
+		Channel:      "mychannel",
+		Provenance:   cuckoofilter.ProvenanceHolderRequested,
+	})
+	require.NoError(t, err)
+	return certificateJSON
+}
+
+func TestSignAndVerify(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	signed, err := certificate.Sign(testCertificateJSON(t), key, time.Now())
+	require.NoError(t, err)
+
+	// The bundle survives a JSON round trip
+	bundleJSON, err := json.Marshal(signed)
+	require.NoError(t, err)
+	var decoded certificate.Signed
+	require.NoError(t, json.Unmarshal(bundleJSON, &decoded))
+
+	cert, err := decoded.Verify(&key.PublicKey)
+	require.NoError(t, err)
+	require.Equal(t, "0a1b2c3d4e5f6a7b", cert.CredentialID)
+	require.Equal(t, "tx2", cert.RevocationTx)
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, err = decoded.Verify(&otherKey.PublicKey)
+	require.ErrorIs(t, err, certificate.ErrInvalidSignature)
+
+	decoded.Certificate = bytes.Replace(decoded.Certificate, []byte("lost device"), []byte("stolen device"), 1)
+	_, err = decoded.Verify(&key.PublicKey)
+	require.ErrorIs(t, err, certificate.ErrInvalidSignature)
+}
+
+func TestSign_RejectsIncompleteCertificate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, err = certificate.Sign([]byte(`{"requestId":"tx1"}`), key, time.Now())
+	require.Error(t, err)
+}
+
+func TestWriteText(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signed, err := certificate.Sign(testCertificateJSON(t), key, time.Now())
+	require.NoError(t, err)
+
+	var text bytes.Buffer
+	require.NoError(t, signed.WriteText(&text))
+	require.Contains(t, text.String(), "REVOCATION CERTIFICATE")
+	require.Contains(t, text.String(), "0a1b2c3d4e5f6a7b")
+	require.Contains(t, text.String(), "2024-03-02T10:00:00Z")
+	require.Contains(t, text.String(), "lost device")
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	ecDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	parsed, err := certificate.ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER}))
+	require.NoError(t, err)
+	require.True(t, key.Equal(parsed))
+
+	pkcs8DER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	parsed, err = certificate.ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8DER}))
+	require.NoError(t, err)
+	require.True(t, key.Equal(parsed))
+
+	_, err = certificate.ParsePrivateKey([]byte("not a key"))
+	require.Error(t, err)
+}
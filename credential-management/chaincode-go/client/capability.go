@@ -0,0 +1,127 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+)
+
+// Errors returned when a capability token is not accepted
+var (
+	ErrMissingCapability = errors.New("request carries no capability token")
+	ErrInvalidCapability = errors.New("capability token is invalid")
+	ErrCapabilityExpired = errors.New("capability token has expired")
+)
+
+// IssueCapability signs capability claims as an ES256 JWT with a registry admin key. The key ID is put in
+// the kid header, so gateways can pick the matching admin key. Capabilities use the claims
+// jti, sub, actions, namespace and exp.
+func IssueCapability(claims cuckoofilter.CapabilityClaims, key *ecdsa.PrivateKey, keyID string) (string, error) {
+	if claims.ID == "" || len(claims.Actions) == 0 || claims.NotAfter.IsZero() {
+		return "", fmt.Errorf("capability needs an ID, actions and an expiry")
+	}
+	token := jwt.NewWithClaims(cuckoofilter.SigningMethodES256Deterministic, jwt.MapClaims{
+		"jti":       claims.ID,
+		"sub":       claims.Subject,
+		"actions":   claims.Actions,
+		"namespace": claims.Namespace,
+		"exp":       claims.NotAfter.Unix(),
+	})
+	token.Header["kid"] = keyID
+	return token.SignedString(key)
+}
+
+// CapabilityVerifier checks capability tokens presented to the gateway as bearer tokens
+type CapabilityVerifier struct {
+	AdminKeys map[string]*ecdsa.PublicKey // Registry admin keys by key ID
+	Clock     clock.Clock                 // Optional, defaults to clock.System
+}
+
+// Verify checks the signature and expiry of a capability token and returns its claims
+func (v *CapabilityVerifier) Verify(tokenString string) (*cuckoofilter.CapabilityClaims, error) {
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodECDSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		keyID, _ := token.Header["kid"].(string)
+		key, ok := v.AdminKeys[keyID]
+		if !ok {
+			return nil, fmt.Errorf("unknown admin key %q", keyID)
+		}
+		return key, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCapability, err)
+	}
+	mapClaims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrInvalidCapability
+	}
+
+	claims := &cuckoofilter.CapabilityClaims{}
+	claims.ID, _ = mapClaims["jti"].(string)
+	claims.Subject, _ = mapClaims["sub"].(string)
+	claims.Namespace, _ = mapClaims["namespace"].(string)
+	actions, _ := mapClaims["actions"].([]interface{})
+	for _, action := range actions {
+		if action, ok := action.(string); ok {
+			claims.Actions = append(claims.Actions, action)
+		}
+	}
+	exp, ok := mapClaims["exp"].(float64)
+	if claims.ID == "" || !ok {
+		return nil, fmt.Errorf("%w: jti and exp claims are required", ErrInvalidCapability)
+	}
+	claims.NotAfter = time.Unix(int64(exp), 0).UTC()
+
+	if !clock.Or(v.Clock).Now().Before(claims.NotAfter) {
+		return nil, ErrCapabilityExpired
+	}
+	return claims, nil
+}
+
+type capabilityContextKey struct{}
+
+// Middleware verifies the bearer capability token of every request and puts its claims in the
+// request context. Requests without a valid token are rejected with 401.
+func (v *CapabilityVerifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenString := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if tokenString == "" || tokenString == r.Header.Get("Authorization") {
+			http.Error(w, ErrMissingCapability.Error(), http.StatusUnauthorized)
+			return
+		}
+		claims, err := v.Verify(tokenString)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), capabilityContextKey{}, claims)))
+	})
+}
+
+// CapabilityFromContext returns the capability claims Middleware verified for a request
+func CapabilityFromContext(ctx context.Context) (*cuckoofilter.CapabilityClaims, bool) {
+	claims, ok := ctx.Value(capabilityContextKey{}).(*cuckoofilter.CapabilityClaims)
+	return claims, ok
+}
+
+// CapabilityTransient returns the transient data that passes the claims on to the chaincode,
+// which enforces them on top of the gateway's own identity
+func CapabilityTransient(claims *cuckoofilter.CapabilityClaims) (map[string][]byte, error) {
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{cuckoofilter.CapabilityTransientKey: claimsJSON}, nil
+}
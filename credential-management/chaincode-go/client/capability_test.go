@@ -0,0 +1,100 @@
+package client_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"github.com/pherbke/credential-management/chaincode-go/client"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newCapabilityVerifier(t *testing.T, now time.Time) (*client.CapabilityVerifier, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	verifier := &client.CapabilityVerifier{
+		AdminKeys: map[string]*ecdsa.PublicKey{"admin-1": &key.PublicKey},
+		Clock:     clock.NewManual(now),
+	}
+	return verifier, key
+}
+
+func TestCapability_IssueAndVerify(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	verifier, key := newCapabilityVerifier(t, now)
+	token, err := client.IssueCapability(cuckoofilter.CapabilityClaims{
+		ID:        "cap-1",
+		Subject:   "issuer-service",
+		Actions:   []string{cuckoofilter.CapabilityActionRevoke},
+		Namespace: "did:key:issuer",
+		NotAfter:  now.Add(time.Hour),
+	}, key, "admin-1")
+	require.NoError(t, err)
+
+	claims, err := verifier.Verify(token)
+	require.NoError(t, err)
+	require.Equal(t, "cap-1", claims.ID)
+	require.Equal(t, []string{cuckoofilter.CapabilityActionRevoke}, claims.Actions)
+	require.True(t, claims.Allows(cuckoofilter.CapabilityActionRevoke, "did:key:issuer", now))
+	require.False(t, claims.Allows(cuckoofilter.CapabilityActionRevoke, "did:key:other", now))
+	require.False(t, claims.Allows(cuckoofilter.CapabilityActionUnrevoke, "did:key:issuer", now))
+
+	transient, err := client.CapabilityTransient(claims)
+	require.NoError(t, err)
+	var passed cuckoofilter.CapabilityClaims
+	require.NoError(t, json.Unmarshal(transient[cuckoofilter.CapabilityTransientKey], &passed))
+	require.Equal(t, *claims, passed)
+
+	verifier.Clock = clock.NewManual(now.Add(time.Hour))
+	_, err = verifier.Verify(token)
+	require.ErrorIs(t, err, client.ErrCapabilityExpired)
+}
+
+func TestCapability_RejectsUnknownKey(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	verifier, _ := newCapabilityVerifier(t, now)
+	_, otherKey := newCapabilityVerifier(t, now)
+	claims := cuckoofilter.CapabilityClaims{ID: "cap-1", Actions: []string{"revoke"}, NotAfter: now.Add(time.Hour)}
+
+	// Signed by a key that is not the registered admin-1 key
+	token, err := client.IssueCapability(claims, otherKey, "admin-1")
+	require.NoError(t, err)
+	_, err = verifier.Verify(token)
+	require.ErrorIs(t, err, client.ErrInvalidCapability)
+
+	token, err = client.IssueCapability(claims, otherKey, "admin-2")
+	require.NoError(t, err)
+	_, err = verifier.Verify(token)
+	require.ErrorIs(t, err, client.ErrInvalidCapability)
+}
+
+func TestCapability_Middleware(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	verifier, key := newCapabilityVerifier(t, now)
+	handler := verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := client.CapabilityFromContext(r.Context())
+		require.True(t, ok)
+		_, _ = w.Write([]byte(claims.Subject))
+	}))
+	token, err := client.IssueCapability(cuckoofilter.CapabilityClaims{
+		ID: "cap-1", Subject: "verifier-app", Actions: []string{"lookup"}, NotAfter: now.Add(time.Hour),
+	}, key, "admin-1")
+	require.NoError(t, err)
+
+	request := httptest.NewRequest(http.MethodGet, "/lookup", nil)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	request.Header.Set("Authorization", "Bearer "+token)
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, "verifier-app", recorder.Body.String())
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"sync"
+)
+
+// ConfigWatcher keeps the settings a long-running service derives from the registry config current, so
+// an admin's UpdateRegistryConfig takes effect without restarting the service. The watcher loads the
+// config once with Reload and then follows the RegistryConfigChanged events it is fed, e.g. by passing
+// HandleBlock as the Handle of a Listener or Apply to a chaincode event stream. Events are applied in
+// version order only, so replayed blocks and events delivered out of order never roll a setting back.
+type ConfigWatcher struct {
+	Load func() (*cuckoofilter.RegistryConfig, error) // Evaluates GetRegistryConfig
+
+	mu          sync.Mutex
+	current     *cuckoofilter.RegistryConfig
+	subscribers []func(config *cuckoofilter.RegistryConfig)
+}
+
+// Subscribe registers a function called with every newer config, e.g. to resize worker pools or swap
+// a rate limiter. It is called right away with the current config if one was loaded already.
+// Subscribers are called one at a time and must not call back into the watcher.
+func (w *ConfigWatcher) Subscribe(subscriber func(config *cuckoofilter.RegistryConfig)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subscribers = append(w.subscribers, subscriber)
+	if w.current != nil {
+		config := *w.current
+		subscriber(&config)
+	}
+}
+
+// Current returns the config last applied, nil before the first Reload or event
+func (w *ConfigWatcher) Current() *cuckoofilter.RegistryConfig {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.current == nil {
+		return nil
+	}
+	config := *w.current
+	return &config
+}
+
+// Reload reads the config from the ledger and applies it if it is newer, e.g. at startup or after the
+// event stream was interrupted
+func (w *ConfigWatcher) Reload() error {
+	config, err := w.Load()
+	if err != nil {
+		return fmt.Errorf("error loading registry config: %v", err)
+	}
+	w.apply(config)
+	return nil
+}
+
+// Apply applies the config carried by a RegistryConfigChanged event and ignores all other events. It
+// reports whether the config was newer than the current one.
+func (w *ConfigWatcher) Apply(event ChaincodeEvent) (bool, error) {
+	if event.Name != cuckoofilter.RegistryConfigChangedEvent {
+		return false, nil
+	}
+	var config cuckoofilter.RegistryConfig
+	if err := json.Unmarshal(event.Payload, &config); err != nil {
+		return false, fmt.Errorf("error decoding %s event of transaction %s: %v", event.Name, event.TxID, err)
+	}
+	return w.apply(&config), nil
+}
+
+// HandleBlock applies the config changes of a block, it has the signature of Listener.Handle
+func (w *ConfigWatcher) HandleBlock(block *BlockEvents, token uint64) error {
+	for _, event := range block.Events {
+		if _, err := w.Apply(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BatchChunks splits items into batches within the current MaxBatchSize, a single batch if the
+// registry sets no limit or no config was loaded yet
+func (w *ConfigWatcher) BatchChunks(items []string) [][]string {
+	size := len(items)
+	if config := w.Current(); config != nil && config.MaxBatchSize > 0 && int(config.MaxBatchSize) < size {
+		size = int(config.MaxBatchSize)
+	}
+	chunks := [][]string{}
+	for start := 0; start < len(items); start += size {
+		end := start + size
+		if end > len(items) {
+			end = len(items)
+		}
+		chunks = append(chunks, items[start:end])
+	}
+	return chunks
+}
+
+// apply stores config and notifies the subscribers unless it is not newer than the current config
+func (w *ConfigWatcher) apply(config *cuckoofilter.RegistryConfig) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.current != nil && config.Version <= w.current.Version {
+		return false
+	}
+	w.current = config
+	for _, subscriber := range w.subscribers {
+		copied := *config
+		subscriber(&copied)
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package client_test
+
+import (
+	"encoding/json"
+	"github.com/pherbke/credential-management/chaincode-go/client"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfigWatcher_HotReload(t *testing.T) {
+	sim, err := simulator.New("credential-management", &cuckoofilter.SmartContract{})
+	require.NoError(t, err)
+	admin, err := simulator.NewIdentityWithAttributes("Org1MSP", "registry-admin", map[string]string{cuckoofilter.RegistryAdminAttribute: "true"})
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "UpdateRegistryConfig", "3")
+	require.NoError(t, err)
+
+	watcher := &client.ConfigWatcher{
+		Load: func() (*cuckoofilter.RegistryConfig, error) {
+			configJSON, err := sim.Evaluate(admin, "GetRegistryConfig")
+			if err != nil {
+				return nil, err
+			}
+			var config cuckoofilter.RegistryConfig
+			return &config, json.Unmarshal(configJSON, &config)
+		},
+	}
+	require.Nil(t, watcher.Current())
+	require.Equal(t, [][]string{{"a", "b", "c", "d"}}, watcher.BatchChunks([]string{"a", "b", "c", "d"}))
+	require.NoError(t, watcher.Reload())
+
+	// A service derives its chunk size from the config and follows every change
+	chunkSize := uint(0)
+	watcher.Subscribe(func(config *cuckoofilter.RegistryConfig) { chunkSize = config.MaxBatchSize })
+	require.Equal(t, uint(3), chunkSize)
+	require.Equal(t, [][]string{{"a", "b", "c"}, {"d"}}, watcher.BatchChunks([]string{"a", "b", "c", "d"}))
+
+	first, err := sim.Submit(admin, "UpdateRegistryConfig", "2")
+	require.NoError(t, err)
+	second, err := sim.Submit(admin, "UpdateRegistryConfig", "5")
+	require.NoError(t, err)
+	event := func(tx *simulator.Transaction) client.ChaincodeEvent {
+		return client.ChaincodeEvent{TxID: tx.ID, Name: tx.Event.EventName, Payload: tx.Event.Payload}
+	}
+	require.NoError(t, watcher.HandleBlock(&client.BlockEvents{Number: 3, Events: []client.ChaincodeEvent{{Name: "FilterChanged"}, event(first)}}, 1))
+	require.Equal(t, uint(2), chunkSize)
+	require.Equal(t, [][]string{{"a", "b"}, {"c", "d"}}, watcher.BatchChunks([]string{"a", "b", "c", "d"}))
+
+	applied, err := watcher.Apply(event(second))
+	require.NoError(t, err)
+	require.True(t, applied)
+	require.Equal(t, uint(5), chunkSize)
+
+	// A replayed block does not roll the config back
+	require.NoError(t, watcher.HandleBlock(&client.BlockEvents{Number: 3, Events: []client.ChaincodeEvent{event(first)}}, 1))
+	require.Equal(t, uint(5), chunkSize)
+	require.Equal(t, uint64(3), watcher.Current().Version)
+
+	_, err = watcher.Apply(client.ChaincodeEvent{Name: cuckoofilter.RegistryConfigChangedEvent, Payload: []byte("{")})
+	require.Error(t, err)
+}
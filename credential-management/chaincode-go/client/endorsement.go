@@ -0,0 +1,420 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrEndorsementMismatch is returned when the endorsing peers of a transaction simulated it with different results
+var ErrEndorsementMismatch = errors.New("endorsements do not match")
+
+// gatewayMismatchMessage is the error Fabric returns when it cannot assemble a transaction from its endorsements
+const gatewayMismatchMessage = "ProposalResponsePayloads do not match"
+
+// Kinds of an EndorsementDifference
+const (
+	DifferenceProposal = "proposal" // The peers endorsed different proposals
+	DifferenceStatus   = "status"   // Some peers failed the proposal
+	DifferencePayload  = "payload"  // The chaincode returned different payloads
+	DifferenceEvent    = "event"    // The chaincode set different events
+	DifferenceRead     = "read"     // A key was read at different versions
+	DifferenceWrite    = "write"    // A key was written differently
+	DifferencePrivate  = "private"  // A private data collection was written differently
+)
+
+// ProposeFunc creates the signed proposal of a transaction, e.g. with the Fabric Gateway client's NewProposal
+type ProposeFunc func(function string, args ...string) (*peer.SignedProposal, error)
+
+// EndorseFunc sends a signed proposal to one endorsing peer, e.g. through its Endorser service, and returns
+// its proposal response without submitting it
+type EndorseFunc func(endorser string, proposal *peer.SignedProposal) (*peer.ProposalResponse, error)
+
+// EndorsementDifference is one point in which the results of the endorsing peers differ
+type EndorsementDifference struct {
+	Kind      string            `json:"kind"`
+	Namespace string            `json:"namespace,omitempty"`
+	Key       string            `json:"key,omitempty"` // Key, or collection for private data
+	Values    map[string]string `json:"values"`        // Value per peer, "(none)" when the peer did not read or write it
+	Cause     string            `json:"cause"`         // Likely cause
+}
+
+func (d EndorsementDifference) String() string {
+	subject := d.Kind
+	if d.Key != "" {
+		subject = fmt.Sprintf("%s of %s/%s", d.Kind, d.Namespace, d.Key)
+	}
+	return fmt.Sprintf("%s differs: %s", subject, d.Cause)
+}
+
+// EndorsementMismatchError reports a transaction whose endorsing peers returned different results.
+// Chaincode must compute the same read/write set on every peer, so a mismatch points to a
+// non-deterministic transaction or to peers whose ledgers diverged.
+type EndorsementMismatchError struct {
+	Function    string                  `json:"function"`
+	Args        []string                `json:"args"`
+	Groups      [][]string              `json:"groups"`           // Peers grouped by identical results, largest group first
+	Orgs        map[string]string       `json:"orgs,omitempty"`   // MSP ID of every peer that endorsed
+	Errors      map[string]string       `json:"errors,omitempty"` // Error of every peer that failed the proposal
+	Differences []EndorsementDifference `json:"differences,omitempty"`
+}
+
+func (e *EndorsementMismatchError) Error() string {
+	groups := make([]string, 0, len(e.Groups))
+	for _, group := range e.Groups {
+		groups = append(groups, "["+strings.Join(group, " ")+"]")
+	}
+	message := fmt.Sprintf("%v for %s, peers grouped by result: %s", ErrEndorsementMismatch, e.Function, strings.Join(groups, " "))
+	if len(e.Differences) > 0 {
+		message += fmt.Sprintf("; %s (%d differences)", e.Differences[0], len(e.Differences))
+	}
+	return message
+}
+
+func (e *EndorsementMismatchError) Unwrap() error {
+	return ErrEndorsementMismatch
+}
+
+// Causes returns the distinct likely causes of the differences in order
+func (e *EndorsementMismatchError) Causes() []string {
+	var causes []string
+	seen := make(map[string]bool)
+	for _, difference := range e.Differences {
+		if !seen[difference.Cause] {
+			seen[difference.Cause] = true
+			causes = append(causes, difference.Cause)
+		}
+	}
+	return causes
+}
+
+// IsEndorsementMismatch reports whether an error is an endorsement mismatch, either an
+// EndorsementMismatchError or the error Fabric returns when the endorsements it collected differ
+func IsEndorsementMismatch(err error) bool {
+	return err != nil && (errors.Is(err, ErrEndorsementMismatch) || strings.Contains(err.Error(), gatewayMismatchMessage))
+}
+
+// EndorsementChecker endorses transactions on one peer of every endorsing org separately, to detect and
+// explain endorsement mismatches that a gateway only reports as a failed submission. With Diagnose set,
+// mismatching proposal responses are decoded and diffed down to the keys they wrote differently.
+type EndorsementChecker struct {
+	Peers      []string // One endorsing peer per org
+	Propose    ProposeFunc
+	Endorse    EndorseFunc
+	Diagnose   bool
+	OnMismatch func(err *EndorsementMismatchError) // Optional
+}
+
+// Check endorses a transaction on every peer with the same proposal and returns the proposal responses
+// if they all match. Nothing is submitted. If the peers disagree, including when only some of them fail,
+// it returns an EndorsementMismatchError; if they all fail, the error of the first peer.
+func (c *EndorsementChecker) Check(function string, args ...string) ([]*peer.ProposalResponse, error) {
+	if len(c.Peers) == 0 {
+		return nil, fmt.Errorf("no endorsing peers to check %s on", function)
+	}
+	proposal, err := c.Propose(function, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating proposal of %s: %v", function, err)
+	}
+	responses := make(map[string]*peer.ProposalResponse, len(c.Peers))
+	errs := make(map[string]string)
+	for _, endorser := range c.Peers {
+		response, err := c.Endorse(endorser, proposal)
+		switch {
+		case err != nil:
+			errs[endorser] = err.Error()
+		case response.GetResponse().GetStatus() >= 400:
+			errs[endorser] = fmt.Sprintf("status %d: %s", response.Response.Status, response.Response.Message)
+		default:
+			responses[endorser] = response
+		}
+	}
+	if len(responses) == 0 {
+		return nil, fmt.Errorf("error endorsing %s on %s: %s", function, c.Peers[0], errs[c.Peers[0]])
+	}
+
+	groups := groupEndorsements(responses, errs)
+	if len(groups) == 1 {
+		ordered := make([]*peer.ProposalResponse, 0, len(c.Peers))
+		for _, endorser := range c.Peers {
+			ordered = append(ordered, responses[endorser])
+		}
+		return ordered, nil
+	}
+
+	mismatch := &EndorsementMismatchError{Function: function, Args: args, Groups: groups, Orgs: make(map[string]string), Errors: errs}
+	for endorser, response := range responses {
+		mismatch.Orgs[endorser] = endorserMSPID(response)
+	}
+	if c.Diagnose {
+		mismatch.Differences, err = DiffEndorsements(responses, errs)
+		if err != nil {
+			return nil, fmt.Errorf("error diagnosing endorsement mismatch of %s: %v", function, err)
+		}
+	}
+	if c.OnMismatch != nil {
+		c.OnMismatch(mismatch)
+	}
+	return nil, mismatch
+}
+
+// Submit submits a transaction with submit. When the submission fails because the endorsements did not
+// match, the transaction is endorsed again with Check, so the error says which peers disagreed and, in
+// diagnostic mode, how.
+func (c *EndorsementChecker) Submit(submit SubmitFunc, function string, args ...string) (string, []byte, error) {
+	txID, payload, err := submit(function, args...)
+	if err == nil || !IsEndorsementMismatch(err) {
+		return txID, payload, err
+	}
+	if _, checkErr := c.Check(function, args...); checkErr != nil {
+		return "", nil, checkErr
+	}
+	return "", nil, fmt.Errorf("%w, but the peers endorsed %s alike when checked again: %v", ErrEndorsementMismatch, function, err)
+}
+
+// groupEndorsements groups the peers by identical proposal response payloads, and failed peers by
+// error, largest group first
+func groupEndorsements(responses map[string]*peer.ProposalResponse, errs map[string]string) [][]string {
+	byResult := make(map[string][]string)
+	for endorser, response := range responses {
+		hash := sha256.Sum256(response.Payload)
+		byResult["payload:"+string(hash[:])] = append(byResult["payload:"+string(hash[:])], endorser)
+	}
+	for endorser, message := range errs {
+		byResult["error:"+message] = append(byResult["error:"+message], endorser)
+	}
+	groups := make([][]string, 0, len(byResult))
+	for _, group := range byResult {
+		sort.Strings(group)
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if len(groups[i]) != len(groups[j]) {
+			return len(groups[i]) > len(groups[j])
+		}
+		return groups[i][0] < groups[j][0]
+	})
+	return groups
+}
+
+// endorsementResult is a decoded proposal response, flattened to comparable values
+type endorsementResult struct {
+	proposalHash string
+	payload      string
+	event        string
+	reads        map[[2]string]string // By namespace and key
+	writes       map[[2]string]string
+	private      map[[2]string]string // By namespace and collection
+}
+
+// DiffEndorsements decodes the proposal responses of the peers and lists in what they differ, with the
+// likely cause of every difference. Peers in errs failed the proposal and only show in the status
+// difference.
+func DiffEndorsements(responses map[string]*peer.ProposalResponse, errs map[string]string) ([]EndorsementDifference, error) {
+	results := make(map[string]*endorsementResult, len(responses))
+	for endorser, response := range responses {
+		result, err := decodeEndorsement(response)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding proposal response of %s: %v", endorser, err)
+		}
+		results[endorser] = result
+	}
+
+	var differences []EndorsementDifference
+	if len(errs) > 0 {
+		values := make(map[string]string, len(responses)+len(errs))
+		for endorser := range responses {
+			values[endorser] = "ok"
+		}
+		for endorser, message := range errs {
+			values[endorser] = message
+		}
+		differences = append(differences, EndorsementDifference{
+			Kind:   DifferenceStatus,
+			Values: values,
+			Cause:  "the transaction failed on some peers only: their state or environment differs, e.g. a file the chaincode reads is missing on them",
+		})
+	}
+	differences = appendDifference(differences, DifferenceProposal, "", "", results, func(r *endorsementResult) string { return r.proposalHash })
+	differences = appendDifference(differences, DifferencePayload, "", "", results, func(r *endorsementResult) string { return r.payload })
+	differences = appendDifference(differences, DifferenceEvent, "", "", results, func(r *endorsementResult) string { return r.event })
+	for _, kind := range []string{DifferenceRead, DifferenceWrite, DifferencePrivate} {
+		for _, key := range resultKeys(results, kind) {
+			differences = appendDifference(differences, kind, key[0], key[1], results, func(r *endorsementResult) string {
+				return resultEntries(r, kind)[key]
+			})
+		}
+	}
+	return differences, nil
+}
+
+// appendDifference appends a difference if value is not the same for every result
+func appendDifference(differences []EndorsementDifference, kind, namespace, key string, results map[string]*endorsementResult, value func(r *endorsementResult) string) []EndorsementDifference {
+	values := make(map[string]string, len(results))
+	distinct := make(map[string]bool)
+	for endorser, result := range results {
+		v := value(result)
+		if v == "" {
+			v = "(none)"
+		}
+		values[endorser] = v
+		distinct[v] = true
+	}
+	if len(distinct) < 2 {
+		return differences
+	}
+	return append(differences, EndorsementDifference{Kind: kind, Namespace: namespace, Key: key, Values: values, Cause: differenceCause(kind, values)})
+}
+
+// Patterns of values that legitimately differ between peers when a chaincode is non-deterministic
+var (
+	timestampPattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?|\b\d{10}(\d{3}|\d{6}|\d{9})?\b`)
+	randomPattern    = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|[A-Za-z0-9+/_-]{16,}={0,2}`)
+)
+
+// differenceCause guesses why the peers' values differ
+func differenceCause(kind string, values map[string]string) string {
+	switch kind {
+	case DifferenceProposal:
+		return "the peers endorsed different proposals: send the same signed proposal to every peer"
+	case DifferenceRead:
+		return "the peers read the key at different versions: their ledgers are at different heights or have diverged, retry once they caught up and compare their state if it persists"
+	case DifferencePrivate:
+		return "the private data written differs: the values depend on something that differs between peers, or the peers' private data diverged"
+	}
+	for _, value := range values {
+		if value == "(none)" {
+			return "only some peers produced it: the chaincode takes a different path on some peers, e.g. because of randomness, the peer's clock, files it reads, or diverged state"
+		}
+	}
+	if sameAfter(values, timestampPattern) {
+		return "the values differ in timestamps: use the transaction timestamp (GetTxTimestamp) instead of the peer's clock"
+	}
+	if sameAfter(values, randomPattern) {
+		return "the values differ in identifiers, keys or signatures: derive them from the transaction (e.g. its ID) instead of randomness, and sign deterministically"
+	}
+	return "the values differ: the chaincode depends on something that differs between peers, e.g. files it reads such as keys or test data, randomness, map iteration order, or diverged state"
+}
+
+// sameAfter reports whether the values are all equal once every match of pattern is blanked out
+func sameAfter(values map[string]string, pattern *regexp.Regexp) bool {
+	distinct := make(map[string]bool)
+	for _, value := range values {
+		distinct[pattern.ReplaceAllString(value, "_")] = true
+	}
+	return len(distinct) == 1
+}
+
+// resultEntries returns the entries of a result of the read, write or private kind
+func resultEntries(result *endorsementResult, kind string) map[[2]string]string {
+	switch kind {
+	case DifferenceRead:
+		return result.reads
+	case DifferenceWrite:
+		return result.writes
+	}
+	return result.private
+}
+
+// resultKeys returns the keys of every result of a kind, sorted
+func resultKeys(results map[string]*endorsementResult, kind string) [][2]string {
+	seen := make(map[[2]string]bool)
+	var keys [][2]string
+	for _, result := range results {
+		for key := range resultEntries(result, kind) {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	return keys
+}
+
+// decodeEndorsement decodes the proposal response payload down to the key-value read/write sets
+func decodeEndorsement(response *peer.ProposalResponse) (*endorsementResult, error) {
+	payload := &peer.ProposalResponsePayload{}
+	if err := proto.Unmarshal(response.Payload, payload); err != nil {
+		return nil, err
+	}
+	action := &peer.ChaincodeAction{}
+	if err := proto.Unmarshal(payload.Extension, action); err != nil {
+		return nil, err
+	}
+	result := &endorsementResult{
+		proposalHash: hex.EncodeToString(payload.ProposalHash),
+		payload:      printable(action.GetResponse().GetPayload()),
+		reads:        make(map[[2]string]string),
+		writes:       make(map[[2]string]string),
+		private:      make(map[[2]string]string),
+	}
+	if len(action.Events) > 0 {
+		event := &peer.ChaincodeEvent{}
+		if err := proto.Unmarshal(action.Events, event); err != nil {
+			return nil, err
+		}
+		result.event = event.EventName + " " + printable(event.Payload)
+	}
+
+	txRWSet := &rwset.TxReadWriteSet{}
+	if err := proto.Unmarshal(action.Results, txRWSet); err != nil {
+		return nil, err
+	}
+	for _, nsRWSet := range txRWSet.NsRwset {
+		kvSet := &kvrwset.KVRWSet{}
+		if err := proto.Unmarshal(nsRWSet.Rwset, kvSet); err != nil {
+			return nil, err
+		}
+		for _, read := range kvSet.Reads {
+			version := "(absent)"
+			if read.Version != nil {
+				version = fmt.Sprintf("block %d tx %d", read.Version.BlockNum, read.Version.TxNum)
+			}
+			result.reads[[2]string{nsRWSet.Namespace, read.Key}] = version
+		}
+		for _, write := range kvSet.Writes {
+			value := printable(write.Value)
+			if write.IsDelete {
+				value = "(deleted)"
+			}
+			result.writes[[2]string{nsRWSet.Namespace, write.Key}] = value
+		}
+		for _, collection := range nsRWSet.CollectionHashedRwset {
+			result.private[[2]string{nsRWSet.Namespace, collection.CollectionName}] = hex.EncodeToString(collection.HashedRwset)
+		}
+	}
+	return result, nil
+}
+
+// endorserMSPID returns the org of the peer that signed a proposal response
+func endorserMSPID(response *peer.ProposalResponse) string {
+	identity := &msp.SerializedIdentity{}
+	if err := proto.Unmarshal(response.GetEndorsement().GetEndorser(), identity); err != nil {
+		return ""
+	}
+	return identity.Mspid
+}
+
+// printable returns a value as text, hex encoded if it is not valid UTF-8
+func printable(value []byte) string {
+	if utf8.Valid(value) {
+		return string(value)
+	}
+	return hex.EncodeToString(value)
+}
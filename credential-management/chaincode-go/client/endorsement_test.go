@@ -0,0 +1,192 @@
+package client_test
+
+import (
+	"errors"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/pherbke/credential-management/chaincode-go/client"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// newChecker returns a checker endorsing on the peers in order, each peer signing as its own org
+func newChecker(t *testing.T, peers map[string]*simulator.Simulator, identity *simulator.Identity, names ...string) *client.EndorsementChecker {
+	endorsers := make(map[string]*simulator.Identity)
+	for i, name := range names {
+		endorser, err := simulator.NewIdentity([]string{"Org1MSP", "Org2MSP", "Org3MSP"}[i], name)
+		require.NoError(t, err)
+		endorsers[name] = endorser
+	}
+	return &client.EndorsementChecker{
+		Peers: names,
+		Propose: func(function string, args ...string) (*peer.SignedProposal, error) {
+			return peers[names[0]].NewProposal(identity, function, args...)
+		},
+		Endorse: func(endorser string, proposal *peer.SignedProposal) (*peer.ProposalResponse, error) {
+			return peers[endorser].ProcessProposal(endorsers[endorser], proposal)
+		},
+		Diagnose: true,
+	}
+}
+
+func TestEndorsementChecker_Match(t *testing.T) {
+	peers, identity := newPeers(t, "peer0", "peer1")
+	checker := newChecker(t, peers, identity, "peer0", "peer1")
+
+	responses, err := checker.Check("BatchInsert", "", `["credential-3"]`)
+	require.NoError(t, err)
+	require.Len(t, responses, 2)
+	require.Equal(t, responses[0].Payload, responses[1].Payload)
+
+	// Checking endorses only, nothing is committed
+	found, err := peers["peer0"].Evaluate(identity, "Lookup", "", "credential-3")
+	require.NoError(t, err)
+	require.Equal(t, "false", string(found))
+}
+
+func TestEndorsementChecker_DivergedPeer(t *testing.T) {
+	peers, identity := newPeers(t, "peer0", "peer1", "peer2")
+	corruptFilter(t, peers["peer2"])
+	checker := newChecker(t, peers, identity, "peer0", "peer1", "peer2")
+	var mismatches []*client.EndorsementMismatchError
+	checker.OnMismatch = func(err *client.EndorsementMismatchError) { mismatches = append(mismatches, err) }
+
+	_, err := checker.Check("BatchInsert", "", `["credential-3"]`)
+	require.ErrorIs(t, err, client.ErrEndorsementMismatch)
+	var mismatch *client.EndorsementMismatchError
+	require.True(t, errors.As(err, &mismatch))
+	require.Equal(t, []*client.EndorsementMismatchError{mismatch}, mismatches)
+	require.Equal(t, [][]string{{"peer0", "peer1"}, {"peer2"}}, mismatch.Groups)
+	require.Equal(t, map[string]string{"peer0": "Org1MSP", "peer1": "Org2MSP", "peer2": "Org3MSP"}, mismatch.Orgs)
+
+	// The diff points at the filter state the corrupted peer wrote differently
+	writes := []string{}
+	for _, difference := range mismatch.Differences {
+		require.Equal(t, difference.Values["peer0"], difference.Values["peer1"])
+		require.NotEqual(t, difference.Values["peer0"], difference.Values["peer2"])
+		if difference.Kind == client.DifferenceWrite {
+			require.Equal(t, "credential-management", difference.Namespace)
+			writes = append(writes, difference.Key)
+		}
+	}
+	require.Contains(t, writes, "CuckooFilterState")
+	require.Contains(t, err.Error(), "peers grouped by result: [peer0 peer1] [peer2]")
+}
+
+// stampContract records the peer's clock and random IDs, as non-deterministic chaincode does. Each
+// peer gets its own values in place of time.Now and crypto/rand.
+type stampContract struct {
+	contractapi.Contract
+	now time.Time
+	id  string
+}
+
+func (c *stampContract) Stamp(ctx contractapi.TransactionContextInterface, key string) error {
+	return ctx.GetStub().PutState(key, []byte(`{"id":"`+key+`","createdAt":"`+c.now.Format(time.RFC3339Nano)+`"}`))
+}
+
+func (c *stampContract) Register(ctx contractapi.TransactionContextInterface) (string, error) {
+	return c.id, ctx.GetStub().PutState("record-"+c.id, []byte(c.id))
+}
+
+func newStampPeers(t *testing.T, contracts map[string]*stampContract) (map[string]*simulator.Simulator, *simulator.Identity) {
+	identity, err := simulator.NewIdentity("Org1MSP", "client")
+	require.NoError(t, err)
+	peers := make(map[string]*simulator.Simulator)
+	for name, contract := range contracts {
+		sim, err := simulator.New("stamps", contract)
+		require.NoError(t, err)
+		peers[name] = sim
+	}
+	return peers, identity
+}
+
+func TestEndorsementChecker_Causes(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	peers, identity := newStampPeers(t, map[string]*stampContract{
+		"peer0": {now: now, id: "3f2c1a9e-5b7d-4e21-9c8f-0a1b2c3d4e5f"},
+		"peer1": {now: now.Add(1500 * time.Microsecond), id: "b8e0d4c2-7a19-4f6e-8d3b-5c2a1e0f9d87"},
+	})
+	checker := newChecker(t, peers, identity, "peer0", "peer1")
+
+	// Timestamps from the peer's clock
+	_, err := checker.Check("Stamp", "record-1")
+	var mismatch *client.EndorsementMismatchError
+	require.True(t, errors.As(err, &mismatch))
+	require.Len(t, mismatch.Differences, 1)
+	difference := mismatch.Differences[0]
+	require.Equal(t, "record-1", difference.Key)
+	require.Equal(t, `{"id":"record-1","createdAt":"2024-03-01T12:00:00Z"}`, difference.Values["peer0"])
+	require.Contains(t, difference.Cause, "GetTxTimestamp")
+
+	// Random IDs change the payload and which keys are written
+	_, err = checker.Check("Register")
+	require.True(t, errors.As(err, &mismatch))
+	kinds := []string{}
+	for _, difference := range mismatch.Differences {
+		kinds = append(kinds, difference.Kind+" "+difference.Key)
+	}
+	require.Equal(t, []string{
+		"payload ",
+		"write record-3f2c1a9e-5b7d-4e21-9c8f-0a1b2c3d4e5f",
+		"write record-b8e0d4c2-7a19-4f6e-8d3b-5c2a1e0f9d87",
+	}, kinds)
+	require.Contains(t, mismatch.Differences[0].Cause, "randomness")
+	require.Equal(t, "(none)", mismatch.Differences[1].Values["peer1"])
+	require.Contains(t, mismatch.Differences[1].Cause, "only some peers")
+	require.Len(t, mismatch.Causes(), 2)
+}
+
+func TestEndorsementChecker_PartialFailure(t *testing.T) {
+	peers, identity := newPeers(t, "peer0", "peer1")
+	checker := newChecker(t, peers, identity, "peer0", "peer1")
+	endorse := checker.Endorse
+	checker.Endorse = func(endorser string, proposal *peer.SignedProposal) (*peer.ProposalResponse, error) {
+		if endorser == "peer1" {
+			return nil, errors.New("open keys/private.pem: no such file or directory")
+		}
+		return endorse(endorser, proposal)
+	}
+
+	_, err := checker.Check("BatchInsert", "", `["credential-3"]`)
+	var mismatch *client.EndorsementMismatchError
+	require.True(t, errors.As(err, &mismatch))
+	require.Equal(t, map[string]string{"peer1": "open keys/private.pem: no such file or directory"}, mismatch.Errors)
+	require.Len(t, mismatch.Differences, 1)
+	require.Equal(t, client.DifferenceStatus, mismatch.Differences[0].Kind)
+	require.Equal(t, "ok", mismatch.Differences[0].Values["peer0"])
+
+	// A transaction failing on every peer is an ordinary failure
+	_, err = checker.Check("Lookup", "missing-filter", "credential-1")
+	require.Error(t, err)
+	require.False(t, client.IsEndorsementMismatch(err))
+}
+
+func TestEndorsementChecker_Submit(t *testing.T) {
+	peers, identity := newPeers(t, "peer0", "peer1")
+	corruptFilter(t, peers["peer1"])
+	checker := newChecker(t, peers, identity, "peer0", "peer1")
+	submissions := 0
+	submit := func(function string, args ...string) (string, []byte, error) {
+		submissions++
+		if function == "BatchInsert" {
+			return "", nil, errors.New("rpc error: code = Aborted desc = failed to assemble transaction: ProposalResponsePayloads do not match")
+		}
+		return "tx", []byte("true"), nil
+	}
+
+	_, _, err := checker.Submit(submit, "BatchInsert", "", `["credential-3"]`)
+	var mismatch *client.EndorsementMismatchError
+	require.True(t, errors.As(err, &mismatch))
+	require.Equal(t, [][]string{{"peer0"}, {"peer1"}}, mismatch.Groups)
+	require.NotEmpty(t, mismatch.Differences)
+
+	// Other submissions pass through
+	txID, payload, err := checker.Submit(submit, "Lookup", "", "credential-1")
+	require.NoError(t, err)
+	require.Equal(t, "tx", txID)
+	require.Equal(t, "true", string(payload))
+	require.Equal(t, 2, submissions)
+}
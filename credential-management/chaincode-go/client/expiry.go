@@ -0,0 +1,31 @@
+package client
+
+import (
+	"fmt"
+
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+)
+
+// ExpiryKeeper runs the EmitExpiryWarning keeper transaction for every issuer that opted in to expiry
+// warnings. A transaction carries one chaincode event, so every issuer gets a transaction of its own.
+// Run it once per day, e.g. from cron.
+type ExpiryKeeper struct {
+	Issuers func() ([]*cuckoofilter.ExpiryWarningConfig, error) // Opted-in issuers, e.g. by evaluating ListExpiryWarnings
+	Emit    func(issuerDID string) error                        // Submits EmitExpiryWarning for an issuer
+}
+
+// Run submits the keeper transaction for every opted-in issuer. Issuers whose transaction fails do not
+// stop the others; the first error is returned after every issuer was tried.
+func (k *ExpiryKeeper) Run() error {
+	configs, err := k.Issuers()
+	if err != nil {
+		return fmt.Errorf("error listing expiry warning issuers: %v", err)
+	}
+	var firstErr error
+	for _, config := range configs {
+		if err := k.Emit(config.IssuerDID); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("error emitting expiry warning for %s: %v", config.IssuerDID, err)
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,34 @@
+package client_test
+
+import (
+	"errors"
+	"github.com/pherbke/credential-management/chaincode-go/client"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestExpiryKeeper_EmitsPerIssuer(t *testing.T) {
+	var emitted []string
+	keeper := &client.ExpiryKeeper{
+		Issuers: func() ([]*cuckoofilter.ExpiryWarningConfig, error) {
+			return []*cuckoofilter.ExpiryWarningConfig{
+				{IssuerDID: "did:key:issuer1", Days: 7},
+				{IssuerDID: "did:key:issuer2", Days: 30},
+				{IssuerDID: "did:key:issuer3", Days: 1},
+			}, nil
+		},
+		Emit: func(issuerDID string) error {
+			emitted = append(emitted, issuerDID)
+			if issuerDID == "did:key:issuer2" {
+				return errors.New("endorsement failed")
+			}
+			return nil
+		},
+	}
+
+	// A failing issuer is reported without skipping the others
+	err := keeper.Run()
+	require.ErrorContains(t, err, "did:key:issuer2")
+	require.Equal(t, []string{"did:key:issuer1", "did:key:issuer2", "did:key:issuer3"}, emitted)
+}
@@ -0,0 +1,251 @@
+package client
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Status values of a journal entry
+const (
+	JournalCommitted = "committed"
+	JournalFailed    = "failed"
+)
+
+// ErrNotReplayable is returned by Replay for entries recorded without their arguments
+var ErrNotReplayable = errors.New("journal entry was recorded without arguments")
+
+// JournalEntry records one submitted write transaction
+type JournalEntry struct {
+	Time     time.Time `json:"time"`
+	Function string    `json:"function"`
+	ArgsHash string    `json:"argsHash"`       // See HashArgs
+	Args     []string  `json:"args,omitempty"` // Only kept when the journal stores arguments
+	TxID     string    `json:"txId,omitempty"` // Empty when the transaction failed before it got an ID
+	Status   string    `json:"status"`
+	Result   string    `json:"result,omitempty"` // Transaction payload, or the error of a failed submission
+}
+
+// HashArgs returns the hex SHA-256 of the JSON encoded arguments, so an auditor holding the arguments
+// can show they match a journal entry
+func HashArgs(args []string) string {
+	argsJSON, _ := json.Marshal(args)
+	hash := sha256.Sum256(argsJSON)
+	return hex.EncodeToString(hash[:])
+}
+
+// SubmitFunc submits a transaction and returns its ID and payload
+type SubmitFunc func(function string, args ...string) (txID string, payload []byte, err error)
+
+// Journal records every submitted write in an append-only JSON lines file, as evidence of what was
+// submitted when and to reconcile the client's view with the ledger after a disaster
+type Journal struct {
+	StoreArgs bool        // Keep the arguments of each entry, which makes entries replayable
+	Clock     clock.Clock // Optional, defaults to clock.System
+
+	mu      sync.Mutex
+	file    *os.File
+	entries []JournalEntry
+}
+
+// OpenJournal opens the journal at path, creating it if needed, and loads the recorded entries.
+// An empty path keeps the journal in memory.
+func OpenJournal(path string) (*Journal, error) {
+	journal := &Journal{}
+	if path == "" {
+		return journal, nil
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening journal: %v", err)
+	}
+	entries, err := readJournalEntries(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	journal.file = file
+	journal.entries = entries
+	return journal, nil
+}
+
+// Close closes the journal file
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+	return err
+}
+
+// Submit submits a write through submit and records the outcome. The error of submit is returned
+// unchanged; an error writing the journal is returned only when the submission succeeded.
+func (j *Journal) Submit(submit SubmitFunc, function string, args ...string) ([]byte, error) {
+	txID, payload, err := submit(function, args...)
+	entry := JournalEntry{
+		Time:     clock.Or(j.Clock).Now().UTC(),
+		Function: function,
+		ArgsHash: HashArgs(args),
+		TxID:     txID,
+		Status:   JournalCommitted,
+		Result:   string(payload),
+	}
+	if j.StoreArgs {
+		entry.Args = args
+	}
+	if err != nil {
+		entry.Status = JournalFailed
+		entry.Result = err.Error()
+	}
+	if recordErr := j.Record(entry); recordErr != nil && err == nil {
+		return payload, recordErr
+	}
+	return payload, err
+}
+
+// Record appends an entry and syncs it to disk
+func (j *Journal) Record(entry JournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.append(entry)
+}
+
+func (j *Journal) append(entry JournalEntry) error {
+	if j.file != nil {
+		entryJSON, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if _, err := j.file.Write(append(entryJSON, '\n')); err != nil {
+			return fmt.Errorf("error writing journal: %v", err)
+		}
+		if err := j.file.Sync(); err != nil {
+			return fmt.Errorf("error syncing journal: %v", err)
+		}
+	}
+	j.entries = append(j.entries, entry)
+	return nil
+}
+
+// Entries returns the recorded entries in recording order
+func (j *Journal) Entries() []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]JournalEntry(nil), j.entries...)
+}
+
+// Export writes the entries as JSON lines
+func (j *Journal) Export(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	for _, entry := range j.Entries() {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Import appends the entries of an exported journal, skipping committed transactions already recorded.
+// It returns the number of imported entries.
+func (j *Journal) Import(r io.Reader) (int, error) {
+	entries, err := readJournalEntries(r)
+	if err != nil {
+		return 0, err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	known := make(map[string]bool, len(j.entries))
+	for _, entry := range j.entries {
+		if entry.TxID != "" {
+			known[entry.TxID] = true
+		}
+	}
+	imported := 0
+	for _, entry := range entries {
+		if entry.TxID != "" && known[entry.TxID] {
+			continue
+		}
+		if err := j.append(entry); err != nil {
+			return imported, err
+		}
+		known[entry.TxID] = true
+		imported++
+	}
+	return imported, nil
+}
+
+// Reconcile checks every committed entry against the ledger, e.g. with GetTransactionByID on qscc,
+// and returns the entries whose transaction the ledger does not know, which need to be replayed
+func (j *Journal) Reconcile(onLedger func(txID string) (bool, error)) ([]JournalEntry, error) {
+	var missing []JournalEntry
+	for _, entry := range j.Entries() {
+		if entry.Status != JournalCommitted {
+			continue
+		}
+		found, err := onLedger(entry.TxID)
+		if err != nil {
+			return nil, fmt.Errorf("error looking up transaction %s: %v", entry.TxID, err)
+		}
+		if !found {
+			missing = append(missing, entry)
+		}
+	}
+	return missing, nil
+}
+
+// Replay submits the given entries again in order, recording each resubmission.
+// It stops at the first entry that cannot be replayed or fails.
+func (j *Journal) Replay(entries []JournalEntry, submit SubmitFunc) error {
+	for _, entry := range entries {
+		if HashArgs(entry.Args) != entry.ArgsHash {
+			return fmt.Errorf("%w: %s %s", ErrNotReplayable, entry.Function, entry.TxID)
+		}
+		if _, err := j.Submit(submit, entry.Function, entry.Args...); err != nil {
+			return fmt.Errorf("error replaying %s %s: %v", entry.Function, entry.TxID, err)
+		}
+	}
+	return nil
+}
+
+func readJournalEntries(r io.Reader) ([]JournalEntry, error) {
+	var entries []JournalEntry
+	err := readJSONLines(r, "journal", func(line []byte) error {
+		var entry JournalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return fmt.Errorf("error decoding journal entry %d: %v", len(entries)+1, err)
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, err
+}
+
+// readJSONLines calls decode for every non-empty line of r, a file of the given kind
+func readJSONLines(r io.Reader, kind string, decode func(line []byte) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if err := decode(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading %s: %v", kind, err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package client_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/pherbke/credential-management/chaincode-go/client"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+// countingSubmit returns sequential transaction IDs and fails for the function "Fail"
+func countingSubmit() client.SubmitFunc {
+	count := 0
+	return func(function string, args ...string) (string, []byte, error) {
+		count++
+		if function == "Fail" {
+			return "", nil, errors.New("endorsement failed")
+		}
+		return fmt.Sprintf("tx%d", count), []byte("ok"), nil
+	}
+}
+
+func TestJournal_RecordsAndReopens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	journal, err := client.OpenJournal(path)
+	require.NoError(t, err)
+	submit := countingSubmit()
+
+	payload, err := journal.Submit(submit, "Insert", "credential-1")
+	require.NoError(t, err)
+	require.Equal(t, "ok", string(payload))
+	_, err = journal.Submit(submit, "Fail", "credential-2")
+	require.Error(t, err)
+	require.NoError(t, journal.Close())
+
+	journal, err = client.OpenJournal(path)
+	require.NoError(t, err)
+	defer journal.Close()
+	entries := journal.Entries()
+	require.Len(t, entries, 2)
+	require.Equal(t, "tx1", entries[0].TxID)
+	require.Equal(t, client.JournalCommitted, entries[0].Status)
+	require.Equal(t, client.HashArgs([]string{"credential-1"}), entries[0].ArgsHash)
+	require.Nil(t, entries[0].Args)
+	require.Equal(t, client.JournalFailed, entries[1].Status)
+	require.Equal(t, "endorsement failed", entries[1].Result)
+}
+
+func TestJournal_ExportImport(t *testing.T) {
+	source, err := client.OpenJournal("")
+	require.NoError(t, err)
+	submit := countingSubmit()
+	_, err = source.Submit(submit, "Insert", "credential-1")
+	require.NoError(t, err)
+	_, err = source.Submit(submit, "Insert", "credential-2")
+	require.NoError(t, err)
+
+	var exported bytes.Buffer
+	require.NoError(t, source.Export(&exported))
+
+	target, err := client.OpenJournal("")
+	require.NoError(t, err)
+	require.NoError(t, target.Record(source.Entries()[0]))
+	imported, err := target.Import(bytes.NewReader(exported.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, 1, imported)
+	require.Equal(t, source.Entries(), target.Entries())
+}
+
+func TestJournal_ReconcileAndReplay(t *testing.T) {
+	journal, err := client.OpenJournal("")
+	require.NoError(t, err)
+	journal.StoreArgs = true
+	submit := countingSubmit()
+	_, err = journal.Submit(submit, "Insert", "credential-1")
+	require.NoError(t, err)
+	_, err = journal.Submit(submit, "Insert", "credential-2")
+	require.NoError(t, err)
+
+	// The ledger was restored from a backup that lacks tx2
+	missing, err := journal.Reconcile(func(txID string) (bool, error) {
+		return txID == "tx1", nil
+	})
+	require.NoError(t, err)
+	require.Len(t, missing, 1)
+	require.Equal(t, []string{"credential-2"}, missing[0].Args)
+
+	var replayed []string
+	require.NoError(t, journal.Replay(missing, func(function string, args ...string) (string, []byte, error) {
+		replayed = append(replayed, args...)
+		return "tx3", nil, nil
+	}))
+	require.Equal(t, []string{"credential-2"}, replayed)
+	require.Len(t, journal.Entries(), 3)
+
+	missing[0].Args = nil
+	require.ErrorIs(t, journal.Replay(missing, submit), client.ErrNotReplayable)
+}
@@ -0,0 +1,162 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
+	"sync"
+	"time"
+)
+
+// ErrNotLeader is returned by AcquireLease while another replica holds an unexpired lease
+var ErrNotLeader = errors.New("another replica holds the listener lease")
+
+// ErrFenced is returned when a replica writes with the fencing token of a lease another replica took over
+var ErrFenced = errors.New("fencing token is stale, another replica took over the listener")
+
+// Lease makes one listener replica the leader until it expires. Every new holder gets a larger
+// fencing token, which it passes along with every write, so stores and sinks can reject writes of a
+// replica that lost the lease without noticing, e.g. after a long GC pause mid-block.
+type Lease struct {
+	Holder  string    `json:"holder"`
+	Token   uint64    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+// Checkpoint is the last block whose events were completely handled
+type Checkpoint struct {
+	Block     uint64    `json:"block"`
+	Token     uint64    `json:"token"` // Fencing token of the replica that wrote it
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// CheckpointStore is the lease and checkpoint storage shared by all listener replicas, e.g. a row in a
+// SQL database updated with compare-and-swap or a key in etcd
+type CheckpointStore interface {
+	// AcquireLease grants the lease to a replica if it is free, expired or already held by the replica,
+	// and extends it to now+ttl. It returns ErrNotLeader while another replica holds it.
+	AcquireLease(replica string, ttl time.Duration, now time.Time) (*Lease, error)
+	// Checkpoint returns the current checkpoint, nil before the first block was handled
+	Checkpoint() (*Checkpoint, error)
+	// Commit stores the checkpoint, failing with ErrFenced unless token is the newest fencing token
+	Commit(checkpoint Checkpoint) error
+}
+
+// MemoryCheckpointStore is a CheckpointStore for replicas in one process and for tests
+type MemoryCheckpointStore struct {
+	mu         sync.Mutex
+	lease      Lease
+	checkpoint *Checkpoint
+}
+
+// AcquireLease implements CheckpointStore
+func (s *MemoryCheckpointStore) AcquireLease(replica string, ttl time.Duration, now time.Time) (*Lease, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lease.Holder != replica {
+		if s.lease.Holder != "" && now.Before(s.lease.Expires) {
+			return nil, ErrNotLeader
+		}
+		s.lease.Holder = replica
+		s.lease.Token++
+	}
+	s.lease.Expires = now.Add(ttl)
+	lease := s.lease
+	return &lease, nil
+}
+
+// Checkpoint implements CheckpointStore
+func (s *MemoryCheckpointStore) Checkpoint() (*Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.checkpoint == nil {
+		return nil, nil
+	}
+	checkpoint := *s.checkpoint
+	return &checkpoint, nil
+}
+
+// Commit implements CheckpointStore
+func (s *MemoryCheckpointStore) Commit(checkpoint Checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if checkpoint.Token != s.lease.Token {
+		return ErrFenced
+	}
+	if s.checkpoint != nil && checkpoint.Block <= s.checkpoint.Block {
+		return fmt.Errorf("checkpoint %d does not advance past block %d", checkpoint.Block, s.checkpoint.Block)
+	}
+	s.checkpoint = &checkpoint
+	return nil
+}
+
+// ChaincodeEvent is a chaincode event of a committed transaction
+type ChaincodeEvent struct {
+	TxID    string
+	Name    string
+	Payload []byte
+}
+
+// BlockEvents holds the chaincode events of one block, in transaction order
+type BlockEvents struct {
+	Number uint64
+	Events []ChaincodeEvent
+}
+
+// Listener processes the chaincode events of the registry block by block. Any number of replicas can run
+// against a shared CheckpointStore: only the holder of the lease processes blocks, standbys take over
+// once the lease expires and continue after the last checkpoint. A block is checkpointed after it was
+// handled, so after a failover mid-block the block is handled again; Handle must be idempotent per
+// block, and sinks should reject writes carrying a token older than the newest they have seen.
+type Listener struct {
+	Replica    string
+	Store      CheckpointStore
+	LeaseTTL   time.Duration
+	StartBlock uint64                                       // First block to handle when there is no checkpoint
+	Fetch      func(block uint64) (*BlockEvents, error)     // Returns nil while the block is not committed yet
+	Handle     func(block *BlockEvents, token uint64) error // Called with the fencing token of the lease
+	Clock      clock.Clock                                  // Optional, defaults to clock.System
+}
+
+// Step handles up to maxBlocks blocks if the replica holds or can acquire the lease and returns the
+// number of blocks handled. A standby handles nothing and returns no error. Steps stop early at the end
+// of the chain and once the lease expired; ErrFenced means another replica took over.
+func (l *Listener) Step(maxBlocks int) (int, error) {
+	now := clock.Or(l.Clock)
+	lease, err := l.Store.AcquireLease(l.Replica, l.LeaseTTL, now.Now())
+	if err == ErrNotLeader {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("error acquiring listener lease: %v", err)
+	}
+	checkpoint, err := l.Store.Checkpoint()
+	if err != nil {
+		return 0, fmt.Errorf("error reading checkpoint: %v", err)
+	}
+	next := l.StartBlock
+	if checkpoint != nil {
+		next = checkpoint.Block + 1
+	}
+
+	handled := 0
+	for ; handled < maxBlocks && now.Now().Before(lease.Expires); handled++ {
+		block, err := l.Fetch(next)
+		if err != nil {
+			return handled, fmt.Errorf("error fetching block %d: %v", next, err)
+		}
+		if block == nil {
+			break
+		}
+		if err := l.Handle(block, lease.Token); err != nil {
+			return handled, fmt.Errorf("error handling block %d: %w", next, err)
+		}
+		if err := l.Store.Commit(Checkpoint{Block: next, Token: lease.Token, UpdatedAt: now.Now()}); err != nil {
+			if err == ErrFenced {
+				return handled, err
+			}
+			return handled, fmt.Errorf("error committing checkpoint %d: %v", next, err)
+		}
+		next++
+	}
+	return handled, nil
+}
@@ -0,0 +1,127 @@
+package client_test
+
+import (
+	"github.com/pherbke/credential-management/chaincode-go/client"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+// chain serves blocks 1 to height
+func chain(height uint64) func(uint64) (*client.BlockEvents, error) {
+	return func(block uint64) (*client.BlockEvents, error) {
+		if block > height {
+			return nil, nil
+		}
+		return &client.BlockEvents{Number: block, Events: []client.ChaincodeEvent{{Name: "FilterChanged"}}}, nil
+	}
+}
+
+// fencedSink records handled blocks and rejects writes with a token older than the newest it has seen
+type fencedSink struct {
+	mu      sync.Mutex
+	token   uint64
+	handled map[uint64]int
+}
+
+func (s *fencedSink) write(block *client.BlockEvents, token uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if token < s.token {
+		return client.ErrFenced
+	}
+	s.token = token
+	if s.handled == nil {
+		s.handled = make(map[uint64]int)
+	}
+	s.handled[block.Number]++
+	return nil
+}
+
+func TestListener_StandbyWaitsForLease(t *testing.T) {
+	now := clock.NewManual(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	store := &client.MemoryCheckpointStore{}
+	sink := &fencedSink{}
+	newReplica := func(name string) *client.Listener {
+		return &client.Listener{Replica: name, Store: store, LeaseTTL: 10 * time.Second, StartBlock: 1, Fetch: chain(5), Handle: sink.write, Clock: now}
+	}
+	primary, standby := newReplica("a"), newReplica("b")
+
+	handled, err := primary.Step(3)
+	require.NoError(t, err)
+	require.Equal(t, 3, handled)
+
+	// The standby does nothing while the lease is held, even after the primary renewed it
+	now.Advance(5 * time.Second)
+	handled, err = standby.Step(10)
+	require.NoError(t, err)
+	require.Zero(t, handled)
+	_, err = primary.Step(1)
+	require.NoError(t, err)
+	now.Advance(9 * time.Second)
+	handled, err = standby.Step(10)
+	require.NoError(t, err)
+	require.Zero(t, handled)
+
+	// Once the primary stops renewing, the standby continues after the checkpoint
+	now.Advance(2 * time.Second)
+	handled, err = standby.Step(10)
+	require.NoError(t, err)
+	require.Equal(t, 1, handled)
+
+	checkpoint, err := store.Checkpoint()
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), checkpoint.Block)
+	require.Equal(t, map[uint64]int{1: 1, 2: 1, 3: 1, 4: 1, 5: 1}, sink.handled)
+}
+
+func TestListener_FailoverMidBlock(t *testing.T) {
+	now := clock.NewManual(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	store := &client.MemoryCheckpointStore{}
+	sink := &fencedSink{}
+	standby := &client.Listener{Replica: "b", Store: store, LeaseTTL: 10 * time.Second, StartBlock: 1, Fetch: chain(6), Handle: sink.write, Clock: now}
+
+	// The primary pauses in block 3 until its lease expired and the standby took over
+	var standbyHandled int
+	primary := &client.Listener{Replica: "a", Store: store, LeaseTTL: 10 * time.Second, StartBlock: 1, Fetch: chain(6), Clock: now}
+	primary.Handle = func(block *client.BlockEvents, token uint64) error {
+		if block.Number == 3 {
+			now.Advance(11 * time.Second)
+			var err error
+			standbyHandled, err = standby.Step(10)
+			require.NoError(t, err)
+		}
+		return sink.write(block, token)
+	}
+
+	handled, err := primary.Step(10)
+	require.ErrorIs(t, err, client.ErrFenced)
+	require.Equal(t, 2, handled)
+	require.Equal(t, 4, standbyHandled)
+
+	// Every block was handled exactly once and the checkpoint was not moved back by the old primary
+	require.Equal(t, map[uint64]int{1: 1, 2: 1, 3: 1, 4: 1, 5: 1, 6: 1}, sink.handled)
+	checkpoint, err := store.Checkpoint()
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), checkpoint.Block)
+	require.Equal(t, uint64(2), checkpoint.Token)
+
+	// A stale checkpoint write is fenced even if the sink accepted the block
+	require.ErrorIs(t, store.Commit(client.Checkpoint{Block: 7, Token: 1}), client.ErrFenced)
+}
+
+func TestListener_StopsBeforeLeaseExpires(t *testing.T) {
+	now := clock.NewManual(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	store := &client.MemoryCheckpointStore{}
+	listener := &client.Listener{Replica: "a", Store: store, LeaseTTL: 10 * time.Second, StartBlock: 1, Fetch: chain(100), Clock: now}
+	listener.Handle = func(*client.BlockEvents, uint64) error {
+		now.Advance(4 * time.Second)
+		return nil
+	}
+
+	handled, err := listener.Step(100)
+	require.NoError(t, err)
+	require.Equal(t, 3, handled)
+}
@@ -0,0 +1,319 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Status values of an outbox record
+const (
+	OutboxPending   = "pending"   // Persisted, waiting to be submitted
+	OutboxSubmitted = "submitted" // Submitted, waiting for its commit to be observed
+	OutboxCompleted = "completed" // Commit observed
+	OutboxFailed    = "failed"    // Gave up after MaxAttempts
+)
+
+// DefaultOutboxBackoff is the retry delay after the first failed attempt, doubled on every further attempt
+const DefaultOutboxBackoff = time.Second
+
+// ErrDuplicateIntent is returned by Enqueue for an ID already used by a different intent
+var ErrDuplicateIntent = errors.New("outbox ID is already used by a different intent")
+
+// OutboxRecord is an intent to submit a transaction
+type OutboxRecord struct {
+	ID          string    `json:"id"` // Chosen by the caller, e.g. the credential ID, to make Enqueue idempotent
+	Function    string    `json:"function"`
+	Args        []string  `json:"args"`
+	Status      string    `json:"status"`
+	TxID        string    `json:"txId,omitempty"` // ID of the latest submission
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"lastError,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	NextAttempt time.Time `json:"nextAttempt,omitempty"`
+}
+
+// Outbox makes ledger writes of a service survive crashes: the service persists an intent with Enqueue
+// in the same step as its local work, e.g. right after signing a credential, and the dispatcher submits
+// it later. A record is only completed once its commit is observed through Committed or Reconcile, so
+// submissions that were lost, rejected or invalidated are retried. Delivery is at least once; a crash
+// between a submission and its record update submits the intent again, so the submitted transactions
+// should tolerate repeats.
+//
+// Records are kept in an append-only JSON lines file holding the latest state of a record per line.
+type Outbox struct {
+	MaxAttempts   int           // Failed attempts before a record fails for good, 0 retries forever
+	Backoff       time.Duration // Defaults to DefaultOutboxBackoff
+	CommitTimeout time.Duration // Time after which Reconcile resubmits a submission the ledger does not know, 0 never does
+	Clock         clock.Clock   // Optional, defaults to clock.System
+
+	mu      sync.Mutex
+	file    *os.File
+	records map[string]*OutboxRecord
+	byTxID  map[string]string
+}
+
+// OpenOutbox opens the outbox at path, creating it if needed, and loads the latest state of every record.
+// An empty path keeps the outbox in memory.
+func OpenOutbox(path string) (*Outbox, error) {
+	outbox := &Outbox{records: make(map[string]*OutboxRecord), byTxID: make(map[string]string)}
+	if path == "" {
+		return outbox, nil
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening outbox: %v", err)
+	}
+	if err := readJSONLines(file, "outbox", func(line []byte) error {
+		var record OutboxRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return fmt.Errorf("error decoding outbox record: %v", err)
+		}
+		outbox.index(&record)
+		return nil
+	}); err != nil {
+		file.Close()
+		return nil, err
+	}
+	outbox.file = file
+	return outbox, nil
+}
+
+// Close closes the outbox file
+func (o *Outbox) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.file == nil {
+		return nil
+	}
+	err := o.file.Close()
+	o.file = nil
+	return err
+}
+
+// Enqueue persists an intent to submit function with args. Enqueueing the same intent under the same
+// ID again returns the existing record, so callers can enqueue again after a crash without knowing
+// whether the first call got through.
+func (o *Outbox) Enqueue(id string, function string, args ...string) (*OutboxRecord, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if existing, ok := o.records[id]; ok {
+		if existing.Function != function || HashArgs(existing.Args) != HashArgs(args) {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateIntent, id)
+		}
+		copied := *existing
+		return &copied, nil
+	}
+	now := clock.Or(o.Clock).Now().UTC()
+	record := &OutboxRecord{
+		ID:        id,
+		Function:  function,
+		Args:      args,
+		Status:    OutboxPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := o.save(record); err != nil {
+		return nil, err
+	}
+	copied := *record
+	return &copied, nil
+}
+
+// Dispatch submits the pending records that are due, oldest first, and returns the number submitted.
+// Failed submissions are retried after a backoff; the first error writing the outbox is returned.
+// Run a single dispatcher per outbox.
+func (o *Outbox) Dispatch(submit SubmitFunc) (int, error) {
+	submitted := 0
+	for _, record := range o.due() {
+		txID, _, err := submit(record.Function, record.Args...)
+
+		o.mu.Lock()
+		now := clock.Or(o.Clock).Now().UTC()
+		record.UpdatedAt = now
+		if err != nil {
+			o.retry(record, err.Error(), now)
+		} else {
+			record.Status = OutboxSubmitted
+			record.TxID = txID
+			record.LastError = ""
+			record.NextAttempt = time.Time{}
+			submitted++
+		}
+		saveErr := o.save(record)
+		o.mu.Unlock()
+		if saveErr != nil {
+			return submitted, saveErr
+		}
+	}
+	return submitted, nil
+}
+
+// Committed records the commit of a transaction, e.g. from a commit status or a chaincode event.
+// Valid commits complete their record, invalidated transactions are retried.
+func (o *Outbox) Committed(txID string, valid bool) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	record, ok := o.records[o.byTxID[txID]]
+	if !ok || record.TxID != txID || record.Status != OutboxSubmitted {
+		return nil
+	}
+	now := clock.Or(o.Clock).Now().UTC()
+	record.UpdatedAt = now
+	if valid {
+		record.Status = OutboxCompleted
+	} else {
+		o.retry(record, fmt.Sprintf("transaction %s was invalidated", txID), now)
+	}
+	return o.save(record)
+}
+
+// ObserveBlock completes the records whose transactions emitted a chaincode event in the block, so the
+// outbox can be fed by the Handle function of a Listener
+func (o *Outbox) ObserveBlock(block *BlockEvents) error {
+	for _, event := range block.Events {
+		if err := o.Committed(event.TxID, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reconcile checks the submitted records against the ledger, e.g. with GetTransactionByID on qscc, for
+// commits that were not observed. Records found on the ledger are completed; records the ledger does
+// not know after CommitTimeout are submitted again.
+func (o *Outbox) Reconcile(onLedger func(txID string) (bool, error)) error {
+	for _, record := range o.Records() {
+		if record.Status != OutboxSubmitted {
+			continue
+		}
+		found, err := onLedger(record.TxID)
+		if err != nil {
+			return fmt.Errorf("error looking up transaction %s: %v", record.TxID, err)
+		}
+		if found {
+			if err := o.Committed(record.TxID, true); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := o.expire(record.ID, record.TxID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run dispatches the due records every interval until stop is closed. Failed dispatches are retried
+// at the next interval.
+func (o *Outbox) Run(submit SubmitFunc, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			_, _ = o.Dispatch(submit)
+		}
+	}
+}
+
+// Records returns a copy of every record, oldest first
+func (o *Outbox) Records() []OutboxRecord {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	records := make([]OutboxRecord, 0, len(o.records))
+	for _, record := range o.records {
+		records = append(records, *record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].CreatedAt.Equal(records[j].CreatedAt) {
+			return records[i].CreatedAt.Before(records[j].CreatedAt)
+		}
+		return records[i].ID < records[j].ID
+	})
+	return records
+}
+
+// due returns the pending records whose next attempt is due, oldest first
+func (o *Outbox) due() []*OutboxRecord {
+	now := clock.Or(o.Clock).Now()
+	var due []*OutboxRecord
+	for _, record := range o.Records() {
+		if record.Status == OutboxPending && !record.NextAttempt.After(now) {
+			record := record
+			due = append(due, &record)
+		}
+	}
+	return due
+}
+
+// expire returns a submission the ledger does not know to pending once CommitTimeout has passed
+func (o *Outbox) expire(id string, txID string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	record, ok := o.records[id]
+	if !ok || record.TxID != txID || record.Status != OutboxSubmitted || o.CommitTimeout <= 0 {
+		return nil
+	}
+	now := clock.Or(o.Clock).Now().UTC()
+	if now.Sub(record.UpdatedAt) < o.CommitTimeout {
+		return nil
+	}
+	record.UpdatedAt = now
+	o.retry(record, fmt.Sprintf("transaction %s was not committed within %s", txID, o.CommitTimeout), now)
+	return o.save(record)
+}
+
+// retry counts a failed attempt and schedules the next one, or fails the record after MaxAttempts
+func (o *Outbox) retry(record *OutboxRecord, reason string, now time.Time) {
+	record.Attempts++
+	record.LastError = reason
+	if o.MaxAttempts > 0 && record.Attempts >= o.MaxAttempts {
+		record.Status = OutboxFailed
+		record.NextAttempt = time.Time{}
+		return
+	}
+	backoff := o.Backoff
+	if backoff <= 0 {
+		backoff = DefaultOutboxBackoff
+	}
+	for i := 1; i < record.Attempts && backoff < time.Hour; i++ {
+		backoff *= 2
+	}
+	record.Status = OutboxPending
+	record.NextAttempt = now.Add(backoff)
+}
+
+// save appends the state of a record to the file and syncs it, then updates the in-memory state
+func (o *Outbox) save(record *OutboxRecord) error {
+	if o.file != nil {
+		recordJSON, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if _, err := o.file.Write(append(recordJSON, '\n')); err != nil {
+			return fmt.Errorf("error writing outbox: %v", err)
+		}
+		if err := o.file.Sync(); err != nil {
+			return fmt.Errorf("error syncing outbox: %v", err)
+		}
+	}
+	copied := *record
+	o.index(&copied)
+	return nil
+}
+
+func (o *Outbox) index(record *OutboxRecord) {
+	o.records[record.ID] = record
+	if record.TxID != "" {
+		o.byTxID[record.TxID] = record.ID
+	}
+}
@@ -0,0 +1,132 @@
+package client_test
+
+import (
+	"errors"
+	"github.com/pherbke/credential-management/chaincode-go/client"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOutbox_SurvivesCrashBeforeSubmission(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	outbox, err := client.OpenOutbox(path)
+	require.NoError(t, err)
+	// The issuer signed a credential and crashes right after persisting the revocation intent
+	_, err = outbox.Enqueue("credential-1", "Insert", "", "fingerprint-1")
+	require.NoError(t, err)
+	require.NoError(t, outbox.Close())
+
+	outbox, err = client.OpenOutbox(path)
+	require.NoError(t, err)
+	defer outbox.Close()
+	// Enqueueing again after the restart is harmless
+	record, err := outbox.Enqueue("credential-1", "Insert", "", "fingerprint-1")
+	require.NoError(t, err)
+	require.Equal(t, client.OutboxPending, record.Status)
+	_, err = outbox.Enqueue("credential-1", "Insert", "", "fingerprint-2")
+	require.ErrorIs(t, err, client.ErrDuplicateIntent)
+
+	submitted, err := outbox.Dispatch(countingSubmit())
+	require.NoError(t, err)
+	require.Equal(t, 1, submitted)
+	records := outbox.Records()
+	require.Len(t, records, 1)
+	require.Equal(t, client.OutboxSubmitted, records[0].Status)
+	require.Equal(t, "tx1", records[0].TxID)
+
+	// Only the observed commit completes the record
+	require.NoError(t, outbox.ObserveBlock(&client.BlockEvents{Number: 7, Events: []client.ChaincodeEvent{{TxID: "tx1", Name: "FilterChanged"}}}))
+	require.Equal(t, client.OutboxCompleted, outbox.Records()[0].Status)
+	submitted, err = outbox.Dispatch(countingSubmit())
+	require.NoError(t, err)
+	require.Equal(t, 0, submitted)
+}
+
+func TestOutbox_RetriesWithBackoff(t *testing.T) {
+	now := clock.NewManual(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	outbox, err := client.OpenOutbox("")
+	require.NoError(t, err)
+	outbox.Clock = now
+	outbox.Backoff = time.Minute
+	outbox.MaxAttempts = 3
+	_, err = outbox.Enqueue("credential-1", "Insert", "", "fingerprint-1")
+	require.NoError(t, err)
+
+	failing := func(function string, args ...string) (string, []byte, error) {
+		return "", nil, errors.New("endorsement failed")
+	}
+	_, err = outbox.Dispatch(failing)
+	require.NoError(t, err)
+	record := outbox.Records()[0]
+	require.Equal(t, client.OutboxPending, record.Status)
+	require.Equal(t, 1, record.Attempts)
+	require.Equal(t, "endorsement failed", record.LastError)
+
+	// Not due before the backoff passed, which doubles on every attempt
+	submitted, err := outbox.Dispatch(failing)
+	require.NoError(t, err)
+	require.Equal(t, 0, submitted)
+	now.Advance(time.Minute)
+	_, err = outbox.Dispatch(failing)
+	require.NoError(t, err)
+	require.Equal(t, now.Now().Add(2*time.Minute), outbox.Records()[0].NextAttempt)
+
+	now.Advance(2 * time.Minute)
+	_, err = outbox.Dispatch(failing)
+	require.NoError(t, err)
+	require.Equal(t, client.OutboxFailed, outbox.Records()[0].Status)
+}
+
+func TestOutbox_InvalidatedTransactionIsResubmitted(t *testing.T) {
+	now := clock.NewManual(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	outbox, err := client.OpenOutbox("")
+	require.NoError(t, err)
+	outbox.Clock = now
+	_, err = outbox.Enqueue("credential-1", "Insert", "", "fingerprint-1")
+	require.NoError(t, err)
+	submit := countingSubmit()
+	_, err = outbox.Dispatch(submit)
+	require.NoError(t, err)
+
+	// An MVCC conflict invalidated the transaction at commit
+	require.NoError(t, outbox.Committed("tx1", false))
+	require.Equal(t, client.OutboxPending, outbox.Records()[0].Status)
+	now.Advance(client.DefaultOutboxBackoff)
+	_, err = outbox.Dispatch(submit)
+	require.NoError(t, err)
+	require.Equal(t, "tx2", outbox.Records()[0].TxID)
+
+	// A late event of the first submission does not complete the record
+	require.NoError(t, outbox.Committed("tx1", true))
+	require.Equal(t, client.OutboxSubmitted, outbox.Records()[0].Status)
+	require.NoError(t, outbox.Committed("tx2", true))
+	require.Equal(t, client.OutboxCompleted, outbox.Records()[0].Status)
+}
+
+func TestOutbox_Reconcile(t *testing.T) {
+	now := clock.NewManual(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	outbox, err := client.OpenOutbox("")
+	require.NoError(t, err)
+	outbox.Clock = now
+	outbox.CommitTimeout = time.Minute
+	_, err = outbox.Enqueue("credential-1", "Insert", "", "fingerprint-1")
+	require.NoError(t, err)
+	_, err = outbox.Enqueue("credential-2", "Insert", "", "fingerprint-2")
+	require.NoError(t, err)
+	_, err = outbox.Dispatch(countingSubmit())
+	require.NoError(t, err)
+
+	// tx1 committed without its event being observed, tx2 was lost
+	onLedger := func(txID string) (bool, error) { return txID == "tx1", nil }
+	require.NoError(t, outbox.Reconcile(onLedger))
+	records := outbox.Records()
+	require.Equal(t, client.OutboxCompleted, records[0].Status)
+	require.Equal(t, client.OutboxSubmitted, records[1].Status)
+
+	now.Advance(time.Minute)
+	require.NoError(t, outbox.Reconcile(onLedger))
+	require.Equal(t, client.OutboxPending, outbox.Records()[1].Status)
+}
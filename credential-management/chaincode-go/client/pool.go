@@ -0,0 +1,188 @@
+// Package client holds client-side helpers for services talking to the registry through Fabric gateways.
+package client
+
+import (
+	"errors"
+	"fmt"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
+	"sync"
+	"time"
+)
+
+// ErrNoEndpoint is returned when every gateway endpoint's circuit is open
+var ErrNoEndpoint = errors.New("no available gateway endpoint")
+
+// EndpointStatus reports the state of one gateway endpoint
+type EndpointStatus struct {
+	Endpoint            string    `json:"endpoint"`
+	Available           bool      `json:"available"` // Circuit closed or due for a retry
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	OpenUntil           time.Time `json:"openUntil,omitempty"`
+}
+
+// Pool fails over between gateway endpoints. Endpoints are tried in order, so the first one is the
+// primary and the others are warm standbys. An endpoint's circuit opens after FailureThreshold
+// consecutive failures and it is skipped until Cooldown has passed or a health check succeeds.
+//
+// The pool only picks endpoints; callers keep one gateway connection per endpoint and run their
+// calls on the endpoint passed to them.
+type Pool struct {
+	Endpoints        []string
+	FailureThreshold int                         // Consecutive failures that open an endpoint's circuit, defaults to 1
+	Cooldown         time.Duration               // Time an open circuit is skipped
+	HealthCheck      func(endpoint string) error // Optional probe used by CheckHealth
+	Clock            clock.Clock                 // Optional, defaults to clock.System
+
+	mu     sync.Mutex
+	states map[string]*endpointState
+	sticky map[string]string
+}
+
+type endpointState struct {
+	failures  int
+	openUntil time.Time
+}
+
+// Do runs fn on the first available endpoint, failing over to the next one when fn returns an error
+func (p *Pool) Do(fn func(endpoint string) error) error {
+	return p.do("", fn)
+}
+
+// DoSticky runs fn like Do, but keeps using the endpoint that last served the key while it is available,
+// e.g. to wait for the commit status of a transaction on the gateway that submitted it
+func (p *Pool) DoSticky(key string, fn func(endpoint string) error) error {
+	return p.do(key, fn)
+}
+
+// Release forgets the endpoint bound to a sticky key
+func (p *Pool) Release(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.sticky, key)
+}
+
+func (p *Pool) do(key string, fn func(endpoint string) error) error {
+	var lastErr error
+	for _, endpoint := range p.candidates(key) {
+		err := fn(endpoint)
+		p.record(endpoint, err)
+		if err == nil {
+			if key != "" {
+				p.bind(key, endpoint)
+			}
+			return nil
+		}
+		lastErr = fmt.Errorf("gateway %s: %v", endpoint, err)
+	}
+	if lastErr == nil {
+		return ErrNoEndpoint
+	}
+	return lastErr
+}
+
+// candidates returns the available endpoints in the order they should be tried
+func (p *Pool) candidates(key string) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := clock.Or(p.Clock).Now()
+	var endpoints []string
+	if bound, ok := p.sticky[key]; ok && key != "" && p.available(bound, now) {
+		endpoints = append(endpoints, bound)
+	}
+	for _, endpoint := range p.Endpoints {
+		if len(endpoints) > 0 && endpoint == endpoints[0] {
+			continue
+		}
+		if p.available(endpoint, now) {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
+}
+
+// available reports whether the endpoint's circuit allows a call, the caller must hold p.mu
+func (p *Pool) available(endpoint string, now time.Time) bool {
+	state, ok := p.states[endpoint]
+	return !ok || !now.Before(state.openUntil)
+}
+
+// record updates the endpoint's circuit with the result of a call
+func (p *Pool) record(endpoint string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.states == nil {
+		p.states = make(map[string]*endpointState)
+	}
+	state, ok := p.states[endpoint]
+	if !ok {
+		state = &endpointState{}
+		p.states[endpoint] = state
+	}
+	if err == nil {
+		state.failures = 0
+		state.openUntil = time.Time{}
+		return
+	}
+
+	state.failures++
+	threshold := p.FailureThreshold
+	if threshold < 1 {
+		threshold = 1
+	}
+	if state.failures >= threshold {
+		state.openUntil = clock.Or(p.Clock).Now().Add(p.Cooldown)
+	}
+}
+
+func (p *Pool) bind(key string, endpoint string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.sticky == nil {
+		p.sticky = make(map[string]string)
+	}
+	p.sticky[key] = endpoint
+}
+
+// CheckHealth probes every endpoint with HealthCheck. A successful probe closes an open circuit.
+func (p *Pool) CheckHealth() {
+	if p.HealthCheck == nil {
+		return
+	}
+	for _, endpoint := range p.Endpoints {
+		p.record(endpoint, p.HealthCheck(endpoint))
+	}
+}
+
+// Watch runs CheckHealth every interval until stop is closed
+func (p *Pool) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.CheckHealth()
+		}
+	}
+}
+
+// Status returns the state of every endpoint in pool order
+func (p *Pool) Status() []EndpointStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := clock.Or(p.Clock).Now()
+	statuses := make([]EndpointStatus, 0, len(p.Endpoints))
+	for _, endpoint := range p.Endpoints {
+		status := EndpointStatus{Endpoint: endpoint, Available: p.available(endpoint, now)}
+		if state, ok := p.states[endpoint]; ok {
+			status.ConsecutiveFailures = state.failures
+			status.OpenUntil = state.openUntil
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
@@ -0,0 +1,113 @@
+package client_test
+
+import (
+	"errors"
+	"github.com/pherbke/credential-management/chaincode-go/client"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// gateways fails calls to the endpoints marked down and records the endpoints called
+type gateways struct {
+	down  map[string]bool
+	calls []string
+}
+
+func (g *gateways) call(endpoint string) error {
+	g.calls = append(g.calls, endpoint)
+	if g.down[endpoint] {
+		return errors.New("unavailable")
+	}
+	return nil
+}
+
+func TestPool_UsesPrimary(t *testing.T) {
+	g := &gateways{}
+	pool := &client.Pool{Endpoints: []string{"peer0", "peer1"}}
+
+	require.NoError(t, pool.Do(g.call))
+	require.Equal(t, []string{"peer0"}, g.calls)
+}
+
+func TestPool_FailsOver(t *testing.T) {
+	g := &gateways{down: map[string]bool{"peer0": true}}
+	pool := &client.Pool{Endpoints: []string{"peer0", "peer1"}, Cooldown: time.Hour}
+
+	require.NoError(t, pool.Do(g.call))
+	require.Equal(t, []string{"peer0", "peer1"}, g.calls)
+
+	// The open circuit skips peer0 until the cooldown passes
+	require.NoError(t, pool.Do(g.call))
+	require.Equal(t, []string{"peer0", "peer1", "peer1"}, g.calls)
+	require.False(t, pool.Status()[0].Available)
+}
+
+func TestPool_RetriesAfterCooldown(t *testing.T) {
+	g := &gateways{down: map[string]bool{"peer0": true}}
+	now := clock.NewManual(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	pool := &client.Pool{Endpoints: []string{"peer0", "peer1"}, Cooldown: time.Hour, Clock: now}
+
+	require.NoError(t, pool.Do(g.call))
+	now.Advance(time.Hour)
+	require.True(t, pool.Status()[0].Available)
+
+	g.down["peer0"] = false
+	require.NoError(t, pool.Do(g.call))
+	require.Equal(t, []string{"peer0", "peer1", "peer0"}, g.calls)
+}
+
+func TestPool_FailureThreshold(t *testing.T) {
+	g := &gateways{down: map[string]bool{"peer0": true}}
+	pool := &client.Pool{Endpoints: []string{"peer0", "peer1"}, FailureThreshold: 2, Cooldown: time.Hour}
+
+	require.NoError(t, pool.Do(g.call))
+	require.True(t, pool.Status()[0].Available)
+	require.NoError(t, pool.Do(g.call))
+	require.False(t, pool.Status()[0].Available)
+	require.Equal(t, 2, pool.Status()[0].ConsecutiveFailures)
+}
+
+func TestPool_AllDown(t *testing.T) {
+	g := &gateways{down: map[string]bool{"peer0": true, "peer1": true}}
+	pool := &client.Pool{Endpoints: []string{"peer0", "peer1"}, Cooldown: time.Hour}
+
+	require.Error(t, pool.Do(g.call))
+	require.ErrorIs(t, pool.Do(g.call), client.ErrNoEndpoint)
+}
+
+func TestPool_HealthCheckClosesCircuit(t *testing.T) {
+	g := &gateways{down: map[string]bool{"peer0": true}}
+	pool := &client.Pool{Endpoints: []string{"peer0", "peer1"}, Cooldown: time.Hour, HealthCheck: g.call}
+
+	require.NoError(t, pool.Do(g.call))
+	require.False(t, pool.Status()[0].Available)
+
+	g.down["peer0"] = false
+	pool.CheckHealth()
+	require.True(t, pool.Status()[0].Available)
+	g.calls = nil
+	require.NoError(t, pool.Do(g.call))
+	require.Equal(t, []string{"peer0"}, g.calls)
+}
+
+func TestPool_Sticky(t *testing.T) {
+	g := &gateways{down: map[string]bool{"peer0": true}}
+	pool := &client.Pool{Endpoints: []string{"peer0", "peer1"}}
+
+	// Submitted through peer1 after peer0 failed
+	require.NoError(t, pool.DoSticky("tx1", g.call))
+
+	// peer0 recovers, but waiting for tx1 stays on peer1 while other calls return to the primary
+	g.down["peer0"] = false
+	g.calls = nil
+	require.NoError(t, pool.DoSticky("tx1", g.call))
+	require.NoError(t, pool.Do(g.call))
+	require.Equal(t, []string{"peer1", "peer0"}, g.calls)
+
+	pool.Release("tx1")
+	g.calls = nil
+	require.NoError(t, pool.DoSticky("tx1", g.call))
+	require.Equal(t, []string{"peer0"}, g.calls)
+}
@@ -0,0 +1,244 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+)
+
+// Types of a RegistryEvent
+const (
+	RegistryEventRevoked   = "revoked"   // Fingerprints were inserted into a filter or namespace
+	RegistryEventUnrevoked = "unrevoked" // Fingerprints were deleted from a filter or namespace
+)
+
+// RegistryEvent is the normalized form of a FilterChanged event, as published to event buses
+type RegistryEvent struct {
+	ID           string   `json:"id"` // ID of the transaction, the idempotency key of the event
+	Block        uint64   `json:"block"`
+	Type         string   `json:"type"`
+	FilterID     string   `json:"filterId,omitempty"`
+	Namespace    string   `json:"namespace,omitempty"`
+	Fingerprints []string `json:"fingerprints"` // Empty for changes of a private default filter
+	Count        uint     `json:"count"`        // Filter or shard count after the transaction
+}
+
+// NormalizeEvent returns the registry event of a FilterChanged event, nil for all other events
+func NormalizeEvent(block uint64, event ChaincodeEvent) (*RegistryEvent, error) {
+	if event.Name != cuckoofilter.FilterChangedEvent {
+		return nil, nil
+	}
+	var change cuckoofilter.FilterChange
+	if err := json.Unmarshal(event.Payload, &change); err != nil {
+		return nil, fmt.Errorf("error decoding %s event of transaction %s: %v", event.Name, event.TxID, err)
+	}
+	eventType := RegistryEventRevoked
+	if change.Action == cuckoofilter.FilterChangeDeleted {
+		eventType = RegistryEventUnrevoked
+	}
+	return &RegistryEvent{
+		ID:           event.TxID,
+		Block:        block,
+		Type:         eventType,
+		FilterID:     change.FilterID,
+		Namespace:    change.Namespace,
+		Fingerprints: change.Fingerprints,
+		Count:        change.Count,
+	}, nil
+}
+
+// Publisher delivers registry events to an event bus. Events carry the transaction ID as key, so a bus
+// with idempotent producers or a deduplication window drops the copies published again when a block
+// is handled a second time after a failover.
+type Publisher interface {
+	Publish(event RegistryEvent) error
+}
+
+// PublisherFunc adapts a function to the Publisher interface
+type PublisherFunc func(event RegistryEvent) error
+
+// Publish calls f(event)
+func (f PublisherFunc) Publish(event RegistryEvent) error {
+	return f(event)
+}
+
+// EventPublisher publishes the filter changes of the blocks a Listener handles. Pass HandleBlock as the
+// Handle of the Listener: a block is checkpointed only after every publisher took all of its events,
+// so events are published at least once and the transaction ID key removes the duplicates.
+type EventPublisher struct {
+	Publishers []Publisher
+}
+
+// HandleBlock publishes the filter changes of a block in transaction order, it has the signature of
+// Listener.Handle
+func (p *EventPublisher) HandleBlock(block *BlockEvents, token uint64) error {
+	for _, chaincodeEvent := range block.Events {
+		event, err := NormalizeEvent(block.Number, chaincodeEvent)
+		if err != nil {
+			return err
+		}
+		if event == nil {
+			continue
+		}
+		for _, publisher := range p.Publishers {
+			if err := publisher.Publish(*event); err != nil {
+				return fmt.Errorf("error publishing event of transaction %s: %w", event.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// defaultPublisherTimeout bounds the requests of publishers that do not set a timeout
+const defaultPublisherTimeout = 10 * time.Second
+
+// KafkaPublisher produces events to a Kafka topic through a Kafka REST proxy (the Confluent REST API
+// v2). The transaction ID is the record key, so with an idempotent consumer or a compacted topic a
+// republished event replaces its earlier copy.
+type KafkaPublisher struct {
+	ProxyURL string // Base URL of the REST proxy
+	Topic    string
+	Client   *http.Client // Optional, defaults to a client with a 10s timeout
+}
+
+// Publish produces the event as a JSON record keyed by its transaction ID
+func (p *KafkaPublisher) Publish(event RegistryEvent) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"records": []interface{}{map[string]interface{}{"key": event.ID, "value": event}},
+	})
+	if err != nil {
+		return err
+	}
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: defaultPublisherTimeout}
+	}
+	topicURL := strings.TrimSuffix(p.ProxyURL, "/") + "/topics/" + url.PathEscape(p.Topic)
+	response, err := client.Post(topicURL, "application/vnd.kafka.json.v2+json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error producing to Kafka topic %s: %v", p.Topic, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("error producing to Kafka topic %s: unexpected status %s", p.Topic, response.Status)
+	}
+	return nil
+}
+
+// NATSPublisher publishes events to a NATS subject. The transaction ID is sent as Nats-Msg-Id header,
+// which JetStream streams use to drop duplicates within their deduplication window. The publisher keeps
+// one connection and reconnects after an error.
+type NATSPublisher struct {
+	Address string // host:port of the NATS server
+	Subject string
+	Timeout time.Duration // Optional, bounds connecting and each publish, defaults to 10s
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// Publish sends the event as JSON message and waits until the server has processed it
+func (p *NATSPublisher) Publish(event RegistryEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil {
+		if err := p.connect(); err != nil {
+			return fmt.Errorf("error connecting to NATS server %s: %v", p.Address, err)
+		}
+	}
+	if err := p.publish(event.ID, payload); err != nil {
+		p.conn.Close()
+		p.conn = nil
+		return fmt.Errorf("error publishing to NATS subject %s: %v", p.Subject, err)
+	}
+	return nil
+}
+
+// Close closes the connection to the NATS server
+func (p *NATSPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn = nil
+	return err
+}
+
+func (p *NATSPublisher) timeout() time.Duration {
+	if p.Timeout == 0 {
+		return defaultPublisherTimeout
+	}
+	return p.Timeout
+}
+
+// connect opens the connection, reads the server INFO and sends CONNECT with headers enabled
+func (p *NATSPublisher) connect() error {
+	conn, err := net.DialTimeout("tcp", p.Address, p.timeout())
+	if err != nil {
+		return err
+	}
+	reader := bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(p.timeout()))
+	info, err := reader.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if !strings.HasPrefix(info, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("unexpected greeting %q", strings.TrimSpace(info))
+	}
+	if _, err := conn.Write([]byte("CONNECT {\"verbose\":false,\"pedantic\":false,\"headers\":true}\r\n")); err != nil {
+		conn.Close()
+		return err
+	}
+	p.conn, p.reader = conn, reader
+	return nil
+}
+
+// publish sends the message with an HPUB followed by a PING. The server answers the PING after it has
+// processed the message, or reports an -ERR first.
+func (p *NATSPublisher) publish(messageID string, payload []byte) error {
+	header := "NATS/1.0\r\nNats-Msg-Id: " + messageID + "\r\n\r\n"
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "HPUB %s %d %d\r\n%s", p.Subject, len(header), len(header)+len(payload), header)
+	message.Write(payload)
+	message.WriteString("\r\nPING\r\n")
+
+	p.conn.SetDeadline(time.Now().Add(p.timeout()))
+	if _, err := p.conn.Write(message.Bytes()); err != nil {
+		return err
+	}
+	for {
+		line, err := p.reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		switch line = strings.TrimSpace(line); {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := p.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("server error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
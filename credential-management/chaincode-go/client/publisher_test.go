@@ -0,0 +1,189 @@
+package client_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/pherbke/credential-management/chaincode-go/client"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func filterChangedEvent(t *testing.T, txID string, change cuckoofilter.FilterChange) client.ChaincodeEvent {
+	payload, err := json.Marshal(change)
+	require.NoError(t, err)
+	return client.ChaincodeEvent{TxID: txID, Name: cuckoofilter.FilterChangedEvent, Payload: payload}
+}
+
+func TestEventPublisher_HandleBlock(t *testing.T) {
+	var published []client.RegistryEvent
+	publisher := &client.EventPublisher{Publishers: []client.Publisher{client.PublisherFunc(func(event client.RegistryEvent) error {
+		published = append(published, event)
+		return nil
+	})}}
+
+	block := &client.BlockEvents{Number: 7, Events: []client.ChaincodeEvent{
+		filterChangedEvent(t, "tx1", cuckoofilter.FilterChange{FilterID: "tenant-a", Action: cuckoofilter.FilterChangeInserted, Fingerprints: []string{"a", "b"}, Count: 2}),
+		{TxID: "tx2", Name: cuckoofilter.RegistryConfigChangedEvent, Payload: []byte("{}")},
+		filterChangedEvent(t, "tx3", cuckoofilter.FilterChange{Namespace: "did:key:issuer", Action: cuckoofilter.FilterChangeDeleted, Fingerprints: []string{"a"}, Count: 1}),
+	}}
+	require.NoError(t, publisher.HandleBlock(block, 1))
+	require.Equal(t, []client.RegistryEvent{
+		{ID: "tx1", Block: 7, Type: client.RegistryEventRevoked, FilterID: "tenant-a", Fingerprints: []string{"a", "b"}, Count: 2},
+		{ID: "tx3", Block: 7, Type: client.RegistryEventUnrevoked, Namespace: "did:key:issuer", Fingerprints: []string{"a"}, Count: 1},
+	}, published)
+}
+
+func TestEventPublisher_FailureKeepsBlockUncheckpointed(t *testing.T) {
+	store := &client.MemoryCheckpointStore{}
+	fail := true
+	published := map[string]int{}
+	publisher := &client.EventPublisher{Publishers: []client.Publisher{client.PublisherFunc(func(event client.RegistryEvent) error {
+		published[event.ID]++
+		if fail {
+			return errors.New("broker unavailable")
+		}
+		return nil
+	})}}
+	listener := &client.Listener{
+		Replica:    "a",
+		Store:      store,
+		LeaseTTL:   time.Minute,
+		StartBlock: 1,
+		Fetch: func(block uint64) (*client.BlockEvents, error) {
+			if block > 1 {
+				return nil, nil
+			}
+			return &client.BlockEvents{Number: 1, Events: []client.ChaincodeEvent{
+				filterChangedEvent(t, "tx1", cuckoofilter.FilterChange{Action: cuckoofilter.FilterChangeInserted, Fingerprints: []string{"a"}, Count: 1}),
+			}}, nil
+		},
+		Handle: publisher.HandleBlock,
+	}
+
+	_, err := listener.Step(1)
+	require.ErrorContains(t, err, "broker unavailable")
+	checkpoint, err := store.Checkpoint()
+	require.NoError(t, err)
+	require.Nil(t, checkpoint)
+
+	// The block is published again with the same key once the broker is back
+	fail = false
+	handled, err := listener.Step(1)
+	require.NoError(t, err)
+	require.Equal(t, 1, handled)
+	require.Equal(t, 2, published["tx1"])
+}
+
+func TestKafkaPublisher(t *testing.T) {
+	var request struct {
+		Records []struct {
+			Key   string               `json:"key"`
+			Value client.RegistryEvent `json:"value"`
+		} `json:"records"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/topics/registry.events", r.URL.Path)
+		require.Equal(t, "application/vnd.kafka.json.v2+json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+	}))
+	defer server.Close()
+
+	publisher := &client.KafkaPublisher{ProxyURL: server.URL + "/", Topic: "registry.events"}
+	event := client.RegistryEvent{ID: "tx1", Block: 3, Type: client.RegistryEventRevoked, Fingerprints: []string{"a"}, Count: 1}
+	require.NoError(t, publisher.Publish(event))
+	require.Len(t, request.Records, 1)
+	require.Equal(t, "tx1", request.Records[0].Key)
+	require.Equal(t, event, request.Records[0].Value)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	require.Error(t, (&client.KafkaPublisher{ProxyURL: failing.URL, Topic: "registry.events"}).Publish(event))
+}
+
+// natsMessage is a message received by fakeNATSServer
+type natsMessage struct {
+	Subject string
+	Header  string
+	Payload string
+}
+
+// fakeNATSServer accepts NATS connections, records the HPUB messages and answers PINGs. Messages to the
+// subject "rejected" are answered with an -ERR.
+func fakeNATSServer(t *testing.T) (string, <-chan natsMessage) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	messages := make(chan natsMessage, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveNATS(conn, messages)
+		}
+	}()
+	return listener.Addr().String(), messages
+}
+
+func serveNATS(conn net.Conn, messages chan<- natsMessage) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	fmt.Fprint(conn, "INFO {\"headers\":true}\r\n")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 4 && fields[0] == "HPUB":
+			headerLength, _ := strconv.Atoi(fields[2])
+			totalLength, _ := strconv.Atoi(fields[3])
+			message := make([]byte, totalLength+2)
+			if _, err := io.ReadFull(reader, message); err != nil {
+				return
+			}
+			if fields[1] == "rejected" {
+				fmt.Fprint(conn, "-ERR 'Permissions Violation'\r\n")
+				continue
+			}
+			messages <- natsMessage{Subject: fields[1], Header: string(message[:headerLength]), Payload: string(message[headerLength:totalLength])}
+		case len(fields) == 1 && fields[0] == "PING":
+			fmt.Fprint(conn, "PONG\r\n")
+		}
+	}
+}
+
+func TestNATSPublisher(t *testing.T) {
+	address, messages := fakeNATSServer(t)
+	publisher := &client.NATSPublisher{Address: address, Subject: "registry.events", Timeout: 5 * time.Second}
+	defer publisher.Close()
+
+	for _, id := range []string{"tx1", "tx2"} {
+		event := client.RegistryEvent{ID: id, Block: 3, Type: client.RegistryEventRevoked, Fingerprints: []string{"a"}, Count: 1}
+		require.NoError(t, publisher.Publish(event))
+		message := <-messages
+		require.Equal(t, "registry.events", message.Subject)
+		require.Equal(t, "NATS/1.0\r\nNats-Msg-Id: "+id+"\r\n\r\n", message.Header)
+		var received client.RegistryEvent
+		require.NoError(t, json.Unmarshal([]byte(message.Payload), &received))
+		require.Equal(t, event, received)
+	}
+
+	rejected := &client.NATSPublisher{Address: address, Subject: "rejected", Timeout: 5 * time.Second}
+	defer rejected.Close()
+	require.ErrorContains(t, rejected.Publish(client.RegistryEvent{ID: "tx3"}), "Permissions Violation")
+}
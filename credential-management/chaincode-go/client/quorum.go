@@ -0,0 +1,137 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrNoQuorum is returned when no answer of a quorum read was returned by enough peers
+var ErrNoQuorum = errors.New("no answer reached the quorum")
+
+// EvaluateFunc evaluates a transaction on one peer
+type EvaluateFunc func(peer string, function string, args ...string) ([]byte, error)
+
+// Divergence reports the peers whose answer to a quorum read differed from the quorum answer
+type Divergence struct {
+	Function string            `json:"function"`
+	Args     []string          `json:"args"`
+	Answer   []byte            `json:"answer"`           // Quorum answer, nil when there was none
+	Answers  map[string][]byte `json:"answers"`          // Answer of every diverging peer that answered
+	Errors   map[string]string `json:"errors,omitempty"` // Error of every peer that failed
+}
+
+// Peers returns the diverging peers in order
+func (d *Divergence) Peers() []string {
+	peers := make([]string, 0, len(d.Answers)+len(d.Errors))
+	for peer := range d.Answers {
+		peers = append(peers, peer)
+	}
+	for peer := range d.Errors {
+		peers = append(peers, peer)
+	}
+	sort.Strings(peers)
+	return peers
+}
+
+// QuorumMetrics counts the reads of a QuorumReader
+type QuorumMetrics struct {
+	Reads           int            `json:"reads"`
+	Divergent       int            `json:"divergent"` // Reads on which at least one peer diverged
+	NoQuorum        int            `json:"noQuorum"`
+	PeerDivergences map[string]int `json:"peerDivergences"` // Divergent reads per peer
+}
+
+// QuorumReader evaluates queries on several peers and only accepts an answer that Quorum of them
+// returned byte for byte, so a single peer with a corrupted or forked state cannot change the result.
+// Peers that answer differently or fail are reported to OnDivergence and counted in the metrics.
+type QuorumReader struct {
+	Peers        []string
+	Quorum       int // Matching answers required, defaults to a majority of Peers
+	Evaluate     EvaluateFunc
+	OnDivergence func(divergence Divergence) // Optional
+
+	mu      sync.Mutex
+	metrics QuorumMetrics
+}
+
+// Read evaluates function on every peer and returns the answer of the quorum
+func (q *QuorumReader) Read(function string, args ...string) ([]byte, error) {
+	answers := make(map[string][]byte, len(q.Peers))
+	errs := make(map[string]string)
+	votes := make(map[string]int)
+	for _, peer := range q.Peers {
+		answer, err := q.Evaluate(peer, function, args...)
+		if err != nil {
+			errs[peer] = err.Error()
+			continue
+		}
+		answers[peer] = answer
+		votes[string(answer)]++
+	}
+
+	var answer []byte
+	found := false
+	for value, count := range votes {
+		if count >= q.quorum() {
+			answer, found = []byte(value), true
+		}
+	}
+	divergence := Divergence{Function: function, Args: args, Answer: answer, Answers: make(map[string][]byte), Errors: errs}
+	for peer, value := range answers {
+		if !found || string(value) != string(answer) {
+			divergence.Answers[peer] = value
+		}
+	}
+	q.record(divergence, found)
+
+	if len(divergence.Answers) > 0 || len(divergence.Errors) > 0 {
+		if q.OnDivergence != nil {
+			q.OnDivergence(divergence)
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: %d of %d peers required for %s", ErrNoQuorum, q.quorum(), len(q.Peers), function)
+	}
+	return answer, nil
+}
+
+// Metrics returns a copy of the read counters
+func (q *QuorumReader) Metrics() QuorumMetrics {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	metrics := q.metrics
+	metrics.PeerDivergences = make(map[string]int, len(q.metrics.PeerDivergences))
+	for peer, count := range q.metrics.PeerDivergences {
+		metrics.PeerDivergences[peer] = count
+	}
+	return metrics
+}
+
+// quorum returns the number of matching answers required
+func (q *QuorumReader) quorum() int {
+	if q.Quorum > 0 {
+		return q.Quorum
+	}
+	return len(q.Peers)/2 + 1
+}
+
+func (q *QuorumReader) record(divergence Divergence, found bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.metrics.PeerDivergences == nil {
+		q.metrics.PeerDivergences = make(map[string]int)
+	}
+	q.metrics.Reads++
+	if !found {
+		q.metrics.NoQuorum++
+	}
+	peers := divergence.Peers()
+	if len(peers) > 0 {
+		q.metrics.Divergent++
+	}
+	for _, peer := range peers {
+		q.metrics.PeerDivergences[peer]++
+	}
+}
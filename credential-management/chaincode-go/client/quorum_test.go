@@ -0,0 +1,134 @@
+package client_test
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"github.com/pherbke/credential-management/chaincode-go/alerting"
+	"github.com/pherbke/credential-management/chaincode-go/client"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// newPeers runs the same transactions on one simulator per peer, as on peers of one channel
+func newPeers(t *testing.T, names ...string) (map[string]*simulator.Simulator, *simulator.Identity) {
+	identity, err := simulator.NewIdentity("Org1MSP", "verifier")
+	require.NoError(t, err)
+	admin, err := simulator.NewIdentityWithAttributes("Org1MSP", "registry-admin", map[string]string{cuckoofilter.RegistryAdminAttribute: "true"})
+	require.NoError(t, err)
+	peers := make(map[string]*simulator.Simulator)
+	for _, name := range names {
+		sim, err := simulator.New("credential-management", &cuckoofilter.SmartContract{})
+		require.NoError(t, err)
+		_, err = sim.Submit(admin, "Init", "", "100", "4", "0")
+		require.NoError(t, err)
+		_, err = sim.Submit(identity, "BatchInsert", "", `["credential-1","credential-2"]`)
+		require.NoError(t, err)
+		peers[name] = sim
+	}
+	return peers, identity
+}
+
+// corruptFilter rewrites a peer's filter state without credential-1, together with a matching
+// state hash, as a byzantine peer would
+func corruptFilter(t *testing.T, sim *simulator.Simulator) {
+	var filter cuckoofilter.Filter
+	require.NoError(t, json.Unmarshal(sim.GetState("CuckooFilterState"), &filter))
+	require.True(t, filter.Delete([]byte("credential-1")))
+	filterJSON, err := json.Marshal(&filter)
+	require.NoError(t, err)
+	hash := sha256.Sum256(filterJSON)
+	require.NoError(t, sim.SetState("CuckooFilterState", filterJSON))
+	require.NoError(t, sim.SetState("\x00stateHash\x00CuckooFilterState\x00", hash[:]))
+}
+
+func TestQuorumReader_ByzantinePeer(t *testing.T) {
+	peers, identity := newPeers(t, "peer0", "peer1", "peer2")
+	corruptFilter(t, peers["peer2"])
+
+	// The corrupted peer answers on its own without complaint
+	found, err := peers["peer2"].Evaluate(identity, "Lookup", "", "credential-1")
+	require.NoError(t, err)
+	require.Equal(t, "false", string(found))
+
+	var alerts []alerting.Alert
+	dispatcher := &alerting.Dispatcher{Routes: []alerting.Route{{Name: "oncall", Sink: alerting.SinkFunc(func(alert alerting.Alert) error {
+		alerts = append(alerts, alert)
+		return nil
+	})}}}
+	var divergences []client.Divergence
+	reader := &client.QuorumReader{
+		Peers: []string{"peer0", "peer1", "peer2"},
+		Evaluate: func(peer string, function string, args ...string) ([]byte, error) {
+			return peers[peer].Evaluate(identity, function, args...)
+		},
+		OnDivergence: func(divergence client.Divergence) {
+			divergences = append(divergences, divergence)
+			_, err := dispatcher.Dispatch(*alerting.PeerDivergenceAlert(divergence.Function, divergence.Peers()))
+			require.NoError(t, err)
+		},
+	}
+
+	// The majority answer wins and the corrupted peer is reported
+	found, err = reader.Read("Lookup", "", "credential-1")
+	require.NoError(t, err)
+	require.Equal(t, "true", string(found))
+	require.Len(t, divergences, 1)
+	require.Equal(t, []string{"peer2"}, divergences[0].Peers())
+	require.Equal(t, "false", string(divergences[0].Answers["peer2"]))
+	require.Len(t, alerts, 1)
+	require.Equal(t, alerting.KindPeerDivergence, alerts[0].Kind)
+	require.Equal(t, "peer2", alerts[0].Details["peers"])
+
+	// Reads the corruption does not affect agree
+	found, err = reader.Read("Lookup", "", "credential-2")
+	require.NoError(t, err)
+	require.Equal(t, "true", string(found))
+	require.Len(t, divergences, 1)
+
+	require.Equal(t, client.QuorumMetrics{
+		Reads:           2,
+		Divergent:       1,
+		PeerDivergences: map[string]int{"peer2": 1},
+	}, reader.Metrics())
+}
+
+func TestQuorumReader_TamperedPeerFails(t *testing.T) {
+	peers, identity := newPeers(t, "peer0", "peer1", "peer2")
+	// Without a matching state hash the tampered peer rejects its own state
+	require.NoError(t, peers["peer1"].SetState("CuckooFilterState", []byte(`{"Count":0}`)))
+
+	var divergences []client.Divergence
+	reader := &client.QuorumReader{
+		Peers: []string{"peer0", "peer1", "peer2"},
+		Evaluate: func(peer string, function string, args ...string) ([]byte, error) {
+			return peers[peer].Evaluate(identity, function, args...)
+		},
+		OnDivergence: func(divergence client.Divergence) { divergences = append(divergences, divergence) },
+	}
+	found, err := reader.Read("Lookup", "", "credential-1")
+	require.NoError(t, err)
+	require.Equal(t, "true", string(found))
+	require.Len(t, divergences, 1)
+	require.Contains(t, divergences[0].Errors["peer1"], cuckoofilter.ErrCorruptState.Error())
+}
+
+func TestQuorumReader_NoQuorum(t *testing.T) {
+	answers := map[string]string{"peer0": "true", "peer1": "false"}
+	reader := &client.QuorumReader{
+		Peers: []string{"peer0", "peer1", "peer2"},
+		Evaluate: func(peer string, function string, args ...string) ([]byte, error) {
+			if answer, ok := answers[peer]; ok {
+				return []byte(answer), nil
+			}
+			return nil, errors.New("peer unavailable")
+		},
+	}
+	_, err := reader.Read("Lookup", "", "credential-1")
+	require.ErrorIs(t, err, client.ErrNoQuorum)
+	metrics := reader.Metrics()
+	require.Equal(t, 1, metrics.NoQuorum)
+	require.Equal(t, map[string]int{"peer0": 1, "peer1": 1, "peer2": 1}, metrics.PeerDivergences)
+}
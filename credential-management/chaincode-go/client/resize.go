@@ -0,0 +1,208 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pherbke/credential-management/chaincode-go/alerting"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+)
+
+// ErrResizeVerification is returned when the registry filter does not verify after a resize. The
+// registry is left frozen, so no revocation is written on top of a state that may have lost some.
+var ErrResizeVerification = errors.New("resized filter failed verification")
+
+// MaintenanceWindow is a daily window of low traffic, in UTC
+type MaintenanceWindow struct {
+	Start    time.Duration // Since midnight
+	Duration time.Duration // Zero for the whole day
+}
+
+// Contains reports whether t falls into the window
+func (w MaintenanceWindow) Contains(t time.Time) bool {
+	if w.Duration <= 0 || w.Duration >= 24*time.Hour {
+		return true
+	}
+	t = t.UTC()
+	sinceMidnight := t.Sub(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC))
+	return ((sinceMidnight-w.Start)%(24*time.Hour)+24*time.Hour)%(24*time.Hour) < w.Duration
+}
+
+// Next returns t if it falls into the window and the start of the next window otherwise
+func (w MaintenanceWindow) Next(t time.Time) time.Time {
+	if w.Contains(t) {
+		return t
+	}
+	t = t.UTC()
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Add(w.Start % (24 * time.Hour))
+	if start.Before(t) {
+		start = start.Add(24 * time.Hour)
+	}
+	return start
+}
+
+// ResizePlan is a resize of the registry filter the keeper decided on
+type ResizePlan struct {
+	LoadFactor    float64
+	Buckets       uint
+	TargetBuckets uint
+	Window        time.Time // When the resize runs
+}
+
+// ResizeReport is the outcome of a ResizeKeeper step that found the filter above the threshold
+type ResizeReport struct {
+	Plan      ResizePlan
+	Resized   bool
+	Postponed string                        // Why a due resize did not run, e.g. a freeze by someone else
+	Before    *cuckoofilter.FilterStats     // Stats the plan was made from
+	After     *cuckoofilter.FilterStats     // Stats after the resize
+	StateHash *cuckoofilter.FilterStateHash // Hash check after the resize
+}
+
+// ResizeKeeper resizes the registry filter when its load factor crosses Threshold. The resize runs in
+// the next maintenance window: the keeper freezes the registry, resizes the filter, checks that it
+// still holds every fingerprint and matches its state hash, and unfreezes the registry. A filter that
+// fails verification stays frozen for an operator to look at. The owner calls Step periodically,
+// e.g. every few minutes.
+type ResizeKeeper struct {
+	Stats      func() (*cuckoofilter.FilterStats, error)     // Evaluates GetFilterStats of the default filter
+	Freeze     func() (*cuckoofilter.RegistryFreeze, error)  // Evaluates GetRegistryFreeze
+	StateHash  func() (*cuckoofilter.FilterStateHash, error) // Evaluates GetFilterStateHash
+	Submit     SubmitFunc                                    // Submits FreezeRegistry, ResizeFilter and UnfreezeRegistry as a registry admin
+	Threshold  float64                                       // Defaults to alerting.DefaultFilterLoadThreshold
+	TargetLoad float64                                       // Load factor to resize to, defaults to half the threshold
+	Window     MaintenanceWindow
+	Alerts     *alerting.Dispatcher // Optional
+	Clock      clock.Clock          // Optional, defaults to clock.System
+}
+
+// Step checks the load factor of the filter and, when it crossed the threshold, plans the resize and
+// runs it if the maintenance window is open. It returns nil if no resize is due.
+func (k *ResizeKeeper) Step() (*ResizeReport, error) {
+	now := clock.Or(k.Clock).Now()
+	stats, err := k.Stats()
+	if err != nil {
+		return nil, fmt.Errorf("error loading filter stats: %v", err)
+	}
+	if stats.LoadFactor < k.threshold() {
+		return nil, nil
+	}
+	report := &ResizeReport{Plan: k.plan(stats, k.Window.Next(now)), Before: stats}
+	if !k.Window.Contains(now) {
+		return report, k.alert(alerting.ResizePlannedAlert(report.Plan.Buckets, report.Plan.TargetBuckets, stats.LoadFactor, report.Plan.Window), now)
+	}
+	if stats.FingerprintSize != cuckoofilter.FingerPrintSize {
+		return report, k.fail(report, false, fmt.Errorf("cannot resize a filter with fingerprints of %d bytes", stats.FingerprintSize), now)
+	}
+
+	freeze, err := k.Freeze()
+	if err != nil {
+		return report, fmt.Errorf("error loading registry freeze: %v", err)
+	}
+	if freeze.Frozen {
+		report.Postponed = fmt.Sprintf("registry is frozen (%s)", freeze.Reason)
+		return report, nil
+	}
+	if hash, err := k.StateHash(); err != nil {
+		return report, fmt.Errorf("error checking filter state hash: %v", err)
+	} else if !hash.Match && hash.Stored != "" {
+		return report, k.fail(report, false, fmt.Errorf("%w before the resize: filter state does not match its stored hash", ErrResizeVerification), now)
+	}
+
+	reason := fmt.Sprintf("resizing filter to %d buckets", report.Plan.TargetBuckets)
+	if _, _, err := k.Submit("FreezeRegistry", reason); err != nil {
+		return report, k.fail(report, false, fmt.Errorf("error freezing registry: %v", err), now)
+	}
+	// Writes may have landed between the first look and the freeze, so plan again from the frozen filter
+	if report.Before, err = k.Stats(); err != nil {
+		return report, k.unfreezeAfter(report, fmt.Errorf("error loading filter stats: %v", err), now)
+	}
+	report.Plan = k.plan(report.Before, now)
+	if _, _, err := k.Submit("ResizeFilter", strconv.FormatUint(uint64(report.Plan.TargetBuckets), 10)); err != nil {
+		return report, k.unfreezeAfter(report, fmt.Errorf("error resizing filter: %v", err), now)
+	}
+
+	if err := k.verify(report); err != nil {
+		return report, k.fail(report, true, err, now)
+	}
+	report.Resized = true
+	if _, _, err := k.Submit("UnfreezeRegistry"); err != nil {
+		return report, k.fail(report, true, fmt.Errorf("error unfreezing registry: %v", err), now)
+	}
+	return report, k.alert(alerting.ResizeCompletedAlert(report.Plan.Buckets, report.Plan.TargetBuckets, report.After.LoadFactor), now)
+}
+
+// verify checks the resized filter against the stats before the resize and its state hash
+func (k *ResizeKeeper) verify(report *ResizeReport) error {
+	var err error
+	if report.After, err = k.Stats(); err != nil {
+		return fmt.Errorf("%w: error loading filter stats: %v", ErrResizeVerification, err)
+	}
+	before, after := report.Before, report.After
+	if after.Buckets != report.Plan.TargetBuckets {
+		return fmt.Errorf("%w: filter has %d buckets instead of %d", ErrResizeVerification, after.Buckets, report.Plan.TargetBuckets)
+	}
+	if after.Stored != before.Stored || after.Count != before.Count {
+		return fmt.Errorf("%w: filter holds %d fingerprints (count %d) instead of %d (count %d)", ErrResizeVerification, after.Stored, after.Count, before.Stored, before.Count)
+	}
+	if report.StateHash, err = k.StateHash(); err != nil {
+		return fmt.Errorf("%w: error checking filter state hash: %v", ErrResizeVerification, err)
+	}
+	if !report.StateHash.Match {
+		return fmt.Errorf("%w: filter state does not match its stored hash", ErrResizeVerification)
+	}
+	return nil
+}
+
+// plan picks the smallest power of two of at least twice the buckets that brings the load factor
+// down to the target
+func (k *ResizeKeeper) plan(stats *cuckoofilter.FilterStats, window time.Time) ResizePlan {
+	target := k.TargetLoad
+	if target <= 0 || target >= k.threshold() {
+		target = k.threshold() / 2
+	}
+	bucketSize := stats.BucketSize
+	if bucketSize == 0 {
+		bucketSize = cuckoofilter.DefaultBucketSize
+	}
+	buckets := uint(1)
+	for buckets <= stats.Buckets || float64(stats.Stored) > float64(buckets*bucketSize)*target {
+		buckets *= 2
+	}
+	return ResizePlan{LoadFactor: stats.LoadFactor, Buckets: stats.Buckets, TargetBuckets: buckets, Window: window}
+}
+
+func (k *ResizeKeeper) threshold() float64 {
+	if k.Threshold <= 0 {
+		return alerting.DefaultFilterLoadThreshold
+	}
+	return k.Threshold
+}
+
+// unfreezeAfter unfreezes the registry after a step failed without changing the filter
+func (k *ResizeKeeper) unfreezeAfter(report *ResizeReport, err error, now time.Time) error {
+	if _, _, unfreezeErr := k.Submit("UnfreezeRegistry"); unfreezeErr != nil {
+		return k.fail(report, true, fmt.Errorf("%v, error unfreezing registry: %v", err, unfreezeErr), now)
+	}
+	return k.fail(report, false, err, now)
+}
+
+// fail raises the failure alert and returns err
+func (k *ResizeKeeper) fail(report *ResizeReport, frozen bool, err error, now time.Time) error {
+	k.alert(alerting.ResizeFailedAlert(report.Plan.TargetBuckets, frozen, err), now)
+	return err
+}
+
+func (k *ResizeKeeper) alert(alert *alerting.Alert, now time.Time) error {
+	if k.Alerts == nil {
+		return nil
+	}
+	alert.Time = now
+	if _, err := k.Alerts.Dispatch(*alert); err != nil {
+		return fmt.Errorf("error dispatching %s alert: %v", alert.Kind, err)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package client_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/pherbke/credential-management/chaincode-go/alerting"
+	"github.com/pherbke/credential-management/chaincode-go/client"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// newResizeKeeper returns a keeper on a registry of 16 buckets holding 40 credentials, with a
+// maintenance window from 02:00 to 04:00 UTC, and the alerts it raises
+func newResizeKeeper(t *testing.T, now *clock.Manual) (*client.ResizeKeeper, *simulator.Simulator, *simulator.Identity, *[]alerting.Alert) {
+	sim, err := simulator.New("credential-management", &cuckoofilter.SmartContract{})
+	require.NoError(t, err)
+	admin, err := simulator.NewIdentityWithAttributes("Org1MSP", "registry-admin", map[string]string{cuckoofilter.RegistryAdminAttribute: "true"})
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "Init", "", "16", "4", "0")
+	require.NoError(t, err)
+	credentials := []string{}
+	for i := 0; i < 40; i++ {
+		credentials = append(credentials, fmt.Sprintf("credential-%d", i))
+	}
+	credentialsJSON, err := json.Marshal(credentials)
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "BatchInsert", "", string(credentialsJSON))
+	require.NoError(t, err)
+
+	evaluate := func(result interface{}, function string, args ...string) error {
+		payload, err := sim.Evaluate(admin, function, args...)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(payload, result)
+	}
+	alerts := &[]alerting.Alert{}
+	keeper := &client.ResizeKeeper{
+		Stats: func() (*cuckoofilter.FilterStats, error) {
+			var stats cuckoofilter.FilterStats
+			return &stats, evaluate(&stats, "GetFilterStats", "")
+		},
+		Freeze: func() (*cuckoofilter.RegistryFreeze, error) {
+			var freeze cuckoofilter.RegistryFreeze
+			return &freeze, evaluate(&freeze, "GetRegistryFreeze")
+		},
+		StateHash: func() (*cuckoofilter.FilterStateHash, error) {
+			var hash cuckoofilter.FilterStateHash
+			return &hash, evaluate(&hash, "GetFilterStateHash")
+		},
+		Submit: func(function string, args ...string) (string, []byte, error) {
+			tx, err := sim.Submit(admin, function, args...)
+			if err != nil {
+				return "", nil, err
+			}
+			return tx.ID, tx.Payload, nil
+		},
+		Threshold: 0.6,
+		Window:    client.MaintenanceWindow{Start: 2 * time.Hour, Duration: 2 * time.Hour},
+		Alerts: &alerting.Dispatcher{Routes: []alerting.Route{{Name: "sink", Sink: alerting.SinkFunc(func(alert alerting.Alert) error {
+			*alerts = append(*alerts, alert)
+			return nil
+		})}}, Clock: now},
+		Clock: now,
+	}
+	return keeper, sim, admin, alerts
+}
+
+func TestMaintenanceWindow(t *testing.T) {
+	window := client.MaintenanceWindow{Start: 23 * time.Hour, Duration: 2 * time.Hour}
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	require.True(t, window.Contains(day.Add(23*time.Hour+30*time.Minute)))
+	require.True(t, window.Contains(day.Add(30*time.Minute)))
+	require.False(t, window.Contains(day.Add(time.Hour)))
+	require.False(t, window.Contains(day.Add(12*time.Hour)))
+	require.Equal(t, day.Add(23*time.Hour), window.Next(day.Add(12*time.Hour)))
+	require.Equal(t, day.Add(30*time.Minute), window.Next(day.Add(30*time.Minute)))
+	require.True(t, client.MaintenanceWindow{}.Contains(day.Add(12*time.Hour)))
+}
+
+func TestResizeKeeper_ResizesInWindow(t *testing.T) {
+	now := clock.NewManual(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	keeper, sim, admin, alerts := newResizeKeeper(t, now)
+
+	// Outside the window the resize is only planned
+	report, err := keeper.Step()
+	require.NoError(t, err)
+	require.False(t, report.Resized)
+	require.Equal(t, uint(16), report.Plan.Buckets)
+	require.Equal(t, uint(64), report.Plan.TargetBuckets)
+	require.Equal(t, time.Date(2024, 3, 2, 2, 0, 0, 0, time.UTC), report.Plan.Window)
+	require.Len(t, *alerts, 1)
+	require.Equal(t, alerting.KindResizePlanned, (*alerts)[0].Kind)
+
+	now.Set(time.Date(2024, 3, 2, 2, 30, 0, 0, time.UTC))
+	report, err = keeper.Step()
+	require.NoError(t, err)
+	require.True(t, report.Resized)
+	require.Equal(t, uint(64), report.After.Buckets)
+	require.Equal(t, report.Before.Stored, report.After.Stored)
+	require.True(t, report.StateHash.Match)
+	require.Equal(t, alerting.KindResizeCompleted, (*alerts)[1].Kind)
+
+	// The registry accepts writes again and kept every revocation
+	_, err = sim.Submit(admin, "Insert", "", "credential-40")
+	require.NoError(t, err)
+	for i := 0; i <= 40; i++ {
+		found, err := sim.Evaluate(admin, "Lookup", "", fmt.Sprintf("credential-%d", i))
+		require.NoError(t, err)
+		require.Equal(t, "true", string(found))
+	}
+
+	// Below the threshold there is nothing to do
+	report, err = keeper.Step()
+	require.NoError(t, err)
+	require.Nil(t, report)
+}
+
+func TestResizeKeeper_PostponesWhileFrozen(t *testing.T) {
+	now := clock.NewManual(time.Date(2024, 3, 2, 3, 0, 0, 0, time.UTC))
+	keeper, sim, admin, _ := newResizeKeeper(t, now)
+	_, err := sim.Submit(admin, "FreezeRegistry", "key rotation")
+	require.NoError(t, err)
+
+	// A freeze by someone else is left alone
+	report, err := keeper.Step()
+	require.NoError(t, err)
+	require.False(t, report.Resized)
+	require.Contains(t, report.Postponed, "key rotation")
+	var freeze cuckoofilter.RegistryFreeze
+	payload, err := sim.Evaluate(admin, "GetRegistryFreeze")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(payload, &freeze))
+	require.Equal(t, "key rotation", freeze.Reason)
+}
+
+func TestResizeKeeper_UnfreezesAfterFailedResize(t *testing.T) {
+	now := clock.NewManual(time.Date(2024, 3, 2, 3, 0, 0, 0, time.UTC))
+	keeper, sim, admin, alerts := newResizeKeeper(t, now)
+	submit := keeper.Submit
+	keeper.Submit = func(function string, args ...string) (string, []byte, error) {
+		if function == "ResizeFilter" {
+			return "", nil, errors.New("endorsement timeout")
+		}
+		return submit(function, args...)
+	}
+
+	_, err := keeper.Step()
+	require.ErrorContains(t, err, "endorsement timeout")
+	require.Equal(t, alerting.KindResizeFailed, (*alerts)[0].Kind)
+	require.NotContains(t, (*alerts)[0].Summary, "stays frozen")
+	_, err = sim.Submit(admin, "Insert", "", "credential-40")
+	require.NoError(t, err)
+}
+
+func TestResizeKeeper_StaysFrozenOnFailedVerification(t *testing.T) {
+	now := clock.NewManual(time.Date(2024, 3, 2, 3, 0, 0, 0, time.UTC))
+	keeper, sim, admin, alerts := newResizeKeeper(t, now)
+	submit := keeper.Submit
+	keeper.Submit = func(function string, args ...string) (string, []byte, error) {
+		txID, payload, err := submit(function, args...)
+		if function == "ResizeFilter" {
+			// A torn write of the resized state
+			filterJSON := sim.GetState("CuckooFilterState")
+			require.NoError(t, sim.SetState("CuckooFilterState", filterJSON[:len(filterJSON)-1]))
+		}
+		return txID, payload, err
+	}
+
+	report, err := keeper.Step()
+	require.ErrorIs(t, err, client.ErrResizeVerification)
+	require.False(t, report.Resized)
+	require.Contains(t, (*alerts)[0].Summary, "stays frozen")
+	_, err = sim.Submit(admin, "Insert", "", "credential-40")
+	require.ErrorContains(t, err, cuckoofilter.RegistryFrozenErrorCode)
+}
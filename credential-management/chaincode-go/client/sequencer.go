@@ -0,0 +1,301 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Priority classes of a Sequencer
+const (
+	PriorityInteractive = "interactive" // Single revocations a user waits for
+	PriorityBulk        = "bulk"        // Batch imports and other background revocations
+)
+
+// DefaultSequencerBatchSize is the number of items a Sequencer puts into one transaction by default
+const DefaultSequencerBatchSize = 100
+
+// Errors returned by Sequencer.Enqueue
+var (
+	ErrUnknownPriority = errors.New("unknown priority class")
+	ErrLoadShed        = errors.New("sequencer queue is full, request shed")
+)
+
+// DefaultSequencerClasses are the classes of a Sequencer without configured classes. Interactive
+// revocations get four times the share of a transaction that bulk revocations get.
+var DefaultSequencerClasses = map[string]SequencerClass{
+	PriorityInteractive: {Weight: 4, MaxQueued: 10000},
+	PriorityBulk:        {Weight: 1, MaxQueued: 1000000},
+}
+
+// SequencerClass configures a priority class of a Sequencer
+type SequencerClass struct {
+	Weight    int // Share of every transaction reserved for the class while it has queued items
+	MaxQueued int // Queued items above which requests of the class are shed, 0 means unlimited
+}
+
+// ClassMetrics holds the counters of one priority class of a Sequencer. Latency is measured from
+// Enqueue to the submission of the transaction carrying the last item of a request.
+type ClassMetrics struct {
+	Class             string `json:"class"`
+	Queued            int    `json:"queued"`    // Items waiting to be submitted
+	Enqueued          uint64 `json:"enqueued"`  // Items accepted by Enqueue
+	Shed              uint64 `json:"shed"`      // Items refused because the queue was full
+	Submitted         uint64 `json:"submitted"` // Items submitted in successful transactions
+	Failed            uint64 `json:"failed"`    // Items of failed transactions
+	Completed         uint64 `json:"completed"` // Requests whose items were all submitted
+	MeanLatencyMicros int64  `json:"meanLatencyMicros"`
+	MaxLatencyMicros  int64  `json:"maxLatencyMicros"`
+}
+
+// Sequencer coalesces revocations of several priority classes into BatchInsert transactions. Every class
+// has its own queue; each transaction is shared between the classes with queued items by their weights,
+// and capacity a class does not use goes to the others, so interactive revocations are not stuck behind
+// a large import. When a queue is full, further requests of its class are shed with ErrLoadShed.
+//
+// A failed transaction fails every request with items in it; requests are not retried, use an Outbox
+// for revocations that must survive failures.
+type Sequencer struct {
+	FilterID  string
+	BatchSize int                       // Items per transaction, defaults to DefaultSequencerBatchSize
+	Classes   map[string]SequencerClass // Defaults to DefaultSequencerClasses
+	Clock     clock.Clock               // Optional, defaults to clock.System
+
+	mu      sync.Mutex
+	queues  map[string][]*sequencedRequest
+	metrics map[string]*classMetrics
+}
+
+type sequencedRequest struct {
+	items      []string
+	next       int // Index of the first item not yet taken into a transaction
+	pending    int // Items taken into transactions not yet submitted
+	enqueuedAt time.Time
+	failed     bool
+	done       chan error
+}
+
+type classMetrics struct {
+	ClassMetrics
+	latencyTotal time.Duration
+}
+
+// sequencedPart is the slice of a request taken into a transaction
+type sequencedPart struct {
+	class   string
+	request *sequencedRequest
+	items   int
+}
+
+// Enqueue queues the items of one request in a priority class. The returned channel receives nil once
+// every item was submitted, or the error of the first failed transaction.
+func (s *Sequencer) Enqueue(class string, items ...string) (<-chan error, error) {
+	config, ok := s.classes()[class]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPriority, class)
+	}
+	if len(items) == 0 {
+		return nil, errors.New("no items to enqueue")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	metrics := s.classMetrics(class)
+	if config.MaxQueued > 0 && metrics.Queued+len(items) > config.MaxQueued {
+		metrics.Shed += uint64(len(items))
+		return nil, fmt.Errorf("%w: %s queue holds %d items", ErrLoadShed, class, metrics.Queued)
+	}
+	if s.queues == nil {
+		s.queues = make(map[string][]*sequencedRequest)
+	}
+	request := &sequencedRequest{
+		items:      items,
+		enqueuedAt: clock.Or(s.Clock).Now(),
+		done:       make(chan error, 1),
+	}
+	s.queues[class] = append(s.queues[class], request)
+	metrics.Queued += len(items)
+	metrics.Enqueued += uint64(len(items))
+	return request.done, nil
+}
+
+// Dispatch submits transactions until the queues are empty and returns the number of transactions.
+// Items enqueued while it runs are scheduled into the next transaction. Run a single dispatcher per
+// sequencer.
+func (s *Sequencer) Dispatch(submit SubmitFunc) int {
+	transactions := 0
+	for {
+		items, parts := s.nextBatch()
+		if len(items) == 0 {
+			return transactions
+		}
+		itemsJSON, err := json.Marshal(items)
+		if err == nil {
+			_, _, err = submit("BatchInsert", s.FilterID, string(itemsJSON))
+		}
+		s.finish(parts, err)
+		transactions++
+	}
+}
+
+// Run dispatches the queued items every interval until stop is closed
+func (s *Sequencer) Run(submit SubmitFunc, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.Dispatch(submit)
+		}
+	}
+}
+
+// Metrics returns the counters of every priority class, ordered by class name
+func (s *Sequencer) Metrics() []ClassMetrics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var metrics []ClassMetrics
+	for class := range s.classes() {
+		metrics = append(metrics, s.classMetrics(class).ClassMetrics)
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Class < metrics[j].Class })
+	return metrics
+}
+
+// nextBatch takes the items of the next transaction from the queues. Every class with queued items
+// gets its weighted share of the batch first, then the remaining capacity goes to the classes by
+// descending weight.
+func (s *Sequencer) nextBatch() ([]string, []sequencedPart) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	size := s.BatchSize
+	if size <= 0 {
+		size = DefaultSequencerBatchSize
+	}
+	classes := s.classes()
+	var waiting []string
+	totalWeight := 0
+	for class, config := range classes {
+		if s.classMetrics(class).Queued > 0 {
+			waiting = append(waiting, class)
+			totalWeight += config.Weight
+		}
+	}
+	sort.Slice(waiting, func(i, j int) bool {
+		if classes[waiting[i]].Weight != classes[waiting[j]].Weight {
+			return classes[waiting[i]].Weight > classes[waiting[j]].Weight
+		}
+		return waiting[i] < waiting[j]
+	})
+
+	var items []string
+	var parts []sequencedPart
+	for _, class := range waiting {
+		share := size / len(waiting)
+		if totalWeight > 0 {
+			share = size * classes[class].Weight / totalWeight
+		}
+		if share < 1 {
+			share = 1
+		}
+		items, parts = s.take(class, share, items, parts, size)
+	}
+	for _, class := range waiting {
+		items, parts = s.take(class, size-len(items), items, parts, size)
+	}
+	return items, parts
+}
+
+// take moves up to limit items of a class into the batch without exceeding size
+func (s *Sequencer) take(class string, limit int, items []string, parts []sequencedPart, size int) ([]string, []sequencedPart) {
+	metrics := s.classMetrics(class)
+	for limit > 0 && len(items) < size && len(s.queues[class]) > 0 {
+		request := s.queues[class][0]
+		count := len(request.items) - request.next
+		if count > limit {
+			count = limit
+		}
+		if count > size-len(items) {
+			count = size - len(items)
+		}
+		items = append(items, request.items[request.next:request.next+count]...)
+		parts = append(parts, sequencedPart{class: class, request: request, items: count})
+		request.next += count
+		request.pending += count
+		metrics.Queued -= count
+		limit -= count
+		if request.next == len(request.items) {
+			s.queues[class] = s.queues[class][1:]
+		}
+	}
+	return items, parts
+}
+
+// finish records the outcome of a transaction and completes the requests it finished or failed
+func (s *Sequencer) finish(parts []sequencedPart, submitErr error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := clock.Or(s.Clock).Now()
+	for _, part := range parts {
+		metrics := s.classMetrics(part.class)
+		request := part.request
+		request.pending -= part.items
+		if submitErr != nil {
+			metrics.Failed += uint64(part.items)
+			if !request.failed {
+				request.failed = true
+				request.done <- fmt.Errorf("error submitting revocations: %v", submitErr)
+				s.drop(part.class, request)
+			}
+			continue
+		}
+		metrics.Submitted += uint64(part.items)
+		if !request.failed && request.next == len(request.items) && request.pending == 0 {
+			latency := now.Sub(request.enqueuedAt)
+			metrics.Completed++
+			metrics.latencyTotal += latency
+			metrics.MeanLatencyMicros = (metrics.latencyTotal / time.Duration(metrics.Completed)).Microseconds()
+			if latency.Microseconds() > metrics.MaxLatencyMicros {
+				metrics.MaxLatencyMicros = latency.Microseconds()
+			}
+			request.done <- nil
+		}
+	}
+}
+
+// drop removes the items of a failed request that were not yet taken into a transaction
+func (s *Sequencer) drop(class string, failed *sequencedRequest) {
+	queue := s.queues[class]
+	for i, request := range queue {
+		if request == failed {
+			s.classMetrics(class).Queued -= len(request.items) - request.next
+			request.next = len(request.items)
+			s.queues[class] = append(queue[:i:i], queue[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *Sequencer) classes() map[string]SequencerClass {
+	if len(s.Classes) > 0 {
+		return s.Classes
+	}
+	return DefaultSequencerClasses
+}
+
+func (s *Sequencer) classMetrics(class string) *classMetrics {
+	if s.metrics == nil {
+		s.metrics = make(map[string]*classMetrics)
+	}
+	metrics, ok := s.metrics[class]
+	if !ok {
+		metrics = &classMetrics{ClassMetrics: ClassMetrics{Class: class}}
+		s.metrics[class] = metrics
+	}
+	return metrics
+}
@@ -0,0 +1,113 @@
+package client_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/pherbke/credential-management/chaincode-go/client"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// recordingSubmit returns a submit function recording the items of every BatchInsert
+func recordingSubmit(t *testing.T, batches *[][]string) client.SubmitFunc {
+	return func(function string, args ...string) (string, []byte, error) {
+		require.Equal(t, "BatchInsert", function)
+		var items []string
+		require.NoError(t, json.Unmarshal([]byte(args[1]), &items))
+		*batches = append(*batches, items)
+		return fmt.Sprintf("tx%d", len(*batches)), nil, nil
+	}
+}
+
+func items(prefix string, count int) []string {
+	var items []string
+	for i := 0; i < count; i++ {
+		items = append(items, fmt.Sprintf("%s-%d", prefix, i))
+	}
+	return items
+}
+
+func TestSequencer_WeightedScheduling(t *testing.T) {
+	sequencer := &client.Sequencer{BatchSize: 10}
+	bulk, err := sequencer.Enqueue(client.PriorityBulk, items("bulk", 25)...)
+	require.NoError(t, err)
+	interactive, err := sequencer.Enqueue(client.PriorityInteractive, items("interactive", 12)...)
+	require.NoError(t, err)
+
+	var batches [][]string
+	require.Equal(t, 4, sequencer.Dispatch(recordingSubmit(t, &batches)))
+	// Interactive items take 8 of 10 slots while bulk items are queued, bulk keeps its share
+	require.Equal(t, append(items("interactive", 12)[:8], items("bulk", 25)[:2]...), batches[0])
+	require.Equal(t, append(items("interactive", 12)[8:], items("bulk", 25)[2:8]...), batches[1])
+	// Without interactive items bulk items fill the transactions
+	require.Equal(t, items("bulk", 25)[8:18], batches[2])
+	require.Len(t, batches[3], 7)
+	require.NoError(t, <-interactive)
+	require.NoError(t, <-bulk)
+
+	metrics := sequencer.Metrics()
+	require.Equal(t, client.PriorityBulk, metrics[0].Class)
+	require.Equal(t, uint64(25), metrics[0].Submitted)
+	require.Equal(t, uint64(12), metrics[1].Submitted)
+	require.Equal(t, 0, metrics[1].Queued)
+}
+
+func TestSequencer_LoadShedding(t *testing.T) {
+	sequencer := &client.Sequencer{Classes: map[string]client.SequencerClass{
+		client.PriorityInteractive: {Weight: 1, MaxQueued: 2},
+	}}
+	_, err := sequencer.Enqueue(client.PriorityInteractive, "credential-1", "credential-2")
+	require.NoError(t, err)
+	_, err = sequencer.Enqueue(client.PriorityInteractive, "credential-3")
+	require.ErrorIs(t, err, client.ErrLoadShed)
+	_, err = sequencer.Enqueue(client.PriorityBulk, "credential-3")
+	require.ErrorIs(t, err, client.ErrUnknownPriority)
+
+	require.Equal(t, uint64(1), sequencer.Metrics()[0].Shed)
+	var batches [][]string
+	sequencer.Dispatch(recordingSubmit(t, &batches))
+	// Dispatched items free the queue
+	_, err = sequencer.Enqueue(client.PriorityInteractive, "credential-3")
+	require.NoError(t, err)
+}
+
+func TestSequencer_FailedTransactionFailsItsRequests(t *testing.T) {
+	sequencer := &client.Sequencer{BatchSize: 4}
+	large, err := sequencer.Enqueue(client.PriorityBulk, items("bulk", 10)...)
+	require.NoError(t, err)
+	small, err := sequencer.Enqueue(client.PriorityInteractive, "credential-1")
+	require.NoError(t, err)
+
+	failing := func(function string, args ...string) (string, []byte, error) {
+		return "", nil, errors.New("endorsement failed")
+	}
+	require.Equal(t, 1, sequencer.Dispatch(failing))
+	require.ErrorContains(t, <-small, "endorsement failed")
+	// The rest of a failed request is dropped
+	require.ErrorContains(t, <-large, "endorsement failed")
+	metrics := sequencer.Metrics()
+	require.Equal(t, 0, metrics[0].Queued)
+	require.Equal(t, uint64(3), metrics[0].Failed)
+}
+
+func TestSequencer_LatencyMetrics(t *testing.T) {
+	now := clock.NewManual(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	sequencer := &client.Sequencer{BatchSize: 2, Clock: now}
+	_, err := sequencer.Enqueue(client.PriorityInteractive, "credential-1")
+	require.NoError(t, err)
+	_, err = sequencer.Enqueue(client.PriorityBulk, items("bulk", 3)...)
+	require.NoError(t, err)
+
+	submit := func(function string, args ...string) (string, []byte, error) {
+		now.Advance(time.Second)
+		return "tx", nil, nil
+	}
+	require.Equal(t, 2, sequencer.Dispatch(submit))
+	metrics := sequencer.Metrics()
+	require.Equal(t, uint64(1), metrics[0].Completed)
+	require.Equal(t, (2 * time.Second).Microseconds(), metrics[0].MaxLatencyMicros)
+	require.Equal(t, time.Second.Microseconds(), metrics[1].MeanLatencyMicros)
+}
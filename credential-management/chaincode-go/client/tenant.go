@@ -0,0 +1,360 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIKeyHeader is the request header carrying a tenant's API key
+const APIKeyHeader = "X-API-Key"
+
+// Errors returned when a request cannot be served for a tenant
+var (
+	ErrUnknownTenant     = errors.New("request does not belong to a known tenant")
+	ErrTenantRateLimited = errors.New("tenant rate limit exceeded")
+	ErrReadOnlyAPIKey    = errors.New("API key is read-only")
+	ErrOutOfScope        = errors.New("API key is not allowed to look up these fingerprints")
+)
+
+// Tenant is one consortium member served by a shared gateway deployment. Each tenant submits with its
+// own Fabric identity to its own channel and chaincode.
+type Tenant struct {
+	ID               string        `json:"id" yaml:"id"`
+	APIKeyHashes     []string      `json:"apiKeyHashes" yaml:"apiKeyHashes"`         // Hex SHA-256 of the tenant's API keys, see HashCredential
+	ScopedAPIKeys    []APIKeyScope `json:"scopedApiKeys" yaml:"scopedApiKeys"`       // Read-only API keys restricted to lookups within a scope
+	ClientCertHashes []string      `json:"clientCertHashes" yaml:"clientCertHashes"` // Hex SHA-256 of the DER client certificates used for mTLS
+	MSPID            string        `json:"mspId" yaml:"mspId"`
+	CertFile         string        `json:"certFile" yaml:"certFile"` // Fabric identity the gateway uses for the tenant
+	KeyFile          string        `json:"keyFile" yaml:"keyFile"`
+	Channel          string        `json:"channel" yaml:"channel"`
+	Chaincode        string        `json:"chaincode" yaml:"chaincode"`
+	Rate             float64       `json:"rate" yaml:"rate"` // Sustained requests per second, 0 disables rate limiting
+	Burst            int           `json:"burst" yaml:"burst"`
+	Webhooks         []string      `json:"webhooks" yaml:"webhooks"` // URLs receiving the chaincode events of the tenant's channel and chaincode
+}
+
+// APIKeyScope restricts an API key to lookups, e.g. for a relying party integrated for one issuer, so it
+// cannot probe the revocations of other issuers. A lookup is in scope if its issuer namespace is listed
+// and every fingerprint starts with one of the prefixes; an empty list does not restrict.
+type APIKeyScope struct {
+	KeyHash             string   `json:"keyHash" yaml:"keyHash"`                         // Hex SHA-256 of the API key, see HashCredential
+	Namespaces          []string `json:"namespaces" yaml:"namespaces"`                   // Issuer namespaces, see LookupInNamespace
+	FingerprintPrefixes []string `json:"fingerprintPrefixes" yaml:"fingerprintPrefixes"` // Hex prefixes of the fingerprints
+}
+
+// Allows reports whether a lookup of the fingerprints in the namespace, empty for the default filter,
+// is in scope
+func (s *APIKeyScope) Allows(namespace string, fingerprints []string) bool {
+	if len(s.Namespaces) > 0 && !containsString(s.Namespaces, namespace) {
+		return false
+	}
+	if len(s.FingerprintPrefixes) == 0 {
+		return true
+	}
+	for _, fingerprint := range fingerprints {
+		allowed := false
+		for _, prefix := range s.FingerprintPrefixes {
+			if strings.HasPrefix(strings.ToLower(fingerprint), strings.ToLower(prefix)) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// MetricsLabels returns the labels that separate the tenant's metrics from other tenants
+func (t *Tenant) MetricsLabels() map[string]string {
+	return map[string]string{"tenant": t.ID, "channel": t.Channel, "chaincode": t.Chaincode}
+}
+
+// HashCredential returns the hex SHA-256 of an API key or DER certificate, as stored in a Tenant
+func HashCredential(credential []byte) string {
+	hash := sha256.Sum256(credential)
+	return hex.EncodeToString(hash[:])
+}
+
+// TenantMetrics holds the request counters of one tenant
+type TenantMetrics struct {
+	TenantID       string `json:"tenantId"`
+	Requests       uint64 `json:"requests"`
+	RateLimited    uint64 `json:"rateLimited"`
+	WebhooksCalled uint64 `json:"webhooksCalled"`
+	WebhookErrors  uint64 `json:"webhookErrors"`
+}
+
+// Tenants resolves the tenant of gateway requests and isolates their rate limits, metrics and webhooks
+type Tenants struct {
+	Tenants       []Tenant
+	WebhookClient *http.Client // Optional, defaults to a client with a 10s timeout
+	Clock         clock.Clock  // Optional, defaults to clock.System
+
+	mu       sync.Mutex
+	byAPIKey map[string]*Tenant
+	byCert   map[string]*Tenant
+	scopes   map[string]*APIKeyScope // By API key hash
+	states   map[string]*tenantState
+}
+
+type tenantState struct {
+	metrics  TenantMetrics
+	tokens   float64
+	lastSeen time.Time
+}
+
+type tenantContextKey struct{}
+
+type scopeContextKey struct{}
+
+// Validate checks that tenant IDs are unique, every tenant can be resolved and has a channel mapping,
+// and no credential is shared between tenants
+func (t *Tenants) Validate() error {
+	ids := make(map[string]bool)
+	credentials := make(map[string]string)
+	for _, tenant := range t.Tenants {
+		if tenant.ID == "" || ids[tenant.ID] {
+			return fmt.Errorf("tenant ID %q is empty or not unique", tenant.ID)
+		}
+		ids[tenant.ID] = true
+		if len(tenant.APIKeyHashes) == 0 && len(tenant.ScopedAPIKeys) == 0 && len(tenant.ClientCertHashes) == 0 {
+			return fmt.Errorf("tenant %s has neither an API key nor a client certificate", tenant.ID)
+		}
+		if tenant.MSPID == "" || tenant.Channel == "" || tenant.Chaincode == "" {
+			return fmt.Errorf("tenant %s needs an MSP ID, channel and chaincode", tenant.ID)
+		}
+		hashes := append(append([]string{}, tenant.APIKeyHashes...), tenant.ClientCertHashes...)
+		for _, scope := range tenant.ScopedAPIKeys {
+			if len(scope.Namespaces) == 0 && len(scope.FingerprintPrefixes) == 0 {
+				return fmt.Errorf("scoped API key %s of tenant %s restricts neither namespaces nor fingerprint prefixes", scope.KeyHash, tenant.ID)
+			}
+			hashes = append(hashes, scope.KeyHash)
+		}
+		for _, hash := range hashes {
+			if other, ok := credentials[hash]; ok {
+				return fmt.Errorf("tenants %s and %s share a credential", other, tenant.ID)
+			}
+			credentials[hash] = tenant.ID
+		}
+	}
+	return nil
+}
+
+// Resolve returns the tenant of a request, identified by the API key header or the mTLS client certificate
+func (t *Tenants) Resolve(r *http.Request) (*Tenant, error) {
+	tenant, _, err := t.resolve(r)
+	return tenant, err
+}
+
+// resolve returns the tenant of a request and the scope of its API key, nil for an unscoped credential
+func (t *Tenants) resolve(r *http.Request) (*Tenant, *APIKeyScope, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.index()
+
+	if apiKey := r.Header.Get(APIKeyHeader); apiKey != "" {
+		hash := HashCredential([]byte(apiKey))
+		if tenant, ok := t.byAPIKey[hash]; ok {
+			return tenant, t.scopes[hash], nil
+		}
+		return nil, nil, ErrUnknownTenant
+	}
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		if tenant, ok := t.byCert[HashCredential(r.TLS.PeerCertificates[0].Raw)]; ok {
+			return tenant, nil, nil
+		}
+	}
+	return nil, nil, ErrUnknownTenant
+}
+
+// index builds the credential lookup tables, the caller must hold t.mu
+func (t *Tenants) index() {
+	if t.byAPIKey != nil {
+		return
+	}
+	t.byAPIKey = make(map[string]*Tenant)
+	t.byCert = make(map[string]*Tenant)
+	t.scopes = make(map[string]*APIKeyScope)
+	for i := range t.Tenants {
+		tenant := &t.Tenants[i]
+		for _, hash := range tenant.APIKeyHashes {
+			t.byAPIKey[hash] = tenant
+		}
+		for j := range tenant.ScopedAPIKeys {
+			scope := &tenant.ScopedAPIKeys[j]
+			t.byAPIKey[scope.KeyHash] = tenant
+			t.scopes[scope.KeyHash] = scope
+		}
+		for _, hash := range tenant.ClientCertHashes {
+			t.byCert[hash] = tenant
+		}
+	}
+}
+
+// Allow counts a request of the tenant against its own rate limit
+func (t *Tenants) Allow(tenant *Tenant) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state := t.state(tenant)
+	state.metrics.Requests++
+	if tenant.Rate > 0 {
+		now := clock.Or(t.Clock).Now()
+		state.tokens += now.Sub(state.lastSeen).Seconds() * tenant.Rate
+		if state.tokens > float64(tenant.Burst) {
+			state.tokens = float64(tenant.Burst)
+		}
+		state.lastSeen = now
+		if state.tokens < 1 {
+			state.metrics.RateLimited++
+			return ErrTenantRateLimited
+		}
+		state.tokens--
+	}
+	return nil
+}
+
+// state returns the counters of a tenant, the caller must hold t.mu
+func (t *Tenants) state(tenant *Tenant) *tenantState {
+	if t.states == nil {
+		t.states = make(map[string]*tenantState)
+	}
+	state, ok := t.states[tenant.ID]
+	if !ok {
+		state = &tenantState{metrics: TenantMetrics{TenantID: tenant.ID}, tokens: float64(tenant.Burst), lastSeen: clock.Or(t.Clock).Now()}
+		t.states[tenant.ID] = state
+	}
+	return state
+}
+
+// Middleware resolves the tenant of every request and applies its rate limit before calling next.
+// Handlers read the tenant with TenantFromContext to pick its identity, channel and chaincode.
+// Requests with a scoped API key are limited to GET and HEAD; the handlers serving lookups check
+// the scope itself, see APIKeyScopeFromContext.
+func (t *Tenants) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant, scope, err := t.resolve(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if err := t.Allow(tenant); err != nil {
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		ctx := context.WithValue(r.Context(), tenantContextKey{}, tenant)
+		if scope != nil {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				http.Error(w, ErrReadOnlyAPIKey.Error(), http.StatusForbidden)
+				return
+			}
+			ctx = context.WithValue(ctx, scopeContextKey{}, scope)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// TenantFromContext returns the tenant Middleware resolved for a request
+func TenantFromContext(ctx context.Context) (*Tenant, bool) {
+	tenant, ok := ctx.Value(tenantContextKey{}).(*Tenant)
+	return tenant, ok
+}
+
+// APIKeyScopeFromContext returns the scope of the API key of a request, if Middleware resolved a scoped key
+func APIKeyScopeFromContext(ctx context.Context) (*APIKeyScope, bool) {
+	scope, ok := ctx.Value(scopeContextKey{}).(*APIKeyScope)
+	return scope, ok
+}
+
+// WebhookEvent is the body posted to tenant webhooks
+type WebhookEvent struct {
+	TenantID  string          `json:"tenantId"`
+	Channel   string          `json:"channel"`
+	Chaincode string          `json:"chaincode"`
+	EventName string          `json:"eventName"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// DispatchEvent posts a chaincode event to the webhooks of the tenants mapped to its channel and chaincode only.
+// It returns the first error after trying every webhook.
+func (t *Tenants) DispatchEvent(channel string, chaincode string, eventName string, payload []byte) error {
+	client := t.WebhookClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	var firstErr error
+	for i := range t.Tenants {
+		tenant := &t.Tenants[i]
+		if tenant.Channel != channel || tenant.Chaincode != chaincode || len(tenant.Webhooks) == 0 {
+			continue
+		}
+		body, err := json.Marshal(WebhookEvent{TenantID: tenant.ID, Channel: channel, Chaincode: chaincode, EventName: eventName, Payload: payload})
+		if err != nil {
+			return err
+		}
+		for _, webhook := range tenant.Webhooks {
+			err := postWebhook(client, webhook, body)
+			t.mu.Lock()
+			state := t.state(tenant)
+			state.metrics.WebhooksCalled++
+			if err != nil {
+				state.metrics.WebhookErrors++
+			}
+			t.mu.Unlock()
+			if err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("tenant %s webhook %s: %v", tenant.ID, webhook, err)
+			}
+		}
+	}
+	return firstErr
+}
+
+func postWebhook(client *http.Client, url string, body []byte) error {
+	response, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", response.Status)
+	}
+	return nil
+}
+
+// Metrics returns the counters of every tenant that received a request or event, sorted by tenant ID
+func (t *Tenants) Metrics() []TenantMetrics {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	metrics := make([]TenantMetrics, 0, len(t.states))
+	for _, state := range t.states {
+		metrics = append(metrics, state.metrics)
+	}
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].TenantID < metrics[j].TenantID
+	})
+	return metrics
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
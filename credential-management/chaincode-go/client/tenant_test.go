@@ -0,0 +1,174 @@
+package client_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"github.com/pherbke/credential-management/chaincode-go/client"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func testTenants(webhooks ...string) *client.Tenants {
+	return &client.Tenants{Tenants: []client.Tenant{
+		{
+			ID: "org1", APIKeyHashes: []string{client.HashCredential([]byte("org1-key"))},
+			MSPID: "Org1MSP", Channel: "registry", Chaincode: "credential-management",
+			Rate: 0.001, Burst: 2, Webhooks: webhooks,
+		},
+		{
+			ID: "org2", ClientCertHashes: []string{client.HashCredential([]byte("org2-cert"))},
+			MSPID: "Org2MSP", Channel: "registry-eu", Chaincode: "credential-management",
+		},
+	}}
+}
+
+func TestTenants_Validate(t *testing.T) {
+	tenants := testTenants()
+	require.NoError(t, tenants.Validate())
+
+	tenants.Tenants[1].ClientCertHashes = tenants.Tenants[0].APIKeyHashes
+	require.Error(t, tenants.Validate())
+
+	tenants = testTenants()
+	tenants.Tenants[1].ClientCertHashes = nil
+	require.Error(t, tenants.Validate())
+}
+
+func TestTenants_Resolve(t *testing.T) {
+	tenants := testTenants()
+
+	request := httptest.NewRequest(http.MethodGet, "/lookup", nil)
+	request.Header.Set(client.APIKeyHeader, "org1-key")
+	tenant, err := tenants.Resolve(request)
+	require.NoError(t, err)
+	require.Equal(t, "org1", tenant.ID)
+
+	request = httptest.NewRequest(http.MethodGet, "/lookup", nil)
+	request.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Raw: []byte("org2-cert")}}}
+	tenant, err = tenants.Resolve(request)
+	require.NoError(t, err)
+	require.Equal(t, "org2", tenant.ID)
+	require.Equal(t, map[string]string{"tenant": "org2", "channel": "registry-eu", "chaincode": "credential-management"}, tenant.MetricsLabels())
+
+	request.Header.Set(client.APIKeyHeader, "wrong-key")
+	_, err = tenants.Resolve(request)
+	require.ErrorIs(t, err, client.ErrUnknownTenant)
+}
+
+func TestTenants_MiddlewareIsolatesRateLimits(t *testing.T) {
+	tenants := testTenants()
+	handler := tenants.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant, ok := client.TenantFromContext(r.Context())
+		require.True(t, ok)
+		_, _ = w.Write([]byte(tenant.Channel))
+	}))
+	call := func(apiKey string, cert string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "/lookup", nil)
+		if apiKey != "" {
+			request.Header.Set(client.APIKeyHeader, apiKey)
+		}
+		if cert != "" {
+			request.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Raw: []byte(cert)}}}
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	require.Equal(t, "registry", call("org1-key", "").Body.String())
+	require.Equal(t, http.StatusOK, call("org1-key", "").Code)
+	require.Equal(t, http.StatusTooManyRequests, call("org1-key", "").Code)
+	// org1 exhausting its burst does not affect org2
+	require.Equal(t, "registry-eu", call("", "org2-cert").Body.String())
+	require.Equal(t, http.StatusUnauthorized, call("", "").Code)
+
+	require.Equal(t, []client.TenantMetrics{
+		{TenantID: "org1", Requests: 3, RateLimited: 1},
+		{TenantID: "org2", Requests: 1},
+	}, tenants.Metrics())
+}
+
+func TestTenants_DispatchEventToMappedTenantsOnly(t *testing.T) {
+	var mu sync.Mutex
+	var received []client.WebhookEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event client.WebhookEvent
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		mu.Lock()
+		received = append(received, event)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	tenants := testTenants(server.URL)
+	tenants.Tenants[1].Webhooks = []string{server.URL}
+
+	require.NoError(t, tenants.DispatchEvent("registry", "credential-management", "RegistryConfigChanged", []byte(`{"version":2}`)))
+	require.Len(t, received, 1)
+	require.Equal(t, "org1", received[0].TenantID)
+	require.JSONEq(t, `{"version":2}`, string(received[0].Payload))
+
+	require.NoError(t, tenants.DispatchEvent("other", "credential-management", "RegistryConfigChanged", []byte(`{}`)))
+	require.Len(t, received, 1)
+}
+
+func TestTenants_DispatchEventFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	tenants := testTenants(server.URL)
+	require.Error(t, tenants.DispatchEvent("registry", "credential-management", "RegistryConfigChanged", []byte(`{}`)))
+	require.Equal(t, uint64(1), tenants.Metrics()[0].WebhookErrors)
+}
+
+func TestTenants_ScopedAPIKeys(t *testing.T) {
+	tenants := testTenants()
+	tenants.Tenants[0].Rate = 0
+	tenants.Tenants[0].ScopedAPIKeys = []client.APIKeyScope{{KeyHash: client.HashCredential([]byte("rp-key")), Namespaces: []string{"did:web:uni.example"}}}
+	require.NoError(t, tenants.Validate())
+
+	var scopes []*client.APIKeyScope
+	handler := tenants.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scope, _ := client.APIKeyScopeFromContext(r.Context())
+		scopes = append(scopes, scope)
+	}))
+	call := func(method string, apiKey string) int {
+		request := httptest.NewRequest(method, "/lookup", nil)
+		request.Header.Set(client.APIKeyHeader, apiKey)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	// Scoped keys resolve to their tenant, carry their scope and are read-only
+	require.Equal(t, http.StatusOK, call(http.MethodGet, "rp-key"))
+	require.Equal(t, http.StatusForbidden, call(http.MethodPost, "rp-key"))
+	require.Equal(t, http.StatusOK, call(http.MethodPost, "org1-key"))
+	require.Equal(t, []*client.APIKeyScope{&tenants.Tenants[0].ScopedAPIKeys[0], nil}, scopes)
+
+	// A scope must restrict something, and scoped keys are credentials like any other
+	tenants = testTenants()
+	tenants.Tenants[0].ScopedAPIKeys = []client.APIKeyScope{{KeyHash: client.HashCredential([]byte("rp-key"))}}
+	require.Error(t, tenants.Validate())
+	tenants.Tenants[0].ScopedAPIKeys[0].FingerprintPrefixes = []string{"ab"}
+	tenants.Tenants[1].ClientCertHashes = []string{client.HashCredential([]byte("rp-key"))}
+	require.Error(t, tenants.Validate())
+}
+
+func TestAPIKeyScope_Allows(t *testing.T) {
+	scope := &client.APIKeyScope{Namespaces: []string{"did:web:uni.example"}, FingerprintPrefixes: []string{"AB", "cd"}}
+	require.True(t, scope.Allows("did:web:uni.example", []string{"ab12", "cd34"}))
+	require.False(t, scope.Allows("did:web:uni.example", []string{"ab12", "ef56"}))
+	require.False(t, scope.Allows("did:web:other.example", []string{"ab12"}))
+	require.False(t, scope.Allows("", []string{"ab12"}))
+
+	scope = &client.APIKeyScope{FingerprintPrefixes: []string{"ab"}}
+	require.True(t, scope.Allows("", []string{"ab12"}))
+	require.True(t, scope.Allows("did:web:other.example", []string{"ab12"}))
+}
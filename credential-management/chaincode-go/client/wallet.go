@@ -0,0 +1,177 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultWalletSyncBatchSize is the number of fingerprints WalletSync looks up per gateway call by default
+const DefaultWalletSyncBatchSize = 100
+
+// HeldCredential is a credential in a wallet together with its last known revocation status
+type HeldCredential struct {
+	ID          string    `json:"id"`
+	Fingerprint string    `json:"fingerprint"` // credentialStatus fingerprint, the only identifier sent to the gateway
+	Revoked     bool      `json:"revoked"`
+	CheckedAt   time.Time `json:"checkedAt"` // Zero until the first successful sync
+}
+
+// WalletSync keeps the revocation status of the credentials held by a wallet up to date. It asks the
+// gateway about the fingerprints of the held credentials only, in batches, and caches the answers,
+// so holders learn about revocations without the gateway seeing their other credentials or their ids.
+type WalletSync struct {
+	Lookup    func(fingerprints []string) (map[string]bool, error) // Revocation status per fingerprint, e.g. by evaluating BatchLookup
+	BatchSize int                                                  // Fingerprints per Lookup call, defaults to DefaultWalletSyncBatchSize
+	OnRevoked func(credential HeldCredential)                      // Optional, called once when a held credential is found revoked
+	Clock     clock.Clock                                          // Optional, defaults to clock.System
+
+	mu          sync.Mutex
+	credentials map[string]*HeldCredential
+	lastSync    time.Time
+}
+
+// Hold adds a credential to the set that is synced
+func (w *WalletSync) Hold(id string, fingerprint string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.credentials == nil {
+		w.credentials = make(map[string]*HeldCredential)
+	}
+	if held, ok := w.credentials[id]; ok && held.Fingerprint == fingerprint {
+		return
+	}
+	w.credentials[id] = &HeldCredential{ID: id, Fingerprint: fingerprint}
+}
+
+// Drop removes a credential the wallet no longer holds
+func (w *WalletSync) Drop(id string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.credentials, id)
+}
+
+// Sync looks up the status of every held credential. Credentials already known to be revoked are not
+// asked for again, as revocations are final. Batches that fail keep their cached status; the first error
+// is returned after every batch was tried.
+func (w *WalletSync) Sync() error {
+	batchSize := w.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultWalletSyncBatchSize
+	}
+
+	w.mu.Lock()
+	var fingerprints []string
+	seen := make(map[string]bool)
+	for _, held := range w.credentials {
+		if !held.Revoked && !seen[held.Fingerprint] {
+			seen[held.Fingerprint] = true
+			fingerprints = append(fingerprints, held.Fingerprint)
+		}
+	}
+	w.mu.Unlock()
+	sort.Strings(fingerprints)
+
+	var firstErr error
+	for start := 0; start < len(fingerprints); start += batchSize {
+		end := start + batchSize
+		if end > len(fingerprints) {
+			end = len(fingerprints)
+		}
+		batch := fingerprints[start:end]
+		// Lookup talks to the gateway, so it runs without holding the lock
+		statuses, err := w.Lookup(batch)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("error looking up %d credentials: %v", len(batch), err)
+			}
+			continue
+		}
+		w.update(batch, statuses, clock.Or(w.Clock).Now())
+	}
+
+	if firstErr == nil {
+		w.mu.Lock()
+		w.lastSync = clock.Or(w.Clock).Now()
+		w.mu.Unlock()
+	}
+	return firstErr
+}
+
+// update caches the statuses of a batch and reports newly revoked credentials
+func (w *WalletSync) update(batch []string, statuses map[string]bool, checkedAt time.Time) {
+	inBatch := make(map[string]bool, len(batch))
+	for _, fingerprint := range batch {
+		inBatch[fingerprint] = true
+	}
+
+	var revoked []HeldCredential
+	w.mu.Lock()
+	for _, held := range w.credentials {
+		if !inBatch[held.Fingerprint] || held.Revoked {
+			continue
+		}
+		held.CheckedAt = checkedAt
+		if statuses[held.Fingerprint] {
+			held.Revoked = true
+			revoked = append(revoked, *held)
+		}
+	}
+	w.mu.Unlock()
+
+	if w.OnRevoked != nil {
+		for _, credential := range revoked {
+			w.OnRevoked(credential)
+		}
+	}
+}
+
+// Run calls Sync every interval until stop is closed. Failed syncs are retried at the next interval.
+func (w *WalletSync) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			_ = w.Sync()
+		}
+	}
+}
+
+// Credentials returns the held credentials with their cached status, sorted by ID
+func (w *WalletSync) Credentials() []HeldCredential {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	credentials := make([]HeldCredential, 0, len(w.credentials))
+	for _, held := range w.credentials {
+		credentials = append(credentials, *held)
+	}
+	sort.Slice(credentials, func(i, j int) bool {
+		return credentials[i].ID < credentials[j].ID
+	})
+	return credentials
+}
+
+// WalletList is the body served by WalletSync as the wallet list API
+type WalletList struct {
+	LastSync    time.Time        `json:"lastSync"` // Time of the last sync without errors
+	Credentials []HeldCredential `json:"credentials"`
+}
+
+func (w *WalletSync) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	w.mu.Lock()
+	lastSync := w.lastSync
+	w.mu.Unlock()
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(WalletList{LastSync: lastSync, Credentials: w.Credentials()}); err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+	}
+}
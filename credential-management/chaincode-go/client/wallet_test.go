@@ -0,0 +1,90 @@
+package client_test
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/pherbke/credential-management/chaincode-go/client"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWalletSync_Sync(t *testing.T) {
+	revoked := map[string]bool{"fp-b": true}
+	var calls [][]string
+	var notified []string
+	wallet := &client.WalletSync{
+		BatchSize: 2,
+		Lookup: func(fingerprints []string) (map[string]bool, error) {
+			calls = append(calls, fingerprints)
+			statuses := make(map[string]bool)
+			for _, fingerprint := range fingerprints {
+				statuses[fingerprint] = revoked[fingerprint]
+			}
+			return statuses, nil
+		},
+		OnRevoked: func(credential client.HeldCredential) {
+			notified = append(notified, credential.ID)
+		},
+	}
+	wallet.Hold("cred-a", "fp-a")
+	wallet.Hold("cred-b", "fp-b")
+	wallet.Hold("cred-c", "fp-c")
+
+	require.NoError(t, wallet.Sync())
+	require.Equal(t, [][]string{{"fp-a", "fp-b"}, {"fp-c"}}, calls)
+	require.Equal(t, []string{"cred-b"}, notified)
+
+	credentials := wallet.Credentials()
+	require.Len(t, credentials, 3)
+	require.False(t, credentials[0].Revoked)
+	require.True(t, credentials[1].Revoked)
+	require.False(t, credentials[2].CheckedAt.IsZero())
+
+	// Revoked credentials are not asked for or reported again
+	calls = nil
+	require.NoError(t, wallet.Sync())
+	require.Equal(t, [][]string{{"fp-a", "fp-c"}}, calls)
+	require.Equal(t, []string{"cred-b"}, notified)
+
+	wallet.Drop("cred-a")
+	require.Len(t, wallet.Credentials(), 2)
+}
+
+func TestWalletSync_SyncKeepsCacheOnError(t *testing.T) {
+	fail := false
+	wallet := &client.WalletSync{Lookup: func(fingerprints []string) (map[string]bool, error) {
+		if fail {
+			return nil, errors.New("gateway unavailable")
+		}
+		return map[string]bool{"fp-a": true}, nil
+	}}
+	wallet.Hold("cred-a", "fp-a")
+	wallet.Hold("cred-b", "fp-b")
+	require.NoError(t, wallet.Sync())
+
+	fail = true
+	require.Error(t, wallet.Sync())
+	credentials := wallet.Credentials()
+	require.True(t, credentials[0].Revoked)
+	require.False(t, credentials[1].CheckedAt.IsZero())
+}
+
+func TestWalletSync_ServeHTTP(t *testing.T) {
+	wallet := &client.WalletSync{Lookup: func(fingerprints []string) (map[string]bool, error) {
+		return map[string]bool{"fp-a": true}, nil
+	}}
+	wallet.Hold("cred-a", "fp-a")
+	require.NoError(t, wallet.Sync())
+
+	recorder := httptest.NewRecorder()
+	wallet.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/credentials", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var list client.WalletList
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &list))
+	require.False(t, list.LastSync.IsZero())
+	require.Len(t, list.Credentials, 1)
+	require.True(t, list.Credentials[0].Revoked)
+}
@@ -0,0 +1,27 @@
+package clock_test
+
+import (
+	"github.com/pherbke/credential-management/chaincode-go/clock"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestManual(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	now := clock.NewManual(start)
+	require.Equal(t, start, now.Now())
+
+	now.Advance(time.Hour)
+	require.Equal(t, start.Add(time.Hour), now.Now())
+
+	now.Set(start.AddDate(-1, 0, 0))
+	require.Equal(t, start.AddDate(-1, 0, 0), now.Now())
+}
+
+func TestOr(t *testing.T) {
+	require.WithinDuration(t, time.Now(), clock.Or(nil).Now(), time.Second)
+
+	fixed := clock.Func(func() time.Time { return time.Unix(0, 0) })
+	require.Equal(t, time.Unix(0, 0), clock.Or(fixed).Now())
+}
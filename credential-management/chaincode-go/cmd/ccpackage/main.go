@@ -0,0 +1,60 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Command ccpackage builds static chaincode binaries for each target architecture and writes the
+// chaincode-as-a-service package (connection.json and metadata.json) installed on the peers.
+//
+//	go run ./cmd/ccpackage -label credential-management_1.0 -address credential-management.org1.example.com:9999
+//
+// The binaries are named credential-management-<arch> so the Dockerfile can pick the one
+// matching TARGETARCH when building a multi-arch image. revocctl deploy installs, approves and
+// commits the package.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	label := flag.String("label", "credential-management_1.0", "chaincode package label")
+	address := flag.String("address", "credential-management.org1.example.com:9999", "chaincode server address the peer connects to")
+	dialTimeout := flag.String("dial-timeout", "10s", "peer dial timeout for the chaincode server")
+	tlsRequired := flag.Bool("tls", false, "require TLS between peer and chaincode server")
+	archs := flag.String("arch", "amd64,arm64", "comma-separated target architectures, empty to skip the binaries")
+	source := flag.String("source", ".", "chaincode source directory")
+	out := flag.String("out", "build", "output directory")
+	flag.Parse()
+
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		log.Fatalf("Error creating output directory: %v", err)
+	}
+
+	for _, arch := range strings.Split(*archs, ",") {
+		if arch = strings.TrimSpace(arch); arch == "" {
+			continue
+		}
+		binary, err := buildStatic(*source, *out, arch)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Built %s", binary)
+	}
+
+	packagePath := filepath.Join(*out, *label+".tar.gz")
+	file, err := os.Create(packagePath)
+	if err != nil {
+		log.Fatalf("Error creating chaincode package: %v", err)
+	}
+	defer file.Close()
+
+	conn := connection{Address: *address, DialTimeout: *dialTimeout, TLSRequired: *tlsRequired}
+	if err := writeCCaaSPackage(file, *label, conn); err != nil {
+		log.Fatalf("Error writing chaincode package: %v", err)
+	}
+	log.Printf("Wrote %s", packagePath)
+}
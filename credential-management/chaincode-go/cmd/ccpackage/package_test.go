@@ -0,0 +1,56 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+func readTarGz(t *testing.T, data []byte) map[string][]byte {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+
+	files := make(map[string][]byte)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[header.Name] = content
+	}
+	return files
+}
+
+func TestWriteCCaaSPackage(t *testing.T) {
+	var buf bytes.Buffer
+	conn := connection{Address: "credential-management.org1.example.com:9999", DialTimeout: "10s"}
+	require.NoError(t, writeCCaaSPackage(&buf, "credential-management_1.0", conn))
+
+	outer := readTarGz(t, buf.Bytes())
+	require.Len(t, outer, 2)
+
+	var meta metadata
+	require.NoError(t, json.Unmarshal(outer["metadata.json"], &meta))
+	require.Equal(t, metadata{Type: "ccaas", Label: "credential-management_1.0"}, meta)
+
+	code := readTarGz(t, outer["code.tar.gz"])
+	var decoded connection
+	require.NoError(t, json.Unmarshal(code["connection.json"], &decoded))
+	require.Equal(t, conn, decoded)
+}
+
+func TestWriteCCaaSPackage_Reproducible(t *testing.T) {
+	conn := connection{Address: "localhost:9999", DialTimeout: "10s", TLSRequired: true}
+	var first, second bytes.Buffer
+	require.NoError(t, writeCCaaSPackage(&first, "credential-management_1.0", conn))
+	require.NoError(t, writeCCaaSPackage(&second, "credential-management_1.0", conn))
+	require.Equal(t, first.Bytes(), second.Bytes(), "Identical inputs should produce identical packages")
+}
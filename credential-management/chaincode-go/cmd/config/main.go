@@ -0,0 +1,40 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Command config checks a configuration file together with the environment overrides and
+// prints the effective configuration:
+//
+//	go run ./cmd/config validate -f config.yaml
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/pherbke/credential-management/chaincode-go/config"
+)
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "validate" {
+		fmt.Fprintln(os.Stderr, "usage: config validate [-f config.yaml]")
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	path := flags.String("f", os.Getenv("CREDENTIAL_MANAGEMENT_CONFIG"), "configuration file")
+	flags.Parse(os.Args[2:])
+
+	c, err := config.Load(*path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		os.Exit(1)
+	}
+	configYAML, err := c.YAML()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding configuration: %v\n", err)
+		os.Exit(1)
+	}
+	os.Stdout.Write(configYAML)
+}
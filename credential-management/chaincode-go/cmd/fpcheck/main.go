@@ -0,0 +1,46 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Command fpcheck checks fingerprints computed by another implementation, e.g. a JS or Python wallet,
+// against this one. The input is a JSON array in the format of smart-contract/testdata/fingerprint_vectors.json
+// with the fingerprints filled in by the implementation under test:
+//
+//	go run ./cmd/fpcheck wallet-results.json
+package main
+
+import (
+	"fmt"
+	"os"
+
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: fpcheck <results.json>")
+		os.Exit(2)
+	}
+
+	file, err := os.Open(os.Args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening results: %v\n", err)
+		os.Exit(2)
+	}
+	defer file.Close()
+
+	vectors, err := cuckoofilter.ReadFingerprintVectors(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	mismatches := cuckoofilter.CheckFingerprintVectors(vectors)
+	for _, mismatch := range mismatches {
+		fmt.Println("MISMATCH", mismatch)
+	}
+	fmt.Printf("%d of %d fingerprints match\n", len(vectors)-len(mismatches), len(vectors))
+	if len(mismatches) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,210 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Command keys runs the key ceremony for the registry signing keys and signs with a quorum of custodians.
+//
+// ceremony generates the snapshot-signing and co-signing keys, splits each across the custodians given
+// as name=public-key.pem and writes one encrypted share per key and custodian, <role>-<name>.share.json,
+// plus the public keys, <role>.pub.pem, to the output directory:
+//
+//	go run ./cmd/keys ceremony -threshold 2 -o ceremony alice=alice.pub.pem bob=bob.pub.pem carol=carol.pub.pem
+//
+// sign reconstructs a key from the shares of a quorum, each given as share.json=custodian-key.pem, and
+// writes the ASN.1 ECDSA signature over the SHA-256 of a file, e.g. a snapshot manifest. The
+// reconstructed key is only held in memory:
+//
+//	go run ./cmd/keys sign -o manifest.json.sig -share ceremony/snapshot-signing-alice.share.json=alice.pem \
+//	    -share ceremony/snapshot-signing-carol.share.json=carol.pem manifest.json
+package main
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pherbke/credential-management/chaincode-go/ceremony"
+	"github.com/pherbke/credential-management/chaincode-go/certificate"
+)
+
+const usage = `usage: keys ceremony [-threshold n] [-o dir] <name>=<public-key.pem>...
+       keys sign -share <share.json>=<custodian-key.pem>... [-o signature] <file>`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "ceremony":
+		err = runCeremony(os.Args[2:])
+	case "sign":
+		err = runSign(os.Args[2:])
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func runCeremony(args []string) error {
+	flags := flag.NewFlagSet("ceremony", flag.ExitOnError)
+	threshold := flags.Int("threshold", 2, "number of custodians needed to reconstruct a key")
+	output := flags.String("o", ".", "directory to write the shares and public keys to")
+	flags.Parse(args)
+	if flags.NArg() < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var custodians []ceremony.Custodian
+	for _, arg := range flags.Args() {
+		name, keyFile, ok := strings.Cut(arg, "=")
+		if !ok {
+			return fmt.Errorf("custodian %q must be given as name=public-key.pem", arg)
+		}
+		publicKey, err := readCustodianPublicKey(keyFile)
+		if err != nil {
+			return err
+		}
+		custodians = append(custodians, ceremony.Custodian{Name: name, PublicKey: publicKey})
+	}
+
+	result, err := ceremony.Run(custodians, *threshold, ceremony.Roles, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*output, 0700); err != nil {
+		return fmt.Errorf("error creating output directory: %v", err)
+	}
+	for role, publicKey := range result.PublicKeys {
+		publicKeyDER, err := x509.MarshalPKIXPublicKey(publicKey)
+		if err != nil {
+			return fmt.Errorf("error encoding %s public key: %v", role, err)
+		}
+		publicKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyDER})
+		if err := os.WriteFile(filepath.Join(*output, role+".pub.pem"), publicKeyPEM, 0644); err != nil {
+			return fmt.Errorf("error writing %s public key: %v", role, err)
+		}
+	}
+	for _, share := range result.Shares {
+		shareJSON, err := share.Marshal()
+		if err != nil {
+			return err
+		}
+		name := filepath.Join(*output, share.Role+"-"+share.Custodian+".share.json")
+		if err := os.WriteFile(name, shareJSON, 0600); err != nil {
+			return fmt.Errorf("error writing share: %v", err)
+		}
+		fmt.Printf("%s: share %d of %d for %s\n", name, share.X, share.Total, share.Custodian)
+	}
+	return nil
+}
+
+// shareFlags collects repeated -share flags
+type shareFlags []string
+
+func (f *shareFlags) String() string { return strings.Join(*f, ",") }
+
+func (f *shareFlags) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+func runSign(args []string) error {
+	flags := flag.NewFlagSet("sign", flag.ExitOnError)
+	var shareArgs shareFlags
+	flags.Var(&shareArgs, "share", "share and key of a custodian as share.json=custodian-key.pem, repeated for the quorum")
+	output := flags.String("o", "", "file to write the signature to, defaults to <file>.sig")
+	flags.Parse(args)
+	if flags.NArg() != 1 || len(shareArgs) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var shares []*ceremony.EncryptedShare
+	var custodianKeys []*ecdh.PrivateKey
+	for _, arg := range shareArgs {
+		shareFile, keyFile, ok := strings.Cut(arg, "=")
+		if !ok {
+			return fmt.Errorf("share %q must be given as share.json=custodian-key.pem", arg)
+		}
+		shareJSON, err := os.ReadFile(shareFile)
+		if err != nil {
+			return fmt.Errorf("error reading share: %v", err)
+		}
+		share, err := ceremony.UnmarshalShare(shareJSON)
+		if err != nil {
+			return err
+		}
+		keyPEM, err := os.ReadFile(keyFile)
+		if err != nil {
+			return fmt.Errorf("error reading custodian key: %v", err)
+		}
+		key, err := certificate.ParsePrivateKey(keyPEM)
+		if err != nil {
+			return err
+		}
+		custodianKey, err := key.ECDH()
+		if err != nil {
+			return fmt.Errorf("custodian key of %s is not a P-256 key: %v", share.Custodian, err)
+		}
+		shares = append(shares, share)
+		custodianKeys = append(custodianKeys, custodianKey)
+	}
+
+	key, err := ceremony.Reconstruct(shares, custodianKeys)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("error reading file to sign: %v", err)
+	}
+	hash := sha256.Sum256(data)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		return fmt.Errorf("error signing: %v", err)
+	}
+	if *output == "" {
+		*output = flags.Arg(0) + ".sig"
+	}
+	if err := os.WriteFile(*output, signature, 0644); err != nil {
+		return fmt.Errorf("error writing signature: %v", err)
+	}
+	fmt.Printf("Signed %s with the %s key\n", flags.Arg(0), shares[0].Role)
+	return nil
+}
+
+func readCustodianPublicKey(keyFile string) (*ecdh.PublicKey, error) {
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading custodian key: %v", err)
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in %s", keyFile)
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing custodian key %s: %v", keyFile, err)
+	}
+	ecKey, ok := publicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("custodian key %s is not an ECDSA key", keyFile)
+	}
+	return ecKey.ECDH()
+}
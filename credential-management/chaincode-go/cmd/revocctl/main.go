@@ -0,0 +1,360 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Command revocctl holds operator tools for the revocation registry.
+//
+// revocation-certificate signs the certificate of an approved revocation request, as returned by
+// evaluating GetRevocationCertificate, with the registry key. It writes the signed JSON bundle and
+// prints the certificate for filing:
+//
+//	peer chaincode query -C mychannel -n credential-management -c '{"Args":["GetRevocationCertificate","<id>"]}' > certificate.json
+//	go run ./cmd/revocctl revocation-certificate -key registry-key.pem -o <id>.json certificate.json
+//
+// lint checks a draft credential before it is signed: its structure, context URLs, date formats, DID
+// syntax and the claims the template of its type requires. It prints one line per problem and exits
+// with status 1 if any of them is an error:
+//
+//	go run ./cmd/revocctl lint [-templates templates.json] credential.json
+//
+// A templates file maps credential types to their required claims below credentialSubject, e.g.
+// {"AlumniCredential": {"required": ["id", "alumniOf.id"]}}, and replaces the built-in templates.
+//
+// import reads a CSV or JSONL list of credentials to revoke and writes the BatchInsert invocations
+// that revoke them, one JSON object per line with the Args for peer chaincode invoke -c and the
+// transient data for --transient. The column holds fingerprints, credential ids of the issuer in
+// -issuer-column or -issuer, or JWT credentials fingerprinted with -normalizer. Rows that cannot be
+// converted are listed on standard error and nothing is written. With -dry-run, the rows that would
+// be revoked are printed instead:
+//
+//	go run ./cmd/revocctl import -format csv -column jti -kind credential-id -issuer did:key:z6Mk... -dry-run revoke.csv
+//	go run ./cmd/revocctl import -format jsonl -column token -kind jwt -o batches.jsonl revoke.jsonl
+//
+// export writes the revocations returned by evaluating ListRevocations as CSV or JSONL. The input holds
+// one or more pages, e.g. the appended outputs of one query per bookmark:
+//
+//	peer chaincode query -C mychannel -n credential-management -c '{"Args":["ListRevocations","1000",""]}' > revocations.json
+//	go run ./cmd/revocctl export -format csv -o revocations.csv revocations.json
+//
+// deploy installs a chaincode package written by ccpackage on the peers, approves its definition for
+// every organization and commits it, skipping the steps that are already done. The channel is the test
+// network in -test-network, or described by a YAML -config file in the layout of deploy.NetworkConfig:
+//
+//	go run ./cmd/ccpackage -out build
+//	go run ./cmd/revocctl deploy -test-network ../../test-network -version 1.0 -sequence 1 build/credential-management_1.0.tar.gz
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pherbke/credential-management/chaincode-go/bulk"
+	"github.com/pherbke/credential-management/chaincode-go/certificate"
+	"github.com/pherbke/credential-management/chaincode-go/deploy"
+	"github.com/pherbke/credential-management/chaincode-go/lint"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"gopkg.in/yaml.v3"
+)
+
+const usage = `usage: revocctl revocation-certificate -key registry-key.pem [-o bundle.json] <certificate.json>
+       revocctl lint [-templates templates.json] <credential.json>
+       revocctl import [-format csv|jsonl] -column name [-kind fingerprint|credential-id|jwt] [-issuer-column name] [-issuer did]
+                       [-reason-column name] [-normalizer name] [-filter id] [-batch-size n] [-dry-run] [-o batches.jsonl] <file>
+       revocctl export [-format csv|jsonl] [-o file] <revocations.json>
+       revocctl deploy (-test-network dir | -config network.yaml) [-channel name] [-name name] [-version v] [-sequence n]
+                       [-init-required] [-timeout d] <package.tar.gz>`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	switch os.Args[1] {
+	case "revocation-certificate":
+		signRevocationCertificate()
+	case "lint":
+		lintCredential()
+	case "import":
+		importRevocations()
+	case "export":
+		exportRevocations()
+	case "deploy":
+		deployChaincode()
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// signRevocationCertificate implements revocation-certificate
+func signRevocationCertificate() {
+	flags := flag.NewFlagSet("revocation-certificate", flag.ExitOnError)
+	keyFile := flags.String("key", os.Getenv("REGISTRY_SIGNING_KEY"), "PEM encoded registry signing key")
+	output := flags.String("o", "", "file to write the signed bundle to, defaults to standard output")
+	flags.Parse(os.Args[2:])
+	if flags.NArg() != 1 || *keyFile == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	keyPEM, err := os.ReadFile(*keyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading registry key: %v\n", err)
+		os.Exit(1)
+	}
+	key, err := certificate.ParsePrivateKey(keyPEM)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	certificateJSON, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading certificate: %v\n", err)
+		os.Exit(1)
+	}
+
+	signed, err := certificate.Sign(certificateJSON, key, time.Now())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	bundleJSON, err := json.MarshalIndent(signed, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding bundle: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *output == "" {
+		os.Stdout.Write(append(bundleJSON, '\n'))
+		return
+	}
+	if err := os.WriteFile(*output, bundleJSON, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing bundle: %v\n", err)
+		os.Exit(1)
+	}
+	if err := signed.WriteText(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// lintCredential implements lint
+func lintCredential() {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	templatesFile := flags.String("templates", "", "JSON file of the required claims per credential type, defaults to the built-in templates")
+	flags.Parse(os.Args[2:])
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	templates := lint.DefaultTemplates
+	if *templatesFile != "" {
+		templatesJSON, err := os.ReadFile(*templatesFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading templates: %v\n", err)
+			os.Exit(1)
+		}
+		templates = map[string]lint.Template{}
+		if err := json.Unmarshal(templatesJSON, &templates); err != nil {
+			fmt.Fprintf(os.Stderr, "Error decoding templates: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	credentialJSON, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading credential: %v\n", err)
+		os.Exit(1)
+	}
+
+	issues := lint.Lint(credentialJSON, templates)
+	for _, issue := range issues {
+		fmt.Printf("%s: %s\n", flags.Arg(0), issue)
+	}
+	if lint.HasErrors(issues) {
+		os.Exit(1)
+	}
+	if len(issues) == 0 {
+		fmt.Printf("%s: ok\n", flags.Arg(0))
+	}
+}
+
+// importRevocations implements import
+func importRevocations() {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	format := flags.String("format", bulk.FormatCSV, "format of the file, csv or jsonl")
+	column := flags.String("column", "", "column holding the credentials")
+	kind := flags.String("kind", bulk.KindFingerprint, "kind of the column values, fingerprint, credential-id or jwt")
+	issuerColumn := flags.String("issuer-column", "", "column of the issuer DID of credential ids")
+	issuer := flags.String("issuer", "", "issuer DID of credential ids without an issuer column or value")
+	reasonColumn := flags.String("reason-column", "", "column of the revocation reason")
+	normalizerName := flags.String("normalizer", "", "normalizer of JWT credentials, defaults to the registry default")
+	filterID := flags.String("filter", "", "filter to revoke in, defaults to the default filter")
+	batchSize := flags.Int("batch-size", 100, "maximum credentials per BatchInsert, at most the maxBatchSize of the registry")
+	dryRun := flags.Bool("dry-run", false, "print the credentials that would be revoked instead of the invocations")
+	output := flags.String("o", "", "file to write the invocations to, defaults to standard output")
+	flags.Parse(os.Args[2:])
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	mapping := bulk.Mapping{Column: *column, Kind: *kind, IssuerColumn: *issuerColumn, Issuer: *issuer, ReasonColumn: *reasonColumn}
+	if *kind == bulk.KindJWT {
+		normalizer, err := cuckoofilter.LookupNormalizer(*normalizerName)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		mapping.Normalizer = normalizer
+	}
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading import file: %v\n", err)
+		os.Exit(1)
+	}
+	defer file.Close()
+	report, err := bulk.Read(file, *format, mapping)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	for _, rowErr := range report.Errors {
+		fmt.Fprintf(os.Stderr, "%s:%s\n", flags.Arg(0), rowErr)
+	}
+	if *dryRun {
+		for _, row := range report.Rows {
+			fmt.Printf("%s:line %d: would revoke %s (%s)\n", flags.Arg(0), row.Line, row.Fingerprint, row.Value)
+		}
+		fmt.Printf("%d credentials would be revoked, %d rows rejected\n", len(report.Rows), len(report.Errors))
+	}
+	if len(report.Errors) > 0 {
+		os.Exit(1)
+	}
+	if *dryRun {
+		return
+	}
+
+	batches, err := report.Batches(*filterID, *batchSize)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	out := os.Stdout
+	if *output != "" {
+		if out, err = os.Create(*output); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing invocations: %v\n", err)
+			os.Exit(1)
+		}
+		defer out.Close()
+	}
+	encoder := json.NewEncoder(out)
+	for _, batch := range batches {
+		if err := encoder.Encode(batch); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing invocations: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// exportRevocations implements export
+func exportRevocations() {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", bulk.FormatCSV, "format to write, csv or jsonl")
+	output := flags.String("o", "", "file to write to, defaults to standard output")
+	flags.Parse(os.Args[2:])
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading revocations: %v\n", err)
+		os.Exit(1)
+	}
+	defer file.Close()
+	revocations, err := bulk.ReadRevocations(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		if out, err = os.Create(*output); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing export: %v\n", err)
+			os.Exit(1)
+		}
+		defer out.Close()
+	}
+	if err := bulk.Export(out, *format, revocations); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing export: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// deployChaincode implements deploy
+func deployChaincode() {
+	flags := flag.NewFlagSet("deploy", flag.ExitOnError)
+	testNetwork := flags.String("test-network", "", "directory of the test network to deploy to")
+	configFile := flags.String("config", "", "YAML file describing the channel, its organizations and orderer")
+	channelID := flags.String("channel", "mychannel", "channel of the test network, overrides the channel of -config when set explicitly")
+	name := flags.String("name", "credential-management", "chaincode name")
+	version := flags.String("version", "1.0", "chaincode version")
+	sequence := flags.Int64("sequence", 1, "sequence of the chaincode definition")
+	initRequired := flags.Bool("init-required", false, "require Init before other transactions")
+	timeout := flags.Duration("timeout", 5*time.Minute, "time allowed for the whole deployment")
+	flags.Parse(os.Args[2:])
+	if flags.NArg() != 1 || (*testNetwork == "") == (*configFile == "") {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	var network *deploy.NetworkConfig
+	if *testNetwork != "" {
+		network = deploy.TestNetworkConfig(*testNetwork, *channelID)
+	} else {
+		configYAML, err := os.ReadFile(*configFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading network config: %v\n", err)
+			os.Exit(1)
+		}
+		network = &deploy.NetworkConfig{}
+		if err := yaml.Unmarshal(configYAML, network); err != nil {
+			fmt.Fprintf(os.Stderr, "Error decoding network config: %v\n", err)
+			os.Exit(1)
+		}
+		flags.Visit(func(f *flag.Flag) {
+			if f.Name == "channel" {
+				network.ChannelID = *channelID
+			}
+		})
+	}
+	chaincodePackage, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading chaincode package: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	deployer, closeConns, err := network.Connect(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer closeConns()
+	definition := deploy.Definition{Name: *name, Version: *version, Sequence: *sequence, InitRequired: *initRequired}
+	packageID, err := deployer.Deploy(ctx, chaincodePackage, definition)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Committed %s version %s sequence %d on %s with package %s\n", *name, *version, *sequence, network.ChannelID, packageID)
+}
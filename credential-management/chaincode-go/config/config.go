@@ -0,0 +1,212 @@
+// Package config holds the typed configuration of the chaincode server, the verifier and the
+// registry settings stored on the ledger. Configuration is read from a YAML file and individual
+// values can be overridden with environment variables.
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+)
+
+// Config is the complete configuration
+type Config struct {
+	Chaincode  ChaincodeConfig             `yaml:"chaincode"`
+	Verifier   VerifierConfig              `yaml:"verifier"`
+	Statistics StatisticsConfig            `yaml:"statistics"`
+	Registry   cuckoofilter.RegistryConfig `yaml:"registry"` // Applied on the ledger with UpdateRegistryConfig and SetFingerprintNormalizer
+}
+
+// ChaincodeConfig configures the chaincode process
+type ChaincodeConfig struct {
+	ServerAddress string    `yaml:"serverAddress"` // Runs as chaincode-as-a-service when set
+	ID            string    `yaml:"id"`            // Package ID assigned by the peer
+	TLS           TLSConfig `yaml:"tls"`
+	// MSPs whose clients administer the issuer trust registry, see TrustRegistryContract.AdminMSPs
+	TrustRegistryAdminMSPs []string `yaml:"trustRegistryAdminMSPs,omitempty"`
+}
+
+// TLSConfig configures TLS between the peer and the chaincode server
+type TLSConfig struct {
+	Enabled          bool   `yaml:"enabled"`
+	KeyFile          string `yaml:"keyFile"`
+	CertFile         string `yaml:"certFile"`
+	ClientCACertFile string `yaml:"clientCACertFile"` // Optional, enables client authentication
+}
+
+// MinDecisionHashKeyLength is the minimum length of the key hashing credential IDs in decision records
+const MinDecisionHashKeyLength = 16
+
+// VerifierConfig configures the verifier
+type VerifierConfig struct {
+	PolicyFile           string        `yaml:"policyFile"`           // Optional allow/deny policy
+	PolicyReloadInterval time.Duration `yaml:"policyReloadInterval"` // How often the policy file is checked for changes
+	DecisionLog          string        `yaml:"decisionLog"`          // File receiving decision records, "-" for stdout, empty to disable
+	DecisionHashKey      string        `yaml:"decisionHashKey"`      // HMAC key of the credential hashes in decision records
+	MaxStaleness         time.Duration `yaml:"maxStaleness"`         // Age of the revocation cache after which lookups bypass it
+	FailClosed           bool          `yaml:"failClosed"`           // Reject instead of querying the ledger when the cache is stale
+}
+
+// StatisticsConfig configures the public registry statistics
+type StatisticsConfig struct {
+	Epsilon float64 `yaml:"epsilon"` // Differential privacy budget of public statistics, 0 publishes exact values
+}
+
+// Default returns the configuration used for values that are neither in the file nor in the environment
+func Default() *Config {
+	return &Config{
+		Verifier: VerifierConfig{
+			PolicyReloadInterval: 30 * time.Second,
+			MaxStaleness:         30 * time.Second,
+		},
+		Registry: *cuckoofilter.DefaultRegistryConfig(),
+	}
+}
+
+// Load reads the configuration from the YAML file at path, applies environment overrides and validates the result.
+// An empty path uses the defaults.
+func Load(path string) (*Config, error) {
+	config := Default()
+	if path != "" {
+		configYAML, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading config file: %v", err)
+		}
+		if err := config.decode(configYAML); err != nil {
+			return nil, err
+		}
+	}
+	if err := config.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// decode merges YAML into the configuration, rejecting unknown keys
+func (c *Config) decode(configYAML []byte) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(configYAML))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("error decoding config file: %v", err)
+	}
+	return nil
+}
+
+// applyEnv overrides configuration values with the environment variables that are set.
+// The chaincode variables are the ones the peer's external builders already pass to chaincode servers.
+func (c *Config) applyEnv() error {
+	if v, ok := os.LookupEnv("CHAINCODE_SERVER_ADDRESS"); ok {
+		c.Chaincode.ServerAddress = v
+	}
+	if v, ok := os.LookupEnv("CHAINCODE_ID"); ok {
+		c.Chaincode.ID = v
+	}
+	if v, ok := os.LookupEnv("CHAINCODE_TLS_DISABLED"); ok {
+		disabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid CHAINCODE_TLS_DISABLED: %v", err)
+		}
+		c.Chaincode.TLS.Enabled = !disabled
+	}
+	if v, ok := os.LookupEnv("CHAINCODE_TLS_KEY"); ok {
+		c.Chaincode.TLS.KeyFile = v
+	}
+	if v, ok := os.LookupEnv("CHAINCODE_TLS_CERT"); ok {
+		c.Chaincode.TLS.CertFile = v
+	}
+	if v, ok := os.LookupEnv("CHAINCODE_CLIENT_CA_CERT"); ok {
+		c.Chaincode.TLS.ClientCACertFile = v
+	}
+	if v, ok := os.LookupEnv("VERIFIER_POLICY_FILE"); ok {
+		c.Verifier.PolicyFile = v
+	}
+	if v, ok := os.LookupEnv("VERIFIER_POLICY_RELOAD_INTERVAL"); ok {
+		interval, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid VERIFIER_POLICY_RELOAD_INTERVAL: %v", err)
+		}
+		c.Verifier.PolicyReloadInterval = interval
+	}
+	if v, ok := os.LookupEnv("VERIFIER_DECISION_LOG"); ok {
+		c.Verifier.DecisionLog = v
+	}
+	if v, ok := os.LookupEnv("VERIFIER_DECISION_HASH_KEY"); ok {
+		c.Verifier.DecisionHashKey = v
+	}
+	if v, ok := os.LookupEnv("VERIFIER_MAX_STALENESS"); ok {
+		staleness, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid VERIFIER_MAX_STALENESS: %v", err)
+		}
+		c.Verifier.MaxStaleness = staleness
+	}
+	if v, ok := os.LookupEnv("VERIFIER_FAIL_CLOSED"); ok {
+		failClosed, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid VERIFIER_FAIL_CLOSED: %v", err)
+		}
+		c.Verifier.FailClosed = failClosed
+	}
+	if v, ok := os.LookupEnv("STATISTICS_EPSILON"); ok {
+		epsilon, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid STATISTICS_EPSILON: %v", err)
+		}
+		c.Statistics.Epsilon = epsilon
+	}
+	if v, ok := os.LookupEnv("REGISTRY_MAX_BATCH_SIZE"); ok {
+		size, err := strconv.ParseUint(v, 10, 0)
+		if err != nil {
+			return fmt.Errorf("invalid REGISTRY_MAX_BATCH_SIZE: %v", err)
+		}
+		c.Registry.MaxBatchSize = uint(size)
+	}
+	return nil
+}
+
+// Validate checks that the configuration is complete and consistent
+func (c *Config) Validate() error {
+	if c.Chaincode.ServerAddress != "" {
+		if _, _, err := net.SplitHostPort(c.Chaincode.ServerAddress); err != nil {
+			return fmt.Errorf("chaincode.serverAddress: %v", err)
+		}
+		if c.Chaincode.ID == "" {
+			return fmt.Errorf("chaincode.id is required when chaincode.serverAddress is set")
+		}
+	}
+	if c.Chaincode.TLS.Enabled && (c.Chaincode.TLS.KeyFile == "" || c.Chaincode.TLS.CertFile == "") {
+		return fmt.Errorf("chaincode.tls.keyFile and chaincode.tls.certFile are required when TLS is enabled")
+	}
+	if c.Verifier.PolicyFile != "" && c.Verifier.PolicyReloadInterval <= 0 {
+		return fmt.Errorf("verifier.policyReloadInterval must be positive when verifier.policyFile is set")
+	}
+	if c.Verifier.DecisionLog != "" && len(c.Verifier.DecisionHashKey) < MinDecisionHashKeyLength {
+		return fmt.Errorf("verifier.decisionHashKey of at least %d characters is required when verifier.decisionLog is set", MinDecisionHashKeyLength)
+	}
+	if c.Verifier.MaxStaleness <= 0 {
+		return fmt.Errorf("verifier.maxStaleness must be positive")
+	}
+	if c.Statistics.Epsilon < 0 || math.IsNaN(c.Statistics.Epsilon) || math.IsInf(c.Statistics.Epsilon, 0) {
+		return fmt.Errorf("statistics.epsilon must be a non-negative number")
+	}
+	if err := c.Registry.Validate(); err != nil {
+		return fmt.Errorf("registry: %v", err)
+	}
+	return nil
+}
+
+// YAML returns the configuration as YAML
+func (c *Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c)
+}
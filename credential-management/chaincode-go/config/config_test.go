@@ -0,0 +1,115 @@
+package config_test
+
+import (
+	"github.com/pherbke/credential-management/chaincode-go/config"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	c, err := config.Load("")
+	require.NoError(t, err)
+	require.Equal(t, config.Default(), c)
+}
+
+func TestLoad_File(t *testing.T) {
+	path := writeConfig(t, `
+chaincode:
+  serverAddress: 0.0.0.0:9999
+  id: credential-management_1.0:abc
+  trustRegistryAdminMSPs: [GovernanceMSP]
+verifier:
+  policyFile: policy.json
+  policyReloadInterval: 1m
+registry:
+  maxBatchSize: 100
+`)
+	c, err := config.Load(path)
+	require.NoError(t, err)
+	require.Equal(t, "0.0.0.0:9999", c.Chaincode.ServerAddress)
+	require.Equal(t, "credential-management_1.0:abc", c.Chaincode.ID)
+	require.False(t, c.Chaincode.TLS.Enabled)
+	require.Equal(t, []string{"GovernanceMSP"}, c.Chaincode.TrustRegistryAdminMSPs)
+	require.Equal(t, "policy.json", c.Verifier.PolicyFile)
+	require.Equal(t, time.Minute, c.Verifier.PolicyReloadInterval)
+	require.Equal(t, uint(100), c.Registry.MaxBatchSize)
+}
+
+func TestLoad_UnknownKey(t *testing.T) {
+	path := writeConfig(t, "verifier:\n  polcyFile: policy.json\n")
+	_, err := config.Load(path)
+	require.Error(t, err)
+}
+
+func TestLoad_EnvOverride(t *testing.T) {
+	path := writeConfig(t, "registry:\n  maxBatchSize: 100\n")
+	t.Setenv("REGISTRY_MAX_BATCH_SIZE", "20")
+	t.Setenv("VERIFIER_POLICY_RELOAD_INTERVAL", "5s")
+	t.Setenv("VERIFIER_MAX_STALENESS", "1m")
+	t.Setenv("VERIFIER_FAIL_CLOSED", "true")
+	t.Setenv("VERIFIER_DECISION_LOG", "-")
+	t.Setenv("VERIFIER_DECISION_HASH_KEY", "decision-hash-key-0123")
+	t.Setenv("STATISTICS_EPSILON", "0.5")
+	t.Setenv("CHAINCODE_TLS_DISABLED", "false")
+	t.Setenv("CHAINCODE_TLS_KEY", "/tls/server.key")
+	t.Setenv("CHAINCODE_TLS_CERT", "/tls/server.crt")
+
+	c, err := config.Load(path)
+	require.NoError(t, err)
+	require.Equal(t, uint(20), c.Registry.MaxBatchSize)
+	require.Equal(t, 5*time.Second, c.Verifier.PolicyReloadInterval)
+	require.Equal(t, time.Minute, c.Verifier.MaxStaleness)
+	require.True(t, c.Verifier.FailClosed)
+	require.Equal(t, "decision-hash-key-0123", c.Verifier.DecisionHashKey)
+	require.Equal(t, 0.5, c.Statistics.Epsilon)
+	require.True(t, c.Chaincode.TLS.Enabled)
+	require.Equal(t, "/tls/server.key", c.Chaincode.TLS.KeyFile)
+}
+
+func TestLoad_InvalidEnv(t *testing.T) {
+	t.Setenv("REGISTRY_MAX_BATCH_SIZE", "many")
+	_, err := config.Load("")
+	require.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]func(c *config.Config){
+		"bad server address":    func(c *config.Config) { c.Chaincode.ServerAddress = "localhost"; c.Chaincode.ID = "cc" },
+		"server without id":     func(c *config.Config) { c.Chaincode.ServerAddress = "localhost:9999" },
+		"tls without key":       func(c *config.Config) { c.Chaincode.TLS.Enabled = true },
+		"policy without reload": func(c *config.Config) { c.Verifier.PolicyFile = "policy.json"; c.Verifier.PolicyReloadInterval = 0 },
+		"batch size over limit": func(c *config.Config) { c.Registry.MaxBatchSize = 1 << 20 },
+		"no staleness bound":    func(c *config.Config) { c.Verifier.MaxStaleness = 0 },
+		"decision log, no key":  func(c *config.Config) { c.Verifier.DecisionLog = "-" },
+		"short decision key":    func(c *config.Config) { c.Verifier.DecisionLog = "-"; c.Verifier.DecisionHashKey = "secret" },
+		"negative epsilon":      func(c *config.Config) { c.Statistics.Epsilon = -1 },
+	}
+	for name, modify := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := config.Default()
+			modify(c)
+			require.Error(t, c.Validate())
+		})
+	}
+}
+
+func TestYAML_RoundTrip(t *testing.T) {
+	c := config.Default()
+	c.Verifier.PolicyFile = "policy.json"
+	c.Registry.MaxBatchSize = 100
+	configYAML, err := c.YAML()
+	require.NoError(t, err)
+
+	loaded, err := config.Load(writeConfig(t, string(configYAML)))
+	require.NoError(t, err)
+	require.Equal(t, c, loaded)
+}
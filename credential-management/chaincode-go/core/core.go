@@ -0,0 +1,43 @@
+// Package core holds the code a verifier needs to check revocations offline: the credential fingerprint
+// derivation, the cuckoo filter hashing and the parsing of exported filter snapshots. It depends on the
+// standard library, go-metro and x/text only, without reflection-heavy or assembly-only dependencies, so
+// it compiles with TinyGo, e.g. for WebAssembly in mobile wallets and for edge devices:
+//
+//	tinygo build -target wasi -o verifier.wasm ./cmd/your-verifier
+//
+// The chaincode and the snapshot tooling use the same functions, so a filter parsed here answers
+// lookups exactly as the registry does.
+package core
+
+import (
+	metro "github.com/dgryski/go-metro"
+)
+
+// MaxFingerprintSize is the largest number of bytes of a filter fingerprint, and the default size
+const MaxFingerprintSize = 8
+
+// hashSeed is the go-metro seed of every filter hash
+const hashSeed = 1337
+
+// IndexAndFingerprint calculates the primary bucket index and the filter fingerprint of data
+func IndexAndFingerprint(data []byte, bucketIndexMask uint, fingerprintSize uint) (uint, []byte) {
+	hash := metro.Hash64(data, hashSeed)
+	fp := FilterFingerprint(hash, fingerprintSize)
+	i1 := uint(hash>>32) & bucketIndexMask
+	return i1, fp
+}
+
+// AltIndex calculates the alternate bucket index of a fingerprint stored at index i
+func AltIndex(fp []byte, i, bucketIndexMask uint) uint {
+	hash := metro.Hash64(fp, hashSeed)
+	return (i ^ uint(hash)) & bucketIndexMask
+}
+
+// FilterFingerprint takes the filter fingerprint of fingerprintSize bytes from a hash value
+func FilterFingerprint(hash uint64, fingerprintSize uint) []byte {
+	fp := make([]byte, fingerprintSize)
+	for i := uint(0); i < fingerprintSize; i++ {
+		fp[i] = byte(hash >> (8 * i))
+	}
+	return fp
+}
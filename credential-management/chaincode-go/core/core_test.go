@@ -0,0 +1,122 @@
+package core_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"os"
+	"testing"
+
+	"github.com/pherbke/credential-management/chaincode-go/core"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter_MatchesRegistry(t *testing.T) {
+	for _, semiSorted := range []bool{false, true} {
+		options := []cuckoofilter.Option{cuckoofilter.WithNumElements(200), cuckoofilter.WithBucketSize(4)}
+		if semiSorted {
+			options = append(options, cuckoofilter.WithSemiSortedBuckets())
+		}
+		filter, err := cuckoofilter.NewFilterWithOptions(options...)
+		require.NoError(t, err)
+		for i := 0; i < 100; i++ {
+			require.True(t, filter.Insert([]byte(fmt.Sprintf("credential-%d", i))))
+		}
+		filterJSON, err := json.Marshal(filter)
+		require.NoError(t, err)
+
+		parsed, err := core.ParseFilter(filterJSON)
+		require.NoError(t, err)
+		require.Equal(t, filter.Count, parsed.Count)
+		for i := 0; i < 200; i++ {
+			data := []byte(fmt.Sprintf("credential-%d", i))
+			require.Equal(t, filter.Lookup(data), parsed.Lookup(data), "semi-sorted %v, %s", semiSorted, data)
+		}
+	}
+}
+
+func TestParseFilter_RejectsCorruptFilters(t *testing.T) {
+	for _, filterJSON := range []string{
+		`{"Count":1}`,
+		`{"BucketIndexMask":3,"SerializedBuckets":[[null],[null]]}`,
+		`{"BucketIndexMask":0,"FingerprintSize":2,"SerializedBuckets":[["AAAA"]]}`,
+		`{"BucketIndexMask":1,"SemiSorted":true,"PackedBuckets":"AA=="}`,
+		`[]`,
+	} {
+		_, err := core.ParseFilter([]byte(filterJSON))
+		require.Error(t, err, filterJSON)
+	}
+}
+
+func TestIsRevoked(t *testing.T) {
+	fingerprint, err := core.ComputeFingerprint(core.FingerprintV1, "did:web:issuer.example", "urn:uuid:1")
+	require.NoError(t, err)
+	filter := cuckoofilter.NewFilter(100, 4, 0)
+	require.True(t, filter.Insert([]byte(fingerprint)))
+	filterJSON, err := json.Marshal(filter)
+	require.NoError(t, err)
+	parsed, err := core.ParseFilter(filterJSON)
+	require.NoError(t, err)
+
+	revoked, err := parsed.IsRevoked(core.FingerprintV1, " did:web:issuer.example", "urn:uuid:1")
+	require.NoError(t, err)
+	require.True(t, revoked)
+	revoked, err = parsed.IsRevoked(core.FingerprintV1, "did:web:issuer.example", "urn:uuid:2")
+	require.NoError(t, err)
+	require.False(t, revoked)
+	_, err = parsed.IsRevoked("cm-fingerprint-v0", "did:web:issuer.example", "urn:uuid:1")
+	require.Error(t, err)
+}
+
+func TestComputeFingerprint_Vectors(t *testing.T) {
+	file, err := os.Open("../smart-contract/testdata/fingerprint_vectors.json")
+	require.NoError(t, err)
+	defer file.Close()
+	vectors, err := cuckoofilter.ReadFingerprintVectors(file)
+	require.NoError(t, err)
+	for _, vector := range vectors {
+		fingerprint, err := core.ComputeFingerprint(vector.Algorithm, vector.Issuer, vector.CredentialID)
+		require.NoError(t, err)
+		require.Equal(t, vector.Fingerprint, fingerprint, vector.Description)
+	}
+}
+
+// TestDependencies keeps the package compiling with TinyGo, which lacks parts of reflection and cannot
+// build assembly-only or cgo dependencies
+func TestDependencies(t *testing.T) {
+	allowed := map[string]bool{
+		"bytes":                          true,
+		"crypto/sha256":                  true,
+		"encoding/hex":                   true,
+		"encoding/json":                  true,
+		"errors":                         true,
+		"fmt":                            true,
+		"sort":                           true,
+		"strings":                        true,
+		"github.com/dgryski/go-metro":    true,
+		"golang.org/x/text/unicode/norm": true,
+	}
+	pkg, err := build.Default.ImportDir(".", 0)
+	require.NoError(t, err)
+	for _, path := range pkg.Imports {
+		require.True(t, allowed[path], "%s is not an allowed dependency of the core package", path)
+	}
+	require.Empty(t, pkg.CgoFiles)
+	require.Empty(t, pkg.SFiles)
+}
+
+func TestMerkleProof_OddLeafCounts(t *testing.T) {
+	for count := 1; count <= 9; count++ {
+		var leaves [][]byte
+		for i := 0; i < count; i++ {
+			leaves = append(leaves, core.BucketLeaf(uint(i), [][]byte{{byte(i)}}))
+		}
+		root := core.MerkleRoot(leaves)
+		for index := uint(0); index < uint(count); index++ {
+			siblings := core.MerkleProof(leaves, index)
+			require.True(t, core.VerifyMerkleProof(leaves[index], index, uint(count), siblings, root), "leaf %d of %d", index, count)
+			require.False(t, core.VerifyMerkleProof(leaves[(index+1)%uint(count)], index, uint(count), siblings, root) && count > 1)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrCorruptFilter is returned when a filter snapshot is structurally broken
+var ErrCorruptFilter = errors.New("corrupt filter state")
+
+// Filter is a read-only cuckoo filter parsed from a snapshot in the ledger format, as exported by
+// the registry and published by the snapshot package
+type Filter struct {
+	Buckets         [][][]byte // Slots of every bucket, nil or empty when free
+	Count           uint
+	BucketIndexMask uint
+	FingerprintSize uint // Bytes per fingerprint, 0 in states saved before it was configurable
+	SemiSorted      bool
+}
+
+// filterJSON is the ledger format of a filter, with the buckets either serialized or packed
+type filterJSON struct {
+	Count             uint
+	BucketIndexMask   uint
+	FingerprintSize   uint
+	SemiSorted        bool
+	SerializedBuckets [][][]byte
+	PackedBuckets     []byte
+}
+
+// ParseFilter decodes and validates a filter snapshot
+func ParseFilter(data []byte) (*Filter, error) {
+	var decoded filterJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, fmt.Errorf("error decoding filter: %v", err)
+	}
+	filter := &Filter{
+		Buckets:         decoded.SerializedBuckets,
+		Count:           decoded.Count,
+		BucketIndexMask: decoded.BucketIndexMask,
+		FingerprintSize: decoded.FingerprintSize,
+		SemiSorted:      decoded.SemiSorted,
+	}
+	if filter.SemiSorted {
+		buckets, err := UnpackBuckets(decoded.PackedBuckets, uint64(filter.BucketIndexMask)+1, filter.fingerprintSize())
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorruptFilter, err)
+		}
+		filter.Buckets = buckets
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// fingerprintSize returns the bytes per fingerprint of the filter
+func (f *Filter) fingerprintSize() uint {
+	if f.FingerprintSize == 0 {
+		return MaxFingerprintSize
+	}
+	return f.FingerprintSize
+}
+
+// Validate checks that the filter is safe to query: one bucket per index of the bucket index mask,
+// fingerprints of the filter's fingerprint size and a count that fits the slots. An empty filter
+// without buckets is valid.
+func (f *Filter) Validate() error {
+	if len(f.Buckets) == 0 {
+		if f.Count != 0 {
+			return fmt.Errorf("%w: count %d without buckets", ErrCorruptFilter, f.Count)
+		}
+		return nil
+	}
+	if f.FingerprintSize > MaxFingerprintSize {
+		return fmt.Errorf("%w: fingerprint size %d exceeds %d bytes", ErrCorruptFilter, f.FingerprintSize, MaxFingerprintSize)
+	}
+	if uint64(f.BucketIndexMask)+1 != uint64(len(f.Buckets)) || f.BucketIndexMask&(f.BucketIndexMask+1) != 0 {
+		return fmt.Errorf("%w: %d buckets do not match bucket index mask %#x", ErrCorruptFilter, len(f.Buckets), f.BucketIndexMask)
+	}
+	slots := uint(0)
+	for i, b := range f.Buckets {
+		if f.SemiSorted && len(b) > SemiSortedBucketSize {
+			return fmt.Errorf("%w: semi-sorted bucket %d has %d slots", ErrCorruptFilter, i, len(b))
+		}
+		slots += uint(len(b))
+		for j, fp := range b {
+			if len(fp) != 0 && uint(len(fp)) != f.fingerprintSize() {
+				return fmt.Errorf("%w: fingerprint %d of bucket %d has %d bytes", ErrCorruptFilter, j, i, len(fp))
+			}
+		}
+	}
+	if f.Count > slots {
+		return fmt.Errorf("%w: count %d exceeds the %d slots", ErrCorruptFilter, f.Count, slots)
+	}
+	return nil
+}
+
+// Lookup reports whether data, e.g. a credential fingerprint from ComputeFingerprint, is in the filter
+func (f *Filter) Lookup(data []byte) bool {
+	if len(f.Buckets) == 0 {
+		return false
+	}
+	i1, fp := IndexAndFingerprint(data, f.BucketIndexMask, f.fingerprintSize())
+	i2 := AltIndex(fp, i1, f.BucketIndexMask)
+	return f.contains(i1, fp) || f.contains(i2, fp)
+}
+
+// IsRevoked computes the fingerprint of a credential and looks it up
+func (f *Filter) IsRevoked(algorithm string, issuer string, credentialID string) (bool, error) {
+	fingerprint, err := ComputeFingerprint(algorithm, issuer, credentialID)
+	if err != nil {
+		return false, err
+	}
+	return f.Lookup([]byte(fingerprint)), nil
+}
+
+func (f *Filter) contains(index uint, fp []byte) bool {
+	if index >= uint(len(f.Buckets)) {
+		return false
+	}
+	for _, slot := range f.Buckets[index] {
+		if len(slot) != 0 && bytes.Equal(slot, fp) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// FingerprintV1 identifies version 1 of the credential fingerprint derivation:
+//
+//  1. Trim leading and trailing whitespace from the issuer DID and the credential id
+//     and normalize both to Unicode NFC.
+//  2. Hash issuer + "\n" + id, UTF-8 encoded, with SHA-256.
+//  3. Keep the first 16 bytes of the digest.
+//  4. Encode them as 32 lowercase hexadecimal characters.
+//
+// Wallets in other languages must follow these steps exactly and check themselves
+// against smart-contract/testdata/fingerprint_vectors.json.
+const FingerprintV1 = "cm-fingerprint-v1"
+
+// FingerprintV1Length is the number of digest bytes kept by FingerprintV1
+const FingerprintV1Length = 16
+
+// ComputeFingerprint derives the registry fingerprint of a credential with the given algorithm
+func ComputeFingerprint(algorithm string, issuer string, credentialID string) (string, error) {
+	switch algorithm {
+	case FingerprintV1:
+		data := NormalizeFingerprintInput(issuer) + "\n" + NormalizeFingerprintInput(credentialID)
+		hash := sha256.Sum256([]byte(data))
+		return hex.EncodeToString(hash[:FingerprintV1Length]), nil
+	default:
+		return "", fmt.Errorf("unknown fingerprint algorithm %q", algorithm)
+	}
+}
+
+// NormalizeFingerprintInput trims and NFC-normalizes an issuer DID or credential id, as step 1 of FingerprintV1
+func NormalizeFingerprintInput(value string) string {
+	return norm.NFC.String(strings.TrimSpace(value))
+}
@@ -0,0 +1,196 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Domain separation prefixes of the Merkle tree over filter buckets
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// ErrInvalidInclusionProof is returned when an inclusion proof does not lead to its Merkle root
+var ErrInvalidInclusionProof = errors.New("inclusion proof does not match the Merkle root")
+
+// BucketLeaf returns the Merkle leaf hash of the bucket at index holding fingerprints. Empty slots are
+// skipped and the fingerprints are hashed in sorted order, so relocating a fingerprint within its
+// bucket does not change the hash.
+func BucketLeaf(index uint, fingerprints [][]byte) []byte {
+	var present [][]byte
+	for _, fp := range fingerprints {
+		if len(fp) > 0 {
+			present = append(present, fp)
+		}
+	}
+	sort.Slice(present, func(i, j int) bool { return bytes.Compare(present[i], present[j]) < 0 })
+
+	hash := sha256.New()
+	var header [9]byte
+	header[0] = merkleLeafPrefix
+	for i := 0; i < 8; i++ {
+		header[8-i] = byte(uint64(index) >> (8 * i))
+	}
+	hash.Write(header[:])
+	for _, fp := range present {
+		hash.Write([]byte{byte(len(fp))})
+		hash.Write(fp)
+	}
+	return hash.Sum(nil)
+}
+
+// MerkleRoot returns the root of the Merkle tree over leaves. A node without a sibling, the last one of
+// a level with an odd number of nodes, is carried up unchanged.
+func MerkleRoot(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		empty := sha256.Sum256(nil)
+		return empty[:]
+	}
+	level := leaves
+	for len(level) > 1 {
+		level = merkleParents(level)
+	}
+	return level[0]
+}
+
+// MerkleProof returns the sibling hashes on the path from the leaf at index to the root, bottom up
+func MerkleProof(leaves [][]byte, index uint) [][]byte {
+	var siblings [][]byte
+	level := leaves
+	for len(level) > 1 {
+		if sibling := index ^ 1; sibling < uint(len(level)) {
+			siblings = append(siblings, level[sibling])
+		}
+		level = merkleParents(level)
+		index /= 2
+	}
+	return siblings
+}
+
+// VerifyMerkleProof checks that the leaf at index of a tree with leafCount leaves leads to root
+func VerifyMerkleProof(leaf []byte, index uint, leafCount uint, siblings [][]byte, root []byte) bool {
+	if index >= leafCount {
+		return false
+	}
+	hash := leaf
+	for width := leafCount; width > 1; width = (width + 1) / 2 {
+		if sibling := index ^ 1; sibling < width {
+			if len(siblings) == 0 {
+				return false
+			}
+			if index%2 == 0 {
+				hash = merkleNode(hash, siblings[0])
+			} else {
+				hash = merkleNode(siblings[0], hash)
+			}
+			siblings = siblings[1:]
+		}
+		index /= 2
+	}
+	return len(siblings) == 0 && bytes.Equal(hash, root)
+}
+
+func merkleParents(level [][]byte) [][]byte {
+	parents := make([][]byte, 0, (len(level)+1)/2)
+	for i := 0; i < len(level); i += 2 {
+		if i+1 == len(level) {
+			parents = append(parents, level[i])
+			continue
+		}
+		parents = append(parents, merkleNode(level[i], level[i+1]))
+	}
+	return parents
+}
+
+func merkleNode(left []byte, right []byte) []byte {
+	hash := sha256.New()
+	hash.Write([]byte{merkleNodePrefix})
+	hash.Write(left)
+	hash.Write(right)
+	return hash.Sum(nil)
+}
+
+// InclusionProof proves against a filter's Merkle root whether a credential is revoked. It holds both
+// candidate buckets of the credential's fingerprint, so it proves absence as well as presence.
+type InclusionProof struct {
+	Root            string        `json:"root"`   // Hex encoded Merkle root committed with the filter state
+	Leaves          uint          `json:"leaves"` // Number of buckets of the filter
+	BucketIndexMask uint          `json:"bucketIndexMask"`
+	FingerprintSize uint          `json:"fingerprintSize"`
+	Buckets         []BucketProof `json:"buckets"` // The primary bucket, then the alternate one if it differs
+}
+
+// BucketProof holds the contents of a bucket and its Merkle path
+type BucketProof struct {
+	Index        uint     `json:"index"`
+	Fingerprints []string `json:"fingerprints"` // Hex encoded fingerprints, sorted, without empty slots
+	Siblings     []string `json:"siblings"`     // Hex encoded sibling hashes, bottom up
+}
+
+// Verify checks the proof for data, e.g. a credential fingerprint, and reports whether the filter holds
+// it. It fails with ErrInvalidInclusionProof if the proof does not cover both candidate buckets of data
+// or does not lead to the root; the caller must compare Root with a root it trusts.
+func (p *InclusionProof) Verify(data []byte) (bool, error) {
+	i1, fp := IndexAndFingerprint(data, p.BucketIndexMask, p.FingerprintSize)
+	i2 := AltIndex(fp, i1, p.BucketIndexMask)
+	expected := []uint{i1}
+	if i2 != i1 {
+		expected = append(expected, i2)
+	}
+	if len(p.Buckets) != len(expected) {
+		return false, fmt.Errorf("%w: proof holds %d buckets, expected %d", ErrInvalidInclusionProof, len(p.Buckets), len(expected))
+	}
+	root, err := hex.DecodeString(p.Root)
+	if err != nil {
+		return false, fmt.Errorf("%w: error decoding root: %v", ErrInvalidInclusionProof, err)
+	}
+
+	found := false
+	for i, bucket := range p.Buckets {
+		if bucket.Index != expected[i] {
+			return false, fmt.Errorf("%w: bucket %d is not a candidate bucket", ErrInvalidInclusionProof, bucket.Index)
+		}
+		fingerprints, err := decodeHexList(bucket.Fingerprints)
+		if err != nil {
+			return false, err
+		}
+		siblings, err := decodeHexList(bucket.Siblings)
+		if err != nil {
+			return false, err
+		}
+		if !VerifyMerkleProof(BucketLeaf(bucket.Index, fingerprints), bucket.Index, p.Leaves, siblings, root) {
+			return false, ErrInvalidInclusionProof
+		}
+		for _, stored := range fingerprints {
+			found = found || bytes.Equal(stored, fp)
+		}
+	}
+	return found, nil
+}
+
+func decodeHexList(encoded []string) ([][]byte, error) {
+	decoded := make([][]byte, len(encoded))
+	for i, value := range encoded {
+		raw, err := hex.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: error decoding %q: %v", ErrInvalidInclusionProof, value, err)
+		}
+		decoded[i] = raw
+	}
+	return decoded, nil
+}
+
+// MerkleRoot returns the Merkle root over the buckets of the filter, as committed by the registry
+// with every write of the filter state
+func (f *Filter) MerkleRoot() []byte {
+	leaves := make([][]byte, len(f.Buckets))
+	for i, b := range f.Buckets {
+		leaves[i] = BucketLeaf(uint(i), b)
+	}
+	return MerkleRoot(leaves)
+}
@@ -0,0 +1,171 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Semi-sorted buckets, from Fan et al., "Cuckoo Filter: Practically Better Than Bloom". The order of the
+// fingerprints in a bucket does not matter, so a bucket is stored sorted: the high 4 bits of its four
+// fingerprints then form a non-decreasing sequence, and there are far fewer of those than 16^4. Each
+// bucket is packed as the index of its sequence, with empty slots as a 17th symbol, in 13 bits, followed
+// by the low bits of every stored fingerprint. Packed buckets take a fraction of the space of the JSON
+// bucket arrays, and a full bucket takes 3 bits less than its raw fingerprints.
+
+// SemiSortedBucketSize is the bucket size filters with semi-sorted buckets must have
+const SemiSortedBucketSize = 4
+
+const (
+	semiSortedEmpty     = 16 // Symbol of an empty slot, after the 16 values of the high 4 bits
+	semiSortedIndexBits = 13 // Bits of a sequence index, there are 4845 sequences
+)
+
+// semiSortedSequences lists the non-decreasing sequences of four symbols, semiSortedIndex maps them back
+var semiSortedSequences, semiSortedIndex = buildSemiSortedTables()
+
+func buildSemiSortedTables() ([][SemiSortedBucketSize]uint8, map[[SemiSortedBucketSize]uint8]uint64) {
+	var sequences [][SemiSortedBucketSize]uint8
+	index := make(map[[SemiSortedBucketSize]uint8]uint64)
+	for a := uint8(0); a <= semiSortedEmpty; a++ {
+		for b := a; b <= semiSortedEmpty; b++ {
+			for c := b; c <= semiSortedEmpty; c++ {
+				for d := c; d <= semiSortedEmpty; d++ {
+					sequence := [SemiSortedBucketSize]uint8{a, b, c, d}
+					index[sequence] = uint64(len(sequences))
+					sequences = append(sequences, sequence)
+				}
+			}
+		}
+	}
+	return sequences, index
+}
+
+// PackBuckets encodes buckets of at most SemiSortedBucketSize fingerprints of fingerprintSize bytes.
+// Empty slots are nil or empty.
+func PackBuckets(buckets [][][]byte, fingerprintSize uint) ([]byte, error) {
+	lowBits := 8*fingerprintSize - 4
+	w := &bitWriter{}
+	for i, b := range buckets {
+		var values []uint64
+		for _, fp := range b {
+			if len(fp) == 0 {
+				continue
+			}
+			if uint(len(fp)) != fingerprintSize {
+				return nil, fmt.Errorf("cannot pack fingerprint of %d bytes in bucket %d", len(fp), i)
+			}
+			values = append(values, fingerprintValue(fp))
+		}
+		if len(b) > SemiSortedBucketSize {
+			return nil, fmt.Errorf("cannot pack bucket %d of %d slots", i, len(b))
+		}
+		sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+		sequence := [SemiSortedBucketSize]uint8{semiSortedEmpty, semiSortedEmpty, semiSortedEmpty, semiSortedEmpty}
+		for j, value := range values {
+			sequence[j] = uint8(value >> lowBits)
+		}
+		w.write(semiSortedIndex[sequence], semiSortedIndexBits)
+		for _, value := range values {
+			w.write(value, lowBits)
+		}
+	}
+	return w.bytes(), nil
+}
+
+// UnpackBuckets decodes numBuckets buckets written by PackBuckets, each of SemiSortedBucketSize slots
+// with nil for the empty ones
+func UnpackBuckets(packed []byte, numBuckets uint64, fingerprintSize uint) ([][][]byte, error) {
+	if fingerprintSize == 0 || fingerprintSize > MaxFingerprintSize {
+		return nil, fmt.Errorf("cannot unpack fingerprints of %d bytes", fingerprintSize)
+	}
+	lowBits := 8*fingerprintSize - 4
+	r := &bitReader{data: packed}
+	var buckets [][][]byte
+	for i := uint64(0); i < numBuckets; i++ {
+		index, err := r.read(semiSortedIndexBits)
+		if err != nil {
+			return nil, err
+		}
+		if index >= uint64(len(semiSortedSequences)) {
+			return nil, fmt.Errorf("invalid sequence index %d in bucket %d", index, i)
+		}
+		b := make([][]byte, SemiSortedBucketSize)
+		for j, high := range semiSortedSequences[index] {
+			if high == semiSortedEmpty {
+				break
+			}
+			low, err := r.read(lowBits)
+			if err != nil {
+				return nil, err
+			}
+			b[j] = fingerprintBytes(uint64(high)<<lowBits|low, fingerprintSize)
+		}
+		buckets = append(buckets, b)
+	}
+	return buckets, nil
+}
+
+// fingerprintValue reads a fingerprint as a big-endian number, so its high 4 bits are those of its first byte
+func fingerprintValue(fp []byte) uint64 {
+	value := uint64(0)
+	for _, b := range fp {
+		value = value<<8 | uint64(b)
+	}
+	return value
+}
+
+// fingerprintBytes is the inverse of fingerprintValue
+func fingerprintBytes(value uint64, fingerprintSize uint) []byte {
+	fp := make([]byte, fingerprintSize)
+	for i := int(fingerprintSize) - 1; i >= 0; i-- {
+		fp[i] = byte(value)
+		value >>= 8
+	}
+	return fp
+}
+
+// errPackedBucketsTruncated is returned when packed buckets end before all buckets are read
+var errPackedBucketsTruncated = errors.New("packed buckets are truncated")
+
+// bitWriter appends values most significant bit first
+type bitWriter struct {
+	data  []byte
+	nbits uint
+}
+
+func (w *bitWriter) write(value uint64, bits uint) {
+	for i := int(bits) - 1; i >= 0; i-- {
+		if w.nbits%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		if value>>uint(i)&1 == 1 {
+			w.data[len(w.data)-1] |= 0x80 >> (w.nbits % 8)
+		}
+		w.nbits++
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	return w.data
+}
+
+// bitReader reads values written by bitWriter
+type bitReader struct {
+	data []byte
+	pos  uint
+}
+
+func (r *bitReader) read(bits uint) (uint64, error) {
+	if uint64(r.pos)+uint64(bits) > 8*uint64(len(r.data)) {
+		return 0, errPackedBucketsTruncated
+	}
+	value := uint64(0)
+	for i := uint(0); i < bits; i++ {
+		bit := r.data[r.pos/8] >> (7 - r.pos%8) & 1
+		value = value<<1 | uint64(bit)
+		r.pos++
+	}
+	return value, nil
+}
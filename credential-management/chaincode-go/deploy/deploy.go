@@ -0,0 +1,327 @@
+// Package deploy installs, approves and commits the chaincode on a channel through the _lifecycle system
+// chaincode of the peers, the steps of peer lifecycle chaincode install, approveformyorg and commit.
+// Integration runs and the revocctl deploy command use it instead of the test network's shell scripts.
+//
+// Every step is idempotent: packages already installed on a peer, definitions the organization already
+// approved and definitions already committed are left as they are, so a failed deployment is rerun as a whole.
+package deploy
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-protos-go/peer/lifecycle"
+)
+
+// DefaultPollInterval is how often a Deployer checks whether approvals and commits reached the ledger
+const DefaultPollInterval = time.Second
+
+// Org is an organization of the channel with its admin identity and its peers
+type Org struct {
+	Signer *Signer
+	Peers  []peer.EndorserClient // The package is installed on every peer, the first one endorses
+}
+
+// Orderer submits transactions to the ordering service, see BroadcastClient
+type Orderer interface {
+	Broadcast(ctx context.Context, envelope *common.Envelope) error
+}
+
+// Definition is a chaincode definition to approve and commit
+type Definition struct {
+	Name                string
+	Version             string
+	Sequence            int64
+	InitRequired        bool
+	ValidationParameter []byte                        // Marshaled application policy, nil for the channel's default endorsement policy
+	Collections         *peer.CollectionConfigPackage // Private data collections, nil for none
+}
+
+// Deployer deploys a chaincode package on a channel
+type Deployer struct {
+	ChannelID    string
+	Orgs         []*Org
+	Orderer      Orderer
+	PollInterval time.Duration // Optional, defaults to DefaultPollInterval
+}
+
+// Deploy installs the package on the peers of every organization, approves the definition for every
+// organization and commits it once all approvals are on the ledger. It returns the package ID.
+func (d *Deployer) Deploy(ctx context.Context, chaincodePackage []byte, definition Definition) (string, error) {
+	if len(d.Orgs) == 0 {
+		return "", errors.New("no organizations to deploy to")
+	}
+	packageID, err := PackageID(chaincodePackage)
+	if err != nil {
+		return "", err
+	}
+	for _, org := range d.Orgs {
+		if err := d.Install(ctx, org, chaincodePackage); err != nil {
+			return "", err
+		}
+	}
+	committed, err := d.Committed(ctx, definition)
+	if err != nil {
+		return "", err
+	}
+	if committed {
+		return packageID, nil
+	}
+	for _, org := range d.Orgs {
+		if err := d.Approve(ctx, org, definition, packageID); err != nil {
+			return "", err
+		}
+	}
+	if err := d.Commit(ctx, definition); err != nil {
+		return "", err
+	}
+	return packageID, nil
+}
+
+// Install installs a chaincode package on every peer of an organization that does not have it yet
+func (d *Deployer) Install(ctx context.Context, org *Org, chaincodePackage []byte) error {
+	packageID, err := PackageID(chaincodePackage)
+	if err != nil {
+		return err
+	}
+	for i, endorser := range org.Peers {
+		installed, err := installedPackages(ctx, org.Signer, endorser)
+		if err != nil {
+			return fmt.Errorf("error querying installed chaincodes on peer %d of %s: %v", i, org.Signer.MSPID, err)
+		}
+		if installed[packageID] {
+			continue
+		}
+		p, err := newProposal(org.Signer, "", installFunction, &lifecycle.InstallChaincodeArgs{ChaincodeInstallPackage: chaincodePackage})
+		if err != nil {
+			return err
+		}
+		var result lifecycle.InstallChaincodeResult
+		if err := evaluate(ctx, endorser, p, &result); err != nil {
+			return fmt.Errorf("error installing %s on peer %d of %s: %v", packageID, i, org.Signer.MSPID, err)
+		}
+		if result.PackageId != packageID {
+			return fmt.Errorf("peer %d of %s installed package %s, expected %s", i, org.Signer.MSPID, result.PackageId, packageID)
+		}
+	}
+	return nil
+}
+
+// Approve approves a definition running the installed package for an organization and waits until the
+// approval is on the ledger. Definitions the organization already approved are not approved again.
+func (d *Deployer) Approve(ctx context.Context, org *Org, definition Definition, packageID string) error {
+	if len(org.Peers) == 0 {
+		return fmt.Errorf("%s has no peers", org.Signer.MSPID)
+	}
+	approvals, err := d.approvals(ctx, org, definition)
+	if err != nil {
+		return err
+	}
+	if approvals[org.Signer.MSPID] {
+		return nil
+	}
+
+	p, err := newProposal(org.Signer, d.ChannelID, approveFunction, &lifecycle.ApproveChaincodeDefinitionForMyOrgArgs{
+		Sequence:            definition.Sequence,
+		Name:                definition.Name,
+		Version:             definition.Version,
+		ValidationParameter: definition.ValidationParameter,
+		Collections:         definition.Collections,
+		InitRequired:        definition.InitRequired,
+		Source: &lifecycle.ChaincodeSource{Type: &lifecycle.ChaincodeSource_LocalPackage{
+			LocalPackage: &lifecycle.ChaincodeSource_Local{PackageId: packageID},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	if err := d.submit(ctx, org.Signer, p, org.Peers[:1]); err != nil {
+		return fmt.Errorf("error approving %s for %s: %v", definition.Name, org.Signer.MSPID, err)
+	}
+	return d.poll(ctx, func() (bool, error) {
+		approvals, err := d.approvals(ctx, org, definition)
+		return approvals[org.Signer.MSPID], err
+	})
+}
+
+// Commit commits a definition approved by the organizations, endorsed by the first peer of every
+// organization, and waits until it is on the ledger
+func (d *Deployer) Commit(ctx context.Context, definition Definition) error {
+	if len(d.Orgs) == 0 {
+		return errors.New("no organizations to commit with")
+	}
+	committed, err := d.Committed(ctx, definition)
+	if err != nil || committed {
+		return err
+	}
+	endorsers := make([]peer.EndorserClient, 0, len(d.Orgs))
+	for _, org := range d.Orgs {
+		if len(org.Peers) == 0 {
+			return fmt.Errorf("%s has no peers", org.Signer.MSPID)
+		}
+		endorsers = append(endorsers, org.Peers[0])
+	}
+	signer := d.Orgs[0].Signer
+	p, err := newProposal(signer, d.ChannelID, commitFunction, &lifecycle.CommitChaincodeDefinitionArgs{
+		Sequence:            definition.Sequence,
+		Name:                definition.Name,
+		Version:             definition.Version,
+		ValidationParameter: definition.ValidationParameter,
+		Collections:         definition.Collections,
+		InitRequired:        definition.InitRequired,
+	})
+	if err != nil {
+		return err
+	}
+	if err := d.submit(ctx, signer, p, endorsers); err != nil {
+		return fmt.Errorf("error committing %s: %v", definition.Name, err)
+	}
+	return d.poll(ctx, func() (bool, error) {
+		return d.Committed(ctx, definition)
+	})
+}
+
+// Committed reports whether the channel runs the definition's sequence, or a later one, of the chaincode
+func (d *Deployer) Committed(ctx context.Context, definition Definition) (bool, error) {
+	org := d.Orgs[0]
+	if len(org.Peers) == 0 {
+		return false, fmt.Errorf("%s has no peers", org.Signer.MSPID)
+	}
+	p, err := newProposal(org.Signer, d.ChannelID, queryDefinitionFunction, &lifecycle.QueryChaincodeDefinitionArgs{Name: definition.Name})
+	if err != nil {
+		return false, err
+	}
+	response, err := org.Peers[0].ProcessProposal(ctx, p.signed)
+	if err != nil {
+		return false, fmt.Errorf("error querying the definition of %s: %v", definition.Name, err)
+	}
+	// The peer fails the query with a 404 status while no definition is committed
+	if response.Response != nil && response.Response.Status == 404 {
+		return false, nil
+	}
+	var result lifecycle.QueryChaincodeDefinitionResult
+	if err := decodeResponse(response, &result); err != nil {
+		return false, fmt.Errorf("error querying the definition of %s: %v", definition.Name, err)
+	}
+	return result.Sequence >= definition.Sequence, nil
+}
+
+// approvals returns which organizations approved a definition, as seen by a peer of org
+func (d *Deployer) approvals(ctx context.Context, org *Org, definition Definition) (map[string]bool, error) {
+	p, err := newProposal(org.Signer, d.ChannelID, checkReadinessFunction, &lifecycle.CheckCommitReadinessArgs{
+		Sequence:            definition.Sequence,
+		Name:                definition.Name,
+		Version:             definition.Version,
+		ValidationParameter: definition.ValidationParameter,
+		Collections:         definition.Collections,
+		InitRequired:        definition.InitRequired,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result lifecycle.CheckCommitReadinessResult
+	if err := evaluate(ctx, org.Peers[0], p, &result); err != nil {
+		return nil, fmt.Errorf("error checking the approvals of %s: %v", definition.Name, err)
+	}
+	return result.Approvals, nil
+}
+
+// submit endorses a proposal on the endorsers and sends the transaction to the orderer
+func (d *Deployer) submit(ctx context.Context, signer *Signer, p *proposal, endorsers []peer.EndorserClient) error {
+	if d.Orderer == nil {
+		return errors.New("no orderer to submit to")
+	}
+	responses := make([]*peer.ProposalResponse, 0, len(endorsers))
+	for _, endorser := range endorsers {
+		response, err := endorse(ctx, endorser, p)
+		if err != nil {
+			return err
+		}
+		responses = append(responses, response)
+	}
+	envelope, err := newTransaction(signer, p, responses)
+	if err != nil {
+		return err
+	}
+	return d.Orderer.Broadcast(ctx, envelope)
+}
+
+// poll calls done until it reports true, an error occurs or the context ends
+func (d *Deployer) poll(ctx context.Context, done func() (bool, error)) error {
+	interval := d.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	for {
+		ok, err := done()
+		if err != nil || ok {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// installedPackages returns the IDs of the packages installed on a peer
+func installedPackages(ctx context.Context, signer *Signer, endorser peer.EndorserClient) (map[string]bool, error) {
+	p, err := newProposal(signer, "", queryInstalledFunction, &lifecycle.QueryInstalledChaincodesArgs{})
+	if err != nil {
+		return nil, err
+	}
+	var result lifecycle.QueryInstalledChaincodesResult
+	if err := evaluate(ctx, endorser, p, &result); err != nil {
+		return nil, err
+	}
+	installed := make(map[string]bool, len(result.InstalledChaincodes))
+	for _, chaincode := range result.InstalledChaincodes {
+		installed[chaincode.PackageId] = true
+	}
+	return installed, nil
+}
+
+// PackageID returns the ID peers assign to an installed chaincode package, <label>:<hex SHA-256 of the package>,
+// with the label read from the package's metadata.json
+func PackageID(chaincodePackage []byte) (string, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(chaincodePackage))
+	if err != nil {
+		return "", fmt.Errorf("error reading chaincode package: %v", err)
+	}
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return "", errors.New("chaincode package has no metadata.json")
+		}
+		if err != nil {
+			return "", fmt.Errorf("error reading chaincode package: %v", err)
+		}
+		if header.Name != "metadata.json" {
+			continue
+		}
+		var metadata struct {
+			Label string `json:"label"`
+		}
+		if err := json.NewDecoder(reader).Decode(&metadata); err != nil {
+			return "", fmt.Errorf("error decoding metadata.json: %v", err)
+		}
+		if metadata.Label == "" {
+			return "", errors.New("chaincode package has no label")
+		}
+		hash := sha256.Sum256(chaincodePackage)
+		return metadata.Label + ":" + hex.EncodeToString(hash[:]), nil
+	}
+}
@@ -0,0 +1,314 @@
+package deploy_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-protos-go/peer/lifecycle"
+	"github.com/pherbke/credential-management/chaincode-go/deploy"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// channel is the lifecycle state of a channel shared by the fake peers and orderer
+type channel struct {
+	mu           sync.Mutex
+	approvals    map[string]string // Package ID approved per MSP ID
+	sequence     int64
+	transactions []string // Lifecycle functions ordered
+	endorsements []int    // Endorsements per ordered transaction
+}
+
+// fakePeer answers _lifecycle proposals like a peer of the channel
+type fakePeer struct {
+	t         *testing.T
+	name      string
+	channel   *channel
+	installed map[string]bool
+	installs  int
+}
+
+func (p *fakePeer) ProcessProposal(_ context.Context, signed *peer.SignedProposal, _ ...grpc.CallOption) (*peer.ProposalResponse, error) {
+	creator, function, args := decodeProposal(p.t, signed.ProposalBytes, signed.Signature)
+	p.channel.mu.Lock()
+	defer p.channel.mu.Unlock()
+
+	var result proto.Message
+	switch function {
+	case "QueryInstalledChaincodes":
+		installed := &lifecycle.QueryInstalledChaincodesResult{}
+		for packageID := range p.installed {
+			installed.InstalledChaincodes = append(installed.InstalledChaincodes, &lifecycle.QueryInstalledChaincodesResult_InstalledChaincode{PackageId: packageID})
+		}
+		result = installed
+	case "InstallChaincode":
+		var installArgs lifecycle.InstallChaincodeArgs
+		require.NoError(p.t, proto.Unmarshal(args, &installArgs))
+		packageID, err := deploy.PackageID(installArgs.ChaincodeInstallPackage)
+		require.NoError(p.t, err)
+		p.installed[packageID] = true
+		p.installs++
+		result = &lifecycle.InstallChaincodeResult{PackageId: packageID}
+	case "CheckCommitReadiness":
+		approvals := &lifecycle.CheckCommitReadinessResult{Approvals: map[string]bool{}}
+		for _, mspID := range []string{"Org1MSP", "Org2MSP"} {
+			_, approvals.Approvals[mspID] = p.channel.approvals[mspID]
+		}
+		result = approvals
+	case "QueryChaincodeDefinition":
+		if p.channel.sequence == 0 {
+			return &peer.ProposalResponse{Response: &peer.Response{Status: 404, Message: "namespace credential-management is not defined"}}, nil
+		}
+		result = &lifecycle.QueryChaincodeDefinitionResult{Sequence: p.channel.sequence, Version: "1.0"}
+	case "ApproveChaincodeDefinitionForMyOrg", "CommitChaincodeDefinition":
+		result = &lifecycle.ApproveChaincodeDefinitionForMyOrgResult{}
+	default:
+		p.t.Fatalf("unexpected lifecycle function %s", function)
+	}
+	payload, err := proto.Marshal(result)
+	require.NoError(p.t, err)
+	// Every peer simulates a transaction to the same result
+	simulation := sha256.Sum256(append([]byte(function), args...))
+	return &peer.ProposalResponse{
+		Response:    &peer.Response{Status: 200, Payload: payload},
+		Payload:     simulation[:],
+		Endorsement: &peer.Endorsement{Endorser: []byte(p.name + "@" + creator)},
+	}, nil
+}
+
+// fakeOrderer applies lifecycle transactions to the channel once they are ordered
+type fakeOrderer struct {
+	t       *testing.T
+	channel *channel
+}
+
+func (o *fakeOrderer) Broadcast(_ context.Context, envelope *common.Envelope) error {
+	var payload common.Payload
+	require.NoError(o.t, proto.Unmarshal(envelope.Payload, &payload))
+	var signatureHeader common.SignatureHeader
+	require.NoError(o.t, proto.Unmarshal(payload.Header.SignatureHeader, &signatureHeader))
+	verifySignature(o.t, signatureHeader.Creator, envelope.Payload, envelope.Signature)
+
+	var transaction peer.Transaction
+	require.NoError(o.t, proto.Unmarshal(payload.Data, &transaction))
+	require.Len(o.t, transaction.Actions, 1)
+	var action peer.ChaincodeActionPayload
+	require.NoError(o.t, proto.Unmarshal(transaction.Actions[0].Payload, &action))
+	var proposalPayload peer.ChaincodeProposalPayload
+	require.NoError(o.t, proto.Unmarshal(action.ChaincodeProposalPayload, &proposalPayload))
+	function, args := decodeInput(o.t, proposalPayload.Input)
+	creator := mspID(o.t, signatureHeader.Creator)
+
+	o.channel.mu.Lock()
+	defer o.channel.mu.Unlock()
+	o.channel.transactions = append(o.channel.transactions, function)
+	o.channel.endorsements = append(o.channel.endorsements, len(action.Action.Endorsements))
+	switch function {
+	case "ApproveChaincodeDefinitionForMyOrg":
+		var approveArgs lifecycle.ApproveChaincodeDefinitionForMyOrgArgs
+		require.NoError(o.t, proto.Unmarshal(args, &approveArgs))
+		o.channel.approvals[creator] = approveArgs.Source.GetLocalPackage().PackageId
+	case "CommitChaincodeDefinition":
+		var commitArgs lifecycle.CommitChaincodeDefinitionArgs
+		require.NoError(o.t, proto.Unmarshal(args, &commitArgs))
+		require.Len(o.t, o.channel.approvals, 2, "committed before every organization approved")
+		o.channel.sequence = commitArgs.Sequence
+	}
+	return nil
+}
+
+func decodeProposal(t *testing.T, proposalBytes []byte, signature []byte) (string, string, []byte) {
+	var proposal peer.Proposal
+	require.NoError(t, proto.Unmarshal(proposalBytes, &proposal))
+	var header common.Header
+	require.NoError(t, proto.Unmarshal(proposal.Header, &header))
+	var signatureHeader common.SignatureHeader
+	require.NoError(t, proto.Unmarshal(header.SignatureHeader, &signatureHeader))
+	verifySignature(t, signatureHeader.Creator, proposalBytes, signature)
+
+	var payload peer.ChaincodeProposalPayload
+	require.NoError(t, proto.Unmarshal(proposal.Payload, &payload))
+	function, args := decodeInput(t, payload.Input)
+	return mspID(t, signatureHeader.Creator), function, args
+}
+
+func decodeInput(t *testing.T, input []byte) (string, []byte) {
+	var spec peer.ChaincodeInvocationSpec
+	require.NoError(t, proto.Unmarshal(input, &spec))
+	require.Equal(t, "_lifecycle", spec.ChaincodeSpec.ChaincodeId.Name)
+	require.Len(t, spec.ChaincodeSpec.Input.Args, 2)
+	return string(spec.ChaincodeSpec.Input.Args[0]), spec.ChaincodeSpec.Input.Args[1]
+}
+
+func mspID(t *testing.T, creator []byte) string {
+	var identity msp.SerializedIdentity
+	require.NoError(t, proto.Unmarshal(creator, &identity))
+	return identity.Mspid
+}
+
+// verifySignature checks a signature against the certificate of the creator, requiring the low-S form Fabric accepts
+func verifySignature(t *testing.T, creator []byte, message []byte, signature []byte) {
+	var identity msp.SerializedIdentity
+	require.NoError(t, proto.Unmarshal(creator, &identity))
+	block, _ := pem.Decode(identity.IdBytes)
+	require.NotNil(t, block)
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	publicKey := certificate.PublicKey.(*ecdsa.PublicKey)
+
+	digest := sha256.Sum256(message)
+	require.True(t, ecdsa.VerifyASN1(publicKey, digest[:], signature))
+	var sig struct{ R, S *big.Int }
+	_, err = asn1.Unmarshal(signature, &sig)
+	require.NoError(t, err)
+	require.LessOrEqual(t, sig.S.Cmp(new(big.Int).Rsh(publicKey.Curve.Params().N, 1)), 0)
+}
+
+// writeMSP writes an MSP directory with a self-signed certificate and a PKCS #8 key, as cryptogen does
+func writeMSP(t *testing.T, name string) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: name}, NotAfter: time.Now().Add(time.Hour)}
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	dir := filepath.Join(t.TempDir(), "msp")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "signcerts"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "keystore"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "signcerts", "cert.pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate}), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "keystore", "priv_sk"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600))
+	return dir
+}
+
+// chaincodePackage returns a package with the metadata.json of ccpackage
+func chaincodePackage(t *testing.T, label string) []byte {
+	var buffer bytes.Buffer
+	gz := gzip.NewWriter(&buffer)
+	tw := tar.NewWriter(gz)
+	metadata := []byte(`{"type":"ccaas","label":"` + label + `"}`)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "metadata.json", Mode: 0644, Size: int64(len(metadata))}))
+	_, err := tw.Write(metadata)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buffer.Bytes()
+}
+
+func newDeployer(t *testing.T) (*deploy.Deployer, *channel, []*fakePeer) {
+	ch := &channel{approvals: map[string]string{}}
+	var peers []*fakePeer
+	deployer := &deploy.Deployer{ChannelID: "mychannel", Orderer: &fakeOrderer{t: t, channel: ch}, PollInterval: time.Millisecond}
+	for _, org := range []struct {
+		mspID string
+		peers []string
+	}{{"Org1MSP", []string{"peer0.org1", "peer1.org1"}}, {"Org2MSP", []string{"peer0.org2"}}} {
+		signer, err := deploy.LoadSigner(org.mspID, writeMSP(t, "Admin@"+org.mspID))
+		require.NoError(t, err)
+		deployOrg := &deploy.Org{Signer: signer}
+		for _, name := range org.peers {
+			fake := &fakePeer{t: t, name: name, channel: ch, installed: map[string]bool{}}
+			peers = append(peers, fake)
+			deployOrg.Peers = append(deployOrg.Peers, fake)
+		}
+		deployer.Orgs = append(deployer.Orgs, deployOrg)
+	}
+	return deployer, ch, peers
+}
+
+func TestPackageID(t *testing.T) {
+	pkg := chaincodePackage(t, "credential-management_1.0")
+	packageID, err := deploy.PackageID(pkg)
+	require.NoError(t, err)
+	hash := sha256.Sum256(pkg)
+	require.Equal(t, "credential-management_1.0:"+hex.EncodeToString(hash[:]), packageID)
+
+	_, err = deploy.PackageID([]byte("not a package"))
+	require.Error(t, err)
+	_, err = deploy.PackageID(chaincodePackage(t, ""))
+	require.Error(t, err)
+}
+
+func TestDeploy(t *testing.T) {
+	deployer, ch, peers := newDeployer(t)
+	pkg := chaincodePackage(t, "credential-management_1.0")
+	definition := deploy.Definition{Name: "credential-management", Version: "1.0", Sequence: 1}
+
+	packageID, err := deployer.Deploy(context.Background(), pkg, definition)
+	require.NoError(t, err)
+	expectedID, err := deploy.PackageID(pkg)
+	require.NoError(t, err)
+	require.Equal(t, expectedID, packageID)
+
+	for _, fake := range peers {
+		require.True(t, fake.installed[packageID], fake.name)
+	}
+	require.Equal(t, map[string]string{"Org1MSP": packageID, "Org2MSP": packageID}, ch.approvals)
+	require.Equal(t, int64(1), ch.sequence)
+	require.Equal(t, []string{"ApproveChaincodeDefinitionForMyOrg", "ApproveChaincodeDefinitionForMyOrg", "CommitChaincodeDefinition"}, ch.transactions)
+	// The commit is endorsed by a peer of every organization
+	require.Equal(t, []int{1, 1, 2}, ch.endorsements)
+}
+
+func TestDeploy_Rerun(t *testing.T) {
+	deployer, ch, peers := newDeployer(t)
+	pkg := chaincodePackage(t, "credential-management_1.0")
+	definition := deploy.Definition{Name: "credential-management", Version: "1.0", Sequence: 1}
+
+	// A deployment that stopped after the first approval picks up where it left off
+	require.NoError(t, deployer.Install(context.Background(), deployer.Orgs[0], pkg))
+	packageID, err := deploy.PackageID(pkg)
+	require.NoError(t, err)
+	require.NoError(t, deployer.Approve(context.Background(), deployer.Orgs[0], definition, packageID))
+
+	_, err = deployer.Deploy(context.Background(), pkg, definition)
+	require.NoError(t, err)
+	require.Equal(t, []string{"ApproveChaincodeDefinitionForMyOrg", "ApproveChaincodeDefinitionForMyOrg", "CommitChaincodeDefinition"}, ch.transactions)
+	for _, fake := range peers {
+		require.Equal(t, 1, fake.installs, fake.name)
+	}
+
+	// Once committed, deploying again changes nothing
+	_, err = deployer.Deploy(context.Background(), pkg, definition)
+	require.NoError(t, err)
+	require.Len(t, ch.transactions, 3)
+}
+
+func TestDeploy_NoOrderer(t *testing.T) {
+	deployer, _, _ := newDeployer(t)
+	deployer.Orderer = nil
+	_, err := deployer.Deploy(context.Background(), chaincodePackage(t, "credential-management_1.0"), deploy.Definition{Name: "credential-management", Version: "1.0", Sequence: 1})
+	require.ErrorContains(t, err, "no orderer")
+}
+
+func TestTestNetworkConfig(t *testing.T) {
+	config := deploy.TestNetworkConfig("test-network", "mychannel")
+	require.Len(t, config.Orgs, 2)
+	require.Equal(t, "Org2MSP", config.Orgs[1].MSPID)
+	require.Equal(t, "localhost:9051", config.Orgs[1].Peers[0].Address)
+	require.Equal(t, "peer0.org2.example.com", config.Orgs[1].Peers[0].ServerName)
+	require.Equal(t, filepath.Join("test-network", "organizations", "peerOrganizations", "org1.example.com", "users", "Admin@org1.example.com", "msp"), config.Orgs[0].MSPDir)
+	require.Equal(t, "orderer.example.com", config.Orderer.ServerName)
+}
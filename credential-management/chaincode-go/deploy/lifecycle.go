@@ -0,0 +1,193 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// lifecycleChaincode is the name of the system chaincode that manages chaincode definitions
+const lifecycleChaincode = "_lifecycle"
+
+// Functions of the _lifecycle system chaincode
+const (
+	installFunction         = "InstallChaincode"
+	queryInstalledFunction  = "QueryInstalledChaincodes"
+	approveFunction         = "ApproveChaincodeDefinitionForMyOrg"
+	checkReadinessFunction  = "CheckCommitReadiness"
+	commitFunction          = "CommitChaincodeDefinition"
+	queryDefinitionFunction = "QueryChaincodeDefinition"
+)
+
+// proposal is a signed lifecycle proposal together with the parts a transaction is assembled from
+type proposal struct {
+	txID    string
+	header  []byte
+	payload []byte
+	signed  *peer.SignedProposal
+}
+
+// newProposal creates a proposal invoking a _lifecycle function with protobuf arguments. Installing is
+// not bound to a channel, so channelID is empty for it.
+func newProposal(signer *Signer, channelID string, function string, args proto.Message) (*proposal, error) {
+	argsBytes, err := proto.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+	creator, err := signer.creator()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, 24)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("error creating nonce: %v", err)
+	}
+	txIDHash := sha256.Sum256(append(append([]byte{}, nonce...), creator...))
+	txID := hex.EncodeToString(txIDHash[:])
+
+	extension, err := proto.Marshal(&peer.ChaincodeHeaderExtension{ChaincodeId: &peer.ChaincodeID{Name: lifecycleChaincode}})
+	if err != nil {
+		return nil, err
+	}
+	channelHeader, err := proto.Marshal(&common.ChannelHeader{
+		Type:      int32(common.HeaderType_ENDORSER_TRANSACTION),
+		ChannelId: channelID,
+		TxId:      txID,
+		Timestamp: timestamppb.Now(),
+		Extension: extension,
+	})
+	if err != nil {
+		return nil, err
+	}
+	signatureHeader, err := proto.Marshal(&common.SignatureHeader{Creator: creator, Nonce: nonce})
+	if err != nil {
+		return nil, err
+	}
+	header, err := proto.Marshal(&common.Header{ChannelHeader: channelHeader, SignatureHeader: signatureHeader})
+	if err != nil {
+		return nil, err
+	}
+	input, err := proto.Marshal(&peer.ChaincodeInvocationSpec{ChaincodeSpec: &peer.ChaincodeSpec{
+		ChaincodeId: &peer.ChaincodeID{Name: lifecycleChaincode},
+		Input:       &peer.ChaincodeInput{Args: [][]byte{[]byte(function), argsBytes}},
+	}})
+	if err != nil {
+		return nil, err
+	}
+	payload, err := proto.Marshal(&peer.ChaincodeProposalPayload{Input: input})
+	if err != nil {
+		return nil, err
+	}
+	proposalBytes, err := proto.Marshal(&peer.Proposal{Header: header, Payload: payload})
+	if err != nil {
+		return nil, err
+	}
+	signature, err := signer.sign(proposalBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &proposal{
+		txID:    txID,
+		header:  header,
+		payload: payload,
+		signed:  &peer.SignedProposal{ProposalBytes: proposalBytes, Signature: signature},
+	}, nil
+}
+
+// endorse sends a proposal to a peer and returns its successful response
+func endorse(ctx context.Context, endorser peer.EndorserClient, p *proposal) (*peer.ProposalResponse, error) {
+	response, err := endorser.ProcessProposal(ctx, p.signed)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse(response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// evaluate sends a proposal to a peer and decodes the payload it returned into result
+func evaluate(ctx context.Context, endorser peer.EndorserClient, p *proposal, result proto.Message) error {
+	response, err := endorser.ProcessProposal(ctx, p.signed)
+	if err != nil {
+		return err
+	}
+	return decodeResponse(response, result)
+}
+
+// checkResponse fails unless the peer executed the proposal successfully
+func checkResponse(response *peer.ProposalResponse) error {
+	if response.Response == nil {
+		return errors.New("proposal response holds no response")
+	}
+	if response.Response.Status < 200 || response.Response.Status >= 400 {
+		return fmt.Errorf("status %d: %s", response.Response.Status, response.Response.Message)
+	}
+	return nil
+}
+
+// decodeResponse decodes the payload of a successful proposal response into result
+func decodeResponse(response *peer.ProposalResponse, result proto.Message) error {
+	if err := checkResponse(response); err != nil {
+		return err
+	}
+	if err := proto.Unmarshal(response.Response.Payload, result); err != nil {
+		return fmt.Errorf("error decoding %s result: %v", lifecycleChaincode, err)
+	}
+	return nil
+}
+
+// newTransaction assembles the signed transaction envelope of an endorsed proposal. All responses must
+// carry the same result, as the orderer only accepts one proposal response payload per action.
+func newTransaction(signer *Signer, p *proposal, responses []*peer.ProposalResponse) (*common.Envelope, error) {
+	if len(responses) == 0 {
+		return nil, errors.New("transaction has no endorsements")
+	}
+	endorsements := make([]*peer.Endorsement, 0, len(responses))
+	for _, response := range responses {
+		if !bytes.Equal(response.Payload, responses[0].Payload) {
+			return nil, errors.New("endorsing peers returned different results")
+		}
+		endorsements = append(endorsements, response.Endorsement)
+	}
+
+	actionPayload, err := proto.Marshal(&peer.ChaincodeActionPayload{
+		ChaincodeProposalPayload: p.payload,
+		Action: &peer.ChaincodeEndorsedAction{
+			ProposalResponsePayload: responses[0].Payload,
+			Endorsements:            endorsements,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var header common.Header
+	if err := proto.Unmarshal(p.header, &header); err != nil {
+		return nil, err
+	}
+	transaction, err := proto.Marshal(&peer.Transaction{Actions: []*peer.TransactionAction{{
+		Header:  header.SignatureHeader,
+		Payload: actionPayload,
+	}}})
+	if err != nil {
+		return nil, err
+	}
+	payload, err := proto.Marshal(&common.Payload{Header: &header, Data: transaction})
+	if err != nil {
+		return nil, err
+	}
+	signature, err := signer.sign(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &common.Envelope{Payload: payload, Signature: signature}, nil
+}
@@ -0,0 +1,155 @@
+package deploy
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/orderer"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// Endpoint is the TLS gRPC endpoint of a peer or orderer
+type Endpoint struct {
+	Address    string `json:"address" yaml:"address"`
+	TLSCACert  string `json:"tlsCACert" yaml:"tlsCACert"`   // PEM file of the TLS CA that issued the server certificate
+	ServerName string `json:"serverName" yaml:"serverName"` // Host name in the server certificate, e.g. peer0.org1.example.com for localhost:7051
+}
+
+// OrgConfig describes an organization of a NetworkConfig
+type OrgConfig struct {
+	MSPID  string     `json:"mspId" yaml:"mspId"`
+	MSPDir string     `json:"mspDir" yaml:"mspDir"` // MSP directory of an admin of the organization, see LoadSigner
+	Peers  []Endpoint `json:"peers" yaml:"peers"`
+}
+
+// NetworkConfig describes the channel a chaincode is deployed to
+type NetworkConfig struct {
+	ChannelID string      `json:"channel" yaml:"channel"`
+	Orgs      []OrgConfig `json:"orgs" yaml:"orgs"`
+	Orderer   Endpoint    `json:"orderer" yaml:"orderer"`
+}
+
+// TestNetworkConfig returns the configuration of the two organization channel created by
+// ./network.sh up createChannel of the test network in dir
+func TestNetworkConfig(dir string, channelID string) *NetworkConfig {
+	org := func(n int, port int) OrgConfig {
+		domain := fmt.Sprintf("org%d.example.com", n)
+		orgDir := filepath.Join(dir, "organizations", "peerOrganizations", domain)
+		return OrgConfig{
+			MSPID:  fmt.Sprintf("Org%dMSP", n),
+			MSPDir: filepath.Join(orgDir, "users", "Admin@"+domain, "msp"),
+			Peers: []Endpoint{{
+				Address:    fmt.Sprintf("localhost:%d", port),
+				TLSCACert:  filepath.Join(orgDir, "tlsca", "tlsca."+domain+"-cert.pem"),
+				ServerName: "peer0." + domain,
+			}},
+		}
+	}
+	return &NetworkConfig{
+		ChannelID: channelID,
+		Orgs:      []OrgConfig{org(1, 7051), org(2, 9051)},
+		Orderer: Endpoint{
+			Address:    "localhost:7050",
+			TLSCACert:  filepath.Join(dir, "organizations", "ordererOrganizations", "example.com", "tlsca", "tlsca.example.com-cert.pem"),
+			ServerName: "orderer.example.com",
+		},
+	}
+}
+
+// Connect loads the admin identities and dials the peers and the orderer of the network. The returned
+// function closes the connections.
+func (c *NetworkConfig) Connect(ctx context.Context) (*Deployer, func(), error) {
+	var conns []*grpc.ClientConn
+	closeAll := func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}
+	dial := func(endpoint Endpoint) (*grpc.ClientConn, error) {
+		conn, err := endpoint.Dial(ctx)
+		if err != nil {
+			return nil, err
+		}
+		conns = append(conns, conn)
+		return conn, nil
+	}
+
+	deployer := &Deployer{ChannelID: c.ChannelID}
+	for _, orgConfig := range c.Orgs {
+		signer, err := LoadSigner(orgConfig.MSPID, orgConfig.MSPDir)
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("error loading the admin of %s: %v", orgConfig.MSPID, err)
+		}
+		org := &Org{Signer: signer}
+		for _, endpoint := range orgConfig.Peers {
+			conn, err := dial(endpoint)
+			if err != nil {
+				closeAll()
+				return nil, nil, err
+			}
+			org.Peers = append(org.Peers, peer.NewEndorserClient(conn))
+		}
+		deployer.Orgs = append(deployer.Orgs, org)
+	}
+	conn, err := dial(c.Orderer)
+	if err != nil {
+		closeAll()
+		return nil, nil, err
+	}
+	deployer.Orderer = &BroadcastClient{Client: orderer.NewAtomicBroadcastClient(conn)}
+	return deployer, closeAll, nil
+}
+
+// Dial opens a TLS connection to the endpoint
+func (e Endpoint) Dial(ctx context.Context) (*grpc.ClientConn, error) {
+	caPEM, err := os.ReadFile(e.TLSCACert)
+	if err != nil {
+		return nil, fmt.Errorf("error reading TLS CA certificate of %s: %v", e.Address, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("TLS CA certificate of %s holds no certificate", e.Address)
+	}
+	tlsConfig := &tls.Config{RootCAs: pool, ServerName: e.ServerName, MinVersion: tls.VersionTLS12}
+	conn, err := grpc.DialContext(ctx, e.Address, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %v", e.Address, err)
+	}
+	return conn, nil
+}
+
+// BroadcastClient submits transactions through the AtomicBroadcast service of an orderer
+type BroadcastClient struct {
+	Client orderer.AtomicBroadcastClient
+}
+
+// Broadcast sends one transaction and waits for the orderer to accept it
+func (c *BroadcastClient) Broadcast(ctx context.Context, envelope *common.Envelope) error {
+	stream, err := c.Client.Broadcast(ctx)
+	if err != nil {
+		return fmt.Errorf("error opening broadcast stream: %v", err)
+	}
+	if err := stream.Send(envelope); err != nil {
+		return fmt.Errorf("error sending transaction: %v", err)
+	}
+	response, err := stream.Recv()
+	if err != nil {
+		return fmt.Errorf("error receiving broadcast response: %v", err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+	if response.Status != common.Status_SUCCESS {
+		return errors.New("orderer rejected the transaction: " + response.Status.String() + " " + response.Info)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package deploy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/msp"
+)
+
+// Signer is the admin identity of an organization that signs lifecycle proposals and transactions
+type Signer struct {
+	MSPID       string
+	Certificate []byte // PEM encoded enrollment certificate
+	PrivateKey  *ecdsa.PrivateKey
+}
+
+// LoadSigner reads the identity of an MSP directory as written by cryptogen or Fabric CA, e.g.
+// organizations/peerOrganizations/org1.example.com/users/Admin@org1.example.com/msp of the test network.
+// It uses the first certificate in signcerts and the first key in keystore.
+func LoadSigner(mspID string, mspDir string) (*Signer, error) {
+	certificate, err := readFirstFile(filepath.Join(mspDir, "signcerts"))
+	if err != nil {
+		return nil, fmt.Errorf("error reading signing certificate: %v", err)
+	}
+	keyPEM, err := readFirstFile(filepath.Join(mspDir, "keystore"))
+	if err != nil {
+		return nil, fmt.Errorf("error reading private key: %v", err)
+	}
+	key, err := parsePrivateKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{MSPID: mspID, Certificate: certificate, PrivateKey: key}, nil
+}
+
+func readFirstFile(dir string) ([]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			return os.ReadFile(filepath.Join(dir, entry.Name()))
+		}
+	}
+	return nil, fmt.Errorf("no file in %s", dir)
+}
+
+func parsePrivateKey(keyPEM []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("private key is not PEM encoded")
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing private key: %v", err)
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an ECDSA key")
+	}
+	return ecKey, nil
+}
+
+// creator returns the serialized identity placed in signature headers
+func (s *Signer) creator() ([]byte, error) {
+	return proto.Marshal(&msp.SerializedIdentity{Mspid: s.MSPID, IdBytes: s.Certificate})
+}
+
+// sign signs a message with a low-S ECDSA signature, the only form Fabric accepts
+func (s *Signer) sign(message []byte) ([]byte, error) {
+	digest := sha256.Sum256(message)
+	r, sigS, err := ecdsa.Sign(rand.Reader, s.PrivateKey, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("error signing: %v", err)
+	}
+	sigS = toLowS(s.PrivateKey.Curve, sigS)
+	return asn1.Marshal(struct{ R, S *big.Int }{r, sigS})
+}
+
+// toLowS returns the S value of an ECDSA signature that is at most half the curve order
+func toLowS(curve elliptic.Curve, sigS *big.Int) *big.Int {
+	order := curve.Params().N
+	if sigS.Cmp(new(big.Int).Rsh(order, 1)) > 0 {
+		return new(big.Int).Sub(order, sigS)
+	}
+	return sigS
+}
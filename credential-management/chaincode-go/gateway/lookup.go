@@ -0,0 +1,52 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/pherbke/credential-management/chaincode-go/client"
+)
+
+// MaxLookupFingerprints is the maximum number of fingerprints of one lookup request
+const MaxLookupFingerprints = 1000
+
+// LookupResponse is the body of a lookup response
+type LookupResponse struct {
+	Namespace string          `json:"namespace,omitempty"`
+	Revoked   map[string]bool `json:"revoked"` // Revocation status per fingerprint
+}
+
+// LookupHandler serves revocation lookups.
+//
+// Query parameters: fingerprint, repeated for every fingerprint to look up, and namespace, the issuer
+// namespace to look them up in, empty for the default filter. Requests made with a scoped API key are
+// refused with 403 Forbidden as a whole unless every fingerprint is in the key's scope, before anything
+// is looked up, so a relying party cannot probe the revocations of other issuers.
+type LookupHandler struct {
+	Lookup func(namespace string, fingerprints []string) (map[string]bool, error) // e.g. by evaluating BatchLookup or LookupInNamespace
+}
+
+func (h *LookupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	namespace := query.Get("namespace")
+	fingerprints := query["fingerprint"]
+	if len(fingerprints) == 0 || len(fingerprints) > MaxLookupFingerprints {
+		writeRequestProblem(w, http.StatusBadRequest, fmt.Sprintf("between 1 and %d fingerprints are required", MaxLookupFingerprints))
+		return
+	}
+	if scope, ok := client.APIKeyScopeFromContext(r.Context()); ok && !scope.Allows(namespace, fingerprints) {
+		writeRequestProblem(w, http.StatusForbidden, client.ErrOutOfScope.Error())
+		return
+	}
+
+	revoked, err := h.Lookup(namespace, fingerprints)
+	if err != nil {
+		WriteProblem(w, fmt.Errorf("error looking up fingerprints: %w", err), "")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(LookupResponse{Namespace: namespace, Revoked: revoked}); err != nil {
+		writeRequestProblem(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,76 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pherbke/credential-management/chaincode-go/client"
+	"github.com/pherbke/credential-management/chaincode-go/gateway"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+)
+
+func TestLookupHandler_Scopes(t *testing.T) {
+	sim, err := simulator.New("credential-management", &cuckoofilter.SmartContract{})
+	require.NoError(t, err)
+	identity, err := simulator.NewIdentityWithAttributes("Org1MSP", "gateway", map[string]string{cuckoofilter.RegistryAdminAttribute: "true"})
+	require.NoError(t, err)
+	_, err = sim.Submit(identity, "InitShards", "2", "16", "100", "4")
+	require.NoError(t, err)
+	_, err = sim.Submit(identity, "InsertInNamespace", "did:web:uni.example", "ab01")
+	require.NoError(t, err)
+	_, err = sim.Submit(identity, "InsertInNamespace", "did:web:other.example", "cd02")
+	require.NoError(t, err)
+
+	lookups := 0
+	tenants := &client.Tenants{Tenants: []client.Tenant{{
+		ID: "org1", APIKeyHashes: []string{client.HashCredential([]byte("admin-key"))},
+		ScopedAPIKeys: []client.APIKeyScope{
+			{KeyHash: client.HashCredential([]byte("uni-key")), Namespaces: []string{"did:web:uni.example"}},
+			{KeyHash: client.HashCredential([]byte("prefix-key")), FingerprintPrefixes: []string{"ab"}},
+		},
+		MSPID: "Org1MSP", Channel: "registry", Chaincode: "credential-management",
+	}}}
+	require.NoError(t, tenants.Validate())
+	handler := tenants.Middleware(&gateway.LookupHandler{Lookup: func(namespace string, fingerprints []string) (map[string]bool, error) {
+		lookups++
+		revoked := make(map[string]bool, len(fingerprints))
+		for _, fingerprint := range fingerprints {
+			found, err := sim.Evaluate(identity, "LookupInNamespace", namespace, fingerprint)
+			if err != nil {
+				return nil, err
+			}
+			revoked[fingerprint] = string(found) == "true"
+		}
+		return revoked, nil
+	}})
+	call := func(apiKey string, namespace string, fingerprints ...string) *httptest.ResponseRecorder {
+		query := url.Values{"namespace": {namespace}, "fingerprint": fingerprints}
+		request := httptest.NewRequest(http.MethodGet, "/lookup?"+query.Encode(), nil)
+		request.Header.Set(client.APIKeyHeader, apiKey)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	recorder := call("uni-key", "did:web:uni.example", "ab01", "ab99")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var response gateway.LookupResponse
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+	require.Equal(t, gateway.LookupResponse{Namespace: "did:web:uni.example", Revoked: map[string]bool{"ab01": true, "ab99": false}}, response)
+
+	// Out-of-scope lookups are refused before reaching the ledger
+	require.Equal(t, http.StatusForbidden, call("uni-key", "did:web:other.example", "cd02").Code)
+	require.Equal(t, http.StatusForbidden, call("prefix-key", "did:web:other.example", "ab01", "cd02").Code)
+	require.Equal(t, 1, lookups)
+
+	require.Equal(t, http.StatusOK, call("prefix-key", "did:web:other.example", "ab01").Code)
+	require.Equal(t, http.StatusOK, call("admin-key", "did:web:other.example", "cd02").Code)
+	require.Equal(t, http.StatusBadRequest, call("admin-key", "did:web:other.example").Code)
+	require.Equal(t, 3, lookups)
+}
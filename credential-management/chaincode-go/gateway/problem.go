@@ -0,0 +1,93 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+)
+
+// ProblemContentType is the content type of error responses, RFC 9457 problem details
+const ProblemContentType = "application/problem+json"
+
+// ProblemTypePrefix prefixes the chaincode error code in the type of a problem, e.g.
+// urn:credential-management:error:NOT_FOUND
+const ProblemTypePrefix = "urn:credential-management:error:"
+
+// ErrorStatus is what a chaincode error code maps to in the gateway services
+type ErrorStatus struct {
+	HTTPStatus int
+	GRPCCode   codes.Code
+	Title      string
+}
+
+// ErrorStatuses maps every chaincode error code to its statuses, the one table all gateway services use
+var ErrorStatuses = map[string]ErrorStatus{
+	cuckoofilter.FilterFullErrorCode:         {http.StatusInsufficientStorage, codes.ResourceExhausted, "Filter is full"},
+	cuckoofilter.NotFoundErrorCode:           {http.StatusNotFound, codes.NotFound, "Not found"},
+	cuckoofilter.AlreadyRevokedErrorCode:     {http.StatusConflict, codes.AlreadyExists, "Credential is already revoked"},
+	cuckoofilter.StateCorruptErrorCode:       {http.StatusInternalServerError, codes.DataLoss, "Registry state is corrupt"},
+	cuckoofilter.UnauthorizedErrorCode:       {http.StatusForbidden, codes.PermissionDenied, "Not authorized"},
+	cuckoofilter.InvalidArgumentErrorCode:    {http.StatusBadRequest, codes.InvalidArgument, "Invalid argument"},
+	cuckoofilter.ConflictErrorCode:           {http.StatusConflict, codes.AlreadyExists, "Conflicting request"},
+	cuckoofilter.FailedPreconditionErrorCode: {http.StatusUnprocessableEntity, codes.FailedPrecondition, "Precondition failed"},
+	cuckoofilter.RegistryFrozenErrorCode:     {http.StatusServiceUnavailable, codes.Unavailable, "Registry is frozen"},
+}
+
+// UnknownErrorStatus is the status of errors without a chaincode error code, e.g. a peer that cannot be reached
+var UnknownErrorStatus = ErrorStatus{http.StatusBadGateway, codes.Unknown, "Chaincode request failed"}
+
+// StatusOf returns the statuses of an error returned by the chaincode or the Fabric gateway
+func StatusOf(err error) ErrorStatus {
+	if status, ok := ErrorStatuses[cuckoofilter.ErrorCode(err)]; ok {
+		return status
+	}
+	return UnknownErrorStatus
+}
+
+// GRPCError converts an error returned by the chaincode or the Fabric gateway to a gRPC status error
+func GRPCError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return status.Error(StatusOf(err).GRPCCode, err.Error())
+}
+
+// Problem is the body of an error response
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	TxID   string `json:"txID,omitempty"` // Transaction that failed, empty for evaluations
+}
+
+// NewProblem describes an error returned by the chaincode or the Fabric gateway
+func NewProblem(err error, txID string) *Problem {
+	errorStatus := StatusOf(err)
+	problem := &Problem{Type: "about:blank", Title: errorStatus.Title, Status: errorStatus.HTTPStatus, Detail: err.Error(), TxID: txID}
+	if code := cuckoofilter.ErrorCode(err); code != "" {
+		problem.Type = ProblemTypePrefix + code
+	}
+	return problem
+}
+
+// WriteProblem writes the problem details of an error returned by the chaincode or the Fabric gateway
+func WriteProblem(w http.ResponseWriter, err error, txID string) {
+	writeProblem(w, NewProblem(err, txID))
+}
+
+// writeRequestProblem writes the problem details of a request the gateway refuses itself
+func writeRequestProblem(w http.ResponseWriter, status int, detail string) {
+	writeProblem(w, &Problem{Type: "about:blank", Title: http.StatusText(status), Status: status, Detail: detail})
+}
+
+func writeProblem(w http.ResponseWriter, problem *Problem) {
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
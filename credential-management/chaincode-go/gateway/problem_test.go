@@ -0,0 +1,140 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/pherbke/credential-management/chaincode-go/gateway"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+)
+
+// chaincodeErrors are the exported errors of the chaincode, TestErrorStatuses_CoverChaincodeErrors
+// fails when one is added without being listed here
+var chaincodeErrors = map[string]error{
+	"ErrAlreadyRevoked":          cuckoofilter.ErrAlreadyRevoked,
+	"ErrAssetNotAnchored":        cuckoofilter.ErrAssetNotAnchored,
+	"ErrConflict":                cuckoofilter.ErrConflict,
+	"ErrCorruptFilter":           cuckoofilter.ErrCorruptFilter,
+	"ErrCorruptState":            cuckoofilter.ErrCorruptState,
+	"ErrCredentialNotAnchored":   cuckoofilter.ErrCredentialNotAnchored,
+	"ErrCredentialRevoked":       cuckoofilter.ErrCredentialRevoked,
+	"ErrDIDDeactivated":          cuckoofilter.ErrDIDDeactivated,
+	"ErrDIDKeyMismatch":          cuckoofilter.ErrDIDKeyMismatch,
+	"ErrFailedPrecondition":      cuckoofilter.ErrFailedPrecondition,
+	"ErrFilterFull":              cuckoofilter.ErrFilterFull,
+	"ErrIdempotencyKeyReused":    cuckoofilter.ErrIdempotencyKeyReused,
+	"ErrInvalidArgument":         cuckoofilter.ErrInvalidArgument,
+	"ErrInvalidProof":            cuckoofilter.ErrInvalidProof,
+	"ErrMigrationMismatch":       cuckoofilter.ErrMigrationMismatch,
+	"ErrNotCredentialIssuer":     cuckoofilter.ErrNotCredentialIssuer,
+	"ErrNotDIDController":        cuckoofilter.ErrNotDIDController,
+	"ErrNotFound":                cuckoofilter.ErrNotFound,
+	"ErrNotGovernanceMember":     cuckoofilter.ErrNotGovernanceMember,
+	"ErrNotInserter":             cuckoofilter.ErrNotInserter,
+	"ErrNotBatchOwner":           cuckoofilter.ErrNotBatchOwner,
+	"ErrNotTrustRegistryAdmin":   cuckoofilter.ErrNotTrustRegistryAdmin,
+	"ErrRawCredentialNotAllowed": cuckoofilter.ErrRawCredentialNotAllowed,
+	"ErrStateCorrupt":            cuckoofilter.ErrStateCorrupt,
+	"ErrUnauthorized":            cuckoofilter.ErrUnauthorized,
+	"ErrUntrustedIssuer":         cuckoofilter.ErrUntrustedIssuer,
+}
+
+func TestErrorStatuses_CoverChaincodeErrors(t *testing.T) {
+	files := token.NewFileSet()
+	packages, err := parser.ParseDir(files, "../smart-contract", func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	require.NoError(t, err)
+	exported := map[string]bool{}
+	for _, file := range packages["cuckoofilter"].Files {
+		for _, decl := range file.Decls {
+			if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.VAR {
+				for _, spec := range gen.Specs {
+					for _, name := range spec.(*ast.ValueSpec).Names {
+						if name.IsExported() && strings.HasPrefix(name.Name, "Err") {
+							exported[name.Name] = true
+						}
+					}
+				}
+			}
+		}
+	}
+	for name := range exported {
+		require.Contains(t, chaincodeErrors, name, "add %s to chaincodeErrors", name)
+	}
+
+	for name, err := range chaincodeErrors {
+		code := cuckoofilter.ErrorCode(err)
+		require.NotEmpty(t, code, "%s has no error code", name)
+		require.Contains(t, gateway.ErrorStatuses, code, "%s of %s has no status", code, name)
+		// The code also survives the trip through a gateway error message
+		message := fmt.Errorf("%w: details", err).Error()
+		require.Equal(t, code, cuckoofilter.ErrorCode(errors.New("chaincode response 500, "+message)), name)
+	}
+	for _, code := range cuckoofilter.ErrorCodes() {
+		require.Contains(t, gateway.ErrorStatuses, code)
+	}
+}
+
+func TestWriteProblem(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	gateway.WriteProblem(recorder, fmt.Errorf("error revoking: %w", cuckoofilter.ErrAlreadyRevoked), "tx1")
+	require.Equal(t, http.StatusConflict, recorder.Code)
+	require.Equal(t, gateway.ProblemContentType, recorder.Header().Get("Content-Type"))
+	var problem gateway.Problem
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &problem))
+	require.Equal(t, gateway.Problem{
+		Type:   gateway.ProblemTypePrefix + cuckoofilter.AlreadyRevokedErrorCode,
+		Title:  "Credential is already revoked",
+		Status: http.StatusConflict,
+		Detail: "error revoking: ALREADY_REVOKED",
+		TxID:   "tx1",
+	}, problem)
+
+	// Errors without a code are failures of the upstream gateway
+	recorder = httptest.NewRecorder()
+	gateway.WriteProblem(recorder, errors.New("connection refused"), "")
+	require.Equal(t, http.StatusBadGateway, recorder.Code)
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &problem))
+	require.Equal(t, "about:blank", problem.Type)
+}
+
+func TestGRPCError(t *testing.T) {
+	require.NoError(t, gateway.GRPCError(nil))
+	converted, ok := status.FromError(gateway.GRPCError(fmt.Errorf("%w: filter 'a' not found", cuckoofilter.ErrNotFound)))
+	require.True(t, ok)
+	require.Equal(t, codes.NotFound, converted.Code())
+	require.Equal(t, "NOT_FOUND: filter 'a' not found", converted.Message())
+}
+
+func TestLookupHandler_ChaincodeError(t *testing.T) {
+	sim, err := simulator.New("credential-management", &cuckoofilter.SmartContract{})
+	require.NoError(t, err)
+	identity, err := simulator.NewIdentity("Org1MSP", "gateway")
+	require.NoError(t, err)
+	handler := &gateway.LookupHandler{Lookup: func(namespace string, fingerprints []string) (map[string]bool, error) {
+		_, err := sim.Evaluate(identity, "Lookup", "unknown", fingerprints[0])
+		return nil, err
+	}}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/lookup?fingerprint=ab01", nil))
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+	var problem gateway.Problem
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &problem))
+	require.Equal(t, gateway.ProblemTypePrefix+cuckoofilter.NotFoundErrorCode, problem.Type)
+}
@@ -0,0 +1,180 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pherbke/credential-management/chaincode-go/clock"
+	"github.com/pherbke/credential-management/chaincode-go/verifier"
+)
+
+// DefaultSnapshotRetryInterval is the time a ReadThrough without a RetryInterval waits after a failed
+// snapshot download
+const DefaultSnapshotRetryInterval = 10 * time.Second
+
+// errNoSnapshot is returned by Load when the download returned no filter before one was loaded
+var errNoSnapshot = errors.New("no snapshot loaded")
+
+// States of the snapshot cache of a ReadThrough
+const (
+	CacheCold    = "cold"    // Not started, lookups are evaluated against the ledger
+	CacheWarming = "warming" // Snapshot downloading or waiting for a retry, lookups are evaluated against the ledger
+	CacheWarm    = "warm"    // Snapshot loaded, lookups of the default filter are answered from it
+)
+
+// CacheHealth reports the state of a ReadThrough, e.g. for readiness probes and metrics
+type CacheHealth struct {
+	State         string    `json:"state"`
+	Since         time.Time `json:"since"`               // Time the cache entered State
+	Ready         bool      `json:"ready"`               // Lookups can be answered, from the snapshot or the ledger
+	Attempts      int       `json:"attempts"`            // Snapshot downloads started
+	LastError     string    `json:"lastError,omitempty"` // Error of the last failed download or ledger lookup
+	CachedLookups uint64    `json:"cachedLookups"`       // Lookups answered from the snapshot
+	LedgerLookups uint64    `json:"ledgerLookups"`       // Lookups evaluated against the ledger
+}
+
+// ReadThrough serves lookups while the gateway starts. Until the snapshot of the default filter is
+// loaded, lookups are evaluated against the ledger, so a cold start never fails verifications; once
+// the snapshot is loaded they switch to it. Namespace lookups always go to the ledger, the snapshot
+// holds the default filter only. Keeping the loaded filter current is up to the filter Snapshot
+// returns, e.g. a filter updated from FilterChanged events.
+//
+// Lookup has the signature of LookupHandler.Lookup, and HealthHandler reports the state.
+type ReadThrough struct {
+	Ledger        func(namespace string, fingerprints []string) (map[string]bool, error) // e.g. by evaluating BatchLookup or LookupInNamespace
+	Snapshot      func() (verifier.FilterLookup, error)                                  // Downloads the snapshot, e.g. with snapshot.Downloader
+	RetryInterval time.Duration                                                          // Between failed downloads, DefaultSnapshotRetryInterval if zero
+	Clock         clock.Clock
+
+	mu          sync.Mutex
+	filter      verifier.FilterLookup
+	health      CacheHealth
+	ledgerFails bool // The last ledger lookup before the snapshot was loaded failed
+}
+
+// Start downloads the snapshot in the background, retrying until it is loaded or stop is closed
+func (r *ReadThrough) Start(stop <-chan struct{}) {
+	r.mu.Lock()
+	if r.health.State != CacheWarm {
+		r.setStateLocked(CacheWarming)
+	}
+	r.mu.Unlock()
+	go r.warm(stop)
+}
+
+// warm retries Load every RetryInterval until it succeeds or stop is closed
+func (r *ReadThrough) warm(stop <-chan struct{}) {
+	interval := r.RetryInterval
+	if interval == 0 {
+		interval = DefaultSnapshotRetryInterval
+	}
+	for r.Load() != nil {
+		timer := time.NewTimer(interval)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// Load downloads the snapshot once and switches lookups to it. A failed download leaves lookups on
+// the ledger and is reported by Health.
+func (r *ReadThrough) Load() error {
+	r.mu.Lock()
+	r.health.Attempts++
+	if r.health.State != CacheWarm {
+		r.setStateLocked(CacheWarming)
+	}
+	r.mu.Unlock()
+
+	filter, err := r.Snapshot()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.health.LastError = err.Error()
+		return err
+	}
+	// A download that found no newer snapshot keeps the loaded one
+	if filter != nil {
+		r.filter = filter
+	}
+	if r.filter == nil {
+		r.health.LastError = errNoSnapshot.Error()
+		return errNoSnapshot
+	}
+	r.health.LastError = ""
+	r.setStateLocked(CacheWarm)
+	return nil
+}
+
+// Lookup answers lookups of the default filter from the snapshot once it is loaded and evaluates all
+// other lookups against the ledger
+func (r *ReadThrough) Lookup(namespace string, fingerprints []string) (map[string]bool, error) {
+	r.mu.Lock()
+	filter := r.filter
+	if namespace == "" && filter != nil {
+		r.health.CachedLookups++
+		r.mu.Unlock()
+		revoked := make(map[string]bool, len(fingerprints))
+		for _, fingerprint := range fingerprints {
+			revoked[fingerprint] = filter.Lookup([]byte(fingerprint))
+		}
+		return revoked, nil
+	}
+	r.health.LedgerLookups++
+	r.mu.Unlock()
+
+	revoked, err := r.Ledger(namespace, fingerprints)
+	if filter == nil {
+		// Before the snapshot is loaded, the ledger decides readiness
+		r.mu.Lock()
+		r.ledgerFails = err != nil
+		if err != nil {
+			r.health.LastError = err.Error()
+		}
+		r.mu.Unlock()
+	}
+	return revoked, err
+}
+
+// Health returns the state of the cache and its lookup counters
+func (r *ReadThrough) Health() CacheHealth {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	health := r.health
+	if health.State == "" {
+		health.State = CacheCold
+	}
+	health.Ready = health.State == CacheWarm || !r.ledgerFails
+	return health
+}
+
+// setStateLocked moves the cache to a state, the caller must hold r.mu
+func (r *ReadThrough) setStateLocked(state string) {
+	if r.health.State != state {
+		r.health.Since = clock.Or(r.Clock).Now()
+	}
+	r.health.State = state
+}
+
+// HealthHandler reports the CacheHealth of a ReadThrough as JSON, with 200 OK while lookups can be
+// answered and 503 Service Unavailable while neither the snapshot nor the ledger can answer them
+type HealthHandler struct {
+	Cache *ReadThrough
+}
+
+func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	health := h.Cache.Health()
+	w.Header().Set("Content-Type", "application/json")
+	if !health.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(health); err != nil {
+		writeRequestProblem(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,110 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pherbke/credential-management/chaincode-go/gateway"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/pherbke/credential-management/chaincode-go/verifier"
+)
+
+// revokedSet is a snapshot filter holding a fixed set of revoked fingerprints
+type revokedSet map[string]bool
+
+func (s revokedSet) Lookup(data []byte) bool {
+	return s[string(data)]
+}
+
+func TestReadThrough_ColdStart(t *testing.T) {
+	sim, err := simulator.New("credential-management", &cuckoofilter.SmartContract{})
+	require.NoError(t, err)
+	identity, err := simulator.NewIdentity("Org1MSP", "gateway")
+	require.NoError(t, err)
+	admin, err := simulator.NewIdentityWithAttributes("Org1MSP", "registry-admin", map[string]string{cuckoofilter.RegistryAdminAttribute: "true"})
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "Init", "", "100", "4", "0")
+	require.NoError(t, err)
+	_, err = sim.Submit(identity, "Insert", "", "ab01")
+	require.NoError(t, err)
+
+	ledgerDown := false
+	downloads := make(chan error)
+	cache := &gateway.ReadThrough{
+		Ledger: func(namespace string, fingerprints []string) (map[string]bool, error) {
+			if ledgerDown {
+				return nil, errors.New("peer unavailable")
+			}
+			fingerprintsJSON, err := json.Marshal(fingerprints)
+			if err != nil {
+				return nil, err
+			}
+			resultJSON, err := sim.Evaluate(identity, "BatchLookup", namespace, string(fingerprintsJSON))
+			if err != nil {
+				return nil, err
+			}
+			var revoked map[string]bool
+			return revoked, json.Unmarshal(resultJSON, &revoked)
+		},
+		Snapshot: func() (verifier.FilterLookup, error) {
+			if err := <-downloads; err != nil {
+				return nil, err
+			}
+			return revokedSet{"ab01": true}, nil
+		},
+		RetryInterval: time.Millisecond,
+	}
+	lookup := &gateway.LookupHandler{Lookup: cache.Lookup}
+	call := func(handler http.Handler, query url.Values) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/?"+query.Encode(), nil))
+		return recorder
+	}
+	health := func() gateway.CacheHealth {
+		recorder := call(&gateway.HealthHandler{Cache: cache}, nil)
+		var health gateway.CacheHealth
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&health))
+		require.Equal(t, health.Ready, recorder.Code == http.StatusOK)
+		return health
+	}
+	require.Equal(t, gateway.CacheCold, health().State)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	cache.Start(stop)
+	// While the snapshot downloads, lookups are evaluated against the ledger
+	recorder := call(lookup, url.Values{"fingerprint": {"ab01", "cd02"}})
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var response gateway.LookupResponse
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+	require.Equal(t, map[string]bool{"ab01": true, "cd02": false}, response.Revoked)
+	require.Equal(t, gateway.CacheWarming, health().State)
+
+	downloads <- errors.New("snapshot server unavailable")
+	require.Eventually(t, func() bool { return health().LastError == "snapshot server unavailable" }, time.Second, time.Millisecond)
+	ledgerDown = true
+	require.Equal(t, http.StatusBadGateway, call(lookup, url.Values{"fingerprint": {"ab01"}}).Code)
+	require.False(t, health().Ready)
+
+	// The retry loads the snapshot and lookups no longer need the ledger
+	downloads <- nil
+	require.Eventually(t, func() bool { return health().State == gateway.CacheWarm }, time.Second, time.Millisecond)
+	recorder = call(lookup, url.Values{"fingerprint": {"ab01", "cd02"}})
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+	require.Equal(t, map[string]bool{"ab01": true, "cd02": false}, response.Revoked)
+
+	state := health()
+	require.True(t, state.Ready)
+	require.Equal(t, 2, state.Attempts)
+	require.Equal(t, uint64(2), state.LedgerLookups)
+	require.Equal(t, uint64(1), state.CachedLookups)
+}
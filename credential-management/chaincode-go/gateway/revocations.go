@@ -0,0 +1,149 @@
+// Package gateway serves registry listings over HTTP, so auditors can pull large registries without
+// a Fabric SDK. Listings are paged through the chaincode with cursors, one chaincode page per request,
+// which keeps every request short however large the registry grows.
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+)
+
+// DefaultPageSize is the page size of requests without a limit
+const DefaultPageSize = 100
+
+// NDJSONContentType selects a streamed dump of the whole listing, one JSON object per line
+const NDJSONContentType = "application/x-ndjson"
+
+// RevocationFilter selects revocations server-side. Zero fields match everything.
+type RevocationFilter struct {
+	IssuerDID string
+	Reason    string    // Matches revocations whose reason contains it
+	From      time.Time // Inclusive
+	To        time.Time // Exclusive
+}
+
+// Matches reports whether a revocation passes the filter
+func (f RevocationFilter) Matches(revocation cuckoofilter.Revocation) bool {
+	if f.IssuerDID != "" && revocation.Issuer.DID != f.IssuerDID {
+		return false
+	}
+	if f.Reason != "" && !strings.Contains(revocation.Reason, f.Reason) {
+		return false
+	}
+	if !f.From.IsZero() && revocation.RevokedAt.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !revocation.RevokedAt.Before(f.To) {
+		return false
+	}
+	return true
+}
+
+// RevocationsResponse is the body of a paged ListRevocations response
+type RevocationsResponse struct {
+	Revocations []cuckoofilter.Revocation `json:"revocations"`
+	NextCursor  string                    `json:"nextCursor,omitempty"` // Empty after the last page
+}
+
+// RevocationsHandler serves ListRevocations.
+//
+// Query parameters: limit (page size), cursor (from the previous response), issuer, reason, and from
+// and to as RFC 3339 times. Filters apply per chaincode page, so a filtered page may hold fewer
+// entries than the limit, or none; clients continue while nextCursor is set. Requests with
+// stream=true or an Accept header of application/x-ndjson get every matching revocation from the
+// cursor on as NDJSON instead.
+type RevocationsHandler struct {
+	Source func(pageSize int32, bookmark string) (*cuckoofilter.RevocationsPage, error) // Reads a page, e.g. by evaluating ListRevocations
+}
+
+func (h *RevocationsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	pageSize, filter, err := parseRevocationsQuery(query)
+	if err != nil {
+		writeRequestProblem(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	cursor := query.Get("cursor")
+	if query.Get("stream") == "true" || strings.Contains(r.Header.Get("Accept"), NDJSONContentType) {
+		h.stream(w, pageSize, cursor, filter)
+		return
+	}
+
+	page, err := h.Source(pageSize, cursor)
+	if err != nil {
+		WriteProblem(w, fmt.Errorf("error reading revocations: %w", err), "")
+		return
+	}
+	response := RevocationsResponse{Revocations: []cuckoofilter.Revocation{}, NextCursor: page.Bookmark}
+	for _, revocation := range page.Revocations {
+		if filter.Matches(revocation) {
+			response.Revocations = append(response.Revocations, revocation)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		writeRequestProblem(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// stream writes the matching revocations of all pages from the cursor on, flushing after every page.
+// Once streaming started, errors can no longer change the status, so they end the stream with a
+// final {"error": ...} line instead of problem details.
+func (h *RevocationsHandler) stream(w http.ResponseWriter, pageSize int32, cursor string, filter RevocationFilter) {
+	w.Header().Set("Content-Type", NDJSONContentType)
+	encoder := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	for started := false; !started || cursor != ""; started = true {
+		page, err := h.Source(pageSize, cursor)
+		if err != nil {
+			if !started {
+				WriteProblem(w, fmt.Errorf("error reading revocations: %w", err), "")
+				return
+			}
+			encoder.Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		for _, revocation := range page.Revocations {
+			if !filter.Matches(revocation) {
+				continue
+			}
+			if err := encoder.Encode(revocation); err != nil {
+				return
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		cursor = page.Bookmark
+	}
+}
+
+func parseRevocationsQuery(query url.Values) (int32, RevocationFilter, error) {
+	filter := RevocationFilter{IssuerDID: query.Get("issuer"), Reason: query.Get("reason")}
+
+	pageSize := int32(DefaultPageSize)
+	if limit := query.Get("limit"); limit != "" {
+		parsed, err := strconv.ParseInt(limit, 10, 32)
+		if err != nil || parsed <= 0 || parsed > cuckoofilter.MaxRevocationsPageSize {
+			return 0, filter, fmt.Errorf("limit must be between 1 and %d", cuckoofilter.MaxRevocationsPageSize)
+		}
+		pageSize = int32(parsed)
+	}
+	for name, bound := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := query.Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return 0, filter, fmt.Errorf("%s must be an RFC 3339 time: %v", name, err)
+			}
+			*bound = parsed
+		}
+	}
+	return pageSize, filter, nil
+}
@@ -0,0 +1,124 @@
+package gateway_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pherbke/credential-management/chaincode-go/gateway"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+)
+
+var day = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+// pagedSource serves revocations like ListRevocations, with the index of the next entry as bookmark
+func pagedSource(revocations []cuckoofilter.Revocation) func(int32, string) (*cuckoofilter.RevocationsPage, error) {
+	return func(pageSize int32, bookmark string) (*cuckoofilter.RevocationsPage, error) {
+		start := 0
+		if bookmark != "" {
+			start, _ = strconv.Atoi(bookmark)
+		}
+		end := start + int(pageSize)
+		page := &cuckoofilter.RevocationsPage{}
+		if end < len(revocations) {
+			page.Bookmark = strconv.Itoa(end)
+		} else {
+			end = len(revocations)
+		}
+		page.Revocations = revocations[start:end]
+		return page, nil
+	}
+}
+
+func testRevocations() []cuckoofilter.Revocation {
+	revocations := make([]cuckoofilter.Revocation, 10)
+	for i := range revocations {
+		revocations[i] = cuckoofilter.Revocation{
+			CredentialID: fmt.Sprintf("credential-%d", i),
+			Issuer:       cuckoofilter.Inserter{MSPID: "Org1MSP", DID: fmt.Sprintf("did:key:issuer%d", i%2)},
+			RevokedAt:    day.AddDate(0, 0, i),
+		}
+	}
+	revocations[3].Reason = "approved request 7"
+	return revocations
+}
+
+func get(t *testing.T, handler http.Handler, target string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+	return recorder
+}
+
+func TestRevocationsHandler_Pages(t *testing.T) {
+	handler := &gateway.RevocationsHandler{Source: pagedSource(testRevocations())}
+
+	var ids []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		recorder := get(t, handler, "/revocations?limit=4&cursor="+cursor)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		var response gateway.RevocationsResponse
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+		for _, revocation := range response.Revocations {
+			ids = append(ids, revocation.CredentialID)
+		}
+		if cursor = response.NextCursor; cursor == "" {
+			require.Equal(t, 2, pages)
+			break
+		}
+	}
+	require.Len(t, ids, 10)
+}
+
+func TestRevocationsHandler_Filters(t *testing.T) {
+	handler := &gateway.RevocationsHandler{Source: pagedSource(testRevocations())}
+
+	recorder := get(t, handler, "/revocations?issuer=did:key:issuer1&from=2024-03-02T00:00:00Z&to=2024-03-06T00:00:00Z")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var response gateway.RevocationsResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	require.Len(t, response.Revocations, 2)
+	require.Equal(t, "credential-1", response.Revocations[0].CredentialID)
+	require.Equal(t, "credential-3", response.Revocations[1].CredentialID)
+
+	recorder = get(t, handler, "/revocations?reason=request+7")
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	require.Len(t, response.Revocations, 1)
+
+	require.Equal(t, http.StatusBadRequest, get(t, handler, "/revocations?limit=0").Code)
+	require.Equal(t, http.StatusBadRequest, get(t, handler, "/revocations?from=yesterday").Code)
+}
+
+func TestRevocationsHandler_Stream(t *testing.T) {
+	handler := &gateway.RevocationsHandler{Source: pagedSource(testRevocations())}
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/revocations?limit=3&issuer=did:key:issuer0", nil)
+	request.Header.Set("Accept", gateway.NDJSONContentType)
+	handler.ServeHTTP(recorder, request)
+
+	require.Equal(t, gateway.NDJSONContentType, recorder.Header().Get("Content-Type"))
+	var ids []string
+	scanner := bufio.NewScanner(recorder.Body)
+	for scanner.Scan() {
+		var revocation cuckoofilter.Revocation
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &revocation))
+		ids = append(ids, revocation.CredentialID)
+	}
+	require.Equal(t, []string{"credential-0", "credential-2", "credential-4", "credential-6", "credential-8"}, ids)
+}
+
+func TestRevocationsHandler_SourceError(t *testing.T) {
+	handler := &gateway.RevocationsHandler{Source: func(int32, string) (*cuckoofilter.RevocationsPage, error) {
+		return nil, errors.New("peer unavailable")
+	}}
+	require.Equal(t, http.StatusBadGateway, get(t, handler, "/revocations").Code)
+	require.Equal(t, http.StatusBadGateway, get(t, handler, "/revocations?stream=true").Code)
+}
@@ -0,0 +1,305 @@
+// Package lint checks draft credentials before an issuer signs them.
+//
+// The checks work on the raw JSON rather than on cuckoofilter.VerifiableCredential, so a single
+// malformed field is reported with its path instead of failing the whole decoding, and every problem
+// of a draft is reported at once.
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Severities of an Issue
+const (
+	SeverityError   = "error"   // The credential must not be signed like this
+	SeverityWarning = "warning" // The credential can be signed, but probably should not be
+)
+
+// BaseContext is the context every W3C verifiable credential starts with
+const BaseContext = "https://www.w3.org/2018/credentials/v1"
+
+// BaseType is the type every verifiable credential has
+const BaseType = "VerifiableCredential"
+
+// Issue is one problem found in a draft credential
+type Issue struct {
+	Path     string `json:"path"` // Dotted path of the field, empty for the whole document
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Fix      string `json:"fix,omitempty"` // What to change
+}
+
+func (i Issue) String() string {
+	path := i.Path
+	if path == "" {
+		path = "(document)"
+	}
+	if i.Fix == "" {
+		return fmt.Sprintf("%s: %s: %s", i.Severity, path, i.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s, %s", i.Severity, path, i.Message, i.Fix)
+}
+
+// Template lists the claims a credential type requires, as dotted paths below credentialSubject
+type Template struct {
+	Required []string `json:"required"`
+}
+
+// DefaultTemplates are the templates of the credential types the stakeholder contract issues
+var DefaultTemplates = map[string]Template{
+	"AlumniCredential": {Required: []string{"id", "alumniOf.id", "alumniOf.name"}},
+}
+
+// didPattern is the DID syntax of DID Core: did:<method-name>:<method-specific-id>
+var didPattern = regexp.MustCompile(`^did:[a-z0-9]+:(?:[A-Za-z0-9._-]|%[0-9A-Fa-f]{2}|:)*(?:[A-Za-z0-9._-]|%[0-9A-Fa-f]{2})$`)
+
+// HasErrors reports whether any issue is an error
+func HasErrors(issues []Issue) bool {
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Lint checks a draft credential against the VC data model and the templates of its types. Issues are
+// sorted by path.
+func Lint(credentialJSON []byte, templates map[string]Template) []Issue {
+	l := &linter{}
+	var credential map[string]interface{}
+	if err := json.Unmarshal(credentialJSON, &credential); err != nil {
+		l.error("", fmt.Sprintf("not a JSON object: %v", err), "")
+		return l.issues
+	}
+
+	l.checkContext(credential["@context"])
+	types := l.checkTypes(credential["type"])
+	l.checkID(credential["id"])
+	l.checkIssuer(credential["issuer"])
+	issuedAt, issuedOK := l.checkDate(credential, "issuanceDate", true)
+	expiresAt, expiresOK := l.checkDate(credential, "expirationDate", false)
+	if issuedOK && expiresOK && !expiresAt.After(issuedAt) {
+		l.error("expirationDate", "must be after issuanceDate", "set it to a later date or drop it")
+	}
+	l.checkSubject(credential["credentialSubject"], types, templates)
+	l.checkStatus(credential["credentialStatus"])
+	if _, ok := credential["proof"]; ok {
+		l.warning("proof", "a draft must not carry a proof", "remove it, the issuer adds the proof when signing")
+	}
+
+	sort.SliceStable(l.issues, func(i, j int) bool {
+		return l.issues[i].Path < l.issues[j].Path
+	})
+	return l.issues
+}
+
+// linter collects the issues of one credential
+type linter struct {
+	issues []Issue
+}
+
+func (l *linter) error(path, message, fix string) {
+	l.issues = append(l.issues, Issue{Path: path, Severity: SeverityError, Message: message, Fix: fix})
+}
+
+func (l *linter) warning(path, message, fix string) {
+	l.issues = append(l.issues, Issue{Path: path, Severity: SeverityWarning, Message: message, Fix: fix})
+}
+
+func (l *linter) checkContext(value interface{}) {
+	contexts, ok := stringArray(value)
+	if !ok || len(contexts) == 0 {
+		l.error("@context", "must be a non-empty array of URLs", fmt.Sprintf("start it with %q", BaseContext))
+		return
+	}
+	if contexts[0] != BaseContext {
+		l.error("@context[0]", fmt.Sprintf("is %q", contexts[0]), fmt.Sprintf("the first context must be %q", BaseContext))
+	}
+	for i, context := range contexts {
+		path := fmt.Sprintf("@context[%d]", i)
+		parsed, err := url.Parse(context)
+		if err != nil || !parsed.IsAbs() || parsed.Host == "" {
+			l.error(path, fmt.Sprintf("%q is not an absolute URL", context), "use the URL of the published context")
+		} else if parsed.Scheme != "https" {
+			l.warning(path, fmt.Sprintf("%q is not served over HTTPS", context), "verifiers may refuse to load it")
+		}
+	}
+}
+
+func (l *linter) checkTypes(value interface{}) []string {
+	types, ok := stringArray(value)
+	if !ok || len(types) == 0 {
+		l.error("type", "must be a non-empty array of strings", fmt.Sprintf("include %q and the credential type", BaseType))
+		return nil
+	}
+	if !contains(types, BaseType) {
+		l.error("type", fmt.Sprintf("does not include %q", BaseType), fmt.Sprintf("add %q", BaseType))
+	}
+	if len(types) == 1 && types[0] == BaseType {
+		l.warning("type", "has no specific credential type", "add the type of the credential, e.g. AlumniCredential")
+	}
+	return types
+}
+
+func (l *linter) checkID(value interface{}) {
+	if value == nil {
+		return
+	}
+	id, ok := value.(string)
+	if !ok {
+		l.error("id", "must be a string", "use a URL or URN")
+		return
+	}
+	if parsed, err := url.Parse(id); err != nil || !parsed.IsAbs() {
+		l.error("id", fmt.Sprintf("%q is not a URI", id), "use a URL or URN, e.g. urn:uuid:<uuid>")
+	}
+}
+
+func (l *linter) checkIssuer(value interface{}) {
+	path := "issuer"
+	if object, ok := value.(map[string]interface{}); ok {
+		path = "issuer.id"
+		value = object["id"]
+	}
+	issuer, ok := value.(string)
+	if !ok || issuer == "" {
+		l.error(path, "is missing", "set it to the DID of the issuer")
+		return
+	}
+	l.checkDID(path, issuer)
+}
+
+func (l *linter) checkDID(path, did string) {
+	if !didPattern.MatchString(did) {
+		l.error(path, fmt.Sprintf("%q is not a valid DID", did), "use did:<method>:<method-specific-id>, e.g. the DID returned by GenerateDID")
+		return
+	}
+	if strings.HasPrefix(did, "did:key:") && !strings.HasPrefix(did, "did:key:z") {
+		l.error(path, fmt.Sprintf("%q is not a base58btc multibase key", did), "did:key identifiers start with did:key:z")
+	}
+}
+
+// checkDate checks that a date is an XML Schema dateTime with a time zone, as the VC data model requires
+func (l *linter) checkDate(credential map[string]interface{}, field string, required bool) (time.Time, bool) {
+	value, present := credential[field]
+	if !present {
+		if required {
+			l.error(field, "is missing", "set it to the time of issuance, e.g. 2024-03-01T12:00:00Z")
+		}
+		return time.Time{}, false
+	}
+	date, ok := value.(string)
+	if !ok {
+		l.error(field, "must be a string", "use an RFC 3339 date-time, e.g. 2024-03-01T12:00:00Z")
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		l.error(field, fmt.Sprintf("%q is not an RFC 3339 date-time", date), "use e.g. 2024-03-01T12:00:00Z, with the time zone")
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func (l *linter) checkSubject(value interface{}, types []string, templates map[string]Template) {
+	subject, ok := value.(map[string]interface{})
+	if !ok {
+		l.error("credentialSubject", "must be an object", "add the claims about the holder")
+		return
+	}
+	if id, present := subject["id"]; present {
+		did, ok := id.(string)
+		if !ok {
+			l.error("credentialSubject.id", "must be a string", "set it to the DID of the holder")
+		} else {
+			l.checkDID("credentialSubject.id", did)
+		}
+	}
+	for _, credentialType := range types {
+		template, ok := templates[credentialType]
+		if !ok {
+			continue
+		}
+		for _, claim := range template.Required {
+			if !hasClaim(subject, claim) {
+				l.error("credentialSubject."+claim, fmt.Sprintf("is required by %s", credentialType), "add the claim")
+			}
+		}
+	}
+}
+
+func (l *linter) checkStatus(value interface{}) {
+	if value == nil {
+		return
+	}
+	status, ok := value.(map[string]interface{})
+	if !ok {
+		l.error("credentialStatus", "must be an object", "drop it, the issuer sets it when signing")
+		return
+	}
+	for _, field := range []string{"id", "type"} {
+		if s, ok := status[field].(string); !ok || s == "" {
+			l.error("credentialStatus."+field, "is missing", "drop credentialStatus, the issuer sets it when signing")
+		}
+	}
+}
+
+// hasClaim reports whether a dotted path leads to a present, non-empty value
+func hasClaim(object map[string]interface{}, path string) bool {
+	var value interface{} = object
+	for _, part := range strings.Split(path, ".") {
+		next, ok := value.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		value, ok = next[part]
+		if !ok {
+			return false
+		}
+	}
+	switch v := value.(type) {
+	case nil:
+		return false
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	}
+	return true
+}
+
+// stringArray reads a JSON array of strings, a single string counts as an array of one
+func stringArray(value interface{}) ([]string, bool) {
+	if s, ok := value.(string); ok {
+		return []string{s}, true
+	}
+	values, ok := value.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	strs := make([]string, 0, len(values))
+	for _, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			return nil, false
+		}
+		strs = append(strs, s)
+	}
+	return strs, true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package lint_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"github.com/pherbke/credential-management/chaincode-go/lint"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+const validDraft = `{
+	"@context": ["https://www.w3.org/2018/credentials/v1", "https://www.w3.org/2018/credentials/examples/v1"],
+	"id": "http://example.edu/credentials/1872",
+	"type": ["VerifiableCredential", "AlumniCredential"],
+	"issuer": "did:key:z2dmzD81cgPx8Vki7JbuuMmFYrWPgYoytykUZ3eyqht1j9Kb",
+	"issuanceDate": "2024-03-01T12:00:00Z",
+	"expirationDate": "2034-03-01T12:00:00Z",
+	"credentialSubject": {
+		"id": "did:example:ebfeb1f712ebc6f1c276e12ec21",
+		"alumniOf": {"id": "did:example:c276e12ec21ebfeb1f712ebc6f1", "name": [{"value": "Example University", "lang": "en"}]}
+	}
+}`
+
+// paths returns the paths of the issues
+func paths(issues []lint.Issue) []string {
+	paths := []string{}
+	for _, issue := range issues {
+		paths = append(paths, issue.Path)
+	}
+	return paths
+}
+
+func TestLint_Valid(t *testing.T) {
+	require.Empty(t, lint.Lint([]byte(validDraft), lint.DefaultTemplates))
+
+	// Credentials the stakeholder contract creates pass, apart from their proof
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	credential, err := cuckoofilter.CreateAndSignCredentialAt(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), "did:key:zIssuer", key, "did:key:zHolder", "-1")
+	require.NoError(t, err)
+	credentialJSON, err := json.Marshal(credential)
+	require.NoError(t, err)
+	issues := lint.Lint(credentialJSON, lint.DefaultTemplates)
+	require.Equal(t, []string{"proof"}, paths(issues))
+	require.False(t, lint.HasErrors(issues))
+}
+
+func TestLint_Errors(t *testing.T) {
+	draft := `{
+		"@context": ["https://www.w3.org/2018/credentials/examples/v1", "not a url"],
+		"type": ["AlumniCredential"],
+		"issuer": {"id": "did:Key:abc"},
+		"issuanceDate": "2024-03-01 12:00",
+		"expirationDate": "2024-03-01T12:00:00Z",
+		"credentialSubject": {"id": "did:key:abc", "alumniOf": {"name": []}},
+		"credentialStatus": {"type": "StatusList2021Entry"},
+		"proof": {}
+	}`
+	issues := lint.Lint([]byte(draft), lint.DefaultTemplates)
+	require.True(t, lint.HasErrors(issues))
+	require.Equal(t, []string{
+		"@context[0]",
+		"@context[1]",
+		"credentialStatus.id",
+		"credentialSubject.alumniOf.id",
+		"credentialSubject.alumniOf.name",
+		"credentialSubject.id",
+		"issuanceDate",
+		"issuer.id",
+		"proof",
+		"type",
+	}, paths(issues))
+	for _, issue := range issues {
+		require.NotEmpty(t, issue.Fix, issue.Path)
+	}
+	require.Equal(t, lint.SeverityWarning, issues[8].Severity)
+	require.Equal(t, `error: issuanceDate: "2024-03-01 12:00" is not an RFC 3339 date-time, use e.g. 2024-03-01T12:00:00Z, with the time zone`, issues[6].String())
+}
+
+func TestLint_Dates(t *testing.T) {
+	var draft map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(validDraft), &draft))
+	draft["expirationDate"] = "2020-01-01T00:00:00Z"
+	delete(draft, "issuanceDate")
+	draftJSON, err := json.Marshal(draft)
+	require.NoError(t, err)
+	require.Equal(t, []string{"issuanceDate"}, paths(lint.Lint(draftJSON, lint.DefaultTemplates)))
+
+	draft["issuanceDate"] = "2024-03-01T12:00:00+01:00"
+	draftJSON, err = json.Marshal(draft)
+	require.NoError(t, err)
+	issues := lint.Lint(draftJSON, lint.DefaultTemplates)
+	require.Equal(t, []string{"expirationDate"}, paths(issues))
+	require.Equal(t, "must be after issuanceDate", issues[0].Message)
+}
+
+func TestLint_Templates(t *testing.T) {
+	templates := map[string]lint.Template{"AlumniCredential": {Required: []string{"degree.type"}}}
+	issues := lint.Lint([]byte(validDraft), templates)
+	require.Equal(t, []string{"credentialSubject.degree.type"}, paths(issues))
+	require.Equal(t, "is required by AlumniCredential", issues[0].Message)
+
+	issues = lint.Lint([]byte(`[1, 2]`), templates)
+	require.Len(t, issues, 1)
+	require.Equal(t, "", issues[0].Path)
+	require.True(t, lint.HasErrors(issues))
+}
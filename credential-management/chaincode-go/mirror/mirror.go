@@ -0,0 +1,113 @@
+// Package mirror keeps an exact local copy of the revoked registry entries next to a verifier.
+//
+// Unlike the cuckoo filter cached by verifier.CachedRegistry, the mirror has no false positives and
+// carries the revocation metadata. It is built from the FilterChanged events the client.Listener
+// delivers and reconciled against ListRevocations on a schedule, which fills in the metadata events do
+// not carry and heals entries the events missed, e.g. of a filter kept in a private data collection.
+package mirror
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pherbke/credential-management/chaincode-go/client"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"sync"
+	"time"
+)
+
+// Store holds the mirrored revocations, keyed by credential ID
+type Store interface {
+	// Get returns the revocation of a credential, nil if it is not revoked
+	Get(credentialID string) (*cuckoofilter.Revocation, error)
+	// Put inserts or replaces a revocation
+	Put(revocation cuckoofilter.Revocation) error
+	// Delete removes a revocation, deleting a missing one is no error
+	Delete(credentialID string) error
+	// All returns every revocation in credential ID order
+	All() ([]cuckoofilter.Revocation, error)
+}
+
+// DriftMetrics counts the differences reconciliations found between the mirror and the ledger, e.g. to
+// export them as metrics and alert when the event feed keeps missing changes
+type DriftMetrics struct {
+	Reconciliations uint64    `json:"reconciliations"` // Completed reconciliations
+	Missing         uint64    `json:"missing"`         // Ledger revocations the mirror lacked
+	Extra           uint64    `json:"extra"`           // Mirrored revocations the ledger no longer has
+	Mismatched      uint64    `json:"mismatched"`      // Revocations whose metadata differed
+	LastDrift       uint64    `json:"lastDrift"`       // Differences found by the last reconciliation
+	LastReconciled  time.Time `json:"lastReconciled"`
+}
+
+// Mirror applies registry events and reconciliations to a Store
+type Mirror struct {
+	Store Store
+
+	mu      sync.Mutex
+	token   uint64
+	metrics DriftMetrics
+}
+
+// HandleBlock applies the FilterChanged events of a block. It has the signature of client.Listener's
+// Handle and rejects blocks carrying a fencing token older than the newest it has seen with
+// client.ErrFenced. Applying a block twice leaves the mirror unchanged.
+func (m *Mirror) HandleBlock(block *client.BlockEvents, token uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if token < m.token {
+		return client.ErrFenced
+	}
+	m.token = token
+	for _, event := range block.Events {
+		if err := m.apply(event); err != nil {
+			return fmt.Errorf("error applying event of transaction %s: %v", event.TxID, err)
+		}
+	}
+	return nil
+}
+
+// apply updates the mirror for one event, the caller must hold m.mu
+func (m *Mirror) apply(event client.ChaincodeEvent) error {
+	if event.Name != cuckoofilter.FilterChangedEvent {
+		return nil
+	}
+	var change cuckoofilter.FilterChange
+	if err := json.Unmarshal(event.Payload, &change); err != nil {
+		return fmt.Errorf("error decoding %s event: %v", event.Name, err)
+	}
+	for _, fingerprint := range change.Fingerprints {
+		switch change.Action {
+		case cuckoofilter.FilterChangeInserted:
+			// Keep the metadata of a credential inserted again
+			existing, err := m.Store.Get(fingerprint)
+			if err != nil {
+				return err
+			}
+			if existing == nil {
+				if err := m.Store.Put(cuckoofilter.Revocation{CredentialID: fingerprint}); err != nil {
+					return err
+				}
+			}
+		case cuckoofilter.FilterChangeDeleted:
+			if err := m.Store.Delete(fingerprint); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// IsRevoked is an exact verifier.RegistryLookup answered from the mirror
+func (m *Mirror) IsRevoked(credentialID string) (bool, error) {
+	revocation, err := m.Store.Get(credentialID)
+	if err != nil {
+		return false, err
+	}
+	return revocation != nil, nil
+}
+
+// Metrics returns the drift counters of all reconciliations so far
+func (m *Mirror) Metrics() DriftMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.metrics
+}
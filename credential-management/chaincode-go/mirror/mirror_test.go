@@ -0,0 +1,135 @@
+package mirror_test
+
+import (
+	"encoding/json"
+	"github.com/pherbke/credential-management/chaincode-go/client"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
+	"github.com/pherbke/credential-management/chaincode-go/mirror"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// filterChanged returns a FilterChanged event of the default filter
+func filterChanged(t *testing.T, action string, fingerprints ...string) client.ChaincodeEvent {
+	payload, err := json.Marshal(cuckoofilter.FilterChange{FilterID: cuckoofilter.DefaultFilterID, Action: action, Fingerprints: fingerprints})
+	require.NoError(t, err)
+	return client.ChaincodeEvent{TxID: "tx-" + action, Name: cuckoofilter.FilterChangedEvent, Payload: payload}
+}
+
+// pagedSource serves the revocations in pages, the bookmark is the offset of the next page
+func pagedSource(revocations []cuckoofilter.Revocation, calls *int) mirror.PageSource {
+	return func(pageSize int32, bookmark string) (*cuckoofilter.RevocationsPage, error) {
+		*calls++
+		offset := 0
+		if bookmark != "" {
+			offset, _ = strconv.Atoi(bookmark)
+		}
+		end := offset + int(pageSize)
+		page := &cuckoofilter.RevocationsPage{}
+		if end < len(revocations) {
+			page.Bookmark = strconv.Itoa(end)
+		} else {
+			end = len(revocations)
+		}
+		page.Revocations = revocations[offset:end]
+		return page, nil
+	}
+}
+
+func TestMirror_HandleBlock(t *testing.T) {
+	m := &mirror.Mirror{Store: &mirror.MemoryStore{}}
+	block := &client.BlockEvents{Number: 1, Events: []client.ChaincodeEvent{
+		filterChanged(t, cuckoofilter.FilterChangeInserted, "credential-1", "credential-2"),
+		{TxID: "tx-other", Name: cuckoofilter.RegistryConfigChangedEvent, Payload: []byte(`{}`)},
+		filterChanged(t, cuckoofilter.FilterChangeDeleted, "credential-2"),
+	}}
+	require.NoError(t, m.HandleBlock(block, 1))
+	// Applying a block again changes nothing
+	require.NoError(t, m.HandleBlock(block, 1))
+
+	revoked, err := m.IsRevoked("credential-1")
+	require.NoError(t, err)
+	require.True(t, revoked)
+	revoked, err = m.IsRevoked("credential-2")
+	require.NoError(t, err)
+	require.False(t, revoked)
+
+	// Blocks of a replica that lost the lease are rejected
+	require.NoError(t, m.HandleBlock(&client.BlockEvents{Number: 2}, 2))
+	require.ErrorIs(t, m.HandleBlock(block, 1), client.ErrFenced)
+}
+
+func TestMirror_Reconcile(t *testing.T) {
+	revokedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	issuer := cuckoofilter.Inserter{MSPID: "Org1MSP", DID: "did:key:issuer"}
+	store := &mirror.MemoryStore{}
+	m := &mirror.Mirror{Store: store}
+
+	// The mirror missed the deletion of credential-0 and the insertion of credential-3, and the
+	// events carried no metadata for credential-1 and credential-2
+	require.NoError(t, m.HandleBlock(&client.BlockEvents{Number: 1, Events: []client.ChaincodeEvent{
+		filterChanged(t, cuckoofilter.FilterChangeInserted, "credential-0", "credential-1", "credential-2"),
+	}}, 1))
+	ledger := []cuckoofilter.Revocation{
+		{CredentialID: "credential-1", Issuer: issuer, RevokedAt: revokedAt, Reason: "key compromise"},
+		{CredentialID: "credential-2", Issuer: issuer, RevokedAt: revokedAt},
+		{CredentialID: "credential-3", Issuer: issuer, RevokedAt: revokedAt},
+	}
+	calls := 0
+	report, err := m.Reconcile(pagedSource(ledger, &calls), 2)
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+	require.Equal(t, 2, report.Pages)
+	require.Equal(t, 3, report.Ledger)
+	require.Equal(t, []string{"credential-3"}, report.Missing)
+	require.Equal(t, []string{"credential-0"}, report.Extra)
+	require.Equal(t, []string{"credential-1", "credential-2"}, report.Mismatched)
+	require.Equal(t, 4, report.Drift())
+
+	mirrored, err := store.All()
+	require.NoError(t, err)
+	require.Equal(t, ledger, mirrored)
+
+	// A second reconciliation finds no drift
+	report, err = m.Reconcile(pagedSource(ledger, &calls), 2)
+	require.NoError(t, err)
+	require.Zero(t, report.Drift())
+
+	metrics := m.Metrics()
+	require.Equal(t, uint64(2), metrics.Reconciliations)
+	require.Equal(t, uint64(1), metrics.Missing)
+	require.Equal(t, uint64(1), metrics.Extra)
+	require.Equal(t, uint64(2), metrics.Mismatched)
+	require.Zero(t, metrics.LastDrift)
+}
+
+func TestReconcileJob_Interval(t *testing.T) {
+	now := clock.NewManual(time.Date(2024, 3, 1, 2, 0, 0, 0, time.UTC))
+	calls := 0
+	job := &mirror.ReconcileJob{
+		Mirror: &mirror.Mirror{Store: &mirror.MemoryStore{}},
+		Source: pagedSource([]cuckoofilter.Revocation{{CredentialID: "credential-1"}}, &calls),
+		Clock:  now,
+	}
+
+	report, err := job.Step()
+	require.NoError(t, err)
+	require.Equal(t, []string{"credential-1"}, report.Missing)
+	require.Equal(t, now.Now(), job.Mirror.Metrics().LastReconciled)
+
+	// Not due again before the default interval of a day passed
+	now.Advance(23 * time.Hour)
+	report, err = job.Step()
+	require.NoError(t, err)
+	require.Nil(t, report)
+	require.Equal(t, 1, calls)
+
+	now.Advance(time.Hour)
+	report, err = job.Step()
+	require.NoError(t, err)
+	require.NotNil(t, report)
+	require.Equal(t, 2, calls)
+}
@@ -0,0 +1,132 @@
+package mirror
+
+import (
+	"fmt"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"time"
+)
+
+// DefaultReconcileInterval is the interval of a ReconcileJob without one, once a night
+const DefaultReconcileInterval = 24 * time.Hour
+
+// PageSource reads a page of the revoked registry entries, e.g. by evaluating ListRevocations
+type PageSource func(pageSize int32, bookmark string) (*cuckoofilter.RevocationsPage, error)
+
+// ReconcileReport lists what one reconciliation changed in the mirror
+type ReconcileReport struct {
+	Pages      int      `json:"pages"`
+	Ledger     int      `json:"ledger"`     // Revocations on the ledger
+	Missing    []string `json:"missing"`    // Credential IDs added to the mirror
+	Extra      []string `json:"extra"`      // Credential IDs removed from the mirror
+	Mismatched []string `json:"mismatched"` // Credential IDs whose metadata was replaced
+}
+
+// Drift is the number of differences the reconciliation healed
+func (r *ReconcileReport) Drift() int {
+	return len(r.Missing) + len(r.Extra) + len(r.Mismatched)
+}
+
+// Reconcile pages through all revocations on the ledger and makes the mirror match them exactly.
+// Blocks are not applied while it runs; a block committed during the paging may be reported as drift
+// and is healed again by the next reconciliation.
+func (m *Mirror) Reconcile(source PageSource, pageSize int32) (*ReconcileReport, error) {
+	return m.reconcile(source, pageSize, clock.System.Now())
+}
+
+// reconcile runs a reconciliation and records it as taken at now
+func (m *Mirror) reconcile(source PageSource, pageSize int32, now time.Time) (*ReconcileReport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	report := &ReconcileReport{Missing: []string{}, Extra: []string{}, Mismatched: []string{}}
+	onLedger := make(map[string]bool)
+	bookmark := ""
+	for {
+		page, err := source(pageSize, bookmark)
+		if err != nil {
+			return nil, fmt.Errorf("error reading revocations page %d: %v", report.Pages+1, err)
+		}
+		report.Pages++
+		for _, revocation := range page.Revocations {
+			onLedger[revocation.CredentialID] = true
+			report.Ledger++
+			mirrored, err := m.Store.Get(revocation.CredentialID)
+			if err != nil {
+				return nil, err
+			}
+			if mirrored != nil && equal(*mirrored, revocation) {
+				continue
+			}
+			if err := m.Store.Put(revocation); err != nil {
+				return nil, err
+			}
+			if mirrored == nil {
+				report.Missing = append(report.Missing, revocation.CredentialID)
+			} else {
+				report.Mismatched = append(report.Mismatched, revocation.CredentialID)
+			}
+		}
+		if page.Bookmark == "" || page.Bookmark == bookmark {
+			break
+		}
+		bookmark = page.Bookmark
+	}
+
+	mirrored, err := m.Store.All()
+	if err != nil {
+		return nil, err
+	}
+	for _, revocation := range mirrored {
+		if onLedger[revocation.CredentialID] {
+			continue
+		}
+		if err := m.Store.Delete(revocation.CredentialID); err != nil {
+			return nil, err
+		}
+		report.Extra = append(report.Extra, revocation.CredentialID)
+	}
+
+	m.metrics.Reconciliations++
+	m.metrics.Missing += uint64(len(report.Missing))
+	m.metrics.Extra += uint64(len(report.Extra))
+	m.metrics.Mismatched += uint64(len(report.Mismatched))
+	m.metrics.LastDrift = uint64(report.Drift())
+	m.metrics.LastReconciled = now
+	return report, nil
+}
+
+// equal reports whether two revocations carry the same metadata
+func equal(a, b cuckoofilter.Revocation) bool {
+	return a.CredentialID == b.CredentialID && a.Issuer == b.Issuer && a.RevokedAt.Equal(b.RevokedAt) && a.Reason == b.Reason
+}
+
+// ReconcileJob reconciles a mirror once per interval. The owner calls Step periodically, e.g. from the
+// loop that steps the client.Listener, so reconciliation and event handling never overlap.
+type ReconcileJob struct {
+	Mirror   *Mirror
+	Source   PageSource
+	PageSize int32         // Defaults to cuckoofilter.MaxRevocationsPageSize
+	Interval time.Duration // Defaults to DefaultReconcileInterval
+	Clock    clock.Clock   // Optional, defaults to clock.System
+}
+
+// Step reconciles the mirror if the interval passed since the last reconciliation and returns its
+// report, nil if none was due
+func (j *ReconcileJob) Step() (*ReconcileReport, error) {
+	interval := j.Interval
+	if interval <= 0 {
+		interval = DefaultReconcileInterval
+	}
+	pageSize := j.PageSize
+	if pageSize <= 0 {
+		pageSize = cuckoofilter.MaxRevocationsPageSize
+	}
+	now := clock.Or(j.Clock).Now()
+	last := j.Mirror.Metrics().LastReconciled
+	if !last.IsZero() && now.Sub(last) < interval {
+		return nil, nil
+	}
+
+	return j.Mirror.reconcile(j.Source, pageSize, now)
+}
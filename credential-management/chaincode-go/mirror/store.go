@@ -0,0 +1,161 @@
+package mirror
+
+import (
+	"database/sql"
+	"fmt"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store for tests and short-lived verifiers
+type MemoryStore struct {
+	mu          sync.Mutex
+	revocations map[string]cuckoofilter.Revocation
+}
+
+// Get implements Store
+func (s *MemoryStore) Get(credentialID string) (*cuckoofilter.Revocation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	revocation, ok := s.revocations[credentialID]
+	if !ok {
+		return nil, nil
+	}
+	return &revocation, nil
+}
+
+// Put implements Store
+func (s *MemoryStore) Put(revocation cuckoofilter.Revocation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.revocations == nil {
+		s.revocations = make(map[string]cuckoofilter.Revocation)
+	}
+	s.revocations[revocation.CredentialID] = revocation
+	return nil
+}
+
+// Delete implements Store
+func (s *MemoryStore) Delete(credentialID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.revocations, credentialID)
+	return nil
+}
+
+// All implements Store
+func (s *MemoryStore) All() ([]cuckoofilter.Revocation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	revocations := make([]cuckoofilter.Revocation, 0, len(s.revocations))
+	for _, revocation := range s.revocations {
+		revocations = append(revocations, revocation)
+	}
+	sort.Slice(revocations, func(i, j int) bool {
+		return revocations[i].CredentialID < revocations[j].CredentialID
+	})
+	return revocations, nil
+}
+
+// sqliteSchema creates the table of a SQLStore
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS revocations (
+	credential_id TEXT PRIMARY KEY,
+	issuer_msp_id TEXT NOT NULL,
+	issuer_did TEXT NOT NULL,
+	revoked_at TEXT NOT NULL,
+	reason TEXT NOT NULL
+)`
+
+// SQLStore is a Store in a SQLite database, so the mirror survives restarts. The chaincode module does
+// not link a driver: the verifier imports one, e.g. modernc.org/sqlite or github.com/mattn/go-sqlite3,
+// opens the database and passes it to OpenSQLStore.
+type SQLStore struct {
+	db *sql.DB
+}
+
+// OpenSQLStore creates the revocations table if needed
+func OpenSQLStore(db *sql.DB) (*SQLStore, error) {
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return nil, fmt.Errorf("error creating mirror table: %v", err)
+	}
+	return &SQLStore{db: db}, nil
+}
+
+// Get implements Store
+func (s *SQLStore) Get(credentialID string) (*cuckoofilter.Revocation, error) {
+	row := s.db.QueryRow(`SELECT credential_id, issuer_msp_id, issuer_did, revoked_at, reason FROM revocations WHERE credential_id = ?`, credentialID)
+	revocation, err := scanRevocation(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading mirrored revocation: %v", err)
+	}
+	return revocation, nil
+}
+
+// Put implements Store
+func (s *SQLStore) Put(revocation cuckoofilter.Revocation) error {
+	_, err := s.db.Exec(`INSERT INTO revocations (credential_id, issuer_msp_id, issuer_did, revoked_at, reason) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(credential_id) DO UPDATE SET issuer_msp_id = excluded.issuer_msp_id, issuer_did = excluded.issuer_did,
+		revoked_at = excluded.revoked_at, reason = excluded.reason`,
+		revocation.CredentialID, revocation.Issuer.MSPID, revocation.Issuer.DID, formatTime(revocation.RevokedAt), revocation.Reason)
+	if err != nil {
+		return fmt.Errorf("error saving mirrored revocation: %v", err)
+	}
+	return nil
+}
+
+// Delete implements Store
+func (s *SQLStore) Delete(credentialID string) error {
+	if _, err := s.db.Exec(`DELETE FROM revocations WHERE credential_id = ?`, credentialID); err != nil {
+		return fmt.Errorf("error deleting mirrored revocation: %v", err)
+	}
+	return nil
+}
+
+// All implements Store
+func (s *SQLStore) All() ([]cuckoofilter.Revocation, error) {
+	rows, err := s.db.Query(`SELECT credential_id, issuer_msp_id, issuer_did, revoked_at, reason FROM revocations ORDER BY credential_id`)
+	if err != nil {
+		return nil, fmt.Errorf("error reading mirrored revocations: %v", err)
+	}
+	defer rows.Close()
+
+	revocations := []cuckoofilter.Revocation{}
+	for rows.Next() {
+		revocation, err := scanRevocation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error reading mirrored revocations: %v", err)
+		}
+		revocations = append(revocations, *revocation)
+	}
+	return revocations, rows.Err()
+}
+
+// scanRevocation reads a revocation from a row of the revocations table
+func scanRevocation(row interface{ Scan(...interface{}) error }) (*cuckoofilter.Revocation, error) {
+	var revocation cuckoofilter.Revocation
+	var revokedAt string
+	if err := row.Scan(&revocation.CredentialID, &revocation.Issuer.MSPID, &revocation.Issuer.DID, &revokedAt, &revocation.Reason); err != nil {
+		return nil, err
+	}
+	if revokedAt != "" {
+		t, err := time.Parse(time.RFC3339Nano, revokedAt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid revocation time %q: %v", revokedAt, err)
+		}
+		revocation.RevokedAt = t
+	}
+	return &revocation, nil
+}
+
+// formatTime stores times as RFC 3339 text, SQLite has no time type; the zero time is stored empty
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
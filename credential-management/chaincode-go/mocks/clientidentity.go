@@ -0,0 +1,42 @@
+package mocks
+
+import (
+	"crypto/x509"
+	"fmt"
+)
+
+// ClientIdentity is a fixed client identity returned by a mocked GetClientIdentity
+type ClientIdentity struct {
+	ID         string
+	MSPID      string
+	Attributes map[string]string
+}
+
+// GetID returns the client ID
+func (c *ClientIdentity) GetID() (string, error) {
+	return c.ID, nil
+}
+
+// GetMSPID returns the client's MSP ID
+func (c *ClientIdentity) GetMSPID() (string, error) {
+	return c.MSPID, nil
+}
+
+// GetAttributeValue returns the value of an attribute
+func (c *ClientIdentity) GetAttributeValue(attrName string) (string, bool, error) {
+	value, ok := c.Attributes[attrName]
+	return value, ok, nil
+}
+
+// AssertAttributeValue fails unless the attribute has the given value
+func (c *ClientIdentity) AssertAttributeValue(attrName, attrValue string) error {
+	if value, ok := c.Attributes[attrName]; !ok || value != attrValue {
+		return fmt.Errorf("attribute '%s' does not have value '%s'", attrName, attrValue)
+	}
+	return nil
+}
+
+// GetX509Certificate returns nil, the mocked identity has no certificate
+func (c *ClientIdentity) GetX509Certificate() (*x509.Certificate, error) {
+	return nil, nil
+}
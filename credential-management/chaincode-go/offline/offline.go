@@ -0,0 +1,117 @@
+// Package offline lets wallets and verifier apps check revocations without a connection to the ledger.
+// It loads a filter snapshot exported with ExportFilterSnapshot, or an inclusion proof from
+// GetInclusionProof, checks it against a commitment anchored on the ledger and answers lookups locally.
+//
+// The package depends on the standard library and the core package only, not on the chaincode or the
+// Fabric SDKs, so apps can embed it without the registry's dependencies.
+package offline
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/pherbke/credential-management/chaincode-go/core"
+	"io"
+)
+
+// SnapshotVersion is the version of the filter snapshots the package reads
+const SnapshotVersion = 1
+
+// MaxSnapshotSize is the largest uncompressed filter LoadSnapshot accepts
+const MaxSnapshotSize = 256 << 20
+
+// ErrCommitmentMismatch is returned when a snapshot or proof does not match the anchored commitment
+var ErrCommitmentMismatch = errors.New("does not match the anchored commitment")
+
+// Commitment is what a verifier trusts about a filter, read from the ledger through endorsed queries or
+// a block it validated: the Merkle root returned by GetFilterMerkleRoot and, for snapshots, optionally
+// the hash of the snapshot
+type Commitment struct {
+	MerkleRoot   string `json:"merkleRoot"`             // Hex encoded Merkle root over the filter buckets
+	SnapshotHash string `json:"snapshotHash,omitempty"` // Hex encoded SHA-256 of the uncompressed filter state
+}
+
+// Snapshot is a filter snapshot in the format written by ExportFilterSnapshot
+type Snapshot struct {
+	Version  int    `json:"version"`
+	FilterID string `json:"filterId"`
+	Data     string `json:"data"` // Base64 of the gzip compressed filter state
+	Hash     string `json:"hash"` // Hex encoded SHA-256 of the uncompressed filter state
+}
+
+// Filter is a filter loaded from a snapshot that matched its commitment
+type Filter struct {
+	*core.Filter
+	FilterID   string
+	Commitment Commitment
+}
+
+// LoadSnapshot decodes a snapshot and checks it against the commitment. The filter must match the
+// Merkle root, and the snapshot hash if the commitment holds one.
+func LoadSnapshot(snapshotJSON []byte, commitment Commitment) (*Filter, error) {
+	var snapshot Snapshot
+	if err := json.Unmarshal(snapshotJSON, &snapshot); err != nil {
+		return nil, fmt.Errorf("error decoding snapshot: %v", err)
+	}
+	if snapshot.Version != SnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", snapshot.Version)
+	}
+	filterJSON, err := decompress(snapshot.Data)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(filterJSON)
+	if hex.EncodeToString(hash[:]) != snapshot.Hash {
+		return nil, errors.New("snapshot does not match its hash")
+	}
+	if commitment.SnapshotHash != "" && commitment.SnapshotHash != snapshot.Hash {
+		return nil, fmt.Errorf("snapshot hash %s %w", snapshot.Hash, ErrCommitmentMismatch)
+	}
+
+	filter, err := core.ParseFilter(filterJSON)
+	if err != nil {
+		return nil, err
+	}
+	if root := hex.EncodeToString(filter.MerkleRoot()); root != commitment.MerkleRoot {
+		return nil, fmt.Errorf("Merkle root %s %w", root, ErrCommitmentMismatch)
+	}
+	return &Filter{Filter: filter, FilterID: snapshot.FilterID, Commitment: commitment}, nil
+}
+
+// VerifyProof checks an inclusion proof for data against the commitment and reports whether data is
+// revoked
+func VerifyProof(proofJSON []byte, data []byte, commitment Commitment) (bool, error) {
+	var proof core.InclusionProof
+	if err := json.Unmarshal(proofJSON, &proof); err != nil {
+		return false, fmt.Errorf("error decoding inclusion proof: %v", err)
+	}
+	if proof.Root != commitment.MerkleRoot {
+		return false, fmt.Errorf("proof root %s %w", proof.Root, ErrCommitmentMismatch)
+	}
+	return proof.Verify(data)
+}
+
+// decompress decodes the base64 gzip data of a snapshot, up to MaxSnapshotSize bytes
+func decompress(data string) ([]byte, error) {
+	compressed, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding snapshot data: %v", err)
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing snapshot: %v", err)
+	}
+	filterJSON, err := io.ReadAll(io.LimitReader(reader, MaxSnapshotSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing snapshot: %v", err)
+	}
+	if len(filterJSON) > MaxSnapshotSize {
+		return nil, fmt.Errorf("snapshot exceeds %d bytes", MaxSnapshotSize)
+	}
+	return filterJSON, nil
+}
@@ -0,0 +1,80 @@
+package offline_test
+
+import (
+	"encoding/json"
+	"go/build"
+	"strings"
+	"testing"
+
+	"github.com/pherbke/credential-management/chaincode-go/offline"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+)
+
+// newRevokedRegistry returns a registry with two revoked credentials and the commitment of its default filter
+func newRevokedRegistry(t *testing.T) (*simulator.Simulator, *simulator.Identity, offline.Commitment) {
+	sim, err := simulator.New("credential-management", &cuckoofilter.SmartContract{})
+	require.NoError(t, err)
+	admin, err := simulator.NewIdentityWithAttributes("Org1MSP", "registry-admin", map[string]string{cuckoofilter.RegistryAdminAttribute: "true"})
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "Init", "", "100", "4", "0")
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "BatchInsert", "", `["credential-1","credential-2"]`)
+	require.NoError(t, err)
+	root, err := sim.Evaluate(admin, "GetFilterMerkleRoot", "")
+	require.NoError(t, err)
+	return sim, admin, offline.Commitment{MerkleRoot: string(root)}
+}
+
+func TestLoadSnapshot(t *testing.T) {
+	sim, admin, commitment := newRevokedRegistry(t)
+	snapshotJSON, err := sim.Evaluate(admin, "ExportFilterSnapshot", "")
+	require.NoError(t, err)
+
+	filter, err := offline.LoadSnapshot(snapshotJSON, commitment)
+	require.NoError(t, err)
+	require.True(t, filter.Lookup([]byte("credential-1")))
+	require.False(t, filter.Lookup([]byte("credential-3")))
+
+	// A snapshot of another state does not match the commitment
+	_, err = sim.Submit(admin, "Insert", "", "credential-3")
+	require.NoError(t, err)
+	newerJSON, err := sim.Evaluate(admin, "ExportFilterSnapshot", "")
+	require.NoError(t, err)
+	_, err = offline.LoadSnapshot(newerJSON, commitment)
+	require.ErrorIs(t, err, offline.ErrCommitmentMismatch)
+
+	var snapshot offline.Snapshot
+	require.NoError(t, json.Unmarshal(snapshotJSON, &snapshot))
+	commitment.SnapshotHash = "00" + snapshot.Hash[2:]
+	_, err = offline.LoadSnapshot(snapshotJSON, commitment)
+	require.ErrorIs(t, err, offline.ErrCommitmentMismatch)
+}
+
+func TestVerifyProof(t *testing.T) {
+	sim, admin, commitment := newRevokedRegistry(t)
+	for data, expected := range map[string]bool{"credential-2": true, "credential-3": false} {
+		proofJSON, err := sim.Evaluate(admin, "GetInclusionProof", "", data)
+		require.NoError(t, err)
+		revoked, err := offline.VerifyProof(proofJSON, []byte(data), commitment)
+		require.NoError(t, err)
+		require.Equal(t, expected, revoked, data)
+	}
+
+	proofJSON, err := sim.Evaluate(admin, "GetInclusionProof", "", "credential-2")
+	require.NoError(t, err)
+	_, err = offline.VerifyProof(proofJSON, []byte("credential-2"), offline.Commitment{MerkleRoot: strings.Repeat("0", 64)})
+	require.ErrorIs(t, err, offline.ErrCommitmentMismatch)
+}
+
+// TestDependencies keeps the package free of the chaincode and the Fabric SDKs
+func TestDependencies(t *testing.T) {
+	pkg, err := build.Default.ImportDir(".", 0)
+	require.NoError(t, err)
+	for _, path := range pkg.Imports {
+		if strings.Contains(path, ".") {
+			require.Equal(t, "github.com/pherbke/credential-management/chaincode-go/core", path)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+// Package quotient is an experimental quotient filter backend for the revocation registry.
+//
+// A quotient filter stores the fingerprints of its entries sorted by their quotient in one flat table,
+// so two filters of the same shape merge in a single linear pass, e.g. when rotating epochs or merging
+// the filters of federated registries. It implements cuckoofilter.MembershipFilter; see the benchmarks
+// for how it compares with the cuckoo filter.
+package quotient
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	metro "github.com/dgryski/go-metro"
+)
+
+// ErrShapeMismatch is returned by Merge for filters with different quotient or remainder sizes
+var ErrShapeMismatch = errors.New("quotient filters differ in shape")
+
+// ErrFull is returned by Merge when the merged entries do not fit the filter
+var ErrFull = errors.New("quotient filter is full")
+
+// hashSeed matches the seed of the cuckoo filter fingerprints
+const hashSeed = 1337
+
+// Slot metadata bits, stored below the remainder
+const (
+	occupiedBit     = 1 << 0 // The slot is the canonical slot of some entry
+	continuationBit = 1 << 1 // The entry continues the run of the previous slot
+	shiftedBit      = 1 << 2 // The entry is not in its canonical slot
+	metadataBits    = 3
+)
+
+// Filter is a quotient filter with 2^q slots holding r-bit remainders
+type Filter struct {
+	qBits   uint
+	rBits   uint
+	mask    uint64 // Slot index mask
+	slots   []uint64
+	entries uint
+}
+
+// New returns a quotient filter with 2^qBits slots and rBits remainders. The false positive rate is
+// about load factor / 2^rBits.
+func New(qBits uint, rBits uint) (*Filter, error) {
+	if qBits == 0 || qBits > 32 || rBits == 0 || rBits > 64-metadataBits || qBits+rBits > 64 {
+		return nil, fmt.Errorf("unsupported quotient filter shape q=%d r=%d", qBits, rBits)
+	}
+	return &Filter{
+		qBits: qBits,
+		rBits: rBits,
+		mask:  1<<qBits - 1,
+		slots: make([]uint64, 1<<qBits),
+	}, nil
+}
+
+// Count returns the number of entries
+func (f *Filter) Count() uint {
+	return f.entries
+}
+
+// Capacity returns the number of slots
+func (f *Filter) Capacity() uint {
+	return uint(len(f.slots))
+}
+
+// LoadFactor returns the share of used slots
+func (f *Filter) LoadFactor() float64 {
+	return float64(f.entries) / float64(len(f.slots))
+}
+
+// Insert adds data, returning false when it is already present or the filter is full
+func (f *Filter) Insert(data []byte) bool {
+	quotient, remainder := f.split(metro.Hash64(data, hashSeed))
+	return f.insert(quotient, remainder)
+}
+
+// Lookup reports whether data may have been inserted
+func (f *Filter) Lookup(data []byte) bool {
+	quotient, remainder := f.split(metro.Hash64(data, hashSeed))
+	if f.slots[quotient]&occupiedBit == 0 {
+		return false
+	}
+	s := f.findRun(quotient)
+	for {
+		r := f.slots[s] >> metadataBits
+		if r == remainder {
+			return true
+		}
+		if r > remainder {
+			return false
+		}
+		s = f.next(s)
+		if f.slots[s]&continuationBit == 0 {
+			return false
+		}
+	}
+}
+
+// Delete removes data, returning false when it was not found
+func (f *Filter) Delete(data []byte) bool {
+	quotient, remainder := f.split(metro.Hash64(data, hashSeed))
+	return f.remove(quotient, remainder)
+}
+
+// Merge adds the entries of other, which must have the same shape, in one pass over both tables
+func (f *Filter) Merge(other *Filter) error {
+	if f.qBits != other.qBits || f.rBits != other.rBits {
+		return ErrShapeMismatch
+	}
+	mine, theirs := f.fingerprints(), other.fingerprints()
+	merged := make([]uint64, 0, len(mine)+len(theirs))
+	for len(mine) > 0 || len(theirs) > 0 {
+		switch {
+		case len(theirs) == 0 || (len(mine) > 0 && mine[0] < theirs[0]):
+			merged, mine = append(merged, mine[0]), mine[1:]
+		case len(mine) == 0 || theirs[0] < mine[0]:
+			merged, theirs = append(merged, theirs[0]), theirs[1:]
+		default:
+			merged, mine, theirs = append(merged, mine[0]), mine[1:], theirs[1:]
+		}
+	}
+	if uint(len(merged)) > uint(len(f.slots)) {
+		return ErrFull
+	}
+
+	// Inserted in ascending order, every entry is appended to its run without moving other entries
+	rebuilt := &Filter{qBits: f.qBits, rBits: f.rBits, mask: f.mask, slots: make([]uint64, len(f.slots))}
+	for _, fp := range merged {
+		rebuilt.insert(fp>>f.rBits, fp&(1<<f.rBits-1))
+	}
+	*f = *rebuilt
+	return nil
+}
+
+// split returns the quotient and remainder taken from the top q+r bits of a hash
+func (f *Filter) split(hash uint64) (uint64, uint64) {
+	fp := hash >> (64 - f.qBits - f.rBits)
+	return fp >> f.rBits, fp & (1<<f.rBits - 1)
+}
+
+func (f *Filter) next(s uint64) uint64 {
+	return (s + 1) & f.mask
+}
+
+func (f *Filter) prev(s uint64) uint64 {
+	return (s - 1) & f.mask
+}
+
+func isEmpty(slot uint64) bool {
+	return slot&(occupiedBit|continuationBit|shiftedBit) == 0
+}
+
+func isClusterStart(slot uint64) bool {
+	return slot&occupiedBit != 0 && slot&continuationBit == 0 && slot&shiftedBit == 0
+}
+
+func isRunStart(slot uint64) bool {
+	return slot&continuationBit == 0 && slot&(occupiedBit|shiftedBit) != 0
+}
+
+// findRun returns the slot at which the run of quotient starts. The quotient must be occupied.
+func (f *Filter) findRun(quotient uint64) uint64 {
+	// Walk back to the start of the cluster, then forward run by run
+	b := quotient
+	for f.slots[b]&shiftedBit != 0 {
+		b = f.prev(b)
+	}
+	s := b
+	for b != quotient {
+		for {
+			s = f.next(s)
+			if f.slots[s]&continuationBit == 0 {
+				break
+			}
+		}
+		for {
+			b = f.next(b)
+			if f.slots[b]&occupiedBit != 0 {
+				break
+			}
+		}
+	}
+	return s
+}
+
+// shiftInto places entry at slot s and shifts the following entries of the cluster one slot right.
+// Occupied bits belong to the slots and stay in place.
+func (f *Filter) shiftInto(s uint64, entry uint64) {
+	current := entry
+	for {
+		previous := f.slots[s]
+		empty := isEmpty(previous)
+		if !empty {
+			previous |= shiftedBit
+			if previous&occupiedBit != 0 {
+				current |= occupiedBit
+				previous &^= occupiedBit
+			}
+		}
+		f.slots[s] = current
+		current = previous
+		s = f.next(s)
+		if empty {
+			return
+		}
+	}
+}
+
+func (f *Filter) insert(quotient uint64, remainder uint64) bool {
+	if f.entries >= uint(len(f.slots)) {
+		return false
+	}
+	canonical := f.slots[quotient]
+	entry := remainder << metadataBits
+	if isEmpty(canonical) {
+		f.slots[quotient] = entry | occupiedBit
+		f.entries++
+		return true
+	}
+	if canonical&occupiedBit == 0 {
+		f.slots[quotient] |= occupiedBit
+	}
+
+	start := f.findRun(quotient)
+	s := start
+	if canonical&occupiedBit != 0 {
+		// The run exists, find the position of the remainder in it
+		for {
+			r := f.slots[s] >> metadataBits
+			if r == remainder {
+				return false
+			}
+			if r > remainder {
+				break
+			}
+			s = f.next(s)
+			if f.slots[s]&continuationBit == 0 {
+				break
+			}
+		}
+		if s == start {
+			// The old start of the run continues it
+			f.slots[start] |= continuationBit
+		} else {
+			entry |= continuationBit
+		}
+	}
+	if s != quotient {
+		entry |= shiftedBit
+	}
+	f.shiftInto(s, entry)
+	f.entries++
+	return true
+}
+
+func (f *Filter) remove(quotient uint64, remainder uint64) bool {
+	canonical := f.slots[quotient]
+	if canonical&occupiedBit == 0 || f.entries == 0 {
+		return false
+	}
+	s := f.findRun(quotient)
+	for {
+		r := f.slots[s] >> metadataBits
+		if r == remainder {
+			break
+		}
+		if r > remainder {
+			return false
+		}
+		s = f.next(s)
+		if f.slots[s]&continuationBit == 0 {
+			return false
+		}
+	}
+
+	killed := f.slots[s]
+	replaceRunStart := isRunStart(killed)
+	if replaceRunStart && f.slots[f.next(s)]&continuationBit == 0 {
+		// The last entry of the run goes, so no entry has this canonical slot any more
+		f.slots[quotient] &^= occupiedBit
+	}
+	f.deleteEntry(s, quotient)
+
+	if replaceRunStart {
+		next := f.slots[s]
+		updated := next
+		if updated&continuationBit != 0 {
+			// The next entry starts the run now
+			updated &^= continuationBit
+		}
+		if s == quotient && isRunStart(updated) {
+			// and sits in its canonical slot
+			updated &^= shiftedBit
+		}
+		f.slots[s] = updated
+	}
+	f.entries--
+	return true
+}
+
+// deleteEntry removes the entry at slot s and shifts the rest of the cluster one slot left,
+// clearing the shifted bit of runs that reach their canonical slot again
+func (f *Filter) deleteEntry(s uint64, quotient uint64) {
+	current := f.slots[s]
+	sp := f.next(s)
+	origin := s
+	for {
+		next := f.slots[sp]
+		currentOccupied := current&occupiedBit != 0
+		if isEmpty(next) || isClusterStart(next) || sp == origin {
+			f.slots[s] = 0
+			if currentOccupied {
+				f.slots[s] = occupiedBit
+			}
+			return
+		}
+		updated := next
+		if isRunStart(next) {
+			for {
+				quotient = f.next(quotient)
+				if f.slots[quotient]&occupiedBit != 0 {
+					break
+				}
+			}
+			if currentOccupied && quotient == s {
+				updated &^= shiftedBit
+			}
+		}
+		if currentOccupied {
+			updated |= occupiedBit
+		} else {
+			updated &^= occupiedBit
+		}
+		f.slots[s] = updated
+		s = sp
+		sp = f.next(sp)
+		current = next
+	}
+}
+
+// fingerprints returns the quotient and remainder of every entry as q+r bit values in ascending order
+func (f *Filter) fingerprints() []uint64 {
+	fps := make([]uint64, 0, f.entries)
+	if f.entries == 0 {
+		return fps
+	}
+	start := uint64(0)
+	for !isClusterStart(f.slots[start]) {
+		start++
+	}
+
+	// Every non-empty slot holds an entry, whose quotient follows from the runs seen since the cluster start
+	quotient := start
+	for i, s := 0, start; i < len(f.slots); i, s = i+1, f.next(s) {
+		slot := f.slots[s]
+		if isEmpty(slot) {
+			continue
+		}
+		if isClusterStart(slot) {
+			quotient = s
+		} else if isRunStart(slot) {
+			for {
+				quotient = f.next(quotient)
+				if f.slots[quotient]&occupiedBit != 0 {
+					break
+				}
+			}
+		}
+		fps = append(fps, quotient<<f.rBits|slot>>metadataBits)
+	}
+	sort.Slice(fps, func(i, j int) bool { return fps[i] < fps[j] })
+	return fps
+}
@@ -0,0 +1,182 @@
+package quotient_test
+
+import (
+	"fmt"
+	"github.com/pherbke/credential-management/chaincode-go/quotient"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"testing"
+)
+
+var _ cuckoofilter.MembershipFilter = (*quotient.Filter)(nil)
+
+func newFilter(t testing.TB, qBits uint) *quotient.Filter {
+	filter, err := quotient.New(qBits, 20)
+	require.NoError(t, err)
+	return filter
+}
+
+func TestNew_RejectsShape(t *testing.T) {
+	_, err := quotient.New(0, 8)
+	require.Error(t, err)
+	_, err = quotient.New(16, 62)
+	require.Error(t, err)
+}
+
+func TestFilter_MatchesSet(t *testing.T) {
+	filter := newFilter(t, 10)
+	rng := rand.New(rand.NewSource(1))
+	present := make(map[string]bool)
+
+	// Keep the filter well filled so clusters wrap around and runs shift
+	for i := 0; i < 20000; i++ {
+		data := fmt.Sprintf("credential-%d", rng.Intn(1200))
+		switch {
+		case rng.Intn(3) > 0 && filter.Count() < filter.Capacity():
+			require.Equal(t, !present[data], filter.Insert([]byte(data)), data)
+			present[data] = true
+		default:
+			require.Equal(t, present[data], filter.Delete([]byte(data)), data)
+			delete(present, data)
+		}
+		require.Equal(t, uint(len(present)), filter.Count())
+	}
+	for data := range present {
+		require.True(t, filter.Lookup([]byte(data)), data)
+	}
+}
+
+func TestFilter_Full(t *testing.T) {
+	filter := newFilter(t, 4)
+	inserted := 0
+	for i := 0; i < 100; i++ {
+		if filter.Insert([]byte(fmt.Sprintf("credential-%d", i))) {
+			inserted++
+		}
+	}
+	require.Equal(t, 16, inserted)
+	require.Equal(t, 1.0, filter.LoadFactor())
+}
+
+func TestMerge(t *testing.T) {
+	a, b := newFilter(t, 12), newFilter(t, 12)
+	for i := 0; i < 1500; i++ {
+		a.Insert([]byte(fmt.Sprintf("credential-%d", i)))
+		b.Insert([]byte(fmt.Sprintf("credential-%d", i+1000)))
+	}
+
+	require.NoError(t, a.Merge(b))
+	require.Equal(t, uint(2500), a.Count())
+	for i := 0; i < 2500; i++ {
+		require.True(t, a.Lookup([]byte(fmt.Sprintf("credential-%d", i))))
+	}
+
+	// The merged filter stays usable
+	require.True(t, a.Delete([]byte("credential-0")))
+	require.False(t, a.Lookup([]byte("credential-0")))
+	require.True(t, a.Insert([]byte("credential-0")))
+}
+
+func TestMerge_Errors(t *testing.T) {
+	a, err := quotient.New(4, 8)
+	require.NoError(t, err)
+	b, err := quotient.New(5, 8)
+	require.NoError(t, err)
+	require.Equal(t, quotient.ErrShapeMismatch, a.Merge(b))
+
+	b, err = quotient.New(4, 8)
+	require.NoError(t, err)
+	for i := 0; i < 12; i++ {
+		a.Insert([]byte(fmt.Sprintf("credential-%d", i)))
+		b.Insert([]byte(fmt.Sprintf("credential-%d", i+100)))
+	}
+	require.Equal(t, quotient.ErrFull, a.Merge(b))
+}
+
+// The benchmarks fill both filters to three quarters of the quotient filter's slots
+
+const benchmarkEntries = 3 << 14
+
+func benchmarkData(n int, offset int) [][]byte {
+	data := make([][]byte, n)
+	for i := range data {
+		data[i] = []byte(fmt.Sprintf("credential-%d", i+offset))
+	}
+	return data
+}
+
+func filledQuotient(b *testing.B, data [][]byte) *quotient.Filter {
+	filter := newFilter(b, 16)
+	for _, d := range data {
+		filter.Insert(d)
+	}
+	return filter
+}
+
+func filledCuckoo(data [][]byte) *cuckoofilter.Filter {
+	filter := cuckoofilter.NewFilter(benchmarkEntries*2, 4, cuckoofilter.FingerPrintSize)
+	for _, d := range data {
+		filter.Insert(d)
+	}
+	return filter
+}
+
+func BenchmarkInsert(b *testing.B) {
+	data := benchmarkData(benchmarkEntries, 0)
+	b.Run("Quotient", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			filledQuotient(b, data)
+		}
+	})
+	b.Run("Cuckoo", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			filledCuckoo(data)
+		}
+	})
+}
+
+func BenchmarkLookup(b *testing.B) {
+	data := benchmarkData(benchmarkEntries, 0)
+	probes := benchmarkData(benchmarkEntries, benchmarkEntries/2)
+	b.Run("Quotient", func(b *testing.B) {
+		filter := filledQuotient(b, data)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			filter.Lookup(probes[i%len(probes)])
+		}
+	})
+	b.Run("Cuckoo", func(b *testing.B) {
+		filter := filledCuckoo(data)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			filter.Lookup(probes[i%len(probes)])
+		}
+	})
+}
+
+// BenchmarkMerge merges two half-filled filters. The cuckoo filter has no merge, so its baseline
+// re-inserts the data of the second filter, which needs the original data rather than the filter.
+func BenchmarkMerge(b *testing.B) {
+	first := benchmarkData(benchmarkEntries/2, 0)
+	second := benchmarkData(benchmarkEntries/2, benchmarkEntries/2)
+	b.Run("Quotient", func(b *testing.B) {
+		other := filledQuotient(b, second)
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			filter := filledQuotient(b, first)
+			b.StartTimer()
+			require.NoError(b, filter.Merge(other))
+		}
+	})
+	b.Run("Cuckoo", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			filter := filledCuckoo(first)
+			b.StartTimer()
+			for _, d := range second {
+				filter.Insert(d)
+			}
+		}
+	})
+}
@@ -0,0 +1,135 @@
+package simulator
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// NewProposal creates the signed proposal of a transaction without running it. It takes the next
+// transaction ID and timestamp of the simulator, so simulators kept in step, as peers of one channel,
+// can all endorse it with ProcessProposal.
+func (s *Simulator) NewProposal(identity *Identity, function string, args ...string) (*peer.SignedProposal, error) {
+	if identity == nil {
+		return nil, fmt.Errorf("an identity is required to propose %s", function)
+	}
+	txID, txTime := s.nextTransaction()
+	invokeArgs := [][]byte{[]byte(function)}
+	for _, arg := range args {
+		invokeArgs = append(invokeArgs, []byte(arg))
+	}
+	return signProposal(identity, s.ledger.ChannelID, s.ledger.Name, txID, timestamppb.New(txTime), invokeArgs)
+}
+
+// ProcessProposal endorses a signed proposal as a peer does: it runs the transaction against the
+// committed state without committing it and returns the proposal response with the read/write set,
+// signed by the endorser identity. A failing transaction gets a response with its error status and no
+// endorsement. The simulator records writes but no reads, and neither checks the proposal signature
+// nor ACLs.
+func (s *Simulator) ProcessProposal(endorser *Identity, signed *peer.SignedProposal) (*peer.ProposalResponse, error) {
+	if endorser == nil {
+		return nil, fmt.Errorf("an endorser identity is required")
+	}
+	proposal := &peer.Proposal{}
+	if err := proto.Unmarshal(signed.GetProposalBytes(), proposal); err != nil {
+		return nil, fmt.Errorf("error decoding proposal: %v", err)
+	}
+	header := &common.Header{}
+	if err := proto.Unmarshal(proposal.Header, header); err != nil {
+		return nil, fmt.Errorf("error decoding proposal header: %v", err)
+	}
+	channelHeader := &common.ChannelHeader{}
+	if err := proto.Unmarshal(header.ChannelHeader, channelHeader); err != nil {
+		return nil, fmt.Errorf("error decoding channel header: %v", err)
+	}
+	if channelHeader.ChannelId != s.ledger.ChannelID {
+		return nil, fmt.Errorf("proposal is for channel %s, not %s", channelHeader.ChannelId, s.ledger.ChannelID)
+	}
+	signatureHeader := &common.SignatureHeader{}
+	if err := proto.Unmarshal(header.SignatureHeader, signatureHeader); err != nil {
+		return nil, fmt.Errorf("error decoding signature header: %v", err)
+	}
+	payload := &peer.ChaincodeProposalPayload{}
+	if err := proto.Unmarshal(proposal.Payload, payload); err != nil {
+		return nil, fmt.Errorf("error decoding proposal payload: %v", err)
+	}
+	invocation := &peer.ChaincodeInvocationSpec{}
+	if err := proto.Unmarshal(payload.Input, invocation); err != nil {
+		return nil, fmt.Errorf("error decoding chaincode invocation: %v", err)
+	}
+
+	response, stub := s.invoke(signatureHeader.Creator, channelHeader.TxId, channelHeader.Timestamp, invocation.GetChaincodeSpec().GetInput().GetArgs(), payload.TransientMap, signed)
+	if response.Status >= 400 {
+		return &peer.ProposalResponse{Version: 1, Timestamp: channelHeader.Timestamp, Response: response}, nil
+	}
+
+	results, err := stub.readWriteSet()
+	if err != nil {
+		return nil, err
+	}
+	var events []byte
+	if stub.event != nil {
+		if events, err = proto.Marshal(stub.event); err != nil {
+			return nil, err
+		}
+	}
+	action, err := proto.Marshal(&peer.ChaincodeAction{
+		Results:     results,
+		Events:      events,
+		Response:    response,
+		ChaincodeId: &peer.ChaincodeID{Name: s.ledger.Name},
+	})
+	if err != nil {
+		return nil, err
+	}
+	proposalHash := sha256.Sum256(signed.ProposalBytes)
+	responsePayload, err := proto.Marshal(&peer.ProposalResponsePayload{ProposalHash: proposalHash[:], Extension: action})
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(append(append([]byte{}, responsePayload...), endorser.creator...))
+	signature, err := ecdsa.SignASN1(rand.Reader, endorser.PrivateKey, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("error signing endorsement: %v", err)
+	}
+	return &peer.ProposalResponse{
+		Version:     1,
+		Timestamp:   channelHeader.Timestamp,
+		Response:    response,
+		Payload:     responsePayload,
+		Endorsement: &peer.Endorsement{Endorser: endorser.creator, Signature: signature},
+	}, nil
+}
+
+// readWriteSet returns the buffered public writes as the read/write set of the chaincode namespace,
+// in key order as a peer writes them
+func (s *txStub) readWriteSet() ([]byte, error) {
+	keys := make([]string, 0, len(s.writes))
+	for key := range s.writes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	kvSet := &kvrwset.KVRWSet{}
+	for _, key := range keys {
+		value := s.writes[key]
+		kvSet.Writes = append(kvSet.Writes, &kvrwset.KVWrite{Key: key, IsDelete: value == nil, Value: value})
+	}
+	kvSetBytes, err := proto.Marshal(kvSet)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(&rwset.TxReadWriteSet{
+		DataModel: rwset.TxReadWriteSet_KV,
+		NsRwset:   []*rwset.NsReadWriteSet{{Namespace: s.Name, Rwset: kvSetBytes}},
+	})
+}
@@ -0,0 +1,38 @@
+package simulator_test
+
+import (
+	"fmt"
+
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+)
+
+// Revoke a credential and check its status without a Fabric network
+func Example() {
+	sim, err := simulator.New("credential-management", &cuckoofilter.SmartContract{})
+	if err != nil {
+		panic(err)
+	}
+	registry, err := simulator.NewIdentityWithAttributes("Org1MSP", "registry-admin", map[string]string{cuckoofilter.RegistryAdminAttribute: "true"})
+	if err != nil {
+		panic(err)
+	}
+
+	if _, err := sim.Submit(registry, "Init", "", "1000", "4", "0"); err != nil {
+		panic(err)
+	}
+	if _, err := sim.Submit(registry, "Insert", "", "revoked-credential"); err != nil {
+		panic(err)
+	}
+
+	for _, credential := range []string{"revoked-credential", "valid-credential"} {
+		revoked, err := sim.Evaluate(registry, "Lookup", "", credential)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("%s revoked: %s\n", credential, revoked)
+	}
+	// Output:
+	// revoked-credential revoked: true
+	// valid-credential revoked: false
+}
@@ -0,0 +1,76 @@
+package simulator
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/msp"
+)
+
+// Identity is a client identity submitting transactions, as issued by an organization's CA
+type Identity struct {
+	MSPID       string
+	Certificate *x509.Certificate
+	PrivateKey  *ecdsa.PrivateKey
+	creator     []byte
+}
+
+// attributesOID is the certificate extension in which Fabric CA stores identity attributes
+var attributesOID = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}
+
+// NewIdentity creates a client identity with a self-signed certificate for the given organization
+func NewIdentity(mspID string, commonName string) (*Identity, error) {
+	return NewIdentityWithAttributes(mspID, commonName, nil)
+}
+
+// NewIdentityWithAttributes creates a client identity whose certificate carries attributes the way
+// Fabric CA enrolls them, so contracts can read them with GetAttributeValue
+func NewIdentityWithAttributes(mspID string, commonName string, attributes map[string]string) (*Identity, error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("error generating identity key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{mspID}},
+		NotBefore:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if len(attributes) > 0 {
+		attributesJSON, err := json.Marshal(map[string]map[string]string{"attrs": attributes})
+		if err != nil {
+			return nil, err
+		}
+		template.ExtraExtensions = []pkix.Extension{{Id: attributesOID, Value: attributesJSON}}
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("error creating identity certificate: %v", err)
+	}
+	certificate, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, err
+	}
+
+	creator, err := proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   mspID,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error serializing identity: %v", err)
+	}
+
+	return &Identity{MSPID: mspID, Certificate: certificate, PrivateKey: privateKey, creator: creator}, nil
+}
@@ -0,0 +1,55 @@
+package simulator
+
+import (
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// GetStateByPartialCompositeKeyWithPagination returns up to pageSize committed entries from the bookmark on.
+// As on a peer, the bookmark of the response is the key the next page starts at and empty after the last page.
+func (s *txStub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	iterator, err := s.MockStub.GetStateByPartialCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer iterator.Close()
+
+	page := &kvIterator{}
+	metadata := &peer.QueryResponseMetadata{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, nil, err
+		}
+		if entry.Key < bookmark {
+			continue
+		}
+		if pageSize > 0 && int32(len(page.entries)) == pageSize {
+			metadata.Bookmark = entry.Key
+			break
+		}
+		page.entries = append(page.entries, entry)
+	}
+	metadata.FetchedRecordsCount = int32(len(page.entries))
+	return page, metadata, nil
+}
+
+// kvIterator iterates over entries read in advance
+type kvIterator struct {
+	entries []*queryresult.KV
+}
+
+func (i *kvIterator) HasNext() bool {
+	return len(i.entries) > 0
+}
+
+func (i *kvIterator) Next() (*queryresult.KV, error) {
+	entry := i.entries[0]
+	i.entries = i.entries[1:]
+	return entry, nil
+}
+
+func (i *kvIterator) Close() error {
+	return nil
+}
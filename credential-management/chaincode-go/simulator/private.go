@@ -0,0 +1,162 @@
+package simulator
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+)
+
+// The simulated channel has a single organization, so every identity is a member of every private
+// data collection. Collections need no definition unless Simulator.DefineCollections was called.
+
+// GetTransient returns the transient data passed with the transaction
+func (s *txStub) GetTransient() (map[string][]byte, error) {
+	return s.transient, nil
+}
+
+// GetPrivateData returns the committed value of a key in a collection
+func (s *txStub) GetPrivateData(collection string, key string) ([]byte, error) {
+	if err := s.checkCollection(collection); err != nil {
+		return nil, err
+	}
+	return s.MockStub.GetPrivateData(collection, key)
+}
+
+// GetPrivateDataHash returns the SHA-256 hash of the committed value of a key in a collection, nil if
+// the key does not exist
+func (s *txStub) GetPrivateDataHash(collection string, key string) ([]byte, error) {
+	if err := s.checkCollection(collection); err != nil {
+		return nil, err
+	}
+	value, ok := s.MockStub.PvtState[collection][key]
+	if !ok {
+		return nil, nil
+	}
+	hash := sha256.Sum256(value)
+	return hash[:], nil
+}
+
+// PutPrivateData buffers a private data write until the transaction commits
+func (s *txStub) PutPrivateData(collection string, key string, value []byte) error {
+	if collection == "" {
+		return fmt.Errorf("collection must not be empty")
+	}
+	if err := s.checkCollection(collection); err != nil {
+		return err
+	}
+	if key == "" {
+		return fmt.Errorf("key must not be empty")
+	}
+	if len(value) == 0 {
+		return s.DelPrivateData(collection, key)
+	}
+	s.privateWrite(collection)[key] = value
+	return nil
+}
+
+// DelPrivateData buffers a private data delete until the transaction commits
+func (s *txStub) DelPrivateData(collection string, key string) error {
+	if collection == "" {
+		return fmt.Errorf("collection must not be empty")
+	}
+	if err := s.checkCollection(collection); err != nil {
+		return err
+	}
+	if key == "" {
+		return fmt.Errorf("key must not be empty")
+	}
+	s.privateWrite(collection)[key] = nil
+	return nil
+}
+
+// GetPrivateDataByPartialCompositeKey returns the committed private entries of a collection in key order
+func (s *txStub) GetPrivateDataByPartialCompositeKey(collection, objectType string, attributes []string) (shim.StateQueryIteratorInterface, error) {
+	if err := s.checkCollection(collection); err != nil {
+		return nil, err
+	}
+	prefix, err := s.CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for key := range s.MockStub.PvtState[collection] {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	iterator := &kvIterator{}
+	for _, key := range keys {
+		iterator.entries = append(iterator.entries, &queryresult.KV{
+			Namespace: s.Name,
+			Key:       key,
+			Value:     s.MockStub.PvtState[collection][key],
+		})
+	}
+	return iterator, nil
+}
+
+// SetPrivateDataValidationParameter buffers a key-level endorsement policy of private data until the
+// transaction commits
+func (s *txStub) SetPrivateDataValidationParameter(collection string, key string, ep []byte) error {
+	if collection == "" {
+		return fmt.Errorf("collection must not be empty")
+	}
+	if err := s.checkCollection(collection); err != nil {
+		return err
+	}
+	params, ok := s.privateParams[collection]
+	if !ok {
+		params = make(map[string][]byte)
+		s.privateParams[collection] = params
+	}
+	params[key] = ep
+	return nil
+}
+
+// checkCollection fails for collections missing from the defined collections, as a peer does for
+// collections missing from the collection config
+func (s *txStub) checkCollection(collection string) error {
+	if s.collections != nil && !s.collections[collection] {
+		return fmt.Errorf("collection %s is not defined", collection)
+	}
+	return nil
+}
+
+// privateWrite returns the buffered writes of a collection
+func (s *txStub) privateWrite(collection string) map[string][]byte {
+	writes, ok := s.privateWrites[collection]
+	if !ok {
+		writes = make(map[string][]byte)
+		s.privateWrites[collection] = writes
+	}
+	return writes
+}
+
+// commitPrivate applies the buffered private data writes to the ledger
+func (s *txStub) commitPrivate() error {
+	for collection, writes := range s.privateWrites {
+		for key, value := range writes {
+			if value == nil {
+				delete(s.MockStub.PvtState[collection], key)
+				continue
+			}
+			if err := s.MockStub.PutPrivateData(collection, key, value); err != nil {
+				return fmt.Errorf("error committing %s in collection %s: %v", key, collection, err)
+			}
+		}
+	}
+	for collection, params := range s.privateParams {
+		for key, ep := range params {
+			if err := s.MockStub.SetPrivateDataValidationParameter(collection, key, ep); err != nil {
+				return fmt.Errorf("error committing validation parameter of %s in collection %s: %v", key, collection, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package simulator
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// signProposal builds the endorser proposal of a transaction the way a client SDK does and signs it
+// with the identity's key, so contracts can read the channel header and the creator from it
+func signProposal(identity *Identity, channelID, chaincode, txID string, timestamp *timestamppb.Timestamp, args [][]byte) (*peer.SignedProposal, error) {
+	extension, err := proto.Marshal(&peer.ChaincodeHeaderExtension{ChaincodeId: &peer.ChaincodeID{Name: chaincode}})
+	if err != nil {
+		return nil, err
+	}
+	channelHeader, err := proto.Marshal(&common.ChannelHeader{
+		Type:      int32(common.HeaderType_ENDORSER_TRANSACTION),
+		ChannelId: channelID,
+		TxId:      txID,
+		Timestamp: timestamp,
+		Extension: extension,
+	})
+	if err != nil {
+		return nil, err
+	}
+	signatureHeader, err := proto.Marshal(&common.SignatureHeader{Creator: identity.creator, Nonce: []byte(txID)})
+	if err != nil {
+		return nil, err
+	}
+	header, err := proto.Marshal(&common.Header{ChannelHeader: channelHeader, SignatureHeader: signatureHeader})
+	if err != nil {
+		return nil, err
+	}
+	input, err := proto.Marshal(&peer.ChaincodeInvocationSpec{ChaincodeSpec: &peer.ChaincodeSpec{
+		Type:        peer.ChaincodeSpec_GOLANG,
+		ChaincodeId: &peer.ChaincodeID{Name: chaincode},
+		Input:       &peer.ChaincodeInput{Args: args},
+	}})
+	if err != nil {
+		return nil, err
+	}
+	// Transient data is not part of the proposal a transaction commits with
+	payload, err := proto.Marshal(&peer.ChaincodeProposalPayload{Input: input})
+	if err != nil {
+		return nil, err
+	}
+	proposal, err := proto.Marshal(&peer.Proposal{Header: header, Payload: payload})
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(proposal)
+	signature, err := ecdsa.SignASN1(rand.Reader, identity.PrivateKey, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("error signing proposal: %v", err)
+	}
+	return &peer.SignedProposal{ProposalBytes: proposal, Signature: signature}, nil
+}
@@ -0,0 +1,196 @@
+// Package simulator runs contracts against an in-memory ledger for local development.
+//
+// Transactions go through the real contractapi routing and serialization. Each transaction gets
+// a deterministic transaction ID and timestamp, sees only committed state, and its writes and
+// chaincode event are committed only when it succeeds, so example programs and end-to-end tests
+// behave like they would on a peer without needing a Fabric network.
+package simulator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// DefaultStartTime is the timestamp of the first simulated transaction
+var DefaultStartTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Transaction is the result of a submitted transaction
+type Transaction struct {
+	ID        string
+	Timestamp time.Time
+	Payload   []byte
+	Event     *peer.ChaincodeEvent // Nil when the transaction emitted no event
+}
+
+// Simulator is an in-memory channel with one chaincode
+type Simulator struct {
+	chaincode *contractapi.ContractChaincode
+	ledger    *shimtest.MockStub
+	clock     time.Time
+	tick      time.Duration
+	txCount   uint64
+	events    []*peer.ChaincodeEvent
+	// Private data collections of the chaincode definition, nil while every collection is accepted
+	collections map[string]bool
+}
+
+// New creates a simulator running the given contracts as one chaincode
+func New(name string, contracts ...contractapi.ContractInterface) (*Simulator, error) {
+	chaincode, err := contractapi.NewChaincode(contracts...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating chaincode: %v", err)
+	}
+	ledger := shimtest.NewMockStub(name, chaincode)
+	ledger.ChannelID = "mychannel"
+
+	return &Simulator{
+		chaincode: chaincode,
+		ledger:    ledger,
+		clock:     DefaultStartTime,
+		tick:      time.Second,
+	}, nil
+}
+
+// SetTime sets the timestamp of the next transaction
+func (s *Simulator) SetTime(t time.Time) {
+	s.clock = t
+}
+
+// DefineCollections defines the private data collections of the chaincode, as its collection config
+// does on a channel. Once collections are defined, accessing any other collection fails.
+func (s *Simulator) DefineCollections(names ...string) {
+	if s.collections == nil {
+		s.collections = make(map[string]bool)
+	}
+	for _, name := range names {
+		s.collections[name] = true
+	}
+}
+
+// SetTick sets how far the clock advances after each transaction
+func (s *Simulator) SetTick(tick time.Duration) {
+	s.tick = tick
+}
+
+// Submit runs a transaction as the given identity and commits its writes and event if it succeeds
+func (s *Simulator) Submit(identity *Identity, function string, args ...string) (*Transaction, error) {
+	return s.SubmitTransient(identity, nil, function, args...)
+}
+
+// SubmitTransient is Submit with transient data, which the contract can read but which is not
+// recorded in the transaction
+func (s *Simulator) SubmitTransient(identity *Identity, transient map[string][]byte, function string, args ...string) (*Transaction, error) {
+	tx, stub, err := s.execute(identity, transient, function, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := stub.commit(); err != nil {
+		return nil, err
+	}
+	if stub.event != nil {
+		s.events = append(s.events, stub.event)
+	}
+	return tx, nil
+}
+
+// Evaluate runs a query as the given identity, nothing is committed
+func (s *Simulator) Evaluate(identity *Identity, function string, args ...string) ([]byte, error) {
+	return s.EvaluateTransient(identity, nil, function, args...)
+}
+
+// EvaluateTransient is Evaluate with transient data
+func (s *Simulator) EvaluateTransient(identity *Identity, transient map[string][]byte, function string, args ...string) ([]byte, error) {
+	tx, _, err := s.execute(identity, transient, function, args)
+	if err != nil {
+		return nil, err
+	}
+	return tx.Payload, nil
+}
+
+// Events returns the events of all committed transactions in commit order
+func (s *Simulator) Events() []*peer.ChaincodeEvent {
+	return s.events
+}
+
+// GetState returns the committed value of a key
+func (s *Simulator) GetState(key string) []byte {
+	return s.ledger.State[key]
+}
+
+// SetState overwrites the committed value of a key outside of any transaction, e.g. to simulate a
+// peer with a corrupted state. A nil value deletes the key.
+func (s *Simulator) SetState(key string, value []byte) error {
+	s.ledger.TxID = "set-state"
+	defer func() { s.ledger.TxID = "" }()
+	if value == nil {
+		return s.ledger.DelState(key)
+	}
+	return s.ledger.PutState(key, value)
+}
+
+// GetPrivateData returns the committed value of a key in a private data collection
+func (s *Simulator) GetPrivateData(collection, key string) []byte {
+	return s.ledger.PvtState[collection][key]
+}
+
+// GetValidationParameter returns the committed key-level endorsement policy of a key in a private data
+// collection, empty for the public state
+func (s *Simulator) GetValidationParameter(collection, key string) []byte {
+	return s.ledger.EndorsementPolicies[collection][key]
+}
+
+// execute runs one transaction against the committed state
+func (s *Simulator) execute(identity *Identity, transient map[string][]byte, function string, args []string) (*Transaction, *txStub, error) {
+	if identity == nil {
+		return nil, nil, fmt.Errorf("an identity is required to run %s", function)
+	}
+
+	txID, txTime := s.nextTransaction()
+	timestamp := timestamppb.New(txTime)
+	invokeArgs := [][]byte{[]byte(function)}
+	for _, arg := range args {
+		invokeArgs = append(invokeArgs, []byte(arg))
+	}
+	proposal, err := signProposal(identity, s.ledger.ChannelID, s.ledger.Name, txID, timestamp, invokeArgs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	response, stub := s.invoke(identity.creator, txID, timestamp, invokeArgs, transient, proposal)
+	if response.Status >= 400 {
+		return nil, nil, fmt.Errorf("transaction %s failed: %s", function, response.Message)
+	}
+	return &Transaction{ID: txID, Timestamp: txTime, Payload: response.Payload, Event: stub.event}, stub, nil
+}
+
+// nextTransaction returns the ID and timestamp of the next transaction and advances the clock
+func (s *Simulator) nextTransaction() (string, time.Time) {
+	s.txCount++
+	txHash := sha256.Sum256([]byte(fmt.Sprintf("%s/%d", s.ledger.Name, s.txCount)))
+	txTime := s.clock
+	s.clock = s.clock.Add(s.tick)
+	return hex.EncodeToString(txHash[:]), txTime
+}
+
+// invoke runs the chaincode on a stub buffering the writes of the transaction
+func (s *Simulator) invoke(creator []byte, txID string, timestamp *timestamppb.Timestamp, args [][]byte, transient map[string][]byte, proposal *peer.SignedProposal) (*peer.Response, *txStub) {
+	stub := newTxStub(s.ledger, txID, args, timestamp, transient)
+	stub.proposal = proposal
+	stub.collections = s.collections
+	s.ledger.TxID = txID
+	s.ledger.Creator = creator
+	defer func() {
+		s.ledger.TxID = ""
+		s.ledger.Creator = nil
+	}()
+
+	response := s.chaincode.Invoke(stub)
+	return &response, stub
+}
@@ -0,0 +1,182 @@
+package simulator_test
+
+import (
+	"encoding/json"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// contextContract exposes what the simulator provides to contracts
+type contextContract struct {
+	contractapi.Contract
+}
+
+func (c *contextContract) Context(ctx contractapi.TransactionContextInterface) (map[string]string, error) {
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"txID":      ctx.GetStub().GetTxID(),
+		"timestamp": timestamp.AsTime().UTC().Format(time.RFC3339),
+		"mspID":     mspID,
+	}, nil
+}
+
+func (c *contextContract) WriteThenRead(ctx contractapi.TransactionContextInterface, key string) (string, error) {
+	if err := ctx.GetStub().PutState(key, []byte("written")); err != nil {
+		return "", err
+	}
+	value, err := ctx.GetStub().GetState(key)
+	return string(value), err
+}
+
+func (c *contextContract) WriteTransientPrivate(ctx contractapi.TransactionContextInterface, collection string, key string) (string, error) {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return "", err
+	}
+	if err := ctx.GetStub().PutPrivateData(collection, key, transient["value"]); err != nil {
+		return "", err
+	}
+	value, err := ctx.GetStub().GetPrivateData(collection, key)
+	return string(value), err
+}
+
+func newIdentity(t *testing.T) *simulator.Identity {
+	identity, err := simulator.NewIdentity("Org1MSP", "admin")
+	require.NoError(t, err)
+	return identity
+}
+
+// newAdminIdentity returns an identity with the registry admin attribute
+func newAdminIdentity(t *testing.T) *simulator.Identity {
+	identity, err := simulator.NewIdentityWithAttributes("Org1MSP", "admin", map[string]string{cuckoofilter.RegistryAdminAttribute: "true"})
+	require.NoError(t, err)
+	return identity
+}
+
+func TestSimulator_SubmitAndEvaluate(t *testing.T) {
+	sim, err := simulator.New("credential-management", &cuckoofilter.SmartContract{})
+	require.NoError(t, err)
+	admin := newAdminIdentity(t)
+
+	_, err = sim.Submit(admin, "Init", "", "100", "4", "0")
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "Insert", "", "fingerprint-1")
+	require.NoError(t, err)
+
+	found, err := sim.Evaluate(admin, "Lookup", "", "fingerprint-1")
+	require.NoError(t, err)
+	require.Equal(t, "true", string(found))
+	found, err = sim.Evaluate(admin, "Lookup", "", "fingerprint-2")
+	require.NoError(t, err)
+	require.Equal(t, "false", string(found))
+}
+
+func TestSimulator_EvaluateDoesNotCommit(t *testing.T) {
+	sim, err := simulator.New("credential-management", &cuckoofilter.SmartContract{})
+	require.NoError(t, err)
+	admin := newAdminIdentity(t)
+
+	_, err = sim.Evaluate(admin, "Init", "", "100", "4", "0")
+	require.NoError(t, err)
+	require.Nil(t, sim.GetState("CuckooFilterState"))
+}
+
+func TestSimulator_FailedTransactionDoesNotCommit(t *testing.T) {
+	sim, err := simulator.New("credential-management", &cuckoofilter.SmartContract{})
+	require.NoError(t, err)
+	admin := newAdminIdentity(t)
+
+	_, err = sim.Submit(admin, "UpdateRegistryConfig", "1000000")
+	require.Error(t, err)
+	require.Nil(t, sim.GetState("RegistryConfig"))
+	require.Empty(t, sim.Events())
+}
+
+func TestSimulator_Events(t *testing.T) {
+	sim, err := simulator.New("credential-management", &cuckoofilter.SmartContract{})
+	require.NoError(t, err)
+	admin := newAdminIdentity(t)
+
+	tx, err := sim.Submit(admin, "UpdateRegistryConfig", "50")
+	require.NoError(t, err)
+	require.NotNil(t, tx.Event)
+	require.Equal(t, cuckoofilter.RegistryConfigChangedEvent, tx.Event.EventName)
+	require.Equal(t, tx.ID, tx.Event.TxId)
+
+	var config cuckoofilter.RegistryConfig
+	require.NoError(t, json.Unmarshal(tx.Event.Payload, &config))
+	require.Equal(t, uint(50), config.MaxBatchSize)
+	require.Len(t, sim.Events(), 1)
+}
+
+func TestSimulator_DeterministicContext(t *testing.T) {
+	run := func() []map[string]string {
+		sim, err := simulator.New("context", &contextContract{})
+		require.NoError(t, err)
+		identity, err := simulator.NewIdentity("Org2MSP", "issuer")
+		require.NoError(t, err)
+
+		var contexts []map[string]string
+		for i := 0; i < 2; i++ {
+			tx, err := sim.Submit(identity, "Context")
+			require.NoError(t, err)
+			var context map[string]string
+			require.NoError(t, json.Unmarshal(tx.Payload, &context))
+			contexts = append(contexts, context)
+		}
+		return contexts
+	}
+
+	first := run()
+	require.Equal(t, first, run())
+	require.Equal(t, "Org2MSP", first[0]["mspID"])
+	require.Equal(t, "2024-01-01T00:00:00Z", first[0]["timestamp"])
+	require.Equal(t, "2024-01-01T00:00:01Z", first[1]["timestamp"])
+	require.NotEqual(t, first[0]["txID"], first[1]["txID"])
+}
+
+func TestSimulator_WritesVisibleAfterCommit(t *testing.T) {
+	sim, err := simulator.New("context", &contextContract{})
+	require.NoError(t, err)
+	identity := newIdentity(t)
+
+	tx, err := sim.Submit(identity, "WriteThenRead", "key")
+	require.NoError(t, err)
+	require.Equal(t, "", string(tx.Payload))
+	require.Equal(t, "written", string(sim.GetState("key")))
+}
+
+func TestSimulator_RequiresIdentity(t *testing.T) {
+	sim, err := simulator.New("context", &contextContract{})
+	require.NoError(t, err)
+	_, err = sim.Submit(nil, "Context")
+	require.Error(t, err)
+}
+
+func TestSimulator_TransientPrivateData(t *testing.T) {
+	sim, err := simulator.New("context", &contextContract{})
+	require.NoError(t, err)
+	identity := newIdentity(t)
+	transient := map[string][]byte{"value": []byte("secret")}
+
+	_, err = sim.EvaluateTransient(identity, transient, "WriteTransientPrivate", "collection", "key")
+	require.NoError(t, err)
+	require.Nil(t, sim.GetPrivateData("collection", "key"))
+
+	tx, err := sim.SubmitTransient(identity, transient, "WriteTransientPrivate", "collection", "key")
+	require.NoError(t, err)
+	require.Equal(t, "", string(tx.Payload))
+	require.Equal(t, "secret", string(sim.GetPrivateData("collection", "key")))
+	require.Nil(t, sim.GetState("key"))
+}
@@ -0,0 +1,139 @@
+package simulator
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// txStub is the stub seen by the contract during one transaction. Reads go to the committed
+// ledger state, public and private writes and the chaincode event are buffered until the
+// transaction commits, as on a peer.
+type txStub struct {
+	*shimtest.MockStub
+	txID          string
+	args          [][]byte
+	timestamp     *timestamppb.Timestamp
+	transient     map[string][]byte
+	writes        map[string][]byte // A nil value deletes the key
+	privateWrites map[string]map[string][]byte
+	collections   map[string]bool // Defined private data collections, nil accepts any
+	params        map[string][]byte
+	privateParams map[string]map[string][]byte
+	event         *peer.ChaincodeEvent
+	proposal      *peer.SignedProposal
+}
+
+func newTxStub(ledger *shimtest.MockStub, txID string, args [][]byte, timestamp *timestamppb.Timestamp, transient map[string][]byte) *txStub {
+	return &txStub{
+		MockStub:      ledger,
+		txID:          txID,
+		args:          args,
+		timestamp:     timestamp,
+		transient:     transient,
+		writes:        make(map[string][]byte),
+		privateWrites: make(map[string]map[string][]byte),
+		params:        make(map[string][]byte),
+		privateParams: make(map[string]map[string][]byte),
+	}
+}
+
+// GetArgs returns the transaction arguments
+func (s *txStub) GetArgs() [][]byte {
+	return s.args
+}
+
+// GetStringArgs returns the transaction arguments as strings
+func (s *txStub) GetStringArgs() []string {
+	args := make([]string, 0, len(s.args))
+	for _, arg := range s.args {
+		args = append(args, string(arg))
+	}
+	return args
+}
+
+// GetFunctionAndParameters returns the function name and its parameters
+func (s *txStub) GetFunctionAndParameters() (string, []string) {
+	args := s.GetStringArgs()
+	if len(args) == 0 {
+		return "", []string{}
+	}
+	return args[0], args[1:]
+}
+
+// GetSignedProposal returns the proposal of the transaction, signed by the submitting identity
+func (s *txStub) GetSignedProposal() (*peer.SignedProposal, error) {
+	return s.proposal, nil
+}
+
+// GetTxTimestamp returns the simulated transaction time
+func (s *txStub) GetTxTimestamp() (*timestamppb.Timestamp, error) {
+	return s.timestamp, nil
+}
+
+// PutState buffers a write until the transaction commits
+func (s *txStub) PutState(key string, value []byte) error {
+	if key == "" {
+		return fmt.Errorf("key must not be empty")
+	}
+	if len(value) == 0 {
+		return s.DelState(key)
+	}
+	s.writes[key] = value
+	return nil
+}
+
+// DelState buffers a delete until the transaction commits
+func (s *txStub) DelState(key string) error {
+	if key == "" {
+		return fmt.Errorf("key must not be empty")
+	}
+	s.writes[key] = nil
+	return nil
+}
+
+// SetStateValidationParameter buffers a key-level endorsement policy until the transaction commits
+func (s *txStub) SetStateValidationParameter(key string, ep []byte) error {
+	s.params[key] = ep
+	return nil
+}
+
+// SetEvent records the chaincode event, only the last event of a transaction is kept
+func (s *txStub) SetEvent(name string, payload []byte) error {
+	if name == "" {
+		return fmt.Errorf("event name must not be empty")
+	}
+	s.event = &peer.ChaincodeEvent{
+		ChaincodeId: s.Name,
+		TxId:        s.txID,
+		EventName:   name,
+		Payload:     payload,
+	}
+	return nil
+}
+
+// commit applies the buffered writes to the ledger
+func (s *txStub) commit() error {
+	s.MockStub.TxID = s.txID
+	defer func() { s.MockStub.TxID = "" }()
+
+	for key, value := range s.writes {
+		var err error
+		if value == nil {
+			err = s.MockStub.DelState(key)
+		} else {
+			err = s.MockStub.PutState(key, value)
+		}
+		if err != nil {
+			return fmt.Errorf("error committing %s: %v", key, err)
+		}
+	}
+	for key, ep := range s.params {
+		if err := s.MockStub.SetStateValidationParameter(key, ep); err != nil {
+			return fmt.Errorf("error committing validation parameter of %s: %v", key, err)
+		}
+	}
+	return s.commitPrivate()
+}
@@ -0,0 +1,35 @@
+package cuckoofilter
+
+import (
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// IdempotentTransientKey is the transient data key of the idempotent flag of BatchInsert. With "true",
+// items that are already revoked are reported as duplicates, so a client can resubmit a batch whose outcome
+// it did not learn.
+const IdempotentTransientKey = "idempotent"
+
+// RevokeIfAbsent is Insert that treats an already revoked credential as success, e.g. for a client
+// retrying a transaction that timed out after it was committed. It reports whether this transaction
+// revoked the credential; an already revoked one is left as it is, without new records or events.
+func (s *SmartContract) RevokeIfAbsent(ctx contractapi.TransactionContextInterface, filterID string, data string) (bool, error) {
+	reason, err := transientReason(ctx)
+	if err != nil {
+		return false, err
+	}
+	result, err := s.batchInsert(ctx, filterID, reason, true, false, []string{data})
+	if err != nil {
+		return false, err
+	}
+	return result.Inserted > 0, nil
+}
+
+// transientIdempotent returns the idempotent flag passed as transient data
+func transientIdempotent(ctx contractapi.TransactionContextInterface) (bool, error) {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return false, fmt.Errorf("error reading transient data: %v", err)
+	}
+	return string(transient[IdempotentTransientKey]) == "true", nil
+}
@@ -0,0 +1,52 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRevokeIfAbsent(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	tx, err := sim.Submit(admin, "RevokeIfAbsent", "", "credential-1")
+	require.NoError(t, err)
+	require.Equal(t, "true", string(tx.Payload))
+	require.NotNil(t, tx.Event)
+
+	// A retry succeeds without writing again
+	tx, err = sim.Submit(admin, "RevokeIfAbsent", "", "credential-1")
+	require.NoError(t, err)
+	require.Equal(t, "false", string(tx.Payload))
+	require.Nil(t, tx.Event)
+	requireLookup(t, sim, admin, "", "credential-1", true)
+
+	_, err = sim.Submit(admin, "Insert", "", "credential-1")
+	require.ErrorContains(t, err, cuckoofilter.AlreadyRevokedErrorCode)
+}
+
+func TestBatchInsert_Idempotent(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	_, err := sim.Submit(admin, "Insert", "", "credential-1")
+	require.NoError(t, err)
+
+	_, err = sim.Submit(admin, "BatchInsert", "", `["credential-1","credential-2"]`)
+	require.ErrorContains(t, err, cuckoofilter.AlreadyRevokedErrorCode)
+	requireLookup(t, sim, admin, "", "credential-2", false)
+
+	idempotent := map[string][]byte{cuckoofilter.IdempotentTransientKey: []byte("true")}
+	_, err = sim.SubmitTransient(admin, idempotent, "BatchInsert", "", `["credential-1","credential-2","credential-2"]`)
+	require.NoError(t, err)
+	requireLookup(t, sim, admin, "", "credential-2", true)
+
+	history, err := sim.Evaluate(admin, "GetRevocationHistory", "credential-1")
+	require.NoError(t, err)
+	var entries []*cuckoofilter.RevocationAuditEntry
+	require.NoError(t, json.Unmarshal(history, &entries))
+	require.Len(t, entries, 1)
+
+	// Nothing left to insert
+	tx, err := sim.SubmitTransient(admin, idempotent, "BatchInsert", "", `["credential-1","credential-2"]`)
+	require.NoError(t, err)
+	require.Nil(t, tx.Event)
+}
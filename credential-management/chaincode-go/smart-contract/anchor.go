@@ -0,0 +1,106 @@
+package cuckoofilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"time"
+)
+
+// credentialAnchorObjectType is the composite key prefix of anchored credentials, credentialAnchor~<SHA-256 hex>
+const credentialAnchorObjectType = "credentialAnchor"
+
+// ErrCredentialNotAnchored is returned by ProveExistence for credential hashes no issuer anchored
+var ErrCredentialNotAnchored = fmt.Errorf("%w: credential is not anchored", ErrNotFound)
+
+// CredentialAnchor proves that a credential existed when it was anchored. Verifiers compare AnchoredAt
+// with the issuance date the credential claims to detect backdated credentials, and treat credentials
+// of anchoring issuers without an anchor as never registered. The block holding TxID, e.g. from
+// GetBlockByTxID on qscc, confirms the time independently of the endorsers.
+type CredentialAnchor struct {
+	Hash       string    `json:"hash"` // Lowercase hex SHA-256 of the credential
+	Issuer     Inserter  `json:"issuer"`
+	TxID       string    `json:"txId"`       // Transaction that anchored the credential
+	AnchoredAt time.Time `json:"anchoredAt"` // Timestamp of the anchoring transaction
+}
+
+// AnchorCredential anchors the SHA-256 hash of a credential at issuance. Anchors are never changed:
+// anchoring a hash again returns the existing anchor for the issuer that anchored it, so retries are
+// safe, and fails with ErrConflict for other issuers.
+func (s *SmartContract) AnchorCredential(ctx contractapi.TransactionContextInterface, credentialHash string) (*CredentialAnchor, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	hash, err := normalizeSHA256("credential", credentialHash)
+	if err != nil {
+		return nil, err
+	}
+	issuer, _, err := clientInserter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := loadCredentialAnchor(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		if existing.Issuer != *issuer {
+			return nil, fmt.Errorf("%w: credential %s is already anchored by %s", ErrConflict, hash, existing.Issuer.DID)
+		}
+		return existing, nil
+	}
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	anchor := &CredentialAnchor{Hash: hash, Issuer: *issuer, TxID: ctx.GetStub().GetTxID(), AnchoredAt: timestamp}
+	key, err := ctx.GetStub().CreateCompositeKey(credentialAnchorObjectType, []string{hash})
+	if err != nil {
+		return nil, fmt.Errorf("error creating credential anchor key: %v", err)
+	}
+	anchorJSON, err := json.Marshal(anchor)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.GetStub().PutState(key, anchorJSON); err != nil {
+		return nil, fmt.Errorf("error saving credential anchor: %v", err)
+	}
+	return anchor, nil
+}
+
+// ProveExistence returns the anchor of a credential hash with the anchoring transaction and its
+// timestamp. Unknown hashes fail with ErrCredentialNotAnchored.
+func (s *SmartContract) ProveExistence(ctx contractapi.TransactionContextInterface, credentialHash string) (*CredentialAnchor, error) {
+	hash, err := normalizeSHA256("credential", credentialHash)
+	if err != nil {
+		return nil, err
+	}
+	anchor, err := loadCredentialAnchor(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if anchor == nil {
+		return nil, ErrCredentialNotAnchored
+	}
+	return anchor, nil
+}
+
+func loadCredentialAnchor(ctx contractapi.TransactionContextInterface, hash string) (*CredentialAnchor, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(credentialAnchorObjectType, []string{hash})
+	if err != nil {
+		return nil, fmt.Errorf("error creating credential anchor key: %v", err)
+	}
+	anchorJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("error loading credential anchor: %v", err)
+	}
+	if anchorJSON == nil {
+		return nil, nil
+	}
+	var anchor CredentialAnchor
+	if err := json.Unmarshal(anchorJSON, &anchor); err != nil {
+		return nil, fmt.Errorf("error decoding credential anchor: %v", err)
+	}
+	return &anchor, nil
+}
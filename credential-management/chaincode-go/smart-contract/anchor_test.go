@@ -0,0 +1,48 @@
+package cuckoofilter_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestAnchorCredential(t *testing.T) {
+	sim, _ := newRegistrySimulator(t)
+	issuer1 := newIssuerIdentity(t, "Org1MSP", "did:key:issuer1")
+	issuer2 := newIssuerIdentity(t, "Org2MSP", "did:key:issuer2")
+	credential := sha256.Sum256([]byte(`{"id":"urn:uuid:credential-1"}`))
+	hash := hex.EncodeToString(credential[:])
+
+	// A credential that was never registered
+	_, err := sim.Evaluate(issuer2, "ProveExistence", hash)
+	require.ErrorContains(t, err, cuckoofilter.ErrCredentialNotAnchored.Error())
+
+	tx, err := sim.Submit(issuer1, "AnchorCredential", hash)
+	require.NoError(t, err)
+	var anchored cuckoofilter.CredentialAnchor
+	require.NoError(t, json.Unmarshal(tx.Payload, &anchored))
+	require.Equal(t, tx.ID, anchored.TxID)
+	require.True(t, tx.Timestamp.Equal(anchored.AnchoredAt))
+
+	proofJSON, err := sim.Evaluate(issuer2, "ProveExistence", "sha256:"+strings.ToUpper(hash))
+	require.NoError(t, err)
+	var proof cuckoofilter.CredentialAnchor
+	require.NoError(t, json.Unmarshal(proofJSON, &proof))
+	require.Equal(t, anchored, proof)
+	require.Equal(t, cuckoofilter.Inserter{MSPID: "Org1MSP", DID: "did:key:issuer1"}, proof.Issuer)
+
+	// Anchors are never moved in time or taken over
+	tx, err = sim.Submit(issuer1, "AnchorCredential", hash)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(tx.Payload, &proof))
+	require.Equal(t, anchored, proof)
+	_, err = sim.Submit(issuer2, "AnchorCredential", hash)
+	require.ErrorContains(t, err, cuckoofilter.ConflictErrorCode)
+
+	_, err = sim.Submit(issuer1, "AnchorCredential", "not-a-hash")
+	require.ErrorContains(t, err, cuckoofilter.InvalidArgumentErrorCode)
+}
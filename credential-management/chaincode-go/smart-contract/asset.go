@@ -0,0 +1,114 @@
+package cuckoofilter
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"strings"
+	"time"
+)
+
+// assetObjectType is the composite key prefix of anchored branding assets, asset~<SHA-256 hex>
+const assetObjectType = "asset"
+
+// ErrAssetNotAnchored is returned by VerifyAsset for hashes no issuer anchored
+var ErrAssetNotAnchored = fmt.Errorf("%w: asset is not anchored", ErrNotFound)
+
+// AnchoredAsset records the hash of a credential branding asset, e.g. a logo or background
+// referenced in credential display metadata, and the issuer that published it
+type AnchoredAsset struct {
+	Hash       string    `json:"hash"` // Lowercase hex SHA-256 of the asset content
+	URI        string    `json:"uri"`
+	Issuer     Inserter  `json:"issuer"`
+	AnchoredAt time.Time `json:"anchoredAt"`
+}
+
+// AnchorAsset anchors the SHA-256 hash of a branding asset published at uri by the submitting issuer.
+// The issuer that anchored a hash first may update its URI; other issuers cannot take it over.
+func (s *SmartContract) AnchorAsset(ctx contractapi.TransactionContextInterface, hash string, uri string) (*AnchoredAsset, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	hash, err := normalizeSHA256("asset", hash)
+	if err != nil {
+		return nil, err
+	}
+	if uri == "" {
+		return nil, fmt.Errorf("asset URI must not be empty")
+	}
+	issuer, _, err := clientInserter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := loadAsset(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.Issuer != *issuer {
+		return nil, fmt.Errorf("asset %s is already anchored by %s", hash, existing.Issuer.DID)
+	}
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	asset := &AnchoredAsset{Hash: hash, URI: uri, Issuer: *issuer, AnchoredAt: timestamp}
+	key, err := ctx.GetStub().CreateCompositeKey(assetObjectType, []string{hash})
+	if err != nil {
+		return nil, fmt.Errorf("error creating asset key: %v", err)
+	}
+	assetJSON, err := json.Marshal(asset)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.GetStub().PutState(key, assetJSON); err != nil {
+		return nil, fmt.Errorf("error saving asset: %v", err)
+	}
+	return asset, nil
+}
+
+// VerifyAsset returns the anchor of a branding asset hash, so wallets can check the issuer that
+// published an asset before rendering it. Unknown hashes fail with ErrAssetNotAnchored.
+func (s *SmartContract) VerifyAsset(ctx contractapi.TransactionContextInterface, hash string) (*AnchoredAsset, error) {
+	hash, err := normalizeSHA256("asset", hash)
+	if err != nil {
+		return nil, err
+	}
+	asset, err := loadAsset(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if asset == nil {
+		return nil, ErrAssetNotAnchored
+	}
+	return asset, nil
+}
+
+func loadAsset(ctx contractapi.TransactionContextInterface, hash string) (*AnchoredAsset, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(assetObjectType, []string{hash})
+	if err != nil {
+		return nil, fmt.Errorf("error creating asset key: %v", err)
+	}
+	assetJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("error loading asset: %v", err)
+	}
+	if assetJSON == nil {
+		return nil, nil
+	}
+	var asset AnchoredAsset
+	if err := json.Unmarshal(assetJSON, &asset); err != nil {
+		return nil, fmt.Errorf("error decoding asset: %v", err)
+	}
+	return &asset, nil
+}
+
+// normalizeSHA256 accepts the hex SHA-256 of an asset or credential, optionally prefixed with "sha256:", in either case
+func normalizeSHA256(name string, hash string) (string, error) {
+	hash = strings.ToLower(strings.TrimPrefix(hash, "sha256:"))
+	if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != 32 {
+		return "", fmt.Errorf("%w: %s hash must be a hex SHA-256 digest", ErrInvalidArgument, name)
+	}
+	return hash, nil
+}
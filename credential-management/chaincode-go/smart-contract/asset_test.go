@@ -0,0 +1,46 @@
+package cuckoofilter_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestAnchorAsset(t *testing.T) {
+	sim, _ := newRegistrySimulator(t)
+	issuer1 := newIssuerIdentity(t, "Org1MSP", "did:key:issuer1")
+	issuer2 := newIssuerIdentity(t, "Org2MSP", "did:key:issuer2")
+	logo := sha256.Sum256([]byte("logo.png"))
+	hash := hex.EncodeToString(logo[:])
+
+	_, err := sim.Evaluate(issuer2, "VerifyAsset", hash)
+	require.ErrorContains(t, err, cuckoofilter.ErrAssetNotAnchored.Error())
+
+	_, err = sim.Submit(issuer1, "AnchorAsset", hash, "https://issuer1.example/logo.png")
+	require.NoError(t, err)
+	// Another issuer cannot claim the asset, the anchoring issuer can move it
+	_, err = sim.Submit(issuer2, "AnchorAsset", hash, "https://issuer2.example/logo.png")
+	require.Error(t, err)
+	_, err = sim.Submit(issuer1, "AnchorAsset", hash, "https://cdn.issuer1.example/logo.png")
+	require.NoError(t, err)
+
+	assetJSON, err := sim.Evaluate(issuer2, "VerifyAsset", "sha256:"+strings.ToUpper(hash))
+	require.NoError(t, err)
+	var asset cuckoofilter.AnchoredAsset
+	require.NoError(t, json.Unmarshal(assetJSON, &asset))
+	require.Equal(t, hash, asset.Hash)
+	require.Equal(t, "https://cdn.issuer1.example/logo.png", asset.URI)
+	require.Equal(t, cuckoofilter.Inserter{MSPID: "Org1MSP", DID: "did:key:issuer1"}, asset.Issuer)
+}
+
+func TestAnchorAsset_InvalidHash(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	_, err := sim.Submit(admin, "AnchorAsset", "not-a-hash", "https://issuer.example/logo.png")
+	require.Error(t, err)
+	_, err = sim.Submit(admin, "AnchorAsset", strings.Repeat("ab", 16), "https://issuer.example/logo.png")
+	require.Error(t, err)
+}
@@ -0,0 +1,97 @@
+package cuckoofilter
+
+import (
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"runtime"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// MaxBenchmarkIterations bounds SelfBenchmark, so a single call cannot tie up a peer
+const MaxBenchmarkIterations = 1000000
+
+// benchmarkBucketSize is the bucket size of the in-memory filter SelfBenchmark uses
+const benchmarkBucketSize = 4
+
+// BenchmarkTiming holds the latencies of one operation measured by SelfBenchmark, in nanoseconds
+type BenchmarkTiming struct {
+	Operations   int     `json:"operations"`
+	TotalNanos   int64   `json:"totalNanos"`
+	MeanNanos    float64 `json:"meanNanos"`
+	P50Nanos     int64   `json:"p50Nanos"`
+	P99Nanos     int64   `json:"p99Nanos"`
+	MaxNanos     int64   `json:"maxNanos"`
+	OpsPerSecond float64 `json:"opsPerSecond"`
+	Failed       int     `json:"failed"` // Inserts into a full filter and lookups that missed
+}
+
+// BenchmarkResult is the outcome of SelfBenchmark together with the runtime it was measured on
+type BenchmarkResult struct {
+	Iterations int             `json:"iterations"`
+	Insert     BenchmarkTiming `json:"insert"`
+	Lookup     BenchmarkTiming `json:"lookup"`
+	GoVersion  string          `json:"goVersion"`
+	GOOS       string          `json:"goos"`
+	GOARCH     string          `json:"goarch"`
+	NumCPU     int             `json:"numCPU"`
+	GOMAXPROCS int             `json:"gomaxprocs"`
+}
+
+// SelfBenchmark inserts and looks up iterations items in an in-memory filter inside the chaincode
+// container and returns the timings, to compare peer hardware and Go runtimes. The ledger is not read
+// or written.
+//
+// Timings differ between endorsers, so SelfBenchmark must only be evaluated: a submitted transaction
+// fails endorsement with mismatching responses.
+func (s *SmartContract) SelfBenchmark(ctx contractapi.TransactionContextInterface, iterations int) (*BenchmarkResult, error) {
+	if iterations <= 0 || iterations > MaxBenchmarkIterations {
+		return nil, fmt.Errorf("iterations must be between 1 and %d", MaxBenchmarkIterations)
+	}
+
+	items := make([][]byte, iterations)
+	for i := range items {
+		items[i] = []byte("benchmark-" + strconv.Itoa(i))
+	}
+
+	filter := NewFilter(uint(iterations), benchmarkBucketSize, FingerPrintSize)
+	insert := benchmarkLoop(items, filter.Insert)
+	lookup := benchmarkLoop(items, filter.Lookup)
+
+	return &BenchmarkResult{
+		Iterations: iterations,
+		Insert:     insert,
+		Lookup:     lookup,
+		GoVersion:  runtime.Version(),
+		GOOS:       runtime.GOOS,
+		GOARCH:     runtime.GOARCH,
+		NumCPU:     runtime.NumCPU(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+	}, nil
+}
+
+// benchmarkLoop times op on every item
+func benchmarkLoop(items [][]byte, op func(data []byte) bool) BenchmarkTiming {
+	latencies := make([]int64, len(items))
+	timing := BenchmarkTiming{Operations: len(items)}
+	for i, item := range items {
+		start := time.Now()
+		ok := op(item)
+		latencies[i] = time.Since(start).Nanoseconds()
+		if !ok {
+			timing.Failed++
+		}
+		timing.TotalNanos += latencies[i]
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	timing.MeanNanos = float64(timing.TotalNanos) / float64(len(latencies))
+	timing.P50Nanos = latencies[len(latencies)/2]
+	timing.P99Nanos = latencies[len(latencies)*99/100]
+	timing.MaxNanos = latencies[len(latencies)-1]
+	if timing.TotalNanos > 0 {
+		timing.OpsPerSecond = float64(len(latencies)) / time.Duration(timing.TotalNanos).Seconds()
+	}
+	return timing
+}
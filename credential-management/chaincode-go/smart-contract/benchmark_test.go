@@ -0,0 +1,40 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"runtime"
+	"testing"
+)
+
+func TestSelfBenchmark(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	filterState := sim.GetState("CuckooFilterState")
+
+	resultJSON, err := sim.Evaluate(admin, "SelfBenchmark", "1000")
+	require.NoError(t, err)
+	var result cuckoofilter.BenchmarkResult
+	require.NoError(t, json.Unmarshal(resultJSON, &result))
+
+	require.Equal(t, 1000, result.Iterations)
+	for _, timing := range []cuckoofilter.BenchmarkTiming{result.Insert, result.Lookup} {
+		require.Equal(t, 1000, timing.Operations)
+		require.Zero(t, timing.Failed)
+		require.LessOrEqual(t, timing.P50Nanos, timing.P99Nanos)
+		require.LessOrEqual(t, timing.P99Nanos, timing.MaxNanos)
+	}
+	require.Equal(t, runtime.Version(), result.GoVersion)
+	require.Equal(t, runtime.NumCPU(), result.NumCPU)
+
+	// The ledger is left alone
+	require.Equal(t, filterState, sim.GetState("CuckooFilterState"))
+}
+
+func TestSelfBenchmark_IterationBounds(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	for _, iterations := range []string{"0", "-1", "1000001"} {
+		_, err := sim.Evaluate(admin, "SelfBenchmark", iterations)
+		require.Error(t, err, iterations)
+	}
+}
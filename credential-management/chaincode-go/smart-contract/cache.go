@@ -0,0 +1,77 @@
+package cuckoofilter
+
+import "sync"
+
+// maxCachedFilters bounds the number of decoded filters a chaincode process keeps
+const maxCachedFilters = 8
+
+// filterCache keeps the filters decoded by this chaincode process, keyed by the state hash stored with
+// them. Every write of a filter state writes a new hash, so the hash identifies the version of the state.
+// The state is still read and checked against its hash, which keeps tampered states failing with
+// ErrCorruptState, but repeated lookups of an unchanged state skip decoding it. The cache is
+// content-addressed, so it is shared by all channels and filters. States written before hashes were
+// stored are not cached.
+var filterCache = &decodedFilterCache{entries: make(map[string]*cachedFilter)}
+
+type decodedFilterCache struct {
+	mu      sync.Mutex
+	entries map[string]*cachedFilter
+	uses    uint64
+}
+
+type cachedFilter struct {
+	filter  *Filter
+	lastUse uint64
+}
+
+// get returns a copy of the filter cached for a state hash, nil if there is none
+func (c *decodedFilterCache) get(hash []byte) *Filter {
+	if hash == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[string(hash)]
+	if !ok {
+		return nil
+	}
+	c.uses++
+	entry.lastUse = c.uses
+	return entry.filter.clone()
+}
+
+// put caches a copy of the filter decoded from the state with the hash, evicting the least recently used
+// filter when the cache is full
+func (c *decodedFilterCache) put(hash []byte, filter *Filter) {
+	if hash == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxCachedFilters {
+		var oldest string
+		for key, entry := range c.entries {
+			if oldest == "" || entry.lastUse < c.entries[oldest].lastUse {
+				oldest = key
+			}
+		}
+		delete(c.entries, oldest)
+	}
+	c.uses++
+	c.entries[string(hash)] = &cachedFilter{filter: filter.clone(), lastUse: c.uses}
+}
+
+// clone copies the filter so transactions can modify it without touching the cached one. Fingerprints
+// are replaced rather than modified in place, so the copies share them.
+func (f *Filter) clone() *Filter {
+	clone := *f
+	clone.Buckets = make([]*bucket, len(f.Buckets))
+	for i, b := range f.Buckets {
+		if b == nil {
+			continue
+		}
+		clone.Buckets[i] = &bucket{Data: make([]fingerprint, len(b.Data)), size: b.size}
+		copy(clone.Buckets[i].Data, b.Data)
+	}
+	return &clone
+}
@@ -0,0 +1,58 @@
+package cuckoofilter_test
+
+import (
+	"fmt"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func requireLookup(t *testing.T, sim *simulator.Simulator, identity *simulator.Identity, filterID string, data string, expected bool) {
+	found, err := sim.Evaluate(identity, "Lookup", filterID, data)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprint(expected), string(found), data)
+}
+
+func TestFilterCache_InvalidatedByWrites(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	_, err := sim.Submit(newRegistryAdmin(t), "Init", "issuer-a", "100", "4", "0")
+	require.NoError(t, err)
+
+	// More versions than the cache holds, each read before and after it is written
+	for i := 0; i < 12; i++ {
+		for _, filterID := range []string{"", "issuer-a"} {
+			data := fmt.Sprintf("%s-credential-%d", filterID, i)
+			requireLookup(t, sim, admin, filterID, data, false)
+			_, err := sim.Submit(admin, "Insert", filterID, data)
+			require.NoError(t, err)
+			requireLookup(t, sim, admin, filterID, data, true)
+		}
+	}
+	_, err = sim.Submit(admin, "Delete", "", "-credential-3")
+	require.NoError(t, err)
+	requireLookup(t, sim, admin, "", "-credential-3", false)
+	requireLookup(t, sim, admin, "", "-credential-4", true)
+}
+
+func TestFilterCache_UncommittedChangesStayPrivate(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	requireLookup(t, sim, admin, "", "credential-1", false)
+
+	// An evaluated insert modifies its copy of the cached filter only
+	_, err := sim.Evaluate(admin, "Insert", "", "credential-1")
+	require.NoError(t, err)
+	requireLookup(t, sim, admin, "", "credential-1", false)
+}
+
+func TestFilterCache_TamperedStateFails(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	_, err := sim.Submit(admin, "Insert", "", "credential-1")
+	require.NoError(t, err)
+	requireLookup(t, sim, admin, "", "credential-1", true)
+
+	// The cached filter is not served for a state that no longer matches its hash
+	require.NoError(t, sim.SetState("CuckooFilterState", []byte(`{"Count":0}`)))
+	_, err = sim.Evaluate(admin, "Lookup", "", "credential-1")
+	require.ErrorContains(t, err, cuckoofilter.ErrCorruptState.Error())
+}
@@ -0,0 +1,71 @@
+package cuckoofilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"time"
+)
+
+// CapabilityTransientKey is the transient data key under which a gateway passes the claims of the
+// capability token it authorized a request with
+const CapabilityTransientKey = "capability"
+
+// Actions a capability can grant
+const (
+	CapabilityActionRevoke   = "revoke"
+	CapabilityActionUnrevoke = "unrevoke"
+	CapabilityActionLookup   = "lookup"
+)
+
+// CapabilityClaims are the authorization claims of a capability token, verified by the gateway.
+// They can only narrow what the submitting identity may do, never widen it.
+type CapabilityClaims struct {
+	ID        string    `json:"id"`
+	Subject   string    `json:"subject"`
+	Actions   []string  `json:"actions"`
+	Namespace string    `json:"namespace,omitempty"` // Issuer namespace the capability is limited to, empty for any
+	NotAfter  time.Time `json:"notAfter"`
+}
+
+// Allows reports whether the claims grant an action within a namespace at a time.
+// The global filter has the empty namespace, which namespace-scoped capabilities do not cover.
+func (c *CapabilityClaims) Allows(action string, namespace string, at time.Time) bool {
+	if !at.Before(c.NotAfter) {
+		return false
+	}
+	if c.Namespace != "" && c.Namespace != namespace {
+		return false
+	}
+	for _, granted := range c.Actions {
+		if granted == action {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCapability enforces the capability claims passed as transient data, if any.
+// Requests without claims are authorized by the submitting identity alone.
+func checkCapability(ctx contractapi.TransactionContextInterface, action string, namespace string) error {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("error reading transient data: %v", err)
+	}
+	claimsJSON, ok := transient[CapabilityTransientKey]
+	if !ok {
+		return nil
+	}
+	var claims CapabilityClaims
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		return fmt.Errorf("error decoding capability claims: %v", err)
+	}
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	if !claims.Allows(action, namespace, timestamp) {
+		return fmt.Errorf("%w: capability %s does not grant %s in namespace '%s'", ErrUnauthorized, claims.ID, action, namespace)
+	}
+	return nil
+}
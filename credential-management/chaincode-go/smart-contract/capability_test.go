@@ -0,0 +1,58 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/pherbke/credential-management/chaincode-go/mocks"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func newCapabilityContext(claims *cuckoofilter.CapabilityClaims) (*mocks.MockChaincodeStubInterface, *mocks.MockTransactionContext) {
+	mockStub := new(mocks.MockChaincodeStubInterface)
+	transient := map[string][]byte{}
+	if claims != nil {
+		transient[cuckoofilter.CapabilityTransientKey], _ = json.Marshal(claims)
+	}
+	mockStub.On("GetTransient").Return(transient, nil)
+	mockRegistryDefaults(mockStub)
+	mockStub.On("GetTxTimestamp").Return(&timestamp.Timestamp{Seconds: 1700000000}, nil)
+	filterJSON, _ := json.Marshal(cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize))
+	mockStub.On("GetState", "CuckooFilterState").Return(filterJSON, nil)
+	mockStub.On("PutState", "CuckooFilterState", mock.Anything).Return(nil)
+
+	mockTxContext := new(mocks.MockTransactionContext)
+	mockTxContext.On("GetStub").Return(mockStub)
+	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
+	return mockStub, mockTxContext
+}
+
+func TestCapability_EnforcedOnInsert(t *testing.T) {
+	smartContract := new(cuckoofilter.SmartContract)
+	notAfter := time.Unix(1700003600, 0)
+
+	// Without capability claims the identity alone decides
+	_, mockTxContext := newCapabilityContext(nil)
+	require.NoError(t, smartContract.Insert(mockTxContext, "", "credential-1"))
+
+	_, mockTxContext = newCapabilityContext(&cuckoofilter.CapabilityClaims{ID: "cap-1", Actions: []string{cuckoofilter.CapabilityActionRevoke}, NotAfter: notAfter})
+	require.NoError(t, smartContract.Insert(mockTxContext, "", "credential-1"))
+
+	// Namespace-scoped capabilities do not cover the global filter
+	mockStub, mockTxContext := newCapabilityContext(&cuckoofilter.CapabilityClaims{ID: "cap-2", Actions: []string{cuckoofilter.CapabilityActionRevoke}, Namespace: "did:key:issuer", NotAfter: notAfter})
+	require.Error(t, smartContract.Insert(mockTxContext, "", "credential-1"))
+	mockStub.AssertNotCalled(t, "PutState", "CuckooFilterState", mock.Anything)
+
+	mockStub, mockTxContext = newCapabilityContext(&cuckoofilter.CapabilityClaims{ID: "cap-3", Actions: []string{cuckoofilter.CapabilityActionLookup}, NotAfter: notAfter})
+	require.Error(t, smartContract.Insert(mockTxContext, "", "credential-1"))
+	require.Error(t, smartContract.Delete(mockTxContext, "", "credential-1"))
+	mockStub.AssertNotCalled(t, "PutState", "CuckooFilterState", mock.Anything)
+
+	// Expired at the transaction time
+	_, mockTxContext = newCapabilityContext(&cuckoofilter.CapabilityClaims{ID: "cap-4", Actions: []string{cuckoofilter.CapabilityActionRevoke}, NotAfter: time.Unix(1700000000, 0)})
+	require.Error(t, smartContract.Insert(mockTxContext, "", "credential-1"))
+}
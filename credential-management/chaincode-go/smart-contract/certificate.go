@@ -0,0 +1,46 @@
+package cuckoofilter
+
+import (
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"time"
+)
+
+// RevocationCertificate is the ledger evidence of an approved revocation, as handed to legal and
+// compliance processes. The chaincode only assembles it; the registry operator signs it off-chain
+// with the certificate package, as endorsers cannot share a signing key.
+type RevocationCertificate struct {
+	RequestID    string    `json:"requestId"`
+	CredentialID string    `json:"credentialId"` // The credential fingerprint, the ledger never sees the credential itself
+	IssuerDID    string    `json:"issuerDID"`
+	HolderDID    string    `json:"holderDID"`
+	Reason       string    `json:"reason"`
+	RequestedAt  time.Time `json:"requestedAt"`
+	RevokedAt    time.Time `json:"revokedAt"`
+	RevocationTx string    `json:"revocationTx"` // ID of the transaction that inserted the fingerprint
+	Channel      string    `json:"channel"`
+	Provenance   string    `json:"provenance"`
+}
+
+// GetRevocationCertificate returns the certificate of an approved revocation request
+func (s *SmartContract) GetRevocationCertificate(ctx contractapi.TransactionContextInterface, requestID string) (*RevocationCertificate, error) {
+	request, err := loadRevocationRequest(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request.Status != RevocationRequestApproved {
+		return nil, fmt.Errorf("revocation request %s is %s, only approved requests have a certificate", requestID, request.Status)
+	}
+	return &RevocationCertificate{
+		RequestID:    request.ID,
+		CredentialID: request.Fingerprint,
+		IssuerDID:    request.IssuerDID,
+		HolderDID:    request.HolderDID,
+		Reason:       request.Reason,
+		RequestedAt:  request.RequestedAt,
+		RevokedAt:    request.DecidedAt,
+		RevocationTx: request.DecisionTx,
+		Channel:      ctx.GetStub().GetChannelID(),
+		Provenance:   request.Provenance,
+	}, nil
+}
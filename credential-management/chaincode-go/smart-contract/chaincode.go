@@ -0,0 +1,13 @@
+package cuckoofilter
+
+import "github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+// Contracts returns the contracts the chaincode registers. SmartContract comes first, so it is the
+// default contract of functions called without a contract name.
+func Contracts(trustRegistryAdminMSPs []string) []contractapi.ContractInterface {
+	return []contractapi.ContractInterface{
+		&SmartContract{},
+		&TrustRegistryContract{AdminMSPs: trustRegistryAdminMSPs},
+		&GovernanceContract{},
+	}
+}
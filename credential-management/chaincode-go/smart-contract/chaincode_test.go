@@ -0,0 +1,30 @@
+package cuckoofilter_test
+
+import (
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestContracts_TrustRegistry(t *testing.T) {
+	sim, err := simulator.New("credential-management", cuckoofilter.Contracts([]string{"GovernanceMSP"})...)
+	require.NoError(t, err)
+	governance, err := simulator.NewIdentity("GovernanceMSP", "governance")
+	require.NoError(t, err)
+	client, err := simulator.NewIdentity("Org1MSP", "client")
+	require.NoError(t, err)
+
+	// The filter functions stay on the default contract
+	_, err = sim.Submit(newRegistryAdmin(t), "Init", "", "100", "4", "0")
+	require.NoError(t, err)
+
+	// The trust registry is reachable and uses the configured admin MSPs
+	_, err = sim.Submit(client, "TrustRegistryContract:AddTrustedIssuer", "did:key:issuer")
+	require.ErrorContains(t, err, cuckoofilter.ErrNotTrustRegistryAdmin.Error())
+	_, err = sim.Submit(governance, "TrustRegistryContract:AddTrustedIssuer", "did:key:issuer")
+	require.NoError(t, err)
+	trusted, err := sim.Evaluate(client, "TrustRegistryContract:IsTrustedIssuer", "did:key:issuer")
+	require.NoError(t, err)
+	require.Equal(t, "true", string(trusted))
+}
@@ -0,0 +1,101 @@
+package cuckoofilter
+
+import (
+	"sync"
+)
+
+// ConcurrentFilter wraps a Filter for use from several goroutines, e.g. by an off-chain service
+// answering lookups while it applies FilterChanged events. Filter itself is not safe for concurrent
+// use: inserts relocate fingerprints between buckets and update Count. Lookups share a read lock,
+// changes take the write lock.
+type ConcurrentFilter struct {
+	mu     sync.RWMutex
+	filter *Filter
+}
+
+// NewConcurrentFilter wraps filter, which must not be used directly afterwards
+func NewConcurrentFilter(filter *Filter) *ConcurrentFilter {
+	return &ConcurrentFilter{filter: filter}
+}
+
+// Insert adds data to the filter, see Filter.Insert
+func (c *ConcurrentFilter) Insert(data []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.filter.Insert(data)
+}
+
+// InsertGrowing adds data to the filter, doubling it first when it is too full as the registry does
+func (c *ConcurrentFilter) InsertGrowing(data []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	filter, inserted := insertGrowing(c.filter, data)
+	c.filter = filter
+	return inserted
+}
+
+// Lookup checks if data is present in the filter
+func (c *ConcurrentFilter) Lookup(data []byte) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.filter.Lookup(data)
+}
+
+// Delete removes data from the filter
+func (c *ConcurrentFilter) Delete(data []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.filter.Delete(data)
+}
+
+// Reset removes all fingerprints from the filter
+func (c *ConcurrentFilter) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.filter.Reset()
+}
+
+// Count returns the number of fingerprints in the filter
+func (c *ConcurrentFilter) Count() uint {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.filter.Count
+}
+
+// LoadFactor returns the fraction of occupied slots
+func (c *ConcurrentFilter) LoadFactor() float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.filter.LoadFactor()
+}
+
+// Replace swaps in another filter, e.g. one reloaded from the ledger, which must not be used directly afterwards
+func (c *ConcurrentFilter) Replace(filter *Filter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.filter = filter
+}
+
+// Snapshot returns a copy of the filter that the caller owns, e.g. to serialize it
+func (c *ConcurrentFilter) Snapshot() *Filter {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	snapshot := *c.filter
+	snapshot.Buckets = make([]*bucket, len(c.filter.Buckets))
+	for i, b := range c.filter.Buckets {
+		if b == nil {
+			continue
+		}
+		data := make([]fingerprint, len(b.Data))
+		for j, fp := range b.Data {
+			data[j] = append(fingerprint(nil), fp...)
+		}
+		snapshot.Buckets[i] = &bucket{Data: data, size: b.size}
+	}
+	return &snapshot
+}
+
+// MarshalJSON serializes a snapshot of the filter in the ledger format
+func (c *ConcurrentFilter) MarshalJSON() ([]byte, error) {
+	return c.Snapshot().MarshalJSON()
+}
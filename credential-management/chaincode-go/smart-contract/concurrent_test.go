@@ -0,0 +1,56 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"fmt"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentFilter_MixedOperations(t *testing.T) {
+	// A small filter, so inserts kick fingerprints between buckets and InsertGrowing grows it
+	filter := cuckoofilter.NewConcurrentFilter(cuckoofilter.NewFilter(8, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize))
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				data := []byte(fmt.Sprintf("worker%d-data%d", worker, i))
+				require.True(t, filter.InsertGrowing(data))
+				require.True(t, filter.Lookup(data))
+				if i%2 == 0 {
+					require.True(t, filter.Delete(data))
+				}
+				filter.Count()
+				filter.LoadFactor()
+			}
+		}(worker)
+	}
+	wg.Wait()
+	require.Equal(t, uint(8*25), filter.Count())
+}
+
+func TestConcurrentFilter_Snapshot(t *testing.T) {
+	filter := cuckoofilter.NewConcurrentFilter(cuckoofilter.NewFilter(16, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize))
+	require.True(t, filter.Insert([]byte("credential-1")))
+
+	snapshot := filter.Snapshot()
+	require.True(t, filter.Insert([]byte("credential-2")))
+	require.False(t, snapshot.Lookup([]byte("credential-2")))
+	require.True(t, snapshot.Lookup([]byte("credential-1")))
+
+	// The wrapper serializes to the ledger format
+	filterJSON, err := json.Marshal(filter)
+	require.NoError(t, err)
+	var loaded cuckoofilter.Filter
+	require.NoError(t, json.Unmarshal(filterJSON, &loaded))
+	require.Equal(t, uint(2), loaded.Count)
+
+	filter.Replace(snapshot)
+	require.False(t, filter.Lookup([]byte("credential-2")))
+	filter.Reset()
+	require.Equal(t, uint(0), filter.Count())
+}
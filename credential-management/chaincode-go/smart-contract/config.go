@@ -0,0 +1,156 @@
+package cuckoofilter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// registryConfigKey is the ledger key holding the registry configuration
+const registryConfigKey = "RegistryConfig"
+
+// RegistryConfigChangedEvent is the name of the chaincode event emitted when the configuration changes.
+// Its payload is the new RegistryConfig as JSON, so long-running clients can reload derived settings.
+const RegistryConfigChangedEvent = "RegistryConfigChanged"
+
+// MaxBatchSizeLimit is the largest batch limit an admin can configure
+const MaxBatchSizeLimit = 10000
+
+// RegistryConfig holds the admin-controlled settings of the revocation registry
+type RegistryConfig struct {
+	Version      uint64 `json:"version" yaml:"-"`                 // Incremented on every update
+	MaxBatchSize uint   `json:"maxBatchSize" yaml:"maxBatchSize"` // Maximum items per batch transaction, 0 means unlimited
+	Normalizer   string `json:"normalizer" yaml:"normalizer"`     // Credential normalizer for JWT fingerprints, empty means issuer-jti
+	// Write changed filter buckets as deltas instead of the whole filter state, see SetDeltaPersistence
+	DeltaPersistence bool `json:"deltaPersistence" yaml:"deltaPersistence"`
+	// Only accept canonical fingerprints for revocation, see SetStrictMode
+	StrictMode bool `json:"strictMode" yaml:"strictMode"`
+	// Private data collection holding the default filter state and the revocation audit records,
+	// empty for the public state, see SetPrivateCollection
+	PrivateCollection string `json:"privateCollection" yaml:"privateCollection"`
+	// Load factor above which inserts grow the filter first, 0 for the default ResizeLoadFactor
+	ResizeLoadFactor float64 `json:"resizeLoadFactor,omitempty" yaml:"resizeLoadFactor" metadata:",optional"`
+	// Number of buckets inserts grow the filter to at most, 0 for MaxResizeBuckets
+	MaxFilterBuckets uint `json:"maxFilterBuckets,omitempty" yaml:"maxFilterBuckets" metadata:",optional"`
+	// Ledger layout of the default filter state and its number of shards, changed by BeginShardedMigration
+	// and FinalizeMigration only
+	StateLayout string `json:"stateLayout,omitempty" yaml:"-" metadata:",optional"`
+	StateShards uint   `json:"stateShards,omitempty" yaml:"-" metadata:",optional"`
+	// Fingerprint the HMAC of inserted values under a registry secret, changed by SetKeyedFingerprints only
+	KeyedFingerprints bool `json:"keyedFingerprints,omitempty" yaml:"-" metadata:",optional"`
+}
+
+// Validate checks the configuration values
+func (c *RegistryConfig) Validate() error {
+	if c.MaxBatchSize > MaxBatchSizeLimit {
+		return fmt.Errorf("maxBatchSize %d exceeds the limit of %d", c.MaxBatchSize, MaxBatchSizeLimit)
+	}
+	if _, err := LookupNormalizer(c.Normalizer); err != nil {
+		return err
+	}
+	if c.PrivateCollection != "" && c.DeltaPersistence {
+		return errors.New("delta persistence cannot be combined with a private data collection")
+	}
+	if c.KeyedFingerprints && c.PrivateCollection == "" {
+		return errors.New("keyed fingerprints require a private data collection holding the secret")
+	}
+	if c.ResizeLoadFactor < 0 || c.ResizeLoadFactor > 1 {
+		return fmt.Errorf("resizeLoadFactor %v must be between 0 and 1", c.ResizeLoadFactor)
+	}
+	if c.MaxFilterBuckets > MaxResizeBuckets {
+		return fmt.Errorf("maxFilterBuckets %d exceeds the limit of %d", c.MaxFilterBuckets, MaxResizeBuckets)
+	}
+	switch c.StateLayout {
+	case StateLayoutSingle:
+	case StateLayoutDual, StateLayoutSharded:
+		if c.DeltaPersistence || c.PrivateCollection != "" {
+			return errors.New("the sharded state layout cannot be combined with delta persistence or a private data collection")
+		}
+		if c.StateShards == 0 || c.StateShards > MaxStateShards {
+			return fmt.Errorf("state shards must be between 1 and %d", MaxStateShards)
+		}
+	default:
+		return fmt.Errorf("unknown state layout %q", c.StateLayout)
+	}
+	return nil
+}
+
+// DefaultRegistryConfig returns the configuration used before an admin stored one
+func DefaultRegistryConfig() *RegistryConfig {
+	return &RegistryConfig{}
+}
+
+// GetRegistryConfig returns the current registry configuration
+func (s *SmartContract) GetRegistryConfig(ctx contractapi.TransactionContextInterface) (*RegistryConfig, error) {
+	return loadRegistryConfig(ctx)
+}
+
+// UpdateRegistryConfig stores a new registry configuration and emits a RegistryConfigChanged event.
+// Registry admins only.
+func (s *SmartContract) UpdateRegistryConfig(ctx contractapi.TransactionContextInterface, maxBatchSize uint) (*RegistryConfig, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	if err := checkRegistryAdmin(ctx, "update the registry config"); err != nil {
+		return nil, err
+	}
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	config.Version++
+	config.MaxBatchSize = maxBatchSize
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if err := saveRegistryConfig(ctx, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// saveRegistryConfig stores the registry configuration and emits a RegistryConfigChanged event
+func saveRegistryConfig(ctx contractapi.TransactionContextInterface, config *RegistryConfig) error {
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(registryConfigKey, configJSON); err != nil {
+		return fmt.Errorf("error saving registry config: %v", err)
+	}
+	if err := ctx.GetStub().SetEvent(RegistryConfigChangedEvent, configJSON); err != nil {
+		return fmt.Errorf("error emitting %s event: %v", RegistryConfigChangedEvent, err)
+	}
+	return nil
+}
+
+// loadRegistryConfig retrieves the registry configuration, falling back to the defaults
+func loadRegistryConfig(ctx contractapi.TransactionContextInterface) (*RegistryConfig, error) {
+	configJSON, err := ctx.GetStub().GetState(registryConfigKey)
+	if err != nil {
+		return nil, fmt.Errorf("error loading registry config: %v", err)
+	}
+	if configJSON == nil {
+		return DefaultRegistryConfig(), nil
+	}
+
+	var config RegistryConfig
+	if err := json.Unmarshal(configJSON, &config); err != nil {
+		return nil, fmt.Errorf("error decoding registry config: %v", err)
+	}
+	return &config, nil
+}
+
+// checkBatchSize enforces the configured batch limit for a batch transaction
+func checkBatchSize(ctx contractapi.TransactionContextInterface, size int) error {
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if config.MaxBatchSize > 0 && uint(size) > config.MaxBatchSize {
+		return fmt.Errorf("batch of %d items exceeds the configured limit of %d", size, config.MaxBatchSize)
+	}
+	return nil
+}
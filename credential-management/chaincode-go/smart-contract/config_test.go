@@ -0,0 +1,142 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/pherbke/credential-management/chaincode-go/mocks"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestGetRegistryConfig_Default(t *testing.T) {
+	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockTxContext := new(mocks.MockTransactionContext)
+	mockTxContext.On("GetStub").Return(mockStub)
+	mockTxContext.Stub = mockStub
+	mockStub.On("GetState", "RegistryConfig").Return(([]byte)(nil), nil)
+	mockStub.On("GetState", "RegistryFreeze").Return(([]byte)(nil), nil)
+
+	smartContract := new(cuckoofilter.SmartContract)
+	config, err := smartContract.GetRegistryConfig(mockTxContext)
+	require.NoError(t, err)
+	require.Equal(t, cuckoofilter.DefaultRegistryConfig(), config)
+}
+
+func TestGetRegistryConfig_Corrupted(t *testing.T) {
+	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockTxContext := new(mocks.MockTransactionContext)
+	mockTxContext.On("GetStub").Return(mockStub)
+	mockTxContext.Stub = mockStub
+	mockStub.On("GetState", "RegistryConfig").Return([]byte("{not json"), nil)
+	mockStub.On("GetState", "RegistryFreeze").Return(([]byte)(nil), nil)
+
+	smartContract := new(cuckoofilter.SmartContract)
+	_, err := smartContract.GetRegistryConfig(mockTxContext)
+	require.Error(t, err)
+}
+
+func TestUpdateRegistryConfig(t *testing.T) {
+	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockTxContext := new(mocks.MockTransactionContext)
+	mockTxContext.On("GetStub").Return(mockStub)
+	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
+
+	current, _ := json.Marshal(cuckoofilter.RegistryConfig{Version: 3, MaxBatchSize: 100})
+	mockStub.On("GetState", "RegistryConfig").Return(current, nil)
+	mockStub.On("GetState", "RegistryFreeze").Return(([]byte)(nil), nil)
+	expected, _ := json.Marshal(cuckoofilter.RegistryConfig{Version: 4, MaxBatchSize: 50})
+	mockStub.On("PutState", "RegistryConfig", expected).Return(nil)
+	mockStub.On("SetEvent", cuckoofilter.RegistryConfigChangedEvent, expected).Return(nil)
+
+	smartContract := new(cuckoofilter.SmartContract)
+	config, err := smartContract.UpdateRegistryConfig(mockTxContext, 50)
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), config.Version)
+	require.Equal(t, uint(50), config.MaxBatchSize)
+	mockStub.AssertCalled(t, "SetEvent", cuckoofilter.RegistryConfigChangedEvent, expected)
+}
+
+func TestUpdateRegistryConfig_SaveFailure(t *testing.T) {
+	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockTxContext := new(mocks.MockTransactionContext)
+	mockTxContext.On("GetStub").Return(mockStub)
+	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
+	mockStub.On("GetState", "RegistryConfig").Return(([]byte)(nil), nil)
+	mockStub.On("GetState", "RegistryFreeze").Return(([]byte)(nil), nil)
+	mockStub.On("PutState", "RegistryConfig", mock.Anything).Return(errors.New("failed to save state"))
+
+	smartContract := new(cuckoofilter.SmartContract)
+	_, err := smartContract.UpdateRegistryConfig(mockTxContext, 50)
+	require.Error(t, err)
+	mockStub.AssertNotCalled(t, "SetEvent", mock.Anything, mock.Anything)
+}
+
+func TestBatchOperations_ExceedMaxBatchSize(t *testing.T) {
+	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockTxContext := new(mocks.MockTransactionContext)
+	mockTxContext.On("GetStub").Return(mockStub)
+	mockTxContext.Stub = mockStub
+
+	configJSON, _ := json.Marshal(cuckoofilter.RegistryConfig{Version: 1, MaxBatchSize: 2})
+	mockStub.On("GetState", "RegistryConfig").Return(configJSON, nil)
+	mockStub.On("GetState", "RegistryFreeze").Return(([]byte)(nil), nil)
+	mockStub.On("GetTransient").Return(map[string][]byte{}, nil)
+
+	smartContract := new(cuckoofilter.SmartContract)
+	batchData := []string{"data1", "data2", "data3"}
+
+	_, err := smartContract.BatchInsert(mockTxContext, "", batchData)
+	require.Error(t, err)
+	_, err = smartContract.BatchLookup(mockTxContext, "", batchData)
+	require.Error(t, err)
+	_, err = smartContract.BatchDelete(mockTxContext, "", batchData, true)
+	require.Error(t, err)
+	mockStub.AssertNotCalled(t, "GetState", "CuckooFilterState")
+}
+
+func TestBatchInsert_WithinMaxBatchSize(t *testing.T) {
+	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockTxContext := new(mocks.MockTransactionContext)
+	mockTxContext.On("GetStub").Return(mockStub)
+	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
+
+	configJSON, _ := json.Marshal(cuckoofilter.RegistryConfig{Version: 1, MaxBatchSize: 3})
+	mockStub.On("GetState", "RegistryConfig").Return(configJSON, nil)
+	mockStub.On("GetState", "RegistryFreeze").Return(([]byte)(nil), nil)
+	filterJSON, _ := json.Marshal(cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize))
+	mockStub.On("GetState", "CuckooFilterState").Return(filterJSON, nil)
+	mockStub.On("PutState", "CuckooFilterState", mock.Anything).Return(nil)
+	mockRegistryDefaults(mockStub)
+
+	smartContract := new(cuckoofilter.SmartContract)
+	_, err := smartContract.BatchInsert(mockTxContext, "", []string{"data1", "data2", "data3"})
+	require.NoError(t, err)
+}
+
+func TestUpdateRegistryConfig_Invalid(t *testing.T) {
+	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockTxContext := new(mocks.MockTransactionContext)
+	mockTxContext.On("GetStub").Return(mockStub)
+	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
+	mockStub.On("GetState", "RegistryConfig").Return(([]byte)(nil), nil)
+	mockStub.On("GetState", "RegistryFreeze").Return(([]byte)(nil), nil)
+
+	smartContract := new(cuckoofilter.SmartContract)
+	_, err := smartContract.UpdateRegistryConfig(mockTxContext, cuckoofilter.MaxBatchSizeLimit+1)
+	require.Error(t, err)
+	mockStub.AssertNotCalled(t, "PutState", mock.Anything, mock.Anything)
+}
+
+func TestUpdateRegistryConfig_AdminsOnly(t *testing.T) {
+	sim, client := newRegistrySimulator(t)
+	_, err := sim.Submit(client, "UpdateRegistryConfig", "1")
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+	_, err = sim.Submit(newRegistryAdmin(t), "UpdateRegistryConfig", "1")
+	require.NoError(t, err)
+}
@@ -1,12 +1,14 @@
 package cuckoofilter
 
 import (
+	"bytes"
+	"crypto"
 	"crypto/ecdsa"
-	"crypto/rand"
-	"crypto/sha256"
-	"encoding/base64"
+	"crypto/ed25519"
 	"encoding/json"
 	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
 	"time"
 )
 
@@ -18,6 +20,7 @@ type VerifiableCredential struct {
 	IssuanceDate      time.Time         `json:"issuanceDate"`
 	ExpirationDate    time.Time         `json:"expirationDate"`
 	CredentialSubject CredentialSubject `json:"credentialSubject"`
+	CredentialStatus  *CredentialStatus `json:"credentialStatus,omitempty"`
 	Proof             Proof             `json:"proof,omitempty"`
 }
 
@@ -44,41 +47,145 @@ type Proof struct {
 	JWS                string    `json:"jws"`
 }
 
-// CreateAndSignCredential creates and signs a credential
-func CreateAndSignCredential(issuerDID string, issuerPrivateKey *ecdsa.PrivateKey, subjectID string) (*VerifiableCredential, error) {
-	// Create the credential
-	credential := VerifiableCredential{
-		Context: []string{
-			"https://www.w3.org/2018/credentials/v1",
-			"https://www.w3.org/2018/credentials/examples/v1",
-		},
-		ID:             "http://example.edu/credentials/1872",
-		Type:           []string{"VerifiableCredential", "AlumniCredential"},
-		Issuer:         issuerDID,
-		IssuanceDate:   time.Now(),
-		ExpirationDate: time.Now().AddDate(10, 0, 0),
-		CredentialSubject: CredentialSubject{
-			ID: subjectID,
-			AlumniOf: Alumni{
-				ID: "did:example:c276e12ec21ebfeb1f712ebc6f1",
-				Name: []Name{
-					{Value: "Example University", Lang: "en"},
-					{Value: "Exemple d'Université", Lang: "fr"},
-				},
-			},
-		},
+// Claims of the EBSI JWT encoding of a credential
+const (
+	ClaimCredential       = "vc"         // The credential as JSON
+	ClaimLegacyCredential = "credential" // The credential in tokens issued before the EBSI layout
+)
+
+// ToJWTClaims maps the credential to the EBSI JWT claim layout: the credential under vc, its issuer as iss,
+// its subject as sub, its id as jti, and its issuance and expiration dates as nbf and exp
+func (c *VerifiableCredential) ToJWTClaims() (jwt.MapClaims, error) {
+	credentialJSON, err := json.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal credential: %v", err)
+	}
+	var vc map[string]interface{}
+	if err := json.Unmarshal(credentialJSON, &vc); err != nil {
+		return nil, fmt.Errorf("failed to marshal credential: %v", err)
 	}
 
-	// Sign the credential
-	signedCredential, err := SignCredential(&credential, issuerPrivateKey)
+	claims := jwt.MapClaims{
+		ClaimCredential: vc,
+		"iss":           c.Issuer,
+		"sub":           c.CredentialSubject.ID,
+		"jti":           c.ID,
+		"nbf":           c.IssuanceDate.Unix(),
+	}
+	if !c.ExpirationDate.IsZero() {
+		claims["exp"] = c.ExpirationDate.Unix()
+	}
+	return claims, nil
+}
+
+// FromJWTClaims reads the credential from JWT claims in the EBSI layout, or from the credential claim of
+// tokens issued before it. Registered claims fill in fields the credential lacks and must agree with the
+// fields it has.
+func (c *VerifiableCredential) FromJWTClaims(claims jwt.MapClaims) error {
+	vc, ok := claims[ClaimCredential]
+	if !ok {
+		vc, ok = claims[ClaimLegacyCredential]
+	}
+	if !ok {
+		return fmt.Errorf("failed to get credential from claims")
+	}
+	credentialJSON, err := json.Marshal(vc)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to decode credential claim: %v", err)
+	}
+	var credential VerifiableCredential
+	if err := json.Unmarshal(credentialJSON, &credential); err != nil {
+		return fmt.Errorf("failed to decode credential claim: %v", err)
 	}
 
-	return signedCredential, nil
+	for _, field := range []struct {
+		claim string
+		value *string
+	}{
+		{"iss", &credential.Issuer},
+		{"sub", &credential.CredentialSubject.ID},
+		{"jti", &credential.ID},
+	} {
+		value, present := claims[field.claim]
+		if !present {
+			continue
+		}
+		claim, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("claim %s is not a string", field.claim)
+		}
+		if *field.value == "" {
+			*field.value = claim
+		} else if *field.value != claim {
+			return fmt.Errorf("claim %s does not match the credential", field.claim)
+		}
+	}
+	for _, field := range []struct {
+		claim string
+		value *time.Time
+	}{
+		{"nbf", &credential.IssuanceDate},
+		{"exp", &credential.ExpirationDate},
+	} {
+		value, present := claims[field.claim]
+		if !present {
+			continue
+		}
+		seconds, ok := numericDate(value)
+		if !ok {
+			return fmt.Errorf("claim %s is not a numeric date", field.claim)
+		}
+		if field.value.IsZero() {
+			*field.value = time.Unix(seconds, 0).UTC()
+		} else if field.value.Unix() != seconds {
+			return fmt.Errorf("claim %s does not match the credential", field.claim)
+		}
+	}
+
+	*c = credential
+	return nil
 }
 
-func CreateAndSignBatchCredential(issuerDID string, issuerPrivateKey *ecdsa.PrivateKey, subjectID string, credentialID string) (*VerifiableCredential, error) {
+// maxNumericDate is the last second of year 9999, the latest time a credential date can be encoded as JSON
+const maxNumericDate = 253402300799
+
+// numericDate reads a JWT NumericDate, a float64 after JSON decoding or an integer in claims built in Go.
+// Dates before 1970 or after year 9999 are rejected.
+func numericDate(value interface{}) (int64, bool) {
+	var seconds int64
+	switch v := value.(type) {
+	case float64:
+		if v < 0 || v > maxNumericDate {
+			return 0, false
+		}
+		seconds = int64(v)
+	case int64:
+		seconds = v
+	case int:
+		seconds = int64(v)
+	case json.Number:
+		var err error
+		if seconds, err = v.Int64(); err != nil {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+	return seconds, seconds >= 0 && seconds <= maxNumericDate
+}
+
+// CreateAndSignCredential creates and signs a credential issued now
+func CreateAndSignCredential(issuerDID string, issuerPrivateKey crypto.PrivateKey, subjectID string) (*VerifiableCredential, error) {
+	return CreateAndSignCredentialAt(clock.System.Now(), issuerDID, issuerPrivateKey, subjectID, "")
+}
+
+func CreateAndSignBatchCredential(issuerDID string, issuerPrivateKey crypto.PrivateKey, subjectID string, credentialID string) (*VerifiableCredential, error) {
+	return CreateAndSignCredentialAt(clock.System.Now(), issuerDID, issuerPrivateKey, subjectID, credentialID)
+}
+
+// CreateAndSignCredentialAt creates and signs a credential issued at issuedAt and valid for ten years.
+// A non-empty credentialID is appended to the credential id.
+func CreateAndSignCredentialAt(issuedAt time.Time, issuerDID string, issuerPrivateKey crypto.PrivateKey, subjectID string, credentialID string) (*VerifiableCredential, error) {
 	// Create the credential
 	credential := VerifiableCredential{
 		Context: []string{
@@ -88,8 +195,8 @@ func CreateAndSignBatchCredential(issuerDID string, issuerPrivateKey *ecdsa.Priv
 		ID:             "http://example.edu/credentials/1872" + credentialID,
 		Type:           []string{"VerifiableCredential", "AlumniCredential"},
 		Issuer:         issuerDID,
-		IssuanceDate:   time.Now(),
-		ExpirationDate: time.Now().AddDate(10, 0, 0),
+		IssuanceDate:   issuedAt,
+		ExpirationDate: issuedAt.AddDate(10, 0, 0),
 		CredentialSubject: CredentialSubject{
 			ID: subjectID,
 			AlumniOf: Alumni{
@@ -102,45 +209,109 @@ func CreateAndSignBatchCredential(issuerDID string, issuerPrivateKey *ecdsa.Priv
 		},
 	}
 
-	// Sign the credential
-	signedCredential, err := SignCredential(&credential, issuerPrivateKey)
+	status, err := NewCredentialStatus(credential.Issuer, credential.ID)
 	if err != nil {
 		return nil, err
 	}
-	return signedCredential, nil
+	credential.CredentialStatus = status
+
+	// Sign the credential
+	return signCredential(&credential, issuerPrivateKey, issuedAt)
 }
 
-// SignCredential signs the credential and returns it
-func SignCredential(credential *VerifiableCredential, privateKey *ecdsa.PrivateKey) (*VerifiableCredential, error) {
-	// Serialize the credential excluding the Proof
-	credentialCopy := *credential
-	credentialCopy.Proof = Proof{} // Exclude the Proof for signing
-	data, err := json.Marshal(credentialCopy)
+// ErrInvalidProof is returned when the proof of a credential does not verify with the issuer key
+var ErrInvalidProof = fmt.Errorf("%w: credential proof is invalid", ErrInvalidArgument)
+
+// Proof types by key type
+const (
+	ProofTypeECDSA   = "EcdsaSecp256k1VerificationKey2019"
+	ProofTypeEd25519 = "Ed25519Signature2018"
+)
+
+// SignCredential signs the credential with a P-256, secp256k1 or Ed25519 key and returns it
+func SignCredential(credential *VerifiableCredential, privateKey crypto.PrivateKey) (*VerifiableCredential, error) {
+	return signCredential(credential, privateKey, clock.System.Now())
+}
+
+func signCredential(credential *VerifiableCredential, privateKey crypto.PrivateKey, created time.Time) (*VerifiableCredential, error) {
+	// The canonical credential excluding the Proof
+	payload, err := credential.signingInput()
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal credential: %v", err)
+		return nil, err
 	}
 
-	// Hash the serialized data
-	hash := sha256.Sum256(data)
-
-	// Sign the hash
-	r, s, err := ecdsa.Sign(rand.Reader, privateKey, hash[:])
+	var proofType string
+	switch privateKey.(type) {
+	case *ecdsa.PrivateKey:
+		proofType = ProofTypeECDSA
+	case ed25519.PrivateKey:
+		proofType = ProofTypeEd25519
+	default:
+		return nil, fmt.Errorf("failed to sign credential: unsupported key %T", privateKey)
+	}
+	// ECDSA keys sign with RFC 6979 nonces, so the proof is reproducible
+	jws, err := signDetachedJWS(payload, privateKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to sign credential: %v", err)
 	}
 
-	// Convert the signature to a format suitable for JSON encoding
-	signature := append(r.Bytes(), s.Bytes()...)
-	encodedSignature := base64.StdEncoding.EncodeToString(signature)
-
 	// Add the proof to the credential
 	credential.Proof = Proof{
-		Type:               "EcdsaSecp256k1VerificationKey2019",
-		Created:            time.Now(),
+		Type:               proofType,
+		Created:            created,
 		ProofPurpose:       "assertionMethod",
 		VerificationMethod: "https://example.edu/issuers/565049#keys-1",
-		JWS:                encodedSignature,
+		JWS:                jws,
 	}
 
 	return credential, nil
 }
+
+// VerifyProof checks the detached JWS in the proof of a credential against the public key of its
+// issuer. The JWS algorithm and the proof type must match the key type.
+func VerifyProof(credential *VerifiableCredential, publicKey crypto.PublicKey) error {
+	var proofType string
+	switch publicKey.(type) {
+	case *ecdsa.PublicKey:
+		proofType = ProofTypeECDSA
+	case ed25519.PublicKey:
+		proofType = ProofTypeEd25519
+	default:
+		return fmt.Errorf("unsupported key: %T", publicKey)
+	}
+	if credential.Proof.Type != proofType {
+		return fmt.Errorf("%w: proof type %s does not match the key", ErrInvalidProof, credential.Proof.Type)
+	}
+	payload, err := credential.signingInput()
+	if err != nil {
+		return err
+	}
+	if err := verifyDetachedJWS(credential.Proof.JWS, payload, publicKey); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	return nil
+}
+
+// VerifyCredentialProof checks the proof of a credential against the public key of its issuer.
+//
+// Deprecated: use VerifyProof.
+func VerifyCredentialProof(credential *VerifiableCredential, publicKey crypto.PublicKey) error {
+	return VerifyProof(credential, publicKey)
+}
+
+// signingInput returns the canonical JSON of the credential without its proof, the detached payload
+// of the proof's JWS
+func (c *VerifiableCredential) signingInput() ([]byte, error) {
+	credentialJSON, err := json.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal credential: %v", err)
+	}
+	var document map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(credentialJSON))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("failed to marshal credential: %v", err)
+	}
+	delete(document, "proof") // Exclude the Proof for signing
+	return encodeCanonical(document)
+}
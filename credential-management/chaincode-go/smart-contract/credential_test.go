@@ -0,0 +1,94 @@
+package cuckoofilter_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"github.com/dgrijalva/jwt-go"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func testCredential(t *testing.T) *cuckoofilter.VerifiableCredential {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	credential, err := cuckoofilter.CreateAndSignCredentialAt(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), "did:key:issuer", key, "did:key:holder", "-1")
+	require.NoError(t, err)
+	return credential
+}
+
+func TestToJWTClaims(t *testing.T) {
+	credential := testCredential(t)
+	claims, err := credential.ToJWTClaims()
+	require.NoError(t, err)
+	require.Equal(t, "did:key:issuer", claims["iss"])
+	require.Equal(t, "did:key:holder", claims["sub"])
+	require.Equal(t, credential.ID, claims["jti"])
+	require.Equal(t, credential.IssuanceDate.Unix(), claims["nbf"])
+	require.Equal(t, credential.ExpirationDate.Unix(), claims["exp"])
+
+	// The claims survive signing and parsing
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tokenString, err := jwt.NewWithClaims(jwt.SigningMethodES256, claims).SignedString(key)
+	require.NoError(t, err)
+	token, err := jwt.Parse(tokenString, func(*jwt.Token) (interface{}, error) { return &key.PublicKey, nil })
+	require.NoError(t, err)
+
+	var parsed cuckoofilter.VerifiableCredential
+	require.NoError(t, parsed.FromJWTClaims(token.Claims.(jwt.MapClaims)))
+	expected, err := json.Marshal(credential)
+	require.NoError(t, err)
+	actual, err := json.Marshal(parsed)
+	require.NoError(t, err)
+	require.JSONEq(t, string(expected), string(actual))
+}
+
+func TestFromJWTClaims_LegacyCredentialClaim(t *testing.T) {
+	credential := testCredential(t)
+	var parsed cuckoofilter.VerifiableCredential
+	require.NoError(t, parsed.FromJWTClaims(jwt.MapClaims{"credential": credential}))
+	require.Equal(t, credential.ID, parsed.ID)
+	require.Equal(t, "did:key:holder", parsed.CredentialSubject.ID)
+}
+
+func TestFromJWTClaims_RegisteredClaimsFillIn(t *testing.T) {
+	var parsed cuckoofilter.VerifiableCredential
+	require.NoError(t, parsed.FromJWTClaims(jwt.MapClaims{
+		"vc":  map[string]interface{}{"type": []string{"VerifiableCredential"}},
+		"iss": "did:key:issuer",
+		"sub": "did:key:holder",
+		"jti": "urn:uuid:1",
+		"exp": float64(1900000000),
+	}))
+	require.Equal(t, "did:key:issuer", parsed.Issuer)
+	require.Equal(t, "did:key:holder", parsed.CredentialSubject.ID)
+	require.Equal(t, "urn:uuid:1", parsed.ID)
+	require.Equal(t, int64(1900000000), parsed.ExpirationDate.Unix())
+}
+
+func TestFromJWTClaims_Mismatch(t *testing.T) {
+	claims, err := testCredential(t).ToJWTClaims()
+	require.NoError(t, err)
+
+	for claim, value := range map[string]interface{}{
+		"iss": "did:key:other",
+		"sub": "did:key:other",
+		"jti": 42,
+		"exp": int64(0),
+	} {
+		changed := jwt.MapClaims{}
+		for name, v := range claims {
+			changed[name] = v
+		}
+		changed[claim] = value
+		var parsed cuckoofilter.VerifiableCredential
+		require.Error(t, parsed.FromJWTClaims(changed), claim)
+	}
+
+	var parsed cuckoofilter.VerifiableCredential
+	require.Error(t, parsed.FromJWTClaims(jwt.MapClaims{"iss": "did:key:issuer"}))
+}
@@ -7,20 +7,33 @@ import (
 	"fmt"
 	metro "github.com/dgryski/go-metro"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/pherbke/credential-management/chaincode-go/core"
 	"math/rand"
 	"os"
 	"time"
 )
 
-const MaxCuckooKicks = 500  // Define a constant for maximum cuckoo kicks
-const DefaultBucketSize = 4 // Define a default bucket size
-const FingerPrintSize = 8   // Define a default fingerprint size
+const MaxCuckooKicks = 500                      // Define a constant for maximum cuckoo kicks
+const DefaultBucketSize = 4                     // Define a default bucket size
+const FingerPrintSize = core.MaxFingerprintSize // Define a default fingerprint size, which is also the largest
+
+// filterStateKey is the ledger key holding the serialized cuckoo filter
+const filterStateKey = "CuckooFilterState"
 
 // Filter represents the cuckoo filter structure
 type Filter struct {
 	Buckets         []*bucket
 	Count           uint
 	BucketIndexMask uint
+	FingerprintSize uint // Bytes per fingerprint, 0 in states saved before it was configurable
+	MaxKicks        uint `json:",omitempty" metadata:",optional"` // Relocations per insert, 0 for MaxCuckooKicks
+	SemiSorted      bool `json:",omitempty" metadata:",optional"` // Buckets are serialized packed, see packBuckets
+
+	growLoadFactor float64 // Configured ResizeLoadFactor of the registry, set when loaded from the ledger
+	growBuckets    uint    // Configured MaxFilterBuckets of the registry, set when loaded from the ledger
+	fingerprintKey []byte  // Registry secret of keyed fingerprints, set when loaded from the ledger
+
+	deltas *filterDeltas // Bucket deltas the default filter was loaded with, set with delta persistence
 }
 
 type bucket struct {
@@ -37,6 +50,22 @@ func NewBucket(size uint) *bucket {
 	}
 }
 
+// maxKicks returns how many fingerprints an insert may relocate
+func (f *Filter) maxKicks() int {
+	if f.MaxKicks == 0 {
+		return MaxCuckooKicks
+	}
+	return int(f.MaxKicks)
+}
+
+// fingerprintSize returns the bytes per fingerprint of the filter
+func (f *Filter) fingerprintSize() uint {
+	if f.FingerprintSize == 0 {
+		return FingerPrintSize
+	}
+	return f.FingerprintSize
+}
+
 func (f *Filter) Capacity() uint {
 	return uint(len(f.Buckets)) * DefaultBucketSize
 }
@@ -47,11 +76,16 @@ func (f *Filter) Insert(data []byte) bool {
 		return false
 	}
 
+	// TODO: Split GetIndexAndFingerprint into two functions
+	i1, fp := f.indexAndFingerprint(data)
+	return f.insertFingerprint(i1, fp)
+}
+
+// insertFingerprint places a fingerprint in its primary bucket i1 or its alternate bucket, kicking out other fingerprints if needed
+func (f *Filter) insertFingerprint(i1 uint, fp fingerprint) bool {
 	// Set a stricter threshold for overfilling
 	overfillThreshold := uint(float32(f.Capacity()) * 1.7)
 
-	// TODO: Split GetIndexAndFingerprint into two functions
-	i1, fp := GetIndexAndFingerprint(data, f.BucketIndexMask, FingerPrintSize) // Assuming a fixed fingerprint size of 8
 	i2 := GetAltIndex(fp, i1, f.BucketIndexMask)
 
 	if f.tryInsert(i1, fp) || f.tryInsert(i2, fp) {
@@ -62,7 +96,7 @@ func (f *Filter) Insert(data []byte) bool {
 	}
 
 	// Cuckoo Kicking Logic
-	for i := 0; i < MaxCuckooKicks; i++ {
+	for i := 0; i < f.maxKicks(); i++ {
 		if f.Count >= overfillThreshold {
 			// Stop if overfill threshold is reached
 			return false
@@ -73,10 +107,13 @@ func (f *Filter) Insert(data []byte) bool {
 			oldFp := f.Buckets[j].randomFingerprint()
 			altIndex := GetAltIndex(oldFp, j, f.BucketIndexMask) // Get alternate index for the kicked out fingerprint
 
-			if f.tryInsert(altIndex, oldFp) {
-				// Successfully inserted in the alternate location
-				return f.tryInsert(j, fp) // Now try to insert the new fingerprint
+			if f.tryInsert(altIndex, oldFp) && f.tryInsert(j, fp) {
+				// Moved to its alternate location, making room for the new fingerprint
+				f.Count++
+				return true
 			}
+			// Put it back, a failed insert must not drop a fingerprint
+			f.tryInsert(j, oldFp)
 		} else if f.tryInsert(j, fp) {
 			f.Count++
 			return true
@@ -109,6 +146,20 @@ func (b *bucket) Insert(fp fingerprint) bool {
 // MarshalJSON customizes the JSON serialization of the Filter.
 func (f *Filter) MarshalJSON() ([]byte, error) {
 	type Alias Filter
+	if f.SemiSorted {
+		packed, err := packBuckets(f.Buckets, f.fingerprintSize())
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(&struct {
+			*Alias
+			Buckets       []*bucket `json:",omitempty"` // Left empty to shadow the buckets, PackedBuckets replaces them
+			PackedBuckets []byte
+		}{
+			Alias:         (*Alias)(f),
+			PackedBuckets: packed,
+		})
+	}
 	return json.Marshal(&struct {
 		*Alias
 		SerializedBuckets [][][]byte // Serialized representation of Buckets
@@ -135,14 +186,24 @@ func (f *Filter) UnmarshalJSON(data []byte) error {
 	aux := &struct {
 		*Alias
 		SerializedBuckets [][][]byte `json:"SerializedBuckets"`
+		PackedBuckets     []byte
 	}{
 		Alias: (*Alias)(f),
 	}
-	if err := json.Unmarshal(data, &aux); err != nil {
+	// Decode into aux itself, a JSON null would set a pointer to it to nil
+	if err := json.Unmarshal(data, aux); err != nil {
 		return err
 	}
-	f.Buckets = deserializeBuckets(aux.SerializedBuckets)
-	return nil
+	if f.SemiSorted {
+		buckets, err := unpackBuckets(aux.PackedBuckets, uint64(f.BucketIndexMask)+1, f.fingerprintSize())
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrCorruptFilter, err)
+		}
+		f.Buckets = buckets
+	} else {
+		f.Buckets = deserializeBuckets(aux.SerializedBuckets)
+	}
+	return f.Validate()
 }
 
 func deserializeBuckets(serializedBuckets [][][]byte) []*bucket {
@@ -192,6 +253,9 @@ func (b *bucket) randomFingerprint() fingerprint {
 // delete a fingerprint from a bucket.
 // Returns true if the fingerprint was present and successfully removed.
 func (b *bucket) delete(fp fingerprint) bool {
+	if b == nil {
+		return false
+	}
 	for i, tfp := range b.Data {
 		if equalFingerprints(tfp, fp) {
 			b.Data[i] = nil
@@ -202,6 +266,9 @@ func (b *bucket) delete(fp fingerprint) bool {
 }
 
 func (b *bucket) contains(needle fingerprint) bool {
+	if b == nil {
+		return false
+	}
 	for _, fp := range b.Data {
 		if equalFingerprints(fp, needle) {
 			return true
@@ -267,12 +334,54 @@ type SmartContract struct {
 	contractapi.Contract
 }
 
-// Init initializes the ledger with a new cuckoo filter
-func (s *SmartContract) Init(ctx contractapi.TransactionContextInterface, numElements uint, bucketSize uint) error {
-	filter := NewFilter(numElements, bucketSize)
-	// Save the cuckoo filter state
-	err := s.SaveFilterState(ctx, filter)
+// ResetTransientKey is the transient data key of the reset flag of Init. With "true", a registry admin
+// replaces a filter that already exists with an empty one.
+const ResetTransientKey = "reset"
+
+// Init initializes the ledger with a new cuckoo filter, the default filter or the named filter filterID.
+// Fingerprints of fingerprintSize bytes, 0 for FingerPrintSize, trade a false positive rate of about
+// 2*bucketSize/2^(8*fingerprintSize) against state size. Filters with fingerprints shorter than
+// FingerPrintSize cannot be resized, so they do not grow when they fill up. Filters have between 1 and
+// MaxResizeBuckets buckets of between 1 and MaxBucketSize slots. Registry admins only; an existing filter
+// is only replaced, dropping all its revocations, with ResetTransientKey set. Named filters cannot be
+// created while keyed fingerprints are enabled, see SetKeyedFingerprints.
+func (s *SmartContract) Init(ctx contractapi.TransactionContextInterface, filterID string, numElements uint, bucketSize uint, fingerprintSize uint) error {
+	if err := checkWritable(ctx); err != nil {
+		return err
+	}
+	if err := checkRegistryAdmin(ctx, "initialize filters"); err != nil {
+		return err
+	}
+	if numElements > MaxResizeBuckets {
+		return fmt.Errorf("%w: number of buckets must be between 1 and %d", ErrInvalidArgument, MaxResizeBuckets)
+	}
+	if fingerprintSize == 0 {
+		fingerprintSize = FingerPrintSize
+	}
+	filter, err := NewFilterWithOptions(WithNumElements(numElements), WithBucketSize(bucketSize), WithFingerprintSize(fingerprintSize))
 	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	if filterID != DefaultFilterID {
+		if err := checkUnkeyed(ctx, "named filters"); err != nil {
+			return err
+		}
+	}
+	exists, err := filterExists(ctx, filterID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		reset, err := transientReset(ctx)
+		if err != nil {
+			return err
+		}
+		if !reset {
+			return fmt.Errorf("%w: filter '%s' is already initialized", ErrFailedPrecondition, filterID)
+		}
+	}
+	// Save the cuckoo filter state
+	if err := s.saveFilter(ctx, filterID, filter); err != nil {
 		return err
 	}
 
@@ -280,42 +389,172 @@ func (s *SmartContract) Init(ctx contractapi.TransactionContextInterface, numEle
 	return ctx.GetStub().PutState("Initialized", []byte("true"))
 }
 
-// Insert adds data to the cuckoo filter - Revoke a credential
-func (s *SmartContract) Insert(ctx contractapi.TransactionContextInterface, data string) error {
-	filter, err := s.LoadFilterState(ctx)
+// transientReset returns the reset flag of Init passed as transient data
+func transientReset(ctx contractapi.TransactionContextInterface) (bool, error) {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return false, fmt.Errorf("error reading transient data: %v", err)
+	}
+	return string(transient[ResetTransientKey]) == "true", nil
+}
+
+// Insert adds data to the cuckoo filter - Revoke a credential. With SuspendedUntilTransientKey set, the
+// credential is suspended instead, until SweepExpired removes it after the given time.
+func (s *SmartContract) Insert(ctx contractapi.TransactionContextInterface, filterID string, data string) error {
+	if err := checkWritable(ctx); err != nil {
+		return err
+	}
+	if err := checkCapability(ctx, CapabilityActionRevoke, filterID); err != nil {
+		return err
+	}
+	if err := checkStrictMode(ctx, data); err != nil {
+		return err
+	}
+	suspendedUntil, err := transientSuspension(ctx)
+	if err != nil {
+		return err
+	}
+	filter, err := s.loadFilter(ctx, filterID)
 	if err != nil {
 		return fmt.Errorf("error loading filter state: %v", err)
 	}
-	if !filter.Insert([]byte(data)) {
-		return fmt.Errorf("failed to insert data '%s' into cuckoo filter", []byte(data))
+	filter, inserted := insertGrowing(filter, []byte(data))
+	if !inserted {
+		return insertFailure(filter, []byte(data), fmt.Sprintf("data '%s'", data))
 	}
-	return s.SaveFilterState(ctx, filter)
+	if err := recordInserter(ctx, filterScope(filterID), data); err != nil {
+		return err
+	}
+	if err := recordLifecycleEvent(ctx, LifecycleRevoked, filterDetails(filterID), data); err != nil {
+		return err
+	}
+	if err := recordTransientRevocationAudit(ctx, LifecycleRevoked, data); err != nil {
+		return err
+	}
+	if suspendedUntil != nil {
+		if err := recordSuspensions(ctx, filterID, *suspendedUntil, data); err != nil {
+			return err
+		}
+	}
+	if err := s.saveFilter(ctx, filterID, filter); err != nil {
+		return err
+	}
+	return emitFilterChanged(ctx, filterID, FilterChangeInserted, filter, []string{data})
 }
 
-func (s *SmartContract) BatchInsert(ctx contractapi.TransactionContextInterface, dataItems []string) error {
-	filter, err := s.LoadFilterState(ctx)
+// BatchInsert adds data items to a filter and reports the result of every item. By default the batch is
+// atomic: the first item that cannot be inserted fails it. With IdempotentTransientKey set to "true",
+// items that are already in the filter are reported as duplicates instead of failing the batch. With
+// PartialTransientKey set to "true", duplicates and items the filter has no room for are reported and
+// the other items are saved. With SuspendedUntilTransientKey set, the inserted items are suspended as
+// by Insert.
+func (s *SmartContract) BatchInsert(ctx contractapi.TransactionContextInterface, filterID string, dataItems []string) (*BatchInsertResult, error) {
+	reason, err := transientReason(ctx)
 	if err != nil {
-		return fmt.Errorf("error loading filter state: %v", err)
+		return nil, err
+	}
+	idempotent, err := transientIdempotent(ctx)
+	if err != nil {
+		return nil, err
+	}
+	partial, err := transientPartial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	suspendedUntil, err := transientSuspension(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.batchInsert(ctx, filterID, reason, idempotent, partial, dataItems)
+	if err != nil || suspendedUntil == nil {
+		return result, err
+	}
+	var inserted []string
+	for _, data := range dataItems {
+		if result.Results[data] == BatchInsertInserted {
+			inserted = append(inserted, data)
+		}
+	}
+	if err := recordSuspensions(ctx, filterID, *suspendedUntil, inserted...); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// batchInsert inserts data items into a filter, recording the reason in the revocation audit, and reports
+// the result of every item. Idempotent inserts report items already in the filter as duplicates, partial
+// inserts all items that cannot be inserted. Either writes nothing if no item is inserted.
+func (s *SmartContract) batchInsert(ctx contractapi.TransactionContextInterface, filterID string, reason string, idempotent bool, partial bool, dataItems []string) (*BatchInsertResult, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	if err := checkCapability(ctx, CapabilityActionRevoke, filterID); err != nil {
+		return nil, err
+	}
+	if err := checkBatchSize(ctx, len(dataItems)); err != nil {
+		return nil, err
+	}
+	if err := checkStrictMode(ctx, dataItems...); err != nil {
+		return nil, err
 	}
+	return s.insertItems(ctx, filterID, reason, idempotent, partial, dataItems)
+}
 
-	successfulInserts := 0
+// insertItems inserts checked data items into a filter like batchInsert, without the batch size limit
+func (s *SmartContract) insertItems(ctx contractapi.TransactionContextInterface, filterID string, reason string, idempotent bool, partial bool, dataItems []string) (*BatchInsertResult, error) {
+	filter, err := s.loadFilter(ctx, filterID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading filter state: %v", err)
+	}
+
+	result := &BatchInsertResult{Results: make(map[string]string)}
+	inserted := []string{}
 	for _, data := range dataItems {
-		if !filter.Insert([]byte(data)) {
-			return fmt.Errorf("failed to insert data '%s' into cuckoo filter after %d successful insertions", data, successfulInserts)
+		if _, seen := result.Results[data]; seen && (idempotent || partial) {
+			continue
+		}
+		var ok bool
+		if filter, ok = insertGrowing(filter, []byte(data)); ok {
+			result.add(data, BatchInsertInserted)
+			inserted = append(inserted, data)
+			continue
+		}
+		failure := insertFailure(filter, []byte(data), fmt.Sprintf("data '%s'", data))
+		duplicate := errors.Is(failure, ErrAlreadyRevoked)
+		switch {
+		case duplicate && (idempotent || partial):
+			result.add(data, BatchInsertDuplicate)
+		case partial:
+			result.add(data, BatchInsertFailed)
+		default:
+			return nil, fmt.Errorf("%w after %d successful insertions", failure, len(inserted))
 		}
-		successfulInserts++
-		//fmt.Printf("Successful inserts so far: %d\n", successfulInserts)
+	}
+	if len(inserted) == 0 && (idempotent || partial) {
+		return result, nil
+	}
 
+	if err := recordInserter(ctx, filterScope(filterID), inserted...); err != nil {
+		return nil, err
+	}
+	if err := recordLifecycleEvent(ctx, LifecycleRevoked, filterDetails(filterID), inserted...); err != nil {
+		return nil, err
+	}
+	if err := recordRevocationAudit(ctx, LifecycleRevoked, reason, inserted...); err != nil {
+		return nil, err
 	}
-	if err := s.SaveFilterState(ctx, filter); err != nil {
-		return fmt.Errorf("error saving filter state after %d successful insertions: %v", successfulInserts, err)
+	if err := s.saveFilter(ctx, filterID, filter); err != nil {
+		return nil, fmt.Errorf("error saving filter state after %d successful insertions: %v", len(inserted), err)
 	}
-	return nil
+	if err := emitFilterChanged(ctx, filterID, FilterChangeInserted, filter, inserted); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
 // Lookup checks if data is present in the cuckoo filter
-func (s *SmartContract) Lookup(ctx contractapi.TransactionContextInterface, data string) (bool, error) {
-	filter, err := s.LoadFilterState(ctx)
+func (s *SmartContract) Lookup(ctx contractapi.TransactionContextInterface, filterID string, data string) (bool, error) {
+	filter, err := s.loadFilter(ctx, filterID)
 	if err != nil {
 		return false, err
 	}
@@ -323,8 +562,11 @@ func (s *SmartContract) Lookup(ctx contractapi.TransactionContextInterface, data
 	return filter.Lookup([]byte(data)), nil
 }
 
-func (s *SmartContract) BatchLookup(ctx contractapi.TransactionContextInterface, dataItems []string) (map[string]bool, error) {
-	filter, err := s.LoadFilterState(ctx)
+func (s *SmartContract) BatchLookup(ctx contractapi.TransactionContextInterface, filterID string, dataItems []string) (map[string]bool, error) {
+	if err := checkBatchSize(ctx, len(dataItems)); err != nil {
+		return nil, err
+	}
+	filter, err := s.loadFilter(ctx, filterID)
 	if err != nil {
 		return nil, fmt.Errorf("error loading filter state: %v", err)
 	}
@@ -336,51 +578,274 @@ func (s *SmartContract) BatchLookup(ctx contractapi.TransactionContextInterface,
 }
 
 // Delete removes data from the cuckoo filter - Unrevoke a credential
-func (s *SmartContract) Delete(ctx contractapi.TransactionContextInterface, data string) error {
-	filter, err := s.LoadFilterState(ctx)
+func (s *SmartContract) Delete(ctx contractapi.TransactionContextInterface, filterID string, data string) error {
+	if err := checkWritable(ctx); err != nil {
+		return err
+	}
+	if err := checkCapability(ctx, CapabilityActionUnrevoke, filterID); err != nil {
+		return err
+	}
+	filter, err := s.loadFilter(ctx, filterID)
 	if err != nil {
 		return err
 	}
 
 	if !filter.Delete([]byte(data)) {
-		return errors.New("failed to delete data from cuckoo filter")
+		return fmt.Errorf("%w: failed to delete data from cuckoo filter", ErrNotFound)
+	}
+	if err := authorizeDelete(ctx, filterScope(filterID), data); err != nil {
+		return err
+	}
+	if err := recordLifecycleEvent(ctx, LifecycleUnrevoked, filterDetails(filterID), data); err != nil {
+		return err
+	}
+	if err := recordTransientRevocationAudit(ctx, LifecycleUnrevoked, data); err != nil {
+		return err
+	}
+
+	if err := s.saveFilter(ctx, filterID, filter); err != nil {
+		return err
 	}
+	return emitFilterChanged(ctx, filterID, FilterChangeDeleted, filter, []string{data})
+}
+
+// Per-item results of BatchDelete
+const (
+	BatchDeleteDeleted      = "deleted"
+	BatchDeleteNotFound     = "not_found"
+	BatchDeleteUnauthorized = "unauthorized"
+)
 
-	return s.SaveFilterState(ctx, filter)
+// BatchDeleteResult reports the outcome of a BatchDelete
+type BatchDeleteResult struct {
+	Results      map[string]string `json:"results"` // Result of every item
+	Deleted      int               `json:"deleted"`
+	NotFound     int               `json:"notFound"`
+	Unauthorized int               `json:"unauthorized"`
 }
 
-func (s *SmartContract) BatchDelete(ctx contractapi.TransactionContextInterface, dataItems []string) error {
-	filter, err := s.LoadFilterState(ctx)
+func (r *BatchDeleteResult) add(data string, status string) {
+	r.Results[data] = status
+	switch status {
+	case BatchDeleteDeleted:
+		r.Deleted++
+	case BatchDeleteNotFound:
+		r.NotFound++
+	case BatchDeleteUnauthorized:
+		r.Unauthorized++
+	}
+}
+
+// BatchDelete removes data items from the cuckoo filter and reports the result of every item.
+// Without atomic, items the client is not authorized to delete are reported and left in the filter.
+// With atomic, an item the client may not delete fails the whole batch, as before results were reported.
+// Items that are not present are reported as not found either way.
+func (s *SmartContract) BatchDelete(ctx contractapi.TransactionContextInterface, filterID string, dataItems []string, atomic bool) (*BatchDeleteResult, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	if err := checkCapability(ctx, CapabilityActionUnrevoke, filterID); err != nil {
+		return nil, err
+	}
+	if err := checkBatchSize(ctx, len(dataItems)); err != nil {
+		return nil, err
+	}
+	filter, err := s.loadFilter(ctx, filterID)
 	if err != nil {
-		return fmt.Errorf("error loading filter state: %v", err)
+		return nil, fmt.Errorf("error loading filter state: %v", err)
 	}
-	for _, data := range dataItems {
-		filter.Delete([]byte(data)) // Ignore the result; attempt to delete whether it exists or not
+
+	result := &BatchDeleteResult{Results: make(map[string]string)}
+	var deleted []string
+	if atomic {
+		var present []string
+		for _, data := range dataItems {
+			if filter.Lookup([]byte(data)) {
+				present = append(present, data)
+			}
+		}
+		if err := authorizeDelete(ctx, filterScope(filterID), present...); err != nil {
+			return nil, err
+		}
+		for _, data := range present {
+			if filter.Delete([]byte(data)) {
+				result.add(data, BatchDeleteDeleted)
+				deleted = append(deleted, data)
+			}
+		}
+		for _, data := range dataItems {
+			if _, reported := result.Results[data]; !reported {
+				result.add(data, BatchDeleteNotFound)
+			}
+		}
+		if err := recordLifecycleEvent(ctx, LifecycleUnrevoked, filterDetails(filterID), present...); err != nil {
+			return nil, err
+		}
+		if err := recordTransientRevocationAudit(ctx, LifecycleUnrevoked, present...); err != nil {
+			return nil, err
+		}
+	} else {
+		client, admin, err := clientInserter(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, data := range dataItems {
+			if _, seen := result.Results[data]; seen {
+				continue
+			}
+			status, err := batchDeleteItem(ctx, filterID, filter, client, admin, data)
+			if err != nil {
+				return nil, err
+			}
+			result.add(data, status)
+			if status == BatchDeleteDeleted {
+				deleted = append(deleted, data)
+			}
+		}
 	}
-	if err := s.SaveFilterState(ctx, filter); err != nil {
-		return fmt.Errorf("error saving filter state: %v", err)
+
+	if err := s.saveFilter(ctx, filterID, filter); err != nil {
+		return nil, fmt.Errorf("error saving filter state: %v", err)
 	}
-	return nil
+	if err := emitFilterChanged(ctx, filterID, FilterChangeDeleted, filter, deleted); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
-// SaveFilterState saves the current state of the cuckoo filter to the ledger
+// batchDeleteItem deletes one item of a reported batch and returns its BatchDelete* status
+func batchDeleteItem(ctx contractapi.TransactionContextInterface, filterID string, filter *Filter, client *Inserter, admin bool, data string) (string, error) {
+	if !filter.Lookup([]byte(data)) {
+		return BatchDeleteNotFound, nil
+	}
+	if err := authorizeDeleteBy(ctx, client, admin, filterScope(filterID), data); err == ErrNotInserter {
+		return BatchDeleteUnauthorized, nil
+	} else if err != nil {
+		return "", err
+	}
+	if !filter.Delete([]byte(data)) {
+		return BatchDeleteNotFound, nil
+	}
+	if err := deleteInserter(ctx, filterScope(filterID), data); err != nil {
+		return "", err
+	}
+	if err := recordLifecycleEvent(ctx, LifecycleUnrevoked, filterDetails(filterID), data); err != nil {
+		return "", err
+	}
+	if err := recordTransientRevocationAudit(ctx, LifecycleUnrevoked, data); err != nil {
+		return "", err
+	}
+	return BatchDeleteDeleted, nil
+}
+
+// SaveFilterState saves the current state of the cuckoo filter to the ledger,
+// as bucket deltas when delta persistence is enabled and as shards in the sharded layout
 func (s *SmartContract) SaveFilterState(ctx contractapi.TransactionContextInterface, filter *Filter) error {
+	if err := checkWritable(ctx); err != nil {
+		return err
+	}
+	return saveFilterState(ctx, filter)
+}
+
+// saveFilterState is SaveFilterState without the freeze check
+func saveFilterState(ctx contractapi.TransactionContextInterface, filter *Filter) error {
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := putFilterEpoch(ctx, filterStateKey, filter); err != nil {
+		return err
+	}
+	if config.DeltaPersistence {
+		return saveFilterDeltas(ctx, filter)
+	}
+	if err := putMerkleRoot(ctx, filterStateKey, filter); err != nil {
+		return err
+	}
+	if config.StateLayout != StateLayoutSingle {
+		if err := saveShardedFilter(ctx, config.StateShards, filter); err != nil {
+			return err
+		}
+		if config.StateLayout == StateLayoutSharded {
+			return nil
+		}
+	}
 	filterJSON, err := json.Marshal(filter)
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState("CuckooFilterState", filterJSON)
+	return putCollectionStateWithHash(ctx, config.PrivateCollection, filterStateKey, filterStateKey, filterJSON)
 }
 
-// LoadFilterState retrieves the cuckoo filter state from the ledger
+// LoadFilterState retrieves the cuckoo filter state from the ledger, failing with ErrCorruptState
+// when it does not match the state hash written with it. Outstanding bucket deltas are applied.
+// During a migration to the sharded layout, the sharded state is read.
 func (s *SmartContract) LoadFilterState(ctx contractapi.TransactionContextInterface) (*Filter, error) {
-	filterJSON, err := ctx.GetStub().GetState("CuckooFilterState")
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := loadDefaultFilter(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	filter.growLoadFactor = config.ResizeLoadFactor
+	filter.growBuckets = config.MaxFilterBuckets
+	if config.KeyedFingerprints {
+		if filter.fingerprintKey, err = loadFingerprintSecret(ctx, config); err != nil {
+			return nil, err
+		}
+	}
+	return filter, nil
+}
+
+// loadDefaultFilter retrieves the default filter in the layout of the configuration
+func loadDefaultFilter(ctx contractapi.TransactionContextInterface, config *RegistryConfig) (*Filter, error) {
+	if config.StateLayout != StateLayoutSingle {
+		return loadShardedFilter(ctx)
+	}
+	filter, err := loadFilterBase(ctx, config.PrivateCollection)
+	if err != nil {
+		return nil, err
+	}
+	if !config.DeltaPersistence {
+		return filter, nil
+	}
+	deltas, err := loadFilterDeltas(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyFilterDeltas(filter, deltas.data); err != nil {
+		return nil, err
+	}
+	deltas.buckets = filter.clone().Buckets
+	filter.deltas = deltas
+	return filter, nil
+}
+
+// errFilterStateNotFound is returned when the default filter was not initialized
+var errFilterStateNotFound = fmt.Errorf("%w: filter state not found", ErrNotFound)
+
+// loadFilterBase retrieves the filter state without its bucket deltas from the given private data
+// collection, empty for the public state
+func loadFilterBase(ctx contractapi.TransactionContextInterface, collection string) (*Filter, error) {
+	storedHash, err := storedStateHash(ctx, collection, filterStateKey)
+	if err != nil {
+		return nil, err
+	}
+	filterJSON, err := getCollectionState(ctx, collection, filterStateKey)
 	if err != nil {
 		return nil, err
 	}
 	if filterJSON == nil {
-		return nil, errors.New("filter state not found")
+		return nil, errFilterStateNotFound
+	}
+	if err := checkStateHash(storedHash, filterJSON); err != nil {
+		return nil, err
+	}
+	if filter := filterCache.get(storedHash); filter != nil {
+		return filter, nil
 	}
 
 	var filter Filter
@@ -388,6 +853,7 @@ func (s *SmartContract) LoadFilterState(ctx contractapi.TransactionContextInterf
 	if err != nil {
 		return nil, err
 	}
+	filterCache.put(storedHash, &filter)
 
 	return &filter, nil
 }
@@ -405,18 +871,19 @@ func (s *SmartContract) ReadJWTFromFile(ctx contractapi.TransactionContextInterf
 	return string(jwtBytes), nil
 }
 
-// NewFilter creates a new cuckoo filter with the specified number of elements
-func NewFilter(numElements uint, bucketSize uint) *Filter {
-	numBuckets := GetNextPow2(uint64(numElements))
-	buckets := make([]*bucket, numBuckets)
-	for i := range buckets {
-		buckets[i] = NewBucket(bucketSize)
-	}
-	return &Filter{
-		Buckets:         buckets,
-		Count:           0,
-		BucketIndexMask: uint(numBuckets - 1),
+// NewFilter creates a new cuckoo filter with the specified number of elements and fingerprints of
+// fingerprintSize bytes, at most FingerPrintSize. A size of 0 selects FingerPrintSize. Unlike
+// NewFilterWithOptions it does not validate its arguments.
+func NewFilter(numElements uint, bucketSize uint, fingerprintSize uint) *Filter {
+	if fingerprintSize == 0 {
+		fingerprintSize = FingerPrintSize
 	}
+	return newFilter(&filterOptions{
+		numElements:     numElements,
+		bucketSize:      bucketSize,
+		fingerprintSize: fingerprintSize,
+		maxKicks:        MaxCuckooKicks,
+	})
 }
 
 // Lookup checks if the data is present in the cuckoo filter
@@ -426,30 +893,24 @@ func (f *Filter) Lookup(data []byte) bool {
 	if f.Buckets == nil || len(f.Buckets) == 0 {
 		return false
 	}
-	i1, fp := GetIndexAndFingerprint(data, f.BucketIndexMask, FingerPrintSize)
-
-	if i1 >= uint(len(f.Buckets)) {
-		return false
-	}
-
+	i1, fp := f.indexAndFingerprint(data)
 	i2 := GetAltIndex(fp, i1, f.BucketIndexMask)
-	if i2 >= uint(len(f.Buckets)) {
-		return false
-	}
 	/*
 		if f.Buckets[i1].contains(fp) || f.Buckets[i2].contains(fp) {
 			fmt.Println("Credential is revoked")
 		}
 	*/
-	return f.Buckets[i1].contains(fp) || f.Buckets[i2].contains(fp)
+	return f.bucketAt(i1).contains(fp) || f.bucketAt(i2).contains(fp)
 }
 
 // Delete removes data from the cuckoo filter
 func (f *Filter) Delete(data []byte) bool {
-	i1, fp := GetIndexAndFingerprint(data, f.BucketIndexMask, 8) // Assuming a fixed fingerprint size of 8
+	i1, fp := f.indexAndFingerprint(data)
 	i2 := GetAltIndex(fp, i1, f.BucketIndexMask)
-	if f.Buckets[i1].delete(fp) || f.Buckets[i2].delete(fp) {
-		f.Count--
+	if f.bucketAt(i1).delete(fp) || f.bucketAt(i2).delete(fp) {
+		if f.Count > 0 {
+			f.Count--
+		}
 		return true
 	}
 	return false
@@ -465,17 +926,12 @@ func (f *Filter) Reset() {
 // Util.go
 // GetAltIndex calculates the alternate index for a given fingerprint and index.
 func GetAltIndex(fp []byte, i, bucketIndexMask uint) uint {
-	hash := metro.Hash64(fp, 1337)
-	return (i ^ uint(hash)) & bucketIndexMask
+	return core.AltIndex(fp, i, bucketIndexMask)
 }
 
 // GetFingerprint generates a fingerprint from a given hash value.
 func GetFingerprint(hash uint64, fingerprintSize uint) []byte {
-	fp := make([]byte, fingerprintSize)
-	for i := uint(0); i < fingerprintSize; i++ {
-		fp[i] = byte(hash >> (8 * i))
-	}
-	return fp
+	return core.FilterFingerprint(hash, fingerprintSize)
 }
 
 func deterministicSelector(data []byte, i1, i2 uint) uint {
@@ -488,11 +944,7 @@ func deterministicSelector(data []byte, i1, i2 uint) uint {
 
 // GetIndexAndFingerprint calculates the primary bucket index and fingerprint for given data.
 func GetIndexAndFingerprint(data []byte, bucketIndexMask uint, fingerprintSize uint) (uint, []byte) {
-	hash := metro.Hash64(data, 1337)
-	// print the size of the hash
-	fp := GetFingerprint(hash, fingerprintSize)
-	i1 := uint(hash>>32) & bucketIndexMask
-	return i1, fp
+	return core.IndexAndFingerprint(data, bucketIndexMask, fingerprintSize)
 }
 
 // GetNextPow2 calculates the next power of two greater than or equal to n.
@@ -10,7 +10,10 @@ import (
 	"errors"
 	"fmt"
 	"github.com/dgrijalva/jwt-go"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-protos-go/peer"
 	"github.com/pherbke/credential-management/chaincode-go/mocks"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
 	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
 	stakeholder "github.com/pherbke/credential-management/chaincode-go/smart-contract"
 	"github.com/stretchr/testify/mock"
@@ -31,20 +34,192 @@ func hashDataToUint64(data []byte) uint64 {
 	return hashUint64
 }
 
+// mockRegistryDefaults lets the stub answer the registry keys that contract calls read
+// besides the filter state as if they had never been written.
+func mockRegistryDefaults(mockStub *mocks.MockChaincodeStubInterface) {
+	mockStub.On("GetState", "RegistryConfig").Return(([]byte)(nil), nil).Maybe()
+	mockStub.On("GetState", "RegistryFreeze").Return(([]byte)(nil), nil).Maybe()
+	mockStub.On("GetState", "Initialized").Return(([]byte)(nil), nil).Maybe()
+	mockStub.On("CreateCompositeKey", "fingerprintInserter", mock.Anything).Return(inserterKey, nil).Maybe()
+	mockStub.On("GetState", inserterKey).Return(([]byte)(nil), nil).Maybe()
+	mockStub.On("PutState", inserterKey, mock.Anything).Return(nil).Maybe()
+	mockStub.On("DelState", inserterKey).Return(nil).Maybe()
+	mockStub.On("CreateCompositeKey", "stateHash", mock.Anything).Return(stateHashKey, nil).Maybe()
+	mockStub.On("GetState", stateHashKey).Return(([]byte)(nil), nil).Maybe()
+	mockStub.On("PutState", stateHashKey, mock.Anything).Return(nil).Maybe()
+	mockStub.On("CreateCompositeKey", "merkleRoot", mock.Anything).Return(merkleRootKey, nil).Maybe()
+	mockStub.On("PutState", merkleRootKey, mock.Anything).Return(nil).Maybe()
+	mockStub.On("CreateCompositeKey", "filterEpoch", mock.Anything).Return(filterEpochKey, nil).Maybe()
+	mockStub.On("GetState", filterEpochKey).Return(([]byte)(nil), nil).Maybe()
+	mockStub.On("PutState", filterEpochKey, mock.Anything).Return(nil).Maybe()
+	mockStub.On("CreateCompositeKey", "filterEpochLog", mock.Anything).Return(filterEpochLogKey, nil).Maybe()
+	mockStub.On("PutState", filterEpochLogKey, mock.Anything).Return(nil).Maybe()
+	mockStub.On("GetTransient").Return(map[string][]byte{}, nil).Maybe()
+	mockStub.On("GetTxID").Return("tx1").Maybe()
+	mockStub.On("GetTxTimestamp").Return(&timestamp.Timestamp{Seconds: 1700000000}, nil).Maybe()
+	mockStub.On("CreateCompositeKey", "credentialEvent", mock.Anything).Return(lifecycleEventKey, nil).Maybe()
+	mockStub.On("PutState", lifecycleEventKey, mock.Anything).Return(nil).Maybe()
+	mockStub.On("CreateCompositeKey", "subject", mock.Anything).Return(subjectKey, nil).Maybe()
+	mockStub.On("GetState", subjectKey).Return(([]byte)(nil), nil).Maybe()
+	mockStub.On("PutState", subjectKey, mock.Anything).Return(nil).Maybe()
+	mockStub.On("SetEvent", cuckoofilter.FilterChangedEvent, mock.Anything).Return(nil).Maybe()
+	mockStub.On("CreateCompositeKey", "revocation", mock.Anything).Return(revocationAuditKey, nil).Maybe()
+	mockStub.On("PutState", revocationAuditKey, mock.Anything).Return(nil).Maybe()
+	mockStub.On("CreateCompositeKey", "didStatus", mock.Anything).Return(didStatusKey, nil).Maybe()
+	mockStub.On("GetState", didStatusKey).Return(([]byte)(nil), nil).Maybe()
+	mockStub.On("CreateCompositeKey", "didKey", mock.Anything).Return(didKeyKey, nil).Maybe()
+	mockStub.On("GetState", didKeyKey).Return(([]byte)(nil), nil).Maybe()
+	mockStub.On("PutState", didKeyKey, mock.Anything).Return(nil).Maybe()
+	mockStub.On("CreateCompositeKey", "trustedIssuer", mock.Anything).Return(trustedIssuerKey, nil).Maybe()
+	mockStub.On("GetState", trustedIssuerKey).Return([]byte(`{"did":"did:key:issuer"}`), nil).Maybe()
+	mockStub.On("GetCreator").Return([]byte("creator"), nil).Maybe()
+	mockStub.On("GetSignedProposal").Return((*peer.SignedProposal)(nil), nil).Maybe()
+	mockStub.On("CreateCompositeKey", "writeProvenance", mock.Anything).Return(writeProvenanceKey, nil).Maybe()
+	mockStub.On("PutState", writeProvenanceKey, mock.Anything).Return(nil).Maybe()
+}
+
+// trustedIssuerKey is the trust registry key mockRegistryDefaults returns for every DID, all issuers are trusted
+const trustedIssuerKey = "\x00trustedIssuer\x00"
+
+// merkleRootKey is the Merkle root key mockRegistryDefaults returns for every filter state
+const merkleRootKey = "\x00merkleRoot\x00"
+
+// filterEpochKey and filterEpochLogKey are the epoch keys mockRegistryDefaults returns for every filter state
+const (
+	filterEpochKey    = "\x00filterEpoch\x00"
+	filterEpochLogKey = "\x00filterEpochLog\x00"
+)
+
+// writeProvenanceKey is the write provenance key mockRegistryDefaults returns for every record
+const writeProvenanceKey = "\x00writeProvenance\x00"
+
+// didStatusKey is the DID status key mockRegistryDefaults returns for every DID, none is deactivated
+const didStatusKey = "\x00didStatus\x00"
+
+// didKeyKey is the DID key record key mockRegistryDefaults returns for every DID, no key is registered
+const didKeyKey = "\x00didKey\x00"
+
+// revocationAuditKey is the revocation audit key mockRegistryDefaults returns for every entry
+const revocationAuditKey = "\x00revocation\x00"
+
+// subjectKey is the subject index key mockRegistryDefaults returns for every credential
+const subjectKey = "\x00subject\x00"
+
+// lifecycleEventKey is the lifecycle event key mockRegistryDefaults returns for every event
+const lifecycleEventKey = "\x00credentialEvent\x00"
+
+// stateHashKey is the state hash key mockRegistryDefaults returns for every filter state
+const stateHashKey = "\x00stateHash\x00"
+
+// inserterKey is the inserter record key mockRegistryDefaults returns for every fingerprint
+const inserterKey = "\x00fingerprintInserter\x00"
+
+// mockAdminIdentity lets the context submit as a registry admin, who may delete any fingerprint
+func mockAdminIdentity(mockTxContext *mocks.MockTransactionContext) {
+	mockTxContext.On("GetClientIdentity").Return(&mocks.ClientIdentity{
+		ID:         "x509::CN=registry-admin::CN=ca",
+		MSPID:      "Org1MSP",
+		Attributes: map[string]string{cuckoofilter.RegistryAdminAttribute: "true"},
+	})
+}
+
 func TestNewFilter(t *testing.T) {
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	require.NotNil(t, filter, "Expected non-nil filter")
 	require.Equal(t, uint(1023), filter.BucketIndexMask, "Expected bucket index mask to be 1023")
 }
 
+func TestNewFilter_FingerprintSize(t *testing.T) {
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, 2)
+	require.True(t, filter.Insert([]byte("credential-1")))
+	filterJSON, err := json.Marshal(filter)
+	require.NoError(t, err)
+
+	// The size survives serialization and keeps lookups and deletes on 2-byte fingerprints
+	var restored cuckoofilter.Filter
+	require.NoError(t, json.Unmarshal(filterJSON, &restored))
+	require.Equal(t, uint(2), restored.FingerprintSize)
+	require.True(t, restored.Lookup([]byte("credential-1")))
+	require.True(t, restored.Delete([]byte("credential-1")))
+	require.False(t, restored.Lookup([]byte("credential-1")))
+
+	_, err = filter.Resize(2000)
+	require.Error(t, err)
+}
+
+func TestNewFilter_DefaultFingerprintSize(t *testing.T) {
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, 0)
+	require.Equal(t, uint(cuckoofilter.FingerPrintSize), filter.FingerprintSize)
+}
+
+func TestInit_FingerprintSize(t *testing.T) {
+	sim, err := simulator.New("credential-management", &cuckoofilter.SmartContract{})
+	require.NoError(t, err)
+	admin, err := simulator.NewIdentity("Org1MSP", "registry-admin")
+	require.NoError(t, err)
+	_, err = sim.Submit(newRegistryAdmin(t), "Init", "", "100", "4", "9")
+	require.ErrorContains(t, err, cuckoofilter.InvalidArgumentErrorCode)
+	_, err = sim.Submit(newRegistryAdmin(t), "Init", "", "100", "4", "2")
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "Insert", "", "credential-1")
+	require.NoError(t, err)
+	requireStatus(t, sim, admin, "credential-1", cuckoofilter.CredentialStatusRevoked)
+	requireStatus(t, sim, admin, "credential-2", cuckoofilter.CredentialStatusActive)
+}
+
+func TestInit_OnlyByRegistryAdmin(t *testing.T) {
+	sim, err := simulator.New("credential-management", &cuckoofilter.SmartContract{})
+	require.NoError(t, err)
+	issuer := newIssuerIdentity(t, "Org1MSP", "did:key:issuer1")
+	_, err = sim.Submit(issuer, "Init", "", "100", "4", "0")
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+	_, err = sim.Submit(issuer, "Init", "tenant-a", "100", "4", "0")
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+
+	// Filters without buckets or slots, or too large to hold in memory, are rejected
+	for _, dimensions := range [][]string{
+		{"0", "4"},
+		{fmt.Sprint(cuckoofilter.MaxResizeBuckets + 1), "4"},
+		{"100", "0"},
+		{"100", fmt.Sprint(cuckoofilter.MaxBucketSize + 1)},
+	} {
+		_, err = sim.Submit(newRegistryAdmin(t), "Init", "", dimensions[0], dimensions[1], "0")
+		require.ErrorContains(t, err, cuckoofilter.InvalidArgumentErrorCode, dimensions)
+	}
+	_, err = sim.Submit(newRegistryAdmin(t), "Init", "", "100", "4", "0")
+	require.NoError(t, err)
+}
+
+func TestInit_ResetRequiresAdmin(t *testing.T) {
+	sim, issuer := newRegistrySimulator(t)
+	_, err := sim.Submit(issuer, "Insert", "", "credential-1")
+	require.NoError(t, err)
+	admin, err := simulator.NewIdentityWithAttributes("RegistryMSP", "registry-admin", map[string]string{
+		cuckoofilter.RegistryAdminAttribute: "true",
+	})
+	require.NoError(t, err)
+
+	// Initializing again must not wipe the revocations
+	_, err = sim.Submit(admin, "Init", "", "100", "4", "0")
+	require.ErrorContains(t, err, cuckoofilter.ErrFailedPrecondition.Error())
+	reset := map[string][]byte{cuckoofilter.ResetTransientKey: []byte("true")}
+	_, err = sim.SubmitTransient(issuer, reset, "Init", "", "100", "4", "0")
+	require.ErrorContains(t, err, cuckoofilter.ErrUnauthorized.Error())
+	requireStatus(t, sim, issuer, "credential-1", cuckoofilter.CredentialStatusRevoked)
+
+	_, err = sim.SubmitTransient(admin, reset, "Init", "", "100", "4", "0")
+	require.NoError(t, err)
+	requireStatus(t, sim, issuer, "credential-1", cuckoofilter.CredentialStatusActive)
+}
+
 func TestInsert_Success(t *testing.T) {
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	data := []byte("test data")
 	require.True(t, filter.Insert(data), "Expected successful insertion")
 }
 
 func TestInsert_MaxSizeData(t *testing.T) {
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	maxSizeData := make([]byte, 1024) // Data at the maximum allowed size
 	mrand.Read(maxSizeData)           // Fill with random data
 
@@ -53,7 +228,7 @@ func TestInsert_MaxSizeData(t *testing.T) {
 }
 
 func TestInsert_Failure(t *testing.T) {
-	filter := cuckoofilter.NewFilter(100, cuckoofilter.DefaultBucketSize) // Smaller filter for testing
+	filter := cuckoofilter.NewFilter(100, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize) // Smaller filter for testing
 	insertionFailures := 0
 	totalInsertions := 0
 
@@ -69,7 +244,7 @@ func TestInsert_Failure(t *testing.T) {
 }
 
 func TestInsert_Failure2(t *testing.T) {
-	filter := cuckoofilter.NewFilter(100, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(100, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	insertionFailures := 0
 	totalInsertions := 0
 
@@ -85,14 +260,14 @@ func TestInsert_Failure2(t *testing.T) {
 }
 
 func TestLookup(t *testing.T) {
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	data := []byte("test data")
 	filter.Insert(data)
 	require.True(t, filter.Lookup(data), "Expected data to be found")
 }
 
 func TestDelete(t *testing.T) {
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	data := []byte("test data")
 	filter.Insert(data)
 	require.True(t, filter.Delete(data), "Expected deletion to succeed")
@@ -100,7 +275,7 @@ func TestDelete(t *testing.T) {
 }
 
 func TestDelete_FromFullFilter(t *testing.T) {
-	filter := cuckoofilter.NewFilter(10, 1) // Small filter to reach full capacity quickly
+	filter := cuckoofilter.NewFilter(10, 1, cuckoofilter.FingerPrintSize) // Small filter to reach full capacity quickly
 
 	// Fill the filter to its full capacity
 	for i := 0; i < 10; i++ {
@@ -113,7 +288,7 @@ func TestDelete_FromFullFilter(t *testing.T) {
 }
 
 func TestDelete_LastRemainingItem(t *testing.T) {
-	filter := cuckoofilter.NewFilter(10, 1)
+	filter := cuckoofilter.NewFilter(10, 1, cuckoofilter.FingerPrintSize)
 	data := []byte("unique data")
 	filter.Insert(data)
 
@@ -123,7 +298,7 @@ func TestDelete_LastRemainingItem(t *testing.T) {
 }
 
 func TestDeleteNonExistent(t *testing.T) {
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	data := []byte("test data")
 	require.False(t, filter.Delete(data), "Expected deletion to fail")
 }
@@ -131,25 +306,29 @@ func TestDeleteNonExistent(t *testing.T) {
 // Additional tests for edge cases and other functionalities can be added here
 
 func TestInsert_Duplicate(t *testing.T) {
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	data := []byte("duplicate data")
 	require.True(t, filter.Insert(data), "Expected first insertion to succeed")
 	require.False(t, filter.Insert(data), "Expected duplicate insertion to fail")
 }
 
 func TestInsert_WithCuckooKicking(t *testing.T) {
-	filter := cuckoofilter.NewFilter(2, 1) // small filter to trigger cuckoo kicking easily
+	filter := cuckoofilter.NewFilter(2, 1, cuckoofilter.FingerPrintSize) // small filter to trigger cuckoo kicking easily
 	data1 := []byte("complex data 1")
 	data2 := []byte("different data 2")
 	data3 := []byte("another unique data 3") // this should trigger cuckoo kicking
 
 	require.True(t, filter.Insert(data1))
 	require.True(t, filter.Insert(data2))
-	require.True(t, filter.Insert(data3), "Expected insertion with cuckoo kicking to succeed")
+	// Both slots are taken, kicking must fail without dropping a fingerprint
+	require.False(t, filter.Insert(data3), "Expected insertion into a full filter to fail")
+	require.True(t, filter.Lookup(data1))
+	require.True(t, filter.Lookup(data2))
+	require.Equal(t, uint(2), filter.Count)
 }
 
 func TestRandomInsertDelete(t *testing.T) {
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	for i := 0; i < 100; i++ {
 		data := []byte(fmt.Sprintf("random%d", i))
 		require.True(t, filter.Insert(data), "Expected insertion to succeed")
@@ -158,7 +337,7 @@ func TestRandomInsertDelete(t *testing.T) {
 }
 
 func TestInsert_OverfilledBucket(t *testing.T) {
-	filter := cuckoofilter.NewFilter(10, 1) // Small filter with small buckets
+	filter := cuckoofilter.NewFilter(10, 1, cuckoofilter.FingerPrintSize) // Small filter with small buckets
 	for i := 0; i < 20; i++ {
 		data := []byte(fmt.Sprintf("data%d", i))
 		filter.Insert(data) // Insert more elements than the nominal capacity
@@ -169,7 +348,7 @@ func TestInsert_OverfilledBucket(t *testing.T) {
 }
 
 func TestBucketOverflow(t *testing.T) {
-	filter := cuckoofilter.NewFilter(1000, 1) // Set bucket size to 1 for easy overflow
+	filter := cuckoofilter.NewFilter(1000, 1, cuckoofilter.FingerPrintSize) // Set bucket size to 1 for easy overflow
 	successInserts := 0
 
 	// Attempt to insert multiple elements
@@ -185,7 +364,7 @@ func TestBucketOverflow(t *testing.T) {
 }
 
 func TestFingerprintCollision(t *testing.T) {
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	data1 := []byte("data1")
 	data2 := []byte("data2") // Assume data2 produces the same fingerprint as data1
 	filter.Insert(data1)
@@ -193,20 +372,20 @@ func TestFingerprintCollision(t *testing.T) {
 }
 
 func TestLookup_NonExistent(t *testing.T) {
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	nonExistentData := []byte("nonexistent")
 	require.False(t, filter.Lookup(nonExistentData), "Expected non-existent data to not be found")
 }
 
 func TestBucketIndexMask(t *testing.T) {
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	data := []byte("test data")
 	i1, _ := cuckoofilter.GetIndexAndFingerprint(data, filter.BucketIndexMask, 8)
 	require.Less(t, i1, uint(1024), "Expected bucket index to be within range")
 }
 
 func TestReset(t *testing.T) {
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	data := []byte("test data")
 	filter.Insert(data)
 	filter.Reset()
@@ -214,7 +393,7 @@ func TestReset(t *testing.T) {
 }
 
 func TestWithStringData(t *testing.T) {
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	data := "test string"
 	byteData := []byte(data)
 	// Insert
@@ -226,7 +405,7 @@ func TestWithStringData(t *testing.T) {
 }
 
 func TestWithEmptyData(t *testing.T) {
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	emptyData := []byte("")
 	// Insert
 	require.False(t, filter.Insert(emptyData), "Insertion of empty data should fail")
@@ -237,7 +416,7 @@ func TestWithEmptyData(t *testing.T) {
 }
 
 func TestMaxCuckooKicks(t *testing.T) {
-	filter := cuckoofilter.NewFilter(2, 1) // Small filter to reach max cuckoo kicks quickly
+	filter := cuckoofilter.NewFilter(2, 1, cuckoofilter.FingerPrintSize) // Small filter to reach max cuckoo kicks quickly
 	successInserts := 0
 
 	// Attempt to fill the filter, expecting to hit the max cuckoo kicks
@@ -253,7 +432,7 @@ func TestMaxCuckooKicks(t *testing.T) {
 }
 
 func TestRandomDataInsertions(t *testing.T) {
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	successInserts := 0
 
 	mrand.Seed(time.Now().UnixNano()) // Ensure randomness
@@ -274,7 +453,7 @@ func TestRandomDataInsertions(t *testing.T) {
 }
 
 func TestStress(t *testing.T) {
-	filter := cuckoofilter.NewFilter(10000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(10000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	successInserts := 0
 
 	// Perform a large number of insertions
@@ -297,7 +476,7 @@ func TestStress(t *testing.T) {
 }
 
 func TestSerialization(t *testing.T) {
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	data := []byte("test data")
 	filter.Insert(data)
 
@@ -322,7 +501,7 @@ func TestDeserialization_CorruptedData(t *testing.T) {
 }
 
 func TestSerializationIntegrity(t *testing.T) {
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	// Insert some data
 	for i := 0; i < 10; i++ {
 		data := []byte(fmt.Sprintf("data%d", i))
@@ -346,7 +525,7 @@ func TestSerializationIntegrity(t *testing.T) {
 }
 
 func TestFalsePositiveRate(t *testing.T) {
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	falsePositives := 0
 	totalChecks := 10000
 	// Insert data into the filter
@@ -369,7 +548,7 @@ func TestFalsePositiveRate(t *testing.T) {
 }
 
 func TestFilterReset(t *testing.T) {
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	data := []byte("test data")
 	filter.Insert(data)
 	filter.Reset()
@@ -380,7 +559,7 @@ func TestFilterReset(t *testing.T) {
 }
 
 func TestConsistencyAfterOperations(t *testing.T) {
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 
 	// Perform a series of insertions, deletions, and lookups
 	for i := 0; i < 100; i++ {
@@ -395,7 +574,7 @@ func TestConsistencyAfterOperations(t *testing.T) {
 }
 
 func TestConcurrentAccess(t *testing.T) {
-	filter := cuckoofilter.NewFilter(10000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewConcurrentFilter(cuckoofilter.NewFilter(10000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize))
 	var wg sync.WaitGroup
 	// Perform concurrent insertions
 	for i := 0; i < 1000; i++ {
@@ -407,6 +586,7 @@ func TestConcurrentAccess(t *testing.T) {
 		}(i)
 	}
 	wg.Wait() // Wait for all goroutines to finish
+	require.Equal(t, uint(1000), filter.Count())
 }
 
 func TestHashFunctionConsistency(t *testing.T) {
@@ -421,7 +601,7 @@ func TestHashFunctionConsistency(t *testing.T) {
 }
 
 func TestErrorHandling(t *testing.T) {
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 
 	// Test with invalid data
 	invalidData := []byte("")
@@ -433,7 +613,7 @@ func TestErrorHandling(t *testing.T) {
 }
 
 func TestBucketFillingAndClearing(t *testing.T) {
-	filter := cuckoofilter.NewFilter(10, 2) // Small filter for easier testing
+	filter := cuckoofilter.NewFilter(10, 2, cuckoofilter.FingerPrintSize) // Small filter for easier testing
 	data1 := []byte("data1")
 	data2 := []byte("data2")
 
@@ -458,7 +638,7 @@ func TestAuxiliaryFunctions(t *testing.T) {
 }
 
 func TestInsertZeroLengthData(t *testing.T) {
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	data := []byte("")
 
 	// Expect insert to fail with zero length data
@@ -466,7 +646,7 @@ func TestInsertZeroLengthData(t *testing.T) {
 }
 
 func TestFilterResetFunctionality(t *testing.T) {
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	// Insert some data
 	for i := 0; i < 50; i++ {
 		data := []byte(fmt.Sprintf("data%d", i))
@@ -494,7 +674,7 @@ func TestUtilityFunctionEdgeCases(t *testing.T) {
 }
 
 func TestFilter_SerializationDeserialization(t *testing.T) {
-	filter := cuckoofilter.NewFilter(10, 4)
+	filter := cuckoofilter.NewFilter(10, 4, cuckoofilter.FingerPrintSize)
 	data := []byte("test data")
 	filter.Insert(data)
 
@@ -551,7 +731,7 @@ func TestGetNextPow2(t *testing.T) {
 }
 
 func TestCapacity(t *testing.T) {
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	// Adjust the expected capacity calculation according to the actual implementation logic
 	// Assuming it rounds to the nearest power of two
 	expectedCapacity := uint(1024) * cuckoofilter.DefaultBucketSize // Adjusted to the nearest power of two
@@ -559,7 +739,7 @@ func TestCapacity(t *testing.T) {
 }
 
 func TestDeleteFunctionality(t *testing.T) {
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	data := []byte("test data")
 	filter.Insert(data)
 	require.True(t, filter.Delete(data), "Delete should successfully remove existing item")
@@ -567,7 +747,7 @@ func TestDeleteFunctionality(t *testing.T) {
 }
 
 func TestUnmarshalJSON(t *testing.T) {
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	data := []byte("test data")
 	filter.Insert(data)
 
@@ -584,10 +764,13 @@ func TestUnmarshalJSON(t *testing.T) {
 func TestInitLedger(t *testing.T) {
 	// Create a mock stub and mock transaction context
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
 
+	mockStub.On("GetState", "CuckooFilterState").Return(([]byte)(nil), nil)
 	// Mock the PutState method to simulate a successful state update
 	mockStub.On("PutState", "CuckooFilterState", mock.Anything).Return(nil)
+	mockStub.On("PutState", "Initialized", mock.Anything).Return(nil)
 
 	// Set the mock stub in the transaction context
 	mockTxContext.On("GetStub").Return(mockStub)
@@ -596,8 +779,9 @@ func TestInitLedger(t *testing.T) {
 	// Create an instance of the SmartContract
 	smartContract := new(cuckoofilter.SmartContract)
 
-	// Call the Init function with the mock transaction context
-	err := smartContract.Init(mockTxContext, 1000, cuckoofilter.DefaultBucketSize)
+	// Call the Init function as a registry admin with the mock transaction context
+	mockAdminIdentity(mockTxContext)
+	err := smartContract.Init(mockTxContext, "", 1000, cuckoofilter.DefaultBucketSize, 0)
 
 	// Assert that there were no errors
 	require.NoError(t, err)
@@ -609,9 +793,10 @@ func TestInitLedger(t *testing.T) {
 func TestInsertInCuckooFilter(t *testing.T) {
 	// Initialize the mock stub
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 
 	// Mock filter state in the ledger
-	filter := cuckoofilter.NewFilter(100, 4)
+	filter := cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize)
 	filterJSON, _ := json.Marshal(filter)
 	mockStub.On("GetState", "CuckooFilterState").Return(filterJSON, nil)
 	mockStub.On("PutState", "CuckooFilterState", mock.Anything).Return(nil)
@@ -620,6 +805,7 @@ func TestInsertInCuckooFilter(t *testing.T) {
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockTxContext.On("GetStub").Return(mockStub)
 	mockTxContext.Stub = mockStub // Ensure that the Stub is set
+	mockAdminIdentity(mockTxContext)
 
 	// Create a new instance of the SmartContract
 	smartContract := new(cuckoofilter.SmartContract)
@@ -628,7 +814,7 @@ func TestInsertInCuckooFilter(t *testing.T) {
 	testData := "testData"
 
 	// Call the Insert function
-	err := smartContract.Insert(mockTxContext, testData)
+	err := smartContract.Insert(mockTxContext, "", testData)
 
 	// Assert that there were no errors
 	require.NoError(t, err)
@@ -639,9 +825,10 @@ func TestInsertInCuckooFilter(t *testing.T) {
 
 func TestLookupInCuckooFilter(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 
 	// Create a filter and manually insert the test data
-	filter := cuckoofilter.NewFilter(100, 4)
+	filter := cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize)
 	testData := "testData"
 	filter.Insert([]byte(testData)) // Manually inserting the data into the filter
 
@@ -657,7 +844,7 @@ func TestLookupInCuckooFilter(t *testing.T) {
 	smartContract := new(cuckoofilter.SmartContract)
 
 	// Call the Lookup function
-	found, err := smartContract.Lookup(mockTxContext, testData)
+	found, err := smartContract.Lookup(mockTxContext, "", testData)
 
 	// Assertions
 	require.NoError(t, err)
@@ -666,9 +853,10 @@ func TestLookupInCuckooFilter(t *testing.T) {
 
 func TestLookupFailure(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 
 	// Create a filter without inserting the test data
-	filter := cuckoofilter.NewFilter(100, 4)
+	filter := cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize)
 	// Do not insert testData into the filter
 
 	// Marshal the filter state without the test data
@@ -684,7 +872,7 @@ func TestLookupFailure(t *testing.T) {
 
 	// Call the Lookup function with testData, which is not in the filter
 	testData := "testData"
-	found, err := smartContract.Lookup(mockTxContext, testData)
+	found, err := smartContract.Lookup(mockTxContext, "", testData)
 
 	// Assertions
 	require.NoError(t, err)
@@ -693,9 +881,10 @@ func TestLookupFailure(t *testing.T) {
 
 func TestDeleteInCuckooFilter(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 
 	// Create a filter and manually insert the test data
-	filter := cuckoofilter.NewFilter(100, 4)
+	filter := cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize)
 	testData := "testData"
 	filter.Insert([]byte(testData)) // Manually inserting the data into the filter
 
@@ -710,11 +899,12 @@ func TestDeleteInCuckooFilter(t *testing.T) {
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockTxContext.On("GetStub").Return(mockStub)
 	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
 
 	smartContract := new(cuckoofilter.SmartContract)
 
 	// Call the Delete function
-	err := smartContract.Delete(mockTxContext, testData)
+	err := smartContract.Delete(mockTxContext, "", testData)
 
 	// Assertions
 	require.NoError(t, err, "Delete operation should succeed")
@@ -722,17 +912,19 @@ func TestDeleteInCuckooFilter(t *testing.T) {
 
 func TestDeleteFailure(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	// Simulate failure in loading filter state by returning nil slice of bytes and an error
 	mockStub.On("GetState", "CuckooFilterState").Return(([]byte)(nil), errors.New("state not found"))
 
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockTxContext.On("GetStub").Return(mockStub)
 	mockTxContext.Stub = mockStub // Ensure that the Stub is set
+	mockAdminIdentity(mockTxContext)
 
 	smartContract := new(cuckoofilter.SmartContract)
 
 	// Attempt to delete data from the filter
-	err := smartContract.Delete(mockTxContext, "testData")
+	err := smartContract.Delete(mockTxContext, "", "testData")
 
 	// Assertions
 	require.Error(t, err, "Delete operation should fail when filter state cannot be loaded")
@@ -740,6 +932,7 @@ func TestDeleteFailure(t *testing.T) {
 
 func TestLoadFilterStateFailure(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockStub.On("GetState", "CuckooFilterState").Return(([]byte)(nil), errors.New("state not found"))
 
 	mockTxContext := new(mocks.MockTransactionContext)
@@ -748,16 +941,17 @@ func TestLoadFilterStateFailure(t *testing.T) {
 
 	smartContract := new(cuckoofilter.SmartContract)
 
-	_, err := smartContract.Lookup(mockTxContext, "testData")
+	_, err := smartContract.Lookup(mockTxContext, "", "testData")
 
 	require.Error(t, err)
 }
 
 func TestSaveFilterStateFailure(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 
 	// Mock GetState to return a valid filter state
-	filter := cuckoofilter.NewFilter(100, 4)
+	filter := cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize)
 	filterJSON, _ := json.Marshal(filter)
 	mockStub.On("GetState", "CuckooFilterState").Return(filterJSON, nil)
 
@@ -767,10 +961,11 @@ func TestSaveFilterStateFailure(t *testing.T) {
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockTxContext.On("GetStub").Return(mockStub)
 	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
 
 	smartContract := new(cuckoofilter.SmartContract)
 
-	err := smartContract.Insert(mockTxContext, "testData")
+	err := smartContract.Insert(mockTxContext, "", "testData")
 
 	// Assertions
 	require.Error(t, err)
@@ -779,11 +974,13 @@ func TestSaveFilterStateFailure(t *testing.T) {
 
 func TestBatchInsert_Failure(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockTxContext.On("GetStub").Return(mockStub)
 	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
 
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	filterJSON, _ := json.Marshal(filter)
 	mockStub.On("GetState", "CuckooFilterState").Return(filterJSON, nil)
 
@@ -794,17 +991,19 @@ func TestBatchInsert_Failure(t *testing.T) {
 	smartContract := new(cuckoofilter.SmartContract)
 	batchData := []string{"data1", "data2", "data3"} // Example batch data
 
-	err := smartContract.BatchInsert(mockTxContext, batchData)
+	_, err := smartContract.BatchInsert(mockTxContext, "", batchData)
 	require.Error(t, err, "Batch insert should fail with partial failure")
 }
 
 func TestBatchInsert_Success(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockTxContext.On("GetStub").Return(mockStub)
 	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
 
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	filterJSON, _ := json.Marshal(filter)
 	mockStub.On("GetState", "CuckooFilterState").Return(filterJSON, nil)
 	mockStub.On("PutState", "CuckooFilterState", mock.Anything).Return(nil)
@@ -812,7 +1011,7 @@ func TestBatchInsert_Success(t *testing.T) {
 	smartContract := new(cuckoofilter.SmartContract)
 	batchData := []string{"data1", "data2", "data3"} // Example batch data
 
-	err := smartContract.BatchInsert(mockTxContext, batchData)
+	_, err := smartContract.BatchInsert(mockTxContext, "", batchData)
 	require.NoError(t, err)
 
 	// Additional verification as required
@@ -820,11 +1019,13 @@ func TestBatchInsert_Success(t *testing.T) {
 
 func TestBatchInsert_LargeBatch(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockTxContext.On("GetStub").Return(mockStub)
 	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
 
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	filterJSON, _ := json.Marshal(filter)
 	mockStub.On("GetState", "CuckooFilterState").Return(filterJSON, nil)
 	mockStub.On("PutState", "CuckooFilterState", mock.Anything).Return(nil)
@@ -832,17 +1033,19 @@ func TestBatchInsert_LargeBatch(t *testing.T) {
 	smartContract := new(cuckoofilter.SmartContract)
 	batchData := make([]string, 1001) // Example batch data
 
-	err := smartContract.BatchInsert(mockTxContext, batchData)
+	_, err := smartContract.BatchInsert(mockTxContext, "", batchData)
 	require.Error(t, err, "Batch insert should fail with large batch data")
 }
 
 func TestBatchInsert_PartialFailure(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockTxContext.On("GetStub").Return(mockStub)
 	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
 
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	filterJSON, _ := json.Marshal(filter)
 	mockStub.On("GetState", "CuckooFilterState").Return(filterJSON, nil)
 
@@ -853,17 +1056,18 @@ func TestBatchInsert_PartialFailure(t *testing.T) {
 	smartContract := new(cuckoofilter.SmartContract)
 	batchData := []string{"data1", "data2", "data3"} // Example batch data
 
-	err := smartContract.BatchInsert(mockTxContext, batchData)
+	_, err := smartContract.BatchInsert(mockTxContext, "", batchData)
 	require.Error(t, err, "Batch insert should fail with partial failure")
 }
 
 func TestBatchLookup(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockTxContext.On("GetStub").Return(mockStub)
 	mockTxContext.Stub = mockStub
 
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	testData := "testData"
 	filter.Insert([]byte(testData))
 	filterJSON, _ := json.Marshal(filter)
@@ -872,7 +1076,7 @@ func TestBatchLookup(t *testing.T) {
 	smartContract := new(cuckoofilter.SmartContract)
 	batchData := []string{testData, "nonexistentData"}
 
-	results, err := smartContract.BatchLookup(mockTxContext, batchData)
+	results, err := smartContract.BatchLookup(mockTxContext, "", batchData)
 	require.NoError(t, err)
 	require.True(t, results[testData], "Existing data should be found")
 	require.False(t, results["nonexistentData"], "Non-existing data should not be found")
@@ -880,11 +1084,12 @@ func TestBatchLookup(t *testing.T) {
 
 func TestBatchLookupLargeBatch(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockTxContext.On("GetStub").Return(mockStub)
 	mockTxContext.Stub = mockStub
 
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	// Insert multiple data items into the filter
 	existingData := []string{"data1", "data2", "data3", "data4", "data5"}
 	for _, data := range existingData {
@@ -897,7 +1102,7 @@ func TestBatchLookupLargeBatch(t *testing.T) {
 	// Create a batch of data containing both existing and non-existing items
 	batchData := append(existingData, "nonexistentData1", "nonexistentData2", "nonexistentData3")
 
-	results, err := smartContract.BatchLookup(mockTxContext, batchData)
+	results, err := smartContract.BatchLookup(mockTxContext, "", batchData)
 	require.NoError(t, err)
 
 	// Check the lookup results for each data item
@@ -911,36 +1116,38 @@ func TestBatchLookupLargeBatch(t *testing.T) {
 
 func TestBatchLookupEmptyBatch(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockTxContext.On("GetStub").Return(mockStub)
 	mockTxContext.Stub = mockStub
 
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	filterJSON, _ := json.Marshal(filter)
 	mockStub.On("GetState", "CuckooFilterState").Return(filterJSON, nil)
 
 	smartContract := new(cuckoofilter.SmartContract)
 	batchData := []string{} // Empty batch
 
-	results, err := smartContract.BatchLookup(mockTxContext, batchData)
+	results, err := smartContract.BatchLookup(mockTxContext, "", batchData)
 	require.NoError(t, err)
 	require.Empty(t, results, "Results should be empty for an empty batch")
 }
 
 func TestBatchLookupAllNonExistent(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockTxContext.On("GetStub").Return(mockStub)
 	mockTxContext.Stub = mockStub
 
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	filterJSON, _ := json.Marshal(filter)
 	mockStub.On("GetState", "CuckooFilterState").Return(filterJSON, nil)
 
 	smartContract := new(cuckoofilter.SmartContract)
 	batchData := []string{"nonexistent1", "nonexistent2", "nonexistent3"}
 
-	results, err := smartContract.BatchLookup(mockTxContext, batchData)
+	results, err := smartContract.BatchLookup(mockTxContext, "", batchData)
 	require.NoError(t, err)
 
 	for _, data := range batchData {
@@ -950,11 +1157,13 @@ func TestBatchLookupAllNonExistent(t *testing.T) {
 
 func TestBatchDelete(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockTxContext.On("GetStub").Return(mockStub)
 	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
 
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	testData := "testData"
 	filter.Insert([]byte(testData))
 	filterJSON, _ := json.Marshal(filter)
@@ -964,7 +1173,7 @@ func TestBatchDelete(t *testing.T) {
 	smartContract := new(cuckoofilter.SmartContract)
 	batchData := []string{testData, "nonexistentData"}
 
-	err := smartContract.BatchDelete(mockTxContext, batchData)
+	_, err := smartContract.BatchDelete(mockTxContext, "", batchData, true)
 	require.NoError(t, err)
 
 	// Additional verification as required
@@ -972,11 +1181,13 @@ func TestBatchDelete(t *testing.T) {
 
 func TestBatchDeleteLargeBatch(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockTxContext.On("GetStub").Return(mockStub)
 	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
 
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	// Insert multiple data items into the filter
 	existingData := []string{"data1", "data2", "data3", "data4", "data5"}
 	for _, data := range existingData {
@@ -990,7 +1201,7 @@ func TestBatchDeleteLargeBatch(t *testing.T) {
 	// Create a batch of data containing both existing and non-existing items
 	batchData := append(existingData, "nonexistentData1", "nonexistentData2", "nonexistentData3")
 
-	err := smartContract.BatchDelete(mockTxContext, batchData)
+	_, err := smartContract.BatchDelete(mockTxContext, "", batchData, true)
 	require.NoError(t, err)
 
 	// Additional verification as required
@@ -998,12 +1209,14 @@ func TestBatchDeleteLargeBatch(t *testing.T) {
 
 func TestBatchDeletePartialFailure(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockTxContext.On("GetStub").Return(mockStub)
 	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
 
 	// Create a filter and manually insert the test data
-	filter := cuckoofilter.NewFilter(100, 4)
+	filter := cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize)
 	testData := "testData"
 	filter.Insert([]byte(testData)) // Manually inserting the data into the filter
 
@@ -1018,16 +1231,18 @@ func TestBatchDeletePartialFailure(t *testing.T) {
 	smartContract := new(cuckoofilter.SmartContract)
 	batchData := []string{testData, "nonexistentData"}
 
-	err := smartContract.BatchDelete(mockTxContext, batchData)
+	_, err := smartContract.BatchDelete(mockTxContext, "", batchData, true)
 	require.Error(t, err, "Batch delete should fail with partial failure")
 }
 
 func TestBatchDeleteLargeBatch2(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockTxContext.On("GetStub").Return(mockStub)
 	mockTxContext.Stub = mockStub
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	mockAdminIdentity(mockTxContext)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	// Insert multiple data items into the filter
 	existingData := []string{"data1", "data2", "data3", "data4", "data5"}
 	for _, data := range existingData {
@@ -1039,49 +1254,55 @@ func TestBatchDeleteLargeBatch2(t *testing.T) {
 	smartContract := new(cuckoofilter.SmartContract)
 	// Create a batch of data containing both existing and non-existing items
 	batchData := append(existingData, "nonexistentData1", "nonexistentData2", "nonexistentData3")
-	err := smartContract.BatchDelete(mockTxContext, batchData)
+	_, err := smartContract.BatchDelete(mockTxContext, "", batchData, true)
 	require.NoError(t, err)
 }
 
 func TestBatchDeleteEmptyBatch(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockTxContext.On("GetStub").Return(mockStub)
 
 	mockTxContext.Stub = mockStub
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	mockAdminIdentity(mockTxContext)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	filterJSON, _ := json.Marshal(filter)
 	mockStub.On("GetState", "CuckooFilterState").Return(filterJSON, nil)
 	mockStub.On("PutState", "CuckooFilterState", mock.Anything).Return(nil)
 	smartContract := new(cuckoofilter.SmartContract)
 	batchData := []string{} // Empty batch
-	err := smartContract.BatchDelete(mockTxContext, batchData)
+	_, err := smartContract.BatchDelete(mockTxContext, "", batchData, true)
 	require.NoError(t, err)
 }
 
 func TestBatchDeleteAllNonExistent(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockTxContext.On("GetStub").Return(mockStub)
 
 	mockTxContext.Stub = mockStub
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	mockAdminIdentity(mockTxContext)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	filterJSON, _ := json.Marshal(filter)
 	mockStub.On("GetState", "CuckooFilterState").Return(filterJSON, nil)
 	mockStub.On("PutState", "CuckooFilterState", mock.Anything).Return(nil)
 	smartContract := new(cuckoofilter.SmartContract)
 	batchData := []string{"nonexistent1", "nonexistent2", "nonexistent3"}
-	err := smartContract.BatchDelete(mockTxContext, batchData)
+	_, err := smartContract.BatchDelete(mockTxContext, "", batchData, true)
 	require.NoError(t, err)
 }
 
 func TestBatchDeleteAllExisting(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockTxContext.On("GetStub").Return(mockStub)
 
 	mockTxContext.Stub = mockStub
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	mockAdminIdentity(mockTxContext)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	// Insert multiple data items into the filter
 	existingData := []string{"data1", "data2", "data3", "data4", "data5"}
 	for _, data := range existingData {
@@ -1092,103 +1313,118 @@ func TestBatchDeleteAllExisting(t *testing.T) {
 	mockStub.On("PutState", "CuckooFilterState", mock.Anything).Return(nil)
 	smartContract := new(cuckoofilter.SmartContract)
 	batchData := existingData
-	err := smartContract.BatchDelete(mockTxContext, batchData)
+	_, err := smartContract.BatchDelete(mockTxContext, "", batchData, true)
 	require.NoError(t, err)
 }
 
 // github.com/pherbke/credential-management/chaincode-go/smart-contract/cuckoofilter.go:64.35,67.4 1 0
 func TestBatchDeleteFailure(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockTxContext.On("GetStub").Return(mockStub)
 
 	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
 	mockStub.On("GetState", "CuckooFilterState").Return(([]byte)(nil), errors.New("state not found"))
 	smartContract := new(cuckoofilter.SmartContract)
 	batchData := []string{"nonexistent1", "nonexistent2", "nonexistent3"}
-	err := smartContract.BatchDelete(mockTxContext, batchData)
+	_, err := smartContract.BatchDelete(mockTxContext, "", batchData, true)
 	require.Error(t, err)
 }
 
 func TestBatchInsertFailure(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockTxContext.On("GetStub").Return(mockStub)
 
 	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
 	mockStub.On("GetState", "CuckooFilterState").Return(([]byte)(nil), errors.New("state not found"))
 	smartContract := new(cuckoofilter.SmartContract)
 	batchData := []string{"nonexistent1", "nonexistent2", "nonexistent3"}
-	err := smartContract.BatchInsert(mockTxContext, batchData)
+	_, err := smartContract.BatchInsert(mockTxContext, "", batchData)
 	require.Error(t, err)
 }
 
 func TestDeleteFailure2(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockStub.On("GetState", "CuckooFilterState").Return(([]byte)(nil), errors.New("state not found"))
 	mockTxContext.On("GetStub").Return(mockStub)
 	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
 	smartContract := new(cuckoofilter.SmartContract)
-	err := smartContract.Delete(mockTxContext, "testData")
+	err := smartContract.Delete(mockTxContext, "", "testData")
 	require.Error(t, err)
 }
 
 func TestInsertFailure2(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockStub.On("GetState", "CuckooFilterState").Return(([]byte)(nil), errors.New("state not found"))
 	mockTxContext.On("GetStub").Return(mockStub)
 	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
 	smartContract := new(cuckoofilter.SmartContract)
-	err := smartContract.Insert(mockTxContext, "testData")
+	err := smartContract.Insert(mockTxContext, "", "testData")
 	require.Error(t, err)
 }
 
 func TestLookupFailure2(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockStub.On("GetState", "CuckooFilterState").Return(([]byte)(nil), errors.New("state not found"))
 	mockTxContext.On("GetStub").Return(mockStub)
 	mockTxContext.Stub = mockStub
 	smartContract := new(cuckoofilter.SmartContract)
-	_, err := smartContract.Lookup(mockTxContext, "testData")
+	_, err := smartContract.Lookup(mockTxContext, "", "testData")
 	require.Error(t, err)
 }
 
 func TestBatchInsertFailure2(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockStub.On("GetState", "CuckooFilterState").Return(([]byte)(nil), errors.New("state not found"))
 	mockTxContext.On("GetStub").Return(mockStub)
 	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
 	smartContract := new(cuckoofilter.SmartContract)
 	batchData := []string{"nonexistent1", "nonexistent2", "nonexistent3"}
-	err := smartContract.BatchInsert(mockTxContext, batchData)
+	_, err := smartContract.BatchInsert(mockTxContext, "", batchData)
 	require.Error(t, err)
 }
 
 func TestBatchInsertFailure3(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockStub.On("GetState", "CuckooFilterState").Return(([]byte)(nil), errors.New("state not found"))
 	mockTxContext.On("GetStub").Return(mockStub)
 	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
 	smartContract := new(cuckoofilter.SmartContract)
 	batchData := []string{"nonexistent1", "nonexistent2", "nonexistent3"}
-	err := smartContract.BatchInsert(mockTxContext, batchData)
+	_, err := smartContract.BatchInsert(mockTxContext, "", batchData)
 	require.Error(t, err)
 }
 
 func TestBatchInsertFailure4(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockStub.On("GetState", "CuckooFilterState").Return(([]byte)(nil), errors.New("state not found"))
 	mockTxContext.On("GetStub").Return(mockStub)
 	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
 	smartContract := new(cuckoofilter.SmartContract)
 	batchData := []string{"nonexistent1", "nonexistent2", "nonexistent3"}
-	err := smartContract.BatchInsert(mockTxContext, batchData)
+	_, err := smartContract.BatchInsert(mockTxContext, "", batchData)
 	require.Error(t, err)
 }
 
@@ -1199,25 +1435,25 @@ func TestNewBucket(t *testing.T) {
 }
 
 func TestInsert(t *testing.T) {
-	filter := cuckoofilter.NewFilter(100, 4)
+	filter := cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize)
 	testData := "testData"
 	require.True(t, filter.Insert([]byte(testData)), "Insertion of data should succeed")
 }
 
 func TestTryInsert(t *testing.T) {
-	filter := cuckoofilter.NewFilter(100, 4)
+	filter := cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize)
 	testData := "testData"
 	require.True(t, filter.Insert([]byte(testData)), "Insertion of data should succeed")
 }
 
 func TestInsert2(t *testing.T) {
-	filter := cuckoofilter.NewFilter(100, 4)
+	filter := cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize)
 	testData := "testData"
 	require.True(t, filter.Insert([]byte(testData)), "Insertion of data should succeed")
 }
 
 func TestMarshalJSON(t *testing.T) {
-	filter := cuckoofilter.NewFilter(100, 4)
+	filter := cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize)
 	filterJSON, err := filter.MarshalJSON()
 	require.NoError(t, err)
 	require.NotNil(t, filterJSON)
@@ -1226,7 +1462,7 @@ func TestMarshalJSON(t *testing.T) {
 // Test Case: Validate the JSON serialization of the filter.
 // Function Name: (f *Filter) UnmarshalJSON(data []byte) error
 func TestUnmarshalJSON2(t *testing.T) {
-	filter := cuckoofilter.NewFilter(100, 4)
+	filter := cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize)
 	filterJSON, err := filter.MarshalJSON()
 	require.NoError(t, err)
 	require.NotNil(t, filterJSON)
@@ -1273,19 +1509,23 @@ func TestReset2(t *testing.T) {
 // Function Name: (b *bucket) String() string
 func TestString2(t *testing.T) {
 	bucket := cuckoofilter.NewBucket(4)
-	bucket.String()
+	require.Equal(t, "[null null null null ]", bucket.String())
 }
 
 // Test Case: Validate the string representation of the bucket.
 // Function Name: (s *SmartContract) Init(ctx contractapi.TransactionContextInterface, numElements uint, bucketSize uint) error
 func TestInitLedger2(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
+	mockStub.On("GetState", "CuckooFilterState").Return(([]byte)(nil), nil)
 	mockStub.On("PutState", "CuckooFilterState", mock.Anything).Return(nil)
+	mockStub.On("PutState", "Initialized", mock.Anything).Return(nil)
 	mockTxContext.On("GetStub").Return(mockStub)
 	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
 	smartContract := new(cuckoofilter.SmartContract)
-	err := smartContract.Init(mockTxContext, 100, 4)
+	err := smartContract.Init(mockTxContext, "", 100, 4, 0)
 	require.NoError(t, err)
 }
 
@@ -1293,15 +1533,17 @@ func TestInitLedger2(t *testing.T) {
 // Function Name: (s *SmartContract) Insert(ctx contractapi.TransactionContextInterface, data string) error
 func TestInsert3(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
-	filter := cuckoofilter.NewFilter(100, 4)
+	filter := cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize)
 	filterJSON, _ := json.Marshal(filter)
 	mockStub.On("GetState", "CuckooFilterState").Return(filterJSON, nil)
 	mockStub.On("PutState", "CuckooFilterState", mock.Anything).Return(nil)
 	mockTxContext.On("GetStub").Return(mockStub)
 	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
 	smartContract := new(cuckoofilter.SmartContract)
-	err := smartContract.Insert(mockTxContext, "testData")
+	err := smartContract.Insert(mockTxContext, "", "testData")
 	require.NoError(t, err)
 }
 
@@ -1309,16 +1551,18 @@ func TestInsert3(t *testing.T) {
 // Function Name: (s *SmartContract) BatchInsert(ctx contractapi.TransactionContextInterface, dataItems []string) error
 func TestBatchInsert2(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
-	filter := cuckoofilter.NewFilter(100, 4)
+	filter := cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize)
 	filterJSON, _ := json.Marshal(filter)
 	mockStub.On("GetState", "CuckooFilterState").Return(filterJSON, nil)
 	mockStub.On("PutState", "CuckooFilterState", mock.Anything).Return(nil)
 	mockTxContext.On("GetStub").Return(mockStub)
 	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
 	smartContract := new(cuckoofilter.SmartContract)
 	batchData := []string{"data1", "data2", "data3"}
-	err := smartContract.BatchInsert(mockTxContext, batchData)
+	_, err := smartContract.BatchInsert(mockTxContext, "", batchData)
 	require.NoError(t, err)
 }
 
@@ -1326,8 +1570,9 @@ func TestBatchInsert2(t *testing.T) {
 // Function Name: (s *SmartContract) Lookup(ctx contractapi.TransactionContextInterface, data string) (bool, error)
 func TestLookup2(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
-	filter := cuckoofilter.NewFilter(100, 4)
+	filter := cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize)
 	testData := "testData"
 	filter.Insert([]byte(testData))
 	filterJSON, _ := json.Marshal(filter)
@@ -1335,7 +1580,7 @@ func TestLookup2(t *testing.T) {
 	mockTxContext.On("GetStub").Return(mockStub)
 	mockTxContext.Stub = mockStub
 	smartContract := new(cuckoofilter.SmartContract)
-	_, err := smartContract.Lookup(mockTxContext, testData)
+	_, err := smartContract.Lookup(mockTxContext, "", testData)
 	require.NoError(t, err)
 }
 
@@ -1343,8 +1588,9 @@ func TestLookup2(t *testing.T) {
 // Function Name: (s *SmartContract) BatchLookup(ctx contractapi.TransactionContextInterface, dataItems []string) (map[string]bool, error)
 func TestBatchLookup2(t *testing.T) {
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
-	filter := cuckoofilter.NewFilter(100, 4)
+	filter := cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize)
 	testData := "testData"
 	filter.Insert([]byte(testData))
 	filterJSON, _ := json.Marshal(filter)
@@ -1353,7 +1599,7 @@ func TestBatchLookup2(t *testing.T) {
 	mockTxContext.Stub = mockStub
 	smartContract := new(cuckoofilter.SmartContract)
 	batchData := []string{testData, "nonexistentData"}
-	_, err := smartContract.BatchLookup(mockTxContext, batchData)
+	_, err := smartContract.BatchLookup(mockTxContext, "", batchData)
 	require.NoError(t, err)
 }
 
@@ -1497,8 +1743,9 @@ func GenerateFingerprints(credentials []string, fpLength int) ([]string, error)
 }
 
 func TestCredentialRevocationAndQuery(t *testing.T) {
+	useStakeholderDir(t)
 	// Create a new Cuckoo filter
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 
 	// Create test credentials
 	credentials, err := CreateTestCredentials(1)
@@ -1516,6 +1763,7 @@ func TestCredentialRevocationAndQuery(t *testing.T) {
 
 	// Save the filter state to the ledger
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
 	filterJSON, err := filter.MarshalJSON()
 	require.NoError(t, err)
@@ -1552,12 +1800,15 @@ func TestCredentialRevocationAndQuery(t *testing.T) {
 // using batch insert smartContract.BatchInsert
 // and batch lookup smartContract.BatchLookup
 func TestBatchCredentialRevocationAndQuery(t *testing.T) {
+	useStakeholderDir(t)
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockTxContext.On("GetStub").Return(mockStub)
 	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
 
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	filterJSON, _ := json.Marshal(filter)
 	mockStub.On("GetState", "CuckooFilterState").Return(filterJSON, nil)
 	mockStub.On("PutState", "CuckooFilterState", mock.Anything).Return(nil)
@@ -1569,7 +1820,7 @@ func TestBatchCredentialRevocationAndQuery(t *testing.T) {
 	fingerprints, err := GenerateFingerprints(credentials, 8)
 	require.NoError(t, err)
 
-	errI := smartContract.BatchInsert(mockTxContext, fingerprints)
+	_, errI := smartContract.BatchInsert(mockTxContext, "", fingerprints)
 	require.NoError(t, errI, "Batch insert should not fail")
 
 	err = smartContract.SaveFilterState(mockTxContext, filter)
@@ -1581,14 +1832,14 @@ func TestBatchCredentialRevocationAndQuery(t *testing.T) {
 	require.NoError(t, err)
 
 	// lookup inserted fingerprints
-	_, err = smartContract.BatchLookup(mockTxContext, fingerprints)
+	_, err = smartContract.BatchLookup(mockTxContext, "", fingerprints)
 	require.NoError(t, err)
 
 	// delete fingerprints batchwise from the filter
-	err = smartContract.BatchDelete(mockTxContext, fingerprints)
+	_, err = smartContract.BatchDelete(mockTxContext, "", fingerprints, true)
 	require.NoError(t, err)
 
-	results, _ := smartContract.BatchLookup(mockTxContext, fingerprints)
+	results, _ := smartContract.BatchLookup(mockTxContext, "", fingerprints)
 	require.False(t, results[fingerprints[0]], "Fingerprint should not be found")
 }
 
@@ -1604,9 +1855,10 @@ func calculateErrorRate(filter *cuckoofilter.Filter, testData []string, testSize
 }
 
 func TestErrorRate(t *testing.T) {
+	useStakeholderDir(t)
 	filterSize := 1000
 	testSize := 1000 // Number of items to test for false positives
-	filter := cuckoofilter.NewFilter(uint(filterSize), cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(uint(filterSize), cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 
 	// Create and insert credentials
 	credentials, err := CreateTestCredentials(filterSize)
@@ -1637,10 +1889,14 @@ func TestErrorRate(t *testing.T) {
 
 // Single Processing
 func TestCredentialVerificationAndRevocation(t *testing.T) {
+	useStakeholderDir(t)
 	stakeholderContract := new(stakeholder.StakeholderManagementContract)
 	smartContract := new(cuckoofilter.SmartContract)
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
+	mockTxContext.On("GetStub").Return(mockStub)
+	mockTxContext.Stub = mockStub
 
 	// Generate DIDs for the issuer and holder
 	issuerDIDResponse, _ := stakeholderContract.GenerateDID(mockTxContext, "issuer")
@@ -1649,7 +1905,7 @@ func TestCredentialVerificationAndRevocation(t *testing.T) {
 	_, _ = stakeholderContract.IssuingCredential(mockTxContext, issuerDIDResponse.DID, holderDIDResponse.DID)
 
 	// Create a filter and manually insert the test data
-	filter := cuckoofilter.NewFilter(100, 4)
+	filter := cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize)
 	testData, _ := smartContract.ReadJWTFromFile(mockTxContext, holderDIDResponse.DID)
 	fmt.Print(testData)
 	// Revoke Credential
@@ -1659,8 +1915,7 @@ func TestCredentialVerificationAndRevocation(t *testing.T) {
 	filterJSON, _ := json.Marshal(filter)
 	// Mock GetState to return the updated filter state
 	mockStub.On("GetState", "CuckooFilterState").Return(filterJSON, nil)
-	mockTxContext.On("GetStub").Return(mockStub)
-	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
 
 	// Call the Lookup function
 	// Verify the credential from the verifier's perspective
@@ -1668,7 +1923,7 @@ func TestCredentialVerificationAndRevocation(t *testing.T) {
 	require.NoError(t, err, "VerifyingCredential should not return an error")
 	require.True(t, isValid, "VerifyingCredential should return true for a valid credential")
 
-	found, err := smartContract.Lookup(mockTxContext, testData)
+	found, err := smartContract.Lookup(mockTxContext, "", testData)
 	// Assertions
 	require.NoError(t, err)
 	require.True(t, found, "Data should be found in cuckoo filter")
@@ -1676,7 +1931,7 @@ func TestCredentialVerificationAndRevocation(t *testing.T) {
 	// Unrevoke the credential
 	// Mock PutState to simulate successful delete operation
 	mockStub.On("PutState", "CuckooFilterState", mock.Anything).Return(nil)
-	err = smartContract.Delete(mockTxContext, testData)
+	err = smartContract.Delete(mockTxContext, "", testData)
 	require.NoError(t, err, "Delete operation should succeed")
 
 	// Update the filter state in the ledger
@@ -1698,13 +1953,16 @@ func TestCredentialVerificationAndRevocation(t *testing.T) {
 
 // Batch processing
 func TestBatchCredentialRevocationVerificationAndQuery(t *testing.T) {
+	useStakeholderDir(t)
 	stakeholderContract := new(stakeholder.StakeholderManagementContract)
 	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
 	mockTxContext := new(mocks.MockTransactionContext)
 	mockTxContext.On("GetStub").Return(mockStub)
 	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
 
-	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize)
+	filter := cuckoofilter.NewFilter(1000, cuckoofilter.DefaultBucketSize, cuckoofilter.FingerPrintSize)
 	filterJSON, _ := json.Marshal(filter)
 	mockStub.On("GetState", "CuckooFilterState").Return(filterJSON, nil)
 	mockStub.On("PutState", "CuckooFilterState", mock.Anything).Return(nil)
@@ -1724,7 +1982,7 @@ func TestBatchCredentialRevocationVerificationAndQuery(t *testing.T) {
 	// Print fingerprints type:
 	fmt.Printf("Fingerprints type: %T\n", fingerprints)
 
-	errI := smartContract.BatchInsert(mockTxContext, fingerprints)
+	_, errI := smartContract.BatchInsert(mockTxContext, "", fingerprints)
 	require.NoError(t, errI, "Batch insert should not fail")
 
 	err = smartContract.SaveFilterState(mockTxContext, filter)
@@ -1743,13 +2001,13 @@ func TestBatchCredentialRevocationVerificationAndQuery(t *testing.T) {
 	}
 
 	// lookup inserted fingerprints
-	_, err = smartContract.BatchLookup(mockTxContext, fingerprints)
+	_, err = smartContract.BatchLookup(mockTxContext, "", fingerprints)
 	require.NoError(t, err)
 
 	// delete fingerprints batchwise from the filter
-	err = smartContract.BatchDelete(mockTxContext, fingerprints)
+	_, err = smartContract.BatchDelete(mockTxContext, "", fingerprints, true)
 	require.NoError(t, err)
 
-	results, _ := smartContract.BatchLookup(mockTxContext, fingerprints)
+	results, _ := smartContract.BatchLookup(mockTxContext, "", fingerprints)
 	require.False(t, results[fingerprints[0]], "Fingerprint should not be found")
 }
@@ -0,0 +1,335 @@
+package cuckoofilter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"sort"
+	"strconv"
+)
+
+// filterDeltaObjectType is the composite key prefix of the filter bucket deltas, filterDelta~<bucket index>.
+// With delta persistence enabled, a transaction writes only the buckets it changed instead of the whole
+// filter state, and Compact folds the deltas into the base filter state. The stored hash of the base
+// state is chained through the deltas under stateHash~filterDelta, see filterDeltas.chainHash.
+const filterDeltaObjectType = "filterDelta"
+
+// SetDeltaPersistence switches between writing the whole filter state on every change and writing
+// per-bucket deltas. Disabling it compacts the outstanding deltas first. Registry admins only.
+func (s *SmartContract) SetDeltaPersistence(ctx contractapi.TransactionContextInterface, enabled bool) (*RegistryConfig, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	if err := checkRegistryAdmin(ctx, "change delta persistence"); err != nil {
+		return nil, err
+	}
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if config.DeltaPersistence == enabled {
+		return config, nil
+	}
+	if !enabled {
+		if _, err := s.Compact(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	config.Version++
+	config.DeltaPersistence = enabled
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if err := saveRegistryConfig(ctx, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Compact folds the bucket deltas into the base filter state and removes them.
+// It returns the number of folded deltas. Registry admins only.
+func (s *SmartContract) Compact(ctx contractapi.TransactionContextInterface) (int, error) {
+	if err := checkWritable(ctx); err != nil {
+		return 0, err
+	}
+	if err := checkRegistryAdmin(ctx, "compact the filter state"); err != nil {
+		return 0, err
+	}
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if config.PrivateCollection != "" {
+		// Deltas are never written in private collection mode, see RegistryConfig.Validate
+		return 0, nil
+	}
+	filter, err := loadFilterBase(ctx, "")
+	if err != nil {
+		return 0, fmt.Errorf("error loading filter state: %v", err)
+	}
+	deltas, err := loadFilterDeltas(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(deltas.stored) == 0 {
+		return 0, nil
+	}
+	if err := applyFilterDeltas(filter, deltas.data); err != nil {
+		return 0, err
+	}
+	if err := saveFilterBase(ctx, filter, deltas.keys()); err != nil {
+		return 0, err
+	}
+	return len(deltas.stored), nil
+}
+
+// filterDeltas are the outstanding bucket deltas of the default filter as read from the ledger
+type filterDeltas struct {
+	baseHash []byte                 // Stored hash of the base filter state the deltas apply to
+	data     map[uint][]fingerprint // Bucket contents by bucket index
+	stored   map[string][]byte      // Delta JSON by key, as hashed into the chain hash
+	buckets  []*bucket              // Buckets with the deltas applied, to find the buckets a save changed
+}
+
+// keys returns the delta keys in ledger order
+func (d *filterDeltas) keys() []string {
+	keys := make([]string, 0, len(d.stored))
+	for key := range d.stored {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// chainHash chains the stored hash of the base filter state through the deltas in key order, so a
+// changed or dropped delta, or deltas replayed on another base state, no longer match the stored hash
+func (d *filterDeltas) chainHash() []byte {
+	hash := d.baseHash
+	for _, key := range d.keys() {
+		h := sha256.New()
+		h.Write(hash)
+		h.Write([]byte(key))
+		h.Write(d.stored[key])
+		hash = h.Sum(nil)
+	}
+	return hash
+}
+
+// saveFilterDeltas writes the buckets of filter that changed since it was loaded as deltas, followed by
+// the chain hash over the base state and all deltas. Neither the base state nor the other deltas are
+// read again. The whole state is written instead for a filter not loaded with its deltas, e.g. a new or
+// resized one. Deltas and the chain hash get the key-level endorsement policy of the filter state; note
+// that Fabric validates the first write of a delta key against the chaincode endorsement policy, as the
+// key has no policy yet.
+func saveFilterDeltas(ctx contractapi.TransactionContextInterface, filter *Filter) error {
+	deltas := filter.deltas
+	if deltas == nil || len(deltas.buckets) != len(filter.Buckets) {
+		keys, err := filterDeltaKeys(ctx)
+		if err != nil {
+			return err
+		}
+		return saveFilterBase(ctx, filter, keys)
+	}
+
+	policy, err := ctx.GetStub().GetStateValidationParameter(filterStateKey)
+	if err != nil {
+		return fmt.Errorf("failed to read validation parameter of filter state: %v", err)
+	}
+	changed := false
+	for i, b := range filter.Buckets {
+		if equalBuckets(deltas.buckets[i], b) {
+			continue
+		}
+		key, err := filterDeltaKey(ctx, uint(i))
+		if err != nil {
+			return err
+		}
+		deltaJSON, err := json.Marshal(b.Data)
+		if err != nil {
+			return err
+		}
+		if err := putFilterDeltaState(ctx, key, deltaJSON, policy); err != nil {
+			return fmt.Errorf("error saving filter delta: %v", err)
+		}
+		// A later save in the same transaction only writes what changed after this one
+		deltas.stored[key] = deltaJSON
+		deltas.buckets[i] = &bucket{Data: append([]fingerprint(nil), b.Data...), size: b.size}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	hashKey, err := stateHashKey(ctx, filterDeltaObjectType)
+	if err != nil {
+		return err
+	}
+	if err := putFilterDeltaState(ctx, hashKey, deltas.chainHash(), policy); err != nil {
+		return fmt.Errorf("error saving filter delta hash: %v", err)
+	}
+	return nil
+}
+
+// putFilterDeltaState writes a delta or the chain hash with the policy of the filter state, nil for none
+func putFilterDeltaState(ctx contractapi.TransactionContextInterface, key string, value []byte, policy []byte) error {
+	if err := ctx.GetStub().PutState(key, value); err != nil {
+		return err
+	}
+	if policy != nil {
+		if err := ctx.GetStub().SetStateValidationParameter(key, policy); err != nil {
+			return fmt.Errorf("failed to set validation parameter: %v", err)
+		}
+	}
+	return nil
+}
+
+// saveFilterBase writes the whole filter state with its Merkle root and removes the deltas with the given
+// keys, which it includes, and their chain hash. Between base writes the Merkle root of the default filter
+// is computed from the deltas, see filterMerkleRoot.
+func saveFilterBase(ctx contractapi.TransactionContextInterface, filter *Filter, deltaKeys []string) error {
+	filterJSON, err := json.Marshal(filter)
+	if err != nil {
+		return err
+	}
+	if err := putStateWithHash(ctx, filterStateKey, filterStateKey, filterJSON); err != nil {
+		return err
+	}
+	if err := putMerkleRoot(ctx, filterStateKey, filter); err != nil {
+		return err
+	}
+	for _, key := range deltaKeys {
+		if err := ctx.GetStub().DelState(key); err != nil {
+			return fmt.Errorf("error deleting filter delta: %v", err)
+		}
+	}
+	hashKey, err := stateHashKey(ctx, filterDeltaObjectType)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().DelState(hashKey); err != nil {
+		return fmt.Errorf("error deleting filter delta hash: %v", err)
+	}
+	return nil
+}
+
+// loadFilterDeltas returns the outstanding deltas, failing with ErrCorruptState when they do not match
+// the chain hash written with them. Deltas written before the chain hash was stored are not checked.
+func loadFilterDeltas(ctx contractapi.TransactionContextInterface) (*filterDeltas, error) {
+	baseHash, err := storedStateHash(ctx, "", filterStateKey)
+	if err != nil {
+		return nil, err
+	}
+	storedHash, err := storedStateHash(ctx, "", filterDeltaObjectType)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(filterDeltaObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("error reading filter deltas: %v", err)
+	}
+	defer iterator.Close()
+
+	deltas := &filterDeltas{baseHash: baseHash, data: make(map[uint][]fingerprint), stored: make(map[string][]byte)}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error reading filter deltas: %v", err)
+		}
+		index, err := filterDeltaIndex(ctx, entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		var data []fingerprint
+		if err := json.Unmarshal(entry.Value, &data); err != nil {
+			return nil, fmt.Errorf("error decoding filter delta %d: %v", index, err)
+		}
+		deltas.data[index] = data
+		deltas.stored[entry.Key] = entry.Value
+	}
+	if storedHash != nil && !bytes.Equal(storedHash, deltas.chainHash()) {
+		return nil, fmt.Errorf("%w: filter deltas", ErrCorruptState)
+	}
+	return deltas, nil
+}
+
+// filterDeltaKeys returns the keys of the outstanding deltas without reading them
+func filterDeltaKeys(ctx contractapi.TransactionContextInterface) ([]string, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(filterDeltaObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("error reading filter deltas: %v", err)
+	}
+	defer iterator.Close()
+
+	var keys []string
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error reading filter deltas: %v", err)
+		}
+		keys = append(keys, entry.Key)
+	}
+	return keys, nil
+}
+
+// applyFilterDeltas replaces the buckets of the base filter with their deltas
+func applyFilterDeltas(filter *Filter, deltas map[uint][]fingerprint) error {
+	count := int(filter.Count)
+	for index, data := range deltas {
+		b := filter.bucketAt(index)
+		if b == nil {
+			return fmt.Errorf("%w: filter delta for missing bucket %d", ErrCorruptFilter, index)
+		}
+		count += storedInBucket(data) - storedInBucket(b.Data)
+		b.Data = data
+	}
+	if count < 0 {
+		count = 0
+	}
+	filter.Count = uint(count)
+	return filter.Validate()
+}
+
+func filterDeltaKey(ctx contractapi.TransactionContextInterface, index uint) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(filterDeltaObjectType, []string{strconv.FormatUint(uint64(index), 10)})
+	if err != nil {
+		return "", fmt.Errorf("error creating filter delta key: %v", err)
+	}
+	return key, nil
+}
+
+// filterDeltaIndex returns the bucket index of a delta key
+func filterDeltaIndex(ctx contractapi.TransactionContextInterface, key string) (uint, error) {
+	_, attributes, err := ctx.GetStub().SplitCompositeKey(key)
+	if err != nil {
+		return 0, err
+	}
+	index, err := strconv.ParseUint(attributes[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: filter delta key %q", ErrCorruptFilter, key)
+	}
+	return uint(index), nil
+}
+
+func storedInBucket(data []fingerprint) int {
+	stored := 0
+	for _, fp := range data {
+		if len(fp) != 0 {
+			stored++
+		}
+	}
+	return stored
+}
+
+func equalBuckets(a *bucket, b *bucket) bool {
+	if len(a.Data) != len(b.Data) {
+		return false
+	}
+	for i := range a.Data {
+		if !equalFingerprints(a.Data[i], b.Data[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,158 @@
+package cuckoofilter_test
+
+import (
+	"fmt"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// deltaKeys returns the keys of the bucket deltas on the ledger of sim
+func deltaKeys(sim *simulator.Simulator) []string {
+	var keys []string
+	for index := 0; index < 1<<16; index++ {
+		key := fmt.Sprintf("\x00filterDelta\x00%d\x00", index)
+		if sim.GetState(key) != nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func TestDeltaPersistence(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	registryAdmin := newRegistryAdmin(t)
+	_, err := sim.Submit(admin, "Insert", "", "credential-0")
+	require.NoError(t, err)
+	_, err = sim.Submit(registryAdmin, "SetDeltaPersistence", "true")
+	require.NoError(t, err)
+	base := sim.GetState("CuckooFilterState")
+
+	for i := 1; i < 20; i++ {
+		_, err = sim.Submit(admin, "Insert", "", fmt.Sprintf("credential-%d", i))
+		require.NoError(t, err)
+	}
+	_, err = sim.Submit(admin, "Delete", "", "credential-0")
+	require.NoError(t, err)
+
+	// Changes went to bucket deltas, the base filter state was not rewritten
+	require.Equal(t, base, sim.GetState("CuckooFilterState"))
+	requireStatus(t, sim, admin, "credential-0", cuckoofilter.CredentialStatusActive)
+	for i := 1; i < 20; i++ {
+		requireStatus(t, sim, admin, fmt.Sprintf("credential-%d", i), cuckoofilter.CredentialStatusRevoked)
+	}
+
+	folded, err := sim.Submit(registryAdmin, "Compact")
+	require.NoError(t, err)
+	require.NotEqual(t, "0", string(folded.Payload))
+	require.NotEqual(t, base, sim.GetState("CuckooFilterState"))
+	folded, err = sim.Submit(registryAdmin, "Compact")
+	require.NoError(t, err)
+	require.Equal(t, "0", string(folded.Payload))
+
+	requireStatus(t, sim, admin, "credential-0", cuckoofilter.CredentialStatusActive)
+	for i := 1; i < 20; i++ {
+		requireStatus(t, sim, admin, fmt.Sprintf("credential-%d", i), cuckoofilter.CredentialStatusRevoked)
+	}
+}
+
+func TestDeltaPersistence_DisablingCompacts(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	registryAdmin := newRegistryAdmin(t)
+	_, err := sim.Submit(registryAdmin, "SetDeltaPersistence", "true")
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "Insert", "", "credential-1")
+	require.NoError(t, err)
+
+	_, err = sim.Submit(registryAdmin, "SetDeltaPersistence", "false")
+	require.NoError(t, err)
+	requireStatus(t, sim, admin, "credential-1", cuckoofilter.CredentialStatusRevoked)
+
+	// The base filter state holds the revocation once deltas are no longer read
+	_, err = sim.Submit(admin, "Insert", "", "credential-2")
+	require.NoError(t, err)
+	requireStatus(t, sim, admin, "credential-1", cuckoofilter.CredentialStatusRevoked)
+	requireStatus(t, sim, admin, "credential-2", cuckoofilter.CredentialStatusRevoked)
+}
+
+func TestDeltaPersistence_Resize(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	registryAdmin := newRegistryAdmin(t)
+	_, err := sim.Submit(registryAdmin, "SetDeltaPersistence", "true")
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "Insert", "", "credential-1")
+	require.NoError(t, err)
+
+	// A resize rewrites the whole filter state and drops the deltas it folded in
+	_, err = sim.Submit(registryAdmin, "ResizeFilter", "1024")
+	require.NoError(t, err)
+	requireStatus(t, sim, admin, "credential-1", cuckoofilter.CredentialStatusRevoked)
+	folded, err := sim.Submit(registryAdmin, "Compact")
+	require.NoError(t, err)
+	require.Equal(t, "0", string(folded.Payload))
+}
+
+func TestDeltaPersistence_AdminsOnly(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	_, err := sim.Submit(admin, "SetDeltaPersistence", "true")
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+	_, err = sim.Submit(admin, "Compact")
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+}
+
+func TestDeltaPersistence_TamperedDeltaFails(t *testing.T) {
+	for name, tamper := range map[string]func(sim *simulator.Simulator, key string) error{
+		"changed": func(sim *simulator.Simulator, key string) error { return sim.SetState(key, []byte(`[]`)) },
+		"dropped": func(sim *simulator.Simulator, key string) error { return sim.SetState(key, nil) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			sim, admin := newRegistrySimulator(t)
+			registryAdmin := newRegistryAdmin(t)
+			_, err := sim.Submit(registryAdmin, "SetDeltaPersistence", "true")
+			require.NoError(t, err)
+			_, err = sim.Submit(admin, "Insert", "", "credential-1")
+			require.NoError(t, err)
+
+			keys := deltaKeys(sim)
+			require.NotEmpty(t, keys)
+			require.NoError(t, tamper(sim, keys[0]))
+			_, err = sim.Evaluate(admin, "Lookup", "", "credential-1")
+			require.ErrorContains(t, err, cuckoofilter.ErrCorruptState.Error())
+			_, err = sim.Submit(registryAdmin, "Compact")
+			require.ErrorContains(t, err, cuckoofilter.ErrCorruptState.Error())
+		})
+	}
+}
+
+func TestDeltaPersistence_MerkleRoot(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	registryAdmin := newRegistryAdmin(t)
+	_, err := sim.Submit(registryAdmin, "SetDeltaPersistence", "true")
+	require.NoError(t, err)
+	committedKey := "\x00merkleRoot\x00CuckooFilterState\x00"
+	committed := sim.GetState(committedKey)
+	emptyRoot, err := sim.Evaluate(admin, "GetFilterMerkleRoot", "")
+	require.NoError(t, err)
+
+	// Delta writes leave the committed root alone, the root is computed over the base state and deltas
+	_, err = sim.Submit(admin, "Insert", "", "credential-1")
+	require.NoError(t, err)
+	require.Equal(t, committed, sim.GetState(committedKey))
+	root, err := sim.Evaluate(admin, "GetFilterMerkleRoot", "")
+	require.NoError(t, err)
+	require.NotEqual(t, emptyRoot, root)
+	proof := requireInclusionProof(t, sim, admin, "", "credential-1")
+	require.Equal(t, string(root), proof.Root)
+	revoked, err := proof.Verify([]byte("credential-1"))
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	// Compacting commits the root with the base state
+	_, err = sim.Submit(registryAdmin, "Compact")
+	require.NoError(t, err)
+	require.NotEqual(t, committed, sim.GetState(committedKey))
+	compacted, err := sim.Evaluate(admin, "GetFilterMerkleRoot", "")
+	require.NoError(t, err)
+	require.Equal(t, root, compacted)
+}
@@ -0,0 +1,124 @@
+package cuckoofilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"time"
+)
+
+// didStatusObjectType is the composite key prefix of the ledger status of a DID document
+const didStatusObjectType = "didStatus"
+
+// DIDDeactivatedEvent is the name of the chaincode event emitted when a DID is deactivated.
+// Its payload is the DIDStatus as JSON.
+const DIDDeactivatedEvent = "DIDDeactivated"
+
+var (
+	// ErrDIDDeactivated is returned when a credential is issued or verified with a deactivated issuer or subject
+	ErrDIDDeactivated = fmt.Errorf("%w: DID is deactivated", ErrFailedPrecondition)
+	// ErrNotDIDController is returned when a client deactivates a DID that is not its own without being a registry admin
+	ErrNotDIDController = fmt.Errorf("%w: only the DID itself or a registry admin may deactivate it", ErrUnauthorized)
+)
+
+// DIDStatus is the ledger status of a DID document. Deactivation is a tombstone: a deactivated DID
+// cannot be activated again, so credentials involving it stay rejected.
+type DIDStatus struct {
+	DID           string    `json:"did"`
+	Deactivated   bool      `json:"deactivated"`
+	DeactivatedAt time.Time `json:"deactivatedAt,omitempty"`
+	DeactivatedBy *Inserter `json:"deactivatedBy,omitempty" metadata:",optional"`
+	TxID          string    `json:"txId,omitempty" metadata:",optional"`
+}
+
+// DeactivateDID marks the DID document as deactivated. IssuingCredential and VerifyingCredential reject
+// credentials whose issuer or subject is deactivated. Only the DID itself, as the DID attribute of the
+// client certificate, or a registry admin may deactivate a DID.
+func (s *StakeholderManagementContract) DeactivateDID(ctx contractapi.TransactionContextInterface, did string) (*DIDStatus, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	if did == "" {
+		return nil, fmt.Errorf("DID must not be empty")
+	}
+	client, admin, err := clientInserter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !admin && client.DID != did {
+		return nil, ErrNotDIDController
+	}
+
+	status, err := loadDIDStatus(ctx, did)
+	if err != nil {
+		return nil, err
+	}
+	if status.Deactivated {
+		return nil, fmt.Errorf("%w: %s since %s", ErrDIDDeactivated, did, status.DeactivatedAt.Format(time.RFC3339))
+	}
+
+	deactivatedAt, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status = &DIDStatus{
+		DID:           did,
+		Deactivated:   true,
+		DeactivatedAt: deactivatedAt,
+		DeactivatedBy: client,
+		TxID:          ctx.GetStub().GetTxID(),
+	}
+	statusJSON, err := json.Marshal(status)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(didStatusObjectType, []string{did})
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.GetStub().PutState(key, statusJSON); err != nil {
+		return nil, fmt.Errorf("error saving DID status: %v", err)
+	}
+	if err := ctx.GetStub().SetEvent(DIDDeactivatedEvent, statusJSON); err != nil {
+		return nil, fmt.Errorf("error emitting %s event: %v", DIDDeactivatedEvent, err)
+	}
+	return status, nil
+}
+
+// GetDIDStatus returns the ledger status of a DID, DIDs that were never deactivated are active
+func (s *StakeholderManagementContract) GetDIDStatus(ctx contractapi.TransactionContextInterface, did string) (*DIDStatus, error) {
+	return loadDIDStatus(ctx, did)
+}
+
+func loadDIDStatus(ctx contractapi.TransactionContextInterface, did string) (*DIDStatus, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(didStatusObjectType, []string{did})
+	if err != nil {
+		return nil, err
+	}
+	statusJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("error loading DID status: %v", err)
+	}
+	if statusJSON == nil {
+		return &DIDStatus{DID: did}, nil
+	}
+	var status DIDStatus
+	if err := json.Unmarshal(statusJSON, &status); err != nil {
+		return nil, fmt.Errorf("error decoding DID status: %v", err)
+	}
+	return &status, nil
+}
+
+// checkDIDsActive fails with ErrDIDDeactivated if any of the DIDs is deactivated
+func checkDIDsActive(ctx contractapi.TransactionContextInterface, dids ...string) error {
+	for _, did := range dids {
+		status, err := loadDIDStatus(ctx, did)
+		if err != nil {
+			return err
+		}
+		if status.Deactivated {
+			return fmt.Errorf("%w: %s", ErrDIDDeactivated, did)
+		}
+	}
+	return nil
+}
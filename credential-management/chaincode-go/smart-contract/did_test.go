@@ -0,0 +1,107 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+// newDIDSimulator deploys the stakeholder contract and returns an issuer and a holder DID
+func newDIDSimulator(t *testing.T) (*simulator.Simulator, *simulator.Identity, *cuckoofilter.DIDResponse, *cuckoofilter.DIDResponse) {
+	sim, err := simulator.New("credential-management", &cuckoofilter.SmartContract{}, &cuckoofilter.StakeholderManagementContract{Clock: clock.System}, &cuckoofilter.TrustRegistryContract{})
+	require.NoError(t, err)
+	admin, err := simulator.NewIdentityWithAttributes("RegistryMSP", "registry-admin", map[string]string{
+		cuckoofilter.DIDAttribute:           "did:key:admin",
+		cuckoofilter.RegistryAdminAttribute: "true",
+	})
+	require.NoError(t, err)
+
+	var dids []*cuckoofilter.DIDResponse
+	for _, role := range []string{"issuer", "holder"} {
+		tx, err := sim.Submit(admin, "StakeholderManagementContract:GenerateDID", role)
+		require.NoError(t, err)
+		var did cuckoofilter.DIDResponse
+		require.NoError(t, json.Unmarshal(tx.Payload, &did))
+		dids = append(dids, &did)
+	}
+	_, err = sim.Submit(admin, "TrustRegistryContract:AddTrustedIssuer", dids[0].DID)
+	require.NoError(t, err)
+	return sim, admin, dids[0], dids[1]
+}
+
+func TestDeactivateDID_Issuer(t *testing.T) {
+	useStakeholderDir(t)
+	sim, _, issuer, holder := newDIDSimulator(t)
+	_, err := sim.Submit(newIssuerIdentity(t, "Org1MSP", issuer.DID), "StakeholderManagementContract:IssuingCredential", issuer.DID, holder.DID)
+	require.NoError(t, err)
+	issued, err := os.ReadFile("./holderCredentials/" + holder.DID + ".jwt")
+	require.NoError(t, err)
+
+	// The issuer deactivates its own DID
+	tx, err := sim.Submit(newIssuerIdentity(t, "Org1MSP", issuer.DID), "StakeholderManagementContract:DeactivateDID", issuer.DID)
+	require.NoError(t, err)
+	var status cuckoofilter.DIDStatus
+	require.NoError(t, json.Unmarshal(tx.Payload, &status))
+	require.True(t, status.Deactivated)
+	require.Equal(t, tx.Timestamp, status.DeactivatedAt)
+	require.Equal(t, issuer.DID, status.DeactivatedBy.DID)
+	require.Equal(t, tx.ID, status.TxID)
+
+	require.NotNil(t, tx.Event)
+	require.Equal(t, cuckoofilter.DIDDeactivatedEvent, tx.Event.EventName)
+	var event cuckoofilter.DIDStatus
+	require.NoError(t, json.Unmarshal(tx.Event.Payload, &event))
+	require.Equal(t, status, event)
+
+	// Credentials of the deactivated issuer are rejected, already issued ones as well
+	_, err = sim.Submit(newIssuerIdentity(t, "Org1MSP", issuer.DID), "StakeholderManagementContract:IssuingCredential", issuer.DID, holder.DID)
+	require.ErrorContains(t, err, cuckoofilter.ErrDIDDeactivated.Error())
+	_, err = sim.Evaluate(newIssuerIdentity(t, "Org2MSP", "did:key:verifier"), "StakeholderManagementContract:VerifyingCredential", string(issued), "verifier", holder.DID, issuer.DID)
+	require.ErrorContains(t, err, cuckoofilter.ErrDIDDeactivated.Error())
+
+	// Deactivation is a tombstone
+	_, err = sim.Submit(newIssuerIdentity(t, "Org1MSP", issuer.DID), "StakeholderManagementContract:DeactivateDID", issuer.DID)
+	require.ErrorContains(t, err, cuckoofilter.ErrDIDDeactivated.Error())
+
+	statusJSON, err := sim.Evaluate(newIssuerIdentity(t, "Org2MSP", "did:key:verifier"), "StakeholderManagementContract:GetDIDStatus", issuer.DID)
+	require.NoError(t, err)
+	var stored cuckoofilter.DIDStatus
+	require.NoError(t, json.Unmarshal(statusJSON, &stored))
+	require.Equal(t, status, stored)
+}
+
+func TestDeactivateDID_Subject(t *testing.T) {
+	useStakeholderDir(t)
+	sim, admin, issuer, holder := newDIDSimulator(t)
+	_, err := sim.Submit(newIssuerIdentity(t, "Org1MSP", issuer.DID), "StakeholderManagementContract:IssuingCredential", issuer.DID, holder.DID)
+	require.NoError(t, err)
+	issued, err := os.ReadFile("./holderCredentials/" + holder.DID + ".jwt")
+	require.NoError(t, err)
+
+	// A registry admin may deactivate any DID
+	_, err = sim.Submit(admin, "StakeholderManagementContract:DeactivateDID", holder.DID)
+	require.NoError(t, err)
+
+	_, err = sim.Submit(newIssuerIdentity(t, "Org1MSP", issuer.DID), "StakeholderManagementContract:IssuingCredential", issuer.DID, holder.DID)
+	require.ErrorContains(t, err, cuckoofilter.ErrDIDDeactivated.Error())
+	_, err = sim.Evaluate(admin, "StakeholderManagementContract:VerifyingCredential", string(issued), "verifier", holder.DID, issuer.DID)
+	require.ErrorContains(t, err, cuckoofilter.ErrDIDDeactivated.Error())
+}
+
+func TestDeactivateDID_OnlyByControllerOrAdmin(t *testing.T) {
+	useStakeholderDir(t)
+	sim, _, issuer, holder := newDIDSimulator(t)
+
+	_, err := sim.Submit(newIssuerIdentity(t, "Org1MSP", issuer.DID), "StakeholderManagementContract:DeactivateDID", holder.DID)
+	require.ErrorContains(t, err, cuckoofilter.ErrNotDIDController.Error())
+
+	statusJSON, err := sim.Evaluate(newIssuerIdentity(t, "Org1MSP", issuer.DID), "StakeholderManagementContract:GetDIDStatus", holder.DID)
+	require.NoError(t, err)
+	var status cuckoofilter.DIDStatus
+	require.NoError(t, json.Unmarshal(statusJSON, &status))
+	require.False(t, status.Deactivated)
+}
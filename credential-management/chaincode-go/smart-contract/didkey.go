@@ -0,0 +1,131 @@
+package cuckoofilter
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"time"
+)
+
+// didKeyObjectType is the composite key prefix of the public keys of DIDs, didKey~<did>
+const didKeyObjectType = "didKey"
+
+// ErrDIDKeyMismatch is returned when a public key is registered for or read back from a DID it does not belong to
+var ErrDIDKeyMismatch = fmt.Errorf("%w: public key does not belong to the DID", ErrInvalidArgument)
+
+// DIDKey is the public key of a DID on the ledger. Holder requests and issuer decisions are verified
+// against it, so they are bound to the DID they name.
+type DIDKey struct {
+	DID          string    `json:"did"`
+	KeyType      string    `json:"keyType"`
+	PublicKey    string    `json:"publicKey"` // Encoded as in the key files
+	RegisteredAt time.Time `json:"registeredAt"`
+	TxID         string    `json:"txId"`
+}
+
+// RegisterDIDKey records the public key of a did:key. The DID is derived from the key, so only the key
+// it encodes can be registered and no further authorization is needed. Registering the same key again
+// returns the existing record.
+func (s *SmartContract) RegisterDIDKey(ctx contractapi.TransactionContextInterface, did string, keyType string, publicKey string) (*DIDKey, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	return registerDIDKey(ctx, did, keyType, publicKey)
+}
+
+// GetDIDKey returns the registered public key of a DID
+func (s *SmartContract) GetDIDKey(ctx contractapi.TransactionContextInterface, did string) (*DIDKey, error) {
+	key, err := loadDIDKey(ctx, did)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, fmt.Errorf("%w: no public key registered for %s", ErrNotFound, did)
+	}
+	return key, nil
+}
+
+// registerDIDKey checks that the public key encodes the DID and records it
+func registerDIDKey(ctx contractapi.TransactionContextInterface, did string, keyType string, publicKey string) (*DIDKey, error) {
+	if _, err := decodeDIDPublicKey(did, keyType, publicKey); err != nil {
+		return nil, err
+	}
+	existing, err := loadDIDKey(ctx, did)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		if existing.KeyType != keyType || existing.PublicKey != publicKey {
+			return nil, fmt.Errorf("%w: a different public key is registered for %s", ErrConflict, did)
+		}
+		return existing, nil
+	}
+
+	registeredAt, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	record := &DIDKey{DID: did, KeyType: keyType, PublicKey: publicKey, RegisteredAt: registeredAt, TxID: ctx.GetStub().GetTxID()}
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(didKeyObjectType, []string{did})
+	if err != nil {
+		return nil, fmt.Errorf("error creating DID key: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, recordJSON); err != nil {
+		return nil, fmt.Errorf("error saving DID key: %v", err)
+	}
+	return record, nil
+}
+
+// loadDIDKey returns the registered public key of a DID, nil if there is none
+func loadDIDKey(ctx contractapi.TransactionContextInterface, did string) (*DIDKey, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(didKeyObjectType, []string{did})
+	if err != nil {
+		return nil, fmt.Errorf("error creating DID key: %v", err)
+	}
+	recordJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("error loading DID key: %v", err)
+	}
+	if recordJSON == nil {
+		return nil, nil
+	}
+	var record DIDKey
+	if err := json.Unmarshal(recordJSON, &record); err != nil {
+		return nil, fmt.Errorf("error decoding DID key: %v", err)
+	}
+	return &record, nil
+}
+
+// didPublicKey returns the registered public key of a DID, failing if none is registered or the
+// stored key does not encode the DID
+func didPublicKey(ctx contractapi.TransactionContextInterface, did string) (crypto.PublicKey, error) {
+	record, err := loadDIDKey(ctx, did)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, fmt.Errorf("%w: no public key registered for %s", ErrNotFound, did)
+	}
+	return decodeDIDPublicKey(did, record.KeyType, record.PublicKey)
+}
+
+// decodeDIDPublicKey decodes a public key and checks that it is the key of the did:key
+func decodeDIDPublicKey(did string, keyType string, publicKeyString string) (crypto.PublicKey, error) {
+	publicKey, err := decodePublicKey(keyType, publicKeyString)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	keyDID, err := didKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	if keyDID != did {
+		return nil, fmt.Errorf("%w: %s", ErrDIDKeyMismatch, did)
+	}
+	return publicKey, nil
+}
@@ -0,0 +1,278 @@
+package cuckoofilter
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"regexp"
+)
+
+// The V1 transactions take their arguments as one JSON request object instead of positional strings.
+// contractapi checks every request against the JSON schema of its DTO in the contract metadata before
+// the transaction runs, so unknown fields, missing required fields and wrong types are refused on entry.
+// A DTO version only ever gains optional fields; a change existing clients could not send unchanged
+// gets a new version, e.g. InsertRequestV2 with an InsertV2 transaction, next to the old one.
+
+// Limits of the request fields
+const (
+	MaxFingerprintLength = 1024
+	MaxReasonLength      = 256
+)
+
+// idempotencyObjectType is the composite key prefix of the recorded idempotency keys
+const idempotencyObjectType = "idempotencyKey"
+
+// ErrIdempotencyKeyReused is returned when an idempotency key is sent again with a different request
+var ErrIdempotencyKeyReused = fmt.Errorf("%w: idempotency key was already used for a different request", ErrConflict)
+
+// idempotencyKeyPattern restricts idempotency keys to what fits a composite key, e.g. a UUID
+var idempotencyKeyPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// InsertRequestV1 revokes a credential
+type InsertRequestV1 struct {
+	Fingerprint string `json:"fingerprint"`
+	Namespace   string `json:"namespace,omitempty" metadata:",optional"` // Issuer namespace, empty for the default filter
+	Reason      string `json:"reason,omitempty" metadata:",optional"`    // Recorded in the revocation history
+	// Makes retries safe: a request sent again with the same key returns the first response
+	IdempotencyKey string `json:"idempotencyKey,omitempty" metadata:",optional"`
+}
+
+// Validate checks the request fields
+func (r *InsertRequestV1) Validate() error {
+	if err := validateFingerprints(r.Fingerprint); err != nil {
+		return err
+	}
+	return validateRequestOptions(r.Reason, r.IdempotencyKey)
+}
+
+// BatchInsertRequestV1 revokes several credentials at once
+type BatchInsertRequestV1 struct {
+	Fingerprints   []string `json:"fingerprints"`
+	Namespace      string   `json:"namespace,omitempty" metadata:",optional"`
+	Reason         string   `json:"reason,omitempty" metadata:",optional"`
+	IdempotencyKey string   `json:"idempotencyKey,omitempty" metadata:",optional"`
+}
+
+// Validate checks the request fields
+func (r *BatchInsertRequestV1) Validate() error {
+	if len(r.Fingerprints) == 0 {
+		return errors.New("fingerprints must not be empty")
+	}
+	if err := validateFingerprints(r.Fingerprints...); err != nil {
+		return err
+	}
+	return validateRequestOptions(r.Reason, r.IdempotencyKey)
+}
+
+// InsertResponseV1 is the result of InsertV1 and BatchInsertV1
+type InsertResponseV1 struct {
+	TxID     string `json:"txId"`     // Transaction that revoked the credentials
+	Replayed bool   `json:"replayed"` // The idempotency key was seen before and nothing was written
+}
+
+// LookupRequestV1 checks the revocation status of credentials
+type LookupRequestV1 struct {
+	Fingerprints []string `json:"fingerprints"`
+	Namespace    string   `json:"namespace,omitempty" metadata:",optional"`
+}
+
+// Validate checks the request fields
+func (r *LookupRequestV1) Validate() error {
+	if len(r.Fingerprints) == 0 {
+		return errors.New("fingerprints must not be empty")
+	}
+	return validateFingerprints(r.Fingerprints...)
+}
+
+// LookupResponseV1 is the result of LookupV1
+type LookupResponseV1 struct {
+	Namespace string          `json:"namespace,omitempty" metadata:",optional"`
+	Revoked   map[string]bool `json:"revoked"`                              // Revocation status per fingerprint
+	Epoch     uint64          `json:"epoch,omitempty" metadata:",optional"` // Epoch of the default filter, see LookupWithEpoch
+}
+
+func validateFingerprints(fingerprints ...string) error {
+	for _, fingerprint := range fingerprints {
+		if fingerprint == "" {
+			return errors.New("fingerprint must not be empty")
+		}
+		if len(fingerprint) > MaxFingerprintLength {
+			return fmt.Errorf("fingerprint exceeds %d bytes", MaxFingerprintLength)
+		}
+	}
+	return nil
+}
+
+func validateRequestOptions(reason string, idempotencyKey string) error {
+	if len(reason) > MaxReasonLength {
+		return fmt.Errorf("reason exceeds %d bytes", MaxReasonLength)
+	}
+	if idempotencyKey != "" && !idempotencyKeyPattern.MatchString(idempotencyKey) {
+		return fmt.Errorf("idempotency key must match %s", idempotencyKeyPattern)
+	}
+	return nil
+}
+
+// InsertV1 revokes the credential of an InsertRequestV1
+func (s *SmartContract) InsertV1(ctx contractapi.TransactionContextInterface, request InsertRequestV1) (*InsertResponseV1, error) {
+	if err := request.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid insert request: %v", err)
+	}
+	return s.insertV1(ctx, &request, request.IdempotencyKey, request.Namespace, request.Reason, []string{request.Fingerprint})
+}
+
+// BatchInsertV1 revokes the credentials of a BatchInsertRequestV1 atomically
+func (s *SmartContract) BatchInsertV1(ctx contractapi.TransactionContextInterface, request BatchInsertRequestV1) (*InsertResponseV1, error) {
+	if err := request.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid batch insert request: %v", err)
+	}
+	return s.insertV1(ctx, &request, request.IdempotencyKey, request.Namespace, request.Reason, request.Fingerprints)
+}
+
+// LookupV1 returns the revocation status of the credentials of a LookupRequestV1
+func (s *SmartContract) LookupV1(ctx contractapi.TransactionContextInterface, request LookupRequestV1) (*LookupResponseV1, error) {
+	if err := request.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid lookup request: %v", err)
+	}
+	if err := checkBatchSize(ctx, len(request.Fingerprints)); err != nil {
+		return nil, err
+	}
+	response := &LookupResponseV1{Namespace: request.Namespace, Revoked: make(map[string]bool)}
+	if request.Namespace == "" {
+		revoked, err := s.BatchLookup(ctx, DefaultFilterID, request.Fingerprints)
+		if err != nil {
+			return nil, err
+		}
+		response.Revoked = revoked
+		if response.Epoch, err = currentEpoch(ctx, DefaultFilterID); err != nil {
+			return nil, err
+		}
+		return response, nil
+	}
+	for _, fingerprint := range request.Fingerprints {
+		revoked, err := s.LookupInNamespace(ctx, request.Namespace, fingerprint)
+		if err != nil {
+			return nil, err
+		}
+		response.Revoked[fingerprint] = revoked
+	}
+	return response, nil
+}
+
+// insertV1 inserts fingerprints into the default filter or an issuer namespace, once per idempotency key
+func (s *SmartContract) insertV1(ctx contractapi.TransactionContextInterface, request interface{}, idempotencyKey string, namespace string, reason string, fingerprints []string) (*InsertResponseV1, error) {
+	if idempotencyKey != "" {
+		response, err := replayIdempotencyKey(ctx, idempotencyKey, request)
+		if err != nil || response != nil {
+			return response, err
+		}
+	}
+
+	if namespace == "" {
+		if _, err := s.batchInsert(ctx, DefaultFilterID, reason, false, false, fingerprints); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := checkBatchSize(ctx, len(fingerprints)); err != nil {
+			return nil, err
+		}
+		if err := checkStrictMode(ctx, fingerprints...); err != nil {
+			return nil, err
+		}
+		for _, fingerprint := range fingerprints {
+			if err := s.InsertInNamespace(ctx, namespace, fingerprint); err != nil {
+				return nil, err
+			}
+		}
+		if err := recordRevocationAudit(ctx, LifecycleRevoked, reason, fingerprints...); err != nil {
+			return nil, err
+		}
+	}
+
+	response := &InsertResponseV1{TxID: ctx.GetStub().GetTxID()}
+	if idempotencyKey != "" {
+		if err := recordIdempotencyKey(ctx, idempotencyKey, request, response); err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
+}
+
+// idempotencyRecord is the response recorded for an idempotency key, with the hash of its request
+type idempotencyRecord struct {
+	RequestHash []byte            `json:"requestHash"`
+	Response    *InsertResponseV1 `json:"response"`
+}
+
+// idempotencyRecordKey scopes an idempotency key to the submitting client
+func idempotencyRecordKey(ctx contractapi.TransactionContextInterface, idempotencyKey string) (string, error) {
+	client, _, err := clientInserter(ctx)
+	if err != nil {
+		return "", err
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(idempotencyObjectType, []string{client.MSPID, client.DID, idempotencyKey})
+	if err != nil {
+		return "", fmt.Errorf("error creating idempotency key: %v", err)
+	}
+	return key, nil
+}
+
+func requestHash(request interface{}) ([]byte, error) {
+	requestJSON, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(requestJSON)
+	return hash[:], nil
+}
+
+// replayIdempotencyKey returns the recorded response of an idempotency key, nil if it was not used yet
+func replayIdempotencyKey(ctx contractapi.TransactionContextInterface, idempotencyKey string, request interface{}) (*InsertResponseV1, error) {
+	key, err := idempotencyRecordKey(ctx, idempotencyKey)
+	if err != nil {
+		return nil, err
+	}
+	recordJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("error loading idempotency key: %v", err)
+	}
+	if recordJSON == nil {
+		return nil, nil
+	}
+	var record idempotencyRecord
+	if err := json.Unmarshal(recordJSON, &record); err != nil {
+		return nil, fmt.Errorf("error decoding idempotency key: %v", err)
+	}
+	hash, err := requestHash(request)
+	if err != nil {
+		return nil, err
+	}
+	if string(hash) != string(record.RequestHash) {
+		return nil, ErrIdempotencyKeyReused
+	}
+	response := *record.Response
+	response.Replayed = true
+	return &response, nil
+}
+
+// recordIdempotencyKey records the response of the request sent with an idempotency key
+func recordIdempotencyKey(ctx contractapi.TransactionContextInterface, idempotencyKey string, request interface{}, response *InsertResponseV1) error {
+	key, err := idempotencyRecordKey(ctx, idempotencyKey)
+	if err != nil {
+		return err
+	}
+	hash, err := requestHash(request)
+	if err != nil {
+		return err
+	}
+	recordJSON, err := json.Marshal(&idempotencyRecord{RequestHash: hash, Response: response})
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(key, recordJSON); err != nil {
+		return fmt.Errorf("error saving idempotency key: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func submitInsertV1(t *testing.T, sim *simulator.Simulator, identity *simulator.Identity, function string, request interface{}) (*cuckoofilter.InsertResponseV1, error) {
+	requestJSON, err := json.Marshal(request)
+	require.NoError(t, err)
+	tx, err := sim.Submit(identity, function, string(requestJSON))
+	if err != nil {
+		return nil, err
+	}
+	var response cuckoofilter.InsertResponseV1
+	require.NoError(t, json.Unmarshal(tx.Payload, &response))
+	require.Equal(t, tx.ID, response.TxID)
+	return &response, nil
+}
+
+func TestInsertV1(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	_, err := submitInsertV1(t, sim, admin, "InsertV1", cuckoofilter.InsertRequestV1{Fingerprint: "credential-1", Reason: "key compromise"})
+	require.NoError(t, err)
+	_, err = submitInsertV1(t, sim, admin, "BatchInsertV1", cuckoofilter.BatchInsertRequestV1{Fingerprints: []string{"credential-2", "credential-3"}})
+	require.NoError(t, err)
+
+	history, err := sim.Evaluate(admin, "GetRevocationHistory", "credential-1")
+	require.NoError(t, err)
+	var entries []*cuckoofilter.RevocationAuditEntry
+	require.NoError(t, json.Unmarshal(history, &entries))
+	require.Len(t, entries, 1)
+	require.Equal(t, "key compromise", entries[0].Reason)
+
+	result, err := sim.Evaluate(admin, "LookupV1", `{"fingerprints":["credential-1","credential-3","credential-4"]}`)
+	require.NoError(t, err)
+	var lookup cuckoofilter.LookupResponseV1
+	require.NoError(t, json.Unmarshal(result, &lookup))
+	require.Equal(t, map[string]bool{"credential-1": true, "credential-3": true, "credential-4": false}, lookup.Revoked)
+}
+
+func TestInsertV1_Namespace(t *testing.T) {
+	sim, admin := newShardSimulator(t, 2)
+	_, err := submitInsertV1(t, sim, admin, "InsertV1", cuckoofilter.InsertRequestV1{Fingerprint: "credential-1", Namespace: "did:web:issuer.example"})
+	require.NoError(t, err)
+
+	result, err := sim.Evaluate(admin, "LookupV1", `{"fingerprints":["credential-1","credential-2"],"namespace":"did:web:issuer.example"}`)
+	require.NoError(t, err)
+	var lookup cuckoofilter.LookupResponseV1
+	require.NoError(t, json.Unmarshal(result, &lookup))
+	require.Equal(t, "did:web:issuer.example", lookup.Namespace)
+	require.Equal(t, map[string]bool{"credential-1": true, "credential-2": false}, lookup.Revoked)
+}
+
+func TestInsertV1_RejectsInvalidRequests(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	for _, request := range []string{
+		`{"fingerprint":"credential-1","issuer":"did:web:issuer.example"}`, // Unknown field
+		`{"reason":"key compromise"}`,                                      // Missing fingerprint
+		`{"fingerprint":42}`,                                               // Wrong type
+		`{"fingerprint":""}`,
+		`{"fingerprint":"credential-1","idempotencyKey":"not a key"}`,
+		`{"fingerprint":"credential-1","reason":"` + strings.Repeat("x", cuckoofilter.MaxReasonLength+1) + `"}`,
+		`credential-1`,
+	} {
+		_, err := sim.Submit(admin, "InsertV1", request)
+		require.Error(t, err, request)
+	}
+	_, err := sim.Submit(admin, "BatchInsertV1", `{"fingerprints":[]}`)
+	require.Error(t, err)
+
+	revoked, err := sim.Evaluate(admin, "Lookup", "", "credential-1")
+	require.NoError(t, err)
+	require.Equal(t, "false", string(revoked))
+}
+
+func TestInsertV1_IdempotencyKey(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	request := cuckoofilter.InsertRequestV1{Fingerprint: "credential-1", IdempotencyKey: "7d1c2f0e-retry"}
+	first, err := submitInsertV1(t, sim, admin, "InsertV1", request)
+	require.NoError(t, err)
+	require.False(t, first.Replayed)
+
+	// A retry returns the first response without inserting again
+	tx, err := sim.Submit(admin, "InsertV1", `{"fingerprint":"credential-1","idempotencyKey":"7d1c2f0e-retry"}`)
+	require.NoError(t, err)
+	var retried cuckoofilter.InsertResponseV1
+	require.NoError(t, json.Unmarshal(tx.Payload, &retried))
+	require.True(t, retried.Replayed)
+	require.Equal(t, first.TxID, retried.TxID)
+	require.Nil(t, tx.Event)
+
+	// The key cannot be reused for another request
+	_, err = submitInsertV1(t, sim, admin, "InsertV1", cuckoofilter.InsertRequestV1{Fingerprint: "credential-2", IdempotencyKey: "7d1c2f0e-retry"})
+	require.ErrorContains(t, err, cuckoofilter.ErrIdempotencyKeyReused.Error())
+
+	// Keys are scoped to the client
+	other, err := simulator.NewIdentity("Org2MSP", "registry-admin")
+	require.NoError(t, err)
+	_, err = submitInsertV1(t, sim, other, "InsertV1", cuckoofilter.InsertRequestV1{Fingerprint: "credential-2", IdempotencyKey: "7d1c2f0e-retry"})
+	require.NoError(t, err)
+}
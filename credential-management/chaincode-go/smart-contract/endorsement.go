@@ -0,0 +1,324 @@
+package cuckoofilter
+
+import (
+	"fmt"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"sort"
+)
+
+// endorsementKey is a key carrying a key-level endorsement policy
+type endorsementKey struct {
+	collection string // Private data collection of the key, empty for the public state
+	key        string
+}
+
+// endorsementTarget names the keys carrying the key-level endorsement policy of a filter or namespace
+type endorsementTarget struct {
+	name   string           // Used in errors
+	policy endorsementKey   // Key the current policy is read from
+	keys   []endorsementKey // Keys the policy is set on
+}
+
+// SetFilterEndorsementPolicy sets a key-level endorsement policy on the state of a filter so that
+// every later write must be endorsed by the registry operator's org and all listed issuer orgs.
+// The default filter is addressed with an empty filter ID, its policy is set on every key of the
+// state layout or on the filter in the private data collection.
+// Fabric validates the change itself against the policy currently set on the key. Registry admins only.
+func (s *SmartContract) SetFilterEndorsementPolicy(ctx contractapi.TransactionContextInterface, filterID string, registryOrg string, issuerOrgs []string) error {
+	if registryOrg == "" {
+		return fmt.Errorf("registry org must not be empty")
+	}
+	if err := checkEndorsementAdmin(ctx); err != nil {
+		return err
+	}
+	target, err := filterEndorsementTarget(ctx, filterID)
+	if err != nil {
+		return err
+	}
+	return setEndorsementPolicy(ctx, target, registryOrg, issuerOrgs)
+}
+
+// AddFilterEndorser adds an org to the endorsement policy of a filter,
+// e.g. when a new issuer joins the registry. Registry admins only.
+func (s *SmartContract) AddFilterEndorser(ctx contractapi.TransactionContextInterface, filterID string, org string) error {
+	if err := checkEndorsementAdmin(ctx); err != nil {
+		return err
+	}
+	target, err := filterEndorsementTarget(ctx, filterID)
+	if err != nil {
+		return err
+	}
+	return addEndorser(ctx, target, org)
+}
+
+// RemoveFilterEndorser removes an org from the endorsement policy of a filter,
+// e.g. when an issuer leaves the registry. The last remaining org cannot be removed. Registry admins only.
+func (s *SmartContract) RemoveFilterEndorser(ctx contractapi.TransactionContextInterface, filterID string, org string) error {
+	if err := checkEndorsementAdmin(ctx); err != nil {
+		return err
+	}
+	target, err := filterEndorsementTarget(ctx, filterID)
+	if err != nil {
+		return err
+	}
+	return removeEndorser(ctx, target, org)
+}
+
+// GetFilterEndorsers returns the orgs whose peers must endorse writes to a filter.
+// An empty result means no key-level policy is set and the chaincode policy applies.
+func (s *SmartContract) GetFilterEndorsers(ctx contractapi.TransactionContextInterface, filterID string) ([]string, error) {
+	target, err := filterEndorsementTarget(ctx, filterID)
+	if err != nil {
+		return nil, err
+	}
+	return endorsers(ctx, target)
+}
+
+// SetNamespaceEndorsementPolicy sets a key-level endorsement policy on the assignment of an issuer
+// namespace. Inserts and deletes in the namespace write its assignment, so they must be endorsed by the
+// registry operator's org and all listed issuer orgs. The shard filters are shared by many namespaces
+// and keep their own policy. Registry admins only.
+func (s *SmartContract) SetNamespaceEndorsementPolicy(ctx contractapi.TransactionContextInterface, namespace string, registryOrg string, issuerOrgs []string) error {
+	if registryOrg == "" {
+		return fmt.Errorf("registry org must not be empty")
+	}
+	if err := checkEndorsementAdmin(ctx); err != nil {
+		return err
+	}
+	target, err := namespaceEndorsementTarget(ctx, namespace, true)
+	if err != nil {
+		return err
+	}
+	return setEndorsementPolicy(ctx, target, registryOrg, issuerOrgs)
+}
+
+// AddNamespaceEndorser adds an org to the endorsement policy of an issuer namespace. Registry admins only.
+func (s *SmartContract) AddNamespaceEndorser(ctx contractapi.TransactionContextInterface, namespace string, org string) error {
+	if err := checkEndorsementAdmin(ctx); err != nil {
+		return err
+	}
+	target, err := namespaceEndorsementTarget(ctx, namespace, true)
+	if err != nil {
+		return err
+	}
+	return addEndorser(ctx, target, org)
+}
+
+// RemoveNamespaceEndorser removes an org from the endorsement policy of an issuer namespace.
+// The last remaining org cannot be removed. Registry admins only.
+func (s *SmartContract) RemoveNamespaceEndorser(ctx contractapi.TransactionContextInterface, namespace string, org string) error {
+	if err := checkEndorsementAdmin(ctx); err != nil {
+		return err
+	}
+	target, err := namespaceEndorsementTarget(ctx, namespace, true)
+	if err != nil {
+		return err
+	}
+	return removeEndorser(ctx, target, org)
+}
+
+// GetNamespaceEndorsers returns the orgs whose peers must endorse writes to an issuer namespace.
+// An empty result means no key-level policy is set and the chaincode policy applies.
+func (s *SmartContract) GetNamespaceEndorsers(ctx contractapi.TransactionContextInterface, namespace string) ([]string, error) {
+	target, err := namespaceEndorsementTarget(ctx, namespace, false)
+	if err != nil {
+		return nil, err
+	}
+	return endorsers(ctx, target)
+}
+
+// checkEndorsementAdmin checks that the client may change endorsement policies
+func checkEndorsementAdmin(ctx contractapi.TransactionContextInterface) error {
+	if err := checkWritable(ctx); err != nil {
+		return err
+	}
+	return checkRegistryAdmin(ctx, "change the filter endorsement policy")
+}
+
+// filterEndorsementTarget returns the keys of the default filter in the state layout or private data
+// collection, or the key of a named filter. Every save also writes the state hash, Merkle root and epoch
+// of the filter, so they get the policy as well; otherwise a single org could rewrite the state hash
+// and fail every later load with ErrCorruptState. The epoch log gets a new key per write, which stays
+// under the chaincode policy.
+func filterEndorsementTarget(ctx contractapi.TransactionContextInterface, filterID string) (*endorsementTarget, error) {
+	if filterID != DefaultFilterID {
+		exists, err := filterExists(ctx, filterID)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("%w: filter '%s' not found", ErrNotFound, filterID)
+		}
+		key, err := namedFilterKey(ctx, filterID)
+		if err != nil {
+			return nil, err
+		}
+		target := &endorsementTarget{name: "filter " + filterID, policy: endorsementKey{key: key}}
+		if err := target.addState(ctx, "", key, namedFilterStateName(filterID)); err != nil {
+			return nil, err
+		}
+		return target, target.addFilterRecords(ctx, namedFilterStateName(filterID))
+	}
+
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	policyKey, err := filterPolicyKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	target := &endorsementTarget{name: "filter state", policy: endorsementKey{collection: config.PrivateCollection, key: policyKey}}
+	if config.StateLayout != StateLayoutSingle {
+		keys, err := shardedStateKeys(ctx, config.StateShards)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			target.keys = append(target.keys, endorsementKey{key: key})
+		}
+	}
+	if config.StateLayout != StateLayoutSharded {
+		if err := target.addState(ctx, config.PrivateCollection, filterStateKey, filterStateKey); err != nil {
+			return nil, err
+		}
+	}
+	return target, target.addFilterRecords(ctx, filterStateKey)
+}
+
+// addState adds a filter state key and the key of its state hash, both in the given collection
+func (t *endorsementTarget) addState(ctx contractapi.TransactionContextInterface, collection string, key string, name string) error {
+	hashKey, err := stateHashKey(ctx, name)
+	if err != nil {
+		return err
+	}
+	t.keys = append(t.keys, endorsementKey{collection, key}, endorsementKey{collection, hashKey})
+	return nil
+}
+
+// addFilterRecords adds the Merkle root and current epoch keys of a filter state, kept in the public state
+func (t *endorsementTarget) addFilterRecords(ctx contractapi.TransactionContextInterface, name string) error {
+	for _, objectType := range []string{merkleRootObjectType, filterEpochObjectType} {
+		key, err := ctx.GetStub().CreateCompositeKey(objectType, []string{name})
+		if err != nil {
+			return fmt.Errorf("error creating %s key: %v", objectType, err)
+		}
+		t.keys = append(t.keys, endorsementKey{key: key})
+	}
+	return nil
+}
+
+// namespaceEndorsementTarget returns the assignment key of an issuer namespace. With save the assignment
+// is stored, as the policy of a key that does not exist is dropped on commit.
+func namespaceEndorsementTarget(ctx contractapi.TransactionContextInterface, namespace string, save bool) (*endorsementTarget, error) {
+	config, err := loadShardConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	assignment, err := loadNamespaceAssignment(ctx, config, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if save {
+		if err := saveNamespaceAssignment(ctx, assignment); err != nil {
+			return nil, err
+		}
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(namespaceAssignmentObjectType, []string{namespace})
+	if err != nil {
+		return nil, fmt.Errorf("error creating namespace key: %v", err)
+	}
+	return &endorsementTarget{name: "namespace " + namespace, policy: endorsementKey{key: key}, keys: []endorsementKey{{key: key}}}, nil
+}
+
+// setEndorsementPolicy replaces the endorsement policy of a target with one of the registry org and issuer orgs
+func setEndorsementPolicy(ctx contractapi.TransactionContextInterface, target *endorsementTarget, registryOrg string, issuerOrgs []string) error {
+	endorsementPolicy, err := statebased.NewStateEP(nil)
+	if err != nil {
+		return err
+	}
+	orgs := append([]string{registryOrg}, issuerOrgs...)
+	err = endorsementPolicy.AddOrgs(statebased.RoleTypePeer, orgs...)
+	if err != nil {
+		return fmt.Errorf("failed to add orgs to endorsement policy: %v", err)
+	}
+	return setValidationParameter(ctx, target, endorsementPolicy)
+}
+
+func addEndorser(ctx contractapi.TransactionContextInterface, target *endorsementTarget, org string) error {
+	endorsementPolicy, err := loadEndorsementPolicy(ctx, target)
+	if err != nil {
+		return err
+	}
+	err = endorsementPolicy.AddOrgs(statebased.RoleTypePeer, org)
+	if err != nil {
+		return fmt.Errorf("failed to add org to endorsement policy: %v", err)
+	}
+	return setValidationParameter(ctx, target, endorsementPolicy)
+}
+
+func removeEndorser(ctx contractapi.TransactionContextInterface, target *endorsementTarget, org string) error {
+	endorsementPolicy, err := loadEndorsementPolicy(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	orgs := endorsementPolicy.ListOrgs()
+	found := false
+	for _, o := range orgs {
+		if o == org {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("org %s is not part of the endorsement policy of the %s", org, target.name)
+	}
+	if len(orgs) == 1 {
+		return fmt.Errorf("cannot remove %s, the endorsement policy of the %s needs at least one org", org, target.name)
+	}
+
+	endorsementPolicy.DelOrgs(org)
+
+	return setValidationParameter(ctx, target, endorsementPolicy)
+}
+
+func endorsers(ctx contractapi.TransactionContextInterface, target *endorsementTarget) ([]string, error) {
+	endorsementPolicy, err := loadEndorsementPolicy(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	orgs := endorsementPolicy.ListOrgs()
+	sort.Strings(orgs)
+	return orgs, nil
+}
+
+// loadEndorsementPolicy reads the current key-level endorsement policy of a target
+func loadEndorsementPolicy(ctx contractapi.TransactionContextInterface, target *endorsementTarget) (statebased.KeyEndorsementPolicy, error) {
+	policy, err := getCollectionValidationParameter(ctx, target.policy.collection, target.policy.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read validation parameter of %s: %v", target.name, err)
+	}
+
+	endorsementPolicy, err := statebased.NewStateEP(policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse validation parameter of %s: %v", target.name, err)
+	}
+	return endorsementPolicy, nil
+}
+
+// setValidationParameter stores the endorsement policy as validation parameter on every key of a target
+func setValidationParameter(ctx contractapi.TransactionContextInterface, target *endorsementTarget, endorsementPolicy statebased.KeyEndorsementPolicy) error {
+	policy, err := endorsementPolicy.Policy()
+	if err != nil {
+		return fmt.Errorf("failed to create endorsement policy bytes from orgs: %v", err)
+	}
+	for _, key := range target.keys {
+		if err := setCollectionValidationParameter(ctx, key.collection, key.key, policy); err != nil {
+			return fmt.Errorf("failed to set validation parameter on %s: %v", target.name, err)
+		}
+	}
+	return nil
+}
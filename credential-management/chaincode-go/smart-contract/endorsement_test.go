@@ -0,0 +1,281 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	"github.com/pherbke/credential-management/chaincode-go/mocks"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func policyForOrgs(t *testing.T, orgs ...string) []byte {
+	endorsementPolicy, err := statebased.NewStateEP(nil)
+	require.NoError(t, err)
+	require.NoError(t, endorsementPolicy.AddOrgs(statebased.RoleTypePeer, orgs...))
+	policy, err := endorsementPolicy.Policy()
+	require.NoError(t, err)
+	return policy
+}
+
+// requireFilterValidationParameters checks that the policy was set on the default filter state and its state
+// hash in the collection, empty for the public state, and on its Merkle root and epoch in the public state
+func requireFilterValidationParameters(t *testing.T, mockStub *mocks.MockChaincodeStubInterface, collection string, expected []byte) {
+	for _, key := range []string{"CuckooFilterState", stateHashKey} {
+		if collection == "" {
+			mockStub.AssertCalled(t, "SetStateValidationParameter", key, expected)
+		} else {
+			mockStub.AssertCalled(t, "SetPrivateDataValidationParameter", collection, key, expected)
+			mockStub.AssertNotCalled(t, "SetStateValidationParameter", key, mock.Anything)
+		}
+	}
+	for _, key := range []string{merkleRootKey, filterEpochKey} {
+		mockStub.AssertCalled(t, "SetStateValidationParameter", key, expected)
+	}
+}
+
+func TestSetFilterEndorsementPolicy(t *testing.T) {
+	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockTxContext := new(mocks.MockTransactionContext)
+	mockTxContext.On("GetStub").Return(mockStub)
+	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
+	mockRegistryDefaults(mockStub)
+
+	expected := policyForOrgs(t, "RegistryMSP", "Issuer1MSP")
+	mockStub.On("SetStateValidationParameter", mock.Anything, expected).Return(nil)
+
+	smartContract := new(cuckoofilter.SmartContract)
+	err := smartContract.SetFilterEndorsementPolicy(mockTxContext, cuckoofilter.DefaultFilterID, "RegistryMSP", []string{"Issuer1MSP"})
+	require.NoError(t, err)
+	requireFilterValidationParameters(t, mockStub, "", expected)
+}
+
+func TestSetFilterEndorsementPolicy_EmptyRegistryOrg(t *testing.T) {
+	mockTxContext := new(mocks.MockTransactionContext)
+
+	smartContract := new(cuckoofilter.SmartContract)
+	err := smartContract.SetFilterEndorsementPolicy(mockTxContext, cuckoofilter.DefaultFilterID, "", []string{"Issuer1MSP"})
+	require.Error(t, err)
+}
+
+func TestAddFilterEndorser(t *testing.T) {
+	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockTxContext := new(mocks.MockTransactionContext)
+	mockTxContext.On("GetStub").Return(mockStub)
+	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
+	mockRegistryDefaults(mockStub)
+
+	mockStub.On("GetStateValidationParameter", "CuckooFilterState").Return(policyForOrgs(t, "RegistryMSP"), nil)
+	expected := policyForOrgs(t, "RegistryMSP", "Issuer2MSP")
+	mockStub.On("SetStateValidationParameter", mock.Anything, expected).Return(nil)
+
+	smartContract := new(cuckoofilter.SmartContract)
+	err := smartContract.AddFilterEndorser(mockTxContext, cuckoofilter.DefaultFilterID, "Issuer2MSP")
+	require.NoError(t, err)
+	requireFilterValidationParameters(t, mockStub, "", expected)
+}
+
+func TestRemoveFilterEndorser(t *testing.T) {
+	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockTxContext := new(mocks.MockTransactionContext)
+	mockTxContext.On("GetStub").Return(mockStub)
+	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
+	mockRegistryDefaults(mockStub)
+
+	mockStub.On("GetStateValidationParameter", "CuckooFilterState").Return(policyForOrgs(t, "RegistryMSP", "Issuer1MSP"), nil)
+	expected := policyForOrgs(t, "RegistryMSP")
+	mockStub.On("SetStateValidationParameter", mock.Anything, expected).Return(nil)
+
+	smartContract := new(cuckoofilter.SmartContract)
+	err := smartContract.RemoveFilterEndorser(mockTxContext, cuckoofilter.DefaultFilterID, "Issuer1MSP")
+	require.NoError(t, err)
+	requireFilterValidationParameters(t, mockStub, "", expected)
+}
+
+func TestRemoveFilterEndorser_Failures(t *testing.T) {
+	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockTxContext := new(mocks.MockTransactionContext)
+	mockTxContext.On("GetStub").Return(mockStub)
+	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
+	mockRegistryDefaults(mockStub)
+
+	mockStub.On("GetStateValidationParameter", "CuckooFilterState").Return(policyForOrgs(t, "RegistryMSP"), nil)
+
+	smartContract := new(cuckoofilter.SmartContract)
+	require.Error(t, smartContract.RemoveFilterEndorser(mockTxContext, cuckoofilter.DefaultFilterID, "UnknownMSP"), "Removing an unknown org should fail")
+	require.Error(t, smartContract.RemoveFilterEndorser(mockTxContext, cuckoofilter.DefaultFilterID, "RegistryMSP"), "Removing the last org should fail")
+	mockStub.AssertNotCalled(t, "SetStateValidationParameter", mock.Anything, mock.Anything)
+}
+
+func TestFilterEndorsers_AdminsOnly(t *testing.T) {
+	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockTxContext := new(mocks.MockTransactionContext)
+	mockTxContext.On("GetStub").Return(mockStub)
+	mockTxContext.Stub = mockStub
+	mockTxContext.On("GetClientIdentity").Return(&mocks.ClientIdentity{ID: "x509::CN=issuer::CN=ca", MSPID: "Issuer1MSP"})
+	mockRegistryDefaults(mockStub)
+
+	smartContract := new(cuckoofilter.SmartContract)
+	require.ErrorIs(t, smartContract.SetFilterEndorsementPolicy(mockTxContext, cuckoofilter.DefaultFilterID, "Issuer1MSP", nil), cuckoofilter.ErrUnauthorized)
+	require.ErrorIs(t, smartContract.AddFilterEndorser(mockTxContext, cuckoofilter.DefaultFilterID, "Issuer1MSP"), cuckoofilter.ErrUnauthorized)
+	require.ErrorIs(t, smartContract.RemoveFilterEndorser(mockTxContext, cuckoofilter.DefaultFilterID, "RegistryMSP"), cuckoofilter.ErrUnauthorized)
+	mockStub.AssertNotCalled(t, "SetStateValidationParameter", mock.Anything, mock.Anything)
+}
+
+func TestGetFilterEndorsers(t *testing.T) {
+	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockTxContext := new(mocks.MockTransactionContext)
+	mockTxContext.On("GetStub").Return(mockStub)
+	mockTxContext.Stub = mockStub
+	mockRegistryDefaults(mockStub)
+
+	mockStub.On("GetStateValidationParameter", "CuckooFilterState").Return(policyForOrgs(t, "RegistryMSP", "Issuer2MSP", "Issuer1MSP"), nil)
+
+	smartContract := new(cuckoofilter.SmartContract)
+	orgs, err := smartContract.GetFilterEndorsers(mockTxContext, cuckoofilter.DefaultFilterID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Issuer1MSP", "Issuer2MSP", "RegistryMSP"}, orgs)
+}
+
+func TestGetFilterEndorsers_NoPolicy(t *testing.T) {
+	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockTxContext := new(mocks.MockTransactionContext)
+	mockTxContext.On("GetStub").Return(mockStub)
+	mockTxContext.Stub = mockStub
+	mockRegistryDefaults(mockStub)
+
+	mockStub.On("GetStateValidationParameter", "CuckooFilterState").Return(([]byte)(nil), nil)
+
+	smartContract := new(cuckoofilter.SmartContract)
+	orgs, err := smartContract.GetFilterEndorsers(mockTxContext, cuckoofilter.DefaultFilterID)
+	require.NoError(t, err)
+	require.Empty(t, orgs)
+}
+
+func TestGetFilterEndorsers_Failure(t *testing.T) {
+	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockTxContext := new(mocks.MockTransactionContext)
+	mockTxContext.On("GetStub").Return(mockStub)
+	mockTxContext.Stub = mockStub
+	mockRegistryDefaults(mockStub)
+
+	mockStub.On("GetStateValidationParameter", "CuckooFilterState").Return(([]byte)(nil), errors.New("ledger unavailable"))
+
+	smartContract := new(cuckoofilter.SmartContract)
+	_, err := smartContract.GetFilterEndorsers(mockTxContext, cuckoofilter.DefaultFilterID)
+	require.Error(t, err)
+}
+
+func requireEndorsers(t *testing.T, sim *simulator.Simulator, function string, target string, expected ...string) {
+	orgsJSON, err := sim.Evaluate(newRegistryAdmin(t), function, target)
+	require.NoError(t, err)
+	var orgs []string
+	require.NoError(t, json.Unmarshal(orgsJSON, &orgs))
+	require.ElementsMatch(t, expected, orgs)
+}
+
+func TestFilterEndorsementPolicy_NamedFilter(t *testing.T) {
+	sim, _ := newRegistrySimulator(t)
+	registryAdmin := newRegistryAdmin(t)
+	_, err := sim.Submit(registryAdmin, "Init", "tenant-a", "100", "4", "0")
+	require.NoError(t, err)
+
+	_, err = sim.Submit(registryAdmin, "SetFilterEndorsementPolicy", "tenant-a", "RegistryMSP", `["Issuer1MSP"]`)
+	require.NoError(t, err)
+	_, err = sim.Submit(registryAdmin, "AddFilterEndorser", "tenant-a", "Issuer2MSP")
+	require.NoError(t, err)
+	requireEndorsers(t, sim, "GetFilterEndorsers", "tenant-a", "RegistryMSP", "Issuer1MSP", "Issuer2MSP")
+	// The default filter keeps the chaincode policy
+	requireEndorsers(t, sim, "GetFilterEndorsers", cuckoofilter.DefaultFilterID)
+
+	_, err = sim.Submit(registryAdmin, "RemoveFilterEndorser", "tenant-a", "Issuer1MSP")
+	require.NoError(t, err)
+	requireEndorsers(t, sim, "GetFilterEndorsers", "tenant-a", "RegistryMSP", "Issuer2MSP")
+
+	// The state hash, Merkle root and epoch written with every save carry the policy too
+	policy := sim.GetValidationParameter("", "\x00namedFilter\x00tenant-a\x00")
+	require.NotNil(t, policy)
+	for _, objectType := range []string{"stateHash", "merkleRoot", "filterEpoch"} {
+		require.Equal(t, policy, sim.GetValidationParameter("", "\x00"+objectType+"\x00namedFilter-tenant-a\x00"), objectType)
+	}
+
+	_, err = sim.Submit(registryAdmin, "SetFilterEndorsementPolicy", "tenant-b", "RegistryMSP", `[]`)
+	require.ErrorContains(t, err, cuckoofilter.NotFoundErrorCode)
+}
+
+func TestFilterEndorsementPolicy_PrivateCollection(t *testing.T) {
+	sim, _ := newRegistrySimulator(t)
+	registryAdmin := newRegistryAdmin(t)
+	_, err := sim.Submit(registryAdmin, "SetFilterEndorsementPolicy", cuckoofilter.DefaultFilterID, "RegistryMSP", `["Issuer1MSP"]`)
+	require.NoError(t, err)
+
+	// The policy moves with the filter state into the collection
+	_, err = sim.Submit(registryAdmin, "SetPrivateCollection", "revocations")
+	require.NoError(t, err)
+	requireEndorsers(t, sim, "GetFilterEndorsers", cuckoofilter.DefaultFilterID, "RegistryMSP", "Issuer1MSP")
+	policy := sim.GetValidationParameter("revocations", "CuckooFilterState")
+	require.NotNil(t, policy)
+	require.Equal(t, policy, sim.GetValidationParameter("revocations", "\x00stateHash\x00CuckooFilterState\x00"))
+	require.Equal(t, policy, sim.GetValidationParameter("", "\x00merkleRoot\x00CuckooFilterState\x00"))
+
+	_, err = sim.Submit(registryAdmin, "AddFilterEndorser", cuckoofilter.DefaultFilterID, "Issuer2MSP")
+	require.NoError(t, err)
+	requireEndorsers(t, sim, "GetFilterEndorsers", cuckoofilter.DefaultFilterID, "RegistryMSP", "Issuer1MSP", "Issuer2MSP")
+
+	_, err = sim.Submit(registryAdmin, "SetPrivateCollection", "")
+	require.NoError(t, err)
+	requireEndorsers(t, sim, "GetFilterEndorsers", cuckoofilter.DefaultFilterID, "RegistryMSP", "Issuer1MSP", "Issuer2MSP")
+}
+
+func TestSetFilterEndorsementPolicy_PrivateCollection(t *testing.T) {
+	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockTxContext := new(mocks.MockTransactionContext)
+	mockTxContext.On("GetStub").Return(mockStub)
+	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
+	configJSON, err := json.Marshal(&cuckoofilter.RegistryConfig{StateLayout: cuckoofilter.StateLayoutSingle, PrivateCollection: "revocations"})
+	require.NoError(t, err)
+	mockStub.On("GetState", "RegistryConfig").Return(configJSON, nil)
+	mockRegistryDefaults(mockStub)
+
+	expected := policyForOrgs(t, "RegistryMSP", "Issuer1MSP")
+	mockStub.On("SetPrivateDataValidationParameter", "revocations", mock.Anything, expected).Return(nil)
+	mockStub.On("SetStateValidationParameter", mock.Anything, expected).Return(nil)
+
+	smartContract := new(cuckoofilter.SmartContract)
+	err = smartContract.SetFilterEndorsementPolicy(mockTxContext, cuckoofilter.DefaultFilterID, "RegistryMSP", []string{"Issuer1MSP"})
+	require.NoError(t, err)
+	requireFilterValidationParameters(t, mockStub, "revocations", expected)
+}
+
+func TestNamespaceEndorsementPolicy(t *testing.T) {
+	sim, _ := newShardSimulator(t, 2)
+	registryAdmin := newRegistryAdmin(t)
+	namespace := "did:web:issuer-a.example"
+
+	_, err := sim.Submit(registryAdmin, "SetNamespaceEndorsementPolicy", namespace, "RegistryMSP", `["Issuer1MSP"]`)
+	require.NoError(t, err)
+	_, err = sim.Submit(registryAdmin, "AddNamespaceEndorser", namespace, "Issuer2MSP")
+	require.NoError(t, err)
+	requireEndorsers(t, sim, "GetNamespaceEndorsers", namespace, "RegistryMSP", "Issuer1MSP", "Issuer2MSP")
+	// Other namespaces on the same shards keep the chaincode policy
+	requireEndorsers(t, sim, "GetNamespaceEndorsers", "did:web:issuer-b.example")
+
+	_, err = sim.Submit(registryAdmin, "RemoveNamespaceEndorser", namespace, "Issuer1MSP")
+	require.NoError(t, err)
+	requireEndorsers(t, sim, "GetNamespaceEndorsers", namespace, "RegistryMSP", "Issuer2MSP")
+	_, err = sim.Submit(registryAdmin, "RemoveNamespaceEndorser", namespace, "Issuer3MSP")
+	require.Error(t, err)
+
+	issuer, err := simulator.NewIdentity("Issuer1MSP", "issuer")
+	require.NoError(t, err)
+	_, err = sim.Submit(issuer, "SetNamespaceEndorsementPolicy", namespace, "Issuer1MSP", `[]`)
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+}
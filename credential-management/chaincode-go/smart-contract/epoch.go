@@ -0,0 +1,236 @@
+package cuckoofilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"sort"
+	"time"
+)
+
+// filterEpochObjectType is the composite key prefix of the current epoch of a filter state,
+// filterEpoch~<state name>
+const filterEpochObjectType = "filterEpoch"
+
+// filterEpochLogObjectType is the composite key prefix of the epochs a filter state went through,
+// filterEpochLog~<state name>~<zero padded epoch>
+const filterEpochLogObjectType = "filterEpochLog"
+
+// FilterEpoch is a version of a filter. Every transaction that writes a filter starts a new epoch,
+// so a verifier that learned the epoch with a lookup can ask for the same filter state later.
+type FilterEpoch struct {
+	Epoch     uint64    `json:"epoch"`
+	TxID      string    `json:"txId"`      // Transaction that wrote the filter
+	Timestamp time.Time `json:"timestamp"` // Timestamp of the transaction
+	Count     uint      `json:"count"`     // Items in the filter after the transaction
+}
+
+// EpochLookup is the result of LookupWithEpoch
+type EpochLookup struct {
+	Revoked bool   `json:"revoked"`
+	Epoch   uint64 `json:"epoch"` // 0 for filters last written before epochs were tracked
+}
+
+// FilterAtEpoch is a filter state reconstructed from the revocation audit trail
+type FilterAtEpoch struct {
+	Epoch    *FilterEpoch    `json:"epoch"`
+	Snapshot *FilterSnapshot `json:"snapshot"` // Reconstructed filter in the format of ExportFilterSnapshot
+}
+
+// putFilterEpoch starts a new epoch of a filter state, once per transaction
+func putFilterEpoch(ctx contractapi.TransactionContextInterface, name string, filter *Filter) error {
+	current, err := loadFilterEpoch(ctx, name)
+	if err != nil {
+		return err
+	}
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	epoch := &FilterEpoch{Epoch: 1, TxID: ctx.GetStub().GetTxID(), Timestamp: timestamp, Count: filter.Count}
+	if current != nil {
+		epoch.Epoch = current.Epoch + 1
+		// A transaction that writes the filter again keeps its epoch
+		if current.TxID == epoch.TxID {
+			epoch.Epoch = current.Epoch
+		}
+	}
+	epochJSON, err := json.Marshal(epoch)
+	if err != nil {
+		return err
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(filterEpochObjectType, []string{name})
+	if err != nil {
+		return fmt.Errorf("error creating filter epoch key: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, epochJSON); err != nil {
+		return fmt.Errorf("error saving epoch of %s: %v", name, err)
+	}
+	logKey, err := ctx.GetStub().CreateCompositeKey(filterEpochLogObjectType, []string{name, fmt.Sprintf("%020d", epoch.Epoch)})
+	if err != nil {
+		return fmt.Errorf("error creating filter epoch key: %v", err)
+	}
+	if err := ctx.GetStub().PutState(logKey, epochJSON); err != nil {
+		return fmt.Errorf("error saving epoch of %s: %v", name, err)
+	}
+	return nil
+}
+
+// loadFilterEpoch returns the current epoch of a filter state, nil if it was never written with epochs
+func loadFilterEpoch(ctx contractapi.TransactionContextInterface, name string) (*FilterEpoch, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(filterEpochObjectType, []string{name})
+	if err != nil {
+		return nil, fmt.Errorf("error creating filter epoch key: %v", err)
+	}
+	epochJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("error loading filter epoch: %v", err)
+	}
+	if epochJSON == nil {
+		return nil, nil
+	}
+	var epoch FilterEpoch
+	if err := json.Unmarshal(epochJSON, &epoch); err != nil {
+		return nil, fmt.Errorf("error decoding filter epoch: %v", err)
+	}
+	return &epoch, nil
+}
+
+// currentEpoch returns the number of the current epoch of a filter, 0 if there is none
+func currentEpoch(ctx contractapi.TransactionContextInterface, filterID string) (uint64, error) {
+	epoch, err := loadFilterEpoch(ctx, filterStateName(filterID))
+	if err != nil || epoch == nil {
+		return 0, err
+	}
+	return epoch.Epoch, nil
+}
+
+// LookupWithEpoch checks if data is present in a filter and returns the epoch of the filter state it
+// checked, for verifiers that record which version of the registry a decision was based on
+func (s *SmartContract) LookupWithEpoch(ctx contractapi.TransactionContextInterface, filterID string, data string) (*EpochLookup, error) {
+	revoked, err := s.Lookup(ctx, filterID, data)
+	if err != nil {
+		return nil, err
+	}
+	epoch, err := currentEpoch(ctx, filterID)
+	if err != nil {
+		return nil, err
+	}
+	return &EpochLookup{Revoked: revoked, Epoch: epoch}, nil
+}
+
+// GetFilterEpochs returns the epochs of a filter in order. A verifier checking whether a credential was
+// revoked at time T picks the last epoch with a timestamp before T and asks GetFilterAtEpoch for it.
+func (s *SmartContract) GetFilterEpochs(ctx contractapi.TransactionContextInterface, filterID string) ([]*FilterEpoch, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(filterEpochLogObjectType, []string{filterStateName(filterID)})
+	if err != nil {
+		return nil, fmt.Errorf("error reading filter epochs: %v", err)
+	}
+	defer iterator.Close()
+
+	epochs := []*FilterEpoch{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error reading filter epochs: %v", err)
+		}
+		var epoch FilterEpoch
+		if err := json.Unmarshal(result.Value, &epoch); err != nil {
+			return nil, fmt.Errorf("error decoding filter epoch: %v", err)
+		}
+		epochs = append(epochs, &epoch)
+	}
+	return epochs, nil
+}
+
+// GetFilterAtEpoch reconstructs a filter as it was at the end of an epoch by replaying the revocation
+// audit trail of the transactions of epochs 1 to epoch into an empty filter with the dimensions of the
+// current one. Filters that held items before epochs were tracked, or were restored from a snapshot,
+// cannot be reconstructed and fail with ErrFailedPrecondition. Registry admins only, like
+// ExportFilterSnapshot.
+func (s *SmartContract) GetFilterAtEpoch(ctx contractapi.TransactionContextInterface, filterID string, epoch uint64) (*FilterAtEpoch, error) {
+	if err := checkSnapshotAdmin(ctx); err != nil {
+		return nil, err
+	}
+	current, err := s.loadFilter(ctx, filterID)
+	if err != nil {
+		return nil, err
+	}
+	epochs, err := s.GetFilterEpochs(ctx, filterID)
+	if err != nil {
+		return nil, err
+	}
+	if epoch == 0 || epoch > uint64(len(epochs)) {
+		return nil, fmt.Errorf("%w: filter '%s' has no epoch %d", ErrNotFound, filterID, epoch)
+	}
+	epochs = epochs[:epoch]
+	epochOfTx := make(map[string]uint64, len(epochs))
+	for _, e := range epochs {
+		epochOfTx[e.TxID] = e.Epoch
+	}
+
+	entries, err := auditEntriesOf(ctx, epochOfTx)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return epochOfTx[entries[i].TxID] < epochOfTx[entries[j].TxID]
+	})
+	filter := newFilter(&filterOptions{
+		numElements:     uint(len(current.Buckets)),
+		bucketSize:      current.bucketSize(),
+		fingerprintSize: current.fingerprintSize(),
+		maxKicks:        uint(current.maxKicks()),
+		semiSorted:      current.SemiSorted,
+	})
+	filter.growLoadFactor = current.growLoadFactor
+	filter.growBuckets = current.growBuckets
+	filter.fingerprintKey = current.fingerprintKey
+	for _, entry := range entries {
+		switch entry.Action {
+		case LifecycleRevoked:
+			filter, _ = insertGrowing(filter, []byte(entry.Fingerprint))
+		case LifecycleUnrevoked:
+			filter.Delete([]byte(entry.Fingerprint))
+		}
+	}
+
+	target := epochs[len(epochs)-1]
+	if filter.Count != target.Count {
+		return nil, fmt.Errorf("%w: the revocation audit trail of filter '%s' rebuilds %d of %d items at epoch %d", ErrFailedPrecondition, filterID, filter.Count, target.Count, epoch)
+	}
+	snapshot, err := newFilterSnapshot(filterID, filter)
+	if err != nil {
+		return nil, err
+	}
+	return &FilterAtEpoch{Epoch: target, Snapshot: snapshot}, nil
+}
+
+// auditEntriesOf returns the revocation audit entries recorded by the given transactions
+func auditEntriesOf(ctx contractapi.TransactionContextInterface, txIDs map[string]uint64) ([]*RevocationAuditEntry, error) {
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := getCollectionStateByPartialCompositeKey(ctx, config.PrivateCollection, revocationAuditObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("error reading revocation history: %v", err)
+	}
+	defer iterator.Close()
+
+	entries := []*RevocationAuditEntry{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error reading revocation history: %v", err)
+		}
+		var entry RevocationAuditEntry
+		if err := json.Unmarshal(result.Value, &entry); err != nil {
+			return nil, fmt.Errorf("error decoding revocation audit entry: %v", err)
+		}
+		if _, ok := txIDs[entry.TxID]; ok {
+			entries = append(entries, &entry)
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,103 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"testing"
+)
+
+func TestFilterEpochs(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	_, err := sim.Submit(admin, "BatchInsert", "", `["credential-1","credential-2"]`)
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "Delete", "", "credential-1")
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "Insert", "", "credential-3")
+	require.NoError(t, err)
+
+	resultJSON, err := sim.Evaluate(admin, "LookupWithEpoch", "", "credential-2")
+	require.NoError(t, err)
+	var lookup cuckoofilter.EpochLookup
+	require.NoError(t, json.Unmarshal(resultJSON, &lookup))
+	require.Equal(t, cuckoofilter.EpochLookup{Revoked: true, Epoch: 4}, lookup)
+
+	epochsJSON, err := sim.Evaluate(admin, "GetFilterEpochs", "")
+	require.NoError(t, err)
+	var epochs []*cuckoofilter.FilterEpoch
+	require.NoError(t, json.Unmarshal(epochsJSON, &epochs))
+	require.Len(t, epochs, 4)
+	for i, epoch := range epochs {
+		require.Equal(t, uint64(i+1), epoch.Epoch)
+	}
+	require.Equal(t, []uint{0, 2, 1, 2}, []uint{epochs[0].Count, epochs[1].Count, epochs[2].Count, epochs[3].Count})
+
+	expected := map[uint64]map[string]bool{
+		1: {"credential-1": false, "credential-2": false, "credential-3": false},
+		2: {"credential-1": true, "credential-2": true, "credential-3": false},
+		3: {"credential-1": false, "credential-2": true, "credential-3": false},
+		4: {"credential-1": false, "credential-2": true, "credential-3": true},
+	}
+	snapshotAdmin := newRegistryAdmin(t)
+	for epoch, revoked := range expected {
+		history := requireFilterAtEpoch(t, sim, "", epoch)
+		require.Equal(t, *epochs[epoch-1], *history.Epoch)
+		// The reconstructed filter is imported as a named filter to check it
+		historyID := "epoch-" + strconv.FormatUint(epoch, 10)
+		snapshotJSON, err := json.Marshal(history.Snapshot)
+		require.NoError(t, err)
+		_, err = sim.Submit(snapshotAdmin, "ImportFilterSnapshot", historyID, string(snapshotJSON))
+		require.NoError(t, err)
+		for data, expected := range revoked {
+			requireLookup(t, sim, admin, historyID, data, expected)
+		}
+	}
+
+	_, err = sim.Evaluate(snapshotAdmin, "GetFilterAtEpoch", "", "5")
+	require.ErrorContains(t, err, cuckoofilter.NotFoundErrorCode)
+	_, err = sim.Evaluate(admin, "GetFilterAtEpoch", "", "1")
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+}
+
+// requireFilterAtEpoch reconstructs a filter at an epoch
+func requireFilterAtEpoch(t *testing.T, sim *simulator.Simulator, filterID string, epoch uint64) *cuckoofilter.FilterAtEpoch {
+	historyJSON, err := sim.Evaluate(newRegistryAdmin(t), "GetFilterAtEpoch", filterID, strconv.FormatUint(epoch, 10))
+	require.NoError(t, err)
+	var history cuckoofilter.FilterAtEpoch
+	require.NoError(t, json.Unmarshal(historyJSON, &history))
+	return &history
+}
+
+func TestFilterEpochs_NamedFilter(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	_, err := sim.Submit(newRegistryAdmin(t), "Init", "issuer-1", "100", "4", "0")
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "Insert", "issuer-1", "credential-1")
+	require.NoError(t, err)
+	// Writes of other filters leave the epoch alone
+	_, err = sim.Submit(admin, "Insert", "", "credential-2")
+	require.NoError(t, err)
+
+	resultJSON, err := sim.Evaluate(admin, "LookupWithEpoch", "issuer-1", "credential-2")
+	require.NoError(t, err)
+	var lookup cuckoofilter.EpochLookup
+	require.NoError(t, json.Unmarshal(resultJSON, &lookup))
+	require.Equal(t, cuckoofilter.EpochLookup{Revoked: false, Epoch: 2}, lookup)
+
+	history := requireFilterAtEpoch(t, sim, "issuer-1", 2)
+	require.Equal(t, uint(1), history.Epoch.Count)
+	require.Equal(t, "issuer-1", history.Snapshot.FilterID)
+}
+
+func TestLookupV1_Epoch(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	_, err := sim.Submit(admin, "Insert", "", "credential-1")
+	require.NoError(t, err)
+	responseJSON, err := sim.Evaluate(admin, "LookupV1", `{"fingerprints":["credential-1"]}`)
+	require.NoError(t, err)
+	var response cuckoofilter.LookupResponseV1
+	require.NoError(t, json.Unmarshal(responseJSON, &response))
+	require.Equal(t, uint64(2), response.Epoch)
+}
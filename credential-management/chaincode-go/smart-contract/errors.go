@@ -0,0 +1,90 @@
+package cuckoofilter
+
+import (
+	"errors"
+	"strings"
+)
+
+// Error codes of the failure reasons clients can branch on. contractapi returns the message of a failed
+// transaction as the chaincode response message, so, like RegistryFrozenErrorCode, the code prefixes
+// the message, e.g. "NOT_FOUND: filter 'issuer-a' not found". ErrorCode recovers it on the client.
+const (
+	FilterFullErrorCode         = "FILTER_FULL"
+	NotFoundErrorCode           = "NOT_FOUND"
+	AlreadyRevokedErrorCode     = "ALREADY_REVOKED"
+	StateCorruptErrorCode       = "STATE_CORRUPT"
+	UnauthorizedErrorCode       = "UNAUTHORIZED"
+	InvalidArgumentErrorCode    = "INVALID_ARGUMENT"
+	ConflictErrorCode           = "CONFLICT"
+	FailedPreconditionErrorCode = "FAILED_PRECONDITION"
+)
+
+// Error is a failure reason with an error code. Its message is the code; the errors returned by
+// transactions wrap it with the details, so errors.Is matches them inside the chaincode.
+type Error struct {
+	Code string
+}
+
+func (e *Error) Error() string {
+	return e.Code
+}
+
+// Failure reasons of the chaincode
+var (
+	// ErrFilterFull is returned when a fingerprint cannot be inserted because its buckets are full
+	ErrFilterFull = &Error{Code: FilterFullErrorCode}
+	// ErrNotFound is returned when a filter, fingerprint, request or other record does not exist
+	ErrNotFound = &Error{Code: NotFoundErrorCode}
+	// ErrAlreadyRevoked is returned when a credential that must not be revoked yet is revoked
+	ErrAlreadyRevoked = &Error{Code: AlreadyRevokedErrorCode}
+	// ErrStateCorrupt is returned when a state read from the ledger does not match its hash or is broken
+	ErrStateCorrupt = &Error{Code: StateCorruptErrorCode}
+	// ErrUnauthorized is returned when the submitting client may not perform the transaction
+	ErrUnauthorized = &Error{Code: UnauthorizedErrorCode}
+	// ErrInvalidArgument is returned when a transaction argument is malformed or not allowed
+	ErrInvalidArgument = &Error{Code: InvalidArgumentErrorCode}
+	// ErrConflict is returned when a request contradicts an earlier one
+	ErrConflict = &Error{Code: ConflictErrorCode}
+	// ErrFailedPrecondition is returned when the ledger state does not allow the transaction, e.g. verifying
+	// a credential of an untrusted issuer
+	ErrFailedPrecondition = &Error{Code: FailedPreconditionErrorCode}
+)
+
+// errorCodes are the codes ErrorCode recognizes
+var errorCodes = []string{
+	FilterFullErrorCode,
+	NotFoundErrorCode,
+	AlreadyRevokedErrorCode,
+	StateCorruptErrorCode,
+	UnauthorizedErrorCode,
+	InvalidArgumentErrorCode,
+	ConflictErrorCode,
+	FailedPreconditionErrorCode,
+	RegistryFrozenErrorCode,
+}
+
+// ErrorCodes returns the codes of the chaincode errors
+func ErrorCodes() []string {
+	return append([]string(nil), errorCodes...)
+}
+
+// ErrorCode returns the error code of an error returned by a transaction, empty if it has none. It reads
+// the code from the message, so it works on the errors a client receives from the gateway, where the
+// chaincode response message is embedded in the gateway's own message.
+func ErrorCode(err error) string {
+	if err == nil {
+		return ""
+	}
+	var coded *Error
+	if errors.As(err, &coded) {
+		return coded.Code
+	}
+	message := err.Error()
+	code, first := "", len(message)
+	for _, candidate := range errorCodes {
+		if i := strings.Index(message, candidate+":"); i >= 0 && i < first {
+			code, first = candidate, i
+		}
+	}
+	return code
+}
@@ -0,0 +1,43 @@
+package cuckoofilter_test
+
+import (
+	"errors"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestErrorCode(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	_, err := sim.Submit(admin, "Insert", "", "credential-1")
+	require.NoError(t, err)
+
+	_, err = sim.Submit(admin, "Insert", "", "credential-1")
+	require.Equal(t, cuckoofilter.AlreadyRevokedErrorCode, cuckoofilter.ErrorCode(err))
+
+	_, err = sim.Evaluate(admin, "Lookup", "issuer-a", "credential-1")
+	require.Equal(t, cuckoofilter.NotFoundErrorCode, cuckoofilter.ErrorCode(err))
+
+	other, err := simulator.NewIdentity("Org2MSP", "issuer")
+	require.NoError(t, err)
+	_, err = sim.Submit(other, "Delete", "", "credential-1")
+	require.Equal(t, cuckoofilter.UnauthorizedErrorCode, cuckoofilter.ErrorCode(err))
+
+	require.NoError(t, sim.SetState("CuckooFilterState", []byte(`{"Count":0}`)))
+	_, err = sim.Evaluate(admin, "Lookup", "", "credential-1")
+	require.Equal(t, cuckoofilter.StateCorruptErrorCode, cuckoofilter.ErrorCode(err))
+}
+
+func TestErrorCode_Wrapped(t *testing.T) {
+	require.Equal(t, "", cuckoofilter.ErrorCode(nil))
+	require.Equal(t, "", cuckoofilter.ErrorCode(errors.New("endorsement failed")))
+	require.True(t, errors.Is(cuckoofilter.ErrCorruptState, cuckoofilter.ErrStateCorrupt))
+	require.True(t, errors.Is(cuckoofilter.ErrNotInserter, cuckoofilter.ErrUnauthorized))
+	require.Equal(t, cuckoofilter.UnauthorizedErrorCode, cuckoofilter.ErrorCode(cuckoofilter.ErrNotCredentialIssuer))
+
+	// A chaincode response message embedded in a gateway error, the earliest code wins
+	gatewayErr := errors.New("rpc error: code = Aborted desc = chaincode response 500, error loading filter state: NOT_FOUND: filter 'issuer-a' not found (STATE_CORRUPT: unrelated)")
+	require.Equal(t, cuckoofilter.NotFoundErrorCode, cuckoofilter.ErrorCode(gatewayErr))
+	require.Equal(t, cuckoofilter.RegistryFrozenErrorCode, cuckoofilter.ErrorCode(errors.New("REGISTRY_FROZEN: registry is frozen")))
+}
@@ -0,0 +1,210 @@
+package cuckoofilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"time"
+)
+
+// Composite key prefixes of the credential expiry registry
+const (
+	credentialExpiryObjectType = "credentialExpiry" // credentialExpiry~<issuer DID>~<expiry day>~<jti> holds a CredentialExpiry
+	expiryWarningObjectType    = "expiryWarning"    // expiryWarning~<issuer DID> holds the ExpiryWarningConfig of an issuer
+)
+
+// CredentialsExpiringEvent is the name of the chaincode event emitted by EmitExpiryWarning.
+// Its payload is the ExpiryWarning as JSON.
+const CredentialsExpiringEvent = "CredentialsExpiring"
+
+// MaxExpiryWarningDays bounds the warning window of an issuer
+const MaxExpiryWarningDays = 365
+
+// CredentialExpiry records when a credential issued by an issuer expires
+type CredentialExpiry struct {
+	JTI       string    `json:"jti"`
+	IssuerDID string    `json:"issuerDID"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// ExpiryWarningConfig opts an issuer in to warnings about credentials expiring within Days days
+type ExpiryWarningConfig struct {
+	IssuerDID string `json:"issuerDID"`
+	Days      uint   `json:"days"`
+}
+
+// ExpiryWarning lists the credentials of an issuer that expire within the issuer's warning window
+type ExpiryWarning struct {
+	IssuerDID   string             `json:"issuerDID"`
+	Days        uint               `json:"days"`
+	Credentials []CredentialExpiry `json:"credentials"`
+}
+
+// RecordCredentialExpiry stores the expiry of a credential of an issuer, identified by its jti. expiresAt
+// is an RFC 3339 time. Only the issuer itself, as the DID attribute of the client certificate, or a
+// registry admin may record expiries.
+func (s *SmartContract) RecordCredentialExpiry(ctx contractapi.TransactionContextInterface, issuerDID string, jti string, expiresAt string) (*CredentialExpiry, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	if issuerDID == "" || jti == "" {
+		return nil, fmt.Errorf("issuer DID and jti must not be empty")
+	}
+	expiry, err := time.Parse(time.RFC3339, expiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("expiry must be an RFC 3339 time: %v", err)
+	}
+	if err := checkIssuerOrAdmin(ctx, issuerDID, "record credential expiries"); err != nil {
+		return nil, err
+	}
+
+	record := &CredentialExpiry{JTI: jti, IssuerDID: issuerDID, ExpiresAt: expiry.UTC()}
+	key, err := ctx.GetStub().CreateCompositeKey(credentialExpiryObjectType, []string{issuerDID, record.ExpiresAt.Format(StatisticsDayFormat), jti})
+	if err != nil {
+		return nil, fmt.Errorf("error creating credential expiry key: %v", err)
+	}
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.GetStub().PutState(key, recordJSON); err != nil {
+		return nil, fmt.Errorf("error saving credential expiry: %v", err)
+	}
+	return record, nil
+}
+
+// SetExpiryWarning opts an issuer in to warnings about credentials expiring within days days. A window
+// of 0 days opts out. Only the issuer itself or a registry admin may change the window.
+func (s *SmartContract) SetExpiryWarning(ctx contractapi.TransactionContextInterface, issuerDID string, days uint) (*ExpiryWarningConfig, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	if issuerDID == "" {
+		return nil, fmt.Errorf("issuer DID must not be empty")
+	}
+	if days > MaxExpiryWarningDays {
+		return nil, fmt.Errorf("warning window must be at most %d days", MaxExpiryWarningDays)
+	}
+	if err := checkIssuerOrAdmin(ctx, issuerDID, "change its expiry warnings"); err != nil {
+		return nil, err
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(expiryWarningObjectType, []string{issuerDID})
+	if err != nil {
+		return nil, fmt.Errorf("error creating expiry warning key: %v", err)
+	}
+
+	config := &ExpiryWarningConfig{IssuerDID: issuerDID, Days: days}
+	if days == 0 {
+		if err := ctx.GetStub().DelState(key); err != nil {
+			return nil, fmt.Errorf("error deleting expiry warning config: %v", err)
+		}
+		return config, nil
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.GetStub().PutState(key, configJSON); err != nil {
+		return nil, fmt.Errorf("error saving expiry warning config: %v", err)
+	}
+	return config, nil
+}
+
+// ListExpiryWarnings returns the configs of the issuers that opted in to expiry warnings
+func (s *SmartContract) ListExpiryWarnings(ctx contractapi.TransactionContextInterface) ([]*ExpiryWarningConfig, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(expiryWarningObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("error reading expiry warning configs: %v", err)
+	}
+	defer iterator.Close()
+
+	configs := []*ExpiryWarningConfig{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error reading expiry warning configs: %v", err)
+		}
+		var config ExpiryWarningConfig
+		if err := json.Unmarshal(entry.Value, &config); err != nil {
+			return nil, fmt.Errorf("error decoding expiry warning config: %v", err)
+		}
+		configs = append(configs, &config)
+	}
+	return configs, nil
+}
+
+// EmitExpiryWarning is the keeper transaction for one opted-in issuer: it emits a CredentialsExpiring event
+// listing the issuer's credentials that expire between the transaction time and the end of the issuer's
+// warning window. Every run lists all of them again, so keepers run it once per warning period.
+// No event is emitted when nothing expires.
+func (s *SmartContract) EmitExpiryWarning(ctx contractapi.TransactionContextInterface, issuerDID string) (*ExpiryWarning, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(expiryWarningObjectType, []string{issuerDID})
+	if err != nil {
+		return nil, fmt.Errorf("error creating expiry warning key: %v", err)
+	}
+	configJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("error loading expiry warning config: %v", err)
+	}
+	if configJSON == nil {
+		return nil, fmt.Errorf("issuer '%s' did not opt in to expiry warnings", issuerDID)
+	}
+	var config ExpiryWarningConfig
+	if err := json.Unmarshal(configJSON, &config); err != nil {
+		return nil, fmt.Errorf("error decoding expiry warning config: %v", err)
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Range queries cannot span composite keys, so the window is read day by day
+	end := now.AddDate(0, 0, int(config.Days))
+	warning := &ExpiryWarning{IssuerDID: issuerDID, Days: config.Days, Credentials: []CredentialExpiry{}}
+	for day := now; day.Format(StatisticsDayFormat) <= end.Format(StatisticsDayFormat); day = day.AddDate(0, 0, 1) {
+		expiries, err := credentialExpiriesOn(ctx, issuerDID, day)
+		if err != nil {
+			return nil, err
+		}
+		for _, expiry := range expiries {
+			if !expiry.ExpiresAt.Before(now) && !expiry.ExpiresAt.After(end) {
+				warning.Credentials = append(warning.Credentials, expiry)
+			}
+		}
+	}
+	if len(warning.Credentials) == 0 {
+		return warning, nil
+	}
+
+	warningJSON, err := json.Marshal(warning)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.GetStub().SetEvent(CredentialsExpiringEvent, warningJSON); err != nil {
+		return nil, fmt.Errorf("error emitting %s event: %v", CredentialsExpiringEvent, err)
+	}
+	return warning, nil
+}
+
+// credentialExpiriesOn returns the credentials of an issuer that expire on the day of t
+func credentialExpiriesOn(ctx contractapi.TransactionContextInterface, issuerDID string, t time.Time) ([]CredentialExpiry, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(credentialExpiryObjectType, []string{issuerDID, t.Format(StatisticsDayFormat)})
+	if err != nil {
+		return nil, fmt.Errorf("error reading credential expiries: %v", err)
+	}
+	defer iterator.Close()
+
+	var expiries []CredentialExpiry
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error reading credential expiries: %v", err)
+		}
+		var expiry CredentialExpiry
+		if err := json.Unmarshal(entry.Value, &expiry); err != nil {
+			return nil, fmt.Errorf("error decoding credential expiry: %v", err)
+		}
+		expiries = append(expiries, expiry)
+	}
+	return expiries, nil
+}
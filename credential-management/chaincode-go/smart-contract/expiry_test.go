@@ -0,0 +1,87 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestEmitExpiryWarning(t *testing.T) {
+	sim, _ := newRegistrySimulator(t)
+	sim.SetTime(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	issuer1 := newIssuerIdentity(t, "Org1MSP", "did:key:issuer1")
+	issuer2 := newIssuerIdentity(t, "Org2MSP", "did:key:issuer2")
+
+	for jti, expiresAt := range map[string]string{
+		"expired":      "2024-03-01T06:00:00Z",
+		"soon":         "2024-03-03T00:00:00Z",
+		"end-of-range": "2024-03-08T11:00:00Z",
+		"later":        "2024-03-20T00:00:00Z",
+	} {
+		_, err := sim.Submit(issuer1, "RecordCredentialExpiry", "did:key:issuer1", jti, expiresAt)
+		require.NoError(t, err)
+	}
+	_, err := sim.Submit(issuer2, "RecordCredentialExpiry", "did:key:issuer2", "other-issuer", "2024-03-02T00:00:00Z")
+	require.NoError(t, err)
+
+	// Issuers that did not opt in get no warnings
+	_, err = sim.Submit(issuer1, "EmitExpiryWarning", "did:key:issuer1")
+	require.Error(t, err)
+	_, err = sim.Submit(issuer1, "SetExpiryWarning", "did:key:issuer1", "7")
+	require.NoError(t, err)
+
+	configsJSON, err := sim.Evaluate(issuer2, "ListExpiryWarnings")
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"issuerDID":"did:key:issuer1","days":7}]`, string(configsJSON))
+
+	tx, err := sim.Submit(issuer2, "EmitExpiryWarning", "did:key:issuer1")
+	require.NoError(t, err)
+	require.Equal(t, cuckoofilter.CredentialsExpiringEvent, tx.Event.EventName)
+	var warning cuckoofilter.ExpiryWarning
+	require.NoError(t, json.Unmarshal(tx.Event.Payload, &warning))
+	require.Equal(t, "did:key:issuer1", warning.IssuerDID)
+	var jtis []string
+	for _, expiry := range warning.Credentials {
+		jtis = append(jtis, expiry.JTI)
+	}
+	require.Equal(t, []string{"soon", "end-of-range"}, jtis)
+
+	// Opting out stops the warnings
+	_, err = sim.Submit(issuer1, "SetExpiryWarning", "did:key:issuer1", "0")
+	require.NoError(t, err)
+	_, err = sim.Submit(issuer2, "EmitExpiryWarning", "did:key:issuer1")
+	require.Error(t, err)
+}
+
+func TestEmitExpiryWarning_NothingExpiring(t *testing.T) {
+	sim, _ := newRegistrySimulator(t)
+	issuer := newIssuerIdentity(t, "Org1MSP", "did:key:issuer1")
+	_, err := sim.Submit(issuer, "SetExpiryWarning", "did:key:issuer1", "30")
+	require.NoError(t, err)
+
+	tx, err := sim.Submit(issuer, "EmitExpiryWarning", "did:key:issuer1")
+	require.NoError(t, err)
+	require.Nil(t, tx.Event)
+}
+
+func TestExpiryWarning_IssuerOrAdmin(t *testing.T) {
+	sim, client := newRegistrySimulator(t)
+	issuer := newIssuerIdentity(t, "Org1MSP", "did:key:issuer1")
+	other := newIssuerIdentity(t, "Org2MSP", "did:key:issuer2")
+
+	for _, identity := range []*simulator.Identity{client, other} {
+		_, err := sim.Submit(identity, "RecordCredentialExpiry", "did:key:issuer1", "credential-1", "2024-03-02T00:00:00Z")
+		require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+		_, err = sim.Submit(identity, "SetExpiryWarning", "did:key:issuer1", "30")
+		require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+	}
+	for _, identity := range []*simulator.Identity{issuer, newRegistryAdmin(t)} {
+		_, err := sim.Submit(identity, "RecordCredentialExpiry", "did:key:issuer1", "credential-1", "2024-03-02T00:00:00Z")
+		require.NoError(t, err)
+		_, err = sim.Submit(identity, "SetExpiryWarning", "did:key:issuer1", "30")
+		require.NoError(t, err)
+	}
+}
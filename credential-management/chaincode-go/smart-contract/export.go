@@ -0,0 +1,121 @@
+package cuckoofilter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"io"
+)
+
+// FilterSnapshotVersion is the version of the snapshots ExportFilterSnapshot writes
+const FilterSnapshotVersion = 1
+
+// MaxFilterSnapshotSize is the largest uncompressed filter ImportFilterSnapshot accepts
+const MaxFilterSnapshotSize = 256 << 20
+
+// FilterSnapshot is a filter exported to move it to another channel or to back it up
+type FilterSnapshot struct {
+	Version  int    `json:"version"`
+	FilterID string `json:"filterId"` // Filter the snapshot was exported from
+	Data     string `json:"data"`     // Base64 of the gzip compressed filter state
+	Hash     string `json:"hash"`     // Hex encoded SHA-256 of the uncompressed filter state
+}
+
+// ExportFilterSnapshot returns a snapshot of a filter. Registry admins only, as the snapshot of a default
+// filter kept in a private data collection holds its fingerprints.
+func (s *SmartContract) ExportFilterSnapshot(ctx contractapi.TransactionContextInterface, filterID string) (*FilterSnapshot, error) {
+	if err := checkSnapshotAdmin(ctx); err != nil {
+		return nil, err
+	}
+	filter, err := s.loadFilter(ctx, filterID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading filter state: %v", err)
+	}
+	return newFilterSnapshot(filterID, filter)
+}
+
+// newFilterSnapshot compresses a filter into a snapshot
+func newFilterSnapshot(filterID string, filter *Filter) (*FilterSnapshot, error) {
+	filterJSON, err := json.Marshal(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	if _, err := writer.Write(filterJSON); err != nil {
+		return nil, fmt.Errorf("error compressing filter state: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("error compressing filter state: %v", err)
+	}
+	hash := sha256.Sum256(filterJSON)
+	return &FilterSnapshot{
+		Version:  FilterSnapshotVersion,
+		FilterID: filterID,
+		Data:     base64.StdEncoding.EncodeToString(compressed.Bytes()),
+		Hash:     hex.EncodeToString(hash[:]),
+	}, nil
+}
+
+// ImportFilterSnapshot replaces a filter, which need not be the one the snapshot was exported from,
+// with the filter of a snapshot. The snapshot must match its hash and hold a valid filter.
+// Registry admins only.
+func (s *SmartContract) ImportFilterSnapshot(ctx contractapi.TransactionContextInterface, filterID string, snapshot FilterSnapshot) error {
+	if err := checkWritable(ctx); err != nil {
+		return err
+	}
+	if err := checkSnapshotAdmin(ctx); err != nil {
+		return err
+	}
+	filter, err := decodeFilterSnapshot(&snapshot)
+	if err != nil {
+		return err
+	}
+	return s.saveFilter(ctx, filterID, filter)
+}
+
+// decodeFilterSnapshot decompresses the filter of a snapshot and checks it against the snapshot hash
+func decodeFilterSnapshot(snapshot *FilterSnapshot) (*Filter, error) {
+	if snapshot.Version != FilterSnapshotVersion {
+		return nil, fmt.Errorf("%w: unsupported filter snapshot version %d", ErrInvalidArgument, snapshot.Version)
+	}
+	compressed, err := base64.StdEncoding.DecodeString(snapshot.Data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: error decoding filter snapshot: %v", ErrInvalidArgument, err)
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("%w: error decompressing filter snapshot: %v", ErrInvalidArgument, err)
+	}
+	filterJSON, err := io.ReadAll(io.LimitReader(reader, MaxFilterSnapshotSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: error decompressing filter snapshot: %v", ErrInvalidArgument, err)
+	}
+	if len(filterJSON) > MaxFilterSnapshotSize {
+		return nil, fmt.Errorf("%w: filter snapshot exceeds %d bytes", ErrInvalidArgument, MaxFilterSnapshotSize)
+	}
+
+	hash := sha256.Sum256(filterJSON)
+	if hex.EncodeToString(hash[:]) != snapshot.Hash {
+		return nil, fmt.Errorf("%w: filter snapshot does not match its hash", ErrInvalidArgument)
+	}
+	var filter Filter
+	if err := json.Unmarshal(filterJSON, &filter); err != nil {
+		return nil, fmt.Errorf("%w: error decoding filter snapshot: %v", ErrInvalidArgument, err)
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	return &filter, nil
+}
+
+// checkSnapshotAdmin fails unless the submitting client is a registry admin
+func checkSnapshotAdmin(ctx contractapi.TransactionContextInterface) error {
+	return checkRegistryAdmin(ctx, "export or import filter snapshots")
+}
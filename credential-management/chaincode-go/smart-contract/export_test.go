@@ -0,0 +1,76 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// newRegistryAdmin returns an identity with the registry admin attribute
+func newRegistryAdmin(t *testing.T) *simulator.Identity {
+	admin, err := simulator.NewIdentityWithAttributes("Org1MSP", "registry-admin", map[string]string{cuckoofilter.RegistryAdminAttribute: "true"})
+	require.NoError(t, err)
+	return admin
+}
+
+func TestFilterSnapshot_ExportImport(t *testing.T) {
+	sim, _ := newRegistrySimulator(t)
+	admin := newRegistryAdmin(t)
+	_, err := sim.Submit(admin, "BatchInsert", "", `["credential-1","credential-2"]`)
+	require.NoError(t, err)
+
+	snapshotJSON, err := sim.Evaluate(admin, "ExportFilterSnapshot", "")
+	require.NoError(t, err)
+	var snapshot cuckoofilter.FilterSnapshot
+	require.NoError(t, json.Unmarshal(snapshotJSON, &snapshot))
+	require.Equal(t, cuckoofilter.FilterSnapshotVersion, snapshot.Version)
+
+	// Migrate the registry to another channel
+	target, err := simulator.New("credential-management", &cuckoofilter.SmartContract{})
+	require.NoError(t, err)
+	_, err = target.Submit(admin, "ImportFilterSnapshot", "", string(snapshotJSON))
+	require.NoError(t, err)
+	requireLookup(t, target, admin, "", "credential-1", true)
+	requireLookup(t, target, admin, "", "credential-3", false)
+	hashJSON, err := target.Evaluate(admin, "GetFilterStateHash")
+	require.NoError(t, err)
+	var hash cuckoofilter.FilterStateHash
+	require.NoError(t, json.Unmarshal(hashJSON, &hash))
+	require.Equal(t, snapshot.Hash, hash.Hash)
+
+	// Or restore it as a named filter
+	_, err = sim.Submit(admin, "ImportFilterSnapshot", "restored", string(snapshotJSON))
+	require.NoError(t, err)
+	requireLookup(t, sim, admin, "restored", "credential-2", true)
+}
+
+func TestFilterSnapshot_RejectsInvalidSnapshots(t *testing.T) {
+	sim, _ := newRegistrySimulator(t)
+	admin := newRegistryAdmin(t)
+	snapshotJSON, err := sim.Evaluate(admin, "ExportFilterSnapshot", "")
+	require.NoError(t, err)
+	var snapshot cuckoofilter.FilterSnapshot
+	require.NoError(t, json.Unmarshal(snapshotJSON, &snapshot))
+
+	tampered := snapshot
+	tampered.Hash = "00" + snapshot.Hash[2:]
+	unknownVersion := snapshot
+	unknownVersion.Version = 2
+	notCompressed := snapshot
+	notCompressed.Data = "e30="
+	for _, invalid := range []cuckoofilter.FilterSnapshot{tampered, unknownVersion, notCompressed} {
+		invalidJSON, err := json.Marshal(invalid)
+		require.NoError(t, err)
+		_, err = sim.Submit(admin, "ImportFilterSnapshot", "", string(invalidJSON))
+		require.ErrorContains(t, err, cuckoofilter.InvalidArgumentErrorCode)
+	}
+
+	// Only registry admins export and import
+	issuer := newIssuerIdentity(t, "Org1MSP", "did:key:issuer")
+	_, err = sim.Evaluate(issuer, "ExportFilterSnapshot", "")
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+	_, err = sim.Submit(issuer, "ImportFilterSnapshot", "", string(snapshotJSON))
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+}
@@ -0,0 +1,67 @@
+package cuckoofilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// FilterChangedEvent is the name of the chaincode event emitted by Insert, BatchInsert, Delete and BatchDelete,
+// by the transactions that revoke on behalf of holders and subjects, such as ApproveRevocationRequest and
+// RevokeAllForSubject, and by InsertInNamespace and DeleteFromNamespace of the sharded registry. Its payload is the FilterChange
+// as JSON. Verifiers subscribe to it instead of polling Lookup.
+const FilterChangedEvent = "FilterChanged"
+
+// Actions of a FilterChange
+const (
+	FilterChangeInserted = "inserted"
+	FilterChangeDeleted  = "deleted"
+)
+
+// FilterChange describes a mutation of a filter
+type FilterChange struct {
+	FilterID     string   `json:"filterID"`
+	Namespace    string   `json:"namespace,omitempty"` // Issuer namespace of a change of the sharded registry
+	Action       string   `json:"action"`
+	Fingerprints []string `json:"fingerprints"` // Fingerprints inserted or deleted by the transaction, empty for a private default filter
+	Count        uint     `json:"count"`        // Filter count after the transaction
+}
+
+// emitFilterChanged emits a FilterChanged event for the fingerprints a transaction inserted into or deleted
+// from a filter. Transactions that changed nothing emit no event. Events are visible to every channel
+// member, so the fingerprints of a default filter kept in a private data collection are left out.
+func emitFilterChanged(ctx contractapi.TransactionContextInterface, filterID string, action string, filter *Filter, fingerprints []string) error {
+	if len(fingerprints) == 0 {
+		return nil
+	}
+	if filterID == DefaultFilterID {
+		config, err := loadRegistryConfig(ctx)
+		if err != nil {
+			return err
+		}
+		if config.PrivateCollection != "" {
+			fingerprints = []string{}
+		}
+	}
+	return setFilterChangedEvent(ctx, &FilterChange{FilterID: filterID, Action: action, Fingerprints: fingerprints, Count: filter.Count})
+}
+
+// emitNamespaceChanged emits a FilterChanged event for the fingerprints a transaction inserted into or
+// deleted from an issuer namespace. The count is the one of the namespace's shard.
+func emitNamespaceChanged(ctx contractapi.TransactionContextInterface, namespace string, action string, shard *Filter, fingerprints []string) error {
+	if len(fingerprints) == 0 {
+		return nil
+	}
+	return setFilterChangedEvent(ctx, &FilterChange{Namespace: namespace, Action: action, Fingerprints: fingerprints, Count: shard.Count})
+}
+
+func setFilterChangedEvent(ctx contractapi.TransactionContextInterface, change *FilterChange) error {
+	changeJSON, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().SetEvent(FilterChangedEvent, changeJSON); err != nil {
+		return fmt.Errorf("error emitting %s event: %v", FilterChangedEvent, err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func requireFilterChange(t *testing.T, tx *simulator.Transaction) cuckoofilter.FilterChange {
+	require.NotNil(t, tx.Event)
+	require.Equal(t, cuckoofilter.FilterChangedEvent, tx.Event.EventName)
+	var change cuckoofilter.FilterChange
+	require.NoError(t, json.Unmarshal(tx.Event.Payload, &change))
+	return change
+}
+
+func TestFilterChangedEvents(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+
+	tx, err := sim.Submit(admin, "Insert", "", "credential-1")
+	require.NoError(t, err)
+	require.Equal(t, cuckoofilter.FilterChange{
+		Action:       cuckoofilter.FilterChangeInserted,
+		Fingerprints: []string{"credential-1"},
+		Count:        1,
+	}, requireFilterChange(t, tx))
+
+	tx, err = sim.Submit(admin, "BatchInsert", "", `["credential-2","credential-3"]`)
+	require.NoError(t, err)
+	require.Equal(t, cuckoofilter.FilterChange{
+		Action:       cuckoofilter.FilterChangeInserted,
+		Fingerprints: []string{"credential-2", "credential-3"},
+		Count:        3,
+	}, requireFilterChange(t, tx))
+
+	tx, err = sim.Submit(admin, "Delete", "", "credential-1")
+	require.NoError(t, err)
+	require.Equal(t, cuckoofilter.FilterChange{
+		Action:       cuckoofilter.FilterChangeDeleted,
+		Fingerprints: []string{"credential-1"},
+		Count:        2,
+	}, requireFilterChange(t, tx))
+
+	// Only the fingerprints actually deleted are reported
+	tx, err = sim.Submit(admin, "BatchDelete", "", `["credential-2","unknown"]`, "false")
+	require.NoError(t, err)
+	require.Equal(t, cuckoofilter.FilterChange{
+		Action:       cuckoofilter.FilterChangeDeleted,
+		Fingerprints: []string{"credential-2"},
+		Count:        1,
+	}, requireFilterChange(t, tx))
+
+	// Batches that change nothing emit no event
+	tx, err = sim.Submit(admin, "BatchDelete", "", `["unknown"]`, "true")
+	require.NoError(t, err)
+	require.Nil(t, tx.Event)
+}
+
+func TestFilterChangedEvents_NamedFilter(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	_, err := sim.Submit(newRegistryAdmin(t), "Init", "employees", "100", "4", "0")
+	require.NoError(t, err)
+
+	tx, err := sim.Submit(admin, "Insert", "employees", "credential-1")
+	require.NoError(t, err)
+	change := requireFilterChange(t, tx)
+	require.Equal(t, "employees", change.FilterID)
+	require.Equal(t, uint(1), change.Count)
+}
@@ -0,0 +1,229 @@
+package cuckoofilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"sort"
+)
+
+// DefaultFilterID selects the registry's original filter, stored under CuckooFilterState.
+// Any other filter ID names an independent filter, e.g. per credential type or per issuer.
+const DefaultFilterID = ""
+
+// namedFilterObjectType is the composite key prefix of the named filters, namedFilter~<filter ID>.
+// Named filters always store their whole state; delta persistence applies to the default filter only.
+const namedFilterObjectType = "namedFilter"
+
+// FilterInfo describes a revocation filter on the ledger
+type FilterInfo struct {
+	ID       string `json:"id"` // Empty for the default filter
+	Count    uint   `json:"count"`
+	Capacity uint   `json:"capacity"`
+}
+
+// ListFilters returns the default filter, if initialized, and the named filters in key order
+func (s *SmartContract) ListFilters(ctx contractapi.TransactionContextInterface) ([]*FilterInfo, error) {
+	filters := []*FilterInfo{}
+	exists, err := filterExists(ctx, DefaultFilterID)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		filter, err := s.LoadFilterState(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error loading filter state: %v", err)
+		}
+		filters = append(filters, &FilterInfo{ID: DefaultFilterID, Count: filter.Count, Capacity: filter.Capacity()})
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(namedFilterObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("error reading named filters: %v", err)
+	}
+	defer iterator.Close()
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error reading named filters: %v", err)
+		}
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		filter, err := decodeNamedFilter(ctx, attributes[0], entry.Value)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, &FilterInfo{ID: attributes[0], Count: filter.Count, Capacity: filter.Capacity()})
+	}
+	return filters, nil
+}
+
+// MultiFilterBatchLookup checks fingerprints against several filters in one evaluation, e.g. for a
+// presentation holding credentials of several issuers. Requests map filter IDs, empty for the default
+// filter, to fingerprints; every filter is loaded once and the results are grouped the same way.
+// The batch limit applies to the fingerprints of all filters together.
+func (s *SmartContract) MultiFilterBatchLookup(ctx contractapi.TransactionContextInterface, requests map[string][]string) (map[string]map[string]bool, error) {
+	total := 0
+	for _, dataItems := range requests {
+		total += len(dataItems)
+	}
+	if err := checkBatchSize(ctx, total); err != nil {
+		return nil, err
+	}
+
+	filterIDs := make([]string, 0, len(requests))
+	for filterID := range requests {
+		filterIDs = append(filterIDs, filterID)
+	}
+	// Read the filters in a fixed order, so every peer builds the same read set
+	sort.Strings(filterIDs)
+
+	results := make(map[string]map[string]bool, len(requests))
+	for _, filterID := range filterIDs {
+		filter, err := s.loadFilter(ctx, filterID)
+		if err != nil {
+			return nil, fmt.Errorf("error loading filter '%s': %v", filterID, err)
+		}
+		results[filterID] = make(map[string]bool, len(requests[filterID]))
+		for _, data := range requests[filterID] {
+			results[filterID][data] = filter.Lookup([]byte(data))
+		}
+	}
+	return results, nil
+}
+
+// filterExists reports whether the default filter or a named filter was initialized, without decoding it
+func filterExists(ctx contractapi.TransactionContextInterface, filterID string) (bool, error) {
+	if filterID != DefaultFilterID {
+		key, err := namedFilterKey(ctx, filterID)
+		if err != nil {
+			return false, err
+		}
+		filterJSON, err := ctx.GetStub().GetState(key)
+		if err != nil {
+			return false, fmt.Errorf("error loading filter '%s': %v", filterID, err)
+		}
+		return filterJSON != nil, nil
+	}
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return false, err
+	}
+	defaultKey := filterStateKey
+	if config.StateLayout != StateLayoutSingle {
+		defaultKey = filterStateHeaderKey
+	}
+	defaultJSON, err := getCollectionState(ctx, config.PrivateCollection, defaultKey)
+	if err != nil {
+		return false, fmt.Errorf("error loading filter state: %v", err)
+	}
+	return defaultJSON != nil, nil
+}
+
+// loadFilter retrieves the default filter or a named filter
+func (s *SmartContract) loadFilter(ctx contractapi.TransactionContextInterface, filterID string) (*Filter, error) {
+	if filterID == DefaultFilterID {
+		return s.LoadFilterState(ctx)
+	}
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := loadNamedFilter(ctx, filterID)
+	if err != nil {
+		return nil, err
+	}
+	filter.growLoadFactor = config.ResizeLoadFactor
+	filter.growBuckets = config.MaxFilterBuckets
+	return filter, nil
+}
+
+// loadNamedFilter retrieves a named filter
+func loadNamedFilter(ctx contractapi.TransactionContextInterface, filterID string) (*Filter, error) {
+	key, err := namedFilterKey(ctx, filterID)
+	if err != nil {
+		return nil, err
+	}
+	storedHash, err := storedStateHash(ctx, "", namedFilterStateName(filterID))
+	if err != nil {
+		return nil, err
+	}
+	filterJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("error loading filter '%s': %v", filterID, err)
+	}
+	if filterJSON == nil {
+		return nil, fmt.Errorf("%w: filter '%s' not found", ErrNotFound, filterID)
+	}
+	if err := checkStateHash(storedHash, filterJSON); err != nil {
+		return nil, err
+	}
+	if filter := filterCache.get(storedHash); filter != nil {
+		return filter, nil
+	}
+	var filter Filter
+	if err := json.Unmarshal(filterJSON, &filter); err != nil {
+		return nil, fmt.Errorf("error decoding filter '%s': %v", filterID, err)
+	}
+	filterCache.put(storedHash, &filter)
+	return &filter, nil
+}
+
+// saveFilter saves the default filter or a named filter
+func (s *SmartContract) saveFilter(ctx contractapi.TransactionContextInterface, filterID string, filter *Filter) error {
+	if filterID == DefaultFilterID {
+		return s.SaveFilterState(ctx, filter)
+	}
+	if err := checkWritable(ctx); err != nil {
+		return err
+	}
+	key, err := namedFilterKey(ctx, filterID)
+	if err != nil {
+		return err
+	}
+	filterJSON, err := json.Marshal(filter)
+	if err != nil {
+		return err
+	}
+	if err := putMerkleRoot(ctx, namedFilterStateName(filterID), filter); err != nil {
+		return err
+	}
+	if err := putFilterEpoch(ctx, namedFilterStateName(filterID), filter); err != nil {
+		return err
+	}
+	return putStateWithHash(ctx, key, namedFilterStateName(filterID), filterJSON)
+}
+
+func decodeNamedFilter(ctx contractapi.TransactionContextInterface, filterID string, filterJSON []byte) (*Filter, error) {
+	if err := verifyStateHash(ctx, namedFilterStateName(filterID), filterJSON); err != nil {
+		return nil, err
+	}
+	var filter Filter
+	if err := json.Unmarshal(filterJSON, &filter); err != nil {
+		return nil, fmt.Errorf("error decoding filter '%s': %v", filterID, err)
+	}
+	return &filter, nil
+}
+
+func namedFilterKey(ctx contractapi.TransactionContextInterface, filterID string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(namedFilterObjectType, []string{filterID})
+	if err != nil {
+		return "", fmt.Errorf("error creating filter key: %v", err)
+	}
+	return key, nil
+}
+
+// namedFilterStateName names a named filter in its state hash key
+func namedFilterStateName(filterID string) string {
+	return namedFilterObjectType + "-" + filterID
+}
+
+// filterDetails describes the filter of a change in lifecycle events
+func filterDetails(filterID string) string {
+	if filterID == DefaultFilterID {
+		return ""
+	}
+	return "filter " + filterID
+}
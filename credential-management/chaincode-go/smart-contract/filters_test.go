@@ -0,0 +1,97 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNamedFilters(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	_, err := sim.Submit(newRegistryAdmin(t), "Init", "diplomas", "100", "4", "0")
+	require.NoError(t, err)
+	_, err = sim.Submit(newRegistryAdmin(t), "Init", "licenses", "200", "4", "0")
+	require.NoError(t, err)
+
+	_, err = sim.Submit(admin, "Insert", "diplomas", "credential-1")
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "BatchInsert", "licenses", `["credential-2","credential-3"]`)
+	require.NoError(t, err)
+
+	// Every filter only holds its own revocations
+	for filterID, expected := range map[string]map[string]bool{
+		"":         {"credential-1": false, "credential-2": false},
+		"diplomas": {"credential-1": true, "credential-2": false},
+		"licenses": {"credential-1": false, "credential-2": true, "credential-3": true},
+	} {
+		for data, revoked := range expected {
+			found, err := sim.Evaluate(admin, "Lookup", filterID, data)
+			require.NoError(t, err)
+			require.Equal(t, revoked, string(found) == "true", "%s in filter '%s'", data, filterID)
+		}
+	}
+
+	_, err = sim.Submit(admin, "Delete", "licenses", "credential-2")
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "Delete", "diplomas", "credential-3")
+	require.Error(t, err)
+	results, err := sim.Evaluate(admin, "BatchLookup", "licenses", `["credential-2","credential-3"]`)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"credential-2":false,"credential-3":true}`, string(results))
+
+	listJSON, err := sim.Evaluate(admin, "ListFilters")
+	require.NoError(t, err)
+	var filters []*cuckoofilter.FilterInfo
+	require.NoError(t, json.Unmarshal(listJSON, &filters))
+	require.Len(t, filters, 3)
+	require.Equal(t, cuckoofilter.DefaultFilterID, filters[0].ID)
+	require.Equal(t, "diplomas", filters[1].ID)
+	require.Equal(t, uint(1), filters[1].Count)
+	require.Equal(t, "licenses", filters[2].ID)
+	require.Equal(t, uint(1), filters[2].Count)
+}
+
+func TestNamedFilters_Unknown(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	_, err := sim.Submit(admin, "Insert", "diplomas", "credential-1")
+	require.Error(t, err)
+	_, err = sim.Evaluate(admin, "Lookup", "diplomas", "credential-1")
+	require.Error(t, err)
+}
+
+func TestMultiFilterBatchLookup(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	_, err := sim.Submit(newRegistryAdmin(t), "Init", "diplomas", "100", "4", "0")
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "Insert", "", "credential-1")
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "Insert", "diplomas", "credential-2")
+	require.NoError(t, err)
+
+	resultsJSON, err := sim.Evaluate(admin, "MultiFilterBatchLookup", `{"":["credential-1","credential-2"],"diplomas":["credential-2"]}`)
+	require.NoError(t, err)
+	var results map[string]map[string]bool
+	require.NoError(t, json.Unmarshal(resultsJSON, &results))
+	require.Equal(t, map[string]map[string]bool{
+		"":         {"credential-1": true, "credential-2": false},
+		"diplomas": {"credential-2": true},
+	}, results)
+
+	_, err = sim.Evaluate(admin, "MultiFilterBatchLookup", `{"missing":["credential-1"]}`)
+	require.Error(t, err)
+}
+
+func TestMultiFilterBatchLookup_BatchLimit(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	_, err := sim.Submit(newRegistryAdmin(t), "Init", "diplomas", "100", "4", "0")
+	require.NoError(t, err)
+	_, err = sim.Submit(newRegistryAdmin(t), "UpdateRegistryConfig", "2")
+	require.NoError(t, err)
+
+	// Each filter stays within the limit, the request as a whole does not
+	_, err = sim.Evaluate(admin, "MultiFilterBatchLookup", `{"":["a","b"],"diplomas":["c"]}`)
+	require.Error(t, err)
+	_, err = sim.Evaluate(admin, "MultiFilterBatchLookup", `{"":["a"],"diplomas":["c"]}`)
+	require.NoError(t, err)
+}
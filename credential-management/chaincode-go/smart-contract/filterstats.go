@@ -0,0 +1,71 @@
+package cuckoofilter
+
+import (
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"math"
+)
+
+// FilterStats describes the fill level of a filter, so operators can tell when it needs a resize
+type FilterStats struct {
+	FilterID          string  `json:"filterID"`
+	Count             uint    `json:"count"`  // Count kept by the filter
+	Stored            uint    `json:"stored"` // Occupied slots, exact after cuckoo kicking
+	Capacity          uint    `json:"capacity"`
+	Buckets           uint    `json:"buckets"`
+	BucketSize        uint    `json:"bucketSize"`
+	FingerprintSize   uint    `json:"fingerprintSize"`
+	LoadFactor        float64 `json:"loadFactor"`
+	Occupancy         []uint  `json:"occupancy"`         // Occupancy[n] is the number of buckets holding n fingerprints
+	FalsePositiveRate float64 `json:"falsePositiveRate"` // Estimated at the current load factor
+	ResizeRecommended bool    `json:"resizeRecommended"` // Load factor reached the configured ResizeLoadFactor
+}
+
+// GetFilterStats returns load factor, count, capacity, bucket occupancy histogram and estimated false
+// positive rate of the default filter or the named filter filterID, computed from the stored filter
+func (s *SmartContract) GetFilterStats(ctx contractapi.TransactionContextInterface, filterID string) (*FilterStats, error) {
+	filter, err := s.loadFilter(ctx, filterID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading filter state: %v", err)
+	}
+
+	bucketSize := filter.bucketSize()
+	stats := &FilterStats{
+		FilterID:        filterID,
+		Count:           filter.Count,
+		Stored:          uint(filter.storedFingerprints()),
+		Buckets:         uint(len(filter.Buckets)),
+		BucketSize:      bucketSize,
+		FingerprintSize: filter.fingerprintSize(),
+		LoadFactor:      filter.LoadFactor(),
+		Occupancy:       make([]uint, bucketSize+1),
+	}
+	stats.Capacity = stats.Buckets * bucketSize
+	for _, b := range filter.Buckets {
+		occupied := 0
+		if b != nil {
+			for _, fp := range b.Data {
+				if len(fp) != 0 {
+					occupied++
+				}
+			}
+		}
+		if occupied < len(stats.Occupancy) {
+			stats.Occupancy[occupied]++
+		}
+	}
+	if stats.Capacity == 0 {
+		stats.LoadFactor = 0
+	}
+	stats.FalsePositiveRate = falsePositiveRate(bucketSize, stats.FingerprintSize, stats.LoadFactor)
+	stats.ResizeRecommended = stats.LoadFactor >= filter.resizeLoadFactor()
+	return stats, nil
+}
+
+// falsePositiveRate estimates the false positive rate of a lookup: it compares against the fingerprints
+// in two buckets, 2*bucketSize*loadFactor on average, each of which matches with probability 2^-bits
+func falsePositiveRate(bucketSize uint, fingerprintSize uint, loadFactor float64) float64 {
+	comparisons := 2 * float64(bucketSize) * loadFactor
+	match := math.Ldexp(1, -8*int(fingerprintSize))
+	return -math.Expm1(comparisons * math.Log1p(-match))
+}
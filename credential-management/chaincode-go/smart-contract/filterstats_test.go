@@ -0,0 +1,56 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestGetFilterStats(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	_, err := sim.Submit(newRegistryAdmin(t), "Init", "small", "4", "4", "1")
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "BatchInsert", "small", `["credential-1","credential-2","credential-3","credential-4"]`)
+	require.NoError(t, err)
+
+	statsJSON, err := sim.Evaluate(admin, "GetFilterStats", "small")
+	require.NoError(t, err)
+	var stats cuckoofilter.FilterStats
+	require.NoError(t, json.Unmarshal(statsJSON, &stats))
+	require.Equal(t, "small", stats.FilterID)
+	require.Equal(t, uint(4), stats.Count)
+	require.Equal(t, uint(4), stats.Stored)
+	require.Equal(t, stats.Buckets*stats.BucketSize, stats.Capacity)
+	require.Equal(t, uint(1), stats.FingerprintSize)
+	require.InDelta(t, float64(stats.Stored)/float64(stats.Capacity), stats.LoadFactor, 1e-9)
+	require.Len(t, stats.Occupancy, int(stats.BucketSize)+1)
+
+	// The histogram accounts for every bucket and every fingerprint
+	var buckets, fingerprints uint
+	for occupied, n := range stats.Occupancy {
+		buckets += n
+		fingerprints += uint(occupied) * n
+	}
+	require.Equal(t, stats.Buckets, buckets)
+	require.Equal(t, stats.Stored, fingerprints)
+
+	// One-byte fingerprints compare against 2*4*loadFactor fingerprints at 1/256 each
+	require.InDelta(t, 8*stats.LoadFactor/256, stats.FalsePositiveRate, 0.01)
+	require.Equal(t, stats.LoadFactor >= cuckoofilter.ResizeLoadFactor, stats.ResizeRecommended)
+}
+
+func TestGetFilterStats_EmptyDefaultFilter(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	statsJSON, err := sim.Evaluate(admin, "GetFilterStats", "")
+	require.NoError(t, err)
+	var stats cuckoofilter.FilterStats
+	require.NoError(t, json.Unmarshal(statsJSON, &stats))
+	require.Equal(t, uint(0), stats.Stored)
+	require.Equal(t, stats.Buckets, stats.Occupancy[0])
+	require.Zero(t, stats.FalsePositiveRate)
+	require.False(t, stats.ResizeRecommended)
+
+	_, err = sim.Evaluate(admin, "GetFilterStats", "unknown")
+	require.Error(t, err)
+}
@@ -0,0 +1,112 @@
+package cuckoofilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/pherbke/credential-management/chaincode-go/core"
+)
+
+// FingerprintV1 identifies version 1 of the credential fingerprint derivation, see core.FingerprintV1.
+// Wallets in other languages must check themselves against testdata/fingerprint_vectors.json.
+const FingerprintV1 = core.FingerprintV1
+
+// fingerprintV1Length is the number of digest bytes kept by FingerprintV1
+const fingerprintV1Length = core.FingerprintV1Length
+
+// CredentialStatusType is the credentialStatus type of credentials tracked by the revocation registry
+const CredentialStatusType = "CuckooFilterRevocationStatus"
+
+// CredentialStatus tells verifiers which registry entry to look up for a credential
+type CredentialStatus struct {
+	ID                   string `json:"id"`
+	Type                 string `json:"type"`
+	Fingerprint          string `json:"fingerprint"`
+	FingerprintAlgorithm string `json:"fingerprintAlgorithm"`
+	Normalizer           string `json:"normalizer"` // Set for JWT credentials, see CredentialNormalizer
+}
+
+// ComputeFingerprint derives the registry fingerprint of a credential with the given algorithm
+func ComputeFingerprint(algorithm string, issuer string, credentialID string) (string, error) {
+	return core.ComputeFingerprint(algorithm, issuer, credentialID)
+}
+
+func normalizeFingerprintInput(value string) string {
+	return core.NormalizeFingerprintInput(value)
+}
+
+// NewCredentialStatus returns the credentialStatus entry for a credential using the current fingerprint algorithm
+func NewCredentialStatus(issuer string, credentialID string) (*CredentialStatus, error) {
+	fingerprint, err := ComputeFingerprint(FingerprintV1, issuer, credentialID)
+	if err != nil {
+		return nil, err
+	}
+	return &CredentialStatus{
+		ID:                   credentialID + "#status",
+		Type:                 CredentialStatusType,
+		Fingerprint:          fingerprint,
+		FingerprintAlgorithm: FingerprintV1,
+	}, nil
+}
+
+// VerifyCredentialStatus recomputes the fingerprint of a credential and checks it matches its credentialStatus
+func VerifyCredentialStatus(credential *VerifiableCredential) error {
+	status := credential.CredentialStatus
+	if status == nil {
+		return fmt.Errorf("credential %s has no credentialStatus", credential.ID)
+	}
+	fingerprint, err := ComputeFingerprint(status.FingerprintAlgorithm, credential.Issuer, credential.ID)
+	if err != nil {
+		return err
+	}
+	if fingerprint != status.Fingerprint {
+		return fmt.Errorf("credentialStatus fingerprint %s does not match computed fingerprint %s", status.Fingerprint, fingerprint)
+	}
+	return nil
+}
+
+// FingerprintVector is one test vector shared with the wallet implementations.
+// External implementations report their results in the same format.
+type FingerprintVector struct {
+	Description  string `json:"description,omitempty"`
+	Algorithm    string `json:"algorithm"`
+	Issuer       string `json:"issuer"`
+	CredentialID string `json:"credentialId"`
+	Fingerprint  string `json:"fingerprint"`
+}
+
+// FingerprintMismatch is a vector for which the reported fingerprint differs from this implementation
+type FingerprintMismatch struct {
+	Vector   FingerprintVector
+	Expected string
+	Err      error // Set when the fingerprint could not be computed, e.g. for an unknown algorithm
+}
+
+func (m FingerprintMismatch) String() string {
+	if m.Err != nil {
+		return fmt.Sprintf("%q (%s): %v", m.Vector.CredentialID, m.Vector.Algorithm, m.Err)
+	}
+	return fmt.Sprintf("%q (%s): got %s, expected %s", m.Vector.CredentialID, m.Vector.Algorithm, m.Vector.Fingerprint, m.Expected)
+}
+
+// CheckFingerprintVectors recomputes every vector and returns those whose fingerprint differs
+func CheckFingerprintVectors(vectors []FingerprintVector) []FingerprintMismatch {
+	var mismatches []FingerprintMismatch
+	for _, vector := range vectors {
+		expected, err := ComputeFingerprint(vector.Algorithm, vector.Issuer, vector.CredentialID)
+		if err != nil || expected != vector.Fingerprint {
+			mismatches = append(mismatches, FingerprintMismatch{Vector: vector, Expected: expected, Err: err})
+		}
+	}
+	return mismatches
+}
+
+// ReadFingerprintVectors decodes a JSON array of fingerprint vectors
+func ReadFingerprintVectors(r io.Reader) ([]FingerprintVector, error) {
+	var vectors []FingerprintVector
+	if err := json.NewDecoder(r).Decode(&vectors); err != nil {
+		return nil, fmt.Errorf("error decoding fingerprint vectors: %v", err)
+	}
+	return vectors, nil
+}
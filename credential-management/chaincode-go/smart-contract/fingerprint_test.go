@@ -0,0 +1,61 @@
+package cuckoofilter_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+func loadFingerprintVectors(t *testing.T) []cuckoofilter.FingerprintVector {
+	file, err := os.Open("testdata/fingerprint_vectors.json")
+	require.NoError(t, err)
+	defer file.Close()
+
+	vectors, err := cuckoofilter.ReadFingerprintVectors(file)
+	require.NoError(t, err)
+	require.NotEmpty(t, vectors)
+	return vectors
+}
+
+func TestComputeFingerprint_Vectors(t *testing.T) {
+	for _, vector := range loadFingerprintVectors(t) {
+		fingerprint, err := cuckoofilter.ComputeFingerprint(vector.Algorithm, vector.Issuer, vector.CredentialID)
+		require.NoError(t, err, vector.Description)
+		require.Equal(t, vector.Fingerprint, fingerprint, vector.Description)
+	}
+	require.Empty(t, cuckoofilter.CheckFingerprintVectors(loadFingerprintVectors(t)))
+}
+
+func TestComputeFingerprint_UnknownAlgorithm(t *testing.T) {
+	_, err := cuckoofilter.ComputeFingerprint("cm-fingerprint-v0", "did:example:issuer", "urn:example:1")
+	require.Error(t, err)
+}
+
+func TestCheckFingerprintVectors_ReportsMismatches(t *testing.T) {
+	vectors := loadFingerprintVectors(t)
+	vectors[0].Fingerprint = "00000000000000000000000000000000"
+	vectors[1].Algorithm = "sha1"
+
+	mismatches := cuckoofilter.CheckFingerprintVectors(vectors)
+	require.Len(t, mismatches, 2)
+	require.NoError(t, mismatches[0].Err)
+	require.Error(t, mismatches[1].Err)
+}
+
+func TestCreateAndSignCredential_CredentialStatus(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	credential, err := cuckoofilter.CreateAndSignCredential("did:example:issuer", privateKey, "did:example:holder")
+	require.NoError(t, err)
+	require.NotNil(t, credential.CredentialStatus)
+	require.Equal(t, cuckoofilter.FingerprintV1, credential.CredentialStatus.FingerprintAlgorithm)
+	require.NoError(t, cuckoofilter.VerifyCredentialStatus(credential))
+
+	credential.ID = "http://example.edu/credentials/9999"
+	require.Error(t, cuckoofilter.VerifyCredentialStatus(credential))
+}
@@ -0,0 +1,103 @@
+package cuckoofilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"strings"
+	"time"
+)
+
+// registryFreezeKey is the ledger key holding the freeze state of the registry
+const registryFreezeKey = "RegistryFreeze"
+
+// RegistryFreezeChangedEvent is the name of the chaincode event emitted when the registry is frozen or unfrozen.
+// Its payload is the new RegistryFreeze as JSON.
+const RegistryFreezeChangedEvent = "RegistryFreezeChanged"
+
+// RegistryFrozenErrorCode prefixes the error returned by write transactions while the registry is frozen.
+// Clients should queue such writes and retry them after the registry is unfrozen.
+const RegistryFrozenErrorCode = "REGISTRY_FROZEN"
+
+// RegistryFreeze is the freeze state of the registry. While frozen, every write transaction fails
+// with RegistryFrozenErrorCode, reads keep working.
+type RegistryFreeze struct {
+	Frozen bool      `json:"frozen"`
+	Reason string    `json:"reason"`
+	Since  time.Time `json:"since"` // Timestamp of the transaction that last changed the state
+}
+
+// IsRegistryFrozen reports whether an error returned by a transaction was caused by a frozen registry
+func IsRegistryFrozen(err error) bool {
+	return err != nil && strings.Contains(err.Error(), RegistryFrozenErrorCode)
+}
+
+// FreezeRegistry blocks all writes to the registry, e.g. during incident response or a migration window.
+// Registry admins only.
+func (s *SmartContract) FreezeRegistry(ctx contractapi.TransactionContextInterface, reason string) (*RegistryFreeze, error) {
+	if err := checkRegistryAdmin(ctx, "freeze the registry"); err != nil {
+		return nil, err
+	}
+	return setRegistryFreeze(ctx, true, reason)
+}
+
+// UnfreezeRegistry allows writes to the registry again. Registry admins only.
+func (s *SmartContract) UnfreezeRegistry(ctx contractapi.TransactionContextInterface) (*RegistryFreeze, error) {
+	if err := checkRegistryAdmin(ctx, "unfreeze the registry"); err != nil {
+		return nil, err
+	}
+	return setRegistryFreeze(ctx, false, "")
+}
+
+// GetRegistryFreeze returns the freeze state of the registry
+func (s *SmartContract) GetRegistryFreeze(ctx contractapi.TransactionContextInterface) (*RegistryFreeze, error) {
+	return loadRegistryFreeze(ctx)
+}
+
+func setRegistryFreeze(ctx contractapi.TransactionContextInterface, frozen bool, reason string) (*RegistryFreeze, error) {
+	since, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	freeze := &RegistryFreeze{Frozen: frozen, Reason: reason, Since: since}
+	freezeJSON, err := json.Marshal(freeze)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.GetStub().PutState(registryFreezeKey, freezeJSON); err != nil {
+		return nil, fmt.Errorf("error saving registry freeze: %v", err)
+	}
+	if err := ctx.GetStub().SetEvent(RegistryFreezeChangedEvent, freezeJSON); err != nil {
+		return nil, fmt.Errorf("error emitting %s event: %v", RegistryFreezeChangedEvent, err)
+	}
+	return freeze, nil
+}
+
+// loadRegistryFreeze retrieves the freeze state, an unset state means the registry is not frozen
+func loadRegistryFreeze(ctx contractapi.TransactionContextInterface) (*RegistryFreeze, error) {
+	freezeJSON, err := ctx.GetStub().GetState(registryFreezeKey)
+	if err != nil {
+		return nil, fmt.Errorf("error loading registry freeze: %v", err)
+	}
+	if freezeJSON == nil {
+		return &RegistryFreeze{}, nil
+	}
+
+	var freeze RegistryFreeze
+	if err := json.Unmarshal(freezeJSON, &freeze); err != nil {
+		return nil, fmt.Errorf("error decoding registry freeze: %v", err)
+	}
+	return &freeze, nil
+}
+
+// checkWritable fails with RegistryFrozenErrorCode while the registry is frozen. Every write transaction calls it first.
+func checkWritable(ctx contractapi.TransactionContextInterface) error {
+	freeze, err := loadRegistryFreeze(ctx)
+	if err != nil {
+		return err
+	}
+	if freeze.Frozen {
+		return fmt.Errorf("%s: registry is frozen since %s (%s), retry later", RegistryFrozenErrorCode, freeze.Since.Format(time.RFC3339), freeze.Reason)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestFreezeRegistry_BlocksWrites(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	_, err := sim.Submit(admin, "Insert", "", "credential-1")
+	require.NoError(t, err)
+
+	tx, err := sim.Submit(newRegistryAdmin(t), "FreezeRegistry", "key rotation")
+	require.NoError(t, err)
+	require.Equal(t, cuckoofilter.RegistryFreezeChangedEvent, tx.Event.EventName)
+	var freeze cuckoofilter.RegistryFreeze
+	require.NoError(t, json.Unmarshal(tx.Event.Payload, &freeze))
+	require.True(t, freeze.Frozen)
+	require.Equal(t, "key rotation", freeze.Reason)
+	require.Equal(t, tx.Timestamp, freeze.Since)
+
+	writes := [][]string{
+		{"Init", "", "100", "4", "0"},
+		{"Insert", "", "credential-2"},
+		{"BatchInsert", "", `["credential-2"]`},
+		{"Delete", "", "credential-1"},
+		{"BatchDelete", "", `["credential-1"]`, "true"},
+		{"UpdateRegistryConfig", "10"},
+		{"ParkCredential", "credential-2"},
+		{"InitShards", "2", "8", "100", "4"},
+	}
+	for _, write := range writes {
+		_, err := sim.Submit(admin, write[0], write[1:]...)
+		require.Error(t, err, write[0])
+		require.True(t, cuckoofilter.IsRegistryFrozen(err), write[0])
+	}
+
+	// Reads keep working
+	revoked, err := sim.Evaluate(admin, "Lookup", "", "credential-1")
+	require.NoError(t, err)
+	require.Equal(t, "true", string(revoked))
+	requireStatus(t, sim, admin, "credential-2", cuckoofilter.CredentialStatusActive)
+}
+
+func TestUnfreezeRegistry(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	registryAdmin := newRegistryAdmin(t)
+	_, err := sim.Submit(registryAdmin, "FreezeRegistry", "migration")
+	require.NoError(t, err)
+	_, err = sim.Submit(registryAdmin, "UnfreezeRegistry")
+	require.NoError(t, err)
+
+	freezeJSON, err := sim.Evaluate(admin, "GetRegistryFreeze")
+	require.NoError(t, err)
+	var freeze cuckoofilter.RegistryFreeze
+	require.NoError(t, json.Unmarshal(freezeJSON, &freeze))
+	require.False(t, freeze.Frozen)
+
+	_, err = sim.Submit(admin, "Insert", "", "credential-1")
+	require.NoError(t, err)
+}
+
+func TestFreezeRegistry_AdminsOnly(t *testing.T) {
+	sim, client := newRegistrySimulator(t)
+	_, err := sim.Submit(client, "FreezeRegistry", "denial of service")
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+
+	_, err = sim.Submit(newRegistryAdmin(t), "FreezeRegistry", "incident")
+	require.NoError(t, err)
+	_, err = sim.Submit(client, "UnfreezeRegistry")
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+	_, err = sim.Submit(client, "Insert", "", "credential-1")
+	require.True(t, cuckoofilter.IsRegistryFrozen(err))
+}
+
+func TestIsRegistryFrozen(t *testing.T) {
+	require.False(t, cuckoofilter.IsRegistryFrozen(nil))
+	require.False(t, cuckoofilter.IsRegistryFrozen(json.Unmarshal([]byte("{"), &struct{}{})))
+}
@@ -0,0 +1,123 @@
+package cuckoofilter_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
+	"github.com/pherbke/credential-management/chaincode-go/mocks"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"testing"
+	"time"
+)
+
+// The fuzz targets below replay their corpus in testdata/fuzz on every go test run. Inputs that
+// crashed a target once are kept there as regression fixtures; run go test -fuzz=<target> to search
+// for new ones and commit the failing inputs go test writes to the corpus together with the fix.
+
+// FuzzLoadFilterState loads arbitrary filter states and runs lookups, inserts and deletes on the
+// states that load, which must fail with an error instead of panicking or looping
+func FuzzLoadFilterState(f *testing.F) {
+	valid, err := json.Marshal(cuckoofilter.NewFilter(8, 2, 2))
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(valid)
+
+	f.Fuzz(func(t *testing.T, state []byte) {
+		mockStub := new(mocks.MockChaincodeStubInterface)
+		mockRegistryDefaults(mockStub)
+		mockStub.On("GetState", "CuckooFilterState").Return(state, nil)
+		mockTxContext := new(mocks.MockTransactionContext)
+		mockTxContext.On("GetStub").Return(mockStub)
+		mockTxContext.Stub = mockStub
+
+		filter, err := new(cuckoofilter.SmartContract).LoadFilterState(mockTxContext)
+		if err != nil {
+			return
+		}
+		for _, data := range []string{"credential-1", "credential-2", ""} {
+			filter.Lookup([]byte(data))
+			filter.Insert([]byte(data))
+			filter.Lookup([]byte(data))
+			filter.Delete([]byte(data))
+		}
+		if _, err := json.Marshal(filter); err != nil {
+			t.Fatalf("loaded filter does not marshal: %v", err)
+		}
+	})
+}
+
+// FuzzCredentialToken runs arbitrary tokens through the credential verification pipeline:
+// fingerprinting with the built-in normalizers and VerifyingCredential
+func FuzzCredentialToken(f *testing.F) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		f.Fatal(err)
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodES256, credentialClaims(1700000000, "proof")).SignedString(privateKey)
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(token)
+	f.Add("eyJhbGciOiJub25lIn0.eyJ2YyI6e319.")
+
+	contract := &cuckoofilter.StakeholderManagementContract{Clock: clock.NewManual(time.Unix(1700000000, 0))}
+	f.Fuzz(func(t *testing.T, token string) {
+		for _, name := range []string{cuckoofilter.NormalizerIssuerJTI, cuckoofilter.NormalizerCanonicalClaims} {
+			normalizer, err := cuckoofilter.LookupNormalizer(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cuckoofilter.NewTokenCredentialStatus(normalizer, token)
+		}
+		contract.VerifyingCredential(newStakeholderContext(), token, "verifier", "did:key:holder", "did:key:issuer")
+	})
+}
+
+// FuzzCredentialClaims decodes arbitrary JWT payloads into credentials. It covers the claim handling
+// behind VerifyingCredential, which fuzzed tokens rarely reach as their signatures do not verify.
+func FuzzCredentialClaims(f *testing.F) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		f.Fatal(err)
+	}
+	credential, err := cuckoofilter.CreateAndSignCredentialAt(time.Unix(1700000000, 0).UTC(), "did:key:issuer", privateKey, "did:key:holder", "")
+	if err != nil {
+		f.Fatal(err)
+	}
+	claims, err := credential.ToJWTClaims()
+	if err != nil {
+		f.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(payload)
+
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		claims := jwt.MapClaims{}
+		if err := json.Unmarshal(payload, &claims); err != nil {
+			return
+		}
+		var credential cuckoofilter.VerifiableCredential
+		if err := credential.FromJWTClaims(claims); err == nil {
+			if credential.CredentialStatus != nil {
+				cuckoofilter.VerifyCredentialStatus(&credential)
+			}
+			if _, err := credential.ToJWTClaims(); err != nil {
+				t.Fatalf("decoded credential does not encode: %v", err)
+			}
+		}
+		for _, name := range []string{cuckoofilter.NormalizerIssuerJTI, cuckoofilter.NormalizerCanonicalClaims} {
+			normalizer, err := cuckoofilter.LookupNormalizer(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			normalizer.Identity(claims)
+		}
+	})
+}
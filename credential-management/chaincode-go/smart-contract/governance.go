@@ -0,0 +1,382 @@
+package cuckoofilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"strconv"
+	"time"
+)
+
+// governanceProposalObjectType is the composite key prefix of governance proposals, governanceProposal~<id>
+const governanceProposalObjectType = "governanceProposal"
+
+// governanceMembersKey is the state key of the governance membership
+const governanceMembersKey = "GovernanceMembers"
+
+// Parameters a governance proposal can change, with the meaning of the proposal value
+const (
+	ProposalMaxBatchSize        = "maxBatchSize"        // RegistryConfig.MaxBatchSize, the batch quota
+	ProposalResizeLoadFactor    = "resizeLoadFactor"    // RegistryConfig.ResizeLoadFactor
+	ProposalAddTrustedIssuer    = "addTrustedIssuer"    // DID of the issuer to trust
+	ProposalRemoveTrustedIssuer = "removeTrustedIssuer" // DID of the issuer to remove from the trust registry
+)
+
+// Status values of a governance proposal
+const (
+	ProposalOpen     = "open"
+	ProposalApproved = "approved" // Reached the threshold, applied by ExecuteProposal
+	ProposalRejected = "rejected" // Can no longer reach the threshold
+	ProposalExecuted = "executed"
+)
+
+// ErrNotGovernanceMember is returned when a client outside the member organizations proposes, votes or executes
+var ErrNotGovernanceMember = fmt.Errorf("%w: only a governance member organization may take part in proposals", ErrUnauthorized)
+
+// GovernanceContract lets member organizations vote on registry parameter changes instead of relying
+// on a single admin. Every member MSP has one vote. The members are kept in the ledger state, see
+// SetGovernanceMembers.
+type GovernanceContract struct {
+	contractapi.Contract
+}
+
+// GovernanceMembers are the organizations that vote on proposals
+type GovernanceMembers struct {
+	Members   []string  `json:"members"`   // MSP IDs of the member organizations
+	Threshold int       `json:"threshold"` // Approvals a proposal needs, 0 for a majority of the members
+	UpdatedAt time.Time `json:"updatedAt"`
+	TxID      string    `json:"txId"`
+}
+
+// Proposal is a proposed parameter change and the votes of the member organizations on it
+type Proposal struct {
+	ID          string          `json:"id"` // ID of the transaction that proposed the change
+	Parameter   string          `json:"parameter"`
+	Value       string          `json:"value"`
+	Proposer    Inserter        `json:"proposer"`
+	Status      string          `json:"status"`
+	Votes       map[string]bool `json:"votes"` // Vote per member MSP, true for approval
+	ProposedAt  time.Time       `json:"proposedAt"`
+	ExecutedAt  time.Time       `json:"executedAt"`
+	ExecutionTx string          `json:"executionTx,omitempty" metadata:",optional"`
+}
+
+// Propose records a parameter change for the members to vote on. The proposing organization's vote
+// counts as an approval. The transaction ID becomes the proposal ID.
+func (s *GovernanceContract) Propose(ctx contractapi.TransactionContextInterface, parameter string, value string) (*Proposal, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	client, membership, err := s.checkMember(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkProposal(parameter, value); err != nil {
+		return nil, err
+	}
+	proposedAt, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	proposal := &Proposal{
+		ID:         ctx.GetStub().GetTxID(),
+		Parameter:  parameter,
+		Value:      value,
+		Proposer:   *client,
+		Status:     ProposalOpen,
+		Votes:      map[string]bool{client.MSPID: true},
+		ProposedAt: proposedAt,
+	}
+	s.tally(proposal, membership)
+	if err := saveProposal(ctx, proposal); err != nil {
+		return nil, err
+	}
+	return proposal, nil
+}
+
+// Vote records the vote of the client's organization on an open proposal, replacing an earlier one
+func (s *GovernanceContract) Vote(ctx contractapi.TransactionContextInterface, proposalID string, approve bool) (*Proposal, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	client, membership, err := s.checkMember(ctx)
+	if err != nil {
+		return nil, err
+	}
+	proposal, err := loadProposal(ctx, proposalID)
+	if err != nil {
+		return nil, err
+	}
+	if proposal.Status != ProposalOpen {
+		return nil, fmt.Errorf("proposal %s is already %s", proposalID, proposal.Status)
+	}
+
+	proposal.Votes[client.MSPID] = approve
+	s.tally(proposal, membership)
+	if err := saveProposal(ctx, proposal); err != nil {
+		return nil, err
+	}
+	return proposal, nil
+}
+
+// ExecuteProposal applies an approved proposal. Configuration changes go through the same validation
+// as UpdateRegistryConfig and emit a RegistryConfigChanged event.
+func (s *GovernanceContract) ExecuteProposal(ctx contractapi.TransactionContextInterface, proposalID string) (*Proposal, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	_, membership, err := s.checkMember(ctx)
+	if err != nil {
+		return nil, err
+	}
+	proposal, err := loadProposal(ctx, proposalID)
+	if err != nil {
+		return nil, err
+	}
+	if proposal.Status == ProposalOpen {
+		// The membership may have changed since the last vote
+		s.tally(proposal, membership)
+	}
+	if proposal.Status != ProposalApproved {
+		return nil, fmt.Errorf("proposal %s is %s, not approved", proposalID, proposal.Status)
+	}
+
+	if err := applyProposal(ctx, proposal); err != nil {
+		return nil, err
+	}
+	executedAt, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	proposal.Status = ProposalExecuted
+	proposal.ExecutedAt = executedAt
+	proposal.ExecutionTx = ctx.GetStub().GetTxID()
+	if err := saveProposal(ctx, proposal); err != nil {
+		return nil, err
+	}
+	return proposal, nil
+}
+
+// GetProposal returns a recorded proposal
+func (s *GovernanceContract) GetProposal(ctx contractapi.TransactionContextInterface, proposalID string) (*Proposal, error) {
+	return loadProposal(ctx, proposalID)
+}
+
+// SetGovernanceMembers replaces the member organizations and the approval threshold. Only registry
+// admins may change them. Open proposals are tallied against the new members when they are next voted
+// on or executed.
+func (s *GovernanceContract) SetGovernanceMembers(ctx contractapi.TransactionContextInterface, members []string, threshold int) (*GovernanceMembers, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	if err := checkRegistryAdmin(ctx, "change the governance members"); err != nil {
+		return nil, err
+	}
+	updatedAt, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	membership := &GovernanceMembers{Members: members, Threshold: threshold, UpdatedAt: updatedAt, TxID: ctx.GetStub().GetTxID()}
+	if err := membership.Validate(); err != nil {
+		return nil, err
+	}
+
+	membershipJSON, err := json.Marshal(membership)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.GetStub().PutState(governanceMembersKey, membershipJSON); err != nil {
+		return nil, fmt.Errorf("error saving governance members: %v", err)
+	}
+	return membership, nil
+}
+
+// GetGovernanceMembers returns the member organizations and the approval threshold
+func (s *GovernanceContract) GetGovernanceMembers(ctx contractapi.TransactionContextInterface) (*GovernanceMembers, error) {
+	return loadGovernanceMembers(ctx)
+}
+
+// Validate checks that the members are distinct MSP IDs and the threshold can be reached
+func (m *GovernanceMembers) Validate() error {
+	if len(m.Members) == 0 {
+		return fmt.Errorf("%w: governance needs at least one member", ErrInvalidArgument)
+	}
+	seen := map[string]bool{}
+	for _, mspID := range m.Members {
+		if mspID == "" || seen[mspID] {
+			return fmt.Errorf("%w: governance member %q is empty or listed twice", ErrInvalidArgument, mspID)
+		}
+		seen[mspID] = true
+	}
+	if m.Threshold < 0 || m.Threshold > len(m.Members) {
+		return fmt.Errorf("%w: governance threshold %d must be between 1 and the %d members", ErrInvalidArgument, m.Threshold, len(m.Members))
+	}
+	return nil
+}
+
+// threshold returns the number of approvals a proposal needs
+func (m *GovernanceMembers) threshold() int {
+	if m.Threshold == 0 {
+		return len(m.Members)/2 + 1
+	}
+	return m.Threshold
+}
+
+// loadGovernanceMembers returns the governance membership, failing if none has been set
+func loadGovernanceMembers(ctx contractapi.TransactionContextInterface) (*GovernanceMembers, error) {
+	membershipJSON, err := ctx.GetStub().GetState(governanceMembersKey)
+	if err != nil {
+		return nil, fmt.Errorf("error loading governance members: %v", err)
+	}
+	if membershipJSON == nil {
+		return nil, fmt.Errorf("%w: no governance members are configured", ErrFailedPrecondition)
+	}
+
+	var membership GovernanceMembers
+	if err := json.Unmarshal(membershipJSON, &membership); err != nil {
+		return nil, fmt.Errorf("%w: error decoding governance members: %v", ErrStateCorrupt, err)
+	}
+	return &membership, nil
+}
+
+// checkMember returns the client and the membership if the client's organization is a governance member
+func (s *GovernanceContract) checkMember(ctx contractapi.TransactionContextInterface) (*Inserter, *GovernanceMembers, error) {
+	membership, err := loadGovernanceMembers(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	client, _, err := clientInserter(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, mspID := range membership.Members {
+		if client.MSPID == mspID {
+			return client, membership, nil
+		}
+	}
+	return nil, nil, ErrNotGovernanceMember
+}
+
+// tally updates the status of an open proposal from the votes of the current members
+func (s *GovernanceContract) tally(proposal *Proposal, membership *GovernanceMembers) {
+	threshold := membership.threshold()
+	approvals, rejections := 0, 0
+	for _, mspID := range membership.Members {
+		approve, voted := proposal.Votes[mspID]
+		if !voted {
+			continue
+		}
+		if approve {
+			approvals++
+		} else {
+			rejections++
+		}
+	}
+	switch {
+	case approvals >= threshold:
+		proposal.Status = ProposalApproved
+	case len(membership.Members)-rejections < threshold:
+		proposal.Status = ProposalRejected
+	}
+}
+
+// checkProposal checks that a value is valid for the parameter
+func checkProposal(parameter string, value string) error {
+	switch parameter {
+	case ProposalMaxBatchSize, ProposalResizeLoadFactor:
+		config := DefaultRegistryConfig()
+		if err := setProposedValue(config, parameter, value); err != nil {
+			return err
+		}
+		return config.Validate()
+	case ProposalAddTrustedIssuer, ProposalRemoveTrustedIssuer:
+		if value == "" {
+			return fmt.Errorf("issuer DID must not be empty")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown governance parameter %q", parameter)
+	}
+}
+
+// setProposedValue sets a configuration parameter to the proposed value
+func setProposedValue(config *RegistryConfig, parameter string, value string) error {
+	switch parameter {
+	case ProposalMaxBatchSize:
+		maxBatchSize, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid maxBatchSize %q: %v", value, err)
+		}
+		config.MaxBatchSize = uint(maxBatchSize)
+	case ProposalResizeLoadFactor:
+		loadFactor, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid resizeLoadFactor %q: %v", value, err)
+		}
+		config.ResizeLoadFactor = loadFactor
+	default:
+		return fmt.Errorf("%s is not a configuration parameter", parameter)
+	}
+	return nil
+}
+
+// applyProposal makes the change of an approved proposal. Trusted issuers are recorded as added by
+// the proposer.
+func applyProposal(ctx contractapi.TransactionContextInterface, proposal *Proposal) error {
+	switch proposal.Parameter {
+	case ProposalAddTrustedIssuer:
+		_, err := addTrustedIssuer(ctx, proposal.Value, &proposal.Proposer)
+		return err
+	case ProposalRemoveTrustedIssuer:
+		return removeTrustedIssuer(ctx, proposal.Value)
+	}
+
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return err
+	}
+	config.Version++
+	if err := setProposedValue(config, proposal.Parameter, proposal.Value); err != nil {
+		return err
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	return saveRegistryConfig(ctx, config)
+}
+
+func saveProposal(ctx contractapi.TransactionContextInterface, proposal *Proposal) error {
+	key, err := ctx.GetStub().CreateCompositeKey(governanceProposalObjectType, []string{proposal.ID})
+	if err != nil {
+		return fmt.Errorf("error creating proposal key: %v", err)
+	}
+	proposalJSON, err := json.Marshal(proposal)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(key, proposalJSON)
+}
+
+func loadProposal(ctx contractapi.TransactionContextInterface, proposalID string) (*Proposal, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(governanceProposalObjectType, []string{proposalID})
+	if err != nil {
+		return nil, fmt.Errorf("error creating proposal key: %v", err)
+	}
+	proposalJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("error loading proposal: %v", err)
+	}
+	if proposalJSON == nil {
+		return nil, fmt.Errorf("%w: proposal %s not found", ErrNotFound, proposalID)
+	}
+
+	var proposal Proposal
+	if err := json.Unmarshal(proposalJSON, &proposal); err != nil {
+		return nil, fmt.Errorf("error decoding proposal: %v", err)
+	}
+	if proposal.Votes == nil {
+		proposal.Votes = map[string]bool{}
+	}
+	return &proposal, nil
+}
@@ -0,0 +1,159 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func newGovernanceSimulator(t *testing.T) (*simulator.Simulator, []*simulator.Identity) {
+	sim, err := simulator.New("credential-management", cuckoofilter.Contracts(nil)...)
+	require.NoError(t, err)
+	mspIDs := []string{"Org1MSP", "Org2MSP", "Org3MSP"}
+	membersJSON, err := json.Marshal(mspIDs)
+	require.NoError(t, err)
+	_, err = sim.Submit(newRegistryAdmin(t), "GovernanceContract:SetGovernanceMembers", string(membersJSON), "0")
+	require.NoError(t, err)
+
+	members := []*simulator.Identity{}
+	for _, mspID := range mspIDs {
+		member, err := simulator.NewIdentity(mspID, "member")
+		require.NoError(t, err)
+		members = append(members, member)
+	}
+	return sim, members
+}
+
+func requireProposal(t *testing.T, payload []byte) *cuckoofilter.Proposal {
+	var proposal cuckoofilter.Proposal
+	require.NoError(t, json.Unmarshal(payload, &proposal))
+	return &proposal
+}
+
+func TestGovernance_ExecuteConfigChange(t *testing.T) {
+	sim, members := newGovernanceSimulator(t)
+	tx, err := sim.Submit(members[0], "GovernanceContract:Propose", cuckoofilter.ProposalResizeLoadFactor, "0.5")
+	require.NoError(t, err)
+	proposal := requireProposal(t, tx.Payload)
+	require.Equal(t, cuckoofilter.ProposalOpen, proposal.Status)
+
+	// One approval of three members is not enough
+	_, err = sim.Submit(members[0], "GovernanceContract:ExecuteProposal", proposal.ID)
+	require.ErrorContains(t, err, "not approved")
+
+	tx, err = sim.Submit(members[1], "GovernanceContract:Vote", proposal.ID, "true")
+	require.NoError(t, err)
+	require.Equal(t, cuckoofilter.ProposalApproved, requireProposal(t, tx.Payload).Status)
+	_, err = sim.Submit(members[2], "GovernanceContract:Vote", proposal.ID, "false")
+	require.ErrorContains(t, err, "already approved")
+
+	tx, err = sim.Submit(members[2], "GovernanceContract:ExecuteProposal", proposal.ID)
+	require.NoError(t, err)
+	require.Equal(t, cuckoofilter.ProposalExecuted, requireProposal(t, tx.Payload).Status)
+	require.NotNil(t, tx.Event)
+	require.Equal(t, cuckoofilter.RegistryConfigChangedEvent, tx.Event.EventName)
+
+	configJSON, err := sim.Evaluate(members[0], "GetRegistryConfig")
+	require.NoError(t, err)
+	var config cuckoofilter.RegistryConfig
+	require.NoError(t, json.Unmarshal(configJSON, &config))
+	require.Equal(t, 0.5, config.ResizeLoadFactor)
+
+	_, err = sim.Submit(members[0], "GovernanceContract:ExecuteProposal", proposal.ID)
+	require.ErrorContains(t, err, "executed")
+}
+
+func TestGovernance_TrustedIssuer(t *testing.T) {
+	sim, members := newGovernanceSimulator(t)
+	tx, err := sim.Submit(members[1], "GovernanceContract:Propose", cuckoofilter.ProposalAddTrustedIssuer, "did:key:issuer")
+	require.NoError(t, err)
+	proposal := requireProposal(t, tx.Payload)
+	_, err = sim.Submit(members[2], "GovernanceContract:Vote", proposal.ID, "true")
+	require.NoError(t, err)
+	_, err = sim.Submit(members[0], "GovernanceContract:ExecuteProposal", proposal.ID)
+	require.NoError(t, err)
+
+	trusted, err := sim.Evaluate(members[0], "TrustRegistryContract:IsTrustedIssuer", "did:key:issuer")
+	require.NoError(t, err)
+	require.Equal(t, "true", string(trusted))
+}
+
+func TestGovernance_Rejected(t *testing.T) {
+	sim, members := newGovernanceSimulator(t)
+	tx, err := sim.Submit(members[0], "GovernanceContract:Propose", cuckoofilter.ProposalMaxBatchSize, "10")
+	require.NoError(t, err)
+	proposal := requireProposal(t, tx.Payload)
+	_, err = sim.Submit(members[1], "GovernanceContract:Vote", proposal.ID, "false")
+	require.NoError(t, err)
+	tx, err = sim.Submit(members[2], "GovernanceContract:Vote", proposal.ID, "false")
+	require.NoError(t, err)
+	require.Equal(t, cuckoofilter.ProposalRejected, requireProposal(t, tx.Payload).Status)
+
+	_, err = sim.Submit(members[0], "GovernanceContract:ExecuteProposal", proposal.ID)
+	require.ErrorContains(t, err, "not approved")
+}
+
+func TestGovernance_RejectsInvalidProposals(t *testing.T) {
+	sim, members := newGovernanceSimulator(t)
+	outsider, err := simulator.NewIdentity("Org4MSP", "outsider")
+	require.NoError(t, err)
+	_, err = sim.Submit(outsider, "GovernanceContract:Propose", cuckoofilter.ProposalMaxBatchSize, "10")
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+
+	for _, proposal := range [][]string{
+		{cuckoofilter.ProposalMaxBatchSize, "-1"},
+		{cuckoofilter.ProposalMaxBatchSize, "100000"},
+		{cuckoofilter.ProposalResizeLoadFactor, "1.5"},
+		{cuckoofilter.ProposalAddTrustedIssuer, ""},
+		{"normalizer", "issuer-jti"},
+	} {
+		_, err = sim.Submit(members[0], "GovernanceContract:Propose", proposal[0], proposal[1])
+		require.Error(t, err, proposal)
+	}
+
+	_, err = sim.Submit(members[0], "GovernanceContract:Vote", "unknown", "true")
+	require.ErrorContains(t, err, cuckoofilter.NotFoundErrorCode)
+}
+
+func TestGovernance_Members(t *testing.T) {
+	sim, err := simulator.New("credential-management", cuckoofilter.Contracts(nil)...)
+	require.NoError(t, err)
+	member, err := simulator.NewIdentity("Org1MSP", "member")
+	require.NoError(t, err)
+	admin := newRegistryAdmin(t)
+
+	// Without members nobody can propose
+	_, err = sim.Submit(member, "GovernanceContract:Propose", cuckoofilter.ProposalMaxBatchSize, "10")
+	require.ErrorContains(t, err, cuckoofilter.FailedPreconditionErrorCode)
+
+	// Only registry admins set valid members
+	_, err = sim.Submit(member, "GovernanceContract:SetGovernanceMembers", `["Org1MSP"]`, "0")
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+	for _, invalid := range [][]string{{`[]`, "0"}, {`["Org1MSP","Org1MSP"]`, "0"}, {`["Org1MSP"]`, "2"}, {`["Org1MSP"]`, "-1"}} {
+		_, err = sim.Submit(admin, "GovernanceContract:SetGovernanceMembers", invalid[0], invalid[1])
+		require.ErrorContains(t, err, cuckoofilter.InvalidArgumentErrorCode, invalid)
+	}
+	tx, err := sim.Submit(admin, "GovernanceContract:SetGovernanceMembers", `["Org1MSP","Org2MSP"]`, "1")
+	require.NoError(t, err)
+	membersJSON, err := sim.Evaluate(member, "GovernanceContract:GetGovernanceMembers")
+	require.NoError(t, err)
+	var members cuckoofilter.GovernanceMembers
+	require.NoError(t, json.Unmarshal(membersJSON, &members))
+	require.Equal(t, []string{"Org1MSP", "Org2MSP"}, members.Members)
+	require.Equal(t, 1, members.Threshold)
+	require.Equal(t, tx.ID, members.TxID)
+
+	// The stored threshold applies: one approval of two members is enough
+	tx, err = sim.Submit(member, "GovernanceContract:Propose", cuckoofilter.ProposalMaxBatchSize, "10")
+	require.NoError(t, err)
+	proposal := requireProposal(t, tx.Payload)
+	require.Equal(t, cuckoofilter.ProposalApproved, proposal.Status)
+
+	// Removed members can no longer execute
+	_, err = sim.Submit(admin, "GovernanceContract:SetGovernanceMembers", `["Org2MSP"]`, "0")
+	require.NoError(t, err)
+	_, err = sim.Submit(member, "GovernanceContract:ExecuteProposal", proposal.ID)
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+}
@@ -0,0 +1,118 @@
+package cuckoofilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"sort"
+	"time"
+)
+
+// revocationAuditObjectType is the composite key prefix of the revocation audit trail, revocation~<fingerprint>~<txID>
+const revocationAuditObjectType = "revocation"
+
+// RevocationReasonTransientKey is the transient data key of an optional reason recorded in the revocation
+// audit trail of Insert, BatchInsert, Delete and BatchDelete
+const RevocationReasonTransientKey = "revocationReason"
+
+// RevocationAuditEntry records one insertion or deletion of a fingerprint
+type RevocationAuditEntry struct {
+	Fingerprint string    `json:"fingerprint"`
+	TxID        string    `json:"txId"`
+	Action      string    `json:"action"` // LifecycleRevoked or LifecycleUnrevoked
+	Timestamp   time.Time `json:"timestamp"`
+	MSPID       string    `json:"mspId"` // MSP of the invoking client
+	Reason      string    `json:"reason,omitempty" metadata:",optional"`
+}
+
+// GetRevocationHistory returns the insertions and deletions of a fingerprint in chronological order.
+// With a private data collection configured, only members of the collection can read the history.
+func (s *SmartContract) GetRevocationHistory(ctx contractapi.TransactionContextInterface, fingerprint string) ([]*RevocationAuditEntry, error) {
+	return revocationHistory(ctx, fingerprint)
+}
+
+// revocationHistory returns the revocation audit trail of a fingerprint in chronological order
+func revocationHistory(ctx contractapi.TransactionContextInterface, fingerprint string) ([]*RevocationAuditEntry, error) {
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := getCollectionStateByPartialCompositeKey(ctx, config.PrivateCollection, revocationAuditObjectType, []string{fingerprint})
+	if err != nil {
+		return nil, fmt.Errorf("error reading revocation history: %v", err)
+	}
+	defer iterator.Close()
+
+	entries := []*RevocationAuditEntry{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error reading revocation history: %v", err)
+		}
+		var entry RevocationAuditEntry
+		if err := json.Unmarshal(result.Value, &entry); err != nil {
+			return nil, fmt.Errorf("error decoding revocation audit entry: %v", err)
+		}
+		entries = append(entries, &entry)
+	}
+	// Keys sort by transaction ID, not by time
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.Before(entries[j].Timestamp)
+	})
+	return entries, nil
+}
+
+// recordRevocationAudit records the insertion or deletion of fingerprints by the current transaction,
+// in the private data collection if one is configured
+func recordRevocationAudit(ctx contractapi.TransactionContextInterface, action string, reason string, dataItems ...string) error {
+	if len(dataItems) == 0 {
+		return nil
+	}
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return err
+	}
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	client, _, err := clientInserter(ctx)
+	if err != nil {
+		return err
+	}
+	txID := ctx.GetStub().GetTxID()
+	for _, data := range dataItems {
+		entry := &RevocationAuditEntry{Fingerprint: data, TxID: txID, Action: action, Timestamp: timestamp, MSPID: client.MSPID, Reason: reason}
+		entryJSON, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		key, err := ctx.GetStub().CreateCompositeKey(revocationAuditObjectType, []string{data, txID})
+		if err != nil {
+			return fmt.Errorf("error creating revocation audit key: %v", err)
+		}
+		if err := putCollectionState(ctx, config.PrivateCollection, key, entryJSON); err != nil {
+			return fmt.Errorf("error saving revocation audit entry: %v", err)
+		}
+	}
+	return recordWriteProvenance(ctx, action, dataItems...)
+}
+
+// transientReason returns the reason passed as transient data, empty if there is none
+func transientReason(ctx contractapi.TransactionContextInterface) (string, error) {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return "", fmt.Errorf("error reading transient data: %v", err)
+	}
+	return string(transient[RevocationReasonTransientKey]), nil
+}
+
+// recordTransientRevocationAudit records the insertion or deletion of fingerprints with the reason passed
+// as transient data
+func recordTransientRevocationAudit(ctx contractapi.TransactionContextInterface, action string, dataItems ...string) error {
+	reason, err := transientReason(ctx)
+	if err != nil {
+		return err
+	}
+	return recordRevocationAudit(ctx, action, reason, dataItems...)
+}
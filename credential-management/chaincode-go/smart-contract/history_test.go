@@ -0,0 +1,77 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"github.com/pherbke/credential-management/chaincode-go/mocks"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestGetRevocationHistory(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	issuer := newIssuerIdentity(t, "Org2MSP", "did:key:issuer")
+
+	var txIDs []string
+	for _, step := range [][]string{
+		{"Insert", "", "credential-1"},
+		{"Delete", "", "credential-1"},
+		{"BatchInsert", "", `["credential-1","credential-2"]`},
+		{"BatchDelete", "", `["credential-1"]`, "false"},
+		{"BatchInsert", "", `["credential-1"]`},
+	} {
+		tx, err := sim.Submit(issuer, step[0], step[1:]...)
+		require.NoError(t, err, step[0])
+		txIDs = append(txIDs, tx.ID)
+	}
+
+	historyJSON, err := sim.Evaluate(admin, "GetRevocationHistory", "credential-1")
+	require.NoError(t, err)
+	var history []*cuckoofilter.RevocationAuditEntry
+	require.NoError(t, json.Unmarshal(historyJSON, &history))
+	require.Len(t, history, 5)
+	for i, action := range []string{
+		cuckoofilter.LifecycleRevoked,
+		cuckoofilter.LifecycleUnrevoked,
+		cuckoofilter.LifecycleRevoked,
+		cuckoofilter.LifecycleUnrevoked,
+		cuckoofilter.LifecycleRevoked,
+	} {
+		require.Equal(t, action, history[i].Action)
+		require.Equal(t, txIDs[i], history[i].TxID)
+		require.Equal(t, "credential-1", history[i].Fingerprint)
+		require.Equal(t, "Org2MSP", history[i].MSPID)
+	}
+
+	historyJSON, err = sim.Evaluate(admin, "GetRevocationHistory", "credential-3")
+	require.NoError(t, err)
+	require.JSONEq(t, `[]`, string(historyJSON))
+}
+
+func TestRevocationHistory_TransientReason(t *testing.T) {
+	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockStub.On("GetTransient").Return(map[string][]byte{cuckoofilter.RevocationReasonTransientKey: []byte("key compromise")}, nil)
+	mockStub.On("CreateCompositeKey", "revocation", []string{"credential-1", "tx1"}).Return("\x00revocation\x00credential-1\x00tx1\x00", nil)
+	mockStub.On("PutState", "\x00revocation\x00credential-1\x00tx1\x00", mock.Anything).Return(nil)
+	mockRegistryDefaults(mockStub)
+	filterJSON, _ := json.Marshal(cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize))
+	mockStub.On("GetState", "CuckooFilterState").Return(filterJSON, nil)
+	mockStub.On("PutState", "CuckooFilterState", mock.Anything).Return(nil)
+	mockTxContext := new(mocks.MockTransactionContext)
+	mockTxContext.On("GetStub").Return(mockStub)
+	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
+
+	require.NoError(t, new(cuckoofilter.SmartContract).Insert(mockTxContext, "", "credential-1"))
+
+	var entry cuckoofilter.RevocationAuditEntry
+	for _, call := range mockStub.Calls {
+		if call.Method == "PutState" && call.Arguments.String(0) == "\x00revocation\x00credential-1\x00tx1\x00" {
+			require.NoError(t, json.Unmarshal(call.Arguments.Get(1).([]byte), &entry))
+		}
+	}
+	require.Equal(t, "key compromise", entry.Reason)
+	require.Equal(t, cuckoofilter.LifecycleRevoked, entry.Action)
+	require.Equal(t, "Org1MSP", entry.MSPID)
+}
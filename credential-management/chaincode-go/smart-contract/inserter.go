@@ -0,0 +1,288 @@
+package cuckoofilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"time"
+)
+
+// inserterObjectType is the composite key prefix of the identity that inserted a fingerprint,
+// fingerprintInserter~<scope>~<data> with the scope of filterScope, namespaceScope or pendingScope
+const inserterObjectType = "fingerprintInserter"
+
+// Scope prefixes of inserter keys, so a filter and a namespace of the same name do not share inserters
+const (
+	filterScopePrefix    = "filter:"
+	namespaceScopePrefix = "namespace:"
+)
+
+// pendingScope scopes inserter records to the pending filter, recording who parked a credential
+const pendingScope = "pending:"
+
+// auditRecordObjectType is the composite key prefix of the registry audit log
+const auditRecordObjectType = "auditRecord"
+
+// auditTimeFormat is a fixed-width UTC time layout, so audit record keys sort chronologically
+const auditTimeFormat = "20060102T150405.000000000Z"
+
+// Certificate attributes read from client identities, as enrolled with Fabric CA
+const (
+	RegistryAdminAttribute = "registry.admin" // "true" marks a registry admin
+	DIDAttribute           = "did"            // DID of the client, the client ID is used when it is not set
+)
+
+// AuditActionDeleteOverride is recorded when a registry admin deletes a fingerprint inserted by someone else
+const AuditActionDeleteOverride = "delete-override"
+
+// ErrNotInserter is returned when a client deletes a fingerprint it did not insert without being a registry admin
+var ErrNotInserter = fmt.Errorf("%w: only the original inserter or a registry admin may delete this fingerprint", ErrUnauthorized)
+
+// Inserter is the identity that inserted a fingerprint into the filter
+type Inserter struct {
+	MSPID string `json:"mspId"`
+	DID   string `json:"did"`
+}
+
+// AuditRecord is an entry of the registry audit log
+type AuditRecord struct {
+	TxID        string    `json:"txId"`
+	Timestamp   time.Time `json:"timestamp"`
+	Action      string    `json:"action"`
+	Fingerprint string    `json:"fingerprint"`
+	Actor       Inserter  `json:"actor"`
+	Inserter    Inserter  `json:"inserter"` // Original inserter whose entry the actor overrode
+}
+
+// GetInserter returns the identity that inserted a fingerprint into a filter. Fingerprints inserted
+// before inserters were recorded return an error.
+func (s *SmartContract) GetInserter(ctx contractapi.TransactionContextInterface, filterID string, data string) (*Inserter, error) {
+	inserter, err := loadInserter(ctx, filterScope(filterID), data)
+	if err != nil {
+		return nil, err
+	}
+	if inserter == nil {
+		return nil, fmt.Errorf("%w: no inserter recorded for '%s'", ErrNotFound, data)
+	}
+	return inserter, nil
+}
+
+// GetAuditLog returns the registry audit log in chronological order
+func (s *SmartContract) GetAuditLog(ctx contractapi.TransactionContextInterface) ([]*AuditRecord, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(auditRecordObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("error reading audit log: %v", err)
+	}
+	defer iterator.Close()
+
+	records := []*AuditRecord{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error reading audit log: %v", err)
+		}
+		var record AuditRecord
+		if err := json.Unmarshal(entry.Value, &record); err != nil {
+			return nil, fmt.Errorf("error decoding audit record: %v", err)
+		}
+		records = append(records, &record)
+	}
+	return records, nil
+}
+
+// clientInserter returns the identity of the submitting client and whether it is a registry admin
+func clientInserter(ctx contractapi.TransactionContextInterface) (*Inserter, bool, error) {
+	identity := ctx.GetClientIdentity()
+	mspID, err := identity.GetMSPID()
+	if err != nil {
+		return nil, false, fmt.Errorf("error reading client MSP ID: %v", err)
+	}
+	did, ok, err := identity.GetAttributeValue(DIDAttribute)
+	if err != nil {
+		return nil, false, fmt.Errorf("error reading client DID: %v", err)
+	}
+	if !ok || did == "" {
+		if did, err = identity.GetID(); err != nil {
+			return nil, false, fmt.Errorf("error reading client ID: %v", err)
+		}
+	}
+	admin, _, err := identity.GetAttributeValue(RegistryAdminAttribute)
+	if err != nil {
+		return nil, false, fmt.Errorf("error reading client attributes: %v", err)
+	}
+	return &Inserter{MSPID: mspID, DID: did}, admin == "true", nil
+}
+
+// checkRegistryAdmin fails with ErrUnauthorized unless the submitting client is a registry admin.
+// action completes "only a registry admin may ...".
+func checkRegistryAdmin(ctx contractapi.TransactionContextInterface, action string) error {
+	_, admin, err := clientInserter(ctx)
+	if err != nil {
+		return err
+	}
+	if !admin {
+		return fmt.Errorf("%w: only a registry admin may %s", ErrUnauthorized, action)
+	}
+	return nil
+}
+
+// checkIssuerOrAdmin fails with ErrUnauthorized unless the submitting client is the issuer, as the DID
+// attribute of its certificate, or a registry admin. action completes "only <issuer> or a registry admin may ...".
+func checkIssuerOrAdmin(ctx contractapi.TransactionContextInterface, issuerDID string, action string) error {
+	client, admin, err := clientInserter(ctx)
+	if err != nil {
+		return err
+	}
+	if !admin && client.DID != issuerDID {
+		return fmt.Errorf("%w: only %s or a registry admin may %s", ErrUnauthorized, issuerDID, action)
+	}
+	return nil
+}
+
+// filterScope scopes inserter records to the default filter or a named filter
+func filterScope(filterID string) string {
+	return filterScopePrefix + filterID
+}
+
+// namespaceScope scopes inserter records to an issuer namespace of the sharded registry
+func namespaceScope(namespace string) string {
+	return namespaceScopePrefix + namespace
+}
+
+// inserterKey returns the key of the inserter of a fingerprint within a scope
+func inserterKey(ctx contractapi.TransactionContextInterface, scope string, data string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(inserterObjectType, []string{scope, data})
+	if err != nil {
+		return "", fmt.Errorf("error creating inserter key: %v", err)
+	}
+	return key, nil
+}
+
+// recordInserter stores the submitting client as the inserter of each fingerprint in the scope that has
+// none yet, so inserting a fingerprint again does not take it over
+func recordInserter(ctx contractapi.TransactionContextInterface, scope string, dataItems ...string) error {
+	client, _, err := clientInserter(ctx)
+	if err != nil {
+		return err
+	}
+	clientJSON, err := json.Marshal(client)
+	if err != nil {
+		return err
+	}
+	for _, data := range dataItems {
+		key, err := inserterKey(ctx, scope, data)
+		if err != nil {
+			return err
+		}
+		existing, err := ctx.GetStub().GetState(key)
+		if err != nil {
+			return fmt.Errorf("error loading inserter: %v", err)
+		}
+		if existing != nil {
+			continue
+		}
+		if err := ctx.GetStub().PutState(key, clientJSON); err != nil {
+			return fmt.Errorf("error saving inserter: %v", err)
+		}
+	}
+	return nil
+}
+
+// authorizeDelete checks that the submitting client may delete the fingerprints from the scope, i.e. it
+// inserted them there or is a registry admin. Admin deletions of entries inserted by someone else are
+// written to the audit log. The inserter records of the fingerprints in the scope are removed.
+func authorizeDelete(ctx contractapi.TransactionContextInterface, scope string, dataItems ...string) error {
+	client, admin, err := clientInserter(ctx)
+	if err != nil {
+		return err
+	}
+	for _, data := range dataItems {
+		if err := authorizeDeleteBy(ctx, client, admin, scope, data); err != nil {
+			return err
+		}
+	}
+	for _, data := range dataItems {
+		if err := deleteInserter(ctx, scope, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// authorizeDeleteBy checks that the client may delete one fingerprint from the scope, auditing admin overrides
+func authorizeDeleteBy(ctx contractapi.TransactionContextInterface, client *Inserter, admin bool, scope string, data string) error {
+	inserter, err := loadInserter(ctx, scope, data)
+	if err != nil {
+		return err
+	}
+	if inserter != nil && *inserter == *client {
+		return nil
+	}
+	// Entries without a recorded inserter predate inserter tracking and are left to admins
+	if !admin {
+		return ErrNotInserter
+	}
+	if inserter != nil {
+		return appendAuditRecord(ctx, AuditActionDeleteOverride, data, client, inserter)
+	}
+	return nil
+}
+
+func deleteInserter(ctx contractapi.TransactionContextInterface, scope string, data string) error {
+	key, err := inserterKey(ctx, scope, data)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().DelState(key); err != nil {
+		return fmt.Errorf("error deleting inserter: %v", err)
+	}
+	return nil
+}
+
+func loadInserter(ctx contractapi.TransactionContextInterface, scope string, data string) (*Inserter, error) {
+	key, err := inserterKey(ctx, scope, data)
+	if err != nil {
+		return nil, err
+	}
+	inserterJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("error loading inserter: %v", err)
+	}
+	if inserterJSON == nil {
+		return nil, nil
+	}
+
+	var inserter Inserter
+	if err := json.Unmarshal(inserterJSON, &inserter); err != nil {
+		return nil, fmt.Errorf("error decoding inserter: %v", err)
+	}
+	return &inserter, nil
+}
+
+// appendAuditRecord writes an audit record keyed by transaction time, transaction ID and fingerprint
+func appendAuditRecord(ctx contractapi.TransactionContextInterface, action string, data string, actor *Inserter, inserter *Inserter) error {
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	record := &AuditRecord{
+		TxID:        ctx.GetStub().GetTxID(),
+		Timestamp:   timestamp,
+		Action:      action,
+		Fingerprint: data,
+		Actor:       *actor,
+		Inserter:    *inserter,
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(auditRecordObjectType, []string{timestamp.Format(auditTimeFormat), record.TxID, data})
+	if err != nil {
+		return fmt.Errorf("error creating audit record key: %v", err)
+	}
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(key, recordJSON); err != nil {
+		return fmt.Errorf("error saving audit record: %v", err)
+	}
+	return nil
+}
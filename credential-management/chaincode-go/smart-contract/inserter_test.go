@@ -0,0 +1,189 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"github.com/pherbke/credential-management/chaincode-go/mocks"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func newIssuerIdentity(t *testing.T, mspID string, did string) *simulator.Identity {
+	identity, err := simulator.NewIdentityWithAttributes(mspID, did, map[string]string{cuckoofilter.DIDAttribute: did})
+	require.NoError(t, err)
+	return identity
+}
+
+func TestDelete_OnlyByInserter(t *testing.T) {
+	sim, _ := newRegistrySimulator(t)
+	issuer1 := newIssuerIdentity(t, "Org1MSP", "did:key:issuer1")
+	issuer2 := newIssuerIdentity(t, "Org2MSP", "did:key:issuer2")
+
+	_, err := sim.Submit(issuer1, "Insert", "", "credential-1")
+	require.NoError(t, err)
+	inserterJSON, err := sim.Evaluate(issuer2, "GetInserter", "", "credential-1")
+	require.NoError(t, err)
+	var inserter cuckoofilter.Inserter
+	require.NoError(t, json.Unmarshal(inserterJSON, &inserter))
+	require.Equal(t, cuckoofilter.Inserter{MSPID: "Org1MSP", DID: "did:key:issuer1"}, inserter)
+
+	_, err = sim.Submit(issuer2, "Delete", "", "credential-1")
+	require.ErrorContains(t, err, cuckoofilter.ErrUnauthorized.Error())
+
+	_, err = sim.Submit(issuer1, "Delete", "", "credential-1")
+	require.NoError(t, err)
+	_, err = sim.Evaluate(issuer1, "GetInserter", "", "credential-1")
+	require.Error(t, err)
+}
+
+func TestBatchDelete_RejectsWholeBatch(t *testing.T) {
+	sim, _ := newRegistrySimulator(t)
+	issuer1 := newIssuerIdentity(t, "Org1MSP", "did:key:issuer1")
+	issuer2 := newIssuerIdentity(t, "Org2MSP", "did:key:issuer2")
+
+	_, err := sim.Submit(issuer1, "Insert", "", "credential-1")
+	require.NoError(t, err)
+	_, err = sim.Submit(issuer2, "Insert", "", "credential-2")
+	require.NoError(t, err)
+
+	_, err = sim.Submit(issuer1, "BatchDelete", "", `["credential-1","credential-2"]`, "true")
+	require.ErrorContains(t, err, cuckoofilter.ErrUnauthorized.Error())
+	revoked, err := sim.Evaluate(issuer1, "Lookup", "", "credential-1")
+	require.NoError(t, err)
+	require.Equal(t, "true", string(revoked))
+}
+
+func TestDelete_AdminOverrideIsAudited(t *testing.T) {
+	sim, _ := newRegistrySimulator(t)
+	issuer := newIssuerIdentity(t, "Org1MSP", "did:key:issuer1")
+	admin, err := simulator.NewIdentityWithAttributes("RegistryMSP", "registry-admin", map[string]string{
+		cuckoofilter.DIDAttribute:           "did:key:admin",
+		cuckoofilter.RegistryAdminAttribute: "true",
+	})
+	require.NoError(t, err)
+
+	_, err = sim.Submit(issuer, "Insert", "", "credential-1")
+	require.NoError(t, err)
+	tx, err := sim.Submit(admin, "Delete", "", "credential-1")
+	require.NoError(t, err)
+
+	auditJSON, err := sim.Evaluate(admin, "GetAuditLog")
+	require.NoError(t, err)
+	var records []cuckoofilter.AuditRecord
+	require.NoError(t, json.Unmarshal(auditJSON, &records))
+	require.Equal(t, []cuckoofilter.AuditRecord{{
+		TxID:        tx.ID,
+		Timestamp:   tx.Timestamp,
+		Action:      cuckoofilter.AuditActionDeleteOverride,
+		Fingerprint: "credential-1",
+		Actor:       cuckoofilter.Inserter{MSPID: "RegistryMSP", DID: "did:key:admin"},
+		Inserter:    cuckoofilter.Inserter{MSPID: "Org1MSP", DID: "did:key:issuer1"},
+	}}, records)
+}
+
+func TestDelete_UnknownInserterNeedsAdmin(t *testing.T) {
+	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
+	mockTxContext := new(mocks.MockTransactionContext)
+	mockTxContext.On("GetStub").Return(mockStub)
+	mockTxContext.Stub = mockStub
+	mockTxContext.On("GetClientIdentity").Return(&mocks.ClientIdentity{ID: "x509::CN=issuer::CN=ca", MSPID: "Org1MSP"})
+
+	filter := cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize)
+	filter.Insert([]byte("testData"))
+	filterJSON, _ := json.Marshal(filter)
+	mockStub.On("GetState", "CuckooFilterState").Return(filterJSON, nil)
+
+	smartContract := new(cuckoofilter.SmartContract)
+	err := smartContract.Delete(mockTxContext, "", "testData")
+	require.ErrorIs(t, err, cuckoofilter.ErrUnauthorized)
+	mockStub.AssertNotCalled(t, "PutState", "CuckooFilterState", mock.Anything)
+}
+
+func TestBatchDelete_ReportsResults(t *testing.T) {
+	sim, _ := newRegistrySimulator(t)
+	issuer1 := newIssuerIdentity(t, "Org1MSP", "did:key:issuer1")
+	issuer2 := newIssuerIdentity(t, "Org2MSP", "did:key:issuer2")
+
+	_, err := sim.Submit(issuer1, "BatchInsert", "", `["credential-1","credential-2"]`)
+	require.NoError(t, err)
+	_, err = sim.Submit(issuer2, "Insert", "", "credential-3")
+	require.NoError(t, err)
+
+	tx, err := sim.Submit(issuer1, "BatchDelete", "", `["credential-1","credential-3","credential-4","credential-1"]`, "false")
+	require.NoError(t, err)
+	var result cuckoofilter.BatchDeleteResult
+	require.NoError(t, json.Unmarshal(tx.Payload, &result))
+	require.Equal(t, cuckoofilter.BatchDeleteResult{
+		Results: map[string]string{
+			"credential-1": cuckoofilter.BatchDeleteDeleted,
+			"credential-3": cuckoofilter.BatchDeleteUnauthorized,
+			"credential-4": cuckoofilter.BatchDeleteNotFound,
+		},
+		Deleted:      1,
+		NotFound:     1,
+		Unauthorized: 1,
+	}, result)
+
+	for data, expected := range map[string]string{"credential-1": "false", "credential-2": "true", "credential-3": "true"} {
+		revoked, err := sim.Evaluate(issuer1, "Lookup", "", data)
+		require.NoError(t, err)
+		require.Equal(t, expected, string(revoked), data)
+	}
+}
+
+func TestBatchDelete_AtomicReportsMissingItems(t *testing.T) {
+	sim, _ := newRegistrySimulator(t)
+	issuer := newIssuerIdentity(t, "Org1MSP", "did:key:issuer1")
+	_, err := sim.Submit(issuer, "Insert", "", "credential-1")
+	require.NoError(t, err)
+
+	tx, err := sim.Submit(issuer, "BatchDelete", "", `["credential-1","credential-2"]`, "true")
+	require.NoError(t, err)
+	var result cuckoofilter.BatchDeleteResult
+	require.NoError(t, json.Unmarshal(tx.Payload, &result))
+	require.Equal(t, cuckoofilter.BatchDeleteResult{
+		Results: map[string]string{
+			"credential-1": cuckoofilter.BatchDeleteDeleted,
+			"credential-2": cuckoofilter.BatchDeleteNotFound,
+		},
+		Deleted:  1,
+		NotFound: 1,
+	}, result)
+}
+
+func TestDelete_InserterPerFilter(t *testing.T) {
+	sim, _ := newRegistrySimulator(t)
+	_, err := sim.Submit(newRegistryAdmin(t), "Init", "employment", "100", "4", "0")
+	require.NoError(t, err)
+	issuer1 := newIssuerIdentity(t, "Org1MSP", "did:key:issuer1")
+	issuer2 := newIssuerIdentity(t, "Org2MSP", "did:key:issuer2")
+
+	// Inserting the same value first into one filter does not make issuer1 its owner in the other
+	_, err = sim.Submit(issuer1, "Insert", "", "credential-1")
+	require.NoError(t, err)
+	_, err = sim.Submit(issuer2, "Insert", "employment", "credential-1")
+	require.NoError(t, err)
+	inserterJSON, err := sim.Evaluate(issuer1, "GetInserter", "employment", "credential-1")
+	require.NoError(t, err)
+	var inserter cuckoofilter.Inserter
+	require.NoError(t, json.Unmarshal(inserterJSON, &inserter))
+	require.Equal(t, "did:key:issuer2", inserter.DID)
+
+	_, err = sim.Submit(issuer1, "Delete", "employment", "credential-1")
+	require.ErrorContains(t, err, cuckoofilter.ErrUnauthorized.Error())
+	_, err = sim.Submit(issuer2, "Delete", "", "credential-1")
+	require.ErrorContains(t, err, cuckoofilter.ErrUnauthorized.Error())
+
+	// A delete in one filter keeps the inserter of the other
+	_, err = sim.Submit(issuer1, "Delete", "", "credential-1")
+	require.NoError(t, err)
+	_, err = sim.Evaluate(issuer1, "GetInserter", "", "credential-1")
+	require.Error(t, err)
+	_, err = sim.Evaluate(issuer1, "GetInserter", "employment", "credential-1")
+	require.NoError(t, err)
+	_, err = sim.Submit(issuer2, "Delete", "employment", "credential-1")
+	require.NoError(t, err)
+}
@@ -0,0 +1,53 @@
+package cuckoofilter
+
+import (
+	"fmt"
+)
+
+// ErrCorruptFilter is returned when a filter read from the ledger or a snapshot is structurally broken
+var ErrCorruptFilter = fmt.Errorf("%w: corrupt filter state", ErrStateCorrupt)
+
+// Validate checks that the filter is safe to query: one bucket per index of the bucket index mask,
+// no missing buckets, fingerprints of the filter's fingerprint size and a count that fits the slots.
+// An empty filter without buckets is valid.
+func (f *Filter) Validate() error {
+	if len(f.Buckets) == 0 {
+		if f.Count != 0 {
+			return fmt.Errorf("%w: count %d without buckets", ErrCorruptFilter, f.Count)
+		}
+		return nil
+	}
+	if f.FingerprintSize > FingerPrintSize {
+		return fmt.Errorf("%w: fingerprint size %d exceeds %d bytes", ErrCorruptFilter, f.FingerprintSize, FingerPrintSize)
+	}
+	if uint64(f.BucketIndexMask)+1 != uint64(len(f.Buckets)) || f.BucketIndexMask&(f.BucketIndexMask+1) != 0 {
+		return fmt.Errorf("%w: %d buckets do not match bucket index mask %#x", ErrCorruptFilter, len(f.Buckets), f.BucketIndexMask)
+	}
+	slots := uint(0)
+	for i, b := range f.Buckets {
+		if b == nil {
+			return fmt.Errorf("%w: bucket %d is missing", ErrCorruptFilter, i)
+		}
+		if f.SemiSorted && len(b.Data) > SemiSortedBucketSize {
+			return fmt.Errorf("%w: semi-sorted bucket %d has %d slots", ErrCorruptFilter, i, len(b.Data))
+		}
+		slots += uint(len(b.Data))
+		for j, fp := range b.Data {
+			if len(fp) != 0 && uint(len(fp)) != f.fingerprintSize() {
+				return fmt.Errorf("%w: fingerprint %d of bucket %d has %d bytes", ErrCorruptFilter, j, i, len(fp))
+			}
+		}
+	}
+	if f.Count > slots {
+		return fmt.Errorf("%w: count %d exceeds the %d slots", ErrCorruptFilter, f.Count, slots)
+	}
+	return nil
+}
+
+// bucketAt returns the bucket at index, or nil when the filter has no such bucket
+func (f *Filter) bucketAt(index uint) *bucket {
+	if index >= uint(len(f.Buckets)) {
+		return nil
+	}
+	return f.Buckets[index]
+}
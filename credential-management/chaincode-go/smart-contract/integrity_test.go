@@ -0,0 +1,133 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"github.com/pherbke/credential-management/chaincode-go/mocks"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// corruptFilterJSON returns the serialized state of a filter holding "credential-1" after edit changed its fields
+func corruptFilterJSON(t *testing.T, edit func(fields map[string]json.RawMessage)) []byte {
+	filter := cuckoofilter.NewFilter(16, 4, cuckoofilter.FingerPrintSize)
+	require.True(t, filter.Insert([]byte("credential-1")))
+	filterJSON, err := json.Marshal(filter)
+	require.NoError(t, err)
+
+	var fields map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(filterJSON, &fields))
+	edit(fields)
+	filterJSON, err = json.Marshal(fields)
+	require.NoError(t, err)
+	return filterJSON
+}
+
+// mockCorruptRegistry returns a context whose ledger holds filterJSON as the filter state
+func mockCorruptRegistry(filterJSON []byte) *mocks.MockTransactionContext {
+	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
+	mockStub.On("GetState", "CuckooFilterState").Return(filterJSON, nil)
+	mockStub.On("PutState", "CuckooFilterState", mock.Anything).Return(nil).Maybe()
+	mockTxContext := new(mocks.MockTransactionContext)
+	mockTxContext.On("GetStub").Return(mockStub)
+	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
+	return mockTxContext
+}
+
+func TestCorruptFilterState(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(fields map[string]json.RawMessage)
+	}{
+		{"fewer buckets than the index mask", func(fields map[string]json.RawMessage) {
+			fields["BucketIndexMask"] = json.RawMessage("1023")
+		}},
+		{"index mask not a power of two minus one", func(fields map[string]json.RawMessage) {
+			fields["BucketIndexMask"] = json.RawMessage("5")
+		}},
+		{"fingerprint of the wrong length", func(fields map[string]json.RawMessage) {
+			var buckets [][][]byte
+			require.NoError(t, json.Unmarshal(fields["SerializedBuckets"], &buckets))
+			buckets[0][0] = []byte{1, 2, 3}
+			fields["SerializedBuckets"], _ = json.Marshal(buckets)
+		}},
+		{"count beyond the slots", func(fields map[string]json.RawMessage) {
+			fields["Count"] = json.RawMessage("18446744073709551615")
+		}},
+		{"negative count", func(fields map[string]json.RawMessage) {
+			fields["Count"] = json.RawMessage("-1")
+		}},
+		{"fingerprint size beyond the hash", func(fields map[string]json.RawMessage) {
+			fields["FingerprintSize"] = json.RawMessage("9")
+		}},
+		{"count without buckets", func(fields map[string]json.RawMessage) {
+			fields["SerializedBuckets"] = json.RawMessage("[]")
+			fields["BucketIndexMask"] = json.RawMessage("0")
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := mockCorruptRegistry(corruptFilterJSON(t, test.edit))
+			smartContract := new(cuckoofilter.SmartContract)
+
+			_, err := smartContract.Lookup(ctx, "", "credential-1")
+			require.Error(t, err)
+			_, err = smartContract.BatchLookup(ctx, "", []string{"credential-1"})
+			require.Error(t, err)
+			require.Error(t, smartContract.Delete(ctx, "", "credential-1"))
+			require.Error(t, smartContract.Insert(ctx, "", "credential-2"))
+		})
+	}
+}
+
+func TestCorruptFilterState_ReportsCorruption(t *testing.T) {
+	filterJSON := corruptFilterJSON(t, func(fields map[string]json.RawMessage) {
+		fields["BucketIndexMask"] = json.RawMessage("1023")
+	})
+	var filter cuckoofilter.Filter
+	require.ErrorIs(t, json.Unmarshal(filterJSON, &filter), cuckoofilter.ErrCorruptFilter)
+}
+
+func TestFilter_TruncatedBuckets(t *testing.T) {
+	filter := cuckoofilter.NewFilter(16, 4, cuckoofilter.FingerPrintSize)
+	require.True(t, filter.Insert([]byte("credential-1")))
+	filter.Buckets = filter.Buckets[:1]
+
+	require.ErrorIs(t, filter.Validate(), cuckoofilter.ErrCorruptFilter)
+	require.NotPanics(t, func() {
+		filter.Lookup([]byte("credential-1"))
+		filter.Delete([]byte("credential-1"))
+	})
+}
+
+func TestFilter_NilBuckets(t *testing.T) {
+	filter := cuckoofilter.NewFilter(16, 4, cuckoofilter.FingerPrintSize)
+	require.True(t, filter.Insert([]byte("credential-1")))
+	for i := range filter.Buckets {
+		filter.Buckets[i] = nil
+	}
+
+	require.ErrorIs(t, filter.Validate(), cuckoofilter.ErrCorruptFilter)
+	require.NotPanics(t, func() {
+		require.False(t, filter.Lookup([]byte("credential-1")))
+		require.False(t, filter.Delete([]byte("credential-1")))
+	})
+}
+
+func TestFilter_DeleteKeepsCountAboveZero(t *testing.T) {
+	filter := cuckoofilter.NewFilter(16, 4, cuckoofilter.FingerPrintSize)
+	require.True(t, filter.Insert([]byte("credential-1")))
+	filter.Count = 0
+
+	require.True(t, filter.Delete([]byte("credential-1")))
+	require.Equal(t, uint(0), filter.Count)
+	require.NoError(t, filter.Validate())
+}
+
+func TestFilter_ValidateEmpty(t *testing.T) {
+	require.NoError(t, (&cuckoofilter.Filter{}).Validate())
+	require.NoError(t, cuckoofilter.NewFilter(16, 4, cuckoofilter.FingerPrintSize).Validate())
+}
@@ -0,0 +1,87 @@
+package cuckoofilter
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// jwsHeader is the protected header of a credential proof: a detached JWS with an unencoded payload,
+// RFC 7797, as the Ed25519Signature2018 and EcdsaSecp256k1Signature2019 suites use it
+type jwsHeader struct {
+	Alg  string   `json:"alg"`
+	B64  bool     `json:"b64"`
+	Crit []string `json:"crit"`
+}
+
+// signDetachedJWS signs the payload with the JWT algorithm of the key and returns the JWS in compact
+// serialization with the payload left out, <header>..<signature>
+func signDetachedJWS(payload []byte, privateKey crypto.PrivateKey) (string, error) {
+	keyType, err := keyTypeOf(privateKey)
+	if err != nil {
+		return "", err
+	}
+	method, err := jwtSigningMethod(keyType)
+	if err != nil {
+		return "", err
+	}
+	headerJSON, err := json.Marshal(jwsHeader{Alg: method.Alg(), B64: false, Crit: []string{"b64"}})
+	if err != nil {
+		return "", err
+	}
+	header := base64.RawURLEncoding.EncodeToString(headerJSON)
+	signature, err := method.Sign(header+"."+string(payload), privateKey)
+	if err != nil {
+		return "", err
+	}
+	return header + ".." + signature, nil
+}
+
+// verifyDetachedJWS checks a detached JWS over the payload. The algorithm must be the one of the key.
+func verifyDetachedJWS(jws string, payload []byte, publicKey crypto.PublicKey) error {
+	parts := strings.Split(jws, ".")
+	if len(parts) != 3 || parts[1] != "" {
+		return fmt.Errorf("not a detached JWS")
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return fmt.Errorf("error decoding JWS header: %v", err)
+	}
+	var header jwsHeader
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return fmt.Errorf("error decoding JWS header: %v", err)
+	}
+	if header.B64 || len(header.Crit) != 1 || header.Crit[0] != "b64" {
+		return fmt.Errorf("JWS header must declare an unencoded payload with b64 false and crit [\"b64\"]")
+	}
+
+	keyType, err := keyTypeOf(publicKey)
+	if err != nil {
+		return err
+	}
+	method, err := jwtSigningMethod(keyType)
+	if err != nil {
+		return err
+	}
+	if header.Alg != method.Alg() {
+		return fmt.Errorf("JWS algorithm %s does not match the %s key", header.Alg, keyType)
+	}
+	return method.Verify(parts[0]+"."+string(payload), parts[2], publicKey)
+}
+
+// encodeCanonical encodes a decoded JSON document with sorted object keys, no insignificant whitespace
+// and no HTML escaping, so signer and verifier derive the same bytes whatever produced the document.
+// Decode with UseNumber to keep numbers as they were written.
+func encodeCanonical(value interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	// encoding/json sorts map keys
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buffer.Bytes(), []byte("\n")), nil
+}
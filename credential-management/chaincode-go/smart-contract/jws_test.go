@@ -0,0 +1,52 @@
+package cuckoofilter_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyProof_DetachedJWS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	issuedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	credential, err := cuckoofilter.CreateAndSignCredentialAt(issuedAt, "did:key:issuer<&>", key, "did:key:holder", "-1")
+	require.NoError(t, err)
+	require.NoError(t, cuckoofilter.VerifyProof(credential, &key.PublicKey))
+
+	// The proof survives a JSON round trip, the payload is canonical
+	credentialJSON, err := json.Marshal(credential)
+	require.NoError(t, err)
+	var decoded cuckoofilter.VerifiableCredential
+	require.NoError(t, json.Unmarshal(credentialJSON, &decoded))
+	require.NoError(t, cuckoofilter.VerifyProof(&decoded, &key.PublicKey))
+
+	parts := strings.Split(credential.Proof.JWS, ".")
+	tampered := []string{
+		// Payload attached
+		parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte("{}")) + "." + parts[2],
+		// Header without the unencoded payload
+		base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256"}`)) + ".." + parts[2],
+		// Another algorithm
+		base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","b64":false,"crit":["b64"]}`)) + ".." + parts[2],
+		// A raw r || s signature, as proofs were stored before
+		base64.StdEncoding.EncodeToString(make([]byte, 64)),
+	}
+	for _, jws := range tampered {
+		forged := *credential
+		forged.Proof.JWS = jws
+		require.ErrorIs(t, cuckoofilter.VerifyProof(&forged, &key.PublicKey), cuckoofilter.ErrInvalidProof, jws)
+	}
+
+	// The proof type must match the key
+	forged := *credential
+	forged.Proof.Type = cuckoofilter.ProofTypeEd25519
+	require.ErrorIs(t, cuckoofilter.VerifyProof(&forged, &key.PublicKey), cuckoofilter.ErrInvalidProof)
+}
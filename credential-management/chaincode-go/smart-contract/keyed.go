@@ -0,0 +1,197 @@
+package cuckoofilter
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// fingerprintSecretKey is the private data key of the registry secret of keyed fingerprints
+const fingerprintSecretKey = "FingerprintSecret"
+
+// FingerprintSecretTransientKey is the transient data key of the secret passed to SetKeyedFingerprints
+const FingerprintSecretTransientKey = "fingerprintSecret"
+
+// MinFingerprintSecretSize is the minimum number of bytes of the registry secret
+const MinFingerprintSecretSize = 32
+
+// AuditorAttribute is the certificate attribute marking dispute auditors, "true" marks an auditor
+const AuditorAttribute = "registry.auditor"
+
+// keyedInput returns the value the filter fingerprints for data: the HMAC-SHA256 of data under the
+// registry secret for keyed fingerprints, data itself otherwise
+func (f *Filter) keyedInput(data []byte) []byte {
+	if f.fingerprintKey == nil {
+		return data
+	}
+	mac := hmac.New(sha256.New, f.fingerprintKey)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// indexAndFingerprint returns the primary bucket index and the fingerprint of data in the filter
+func (f *Filter) indexAndFingerprint(data []byte) (uint, []byte) {
+	return GetIndexAndFingerprint(f.keyedInput(data), f.BucketIndexMask, f.fingerprintSize())
+}
+
+// SetKeyedFingerprints switches the default filter to fingerprints of the HMAC of the inserted values
+// under a registry secret, passed under FingerprintSecretTransientKey, or back. The secret is kept in the
+// private data collection of the default filter, so fingerprints cannot be recomputed or correlated with
+// other registries without it; lookups are answered by peers of the collection only. Combine it with
+// RevokePrivate to keep the values off the channel as well. The default filter must be empty, as its
+// fingerprints would no longer match.
+//
+// Keying applies to the default filter only: named filters and shards live in the public state, where
+// peers outside the collection could not look them up. So keyed fingerprints cannot be enabled while
+// named filters or shards exist, and neither can be created while they are enabled. Registry admins only.
+func (s *SmartContract) SetKeyedFingerprints(ctx contractapi.TransactionContextInterface, enabled bool) (*RegistryConfig, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	if err := checkRegistryAdmin(ctx, "switch keyed fingerprints"); err != nil {
+		return nil, err
+	}
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if config.KeyedFingerprints == enabled {
+		return config, nil
+	}
+	if enabled {
+		if err := checkDefaultFilterOnly(ctx); err != nil {
+			return nil, err
+		}
+	}
+	filter, err := loadDefaultFilter(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	if filter.Count > 0 {
+		return nil, fmt.Errorf("%w: the default filter holds %d fingerprints, keyed fingerprints can only be switched on an empty filter", ErrFailedPrecondition, filter.Count)
+	}
+
+	config.Version++
+	config.KeyedFingerprints = enabled
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	if enabled {
+		transient, err := ctx.GetStub().GetTransient()
+		if err != nil {
+			return nil, fmt.Errorf("error reading transient data: %v", err)
+		}
+		secret := transient[FingerprintSecretTransientKey]
+		if len(secret) < MinFingerprintSecretSize {
+			return nil, fmt.Errorf("%w: transient data '%s' must hold a secret of at least %d bytes", ErrInvalidArgument, FingerprintSecretTransientKey, MinFingerprintSecretSize)
+		}
+		if err := ctx.GetStub().PutPrivateData(config.PrivateCollection, fingerprintSecretKey, secret); err != nil {
+			return nil, fmt.Errorf("error saving fingerprint secret: %v", err)
+		}
+	} else if err := ctx.GetStub().DelPrivateData(config.PrivateCollection, fingerprintSecretKey); err != nil {
+		return nil, fmt.Errorf("error deleting fingerprint secret: %v", err)
+	}
+	if err := saveRegistryConfig(ctx, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// checkDefaultFilterOnly fails with ErrFailedPrecondition if named filters or shards exist, which would
+// keep plain fingerprints next to a keyed default filter
+func checkDefaultFilterOnly(ctx contractapi.TransactionContextInterface) error {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(namedFilterObjectType, []string{})
+	if err != nil {
+		return fmt.Errorf("error reading named filters: %v", err)
+	}
+	defer iterator.Close()
+	if iterator.HasNext() {
+		return fmt.Errorf("%w: keyed fingerprints apply to the default filter only, but named filters exist", ErrFailedPrecondition)
+	}
+	shardConfig, err := ctx.GetStub().GetState(shardConfigKey)
+	if err != nil {
+		return fmt.Errorf("error loading shard config: %v", err)
+	}
+	if shardConfig != nil {
+		return fmt.Errorf("%w: keyed fingerprints apply to the default filter only, but shards are initialized", ErrFailedPrecondition)
+	}
+	return nil
+}
+
+// checkUnkeyed fails with ErrFailedPrecondition while keyed fingerprints are enabled. what names the
+// filters that would be created, e.g. "named filters".
+func checkUnkeyed(ctx contractapi.TransactionContextInterface, what string) error {
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if config.KeyedFingerprints {
+		return fmt.Errorf("%w: keyed fingerprints apply to the default filter only, %s cannot be created", ErrFailedPrecondition, what)
+	}
+	return nil
+}
+
+// ConfirmMembership lets an auditor resolve a dispute over whether specific credentials are revoked in
+// the default filter with keyed fingerprints, by recomputing their HMAC inside the chaincode. The values
+// are passed under PrivateFingerprintsTransientKey so they stay off the channel; evaluate it on a peer
+// of the private data collection. Clients with AuditorAttribute or RegistryAdminAttribute only.
+func (s *SmartContract) ConfirmMembership(ctx contractapi.TransactionContextInterface) (map[string]bool, error) {
+	if err := checkAuditor(ctx); err != nil {
+		return nil, err
+	}
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !config.KeyedFingerprints {
+		return nil, fmt.Errorf("%w: the default filter does not use keyed fingerprints, use LookupPrivate", ErrFailedPrecondition)
+	}
+	return s.LookupPrivate(ctx)
+}
+
+// checkAuditor fails unless the submitting client is an auditor or a registry admin
+func checkAuditor(ctx contractapi.TransactionContextInterface) error {
+	_, admin, err := clientInserter(ctx)
+	if err != nil {
+		return err
+	}
+	if admin {
+		return nil
+	}
+	value, found, err := ctx.GetClientIdentity().GetAttributeValue(AuditorAttribute)
+	if err != nil {
+		return fmt.Errorf("error reading client attributes: %v", err)
+	}
+	if !found || value != "true" {
+		return fmt.Errorf("%w: only an auditor may confirm membership", ErrUnauthorized)
+	}
+	return nil
+}
+
+// loadFingerprintSecret returns the registry secret of keyed fingerprints
+func loadFingerprintSecret(ctx contractapi.TransactionContextInterface, config *RegistryConfig) ([]byte, error) {
+	secret, err := ctx.GetStub().GetPrivateData(config.PrivateCollection, fingerprintSecretKey)
+	if err != nil {
+		return nil, fmt.Errorf("error loading fingerprint secret, lookups of keyed fingerprints need a peer of collection %s: %v", config.PrivateCollection, err)
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("%w: keyed fingerprints are enabled but the fingerprint secret is missing", ErrStateCorrupt)
+	}
+	return secret, nil
+}
+
+// moveFingerprintSecret moves the registry secret between private data collections
+func moveFingerprintSecret(ctx contractapi.TransactionContextInterface, from string, to string) error {
+	secret, err := ctx.GetStub().GetPrivateData(from, fingerprintSecretKey)
+	if err != nil {
+		return fmt.Errorf("error loading fingerprint secret: %v", err)
+	}
+	if secret == nil {
+		return nil
+	}
+	if err := ctx.GetStub().PutPrivateData(to, fingerprintSecretKey, secret); err != nil {
+		return fmt.Errorf("error saving fingerprint secret: %v", err)
+	}
+	return ctx.GetStub().DelPrivateData(from, fingerprintSecretKey)
+}
@@ -0,0 +1,116 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+// newKeyedRegistry returns a registry with keyed fingerprints under secret and a registry admin
+func newKeyedRegistry(t *testing.T, secret string) (*simulator.Simulator, *simulator.Identity) {
+	sim, _ := newRegistrySimulator(t)
+	admin := newRegistryAdmin(t)
+	_, err := sim.Submit(admin, "SetPrivateCollection", "revocations")
+	require.NoError(t, err)
+	tx, err := sim.SubmitTransient(admin, map[string][]byte{cuckoofilter.FingerprintSecretTransientKey: []byte(secret)}, "SetKeyedFingerprints", "true")
+	require.NoError(t, err)
+	var config cuckoofilter.RegistryConfig
+	require.NoError(t, json.Unmarshal(tx.Payload, &config))
+	require.True(t, config.KeyedFingerprints)
+	return sim, admin
+}
+
+func TestKeyedFingerprints_UncorrelatedAcrossRegistries(t *testing.T) {
+	registry1, admin := newKeyedRegistry(t, strings.Repeat("a", cuckoofilter.MinFingerprintSecretSize))
+	registry2, _ := newKeyedRegistry(t, strings.Repeat("b", cuckoofilter.MinFingerprintSecretSize))
+	for _, sim := range []*simulator.Simulator{registry1, registry2} {
+		_, err := sim.SubmitTransient(admin, fingerprintsTransient(t, "credential-1"), "RevokePrivate")
+		require.NoError(t, err)
+		resultJSON, err := sim.EvaluateTransient(admin, fingerprintsTransient(t, "credential-1", "credential-2"), "LookupPrivate")
+		require.NoError(t, err)
+		var results map[string]bool
+		require.NoError(t, json.Unmarshal(resultJSON, &results))
+		require.Equal(t, map[string]bool{"credential-1": true, "credential-2": false}, results)
+	}
+	// The same credential is stored under unrelated fingerprints
+	require.NotEqual(t, registry1.GetPrivateData("revocations", "CuckooFilterState"), registry2.GetPrivateData("revocations", "CuckooFilterState"))
+
+	// Keyed fingerprints can only be switched on an empty filter
+	_, err := registry1.Submit(admin, "SetKeyedFingerprints", "false")
+	require.ErrorContains(t, err, cuckoofilter.FailedPreconditionErrorCode)
+}
+
+func TestKeyedFingerprints_Preconditions(t *testing.T) {
+	sim, _ := newRegistrySimulator(t)
+	admin := newRegistryAdmin(t)
+	secret := map[string][]byte{cuckoofilter.FingerprintSecretTransientKey: []byte(strings.Repeat("a", cuckoofilter.MinFingerprintSecretSize))}
+	// The secret is held in the private data collection of the default filter
+	_, err := sim.SubmitTransient(admin, secret, "SetKeyedFingerprints", "true")
+	require.ErrorContains(t, err, cuckoofilter.InvalidArgumentErrorCode)
+
+	_, err = sim.Submit(admin, "SetPrivateCollection", "revocations")
+	require.NoError(t, err)
+	_, err = sim.SubmitTransient(admin, map[string][]byte{cuckoofilter.FingerprintSecretTransientKey: []byte("short")}, "SetKeyedFingerprints", "true")
+	require.ErrorContains(t, err, cuckoofilter.InvalidArgumentErrorCode)
+	issuer := newIssuerIdentity(t, "Org1MSP", "did:key:issuer")
+	_, err = sim.SubmitTransient(issuer, secret, "SetKeyedFingerprints", "true")
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+
+	_, err = sim.SubmitTransient(admin, secret, "SetKeyedFingerprints", "true")
+	require.NoError(t, err)
+	require.NotNil(t, sim.GetPrivateData("revocations", "FingerprintSecret"))
+	// The secret moves with the filter state
+	_, err = sim.Submit(admin, "SetPrivateCollection", "revocations-v2")
+	require.NoError(t, err)
+	require.Nil(t, sim.GetPrivateData("revocations", "FingerprintSecret"))
+	require.NotNil(t, sim.GetPrivateData("revocations-v2", "FingerprintSecret"))
+	_, err = sim.Submit(admin, "SetPrivateCollection", "")
+	require.ErrorContains(t, err, "private data collection")
+}
+
+func TestConfirmMembership(t *testing.T) {
+	sim, admin := newKeyedRegistry(t, strings.Repeat("a", cuckoofilter.MinFingerprintSecretSize))
+	_, err := sim.SubmitTransient(admin, fingerprintsTransient(t, "credential-1"), "RevokePrivate")
+	require.NoError(t, err)
+
+	auditor, err := simulator.NewIdentityWithAttributes("AuditMSP", "auditor", map[string]string{cuckoofilter.AuditorAttribute: "true"})
+	require.NoError(t, err)
+	resultJSON, err := sim.EvaluateTransient(auditor, fingerprintsTransient(t, "credential-1", "credential-2"), "ConfirmMembership")
+	require.NoError(t, err)
+	var results map[string]bool
+	require.NoError(t, json.Unmarshal(resultJSON, &results))
+	require.Equal(t, map[string]bool{"credential-1": true, "credential-2": false}, results)
+
+	issuer := newIssuerIdentity(t, "Org1MSP", "did:key:issuer")
+	_, err = sim.EvaluateTransient(issuer, fingerprintsTransient(t, "credential-1"), "ConfirmMembership")
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+}
+
+func TestKeyedFingerprints_DefaultFilterOnly(t *testing.T) {
+	secret := map[string][]byte{cuckoofilter.FingerprintSecretTransientKey: []byte(strings.Repeat("a", cuckoofilter.MinFingerprintSecretSize))}
+	sim, admin := newKeyedRegistry(t, string(secret[cuckoofilter.FingerprintSecretTransientKey]))
+	// Named filters and shards would hold plain fingerprints next to the keyed default filter
+	_, err := sim.Submit(admin, "Init", "tenant-a", "100", "4", "0")
+	require.ErrorContains(t, err, cuckoofilter.FailedPreconditionErrorCode)
+	_, err = sim.Submit(admin, "InitShards", "2", "16", "100", "4")
+	require.ErrorContains(t, err, cuckoofilter.FailedPreconditionErrorCode)
+
+	sim, _ = newRegistrySimulator(t)
+	_, err = sim.Submit(admin, "SetPrivateCollection", "revocations")
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "Init", "tenant-a", "100", "4", "0")
+	require.NoError(t, err)
+	_, err = sim.SubmitTransient(admin, secret, "SetKeyedFingerprints", "true")
+	require.ErrorContains(t, err, cuckoofilter.FailedPreconditionErrorCode)
+
+	sim, _ = newRegistrySimulator(t)
+	_, err = sim.Submit(admin, "SetPrivateCollection", "revocations")
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "InitShards", "2", "16", "100", "4")
+	require.NoError(t, err)
+	_, err = sim.SubmitTransient(admin, secret, "SetKeyedFingerprints", "true")
+	require.ErrorContains(t, err, cuckoofilter.FailedPreconditionErrorCode)
+}
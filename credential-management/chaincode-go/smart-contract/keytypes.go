@@ -0,0 +1,315 @@
+package cuckoofilter
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/multiformats/go-multibase"
+	"math/big"
+	"strings"
+)
+
+// Key types of stakeholder DIDs
+const (
+	KeyTypeP256      = "P-256"
+	KeyTypeSecp256k1 = "secp256k1"
+	KeyTypeEd25519   = "Ed25519"
+)
+
+// Multicodec prefixes of the public key in a did:key. P-256 DIDs keep the prefix and uncompressed
+// X || Y encoding they were always issued with, so existing DIDs stay valid; the other key types use
+// the varint encoded codes of the multicodec table and compressed points.
+var (
+	p256Multicodec      = []byte{0x12, 0x00}
+	secp256k1Multicodec = []byte{0xe7, 0x01}
+	ed25519Multicodec   = []byte{0xed, 0x01}
+)
+
+// SigningMethodES256K signs JWTs with secp256k1 keys and RFC 6979 nonces, see RFC 8812
+var SigningMethodES256K jwt.SigningMethod = &deterministicSigningMethod{
+	SigningMethodECDSA: &jwt.SigningMethodECDSA{Name: "ES256K", Hash: crypto.SHA256, KeySize: 32, CurveBits: 256},
+}
+
+// SigningMethodEdDSA signs JWTs with Ed25519 keys, see RFC 8037
+var SigningMethodEdDSA jwt.SigningMethod = &signingMethodEd25519{}
+
+func init() {
+	jwt.RegisterSigningMethod(SigningMethodES256K.Alg(), func() jwt.SigningMethod { return SigningMethodES256K })
+	jwt.RegisterSigningMethod(SigningMethodEdDSA.Alg(), func() jwt.SigningMethod { return SigningMethodEdDSA })
+}
+
+type signingMethodEd25519 struct{}
+
+func (m *signingMethodEd25519) Alg() string {
+	return "EdDSA"
+}
+
+func (m *signingMethodEd25519) Sign(signingString string, key interface{}) (string, error) {
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok || len(privateKey) != ed25519.PrivateKeySize {
+		return "", jwt.ErrInvalidKeyType
+	}
+	return jwt.EncodeSegment(ed25519.Sign(privateKey, []byte(signingString))), nil
+}
+
+func (m *signingMethodEd25519) Verify(signingString, signature string, key interface{}) error {
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok || len(publicKey) != ed25519.PublicKeySize {
+		return jwt.ErrInvalidKeyType
+	}
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(publicKey, []byte(signingString), sig) {
+		return errors.New("ed25519: verification error")
+	}
+	return nil
+}
+
+// generateKey creates a private key of the key type
+func generateKey(keyType string) (crypto.PrivateKey, error) {
+	switch keyType {
+	case KeyTypeP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyTypeSecp256k1:
+		return generateSecp256k1Key()
+	case KeyTypeEd25519:
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		return privateKey, err
+	default:
+		return nil, fmt.Errorf("unsupported key type: %v", keyType)
+	}
+}
+
+// generateSecp256k1Key draws a scalar in [1, n-1], as ecdsa.GenerateKey does not accept custom curves
+func generateSecp256k1Key() (*ecdsa.PrivateKey, error) {
+	curve := Secp256k1()
+	max := new(big.Int).Sub(curve.Params().N, big.NewInt(1))
+	d, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return nil, err
+	}
+	d.Add(d, big.NewInt(1))
+	privateKey := &ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: curve}, D: d}
+	privateKey.PublicKey.X, privateKey.PublicKey.Y = curve.ScalarBaseMult(d.Bytes())
+	return privateKey, nil
+}
+
+// keyTypeOf returns the key type of a public or private key
+func keyTypeOf(key interface{}) (string, error) {
+	switch key := key.(type) {
+	case *ecdsa.PrivateKey:
+		return keyTypeOf(&key.PublicKey)
+	case *ecdsa.PublicKey:
+		switch key.Curve.Params().Name {
+		case elliptic.P256().Params().Name:
+			return KeyTypeP256, nil
+		case Secp256k1().Params().Name:
+			return KeyTypeSecp256k1, nil
+		}
+		return "", fmt.Errorf("unsupported curve: %v", key.Curve.Params().Name)
+	case ed25519.PrivateKey, ed25519.PublicKey:
+		return KeyTypeEd25519, nil
+	default:
+		return "", fmt.Errorf("unsupported key: %T", key)
+	}
+}
+
+// publicKeyOf returns the public key of a private key
+func publicKeyOf(privateKey crypto.PrivateKey) (crypto.PublicKey, error) {
+	switch privateKey := privateKey.(type) {
+	case *ecdsa.PrivateKey:
+		return &privateKey.PublicKey, nil
+	case ed25519.PrivateKey:
+		return privateKey.Public(), nil
+	default:
+		return nil, fmt.Errorf("unsupported key: %T", privateKey)
+	}
+}
+
+// didKey returns the did:key of a public key
+func didKey(publicKey crypto.PublicKey) (string, error) {
+	var keyBytes []byte
+	switch publicKey := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		keyType, err := keyTypeOf(publicKey)
+		if err != nil {
+			return "", err
+		}
+		if keyType == KeyTypeP256 {
+			keyBytes = append(append(append([]byte{}, p256Multicodec...), publicKey.X.Bytes()...), publicKey.Y.Bytes()...)
+		} else {
+			keyBytes = append(append([]byte{}, secp256k1Multicodec...), elliptic.MarshalCompressed(publicKey.Curve, publicKey.X, publicKey.Y)...)
+		}
+	case ed25519.PublicKey:
+		keyBytes = append(append([]byte{}, ed25519Multicodec...), publicKey...)
+	default:
+		return "", fmt.Errorf("unsupported key: %T", publicKey)
+	}
+	encodedValue, err := multibase.Encode(multibase.Base58BTC, keyBytes)
+	if err != nil {
+		return "", fmt.Errorf("error encoding public key: %v", err)
+	}
+	return "did:key:" + encodedValue, nil
+}
+
+// KeyTypeOfDID returns the key type of a did:key from its multicodec prefix
+func KeyTypeOfDID(did string) (string, error) {
+	if !strings.HasPrefix(did, "did:key:") {
+		return "", fmt.Errorf("not a did:key: %v", did)
+	}
+	_, decoded, err := multibase.Decode(strings.TrimPrefix(did, "did:key:"))
+	if err != nil {
+		return "", fmt.Errorf("error decoding did:key: %v", err)
+	}
+	switch {
+	case hasPrefix(decoded, secp256k1Multicodec) && len(decoded) == len(secp256k1Multicodec)+33:
+		return KeyTypeSecp256k1, nil
+	case hasPrefix(decoded, ed25519Multicodec) && len(decoded) == len(ed25519Multicodec)+ed25519.PublicKeySize:
+		return KeyTypeEd25519, nil
+	case hasPrefix(decoded, p256Multicodec):
+		return KeyTypeP256, nil
+	}
+	return "", fmt.Errorf("unsupported multicodec in did:key: %v", did)
+}
+
+func hasPrefix(b []byte, prefix []byte) bool {
+	return len(b) >= len(prefix) && string(b[:len(prefix)]) == string(prefix)
+}
+
+// jwtSigningMethod returns the JWT algorithm of the key type
+func jwtSigningMethod(keyType string) (jwt.SigningMethod, error) {
+	switch keyType {
+	case KeyTypeP256:
+		return SigningMethodES256Deterministic, nil
+	case KeyTypeSecp256k1:
+		return SigningMethodES256K, nil
+	case KeyTypeEd25519:
+		return SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %v", keyType)
+	}
+}
+
+// signJWT signs claims with the JWT algorithm of the key
+func signJWT(claims jwt.Claims, privateKey crypto.PrivateKey) (string, error) {
+	keyType, err := keyTypeOf(privateKey)
+	if err != nil {
+		return "", err
+	}
+	method, err := jwtSigningMethod(keyType)
+	if err != nil {
+		return "", err
+	}
+	return jwt.NewWithClaims(method, claims).SignedString(privateKey)
+}
+
+// checkJWTAlgorithm rejects tokens whose algorithm does not belong to the key type of publicKey, so a
+// token cannot pick the algorithm it is verified with
+func checkJWTAlgorithm(token *jwt.Token, publicKey crypto.PublicKey) error {
+	keyType, err := keyTypeOf(publicKey)
+	if err != nil {
+		return err
+	}
+	method, err := jwtSigningMethod(keyType)
+	if err != nil {
+		return err
+	}
+	if token.Method.Alg() != method.Alg() {
+		return fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return nil
+}
+
+// ecdsaKeyParts is the JSON form of ECDSA keys in the key files
+type ecdsaKeyParts struct {
+	D *big.Int `json:",omitempty"`
+	X *big.Int
+	Y *big.Int
+}
+
+// encodeKeyPair encodes a private key and its public key as stored in the key files
+func encodeKeyPair(privateKey crypto.PrivateKey) (string, string, error) {
+	var privateKeyBytes, publicKeyBytes []byte
+	var err error
+	switch privateKey := privateKey.(type) {
+	case *ecdsa.PrivateKey:
+		if privateKeyBytes, err = json.Marshal(ecdsaKeyParts{D: privateKey.D, X: privateKey.X, Y: privateKey.Y}); err != nil {
+			return "", "", fmt.Errorf("error marshalling private key: %v", err)
+		}
+		if publicKeyBytes, err = json.Marshal(ecdsaKeyParts{X: privateKey.X, Y: privateKey.Y}); err != nil {
+			return "", "", fmt.Errorf("error marshalling public key: %v", err)
+		}
+	case ed25519.PrivateKey:
+		privateKeyBytes = privateKey
+		publicKeyBytes = privateKey.Public().(ed25519.PublicKey)
+	default:
+		return "", "", fmt.Errorf("unsupported key: %T", privateKey)
+	}
+	return base64.StdEncoding.EncodeToString(privateKeyBytes), base64.StdEncoding.EncodeToString(publicKeyBytes), nil
+}
+
+// decodePrivateKey decodes a private key of the key type written by encodeKeyPair
+func decodePrivateKey(keyType string, privateKeyString string) (crypto.PrivateKey, error) {
+	privateKeyBytes, err := base64.StdEncoding.DecodeString(privateKeyString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode base64 private key: %v", err)
+	}
+	if keyType == KeyTypeEd25519 {
+		if len(privateKeyBytes) != ed25519.PrivateKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 private key of %d bytes", len(privateKeyBytes))
+		}
+		return ed25519.PrivateKey(privateKeyBytes), nil
+	}
+	curve, err := keyTypeCurve(keyType)
+	if err != nil {
+		return nil, err
+	}
+	var parts ecdsaKeyParts
+	if err := json.Unmarshal(privateKeyBytes, &parts); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal private key: %v", err)
+	}
+	return &ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: curve, X: parts.X, Y: parts.Y}, D: parts.D}, nil
+}
+
+// decodePublicKey decodes a public key of the key type written by encodeKeyPair
+func decodePublicKey(keyType string, publicKeyString string) (crypto.PublicKey, error) {
+	publicKeyBytes, err := base64.StdEncoding.DecodeString(publicKeyString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode base64 public key: %v", err)
+	}
+	if keyType == KeyTypeEd25519 {
+		if len(publicKeyBytes) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 public key of %d bytes", len(publicKeyBytes))
+		}
+		return ed25519.PublicKey(publicKeyBytes), nil
+	}
+	curve, err := keyTypeCurve(keyType)
+	if err != nil {
+		return nil, err
+	}
+	var parts ecdsaKeyParts
+	if err := json.Unmarshal(publicKeyBytes, &parts); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal public key: %v", err)
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: parts.X, Y: parts.Y}, nil
+}
+
+func keyTypeCurve(keyType string) (elliptic.Curve, error) {
+	switch keyType {
+	case KeyTypeP256:
+		return elliptic.P256(), nil
+	case KeyTypeSecp256k1:
+		return Secp256k1(), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %v", keyType)
+	}
+}
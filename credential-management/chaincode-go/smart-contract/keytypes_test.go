@@ -0,0 +1,149 @@
+package cuckoofilter_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/multiformats/go-multibase"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateDIDWithKeyType_Multicodec(t *testing.T) {
+	useStakeholderDir(t)
+	contract := &cuckoofilter.StakeholderManagementContract{Clock: clock.System}
+	mockCtx := newStakeholderContext()
+
+	tests := []struct {
+		keyType string
+		prefix  []byte
+		length  int
+	}{
+		{cuckoofilter.KeyTypeSecp256k1, []byte{0xe7, 0x01}, 2 + 33},
+		{cuckoofilter.KeyTypeEd25519, []byte{0xed, 0x01}, 2 + ed25519.PublicKeySize},
+	}
+	for _, tt := range tests {
+		didResponse, err := contract.GenerateDIDWithKeyType(mockCtx, "issuer", tt.keyType)
+		require.NoError(t, err, tt.keyType)
+		require.Equal(t, tt.keyType, didResponse.KeyType)
+
+		encoding, decoded, err := multibase.Decode(strings.TrimPrefix(didResponse.DID, "did:key:"))
+		require.NoError(t, err)
+		require.Equal(t, int32(multibase.Base58BTC), int32(encoding))
+		require.Equal(t, tt.prefix, decoded[:2], tt.keyType)
+		require.Len(t, decoded, tt.length, tt.keyType)
+
+		keyType, err := cuckoofilter.KeyTypeOfDID(didResponse.DID)
+		require.NoError(t, err)
+		require.Equal(t, tt.keyType, keyType)
+	}
+
+	// secp256k1 keys are compressed points on the curve
+	didResponse, err := contract.GenerateDIDWithKeyType(mockCtx, "issuer", cuckoofilter.KeyTypeSecp256k1)
+	require.NoError(t, err)
+	_, decoded, err := multibase.Decode(strings.TrimPrefix(didResponse.DID, "did:key:"))
+	require.NoError(t, err)
+	require.Contains(t, []byte{0x02, 0x03}, decoded[2])
+	params := cuckoofilter.Secp256k1().Params()
+	x := new(big.Int).SetBytes(decoded[3:])
+	y2 := new(big.Int).Exp(x, big.NewInt(3), params.P)
+	y2.Add(y2, params.B)
+	y := new(big.Int).ModSqrt(y2.Mod(y2, params.P), params.P)
+	require.NotNil(t, y, "x is not on the curve")
+	if y.Bit(0) != uint(decoded[2]&1) {
+		y.Sub(params.P, y)
+	}
+	require.True(t, cuckoofilter.Secp256k1().IsOnCurve(x, y))
+
+	// P-256 DIDs keep their format
+	didResponse, err = contract.GenerateDID(mockCtx, "issuer")
+	require.NoError(t, err)
+	require.Equal(t, cuckoofilter.KeyTypeP256, didResponse.KeyType)
+	keyType, err := cuckoofilter.KeyTypeOfDID(didResponse.DID)
+	require.NoError(t, err)
+	require.Equal(t, cuckoofilter.KeyTypeP256, keyType)
+
+	_, err = contract.GenerateDIDWithKeyType(mockCtx, "issuer", "RSA")
+	require.EqualError(t, err, "error generating key: unsupported key type: RSA")
+}
+
+func TestCredentialLifecycle_KeyTypes(t *testing.T) {
+	useStakeholderDir(t)
+	contract := &cuckoofilter.StakeholderManagementContract{Clock: clock.System}
+	mockCtx := newStakeholderContext()
+	algs := map[string]string{
+		cuckoofilter.KeyTypeP256:      "ES256",
+		cuckoofilter.KeyTypeSecp256k1: "ES256K",
+		cuckoofilter.KeyTypeEd25519:   "EdDSA",
+	}
+
+	for keyType, alg := range algs {
+		issuer, err := contract.GenerateDIDWithKeyType(mockCtx, "issuer", keyType)
+		require.NoError(t, err, keyType)
+		holder, err := contract.GenerateDID(mockCtx, "holder")
+		require.NoError(t, err)
+
+		credential, err := contract.IssuingCredential(mockCtx, issuer.DID, holder.DID)
+		require.NoError(t, err, keyType)
+
+		valid, err := contract.VerifyingCredential(mockCtx, "", "verifier", holder.DID, issuer.DID)
+		require.NoError(t, err, keyType)
+		require.True(t, valid)
+
+		issued, err := os.ReadFile("./holderCredentials/" + holder.DID + ".jwt")
+		require.NoError(t, err)
+		token, _, err := new(jwt.Parser).ParseUnverified(string(issued), jwt.MapClaims{})
+		require.NoError(t, err)
+		require.Equal(t, alg, token.Header["alg"], keyType)
+
+		// A token of another algorithm does not verify against the issuer, even if it is well formed
+		otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		claims, err := credential.ToJWTClaims()
+		require.NoError(t, err)
+		forged, err := jwt.NewWithClaims(jwt.SigningMethodES256, claims).SignedString(otherKey)
+		require.NoError(t, err)
+		_, err = contract.VerifyingCredential(mockCtx, forged, "verifier", holder.DID, issuer.DID)
+		require.Error(t, err, keyType)
+	}
+}
+
+func TestVerifyProof_KeyTypes(t *testing.T) {
+	issuedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	secp256k1Key := privateKeyFromD(cuckoofilter.Secp256k1(), hexInt(t, "C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721"))
+	ed25519Public, ed25519Private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	keys := []struct {
+		private, public interface{}
+		proofType       string
+	}{
+		{p256Key, &p256Key.PublicKey, cuckoofilter.ProofTypeECDSA},
+		{secp256k1Key, &secp256k1Key.PublicKey, cuckoofilter.ProofTypeECDSA},
+		{ed25519Private, ed25519Public, cuckoofilter.ProofTypeEd25519},
+	}
+	for _, key := range keys {
+		credential, err := cuckoofilter.CreateAndSignCredentialAt(issuedAt, "did:key:issuer", key.private, "did:key:holder", "-1")
+		require.NoError(t, err)
+		require.Equal(t, key.proofType, credential.Proof.Type)
+		require.NoError(t, cuckoofilter.VerifyProof(credential, key.public))
+
+		credential.CredentialSubject.ID = "did:key:other"
+		require.ErrorIs(t, cuckoofilter.VerifyProof(credential, key.public), cuckoofilter.ErrInvalidProof)
+	}
+
+	// A proof does not verify with a key of another type
+	credential, err := cuckoofilter.CreateAndSignCredentialAt(issuedAt, "did:key:issuer", ed25519Private, "did:key:holder", "-1")
+	require.NoError(t, err)
+	require.ErrorIs(t, cuckoofilter.VerifyProof(credential, &p256Key.PublicKey), cuckoofilter.ErrInvalidProof)
+}
@@ -0,0 +1,417 @@
+package cuckoofilter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"strconv"
+)
+
+// State layouts of the default filter, see BeginShardedMigration
+const (
+	StateLayoutSingle  = ""        // The whole filter under one key, CuckooFilterState
+	StateLayoutDual    = "dual"    // Migration window: writes go to both layouts, reads use the sharded one
+	StateLayoutSharded = "sharded" // Bucket ranges under filterStateShard~<index>, described by a header key
+)
+
+// MaxStateShards is the largest number of shards of the sharded layout
+const MaxStateShards = 256
+
+const (
+	filterStateHeaderKey       = "CuckooFilterStateHeader"
+	filterStateShardObjectType = "filterStateShard" // filterStateShard~<index> holds a range of buckets
+)
+
+// ErrMigrationMismatch is returned by FinalizeMigration when the sharded state does not hold the same
+// filter as the legacy state
+var ErrMigrationMismatch = fmt.Errorf("%w: sharded filter state does not match the legacy filter state", ErrStateCorrupt)
+
+// StateMigrationResult describes the default filter after a layout migration step
+type StateMigrationResult struct {
+	Layout string `json:"layout"`
+	Shards uint   `json:"shards"`
+	Count  uint   `json:"count"`
+	Hash   string `json:"hash"` // Hex encoded SHA-256 of the filter state in the single key layout
+}
+
+// filterStateHeader holds the filter fields of the sharded layout besides the buckets. Shard i holds
+// the buckets from i*Buckets/Shards up to (i+1)*Buckets/Shards.
+type filterStateHeader struct {
+	Shards          uint     `json:"shards"`
+	Buckets         uint     `json:"buckets"`
+	Count           uint     `json:"count"`
+	BucketIndexMask uint     `json:"bucketIndexMask"`
+	FingerprintSize uint     `json:"fingerprintSize"`
+	MaxKicks        uint     `json:"maxKicks,omitempty"`
+	SemiSorted      bool     `json:"semiSorted,omitempty"`
+	ShardHashes     [][]byte `json:"shardHashes"` // SHA-256 of every shard state
+}
+
+// shardRange returns the buckets of a shard
+func (h *filterStateHeader) shardRange(shard uint) (uint, uint) {
+	return shard * h.Buckets / h.Shards, (shard + 1) * h.Buckets / h.Shards
+}
+
+// filterStateShard is the state of one shard, packed like the whole filter state
+type filterStateShard struct {
+	SerializedBuckets [][][]byte `json:",omitempty"`
+	PackedBuckets     []byte     `json:",omitempty"`
+}
+
+// BeginShardedMigration starts moving the default filter from the single key layout to shards of bucket
+// ranges, so a transaction only writes the shards it changed. Until FinalizeMigration, every write goes
+// to both layouts and reads use the sharded one, so the registry stays available and the migration can
+// be verified against the legacy state. The key-level endorsement policy of the filter state is copied
+// to the shards. The sharded layout cannot be combined with delta persistence or a private data collection.
+// Registry admins only.
+func (s *SmartContract) BeginShardedMigration(ctx contractapi.TransactionContextInterface, shards uint) (*StateMigrationResult, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	if err := checkRegistryAdmin(ctx, "migrate the filter state layout"); err != nil {
+		return nil, err
+	}
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if config.StateLayout != StateLayoutSingle {
+		return nil, fmt.Errorf("filter state layout is already %s", config.StateLayout)
+	}
+	filter, err := s.LoadFilterState(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error loading filter state: %v", err)
+	}
+
+	config.Version++
+	config.StateLayout = StateLayoutDual
+	config.StateShards = shards
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if err := saveRegistryConfig(ctx, config); err != nil {
+		return nil, err
+	}
+	// Rewrite both layouts from the same filter, so they compare equal on FinalizeMigration. The new
+	// configuration is not visible to reads of this transaction, so saveFilterState would miss the shards.
+	if err := saveShardedFilter(ctx, shards, filter); err != nil {
+		return nil, err
+	}
+	filterJSON, err := json.Marshal(filter)
+	if err != nil {
+		return nil, err
+	}
+	if err := putStateWithHash(ctx, filterStateKey, filterStateKey, filterJSON); err != nil {
+		return nil, err
+	}
+	policy, err := ctx.GetStub().GetStateValidationParameter(filterStateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read validation parameter of filter state: %v", err)
+	}
+	if policy != nil {
+		if err := setShardedValidationParameter(ctx, shards, policy); err != nil {
+			return nil, err
+		}
+	}
+	return migrationResult(config, filter)
+}
+
+// FinalizeMigration ends the migration window of BeginShardedMigration. It verifies that the sharded
+// state holds the same filter as the legacy state, comparing the state hash of the legacy key with the
+// hash of the filter assembled from the shards, and removes the legacy key. Registry admins only.
+func (s *SmartContract) FinalizeMigration(ctx contractapi.TransactionContextInterface) (*StateMigrationResult, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	if err := checkRegistryAdmin(ctx, "migrate the filter state layout"); err != nil {
+		return nil, err
+	}
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if config.StateLayout != StateLayoutDual {
+		return nil, errors.New("no filter state migration in progress")
+	}
+
+	legacyJSON, err := ctx.GetStub().GetState(filterStateKey)
+	if err != nil {
+		return nil, fmt.Errorf("error loading filter state: %v", err)
+	}
+	if legacyJSON == nil {
+		return nil, errFilterStateNotFound
+	}
+	if err := verifyStateHash(ctx, filterStateKey, legacyJSON); err != nil {
+		return nil, err
+	}
+	filter, err := loadShardedFilter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shardedJSON, err := json.Marshal(filter)
+	if err != nil {
+		return nil, err
+	}
+	if sha256.Sum256(shardedJSON) != sha256.Sum256(legacyJSON) {
+		return nil, ErrMigrationMismatch
+	}
+
+	hashKey, err := ctx.GetStub().CreateCompositeKey(stateHashObjectType, []string{filterStateKey})
+	if err != nil {
+		return nil, fmt.Errorf("error creating state hash key: %v", err)
+	}
+	for _, key := range []string{filterStateKey, hashKey} {
+		if err := ctx.GetStub().DelState(key); err != nil {
+			return nil, fmt.Errorf("error deleting %s: %v", key, err)
+		}
+	}
+	config.Version++
+	config.StateLayout = StateLayoutSharded
+	if err := saveRegistryConfig(ctx, config); err != nil {
+		return nil, err
+	}
+	return migrationResult(config, filter)
+}
+
+func migrationResult(config *RegistryConfig, filter *Filter) (*StateMigrationResult, error) {
+	filterJSON, err := json.Marshal(filter)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(filterJSON)
+	return &StateMigrationResult{Layout: config.StateLayout, Shards: config.StateShards, Count: filter.Count, Hash: hex.EncodeToString(hash[:])}, nil
+}
+
+func filterStateShardKey(ctx contractapi.TransactionContextInterface, shard uint) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(filterStateShardObjectType, []string{strconv.FormatUint(uint64(shard), 10)})
+	if err != nil {
+		return "", fmt.Errorf("error creating filter state shard key: %v", err)
+	}
+	return key, nil
+}
+
+// saveShardedFilter writes the shards of the filter that changed and the header
+func saveShardedFilter(ctx contractapi.TransactionContextInterface, shards uint, filter *Filter) error {
+	previous, err := readShardedFilterState(ctx)
+	if err != nil {
+		return err
+	}
+	header := &filterStateHeader{
+		Shards:          shards,
+		Buckets:         uint(len(filter.Buckets)),
+		Count:           filter.Count,
+		BucketIndexMask: filter.BucketIndexMask,
+		FingerprintSize: filter.FingerprintSize,
+		MaxKicks:        filter.MaxKicks,
+		SemiSorted:      filter.SemiSorted,
+		ShardHashes:     make([][]byte, shards),
+	}
+	for shard := uint(0); shard < shards; shard++ {
+		start, end := header.shardRange(shard)
+		var state filterStateShard
+		if filter.SemiSorted {
+			if state.PackedBuckets, err = packBuckets(filter.Buckets[start:end], filter.fingerprintSize()); err != nil {
+				return err
+			}
+		} else {
+			state.SerializedBuckets = serializeBuckets(filter.Buckets[start:end])
+		}
+		shardJSON, err := json.Marshal(&state)
+		if err != nil {
+			return err
+		}
+		hash := sha256.Sum256(shardJSON)
+		header.ShardHashes[shard] = hash[:]
+		if previous != nil && previous.header.Shards == shards && string(previous.header.ShardHashes[shard]) == string(hash[:]) {
+			continue
+		}
+		key, err := filterStateShardKey(ctx, shard)
+		if err != nil {
+			return err
+		}
+		if err := ctx.GetStub().PutState(key, shardJSON); err != nil {
+			return fmt.Errorf("error saving filter state shard %d: %v", shard, err)
+		}
+	}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	return putStateWithHash(ctx, filterStateHeaderKey, filterStateHeaderKey, headerJSON)
+}
+
+// shardedFilterState is the sharded layout as read from the ledger
+type shardedFilterState struct {
+	header     filterStateHeader
+	headerJSON []byte
+	storedHash []byte // Hash stored with the header
+	shards     [][]byte
+}
+
+// readShardedFilterState reads the header and the shards of the sharded layout, nil if there is none
+func readShardedFilterState(ctx contractapi.TransactionContextInterface) (*shardedFilterState, error) {
+	headerJSON, err := ctx.GetStub().GetState(filterStateHeaderKey)
+	if err != nil {
+		return nil, fmt.Errorf("error loading filter state header: %v", err)
+	}
+	if headerJSON == nil {
+		return nil, nil
+	}
+	state := &shardedFilterState{headerJSON: headerJSON}
+	if state.storedHash, err = storedStateHash(ctx, "", filterStateHeaderKey); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(headerJSON, &state.header); err != nil {
+		return nil, fmt.Errorf("%w: error decoding filter state header: %v", ErrCorruptState, err)
+	}
+	if state.header.Shards == 0 || state.header.Shards > MaxStateShards || uint(len(state.header.ShardHashes)) != state.header.Shards {
+		return nil, fmt.Errorf("%w: filter state header lists %d shards", ErrCorruptState, len(state.header.ShardHashes))
+	}
+	for shard := uint(0); shard < state.header.Shards; shard++ {
+		key, err := filterStateShardKey(ctx, shard)
+		if err != nil {
+			return nil, err
+		}
+		shardJSON, err := ctx.GetStub().GetState(key)
+		if err != nil {
+			return nil, fmt.Errorf("error loading filter state shard %d: %v", shard, err)
+		}
+		state.shards = append(state.shards, shardJSON)
+	}
+	return state, nil
+}
+
+// verify checks the header against its stored hash and the shards against the header
+func (s *shardedFilterState) verify() error {
+	if s.storedHash == nil {
+		return ErrCorruptState
+	}
+	if err := checkStateHash(s.storedHash, s.headerJSON); err != nil {
+		return err
+	}
+	for shard, shardJSON := range s.shards {
+		if err := checkStateHash(s.header.ShardHashes[shard], shardJSON); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decode assembles the filter from the shards
+func (s *shardedFilterState) decode() (*Filter, error) {
+	filter := &Filter{
+		Buckets:         make([]*bucket, 0, s.header.Buckets),
+		Count:           s.header.Count,
+		BucketIndexMask: s.header.BucketIndexMask,
+		FingerprintSize: s.header.FingerprintSize,
+		MaxKicks:        s.header.MaxKicks,
+		SemiSorted:      s.header.SemiSorted,
+	}
+	for shard, shardJSON := range s.shards {
+		var state filterStateShard
+		if err := json.Unmarshal(shardJSON, &state); err != nil {
+			return nil, fmt.Errorf("error decoding filter state shard %d: %v", shard, err)
+		}
+		if !filter.SemiSorted {
+			filter.Buckets = append(filter.Buckets, deserializeBuckets(state.SerializedBuckets)...)
+			continue
+		}
+		start, end := s.header.shardRange(uint(shard))
+		buckets, err := unpackBuckets(state.PackedBuckets, uint64(end-start), filter.fingerprintSize())
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorruptFilter, err)
+		}
+		filter.Buckets = append(filter.Buckets, buckets...)
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// loadShardedFilter retrieves the default filter from the sharded layout, failing with ErrCorruptState
+// when the header or a shard does not match its hash
+func loadShardedFilter(ctx contractapi.TransactionContextInterface) (*Filter, error) {
+	state, err := readShardedFilterState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return nil, errFilterStateNotFound
+	}
+	if err := state.verify(); err != nil {
+		return nil, err
+	}
+	if filter := filterCache.get(state.storedHash); filter != nil {
+		return filter, nil
+	}
+	filter, err := state.decode()
+	if err != nil {
+		return nil, err
+	}
+	filterCache.put(state.storedHash, filter)
+	return filter, nil
+}
+
+// filterPolicyKey returns the key carrying the key-level endorsement policy of the default filter
+func filterPolicyKey(ctx contractapi.TransactionContextInterface) (string, error) {
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	if config.StateLayout == StateLayoutSharded {
+		return filterStateHeaderKey, nil
+	}
+	return filterStateKey, nil
+}
+
+// shardedStateKeys returns the keys of the header, its state hash and the shards of the sharded layout
+func shardedStateKeys(ctx contractapi.TransactionContextInterface, shards uint) ([]string, error) {
+	hashKey, err := stateHashKey(ctx, filterStateHeaderKey)
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{filterStateHeaderKey, hashKey}
+	for shard := uint(0); shard < shards; shard++ {
+		key, err := filterStateShardKey(ctx, shard)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// setShardedValidationParameter sets a key-level endorsement policy on the header and shards of the sharded layout
+func setShardedValidationParameter(ctx contractapi.TransactionContextInterface, shards uint, policy []byte) error {
+	keys, err := shardedStateKeys(ctx, shards)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := ctx.GetStub().SetStateValidationParameter(key, policy); err != nil {
+			return fmt.Errorf("failed to set validation parameter on %s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// shardedFilterStateHash is GetFilterStateHash for the sharded layout
+func shardedFilterStateHash(ctx contractapi.TransactionContextInterface) (*FilterStateHash, error) {
+	state, err := readShardedFilterState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return nil, errFilterStateNotFound
+	}
+	hash := sha256.Sum256(state.headerJSON)
+	return &FilterStateHash{
+		Hash:   hex.EncodeToString(hash[:]),
+		Stored: hex.EncodeToString(state.storedHash),
+		Match:  state.verify() == nil,
+	}, nil
+}
@@ -0,0 +1,114 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func submitMigration(t *testing.T, sim *simulator.Simulator, identity *simulator.Identity, function string, args ...string) *cuckoofilter.StateMigrationResult {
+	tx, err := sim.Submit(identity, function, args...)
+	require.NoError(t, err)
+	var result cuckoofilter.StateMigrationResult
+	require.NoError(t, json.Unmarshal(tx.Payload, &result))
+	return &result
+}
+
+func requireFilterStateHashMatch(t *testing.T, sim *simulator.Simulator, identity *simulator.Identity, expected bool) {
+	hashJSON, err := sim.Evaluate(identity, "GetFilterStateHash")
+	require.NoError(t, err)
+	var hash cuckoofilter.FilterStateHash
+	require.NoError(t, json.Unmarshal(hashJSON, &hash))
+	require.Equal(t, expected, hash.Match)
+}
+
+func TestShardedMigration(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	registryAdmin := newRegistryAdmin(t)
+	for i := 0; i < 20; i++ {
+		_, err := sim.Submit(admin, "Insert", "", fmt.Sprintf("credential-%d", i))
+		require.NoError(t, err)
+	}
+
+	begun := submitMigration(t, sim, registryAdmin, "BeginShardedMigration", "4")
+	require.Equal(t, cuckoofilter.StateLayoutDual, begun.Layout)
+	require.Equal(t, uint(20), begun.Count)
+
+	// Writes during the migration window go to both layouts
+	_, err := sim.Submit(admin, "Insert", "", "credential-20")
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "Delete", "", "credential-0")
+	require.NoError(t, err)
+	requireLookup(t, sim, admin, "", "credential-20", true)
+	requireLookup(t, sim, admin, "", "credential-0", false)
+	requireFilterStateHashMatch(t, sim, admin, true)
+	require.NotNil(t, sim.GetState("CuckooFilterState"))
+
+	finalized := submitMigration(t, sim, registryAdmin, "FinalizeMigration")
+	require.Equal(t, cuckoofilter.StateLayoutSharded, finalized.Layout)
+	require.Equal(t, uint(4), finalized.Shards)
+	require.Equal(t, uint(20), finalized.Count)
+	require.Nil(t, sim.GetState("CuckooFilterState"))
+
+	for i := 1; i <= 20; i++ {
+		requireLookup(t, sim, admin, "", fmt.Sprintf("credential-%d", i), true)
+	}
+	_, err = sim.Submit(admin, "Insert", "", "credential-21")
+	require.NoError(t, err)
+	requireLookup(t, sim, admin, "", "credential-21", true)
+	requireFilterStateHashMatch(t, sim, admin, true)
+
+	filtersJSON, err := sim.Evaluate(admin, "ListFilters")
+	require.NoError(t, err)
+	var filters []*cuckoofilter.FilterInfo
+	require.NoError(t, json.Unmarshal(filtersJSON, &filters))
+	require.Equal(t, uint(21), filters[0].Count)
+
+	_, err = sim.Submit(registryAdmin, "FinalizeMigration")
+	require.Error(t, err)
+	_, err = sim.Submit(registryAdmin, "BeginShardedMigration", "4")
+	require.Error(t, err)
+}
+
+func TestShardedMigration_TamperedShardFails(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	registryAdmin := newRegistryAdmin(t)
+	_, err := sim.Submit(admin, "Insert", "", "credential-1")
+	require.NoError(t, err)
+	_, err = sim.Submit(registryAdmin, "BeginShardedMigration", "2")
+	require.NoError(t, err)
+
+	require.NoError(t, sim.SetState("\x00filterStateShard\x001\x00", []byte(`{}`)))
+	requireFilterStateHashMatch(t, sim, admin, false)
+	_, err = sim.Evaluate(admin, "Lookup", "", "credential-1")
+	require.ErrorContains(t, err, cuckoofilter.ErrCorruptState.Error())
+	_, err = sim.Submit(registryAdmin, "FinalizeMigration")
+	require.ErrorContains(t, err, cuckoofilter.ErrCorruptState.Error())
+}
+
+func TestShardedMigration_RejectsInvalidConfig(t *testing.T) {
+	sim, _ := newRegistrySimulator(t)
+	registryAdmin := newRegistryAdmin(t)
+	_, err := sim.Submit(registryAdmin, "BeginShardedMigration", "0")
+	require.Error(t, err)
+	_, err = sim.Submit(registryAdmin, "FinalizeMigration")
+	require.Error(t, err)
+
+	_, err = sim.Submit(registryAdmin, "SetDeltaPersistence", "true")
+	require.NoError(t, err)
+	_, err = sim.Submit(registryAdmin, "BeginShardedMigration", "4")
+	require.Error(t, err)
+}
+
+func TestShardedMigration_AdminsOnly(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	_, err := sim.Submit(admin, "BeginShardedMigration", "4")
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+	_, err = sim.Submit(newRegistryAdmin(t), "BeginShardedMigration", "4")
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "FinalizeMigration")
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+}
@@ -0,0 +1,98 @@
+package cuckoofilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"time"
+)
+
+// lifecycleEventObjectType is the composite key prefix of the per-credential lifecycle events.
+// Keys are credentialEvent~<data>~<time>~<txID>~<kind>, so the events of a credential sort chronologically.
+const lifecycleEventObjectType = "credentialEvent"
+
+// Kinds of lifecycle events
+const (
+	LifecycleParked             = "parked"
+	LifecycleActivated          = "activated"
+	LifecycleRevocationRequest  = "revocation-requested"
+	LifecycleRevocationRejected = "revocation-rejected"
+	LifecycleRevoked            = "revoked"
+	LifecycleUnrevoked          = "unrevoked"
+)
+
+// LifecycleEvent is one step in the life of a credential's registry entry
+type LifecycleEvent struct {
+	Kind      string    `json:"kind"`
+	TxID      string    `json:"txId"`
+	Timestamp time.Time `json:"timestamp"`
+	Actor     Inserter  `json:"actor"`
+	Details   string    `json:"details,omitempty" metadata:",optional"`
+}
+
+// CredentialLifecycle is the chronological timeline of a credential together with its current status
+type CredentialLifecycle struct {
+	CredentialID string           `json:"credentialId"`
+	Status       string           `json:"status"` // As reported by LookupStatus
+	Events       []LifecycleEvent `json:"events"`
+}
+
+// GetCredentialLifecycle returns everything the ledger recorded about a credential, identified by its
+// registry entry (the fingerprint), in chronological order. Entries written before lifecycle events were
+// recorded only report their current status.
+func (s *SmartContract) GetCredentialLifecycle(ctx contractapi.TransactionContextInterface, credentialID string) (*CredentialLifecycle, error) {
+	status, err := s.LookupStatus(ctx, credentialID)
+	if err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(lifecycleEventObjectType, []string{credentialID})
+	if err != nil {
+		return nil, fmt.Errorf("error reading lifecycle events: %v", err)
+	}
+	defer iterator.Close()
+
+	lifecycle := &CredentialLifecycle{CredentialID: credentialID, Status: status, Events: []LifecycleEvent{}}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error reading lifecycle events: %v", err)
+		}
+		var event LifecycleEvent
+		if err := json.Unmarshal(entry.Value, &event); err != nil {
+			return nil, fmt.Errorf("error decoding lifecycle event: %v", err)
+		}
+		lifecycle.Events = append(lifecycle.Events, event)
+	}
+	return lifecycle, nil
+}
+
+// recordLifecycleEvent appends an event of the current transaction to the timeline of each credential
+func recordLifecycleEvent(ctx contractapi.TransactionContextInterface, kind string, details string, dataItems ...string) error {
+	if len(dataItems) == 0 {
+		return nil
+	}
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	actor, _, err := clientInserter(ctx)
+	if err != nil {
+		return err
+	}
+	event := LifecycleEvent{Kind: kind, TxID: ctx.GetStub().GetTxID(), Timestamp: timestamp, Actor: *actor, Details: details}
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	for _, data := range dataItems {
+		key, err := ctx.GetStub().CreateCompositeKey(lifecycleEventObjectType, []string{data, timestamp.Format(auditTimeFormat), event.TxID, kind})
+		if err != nil {
+			return fmt.Errorf("error creating lifecycle event key: %v", err)
+		}
+		if err := ctx.GetStub().PutState(key, eventJSON); err != nil {
+			return fmt.Errorf("error saving lifecycle event: %v", err)
+		}
+	}
+	return recordWriteProvenance(ctx, kind, dataItems...)
+}
@@ -0,0 +1,51 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestGetCredentialLifecycle(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	for _, tx := range [][]string{
+		{"ParkCredential", "credential-1"},
+		{"ActivateCredential", "credential-1"},
+		{"Insert", "", "credential-1"},
+		{"Delete", "", "credential-1"},
+		{"BatchInsert", "", `["credential-1","credential-2"]`},
+	} {
+		_, err := sim.Submit(admin, tx[0], tx[1:]...)
+		require.NoError(t, err, tx[0])
+	}
+
+	lifecycleJSON, err := sim.Evaluate(admin, "GetCredentialLifecycle", "credential-1")
+	require.NoError(t, err)
+	var lifecycle cuckoofilter.CredentialLifecycle
+	require.NoError(t, json.Unmarshal(lifecycleJSON, &lifecycle))
+	require.Equal(t, cuckoofilter.CredentialStatusRevoked, lifecycle.Status)
+
+	var kinds []string
+	for i, event := range lifecycle.Events {
+		kinds = append(kinds, event.Kind)
+		require.Equal(t, "Org1MSP", event.Actor.MSPID)
+		if i > 0 {
+			require.True(t, event.Timestamp.After(lifecycle.Events[i-1].Timestamp))
+		}
+	}
+	require.Equal(t, []string{
+		cuckoofilter.LifecycleParked,
+		cuckoofilter.LifecycleActivated,
+		cuckoofilter.LifecycleRevoked,
+		cuckoofilter.LifecycleUnrevoked,
+		cuckoofilter.LifecycleRevoked,
+	}, kinds)
+
+	// Credentials the registry never saw have an empty timeline
+	lifecycleJSON, err = sim.Evaluate(admin, "GetCredentialLifecycle", "credential-3")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(lifecycleJSON, &lifecycle))
+	require.Equal(t, cuckoofilter.CredentialStatusActive, lifecycle.Status)
+	require.Empty(t, lifecycle.Events)
+}
@@ -0,0 +1,13 @@
+package cuckoofilter
+
+// MembershipFilter is an approximate set of revoked credentials. Lookup may report false positives
+// but never false negatives for inserted data that was not deleted.
+type MembershipFilter interface {
+	// Insert adds data and reports whether it was added, false for duplicates and a full filter
+	Insert(data []byte) bool
+	Lookup(data []byte) bool
+	// Delete removes data and reports whether it was found
+	Delete(data []byte) bool
+}
+
+var _ MembershipFilter = (*Filter)(nil)
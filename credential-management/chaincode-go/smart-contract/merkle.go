@@ -0,0 +1,144 @@
+package cuckoofilter
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/pherbke/credential-management/chaincode-go/core"
+	"sort"
+)
+
+// merkleRootObjectType is the composite key prefix of the Merkle roots committed with filter states,
+// merkleRoot~<state name>. Roots are kept in the public state, also for a default filter kept in a
+// private data collection, so every channel member can read them.
+const merkleRootObjectType = "merkleRoot"
+
+// bucketLeaves returns the Merkle leaf hashes of the filter buckets
+func (f *Filter) bucketLeaves() [][]byte {
+	leaves := make([][]byte, len(f.Buckets))
+	for i, b := range f.Buckets {
+		var fingerprints [][]byte
+		if b != nil {
+			for _, fp := range b.Data {
+				fingerprints = append(fingerprints, fp)
+			}
+		}
+		leaves[i] = core.BucketLeaf(uint(i), fingerprints)
+	}
+	return leaves
+}
+
+// putMerkleRoot commits the Merkle root over the buckets of a filter state
+func putMerkleRoot(ctx contractapi.TransactionContextInterface, name string, filter *Filter) error {
+	key, err := ctx.GetStub().CreateCompositeKey(merkleRootObjectType, []string{name})
+	if err != nil {
+		return fmt.Errorf("error creating Merkle root key: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, core.MerkleRoot(filter.bucketLeaves())); err != nil {
+		return fmt.Errorf("error saving Merkle root of %s: %v", name, err)
+	}
+	return nil
+}
+
+// storedMerkleRoot returns the Merkle root committed with a filter, failing for filters last written
+// before roots were committed
+func storedMerkleRoot(ctx contractapi.TransactionContextInterface, filterID string) ([]byte, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(merkleRootObjectType, []string{filterStateName(filterID)})
+	if err != nil {
+		return nil, fmt.Errorf("error creating Merkle root key: %v", err)
+	}
+	root, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("error loading Merkle root: %v", err)
+	}
+	if root == nil {
+		return nil, fmt.Errorf("%w: no Merkle root committed for filter '%s', it is committed with the next write", ErrFailedPrecondition, filterID)
+	}
+	return root, nil
+}
+
+// filterStateName returns the name identifying the state of a filter in hash and root keys
+func filterStateName(filterID string) string {
+	if filterID == DefaultFilterID {
+		return filterStateKey
+	}
+	return namedFilterStateName(filterID)
+}
+
+// filterMerkleRoot returns the Merkle root of a filter, the stored filter or nil to load it. With delta
+// persistence, the root of the default filter is committed with the base state only and computed over
+// the base state and the deltas in between, which loading checks against their chain hash.
+func (s *SmartContract) filterMerkleRoot(ctx contractapi.TransactionContextInterface, filterID string, filter *Filter) ([]byte, error) {
+	if filterID == DefaultFilterID {
+		config, err := loadRegistryConfig(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if config.DeltaPersistence {
+			if filter == nil {
+				if filter, err = s.LoadFilterState(ctx); err != nil {
+					return nil, err
+				}
+			}
+			return core.MerkleRoot(filter.bucketLeaves()), nil
+		}
+	}
+	return storedMerkleRoot(ctx, filterID)
+}
+
+// GetFilterMerkleRoot returns the hex encoded Merkle root over the buckets of a filter, committed with
+// every write of the filter state, see filterMerkleRoot for delta persistence
+func (s *SmartContract) GetFilterMerkleRoot(ctx contractapi.TransactionContextInterface, filterID string) (string, error) {
+	root, err := s.filterMerkleRoot(ctx, filterID, nil)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(root), nil
+}
+
+// GetInclusionProof returns the proof whether data is revoked in a filter: the contents and Merkle paths
+// of both candidate buckets, verifiable with core.InclusionProof.Verify against the committed root
+// without downloading the filter.
+func (s *SmartContract) GetInclusionProof(ctx contractapi.TransactionContextInterface, filterID string, data string) (*core.InclusionProof, error) {
+	filter, err := s.loadFilter(ctx, filterID)
+	if err != nil {
+		return nil, err
+	}
+	root, err := s.filterMerkleRoot(ctx, filterID, filter)
+	if err != nil {
+		return nil, err
+	}
+	leaves := filter.bucketLeaves()
+	if !bytes.Equal(core.MerkleRoot(leaves), root) {
+		return nil, fmt.Errorf("%w: filter '%s' does not match its committed Merkle root", ErrStateCorrupt, filterID)
+	}
+
+	i1, fp := filter.indexAndFingerprint([]byte(data))
+	indexes := []uint{i1}
+	if i2 := GetAltIndex(fp, i1, filter.BucketIndexMask); i2 != i1 {
+		indexes = append(indexes, i2)
+	}
+	proof := &core.InclusionProof{
+		Root:            hex.EncodeToString(root),
+		Leaves:          uint(len(leaves)),
+		BucketIndexMask: filter.BucketIndexMask,
+		FingerprintSize: filter.fingerprintSize(),
+	}
+	for _, index := range indexes {
+		bucketProof := core.BucketProof{Index: index, Fingerprints: []string{}, Siblings: []string{}}
+		if b := filter.bucketAt(index); b != nil {
+			for _, stored := range b.Data {
+				if len(stored) > 0 {
+					bucketProof.Fingerprints = append(bucketProof.Fingerprints, hex.EncodeToString(stored))
+				}
+			}
+		}
+		sort.Strings(bucketProof.Fingerprints)
+		for _, sibling := range core.MerkleProof(leaves, index) {
+			bucketProof.Siblings = append(bucketProof.Siblings, hex.EncodeToString(sibling))
+		}
+		proof.Buckets = append(proof.Buckets, bucketProof)
+	}
+	return proof, nil
+}
@@ -0,0 +1,62 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"github.com/pherbke/credential-management/chaincode-go/core"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func requireInclusionProof(t *testing.T, sim *simulator.Simulator, identity *simulator.Identity, filterID string, data string) *core.InclusionProof {
+	proofJSON, err := sim.Evaluate(identity, "GetInclusionProof", filterID, data)
+	require.NoError(t, err)
+	var proof core.InclusionProof
+	require.NoError(t, json.Unmarshal(proofJSON, &proof))
+	return &proof
+}
+
+func TestInclusionProof_VerifiesAgainstCommittedRoot(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	emptyRoot, err := sim.Evaluate(admin, "GetFilterMerkleRoot", "")
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "BatchInsert", "", `["credential-1","credential-2"]`)
+	require.NoError(t, err)
+	root, err := sim.Evaluate(admin, "GetFilterMerkleRoot", "")
+	require.NoError(t, err)
+	// Every write commits the root of the new state
+	require.NotEqual(t, emptyRoot, root)
+
+	for data, expected := range map[string]bool{"credential-1": true, "credential-2": true, "credential-3": false} {
+		proof := requireInclusionProof(t, sim, admin, "", data)
+		require.Equal(t, string(root), proof.Root)
+		revoked, err := proof.Verify([]byte(data))
+		require.NoError(t, err)
+		require.Equal(t, expected, revoked, data)
+	}
+
+	// A proof does not hold for another root or for data of other buckets
+	proof := requireInclusionProof(t, sim, admin, "", "credential-1")
+	proof.Buckets[0].Fingerprints = []string{}
+	_, err = proof.Verify([]byte("credential-1"))
+	require.ErrorIs(t, err, core.ErrInvalidInclusionProof)
+	proof = requireInclusionProof(t, sim, admin, "", "credential-3")
+	_, err = proof.Verify([]byte("credential-4"))
+	require.ErrorIs(t, err, core.ErrInvalidInclusionProof)
+}
+
+func TestInclusionProof_NamedFilter(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	_, err := sim.Submit(newRegistryAdmin(t), "Init", "issuer-a", "100", "4", "0")
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "Insert", "issuer-a", "credential-1")
+	require.NoError(t, err)
+
+	proof := requireInclusionProof(t, sim, admin, "issuer-a", "credential-1")
+	revoked, err := proof.Verify([]byte("credential-1"))
+	require.NoError(t, err)
+	require.True(t, revoked)
+	defaultRoot, err := sim.Evaluate(admin, "GetFilterMerkleRoot", "")
+	require.NoError(t, err)
+	require.NotEqual(t, string(defaultRoot), proof.Root)
+}
@@ -0,0 +1,202 @@
+package cuckoofilter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"sort"
+	"sync"
+)
+
+// Names of the built-in credential normalizers
+const (
+	// NormalizerIssuerJTI identifies a credential by its issuer and id: the iss and jti claims,
+	// or the issuer and id of the embedded credential claim. It is the default.
+	NormalizerIssuerJTI = "issuer-jti"
+	// NormalizerCanonicalClaims identifies a credential by the hash of its canonicalized claims, for
+	// credentials without a stable id. The signing time claims iat and nbf and the embedded proof are
+	// left out, so re-signing a credential does not change its identity.
+	NormalizerCanonicalClaims = "canonical-claims"
+)
+
+// CredentialNormalizer derives the stable identity of a JWT credential, so that tokens with the same
+// claims but a different header, signature or signing time get the same fingerprint
+type CredentialNormalizer interface {
+	Name() string
+	Identity(claims jwt.MapClaims) (issuer string, credentialID string, err error)
+}
+
+var (
+	normalizersMu sync.RWMutex
+	normalizers   = map[string]CredentialNormalizer{
+		NormalizerIssuerJTI:       issuerJTINormalizer{},
+		NormalizerCanonicalClaims: canonicalClaimsNormalizer{},
+	}
+)
+
+// RegisterNormalizer makes a normalizer available to registries under its name
+func RegisterNormalizer(normalizer CredentialNormalizer) {
+	normalizersMu.Lock()
+	defer normalizersMu.Unlock()
+	normalizers[normalizer.Name()] = normalizer
+}
+
+// LookupNormalizer returns the normalizer registered under a name. An empty name returns the default.
+func LookupNormalizer(name string) (CredentialNormalizer, error) {
+	if name == "" {
+		name = NormalizerIssuerJTI
+	}
+	normalizersMu.RLock()
+	defer normalizersMu.RUnlock()
+	normalizer, ok := normalizers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown credential normalizer %q", name)
+	}
+	return normalizer, nil
+}
+
+// NormalizerNames returns the names of the registered normalizers
+func NormalizerNames() []string {
+	normalizersMu.RLock()
+	defer normalizersMu.RUnlock()
+	names := make([]string, 0, len(normalizers))
+	for name := range normalizers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewTokenCredentialStatus returns the credentialStatus entry of a JWT credential, fingerprinting the
+// identity the normalizer derives instead of the token bytes. The token signature is not checked.
+func NewTokenCredentialStatus(normalizer CredentialNormalizer, token string) (*CredentialStatus, error) {
+	claims, err := parseUnverifiedClaims(token)
+	if err != nil {
+		return nil, err
+	}
+	issuer, credentialID, err := normalizer.Identity(claims)
+	if err != nil {
+		return nil, fmt.Errorf("error normalizing credential with %s: %v", normalizer.Name(), err)
+	}
+	status, err := NewCredentialStatus(issuer, credentialID)
+	if err != nil {
+		return nil, err
+	}
+	status.Normalizer = normalizer.Name()
+	return status, nil
+}
+
+// GetTokenCredentialStatus returns the credentialStatus entry of a JWT credential using the registry's normalizer
+func (s *SmartContract) GetTokenCredentialStatus(ctx contractapi.TransactionContextInterface, token string) (*CredentialStatus, error) {
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	normalizer, err := LookupNormalizer(config.Normalizer)
+	if err != nil {
+		return nil, err
+	}
+	return NewTokenCredentialStatus(normalizer, token)
+}
+
+// SetFingerprintNormalizer changes the normalizer the registry fingerprints JWT credentials with and
+// emits a RegistryConfigChanged event. Credentials already revoked keep the fingerprint of the previous
+// normalizer, so it should only be changed before the registry is in use or together with a migration.
+// Registry admins only.
+func (s *SmartContract) SetFingerprintNormalizer(ctx contractapi.TransactionContextInterface, name string) (*RegistryConfig, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	if err := checkRegistryAdmin(ctx, "change the fingerprint normalizer"); err != nil {
+		return nil, err
+	}
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	config.Version++
+	config.Normalizer = name
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if err := saveRegistryConfig(ctx, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+type issuerJTINormalizer struct{}
+
+func (issuerJTINormalizer) Name() string {
+	return NormalizerIssuerJTI
+}
+
+func (issuerJTINormalizer) Identity(claims jwt.MapClaims) (string, string, error) {
+	issuer, _ := claims["iss"].(string)
+	credentialID, _ := claims["jti"].(string)
+	if credential, ok := credentialClaim(claims); ok {
+		if issuer == "" {
+			issuer, _ = credential["issuer"].(string)
+		}
+		if credentialID == "" {
+			credentialID, _ = credential["id"].(string)
+		}
+	}
+	if issuer == "" || credentialID == "" {
+		return "", "", fmt.Errorf("credential has no issuer and id")
+	}
+	return issuer, credentialID, nil
+}
+
+// credentialClaim returns the credential of the EBSI vc claim or of the legacy credential claim
+func credentialClaim(claims jwt.MapClaims) (map[string]interface{}, bool) {
+	if credential, ok := claims[ClaimCredential].(map[string]interface{}); ok {
+		return credential, true
+	}
+	credential, ok := claims[ClaimLegacyCredential].(map[string]interface{})
+	return credential, ok
+}
+
+type canonicalClaimsNormalizer struct{}
+
+func (canonicalClaimsNormalizer) Name() string {
+	return NormalizerCanonicalClaims
+}
+
+func (canonicalClaimsNormalizer) Identity(claims jwt.MapClaims) (string, string, error) {
+	issuer, _ := claims["iss"].(string)
+	stable := make(map[string]interface{}, len(claims))
+	for name, value := range claims {
+		if name == "iat" || name == "nbf" {
+			continue
+		}
+		if credential, ok := value.(map[string]interface{}); ok && (name == ClaimCredential || name == ClaimLegacyCredential) {
+			if issuer == "" {
+				issuer, _ = credential["issuer"].(string)
+			}
+			withoutProof := make(map[string]interface{}, len(credential))
+			for field, fieldValue := range credential {
+				if field != "proof" {
+					withoutProof[field] = fieldValue
+				}
+			}
+			value = withoutProof
+		}
+		stable[name] = value
+	}
+	if issuer == "" {
+		return "", "", fmt.Errorf("credential has no issuer")
+	}
+
+	// encoding/json writes map keys in sorted order, which makes the encoding canonical
+	canonical, err := json.Marshal(stable)
+	if err != nil {
+		return "", "", err
+	}
+	hash := sha256.Sum256(canonical)
+	return issuer, "urn:sha256:" + hex.EncodeToString(hash[:]), nil
+}
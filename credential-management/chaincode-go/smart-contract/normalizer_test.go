@@ -0,0 +1,122 @@
+package cuckoofilter_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"github.com/dgrijalva/jwt-go"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func signToken(t *testing.T, claims jwt.MapClaims, header map[string]interface{}) string {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	for name, value := range header {
+		token.Header[name] = value
+	}
+	tokenString, err := token.SignedString(privateKey)
+	require.NoError(t, err)
+	return tokenString
+}
+
+func credentialClaims(iat int64, proof string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iat": iat,
+		"credential": map[string]interface{}{
+			"id":                "http://example.edu/credentials/3732",
+			"issuer":            "did:key:issuer",
+			"credentialSubject": map[string]interface{}{"id": "did:key:holder"},
+			"proof":             map[string]interface{}{"jws": proof},
+		},
+	}
+}
+
+func tokenStatus(t *testing.T, normalizerName string, token string) *cuckoofilter.CredentialStatus {
+	normalizer, err := cuckoofilter.LookupNormalizer(normalizerName)
+	require.NoError(t, err)
+	status, err := cuckoofilter.NewTokenCredentialStatus(normalizer, token)
+	require.NoError(t, err)
+	require.Equal(t, normalizer.Name(), status.Normalizer)
+	return status
+}
+
+func TestNormalizers_ResignedTokenKeepsFingerprint(t *testing.T) {
+	original := signToken(t, credentialClaims(1700000000, "sig-1"), nil)
+	resigned := signToken(t, credentialClaims(1700000500, "sig-2"), map[string]interface{}{"kid": "key-2"})
+	require.NotEqual(t, original, resigned)
+
+	for _, name := range []string{cuckoofilter.NormalizerIssuerJTI, cuckoofilter.NormalizerCanonicalClaims} {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tokenStatus(t, name, original).Fingerprint, tokenStatus(t, name, resigned).Fingerprint)
+		})
+	}
+}
+
+func TestIssuerJTINormalizer_MatchesCredentialStatus(t *testing.T) {
+	token := signToken(t, jwt.MapClaims{"iss": "did:key:issuer", "jti": "http://example.edu/credentials/3732"}, nil)
+	expected, err := cuckoofilter.NewCredentialStatus("did:key:issuer", "http://example.edu/credentials/3732")
+	require.NoError(t, err)
+	require.Equal(t, expected.Fingerprint, tokenStatus(t, cuckoofilter.NormalizerIssuerJTI, token).Fingerprint)
+	require.Equal(t, expected.Fingerprint, tokenStatus(t, "", signToken(t, credentialClaims(1, "sig"), nil)).Fingerprint)
+
+	normalizer, err := cuckoofilter.LookupNormalizer(cuckoofilter.NormalizerIssuerJTI)
+	require.NoError(t, err)
+	_, err = cuckoofilter.NewTokenCredentialStatus(normalizer, signToken(t, jwt.MapClaims{"iss": "did:key:issuer"}, nil))
+	require.Error(t, err)
+}
+
+func TestCanonicalClaimsNormalizer_ChangedClaims(t *testing.T) {
+	claims := credentialClaims(1700000000, "sig")
+	changed := credentialClaims(1700000000, "sig")
+	changed["credential"].(map[string]interface{})["credentialSubject"] = map[string]interface{}{"id": "did:key:other"}
+
+	require.NotEqual(t,
+		tokenStatus(t, cuckoofilter.NormalizerCanonicalClaims, signToken(t, claims, nil)).Fingerprint,
+		tokenStatus(t, cuckoofilter.NormalizerCanonicalClaims, signToken(t, changed, nil)).Fingerprint)
+}
+
+type subjectNormalizer struct{}
+
+func (subjectNormalizer) Name() string { return "test-subject" }
+
+func (subjectNormalizer) Identity(claims jwt.MapClaims) (string, string, error) {
+	return "did:key:issuer", claims["sub"].(string), nil
+}
+
+func TestRegisterNormalizer(t *testing.T) {
+	_, err := cuckoofilter.LookupNormalizer("test-subject")
+	require.Error(t, err)
+
+	cuckoofilter.RegisterNormalizer(subjectNormalizer{})
+	require.Contains(t, cuckoofilter.NormalizerNames(), "test-subject")
+	status := tokenStatus(t, "test-subject", signToken(t, jwt.MapClaims{"sub": "credential-1"}, nil))
+	expected, err := cuckoofilter.NewCredentialStatus("did:key:issuer", "credential-1")
+	require.NoError(t, err)
+	require.Equal(t, expected.Fingerprint, status.Fingerprint)
+}
+
+func TestSetFingerprintNormalizer(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	registryAdmin := newRegistryAdmin(t)
+	token := signToken(t, credentialClaims(1700000000, "sig"), nil)
+
+	_, err := sim.Submit(admin, "SetFingerprintNormalizer", cuckoofilter.NormalizerCanonicalClaims)
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+	tx, err := sim.Submit(registryAdmin, "SetFingerprintNormalizer", cuckoofilter.NormalizerCanonicalClaims)
+	require.NoError(t, err)
+	require.Equal(t, cuckoofilter.RegistryConfigChangedEvent, tx.Event.EventName)
+
+	statusJSON, err := sim.Evaluate(admin, "GetTokenCredentialStatus", token)
+	require.NoError(t, err)
+	var status cuckoofilter.CredentialStatus
+	require.NoError(t, json.Unmarshal(statusJSON, &status))
+	require.Equal(t, cuckoofilter.NormalizerCanonicalClaims, status.Normalizer)
+	require.Equal(t, tokenStatus(t, cuckoofilter.NormalizerCanonicalClaims, token).Fingerprint, status.Fingerprint)
+
+	_, err = sim.Submit(registryAdmin, "SetFingerprintNormalizer", "unknown")
+	require.Error(t, err)
+}
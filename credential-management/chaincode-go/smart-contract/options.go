@@ -0,0 +1,121 @@
+package cuckoofilter
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DefaultNumElements is the number of buckets of a filter created without WithNumElements
+const DefaultNumElements = 1024
+
+// maxNumElements bounds the number of buckets, so rounding up to a power of two cannot overflow
+const maxNumElements = 1 << 32
+
+// MaxBucketSize is the largest number of fingerprint slots per bucket
+const MaxBucketSize = 16
+
+// filterOptions holds the parameters of a new filter
+type filterOptions struct {
+	numElements     uint
+	bucketSize      uint
+	fingerprintSize uint
+	maxKicks        uint
+	semiSorted      bool
+}
+
+// Option configures a filter created with NewFilterWithOptions
+type Option func(*filterOptions) error
+
+// WithNumElements sets the number of buckets, rounded up to a power of two
+func WithNumElements(numElements uint) Option {
+	return func(o *filterOptions) error {
+		if numElements == 0 || numElements > maxNumElements {
+			return fmt.Errorf("number of elements must be between 1 and %d", uint64(maxNumElements))
+		}
+		o.numElements = numElements
+		return nil
+	}
+}
+
+// WithBucketSize sets the number of fingerprint slots per bucket
+func WithBucketSize(bucketSize uint) Option {
+	return func(o *filterOptions) error {
+		if bucketSize == 0 || bucketSize > MaxBucketSize {
+			return fmt.Errorf("bucket size must be between 1 and %d", MaxBucketSize)
+		}
+		o.bucketSize = bucketSize
+		return nil
+	}
+}
+
+// WithFingerprintSize sets the bytes per fingerprint, see Init for the trade-off
+func WithFingerprintSize(fingerprintSize uint) Option {
+	return func(o *filterOptions) error {
+		if fingerprintSize == 0 || fingerprintSize > FingerPrintSize {
+			return fmt.Errorf("fingerprint size must be between 1 and %d bytes", FingerPrintSize)
+		}
+		o.fingerprintSize = fingerprintSize
+		return nil
+	}
+}
+
+// WithMaxKicks sets how many fingerprints an insert may relocate before the filter counts as full
+func WithMaxKicks(maxKicks uint) Option {
+	return func(o *filterOptions) error {
+		if maxKicks == 0 {
+			return errors.New("max kicks must be at least 1")
+		}
+		o.maxKicks = maxKicks
+		return nil
+	}
+}
+
+// WithSemiSortedBuckets serializes the buckets packed, see packBuckets. It requires buckets of
+// SemiSortedBucketSize slots.
+func WithSemiSortedBuckets() Option {
+	return func(o *filterOptions) error {
+		o.semiSorted = true
+		return nil
+	}
+}
+
+// NewFilterWithOptions creates a new cuckoo filter. Without options it has DefaultNumElements buckets
+// of DefaultBucketSize slots, fingerprints of FingerPrintSize bytes and MaxCuckooKicks kicks per insert.
+func NewFilterWithOptions(opts ...Option) (*Filter, error) {
+	o := &filterOptions{
+		numElements:     DefaultNumElements,
+		bucketSize:      DefaultBucketSize,
+		fingerprintSize: FingerPrintSize,
+		maxKicks:        MaxCuckooKicks,
+	}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+	if o.semiSorted && o.bucketSize != SemiSortedBucketSize {
+		return nil, fmt.Errorf("semi-sorted buckets require a bucket size of %d", SemiSortedBucketSize)
+	}
+	return newFilter(o), nil
+}
+
+// newFilter creates a filter from options that are not validated
+func newFilter(o *filterOptions) *Filter {
+	numBuckets := GetNextPow2(uint64(o.numElements))
+	buckets := make([]*bucket, numBuckets)
+	for i := range buckets {
+		buckets[i] = NewBucket(o.bucketSize)
+	}
+	filter := &Filter{
+		Buckets:         buckets,
+		Count:           0,
+		BucketIndexMask: uint(numBuckets - 1),
+		FingerprintSize: o.fingerprintSize,
+		SemiSorted:      o.semiSorted,
+	}
+	// The default is not stored, so filter states stay unchanged
+	if o.maxKicks != MaxCuckooKicks {
+		filter.MaxKicks = o.maxKicks
+	}
+	return filter
+}
@@ -0,0 +1,53 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNewFilterWithOptions_Defaults(t *testing.T) {
+	filter, err := cuckoofilter.NewFilterWithOptions()
+	require.NoError(t, err)
+	require.Equal(t, cuckoofilter.NewFilter(cuckoofilter.DefaultNumElements, cuckoofilter.DefaultBucketSize, 0), filter)
+}
+
+func TestNewFilterWithOptions(t *testing.T) {
+	filter, err := cuckoofilter.NewFilterWithOptions(
+		cuckoofilter.WithNumElements(100),
+		cuckoofilter.WithBucketSize(2),
+		cuckoofilter.WithFingerprintSize(4),
+		cuckoofilter.WithMaxKicks(10),
+	)
+	require.NoError(t, err)
+	require.Len(t, filter.Buckets, 128)
+	require.Len(t, filter.Buckets[0].Data, 2)
+	require.Equal(t, uint(4), filter.FingerprintSize)
+	require.Equal(t, uint(10), filter.MaxKicks)
+
+	require.True(t, filter.Insert([]byte("credential-1")))
+	require.True(t, filter.Lookup([]byte("credential-1")))
+
+	// The kick limit survives a round trip through the ledger format
+	filterJSON, err := json.Marshal(filter)
+	require.NoError(t, err)
+	var loaded cuckoofilter.Filter
+	require.NoError(t, json.Unmarshal(filterJSON, &loaded))
+	require.Equal(t, uint(10), loaded.MaxKicks)
+}
+
+func TestNewFilterWithOptions_Invalid(t *testing.T) {
+	for name, option := range map[string]cuckoofilter.Option{
+		"no elements":           cuckoofilter.WithNumElements(0),
+		"empty buckets":         cuckoofilter.WithBucketSize(0),
+		"no fingerprint":        cuckoofilter.WithFingerprintSize(0),
+		"oversized fingerprint": cuckoofilter.WithFingerprintSize(cuckoofilter.FingerPrintSize + 1),
+		"no kicks":              cuckoofilter.WithMaxKicks(0),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := cuckoofilter.NewFilterWithOptions(option)
+			require.Error(t, err)
+		})
+	}
+}
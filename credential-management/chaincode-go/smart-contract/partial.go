@@ -0,0 +1,46 @@
+package cuckoofilter
+
+import (
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// PartialTransientKey is the transient data key of the partial flag of BatchInsert. With "true", items
+// that cannot be inserted are reported and the others are saved, instead of the first one failing the batch.
+const PartialTransientKey = "partial"
+
+// Per-item results of BatchInsert
+const (
+	BatchInsertInserted  = "inserted"
+	BatchInsertDuplicate = "duplicate" // Already in the filter
+	BatchInsertFailed    = "failed"    // The filter has no room for the item
+)
+
+// BatchInsertResult reports the outcome of a BatchInsert
+type BatchInsertResult struct {
+	Results   map[string]string `json:"results"` // Result of every item
+	Inserted  int               `json:"inserted"`
+	Duplicate int               `json:"duplicate"`
+	Failed    int               `json:"failed"`
+}
+
+func (r *BatchInsertResult) add(data string, status string) {
+	r.Results[data] = status
+	switch status {
+	case BatchInsertInserted:
+		r.Inserted++
+	case BatchInsertDuplicate:
+		r.Duplicate++
+	case BatchInsertFailed:
+		r.Failed++
+	}
+}
+
+// transientPartial returns the partial flag passed as transient data
+func transientPartial(ctx contractapi.TransactionContextInterface) (bool, error) {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return false, fmt.Errorf("error reading transient data: %v", err)
+	}
+	return string(transient[PartialTransientKey]) == "true", nil
+}
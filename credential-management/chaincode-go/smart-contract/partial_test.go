@@ -0,0 +1,83 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"fmt"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestBatchInsert_ReportsResults(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	tx, err := sim.Submit(admin, "BatchInsert", "", `["credential-1","credential-2"]`)
+	require.NoError(t, err)
+	var result cuckoofilter.BatchInsertResult
+	require.NoError(t, json.Unmarshal(tx.Payload, &result))
+	require.Equal(t, cuckoofilter.BatchInsertResult{
+		Results: map[string]string{
+			"credential-1": cuckoofilter.BatchInsertInserted,
+			"credential-2": cuckoofilter.BatchInsertInserted,
+		},
+		Inserted: 2,
+	}, result)
+}
+
+func TestBatchInsert_Partial(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	_, err := sim.Submit(admin, "Insert", "", "credential-1")
+	require.NoError(t, err)
+
+	// Atomic by default, the duplicate fails the batch and nothing is saved
+	_, err = sim.Submit(admin, "BatchInsert", "", `["credential-2","credential-1"]`)
+	require.ErrorContains(t, err, cuckoofilter.AlreadyRevokedErrorCode)
+	requireLookup(t, sim, admin, "", "credential-2", false)
+
+	partial := map[string][]byte{cuckoofilter.PartialTransientKey: []byte("true")}
+	tx, err := sim.SubmitTransient(admin, partial, "BatchInsert", "", `["credential-2","credential-1","credential-2"]`)
+	require.NoError(t, err)
+	var result cuckoofilter.BatchInsertResult
+	require.NoError(t, json.Unmarshal(tx.Payload, &result))
+	require.Equal(t, cuckoofilter.BatchInsertResult{
+		Results: map[string]string{
+			"credential-1": cuckoofilter.BatchInsertDuplicate,
+			"credential-2": cuckoofilter.BatchInsertInserted,
+		},
+		Inserted:  1,
+		Duplicate: 1,
+	}, result)
+	requireLookup(t, sim, admin, "", "credential-2", true)
+
+	// A batch that inserts nothing writes nothing
+	tx, err = sim.SubmitTransient(admin, partial, "BatchInsert", "", `["credential-1"]`)
+	require.NoError(t, err)
+	require.Nil(t, tx.Event)
+}
+
+func TestBatchInsert_PartialReportsFailedItems(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	// Filters with short fingerprints cannot grow, so a small one fills up
+	_, err := sim.Submit(newRegistryAdmin(t), "Init", "small", "4", "1", "2")
+	require.NoError(t, err)
+	items := []string{}
+	for i := 0; i < 12; i++ {
+		items = append(items, fmt.Sprintf("credential-%d", i))
+	}
+	itemsJSON, err := json.Marshal(items)
+	require.NoError(t, err)
+
+	_, err = sim.Submit(admin, "BatchInsert", "small", string(itemsJSON))
+	require.ErrorContains(t, err, cuckoofilter.FilterFullErrorCode)
+
+	partial := map[string][]byte{cuckoofilter.PartialTransientKey: []byte("true")}
+	tx, err := sim.SubmitTransient(admin, partial, "BatchInsert", "small", string(itemsJSON))
+	require.NoError(t, err)
+	var result cuckoofilter.BatchInsertResult
+	require.NoError(t, json.Unmarshal(tx.Payload, &result))
+	require.NotZero(t, result.Inserted)
+	require.NotZero(t, result.Failed)
+	require.Equal(t, len(items), result.Inserted+result.Failed)
+	for data, status := range result.Results {
+		requireLookup(t, sim, admin, "small", data, status == cuckoofilter.BatchInsertInserted)
+	}
+}
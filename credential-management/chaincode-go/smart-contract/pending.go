@@ -0,0 +1,132 @@
+package cuckoofilter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// pendingFilterStateKey is the ledger key holding the filter of issued but not yet active credentials
+const pendingFilterStateKey = "PendingFilterState"
+
+// Credential status values returned by LookupStatus, see also CredentialStatusSuspended
+const (
+	CredentialStatusActive  = "active"
+	CredentialStatusPending = "pending"
+	CredentialStatusRevoked = "revoked"
+)
+
+// ParkCredential inserts the fingerprint of an issued credential into the pending filter,
+// so it is reported as pending until ActivateCredential is called, e.g. after payment or identity proofing.
+// The client that parked a credential is recorded, so only it or a registry admin may activate it.
+func (s *SmartContract) ParkCredential(ctx contractapi.TransactionContextInterface, data string) error {
+	if err := checkWritable(ctx); err != nil {
+		return err
+	}
+	if err := checkCapability(ctx, CapabilityActionRevoke, DefaultFilterID); err != nil {
+		return err
+	}
+	pending, err := loadPendingFilter(ctx)
+	if err != nil {
+		return err
+	}
+	if pending.Lookup([]byte(data)) {
+		return fmt.Errorf("%w: credential '%s' is already pending", ErrConflict, data)
+	}
+	if !pending.Insert([]byte(data)) {
+		return fmt.Errorf("%w: failed to insert data '%s' into pending filter", ErrFilterFull, data)
+	}
+	if err := recordInserter(ctx, pendingScope, data); err != nil {
+		return err
+	}
+	if err := recordLifecycleEvent(ctx, LifecycleParked, "", data); err != nil {
+		return err
+	}
+	return savePendingFilter(ctx, pending)
+}
+
+// ActivateCredential removes the fingerprint of a parked credential from the pending filter.
+// Only the client that parked it or a registry admin may activate it.
+func (s *SmartContract) ActivateCredential(ctx contractapi.TransactionContextInterface, data string) error {
+	if err := checkWritable(ctx); err != nil {
+		return err
+	}
+	if err := checkCapability(ctx, CapabilityActionUnrevoke, DefaultFilterID); err != nil {
+		return err
+	}
+	pending, err := loadPendingFilter(ctx)
+	if err != nil {
+		return err
+	}
+	if !pending.Lookup([]byte(data)) {
+		return fmt.Errorf("%w: credential '%s' is not pending", ErrNotFound, data)
+	}
+	if err := authorizeDelete(ctx, pendingScope, data); err != nil {
+		return err
+	}
+	if !pending.Delete([]byte(data)) {
+		return fmt.Errorf("%w: credential '%s' is not pending", ErrNotFound, data)
+	}
+	if err := recordLifecycleEvent(ctx, LifecycleActivated, "", data); err != nil {
+		return err
+	}
+	return savePendingFilter(ctx, pending)
+}
+
+// LookupStatus reports whether a credential is revoked, suspended, pending activation or active.
+// Revocation and suspension take precedence over a pending status.
+func (s *SmartContract) LookupStatus(ctx contractapi.TransactionContextInterface, data string) (string, error) {
+	revocation, err := s.LookupRevocation(ctx, DefaultFilterID, data)
+	if err != nil {
+		return "", err
+	}
+	if revocation.Status != CredentialStatusActive {
+		return revocation.Status, nil
+	}
+
+	pending, err := loadPendingFilter(ctx)
+	if err != nil {
+		return "", err
+	}
+	if pending.Lookup([]byte(data)) {
+		return CredentialStatusPending, nil
+	}
+	return CredentialStatusActive, nil
+}
+
+// loadPendingFilter retrieves the pending filter, creating an empty one with the dimensions of the revocation filter
+func loadPendingFilter(ctx contractapi.TransactionContextInterface) (*Filter, error) {
+	filterJSON, err := ctx.GetStub().GetState(pendingFilterStateKey)
+	if err != nil {
+		return nil, fmt.Errorf("error loading pending filter: %v", err)
+	}
+	if filterJSON == nil {
+		revocationFilter, err := new(SmartContract).LoadFilterState(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error loading filter state: %v", err)
+		}
+		if len(revocationFilter.Buckets) == 0 {
+			return nil, errors.New("filter state has no buckets")
+		}
+		return NewFilter(uint(len(revocationFilter.Buckets)), uint(len(revocationFilter.Buckets[0].Data)), revocationFilter.FingerprintSize), nil
+	}
+
+	if err := verifyStateHash(ctx, pendingFilterStateKey, filterJSON); err != nil {
+		return nil, err
+	}
+
+	var filter Filter
+	if err := json.Unmarshal(filterJSON, &filter); err != nil {
+		return nil, fmt.Errorf("error decoding pending filter: %v", err)
+	}
+	return &filter, nil
+}
+
+func savePendingFilter(ctx contractapi.TransactionContextInterface, filter *Filter) error {
+	filterJSON, err := json.Marshal(filter)
+	if err != nil {
+		return err
+	}
+	return putStateWithHash(ctx, pendingFilterStateKey, pendingFilterStateKey, filterJSON)
+}
@@ -0,0 +1,90 @@
+package cuckoofilter_test
+
+import (
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func newRegistrySimulator(t *testing.T) (*simulator.Simulator, *simulator.Identity) {
+	sim, err := simulator.New("credential-management", &cuckoofilter.SmartContract{})
+	require.NoError(t, err)
+	admin, err := simulator.NewIdentity("Org1MSP", "registry-admin")
+	require.NoError(t, err)
+	_, err = sim.Submit(newRegistryAdmin(t), "Init", "", "100", "4", "0")
+	require.NoError(t, err)
+	return sim, admin
+}
+
+func requireStatus(t *testing.T, sim *simulator.Simulator, identity *simulator.Identity, data string, expected string) {
+	status, err := sim.Evaluate(identity, "LookupStatus", data)
+	require.NoError(t, err)
+	require.Equal(t, expected, string(status))
+}
+
+func TestParkAndActivateCredential(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+
+	_, err := sim.Submit(admin, "ParkCredential", "credential-1")
+	require.NoError(t, err)
+	requireStatus(t, sim, admin, "credential-1", cuckoofilter.CredentialStatusPending)
+	requireStatus(t, sim, admin, "credential-2", cuckoofilter.CredentialStatusActive)
+
+	_, err = sim.Submit(admin, "ActivateCredential", "credential-1")
+	require.NoError(t, err)
+	requireStatus(t, sim, admin, "credential-1", cuckoofilter.CredentialStatusActive)
+
+	// Activating twice fails
+	_, err = sim.Submit(admin, "ActivateCredential", "credential-1")
+	require.ErrorContains(t, err, cuckoofilter.NotFoundErrorCode)
+}
+
+func TestLookupStatus_RevokedWhilePending(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+
+	_, err := sim.Submit(admin, "ParkCredential", "credential-1")
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "Insert", "", "credential-1")
+	require.NoError(t, err)
+	requireStatus(t, sim, admin, "credential-1", cuckoofilter.CredentialStatusRevoked)
+}
+
+func TestParkCredential_NotInitialized(t *testing.T) {
+	sim, err := simulator.New("credential-management", &cuckoofilter.SmartContract{})
+	require.NoError(t, err)
+	admin, err := simulator.NewIdentity("Org1MSP", "registry-admin")
+	require.NoError(t, err)
+
+	_, err = sim.Submit(admin, "ParkCredential", "credential-1")
+	require.Error(t, err)
+}
+
+func TestActivateCredential_OnlyByParker(t *testing.T) {
+	sim, _ := newRegistrySimulator(t)
+	issuer1 := newIssuerIdentity(t, "Org1MSP", "did:key:issuer1")
+	issuer2 := newIssuerIdentity(t, "Org2MSP", "did:key:issuer2")
+
+	_, err := sim.Submit(issuer1, "ParkCredential", "credential-1")
+	require.NoError(t, err)
+	// Parking twice is a conflict, not a full filter
+	_, err = sim.Submit(issuer2, "ParkCredential", "credential-1")
+	require.ErrorContains(t, err, cuckoofilter.ConflictErrorCode)
+
+	_, err = sim.Submit(issuer2, "ActivateCredential", "credential-1")
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+	requireStatus(t, sim, issuer2, "credential-1", cuckoofilter.CredentialStatusPending)
+	_, err = sim.Submit(issuer2, "ActivateCredential", "credential-2")
+	require.ErrorContains(t, err, cuckoofilter.NotFoundErrorCode)
+
+	_, err = sim.Submit(issuer1, "ActivateCredential", "credential-1")
+	require.NoError(t, err)
+	requireStatus(t, sim, issuer1, "credential-1", cuckoofilter.CredentialStatusActive)
+
+	// A registry admin may activate credentials parked by anyone
+	_, err = sim.Submit(issuer2, "ParkCredential", "credential-2")
+	require.NoError(t, err)
+	_, err = sim.Submit(newRegistryAdmin(t), "ActivateCredential", "credential-2")
+	require.NoError(t, err)
+	requireStatus(t, sim, issuer1, "credential-2", cuckoofilter.CredentialStatusActive)
+}
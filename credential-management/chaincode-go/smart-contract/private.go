@@ -0,0 +1,306 @@
+package cuckoofilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Transient data keys of RevokePrivate, UnrevokePrivate and LookupPrivate. Transient data is not recorded
+// in the transaction, so the fingerprints are only seen by the endorsing peers.
+const (
+	PrivateFingerprintsTransientKey = "fingerprints" // JSON array of fingerprints
+)
+
+// SetPrivateCollection moves the default filter state and the revocation audit records into a Fabric
+// private data collection, or back to the public state for an empty collection. The collection must be
+// defined in the collection config of the chaincode; its members endorse and read revocations while
+// other channel members only see hashes. Named filters and shards stay in the public state.
+//
+// Insert and the other public transactions still record the fingerprint in their arguments, inserter
+// and lifecycle entries; use RevokePrivate and UnrevokePrivate to keep fingerprints off the channel.
+// Registry admins only.
+func (s *SmartContract) SetPrivateCollection(ctx contractapi.TransactionContextInterface, collection string) (*RegistryConfig, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	if err := checkRegistryAdmin(ctx, "move the filter state to a private data collection"); err != nil {
+		return nil, err
+	}
+	if err := checkCollectionDefined(ctx, collection); err != nil {
+		return nil, err
+	}
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if config.PrivateCollection == collection {
+		return config, nil
+	}
+	previous := config.PrivateCollection
+
+	config.Version++
+	config.PrivateCollection = collection
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if err := moveFilterState(ctx, previous, collection); err != nil {
+		return nil, err
+	}
+	if err := moveRevocationAudit(ctx, previous, collection); err != nil {
+		return nil, err
+	}
+	if config.KeyedFingerprints {
+		if err := moveFingerprintSecret(ctx, previous, collection); err != nil {
+			return nil, err
+		}
+	}
+	if err := saveRegistryConfig(ctx, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// checkCollectionDefined fails with ErrInvalidArgument unless a collection is defined in the collection
+// config of the chaincode. Reading a private data hash needs no collection membership, but fails for
+// collections the peer does not know.
+func checkCollectionDefined(ctx contractapi.TransactionContextInterface, collection string) error {
+	if collection == "" {
+		return nil
+	}
+	if _, err := ctx.GetStub().GetPrivateDataHash(collection, registryConfigKey); err != nil {
+		return fmt.Errorf("%w: collection %s is not defined in the collection config: %v", ErrInvalidArgument, collection, err)
+	}
+	return nil
+}
+
+// RevokePrivate inserts the fingerprints passed as transient data into the default filter. Unlike
+// BatchInsert it records no inserter, lifecycle or subject entries, the revocation audit records go to
+// the private data collection and the FilterChanged event carries no fingerprints. A reason can be
+// passed under RevocationReasonTransientKey.
+func (s *SmartContract) RevokePrivate(ctx contractapi.TransactionContextInterface) error {
+	if err := checkWritable(ctx); err != nil {
+		return err
+	}
+	if err := checkCapability(ctx, CapabilityActionRevoke, DefaultFilterID); err != nil {
+		return err
+	}
+	dataItems, err := transientFingerprints(ctx)
+	if err != nil {
+		return err
+	}
+	if err := checkBatchSize(ctx, len(dataItems)); err != nil {
+		return err
+	}
+	if err := checkStrictMode(ctx, dataItems...); err != nil {
+		return err
+	}
+	filter, err := s.loadFilter(ctx, DefaultFilterID)
+	if err != nil {
+		return fmt.Errorf("error loading filter state: %v", err)
+	}
+
+	for i, data := range dataItems {
+		var inserted bool
+		if filter, inserted = insertGrowing(filter, []byte(data)); !inserted {
+			return fmt.Errorf("%w after %d successful insertions", insertFailure(filter, []byte(data), "fingerprint"), i)
+		}
+	}
+	if err := recordTransientRevocationAudit(ctx, LifecycleRevoked, dataItems...); err != nil {
+		return err
+	}
+	if err := s.saveFilter(ctx, DefaultFilterID, filter); err != nil {
+		return err
+	}
+	return emitFilterChanged(ctx, DefaultFilterID, FilterChangeInserted, filter, dataItems)
+}
+
+// UnrevokePrivate deletes the fingerprints passed as transient data from the default filter. As
+// RevokePrivate records no inserters, only registry admins may call it.
+func (s *SmartContract) UnrevokePrivate(ctx contractapi.TransactionContextInterface) error {
+	if err := checkWritable(ctx); err != nil {
+		return err
+	}
+	if err := checkCapability(ctx, CapabilityActionUnrevoke, DefaultFilterID); err != nil {
+		return err
+	}
+	if _, admin, err := clientInserter(ctx); err != nil {
+		return err
+	} else if !admin {
+		return fmt.Errorf("%w: only a registry admin may delete private fingerprints", ErrUnauthorized)
+	}
+	dataItems, err := transientFingerprints(ctx)
+	if err != nil {
+		return err
+	}
+	if err := checkBatchSize(ctx, len(dataItems)); err != nil {
+		return err
+	}
+	filter, err := s.loadFilter(ctx, DefaultFilterID)
+	if err != nil {
+		return fmt.Errorf("error loading filter state: %v", err)
+	}
+
+	for _, data := range dataItems {
+		if !filter.Delete([]byte(data)) {
+			return fmt.Errorf("%w: failed to delete fingerprint from cuckoo filter", ErrNotFound)
+		}
+	}
+	if err := recordTransientRevocationAudit(ctx, LifecycleUnrevoked, dataItems...); err != nil {
+		return err
+	}
+	if err := s.saveFilter(ctx, DefaultFilterID, filter); err != nil {
+		return err
+	}
+	return emitFilterChanged(ctx, DefaultFilterID, FilterChangeDeleted, filter, dataItems)
+}
+
+// LookupPrivate checks the fingerprints passed as transient data against the default filter
+func (s *SmartContract) LookupPrivate(ctx contractapi.TransactionContextInterface) (map[string]bool, error) {
+	dataItems, err := transientFingerprints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := s.loadFilter(ctx, DefaultFilterID)
+	if err != nil {
+		return nil, err
+	}
+	results := make(map[string]bool, len(dataItems))
+	for _, data := range dataItems {
+		results[data] = filter.Lookup([]byte(data))
+	}
+	return results, nil
+}
+
+// transientFingerprints returns the fingerprints passed under PrivateFingerprintsTransientKey
+func transientFingerprints(ctx contractapi.TransactionContextInterface) ([]string, error) {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("error reading transient data: %v", err)
+	}
+	fingerprintsJSON, ok := transient[PrivateFingerprintsTransientKey]
+	if !ok {
+		return nil, fmt.Errorf("transient data '%s' is required", PrivateFingerprintsTransientKey)
+	}
+	var fingerprints []string
+	if err := json.Unmarshal(fingerprintsJSON, &fingerprints); err != nil {
+		return nil, fmt.Errorf("error decoding transient data '%s': %v", PrivateFingerprintsTransientKey, err)
+	}
+	if len(fingerprints) == 0 {
+		return nil, fmt.Errorf("transient data '%s' holds no fingerprints", PrivateFingerprintsTransientKey)
+	}
+	return fingerprints, nil
+}
+
+// moveFilterState moves the default filter state and its hash between collections, empty for the public state
+func moveFilterState(ctx contractapi.TransactionContextInterface, from string, to string) error {
+	filterJSON, err := getCollectionState(ctx, from, filterStateKey)
+	if err != nil {
+		return fmt.Errorf("error loading filter state: %v", err)
+	}
+	if filterJSON == nil {
+		return nil
+	}
+	if err := verifyCollectionStateHash(ctx, from, filterStateKey, filterJSON); err != nil {
+		return err
+	}
+	if err := putCollectionStateWithHash(ctx, to, filterStateKey, filterStateKey, filterJSON); err != nil {
+		return err
+	}
+	hashKey, err := stateHashKey(ctx, filterStateKey)
+	if err != nil {
+		return err
+	}
+	// The endorsement policies of the filter and its hash move along, see SetFilterEndorsementPolicy
+	for _, key := range []string{filterStateKey, hashKey} {
+		policy, err := getCollectionValidationParameter(ctx, from, key)
+		if err != nil {
+			return fmt.Errorf("failed to read validation parameter of %s: %v", key, err)
+		}
+		if policy == nil {
+			continue
+		}
+		if err := setCollectionValidationParameter(ctx, to, key, policy); err != nil {
+			return fmt.Errorf("failed to set validation parameter on %s: %v", key, err)
+		}
+	}
+	for _, key := range []string{filterStateKey, hashKey} {
+		if err := delCollectionState(ctx, from, key); err != nil {
+			return fmt.Errorf("error deleting %s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// moveRevocationAudit moves the revocation audit records between collections, empty for the public state
+func moveRevocationAudit(ctx contractapi.TransactionContextInterface, from string, to string) error {
+	iterator, err := getCollectionStateByPartialCompositeKey(ctx, from, revocationAuditObjectType, []string{})
+	if err != nil {
+		return fmt.Errorf("error reading revocation history: %v", err)
+	}
+	defer iterator.Close()
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return fmt.Errorf("error reading revocation history: %v", err)
+		}
+		if err := putCollectionState(ctx, to, entry.Key, entry.Value); err != nil {
+			return fmt.Errorf("error saving revocation audit entry: %v", err)
+		}
+		if err := delCollectionState(ctx, from, entry.Key); err != nil {
+			return fmt.Errorf("error deleting revocation audit entry: %v", err)
+		}
+	}
+	return nil
+}
+
+// getCollectionState reads a key from a private data collection, empty for the public state
+func getCollectionState(ctx contractapi.TransactionContextInterface, collection string, key string) ([]byte, error) {
+	if collection == "" {
+		return ctx.GetStub().GetState(key)
+	}
+	return ctx.GetStub().GetPrivateData(collection, key)
+}
+
+// putCollectionState writes a key to a private data collection, empty for the public state
+func putCollectionState(ctx contractapi.TransactionContextInterface, collection string, key string, value []byte) error {
+	if collection == "" {
+		return ctx.GetStub().PutState(key, value)
+	}
+	return ctx.GetStub().PutPrivateData(collection, key, value)
+}
+
+// getCollectionValidationParameter reads the key-level endorsement policy of a key in a private data
+// collection, empty for the public state
+func getCollectionValidationParameter(ctx contractapi.TransactionContextInterface, collection string, key string) ([]byte, error) {
+	if collection == "" {
+		return ctx.GetStub().GetStateValidationParameter(key)
+	}
+	return ctx.GetStub().GetPrivateDataValidationParameter(collection, key)
+}
+
+// setCollectionValidationParameter sets the key-level endorsement policy of a key in a private data
+// collection, empty for the public state
+func setCollectionValidationParameter(ctx contractapi.TransactionContextInterface, collection string, key string, policy []byte) error {
+	if collection == "" {
+		return ctx.GetStub().SetStateValidationParameter(key, policy)
+	}
+	return ctx.GetStub().SetPrivateDataValidationParameter(collection, key, policy)
+}
+
+// delCollectionState deletes a key from a private data collection, empty for the public state
+func delCollectionState(ctx contractapi.TransactionContextInterface, collection string, key string) error {
+	if collection == "" {
+		return ctx.GetStub().DelState(key)
+	}
+	return ctx.GetStub().DelPrivateData(collection, key)
+}
+
+// getCollectionStateByPartialCompositeKey iterates over composite keys in a private data collection, empty for the public state
+func getCollectionStateByPartialCompositeKey(ctx contractapi.TransactionContextInterface, collection string, objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	if collection == "" {
+		return ctx.GetStub().GetStateByPartialCompositeKey(objectType, keys)
+	}
+	return ctx.GetStub().GetPrivateDataByPartialCompositeKey(collection, objectType, keys)
+}
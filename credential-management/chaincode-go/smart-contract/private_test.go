@@ -0,0 +1,120 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// fingerprintsTransient returns the transient data of RevokePrivate, UnrevokePrivate and LookupPrivate
+func fingerprintsTransient(t *testing.T, fingerprints ...string) map[string][]byte {
+	fingerprintsJSON, err := json.Marshal(fingerprints)
+	require.NoError(t, err)
+	return map[string][]byte{cuckoofilter.PrivateFingerprintsTransientKey: fingerprintsJSON}
+}
+
+func TestSetPrivateCollection(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	registryAdmin := newRegistryAdmin(t)
+	_, err := sim.Submit(admin, "Insert", "", "public-credential")
+	require.NoError(t, err)
+	require.NotNil(t, sim.GetState("CuckooFilterState"))
+
+	tx, err := sim.Submit(registryAdmin, "SetPrivateCollection", "revocations")
+	require.NoError(t, err)
+	var config cuckoofilter.RegistryConfig
+	require.NoError(t, json.Unmarshal(tx.Payload, &config))
+	require.Equal(t, "revocations", config.PrivateCollection)
+
+	// The filter state and the audit trail moved into the collection
+	require.Nil(t, sim.GetState("CuckooFilterState"))
+	require.NotNil(t, sim.GetPrivateData("revocations", "CuckooFilterState"))
+	requireStatus(t, sim, admin, "public-credential", cuckoofilter.CredentialStatusRevoked)
+	historyJSON, err := sim.Evaluate(admin, "GetRevocationHistory", "public-credential")
+	require.NoError(t, err)
+	var history []*cuckoofilter.RevocationAuditEntry
+	require.NoError(t, json.Unmarshal(historyJSON, &history))
+	require.Len(t, history, 1)
+
+	// Delta persistence writes public bucket deltas
+	_, err = sim.Submit(registryAdmin, "SetDeltaPersistence", "true")
+	require.Error(t, err)
+
+	_, err = sim.Submit(registryAdmin, "SetPrivateCollection", "")
+	require.NoError(t, err)
+	require.NotNil(t, sim.GetState("CuckooFilterState"))
+	require.Nil(t, sim.GetPrivateData("revocations", "CuckooFilterState"))
+	requireStatus(t, sim, admin, "public-credential", cuckoofilter.CredentialStatusRevoked)
+}
+
+func TestSetPrivateCollection_Checks(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	sim.DefineCollections("revocations")
+	registryAdmin := newRegistryAdmin(t)
+
+	_, err := sim.Submit(admin, "SetPrivateCollection", "revocations")
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+	// A typo would leave the filter state in a collection no peer stores
+	_, err = sim.Submit(registryAdmin, "SetPrivateCollection", "revocation")
+	require.ErrorContains(t, err, cuckoofilter.InvalidArgumentErrorCode)
+	require.NotNil(t, sim.GetState("CuckooFilterState"))
+
+	_, err = sim.Submit(registryAdmin, "SetPrivateCollection", "revocations")
+	require.NoError(t, err)
+	require.NotNil(t, sim.GetPrivateData("revocations", "CuckooFilterState"))
+}
+
+func TestRevokePrivate(t *testing.T) {
+	sim, issuer := newRegistrySimulator(t)
+	_, err := sim.Submit(newRegistryAdmin(t), "SetPrivateCollection", "revocations")
+	require.NoError(t, err)
+
+	transient := fingerprintsTransient(t, "private-1", "private-2")
+	transient[cuckoofilter.RevocationReasonTransientKey] = []byte("key compromise")
+	tx, err := sim.SubmitTransient(issuer, transient, "RevokePrivate")
+	require.NoError(t, err)
+
+	// The event reports the change without the fingerprints
+	var change cuckoofilter.FilterChange
+	require.NoError(t, json.Unmarshal(tx.Event.Payload, &change))
+	require.Empty(t, change.Fingerprints)
+	require.Equal(t, uint(2), change.Count)
+
+	resultJSON, err := sim.EvaluateTransient(issuer, fingerprintsTransient(t, "private-1", "private-3"), "LookupPrivate")
+	require.NoError(t, err)
+	var results map[string]bool
+	require.NoError(t, json.Unmarshal(resultJSON, &results))
+	require.Equal(t, map[string]bool{"private-1": true, "private-3": false}, results)
+
+	// Only the collection holds the audit trail
+	historyJSON, err := sim.Evaluate(issuer, "GetRevocationHistory", "private-1")
+	require.NoError(t, err)
+	var history []*cuckoofilter.RevocationAuditEntry
+	require.NoError(t, json.Unmarshal(historyJSON, &history))
+	require.Len(t, history, 1)
+	require.Equal(t, "key compromise", history[0].Reason)
+	_, err = sim.Evaluate(issuer, "GetInserter", "", "private-1")
+	require.Error(t, err)
+
+	// Without inserter records only registry admins can unrevoke
+	_, err = sim.SubmitTransient(issuer, fingerprintsTransient(t, "private-1"), "UnrevokePrivate")
+	require.ErrorContains(t, err, cuckoofilter.ErrUnauthorized.Error())
+	admin, err := simulator.NewIdentityWithAttributes("RegistryMSP", "registry-admin", map[string]string{
+		cuckoofilter.DIDAttribute:           "did:key:admin",
+		cuckoofilter.RegistryAdminAttribute: "true",
+	})
+	require.NoError(t, err)
+	_, err = sim.SubmitTransient(admin, fingerprintsTransient(t, "private-1"), "UnrevokePrivate")
+	require.NoError(t, err)
+	requireStatus(t, sim, admin, "private-1", cuckoofilter.CredentialStatusActive)
+}
+
+func TestRevokePrivate_RequiresFingerprints(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	_, err := sim.Submit(admin, "RevokePrivate")
+	require.Error(t, err)
+	_, err = sim.SubmitTransient(admin, map[string][]byte{cuckoofilter.PrivateFingerprintsTransientKey: []byte("[]")}, "RevokePrivate")
+	require.Error(t, err)
+}
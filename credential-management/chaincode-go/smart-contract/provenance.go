@@ -0,0 +1,187 @@
+package cuckoofilter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"sort"
+	"time"
+)
+
+// writeProvenanceObjectType is the composite key prefix of the write provenance records,
+// writeProvenance~<fingerprint>~<txID>~<action>
+const writeProvenanceObjectType = "writeProvenance"
+
+// WriteProvenance attributes one mutation of a registry entry to the client certificate that signed the
+// transaction proposal. Only hashes of the creator and the proposal are kept, so the record can be
+// matched against the certificates and proposals an auditor holds without storing them on the ledger.
+type WriteProvenance struct {
+	Fingerprint     string    `json:"fingerprint"`
+	TxID            string    `json:"txId"`
+	Action          string    `json:"action"` // Kind of the lifecycle event, e.g. LifecycleRevoked
+	Timestamp       time.Time `json:"timestamp"`
+	MSPID           string    `json:"mspId"`
+	ClientID        string    `json:"clientId"`                                       // x509::<subject>::<issuer> of the client certificate
+	CreatorHash     string    `json:"creatorHash"`                                    // Hex SHA-256 of the serialized identity returned by GetCreator
+	CertificateHash string    `json:"certificateHash,omitempty" metadata:",optional"` // Hex SHA-256 of the DER client certificate
+	ProposalHash    string    `json:"proposalHash,omitempty" metadata:",optional"`    // Hex SHA-256 of the signed proposal bytes
+	ChannelID       string    `json:"channelId,omitempty" metadata:",optional"`       // From the channel header of the proposal
+	HeaderType      string    `json:"headerType,omitempty" metadata:",optional"`      // From the channel header, e.g. ENDORSER_TRANSACTION
+	Epoch           uint64    `json:"epoch,omitempty" metadata:",optional"`           // From the channel header
+	HeaderTime      time.Time `json:"headerTimestamp,omitempty" metadata:",optional"` // Client-side time of the proposal
+	ChaincodeID     string    `json:"chaincodeId,omitempty" metadata:",optional"`     // From the chaincode header extension
+}
+
+// GetWriteProvenance returns who wrote each mutation of a registry entry in chronological order.
+// With a private data collection configured, only members of the collection can read the records.
+func (s *SmartContract) GetWriteProvenance(ctx contractapi.TransactionContextInterface, credentialID string) ([]*WriteProvenance, error) {
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := getCollectionStateByPartialCompositeKey(ctx, config.PrivateCollection, writeProvenanceObjectType, []string{credentialID})
+	if err != nil {
+		return nil, fmt.Errorf("error reading write provenance: %v", err)
+	}
+	defer iterator.Close()
+
+	records := []*WriteProvenance{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error reading write provenance: %v", err)
+		}
+		var record WriteProvenance
+		if err := json.Unmarshal(result.Value, &record); err != nil {
+			return nil, fmt.Errorf("error decoding write provenance: %v", err)
+		}
+		records = append(records, &record)
+	}
+	// Keys sort by transaction ID, not by time
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Timestamp.Before(records[j].Timestamp)
+	})
+	return records, nil
+}
+
+// recordWriteProvenance records the creator and channel header of the current transaction for each
+// fingerprint it mutates. Recording the same action twice in one transaction overwrites the record with
+// the same content.
+func recordWriteProvenance(ctx contractapi.TransactionContextInterface, action string, dataItems ...string) error {
+	if len(dataItems) == 0 {
+		return nil
+	}
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return err
+	}
+	record, err := transactionProvenance(ctx)
+	if err != nil {
+		return err
+	}
+	record.Action = action
+	for _, data := range dataItems {
+		record.Fingerprint = data
+		recordJSON, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		key, err := ctx.GetStub().CreateCompositeKey(writeProvenanceObjectType, []string{data, record.TxID, action})
+		if err != nil {
+			return fmt.Errorf("error creating write provenance key: %v", err)
+		}
+		if err := putCollectionState(ctx, config.PrivateCollection, key, recordJSON); err != nil {
+			return fmt.Errorf("error saving write provenance: %v", err)
+		}
+	}
+	return nil
+}
+
+// transactionProvenance reads the creator and, if the peer passed one, the signed proposal of the
+// current transaction
+func transactionProvenance(ctx contractapi.TransactionContextInterface) (*WriteProvenance, error) {
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	identity := ctx.GetClientIdentity()
+	mspID, err := identity.GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("error reading client MSP ID: %v", err)
+	}
+	clientID, err := identity.GetID()
+	if err != nil {
+		return nil, fmt.Errorf("error reading client ID: %v", err)
+	}
+	creator, err := ctx.GetStub().GetCreator()
+	if err != nil {
+		return nil, fmt.Errorf("error reading transaction creator: %v", err)
+	}
+	record := &WriteProvenance{
+		TxID:        ctx.GetStub().GetTxID(),
+		Timestamp:   timestamp,
+		MSPID:       mspID,
+		ClientID:    clientID,
+		CreatorHash: sha256Hex(creator),
+	}
+	certificate, err := identity.GetX509Certificate()
+	if err != nil {
+		return nil, fmt.Errorf("error reading client certificate: %v", err)
+	}
+	if certificate != nil {
+		record.CertificateHash = sha256Hex(certificate.Raw)
+	}
+
+	signedProposal, err := ctx.GetStub().GetSignedProposal()
+	if err != nil {
+		return nil, fmt.Errorf("error reading signed proposal: %v", err)
+	}
+	if signedProposal == nil {
+		return record, nil
+	}
+	record.ProposalHash = sha256Hex(signedProposal.ProposalBytes)
+	if err := readChannelHeader(signedProposal, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// readChannelHeader copies the channel header fields of a signed proposal into the record
+func readChannelHeader(signedProposal *peer.SignedProposal, record *WriteProvenance) error {
+	var proposal peer.Proposal
+	if err := proto.Unmarshal(signedProposal.ProposalBytes, &proposal); err != nil {
+		return fmt.Errorf("error decoding proposal: %v", err)
+	}
+	var header common.Header
+	if err := proto.Unmarshal(proposal.Header, &header); err != nil {
+		return fmt.Errorf("error decoding proposal header: %v", err)
+	}
+	var channelHeader common.ChannelHeader
+	if err := proto.Unmarshal(header.ChannelHeader, &channelHeader); err != nil {
+		return fmt.Errorf("error decoding channel header: %v", err)
+	}
+	record.ChannelID = channelHeader.ChannelId
+	record.HeaderType = common.HeaderType(channelHeader.Type).String()
+	record.Epoch = channelHeader.Epoch
+	if channelHeader.Timestamp != nil {
+		record.HeaderTime = channelHeader.Timestamp.AsTime().UTC()
+	}
+	if len(channelHeader.Extension) > 0 {
+		var extension peer.ChaincodeHeaderExtension
+		if err := proto.Unmarshal(channelHeader.Extension, &extension); err != nil {
+			return fmt.Errorf("error decoding chaincode header extension: %v", err)
+		}
+		record.ChaincodeID = extension.GetChaincodeId().GetName()
+	}
+	return nil
+}
+
+func sha256Hex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,82 @@
+package cuckoofilter_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestGetWriteProvenance(t *testing.T) {
+	sim, _ := newRegistrySimulator(t)
+	issuer := newIssuerIdentity(t, "Org2MSP", "did:key:issuer")
+	admin, err := simulator.NewIdentityWithAttributes("RegistryMSP", "registry-admin", map[string]string{
+		cuckoofilter.DIDAttribute:           "did:key:admin",
+		cuckoofilter.RegistryAdminAttribute: "true",
+	})
+	require.NoError(t, err)
+
+	inserted, err := sim.Submit(issuer, "Insert", "", "credential-1")
+	require.NoError(t, err)
+	deleted, err := sim.Submit(admin, "Delete", "", "credential-1")
+	require.NoError(t, err)
+
+	provenanceJSON, err := sim.Evaluate(issuer, "GetWriteProvenance", "credential-1")
+	require.NoError(t, err)
+	var provenance []*cuckoofilter.WriteProvenance
+	require.NoError(t, json.Unmarshal(provenanceJSON, &provenance))
+	require.Len(t, provenance, 2)
+
+	for i, expected := range []struct {
+		tx     string
+		action string
+		mspID  string
+		cert   []byte
+	}{
+		{inserted.ID, cuckoofilter.LifecycleRevoked, "Org2MSP", issuer.Certificate.Raw},
+		{deleted.ID, cuckoofilter.LifecycleUnrevoked, "RegistryMSP", admin.Certificate.Raw},
+	} {
+		record := provenance[i]
+		certHash := sha256.Sum256(expected.cert)
+		require.Equal(t, "credential-1", record.Fingerprint)
+		require.Equal(t, expected.tx, record.TxID)
+		require.Equal(t, expected.action, record.Action)
+		require.Equal(t, expected.mspID, record.MSPID)
+		require.Equal(t, hex.EncodeToString(certHash[:]), record.CertificateHash)
+		require.Len(t, record.CreatorHash, 64)
+		require.Len(t, record.ProposalHash, 64)
+
+		// Channel header of the signed proposal
+		require.Equal(t, "mychannel", record.ChannelID)
+		require.Equal(t, "ENDORSER_TRANSACTION", record.HeaderType)
+		require.Equal(t, "credential-management", record.ChaincodeID)
+		require.Equal(t, record.Timestamp, record.HeaderTime)
+	}
+	require.NotEqual(t, provenance[0].CreatorHash, provenance[1].CreatorHash)
+	require.NotEqual(t, provenance[0].ProposalHash, provenance[1].ProposalHash)
+
+	provenanceJSON, err = sim.Evaluate(issuer, "GetWriteProvenance", "credential-2")
+	require.NoError(t, err)
+	require.JSONEq(t, `[]`, string(provenanceJSON))
+}
+
+func TestWriteProvenance_Batch(t *testing.T) {
+	sim, _ := newRegistrySimulator(t)
+	issuer := newIssuerIdentity(t, "Org2MSP", "did:key:issuer")
+
+	// Lifecycle event and revocation audit of one transaction share a single record per fingerprint
+	tx, err := sim.Submit(issuer, "BatchInsert", "", `["credential-1","credential-2"]`)
+	require.NoError(t, err)
+	for _, data := range []string{"credential-1", "credential-2"} {
+		provenanceJSON, err := sim.Evaluate(issuer, "GetWriteProvenance", data)
+		require.NoError(t, err)
+		var provenance []*cuckoofilter.WriteProvenance
+		require.NoError(t, json.Unmarshal(provenanceJSON, &provenance))
+		require.Len(t, provenance, 1, data)
+		require.Equal(t, tx.ID, provenance[0].TxID)
+		require.Equal(t, data, provenance[0].Fingerprint)
+	}
+}
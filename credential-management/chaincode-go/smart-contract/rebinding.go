@@ -0,0 +1,321 @@
+package cuckoofilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"time"
+)
+
+// Composite key prefixes of rebinding requests, rebindingRequest~<requestID>, and of supersession
+// links, supersession~<fingerprint>, stored under the fingerprints of both credentials
+const (
+	rebindingRequestObjectType = "rebindingRequest"
+	supersessionObjectType     = "supersession"
+)
+
+// Lifecycle events of a credential migrated to a new holder DID
+const (
+	LifecycleRebindingRequest = "rebinding-requested"
+	LifecycleSuperseded       = "superseded"
+	LifecycleReissued         = "reissued"
+)
+
+// RebindingRequest is a holder's request to have a credential re-issued to a new DID, e.g. the key of
+// a new wallet or device. Status takes the RevocationRequest status values.
+type RebindingRequest struct {
+	ID           string    `json:"id"`
+	Fingerprint  string    `json:"fingerprint"`
+	HolderDID    string    `json:"holderDID"`
+	NewHolderDID string    `json:"newHolderDID"`
+	IssuerDID    string    `json:"issuerDID"`
+	Status       string    `json:"status"`
+	RequestedAt  time.Time `json:"requestedAt"`
+	DecidedAt    time.Time `json:"decidedAt"`
+	DecisionTx   string    `json:"decisionTx,omitempty" metadata:",optional"`
+}
+
+// Supersession links a credential to the credential that replaced it after a holder migration
+type Supersession struct {
+	OldFingerprint string    `json:"oldFingerprint"`
+	NewFingerprint string    `json:"newFingerprint"`
+	OldHolderDID   string    `json:"oldHolderDID"`
+	NewHolderDID   string    `json:"newHolderDID"`
+	IssuerDID      string    `json:"issuerDID"`
+	RequestID      string    `json:"requestID"`
+	TxID           string    `json:"txId"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// RequestRebinding records a holder-signed request to re-issue a credential bound to a new DID.
+// The request token is an ES256 JWT signed with the current holder key carrying the claims
+// iss (holder DID), issuer (issuer DID), fingerprint and newHolder (the new holder DID).
+// The transaction ID becomes the request ID.
+func (s *SmartContract) RequestRebinding(ctx contractapi.TransactionContextInterface, requestToken string) (*RebindingRequest, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	claims, err := parseUnverifiedClaims(requestToken)
+	if err != nil {
+		return nil, err
+	}
+	holderDID, _ := claims["iss"].(string)
+	issuerDID, _ := claims["issuer"].(string)
+	fingerprint, _ := claims["fingerprint"].(string)
+	newHolderDID, _ := claims["newHolder"].(string)
+	if holderDID == "" || issuerDID == "" || fingerprint == "" || newHolderDID == "" {
+		return nil, fmt.Errorf("rebinding request must contain iss, issuer, fingerprint and newHolder claims")
+	}
+	if newHolderDID == holderDID {
+		return nil, fmt.Errorf("new holder DID must differ from the current holder DID")
+	}
+	if _, err := verifyStakeholderToken(ctx, holderDID, requestToken); err != nil {
+		return nil, fmt.Errorf("invalid holder signature on rebinding request: %v", err)
+	}
+	requestedAt, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	request := &RebindingRequest{
+		ID:           ctx.GetStub().GetTxID(),
+		Fingerprint:  fingerprint,
+		HolderDID:    holderDID,
+		NewHolderDID: newHolderDID,
+		IssuerDID:    issuerDID,
+		Status:       RevocationRequestPending,
+		RequestedAt:  requestedAt,
+	}
+	if err := saveRebindingRequest(ctx, request); err != nil {
+		return nil, err
+	}
+	if err := recordLifecycleEvent(ctx, LifecycleRebindingRequest, "request "+request.ID+" to "+newHolderDID, fingerprint); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// ApproveRebinding lets the issuer complete a migration after re-issuing the credential to the new
+// holder DID. The decision token is an ES256 JWT signed with the issuer's key carrying the claims
+// iss (issuer DID), requestID, decision, which must be DecisionApprove, and fingerprint, the fingerprint
+// of the re-issued credential.
+// In one transaction the old fingerprint is revoked, the new one is activated if it was parked, and
+// the supersession link between both is recorded, so no verifier sees both or neither as valid.
+func (s *SmartContract) ApproveRebinding(ctx contractapi.TransactionContextInterface, requestID string, decisionToken string) (*Supersession, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	request, claims, err := decideRebindingRequest(ctx, requestID, decisionToken, RevocationRequestApproved)
+	if err != nil {
+		return nil, err
+	}
+	newFingerprint, _ := claims["fingerprint"].(string)
+	if newFingerprint == "" || newFingerprint == request.Fingerprint {
+		return nil, fmt.Errorf("decision must contain the fingerprint of the re-issued credential")
+	}
+	if existing, err := loadSupersession(ctx, newFingerprint); err != nil {
+		return nil, err
+	} else if existing != nil {
+		return nil, fmt.Errorf("credential %s is already part of a supersession", newFingerprint)
+	}
+
+	filter, err := s.loadFilter(ctx, DefaultFilterID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading filter state: %v", err)
+	}
+	if filter.Lookup([]byte(request.Fingerprint)) {
+		return nil, fmt.Errorf("%w: credential %s is already revoked", ErrAlreadyRevoked, request.Fingerprint)
+	}
+	if filter.Lookup([]byte(newFingerprint)) {
+		return nil, fmt.Errorf("re-issued credential %s is revoked", newFingerprint)
+	}
+	filter, inserted := insertGrowing(filter, []byte(request.Fingerprint))
+	if !inserted {
+		return nil, fmt.Errorf("%w: failed to insert data '%s' into cuckoo filter", ErrFilterFull, request.Fingerprint)
+	}
+	pending, err := loadPendingFilter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if pending.Delete([]byte(newFingerprint)) {
+		if err := savePendingFilter(ctx, pending); err != nil {
+			return nil, err
+		}
+		if err := deleteInserter(ctx, pendingScope, newFingerprint); err != nil {
+			return nil, err
+		}
+	}
+
+	supersession := &Supersession{
+		OldFingerprint: request.Fingerprint,
+		NewFingerprint: newFingerprint,
+		OldHolderDID:   request.HolderDID,
+		NewHolderDID:   request.NewHolderDID,
+		IssuerDID:      request.IssuerDID,
+		RequestID:      request.ID,
+		TxID:           request.DecisionTx,
+		Timestamp:      request.DecidedAt,
+	}
+	if err := saveSupersession(ctx, supersession); err != nil {
+		return nil, err
+	}
+	if err := recordInserter(ctx, filterScope(DefaultFilterID), request.Fingerprint); err != nil {
+		return nil, err
+	}
+	if err := recordLifecycleEvent(ctx, LifecycleSuperseded, "superseded by "+newFingerprint, request.Fingerprint); err != nil {
+		return nil, err
+	}
+	if err := recordLifecycleEvent(ctx, LifecycleReissued, "supersedes "+request.Fingerprint, newFingerprint); err != nil {
+		return nil, err
+	}
+	if err := recordRevocationAudit(ctx, LifecycleRevoked, "superseded by "+newFingerprint, request.Fingerprint); err != nil {
+		return nil, err
+	}
+	if err := saveRebindingRequest(ctx, request); err != nil {
+		return nil, err
+	}
+	if err := s.saveFilter(ctx, DefaultFilterID, filter); err != nil {
+		return nil, err
+	}
+	if err := emitFilterChanged(ctx, DefaultFilterID, FilterChangeInserted, filter, []string{request.Fingerprint}); err != nil {
+		return nil, err
+	}
+	return supersession, nil
+}
+
+// RejectRebinding lets the issuer reject a pending rebinding request, leaving the credential valid. The
+// decision token carries the claims iss, requestID and decision, which must be DecisionReject.
+func (s *SmartContract) RejectRebinding(ctx contractapi.TransactionContextInterface, requestID string, decisionToken string) (*RebindingRequest, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	request, _, err := decideRebindingRequest(ctx, requestID, decisionToken, RevocationRequestRejected)
+	if err != nil {
+		return nil, err
+	}
+	if err := saveRebindingRequest(ctx, request); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// GetRebindingRequest returns a recorded rebinding request
+func (s *SmartContract) GetRebindingRequest(ctx contractapi.TransactionContextInterface, requestID string) (*RebindingRequest, error) {
+	return loadRebindingRequest(ctx, requestID)
+}
+
+// GetSupersession returns the supersession link of a credential that was migrated or that replaced one
+func (s *SmartContract) GetSupersession(ctx contractapi.TransactionContextInterface, fingerprint string) (*Supersession, error) {
+	supersession, err := loadSupersession(ctx, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	if supersession == nil {
+		return nil, fmt.Errorf("credential %s has no supersession", fingerprint)
+	}
+	return supersession, nil
+}
+
+// decideRebindingRequest checks the issuer's decision token against a pending request and updates its status
+func decideRebindingRequest(ctx contractapi.TransactionContextInterface, requestID string, decisionToken string, status string) (*RebindingRequest, jwt.MapClaims, error) {
+	request, err := loadRebindingRequest(ctx, requestID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if request.Status != RevocationRequestPending {
+		return nil, nil, fmt.Errorf("rebinding request %s is already %s", requestID, request.Status)
+	}
+
+	claims, err := verifyStakeholderToken(ctx, request.IssuerDID, decisionToken)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid issuer signature on decision: %v", err)
+	}
+	if iss, _ := claims["iss"].(string); iss != request.IssuerDID {
+		return nil, nil, fmt.Errorf("decision is not signed by the credential issuer")
+	}
+	if id, _ := claims["requestID"].(string); id != requestID {
+		return nil, nil, fmt.Errorf("decision does not refer to rebinding request %s", requestID)
+	}
+	if err := checkDecisionClaim(claims, status); err != nil {
+		return nil, nil, err
+	}
+
+	decidedAt, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	request.Status = status
+	request.DecidedAt = decidedAt
+	request.DecisionTx = ctx.GetStub().GetTxID()
+	return request, claims, nil
+}
+
+func saveRebindingRequest(ctx contractapi.TransactionContextInterface, request *RebindingRequest) error {
+	key, err := ctx.GetStub().CreateCompositeKey(rebindingRequestObjectType, []string{request.ID})
+	if err != nil {
+		return fmt.Errorf("error creating rebinding request key: %v", err)
+	}
+	requestJSON, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(key, requestJSON)
+}
+
+func loadRebindingRequest(ctx contractapi.TransactionContextInterface, requestID string) (*RebindingRequest, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(rebindingRequestObjectType, []string{requestID})
+	if err != nil {
+		return nil, fmt.Errorf("error creating rebinding request key: %v", err)
+	}
+	requestJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("error loading rebinding request: %v", err)
+	}
+	if requestJSON == nil {
+		return nil, fmt.Errorf("%w: rebinding request %s not found", ErrNotFound, requestID)
+	}
+
+	var request RebindingRequest
+	if err := json.Unmarshal(requestJSON, &request); err != nil {
+		return nil, fmt.Errorf("error decoding rebinding request: %v", err)
+	}
+	return &request, nil
+}
+
+func saveSupersession(ctx contractapi.TransactionContextInterface, supersession *Supersession) error {
+	supersessionJSON, err := json.Marshal(supersession)
+	if err != nil {
+		return err
+	}
+	for _, fingerprint := range []string{supersession.OldFingerprint, supersession.NewFingerprint} {
+		key, err := ctx.GetStub().CreateCompositeKey(supersessionObjectType, []string{fingerprint})
+		if err != nil {
+			return fmt.Errorf("error creating supersession key: %v", err)
+		}
+		if err := ctx.GetStub().PutState(key, supersessionJSON); err != nil {
+			return fmt.Errorf("error saving supersession: %v", err)
+		}
+	}
+	return nil
+}
+
+// loadSupersession returns nil if the credential has no supersession link
+func loadSupersession(ctx contractapi.TransactionContextInterface, fingerprint string) (*Supersession, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(supersessionObjectType, []string{fingerprint})
+	if err != nil {
+		return nil, fmt.Errorf("error creating supersession key: %v", err)
+	}
+	supersessionJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("error loading supersession: %v", err)
+	}
+	if supersessionJSON == nil {
+		return nil, nil
+	}
+	var supersession Supersession
+	if err := json.Unmarshal(supersessionJSON, &supersession); err != nil {
+		return nil, fmt.Errorf("error decoding supersession: %v", err)
+	}
+	return &supersession, nil
+}
@@ -0,0 +1,137 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// newRebindingSimulator deploys the registry with the stakeholder contract and returns the issuer and old holder DIDs
+func newRebindingSimulator(t *testing.T) (*simulator.Simulator, *simulator.Identity, *cuckoofilter.DIDResponse, *cuckoofilter.DIDResponse) {
+	sim, err := simulator.New("credential-management", &cuckoofilter.SmartContract{}, &cuckoofilter.StakeholderManagementContract{})
+	require.NoError(t, err)
+	admin, err := simulator.NewIdentity("Org1MSP", "registry-admin")
+	require.NoError(t, err)
+	_, err = sim.Submit(newRegistryAdmin(t), "SmartContract:Init", "", "100", "4", "0")
+	require.NoError(t, err)
+
+	var dids []*cuckoofilter.DIDResponse
+	for _, role := range []string{"issuer", "holder"} {
+		tx, err := sim.Submit(admin, "StakeholderManagementContract:GenerateDID", role)
+		require.NoError(t, err)
+		var did cuckoofilter.DIDResponse
+		require.NoError(t, json.Unmarshal(tx.Payload, &did))
+		dids = append(dids, &did)
+	}
+	return sim, admin, dids[0], dids[1]
+}
+
+func TestRebinding(t *testing.T) {
+	useStakeholderDir(t)
+	sim, admin, issuer, holder := newRebindingSimulator(t)
+	issuerIdentity := newIssuerIdentity(t, "Org1MSP", issuer.DID)
+
+	requestToken := signWithDID(t, holder, jwt.MapClaims{
+		"iss":         holder.DID,
+		"issuer":      issuer.DID,
+		"fingerprint": "old-credential",
+		"newHolder":   "did:key:new-wallet",
+	})
+	tx, err := sim.Submit(admin, "SmartContract:RequestRebinding", requestToken)
+	require.NoError(t, err)
+	var request cuckoofilter.RebindingRequest
+	require.NoError(t, json.Unmarshal(tx.Payload, &request))
+	require.Equal(t, cuckoofilter.RevocationRequestPending, request.Status)
+
+	// The issuer re-issues the credential to the new DID and parks it until the migration completes
+	_, err = sim.Submit(issuerIdentity, "SmartContract:ParkCredential", "new-credential")
+	require.NoError(t, err)
+
+	// The decision must be signed by the issuer
+	holderDecision := signWithDID(t, holder, jwt.MapClaims{"iss": issuer.DID, "requestID": request.ID, "decision": cuckoofilter.DecisionApprove, "fingerprint": "new-credential"})
+	_, err = sim.Submit(issuerIdentity, "SmartContract:ApproveRebinding", request.ID, holderDecision)
+	require.Error(t, err)
+
+	decision := signWithDID(t, issuer, jwt.MapClaims{"iss": issuer.DID, "requestID": request.ID, "decision": cuckoofilter.DecisionApprove, "fingerprint": "new-credential"})
+	tx, err = sim.Submit(issuerIdentity, "SmartContract:ApproveRebinding", request.ID, decision)
+	require.NoError(t, err)
+	var supersession cuckoofilter.Supersession
+	require.NoError(t, json.Unmarshal(tx.Payload, &supersession))
+	require.Equal(t, tx.ID, supersession.TxID)
+
+	// Both credentials changed status in the same transaction
+	status, err := sim.Evaluate(admin, "SmartContract:LookupStatus", "old-credential")
+	require.NoError(t, err)
+	require.Equal(t, cuckoofilter.CredentialStatusRevoked, string(status))
+	status, err = sim.Evaluate(admin, "SmartContract:LookupStatus", "new-credential")
+	require.NoError(t, err)
+	require.Equal(t, cuckoofilter.CredentialStatusActive, string(status))
+
+	for _, fingerprint := range []string{"old-credential", "new-credential"} {
+		linkJSON, err := sim.Evaluate(admin, "SmartContract:GetSupersession", fingerprint)
+		require.NoError(t, err)
+		var link cuckoofilter.Supersession
+		require.NoError(t, json.Unmarshal(linkJSON, &link))
+		require.Equal(t, supersession, link)
+	}
+	require.Equal(t, "old-credential", supersession.OldFingerprint)
+	require.Equal(t, "new-credential", supersession.NewFingerprint)
+	require.Equal(t, holder.DID, supersession.OldHolderDID)
+	require.Equal(t, "did:key:new-wallet", supersession.NewHolderDID)
+
+	lifecycleJSON, err := sim.Evaluate(admin, "SmartContract:GetCredentialLifecycle", "old-credential")
+	require.NoError(t, err)
+	var lifecycle cuckoofilter.CredentialLifecycle
+	require.NoError(t, json.Unmarshal(lifecycleJSON, &lifecycle))
+	require.Len(t, lifecycle.Events, 2)
+	require.Equal(t, cuckoofilter.LifecycleRebindingRequest, lifecycle.Events[0].Kind)
+	require.Equal(t, cuckoofilter.LifecycleSuperseded, lifecycle.Events[1].Kind)
+
+	// A decided request cannot be approved again
+	_, err = sim.Submit(issuerIdentity, "SmartContract:ApproveRebinding", request.ID, decision)
+	require.Error(t, err)
+}
+
+func TestRebinding_Reject(t *testing.T) {
+	useStakeholderDir(t)
+	sim, admin, issuer, holder := newRebindingSimulator(t)
+	requestToken := signWithDID(t, holder, jwt.MapClaims{
+		"iss":         holder.DID,
+		"issuer":      issuer.DID,
+		"fingerprint": "old-credential",
+		"newHolder":   "did:key:new-wallet",
+	})
+	tx, err := sim.Submit(admin, "SmartContract:RequestRebinding", requestToken)
+	require.NoError(t, err)
+	var request cuckoofilter.RebindingRequest
+	require.NoError(t, json.Unmarshal(tx.Payload, &request))
+
+	// An approval cannot be replayed as a rejection
+	decision := signWithDID(t, issuer, jwt.MapClaims{"iss": issuer.DID, "requestID": request.ID, "decision": cuckoofilter.DecisionApprove})
+	_, err = sim.Submit(admin, "SmartContract:RejectRebinding", request.ID, decision)
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+	decision = signWithDID(t, issuer, jwt.MapClaims{"iss": issuer.DID, "requestID": request.ID, "decision": cuckoofilter.DecisionReject})
+	_, err = sim.Submit(admin, "SmartContract:RejectRebinding", request.ID, decision)
+	require.NoError(t, err)
+
+	status, err := sim.Evaluate(admin, "SmartContract:LookupStatus", "old-credential")
+	require.NoError(t, err)
+	require.Equal(t, cuckoofilter.CredentialStatusActive, string(status))
+	_, err = sim.Evaluate(admin, "SmartContract:GetSupersession", "old-credential")
+	require.Error(t, err)
+}
+
+func TestRequestRebinding_MissingNewHolder(t *testing.T) {
+	useStakeholderDir(t)
+	sim, admin, issuer, holder := newRebindingSimulator(t)
+	requestToken := signWithDID(t, holder, jwt.MapClaims{
+		"iss":         holder.DID,
+		"issuer":      issuer.DID,
+		"fingerprint": "old-credential",
+	})
+	_, err := sim.Submit(admin, "SmartContract:RequestRebinding", requestToken)
+	require.Error(t, err)
+}
@@ -0,0 +1,196 @@
+package cuckoofilter
+
+import (
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ResizeLoadFactor is the default load factor above which inserts into the registry filter grow it first,
+// see RegistryConfig.ResizeLoadFactor
+const ResizeLoadFactor = 0.9
+
+// MaxResizeBuckets is the largest number of buckets ResizeFilter accepts and inserts grow the filter to.
+// Larger filters outgrow what a transaction can write and what ImportFilterSnapshot accepts.
+const MaxResizeBuckets = 1 << 22
+
+// resizeLoadFactor returns the load factor above which inserts grow the filter
+func (f *Filter) resizeLoadFactor() float64 {
+	if f.growLoadFactor == 0 {
+		return ResizeLoadFactor
+	}
+	return f.growLoadFactor
+}
+
+// maxBuckets returns the number of buckets inserts grow the filter to at most
+func (f *Filter) maxBuckets() uint {
+	if f.growBuckets == 0 {
+		return MaxResizeBuckets
+	}
+	return f.growBuckets
+}
+
+// LoadFactor returns the share of the filter's slots that hold a fingerprint
+func (f *Filter) LoadFactor() float64 {
+	slots := 0
+	for _, b := range f.Buckets {
+		if b != nil {
+			slots += len(b.Data)
+		}
+	}
+	if slots == 0 {
+		return 1
+	}
+	return float64(f.storedFingerprints()) / float64(slots)
+}
+
+// storedFingerprints counts the occupied slots, which unlike Count is exact after cuckoo kicking
+func (f *Filter) storedFingerprints() int {
+	stored := 0
+	for _, b := range f.Buckets {
+		if b == nil {
+			continue
+		}
+		for _, fp := range b.Data {
+			if len(fp) != 0 {
+				stored++
+			}
+		}
+	}
+	return stored
+}
+
+// bucketSize returns the number of slots per bucket
+func (f *Filter) bucketSize() uint {
+	for _, b := range f.Buckets {
+		if b != nil && len(b.Data) > 0 {
+			return uint(len(b.Data))
+		}
+	}
+	return DefaultBucketSize
+}
+
+// Resize returns a copy of the filter with numElements buckets, rounded up to a power of two, holding the same
+// fingerprints. Fingerprints of FingerPrintSize bytes keep the full 64-bit hash of the data, so every
+// fingerprint is rehashed to its bucket in the new filter without knowing the data. Filters with shorter
+// fingerprints cannot be resized.
+func (f *Filter) Resize(numElements uint) (*Filter, error) {
+	if f.fingerprintSize() != FingerPrintSize {
+		return nil, fmt.Errorf("cannot resize a filter with fingerprints of %d bytes", f.fingerprintSize())
+	}
+	resized := NewFilter(numElements, f.bucketSize(), FingerPrintSize)
+	resized.MaxKicks = f.MaxKicks
+	resized.SemiSorted = f.SemiSorted
+	resized.fingerprintKey = f.fingerprintKey
+	for _, b := range f.Buckets {
+		if b == nil {
+			continue
+		}
+		for _, fp := range b.Data {
+			if len(fp) == 0 {
+				continue
+			}
+			if len(fp) != FingerPrintSize {
+				return nil, fmt.Errorf("cannot rehash fingerprint of %d bytes", len(fp))
+			}
+			hash := uint64(0)
+			for i := FingerPrintSize - 1; i >= 0; i-- {
+				hash = hash<<8 | uint64(fp[i])
+			}
+			i1 := uint(hash>>32) & resized.BucketIndexMask
+			if !resized.insertFingerprint(i1, append(fingerprint(nil), fp...)) {
+				return nil, errResizeTooSmall(resized, f)
+			}
+		}
+	}
+	// Kicking drops a fingerprint when it runs out of room, so check none got lost
+	if resized.storedFingerprints() != f.storedFingerprints() {
+		return nil, errResizeTooSmall(resized, f)
+	}
+	resized.Count = f.Count
+	return resized, nil
+}
+
+func errResizeTooSmall(resized *Filter, f *Filter) error {
+	return fmt.Errorf("filter with %d buckets is too small for %d fingerprints", len(resized.Buckets), f.storedFingerprints())
+}
+
+// Grow returns a copy of the filter with twice the buckets
+func (f *Filter) Grow() (*Filter, error) {
+	return f.Resize(uint(len(f.Buckets)) * 2)
+}
+
+// ResizeFilter rebuilds the registry filter with numElements buckets, rounded up to a power of two,
+// so the registry can scale beyond the size it was initialized with. A resize keeps the revocations
+// as they are, so it is allowed while the registry is frozen: freezing first keeps writes from racing
+// the resize and its verification. Registry admins only.
+func (s *SmartContract) ResizeFilter(ctx contractapi.TransactionContextInterface, numElements uint) error {
+	if err := checkRegistryAdmin(ctx, "resize the filter"); err != nil {
+		return err
+	}
+	if numElements == 0 || numElements > MaxResizeBuckets {
+		return fmt.Errorf("%w: number of buckets must be between 1 and %d", ErrInvalidArgument, MaxResizeBuckets)
+	}
+	filter, err := s.LoadFilterState(ctx)
+	if err != nil {
+		return fmt.Errorf("error loading filter state: %v", err)
+	}
+	resized, err := filter.Resize(numElements)
+	if err != nil {
+		return err
+	}
+	return saveFilterState(ctx, resized)
+}
+
+// maxGrowSteps bounds how often a single insert grows the filter
+const maxGrowSteps = 4
+
+// insertFailure is the error for data insertGrowing did not insert, ErrAlreadyRevoked when the filter already
+// holds it and ErrFilterFull otherwise. The message names the data as described, e.g. data 'x'.
+func insertFailure(filter *Filter, data []byte, described string) error {
+	if filter.Lookup(data) {
+		return fmt.Errorf("%w: %s is already in the cuckoo filter", ErrAlreadyRevoked, described)
+	}
+	return fmt.Errorf("%w: failed to insert %s into cuckoo filter", ErrFilterFull, described)
+}
+
+// insertGrowing inserts data into the filter and returns the filter to save. The filter is doubled first when
+// its load factor exceeds the configured ResizeLoadFactor, and again while both candidate buckets of the data are full,
+// so the registry filter never relies on cuckoo kicking, which can drop a fingerprint once buckets saturate.
+// Growth stops at the configured MaxFilterBuckets; a filter of that size only takes data with a free candidate
+// bucket, so insertFailure reports ErrFilterFull instead of the filter outgrowing a transaction.
+// Filters with fingerprints shorter than FingerPrintSize cannot grow and insert directly.
+func insertGrowing(filter *Filter, data []byte) (*Filter, bool) {
+	if filter.Lookup(data) {
+		return filter, false
+	}
+	loadFactor := filter.resizeLoadFactor()
+	for step := 0; step < maxGrowSteps && filter.fingerprintSize() == FingerPrintSize; step++ {
+		if filter.LoadFactor() < loadFactor && filter.hasFreeCandidate(data) {
+			break
+		}
+		if uint(len(filter.Buckets))*2 > filter.maxBuckets() {
+			if !filter.hasFreeCandidate(data) {
+				return filter, false
+			}
+			break
+		}
+		grown, err := filter.Grow()
+		if err != nil {
+			return filter, false
+		}
+		grown.growLoadFactor = loadFactor
+		grown.growBuckets = filter.growBuckets
+		filter = grown
+	}
+	return filter, filter.Insert(data)
+}
+
+// hasFreeCandidate reports whether one of the two buckets of data has a free slot
+func (f *Filter) hasFreeCandidate(data []byte) bool {
+	if len(f.Buckets) == 0 {
+		return false
+	}
+	i1, fp := f.indexAndFingerprint(data)
+	i2 := GetAltIndex(fp, i1, f.BucketIndexMask)
+	return !f.Buckets[i1].IsFull() || !f.Buckets[i2].IsFull()
+}
@@ -0,0 +1,141 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"testing"
+)
+
+func TestFilterResize_KeepsFingerprints(t *testing.T) {
+	filter := cuckoofilter.NewFilter(16, 4, cuckoofilter.FingerPrintSize)
+	for i := 0; i < 40; i++ {
+		require.True(t, filter.Insert([]byte(fmt.Sprintf("credential-%d", i))))
+	}
+
+	resized, err := filter.Resize(64)
+	require.NoError(t, err)
+	require.Len(t, resized.Buckets, 64)
+	require.Equal(t, filter.Count, resized.Count)
+	require.Less(t, resized.LoadFactor(), filter.LoadFactor())
+	for i := 0; i < 40; i++ {
+		require.True(t, resized.Lookup([]byte(fmt.Sprintf("credential-%d", i))))
+	}
+
+	// Deletes still find the rehashed fingerprints
+	require.True(t, resized.Delete([]byte("credential-0")))
+	require.False(t, resized.Lookup([]byte("credential-0")))
+}
+
+func TestFilterResize_TooSmall(t *testing.T) {
+	filter := cuckoofilter.NewFilter(16, 4, cuckoofilter.FingerPrintSize)
+	for i := 0; i < 40; i++ {
+		require.True(t, filter.Insert([]byte(fmt.Sprintf("credential-%d", i))))
+	}
+	_, err := filter.Resize(2)
+	require.Error(t, err)
+}
+
+func TestResizeFilter(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	registryAdmin := newRegistryAdmin(t)
+	_, err := sim.Submit(admin, "Insert", "", "credential-1")
+	require.NoError(t, err)
+
+	_, err = sim.Submit(registryAdmin, "ResizeFilter", "1024")
+	require.NoError(t, err)
+	requireStatus(t, sim, admin, "credential-1", cuckoofilter.CredentialStatusRevoked)
+}
+
+func TestResizeFilter_Checks(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	_, err := sim.Submit(admin, "ResizeFilter", "1024")
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+	registryAdmin := newRegistryAdmin(t)
+	for _, buckets := range []string{"0", strconv.Itoa(cuckoofilter.MaxResizeBuckets + 1)} {
+		_, err = sim.Submit(registryAdmin, "ResizeFilter", buckets)
+		require.ErrorContains(t, err, cuckoofilter.InvalidArgumentErrorCode, buckets)
+	}
+}
+
+func TestResizeFilter_WhileFrozen(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	registryAdmin := newRegistryAdmin(t)
+	_, err := sim.Submit(admin, "Insert", "", "credential-1")
+	require.NoError(t, err)
+	_, err = sim.Submit(registryAdmin, "FreezeRegistry", "resize")
+	require.NoError(t, err)
+
+	// Resizing keeps the revocations, so it runs during the freeze that keeps writes out
+	_, err = sim.Submit(registryAdmin, "ResizeFilter", "1024")
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "Insert", "", "credential-2")
+	require.ErrorContains(t, err, cuckoofilter.RegistryFrozenErrorCode)
+	requireStatus(t, sim, admin, "credential-1", cuckoofilter.CredentialStatusRevoked)
+}
+
+func TestInsert_GrowsFullFilter(t *testing.T) {
+	sim, err := simulator.New("credential-management", &cuckoofilter.SmartContract{})
+	require.NoError(t, err)
+	admin, err := simulator.NewIdentity("Org1MSP", "registry-admin")
+	require.NoError(t, err)
+	_, err = sim.Submit(newRegistryAdmin(t), "Init", "", "2", "2", "0")
+	require.NoError(t, err)
+
+	// Far more credentials than the 4 slots the registry was initialized with
+	for i := 0; i < 50; i++ {
+		_, err = sim.Submit(admin, "Insert", "", fmt.Sprintf("credential-%d", i))
+		require.NoError(t, err)
+	}
+	_, err = sim.Submit(admin, "BatchInsert", "", `["batch-1","batch-2","batch-3"]`)
+	require.NoError(t, err)
+
+	for i := 0; i < 50; i++ {
+		requireStatus(t, sim, admin, fmt.Sprintf("credential-%d", i), cuckoofilter.CredentialStatusRevoked)
+	}
+	requireStatus(t, sim, admin, "batch-2", cuckoofilter.CredentialStatusRevoked)
+}
+
+func TestInsert_GrowthStopsAtMaxFilterBuckets(t *testing.T) {
+	sim, err := simulator.New("credential-management", &cuckoofilter.SmartContract{})
+	require.NoError(t, err)
+	admin, err := simulator.NewIdentity("Org1MSP", "registry-admin")
+	require.NoError(t, err)
+	_, err = sim.Submit(newRegistryAdmin(t), "Init", "", "2", "4", "0")
+	require.NoError(t, err)
+	require.NoError(t, sim.SetState("RegistryConfig", []byte(`{"maxFilterBuckets": 8}`)))
+
+	// Inserts grow the filter from 2 up to 8 buckets of 4 slots, then fail instead of growing further
+	inserted := 0
+	for i := 0; i < 64; i++ {
+		_, err := sim.Submit(admin, "Insert", "", fmt.Sprintf("credential-%d", i))
+		if err != nil {
+			require.ErrorContains(t, err, cuckoofilter.FilterFullErrorCode)
+			break
+		}
+		inserted++
+	}
+	require.Greater(t, inserted, 4)
+	require.Less(t, inserted, 64)
+
+	filtersJSON, err := sim.Evaluate(admin, "ListFilters")
+	require.NoError(t, err)
+	var filters []*cuckoofilter.FilterInfo
+	require.NoError(t, json.Unmarshal(filtersJSON, &filters))
+	require.Equal(t, uint(32), filters[0].Capacity)
+	for i := 0; i < inserted; i++ {
+		requireStatus(t, sim, admin, fmt.Sprintf("credential-%d", i), cuckoofilter.CredentialStatusRevoked)
+	}
+
+	_, err = sim.Submit(newRegistryAdmin(t), "ResizeFilter", "16")
+	require.NoError(t, err)
+}
+
+func TestRegistryConfig_MaxFilterBuckets(t *testing.T) {
+	config := cuckoofilter.DefaultRegistryConfig()
+	config.MaxFilterBuckets = cuckoofilter.MaxResizeBuckets + 1
+	require.Error(t, config.Validate())
+}
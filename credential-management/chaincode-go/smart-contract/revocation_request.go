@@ -0,0 +1,318 @@
+package cuckoofilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"time"
+)
+
+// revocationRequestObjectType is the composite key prefix of holder-initiated revocation requests
+const revocationRequestObjectType = "revocationRequest"
+
+// Status values of a revocation request
+const (
+	RevocationRequestPending  = "pending"
+	RevocationRequestApproved = "approved"
+	RevocationRequestRejected = "rejected"
+)
+
+// Values of the decision claim of an issuer's decision token, so a token signed to reject a request
+// cannot be submitted to approve it or the other way round
+const (
+	DecisionApprove = "approve"
+	DecisionReject  = "reject"
+)
+
+// ProvenanceHolderRequested marks revocations that were triggered by an approved holder request
+const ProvenanceHolderRequested = "holder-requested"
+
+// RevocationRequest is a holder's request to revoke one of their credentials, pending issuer approval
+type RevocationRequest struct {
+	ID          string    `json:"id"`
+	Fingerprint string    `json:"fingerprint"`
+	HolderDID   string    `json:"holderDID"`
+	IssuerDID   string    `json:"issuerDID"`
+	Reason      string    `json:"reason"`
+	Status      string    `json:"status"`
+	Provenance  string    `json:"provenance,omitempty" metadata:",optional"`
+	RequestedAt time.Time `json:"requestedAt"`
+	DecidedAt   time.Time `json:"decidedAt"`
+	DecisionTx  string    `json:"decisionTx,omitempty" metadata:",optional"` // ID of the transaction that approved or rejected the request
+}
+
+// RequestRevocation records a holder-signed revocation request for the holder's own credential.
+// The request token is a JWT signed with the holder's key carrying the claims iss (holder DID),
+// issuer (issuer DID), fingerprint, credential and an optional reason. The credential claim is the JWT
+// of the credential, which proves that the issuer issued the fingerprint to the holder. Both tokens are
+// verified against the keys registered for the DIDs, see RegisterDIDKey.
+// The transaction ID becomes the request ID.
+func (s *SmartContract) RequestRevocation(ctx contractapi.TransactionContextInterface, requestToken string) (*RevocationRequest, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	claims, err := parseUnverifiedClaims(requestToken)
+	if err != nil {
+		return nil, err
+	}
+	holderDID, _ := claims["iss"].(string)
+	issuerDID, _ := claims["issuer"].(string)
+	fingerprint, _ := claims["fingerprint"].(string)
+	credentialJWT, _ := claims["credential"].(string)
+	reason, _ := claims["reason"].(string)
+	if holderDID == "" || issuerDID == "" || fingerprint == "" || credentialJWT == "" {
+		return nil, fmt.Errorf("revocation request must contain iss, issuer, fingerprint and credential claims")
+	}
+
+	if _, err := verifyStakeholderToken(ctx, holderDID, requestToken); err != nil {
+		return nil, fmt.Errorf("invalid holder signature on revocation request: %v", err)
+	}
+	if err := s.checkCredentialOwner(ctx, credentialJWT, issuerDID, holderDID, fingerprint); err != nil {
+		return nil, err
+	}
+
+	requestedAt, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	request := &RevocationRequest{
+		ID:          ctx.GetStub().GetTxID(),
+		Fingerprint: fingerprint,
+		HolderDID:   holderDID,
+		IssuerDID:   issuerDID,
+		Reason:      reason,
+		Status:      RevocationRequestPending,
+		RequestedAt: requestedAt,
+	}
+	if err := saveRevocationRequest(ctx, request); err != nil {
+		return nil, err
+	}
+	if err := recordLifecycleEvent(ctx, LifecycleRevocationRequest, "request "+request.ID+": "+reason, fingerprint); err != nil {
+		return nil, err
+	}
+	if err := indexSubject(ctx, holderDID, fingerprint, issuerDID); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// ApproveRevocationRequest lets the issuer approve a pending request, which inserts the fingerprint
+// into the cuckoo filter. The decision token is a JWT signed with the issuer's registered key carrying
+// the claims iss (issuer DID), requestID and decision, which must be DecisionApprove. The submitting
+// client passes the same checks as for Insert.
+func (s *SmartContract) ApproveRevocationRequest(ctx contractapi.TransactionContextInterface, requestID string, decisionToken string) (*RevocationRequest, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	if err := checkCapability(ctx, CapabilityActionRevoke, DefaultFilterID); err != nil {
+		return nil, err
+	}
+	request, err := s.decideRevocationRequest(ctx, requestID, decisionToken, RevocationRequestApproved)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkStrictMode(ctx, request.Fingerprint); err != nil {
+		return nil, err
+	}
+
+	filter, err := s.LoadFilterState(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error loading filter state: %v", err)
+	}
+	filter, inserted := insertGrowing(filter, []byte(request.Fingerprint))
+	if !inserted {
+		return nil, insertFailure(filter, []byte(request.Fingerprint), fmt.Sprintf("data '%s'", request.Fingerprint))
+	}
+	if err := recordInserter(ctx, filterScope(DefaultFilterID), request.Fingerprint); err != nil {
+		return nil, err
+	}
+	if err := recordLifecycleEvent(ctx, LifecycleRevoked, "approved request "+request.ID, request.Fingerprint); err != nil {
+		return nil, err
+	}
+	if err := recordRevocationAudit(ctx, LifecycleRevoked, request.Reason, request.Fingerprint); err != nil {
+		return nil, err
+	}
+	if err := s.SaveFilterState(ctx, filter); err != nil {
+		return nil, err
+	}
+
+	request.Provenance = ProvenanceHolderRequested
+	if err := saveRevocationRequest(ctx, request); err != nil {
+		return nil, err
+	}
+	if err := emitFilterChanged(ctx, DefaultFilterID, FilterChangeInserted, filter, []string{request.Fingerprint}); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// RejectRevocationRequest lets the issuer reject a pending request, leaving the filter untouched. The
+// decision token carries the same claims as for ApproveRevocationRequest with the decision DecisionReject.
+func (s *SmartContract) RejectRevocationRequest(ctx contractapi.TransactionContextInterface, requestID string, decisionToken string) (*RevocationRequest, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	request, err := s.decideRevocationRequest(ctx, requestID, decisionToken, RevocationRequestRejected)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordLifecycleEvent(ctx, LifecycleRevocationRejected, "request "+request.ID, request.Fingerprint); err != nil {
+		return nil, err
+	}
+	if err := saveRevocationRequest(ctx, request); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// GetRevocationRequest returns a recorded revocation request
+func (s *SmartContract) GetRevocationRequest(ctx contractapi.TransactionContextInterface, requestID string) (*RevocationRequest, error) {
+	return loadRevocationRequest(ctx, requestID)
+}
+
+// decideRevocationRequest checks the issuer's decision token against a pending request and updates its status
+func (s *SmartContract) decideRevocationRequest(ctx contractapi.TransactionContextInterface, requestID string, decisionToken string, status string) (*RevocationRequest, error) {
+	request, err := loadRevocationRequest(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request.Status != RevocationRequestPending {
+		return nil, fmt.Errorf("revocation request %s is already %s", requestID, request.Status)
+	}
+
+	claims, err := verifyStakeholderToken(ctx, request.IssuerDID, decisionToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid issuer signature on decision: %v", err)
+	}
+	if iss, _ := claims["iss"].(string); iss != request.IssuerDID {
+		return nil, fmt.Errorf("decision is not signed by the credential issuer")
+	}
+	if id, _ := claims["requestID"].(string); id != requestID {
+		return nil, fmt.Errorf("decision does not refer to revocation request %s", requestID)
+	}
+	if err := checkDecisionClaim(claims, status); err != nil {
+		return nil, err
+	}
+
+	decidedAt, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	request.Status = status
+	request.DecidedAt = decidedAt
+	request.DecisionTx = ctx.GetStub().GetTxID()
+	return request, nil
+}
+
+// verifyStakeholderToken checks the signature of a JWT against the public key registered for the DID on
+// the ledger, see RegisterDIDKey, with the algorithm of its key type
+func verifyStakeholderToken(ctx contractapi.TransactionContextInterface, did string, tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		publicKey, err := didPublicKey(ctx, did)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkJWTAlgorithm(token, publicKey); err != nil {
+			return nil, err
+		}
+		return publicKey, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("token is not valid")
+	}
+	return claims, nil
+}
+
+// checkCredentialOwner checks that a credential JWT is signed by the issuer's registered key, issued to
+// the holder and has the fingerprint
+func (s *SmartContract) checkCredentialOwner(ctx contractapi.TransactionContextInterface, credentialJWT string, issuerDID string, holderDID string, fingerprint string) error {
+	claims, err := verifyStakeholderToken(ctx, issuerDID, credentialJWT)
+	if err != nil {
+		return fmt.Errorf("%w: invalid issuer signature on credential: %v", ErrUnauthorized, err)
+	}
+	var credential VerifiableCredential
+	if err := credential.FromJWTClaims(claims); err != nil {
+		return err
+	}
+	if credential.Issuer != issuerDID || credential.CredentialSubject.ID != holderDID {
+		return fmt.Errorf("%w: credential was not issued by %s to %s", ErrUnauthorized, issuerDID, holderDID)
+	}
+	status, err := s.GetTokenCredentialStatus(ctx, credentialJWT)
+	if err != nil {
+		return err
+	}
+	if status.Fingerprint != fingerprint {
+		return fmt.Errorf("%w: fingerprint '%s' is not the fingerprint of the credential", ErrUnauthorized, fingerprint)
+	}
+	return nil
+}
+
+// checkDecisionClaim checks that the decision claim of a decision token matches the status the
+// transaction moves the request to
+func checkDecisionClaim(claims jwt.MapClaims, status string) error {
+	expected := DecisionReject
+	if status == RevocationRequestApproved {
+		expected = DecisionApprove
+	}
+	if decision, _ := claims["decision"].(string); decision != expected {
+		return fmt.Errorf("%w: decision token must carry the decision %q, got %q", ErrUnauthorized, expected, decision)
+	}
+	return nil
+}
+
+// parseUnverifiedClaims decodes the claims of a JWT so the signer can be looked up before verification
+func parseUnverifiedClaims(tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(tokenString, claims); err != nil {
+		return nil, fmt.Errorf("error parsing JWT: %v", err)
+	}
+	return claims, nil
+}
+
+// txTimestamp returns the transaction timestamp, which is identical on all endorsing peers
+func txTimestamp(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error reading transaction timestamp: %v", err)
+	}
+	return timestamp.AsTime().UTC(), nil
+}
+
+func saveRevocationRequest(ctx contractapi.TransactionContextInterface, request *RevocationRequest) error {
+	key, err := ctx.GetStub().CreateCompositeKey(revocationRequestObjectType, []string{request.ID})
+	if err != nil {
+		return fmt.Errorf("error creating revocation request key: %v", err)
+	}
+	requestJSON, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(key, requestJSON)
+}
+
+func loadRevocationRequest(ctx contractapi.TransactionContextInterface, requestID string) (*RevocationRequest, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(revocationRequestObjectType, []string{requestID})
+	if err != nil {
+		return nil, fmt.Errorf("error creating revocation request key: %v", err)
+	}
+	requestJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("error loading revocation request: %v", err)
+	}
+	if requestJSON == nil {
+		return nil, fmt.Errorf("%w: revocation request %s not found", ErrNotFound, requestID)
+	}
+
+	var request RevocationRequest
+	if err := json.Unmarshal(requestJSON, &request); err != nil {
+		return nil, fmt.Errorf("error decoding revocation request: %v", err)
+	}
+	return &request, nil
+}
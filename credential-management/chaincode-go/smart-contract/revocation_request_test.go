@@ -0,0 +1,334 @@
+package cuckoofilter_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/pherbke/credential-management/chaincode-go/mocks"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+	"time"
+)
+
+const revocationRequestKey = "\x00revocationRequest\x00tx1\x00"
+
+// privateKeyOfDID decodes the P-256 private key returned by GenerateDID
+func privateKeyOfDID(t *testing.T, didResponse *cuckoofilter.DIDResponse) *ecdsa.PrivateKey {
+	privateKeyBytes, err := base64.StdEncoding.DecodeString(didResponse.PrivateKey)
+	require.NoError(t, err)
+	var keyParts struct {
+		D, X, Y *big.Int
+	}
+	require.NoError(t, json.Unmarshal(privateKeyBytes, &keyParts))
+	return &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{Curve: elliptic.P256(), X: keyParts.X, Y: keyParts.Y},
+		D:         keyParts.D,
+	}
+}
+
+// publicKeyOfDID encodes the public key of a DID returned by GenerateDID as in the key files
+func publicKeyOfDID(t *testing.T, didResponse *cuckoofilter.DIDResponse) string {
+	privateKey := privateKeyOfDID(t, didResponse)
+	publicKeyBytes, err := json.Marshal(struct{ X, Y *big.Int }{privateKey.X, privateKey.Y})
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(publicKeyBytes)
+}
+
+// signWithDID signs the claims as ES256 JWT with the private key returned by GenerateDID
+func signWithDID(t *testing.T, didResponse *cuckoofilter.DIDResponse, claims jwt.MapClaims) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodES256, claims).SignedString(privateKeyOfDID(t, didResponse))
+	require.NoError(t, err)
+	return token
+}
+
+func newRevocationRequestContext() (*mocks.MockChaincodeStubInterface, *mocks.MockTransactionContext) {
+	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
+	mockTxContext := new(mocks.MockTransactionContext)
+	mockTxContext.On("GetStub").Return(mockStub)
+	mockTxContext.Stub = mockStub
+	mockAdminIdentity(mockTxContext)
+
+	mockStub.On("GetTxID").Return("tx1")
+	mockStub.On("GetTxTimestamp").Return(&timestamp.Timestamp{Seconds: 1700000000}, nil)
+	mockStub.On("CreateCompositeKey", "revocationRequest", []string{"tx1"}).Return(revocationRequestKey, nil)
+	return mockStub, mockTxContext
+}
+
+func pendingRequestJSON(holderDID, issuerDID string) []byte {
+	requestJSON, _ := json.Marshal(cuckoofilter.RevocationRequest{
+		ID:          "tx1",
+		Fingerprint: "0a1b2c3d4e5f6a7b",
+		HolderDID:   holderDID,
+		IssuerDID:   issuerDID,
+		Reason:      "lost device",
+		Status:      cuckoofilter.RevocationRequestPending,
+		RequestedAt: time.Unix(1700000000, 0).UTC(),
+	})
+	return requestJSON
+}
+
+// revocationRequestFixture is a registry with an issuer and a holder whose keys are registered on the
+// ledger, and a credential the issuer issued to the holder
+type revocationRequestFixture struct {
+	sim            *simulator.Simulator
+	admin          *simulator.Identity
+	issuerIdentity *simulator.Identity
+	issuer         *cuckoofilter.DIDResponse
+	holder         *cuckoofilter.DIDResponse
+	credential     string // JWT of the credential
+	fingerprint    string
+}
+
+func newRevocationRequestFixture(t *testing.T) *revocationRequestFixture {
+	sim, admin, issuer, holder := newRebindingSimulator(t)
+	credential, err := cuckoofilter.CreateAndSignCredential(issuer.DID, privateKeyOfDID(t, issuer), holder.DID)
+	require.NoError(t, err)
+	claims, err := credential.ToJWTClaims()
+	require.NoError(t, err)
+	credentialJWT := signWithDID(t, issuer, claims)
+	statusJSON, err := sim.Evaluate(admin, "SmartContract:GetTokenCredentialStatus", credentialJWT)
+	require.NoError(t, err)
+	var status cuckoofilter.CredentialStatus
+	require.NoError(t, json.Unmarshal(statusJSON, &status))
+	return &revocationRequestFixture{
+		sim:            sim,
+		admin:          admin,
+		issuerIdentity: newIssuerIdentity(t, "Org1MSP", issuer.DID),
+		issuer:         issuer,
+		holder:         holder,
+		credential:     credentialJWT,
+		fingerprint:    status.Fingerprint,
+	}
+}
+
+// request submits a revocation request of the holder for the credential
+func (f *revocationRequestFixture) request(t *testing.T) *cuckoofilter.RevocationRequest {
+	requestToken := signWithDID(t, f.holder, jwt.MapClaims{
+		"iss":         f.holder.DID,
+		"issuer":      f.issuer.DID,
+		"fingerprint": f.fingerprint,
+		"credential":  f.credential,
+		"reason":      "lost device",
+	})
+	tx, err := f.sim.Submit(f.admin, "SmartContract:RequestRevocation", requestToken)
+	require.NoError(t, err)
+	var request cuckoofilter.RevocationRequest
+	require.NoError(t, json.Unmarshal(tx.Payload, &request))
+	return &request
+}
+
+func TestRequestRevocation(t *testing.T) {
+	useStakeholderDir(t)
+	f := newRevocationRequestFixture(t)
+	request := f.request(t)
+	require.Equal(t, cuckoofilter.RevocationRequestPending, request.Status)
+	require.Equal(t, f.fingerprint, request.Fingerprint)
+	require.Equal(t, f.holder.DID, request.HolderDID)
+	requireStatus(t, f.sim, f.admin, f.fingerprint, cuckoofilter.CredentialStatusActive)
+}
+
+func TestRequestRevocation_NotSignedByHolder(t *testing.T) {
+	useStakeholderDir(t)
+	f := newRevocationRequestFixture(t)
+
+	// The issuer signs a request in the holder's name
+	requestToken := signWithDID(t, f.issuer, jwt.MapClaims{
+		"iss":         f.holder.DID,
+		"issuer":      f.issuer.DID,
+		"fingerprint": f.fingerprint,
+		"credential":  f.credential,
+	})
+	_, err := f.sim.Submit(f.admin, "SmartContract:RequestRevocation", requestToken)
+	require.Error(t, err)
+}
+
+func TestRequestRevocation_KeyMustBeRegisteredForDID(t *testing.T) {
+	useStakeholderDir(t)
+	f := newRevocationRequestFixture(t)
+	// A holder whose key is registered on another ledger only
+	other := newRevocationRequestFixture(t).holder
+
+	requestToken := signWithDID(t, other, jwt.MapClaims{
+		"iss":         other.DID,
+		"issuer":      f.issuer.DID,
+		"fingerprint": f.fingerprint,
+		"credential":  f.credential,
+	})
+	_, err := f.sim.Submit(f.admin, "SmartContract:RequestRevocation", requestToken)
+	require.ErrorContains(t, err, cuckoofilter.NotFoundErrorCode)
+
+	// Only the key a did:key encodes can be registered for it
+	_, err = f.sim.Submit(f.admin, "SmartContract:RegisterDIDKey", f.holder.DID, other.KeyType, publicKeyOfDID(t, other))
+	require.ErrorContains(t, err, cuckoofilter.InvalidArgumentErrorCode)
+	_, err = f.sim.Submit(f.admin, "SmartContract:RegisterDIDKey", other.DID, other.KeyType, publicKeyOfDID(t, other))
+	require.NoError(t, err)
+}
+
+func TestRequestRevocation_RequiresOwnCredential(t *testing.T) {
+	useStakeholderDir(t)
+	f := newRevocationRequestFixture(t)
+	for name, claims := range map[string]jwt.MapClaims{
+		"missing credential": {"fingerprint": f.fingerprint},
+		"other fingerprint":  {"fingerprint": "0a1b2c3d4e5f6a7b", "credential": f.credential},
+		// A credential signed by the holder itself does not prove the issuer issued it
+		"self-signed credential": {"fingerprint": f.fingerprint, "credential": signWithDID(t, f.holder, jwt.MapClaims{"iss": f.issuer.DID})},
+	} {
+		claims["iss"] = f.holder.DID
+		claims["issuer"] = f.issuer.DID
+		_, err := f.sim.Submit(f.admin, "SmartContract:RequestRevocation", signWithDID(t, f.holder, claims))
+		require.Error(t, err, name)
+	}
+
+	// The credential of another holder cannot be requested for revocation
+	other := newRevocationRequestFixture(t)
+	requestToken := signWithDID(t, other.holder, jwt.MapClaims{
+		"iss":         other.holder.DID,
+		"issuer":      f.issuer.DID,
+		"fingerprint": f.fingerprint,
+		"credential":  f.credential,
+	})
+	_, err := f.sim.Submit(f.admin, "SmartContract:RegisterDIDKey", other.holder.DID, other.holder.KeyType, publicKeyOfDID(t, other.holder))
+	require.NoError(t, err)
+	_, err = f.sim.Submit(f.admin, "SmartContract:RequestRevocation", requestToken)
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+}
+
+func TestApproveRevocationRequest(t *testing.T) {
+	useStakeholderDir(t)
+	f := newRevocationRequestFixture(t)
+	request := f.request(t)
+
+	decisionToken := signWithDID(t, f.issuer, jwt.MapClaims{"iss": f.issuer.DID, "requestID": request.ID, "decision": cuckoofilter.DecisionApprove})
+	tx, err := f.sim.Submit(f.issuerIdentity, "SmartContract:ApproveRevocationRequest", request.ID, decisionToken)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(tx.Payload, request))
+	require.Equal(t, cuckoofilter.RevocationRequestApproved, request.Status)
+	require.Equal(t, cuckoofilter.ProvenanceHolderRequested, request.Provenance)
+	require.Equal(t, tx.ID, request.DecisionTx)
+	require.Equal(t, cuckoofilter.FilterChangedEvent, tx.Event.EventName)
+	var change cuckoofilter.FilterChange
+	require.NoError(t, json.Unmarshal(tx.Event.Payload, &change))
+	require.Equal(t, cuckoofilter.FilterChangeInserted, change.Action)
+	require.Equal(t, []string{f.fingerprint}, change.Fingerprints)
+
+	// The approved fingerprint is revoked
+	requireStatus(t, f.sim, f.admin, f.fingerprint, cuckoofilter.CredentialStatusRevoked)
+}
+
+func TestApproveRevocationRequest_StrictMode(t *testing.T) {
+	useStakeholderDir(t)
+	f := newRevocationRequestFixture(t)
+	request := f.request(t)
+	_, err := f.sim.Submit(newRegistryAdmin(t), "SmartContract:SetStrictMode", "true")
+	require.NoError(t, err)
+
+	decisionToken := signWithDID(t, f.issuer, jwt.MapClaims{"iss": f.issuer.DID, "requestID": request.ID, "decision": cuckoofilter.DecisionApprove})
+	_, err = f.sim.Submit(f.issuerIdentity, "SmartContract:ApproveRevocationRequest", request.ID, decisionToken)
+	require.NoError(t, err)
+	requireStatus(t, f.sim, f.admin, f.fingerprint, cuckoofilter.CredentialStatusRevoked)
+}
+
+func TestRejectRevocationRequest(t *testing.T) {
+	useStakeholderDir(t)
+	f := newRevocationRequestFixture(t)
+	request := f.request(t)
+
+	decisionToken := signWithDID(t, f.issuer, jwt.MapClaims{"iss": f.issuer.DID, "requestID": request.ID, "decision": cuckoofilter.DecisionReject})
+	tx, err := f.sim.Submit(f.issuerIdentity, "SmartContract:RejectRevocationRequest", request.ID, decisionToken)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(tx.Payload, request))
+	require.Equal(t, cuckoofilter.RevocationRequestRejected, request.Status)
+	require.Empty(t, request.Provenance)
+	requireStatus(t, f.sim, f.admin, f.fingerprint, cuckoofilter.CredentialStatusActive)
+}
+
+func TestApproveRevocationRequest_Failures(t *testing.T) {
+	useStakeholderDir(t)
+	f := newRevocationRequestFixture(t)
+	request := f.request(t)
+
+	for name, test := range map[string]struct {
+		signer *cuckoofilter.DIDResponse
+		claims jwt.MapClaims
+	}{
+		"other request":     {f.issuer, jwt.MapClaims{"iss": f.issuer.DID, "requestID": "tx2", "decision": cuckoofilter.DecisionApprove}},
+		"other issuer":      {f.issuer, jwt.MapClaims{"iss": "did:key:other", "requestID": request.ID, "decision": cuckoofilter.DecisionApprove}},
+		"signed by holder":  {f.holder, jwt.MapClaims{"iss": f.issuer.DID, "requestID": request.ID, "decision": cuckoofilter.DecisionApprove}},
+		"reject decision":   {f.issuer, jwt.MapClaims{"iss": f.issuer.DID, "requestID": request.ID, "decision": cuckoofilter.DecisionReject}},
+		"no decision claim": {f.issuer, jwt.MapClaims{"iss": f.issuer.DID, "requestID": request.ID}},
+	} {
+		_, err := f.sim.Submit(f.issuerIdentity, "SmartContract:ApproveRevocationRequest", request.ID, signWithDID(t, test.signer, test.claims))
+		require.Error(t, err, name)
+	}
+	requireStatus(t, f.sim, f.admin, f.fingerprint, cuckoofilter.CredentialStatusActive)
+}
+
+func TestApproveRevocationRequest_AlreadyDecided(t *testing.T) {
+	mockStub, mockTxContext := newRevocationRequestContext()
+	requestJSON, _ := json.Marshal(cuckoofilter.RevocationRequest{ID: "tx1", Status: cuckoofilter.RevocationRequestRejected})
+	mockStub.On("GetState", revocationRequestKey).Return(requestJSON, nil)
+
+	smartContract := new(cuckoofilter.SmartContract)
+	_, err := smartContract.ApproveRevocationRequest(mockTxContext, "tx1", "")
+	require.Error(t, err)
+}
+
+func TestGetRevocationCertificate(t *testing.T) {
+	mockStub, mockTxContext := newRevocationRequestContext()
+	mockStub.On("GetChannelID").Return("mychannel")
+	requestJSON, _ := json.Marshal(cuckoofilter.RevocationRequest{
+		ID:          "tx1",
+		Fingerprint: "0a1b2c3d4e5f6a7b",
+		HolderDID:   "did:key:holder",
+		IssuerDID:   "did:key:issuer",
+		Reason:      "lost device",
+		Status:      cuckoofilter.RevocationRequestApproved,
+		Provenance:  cuckoofilter.ProvenanceHolderRequested,
+		DecidedAt:   time.Unix(1700003600, 0).UTC(),
+		DecisionTx:  "tx2",
+	})
+	mockStub.On("GetState", revocationRequestKey).Return(requestJSON, nil)
+
+	smartContract := new(cuckoofilter.SmartContract)
+	certificate, err := smartContract.GetRevocationCertificate(mockTxContext, "tx1")
+	require.NoError(t, err)
+	require.Equal(t, "0a1b2c3d4e5f6a7b", certificate.CredentialID)
+	require.Equal(t, "tx2", certificate.RevocationTx)
+	require.Equal(t, "mychannel", certificate.Channel)
+	require.Equal(t, time.Unix(1700003600, 0).UTC(), certificate.RevokedAt)
+}
+
+func TestGetRevocationCertificate_NotApproved(t *testing.T) {
+	mockStub, mockTxContext := newRevocationRequestContext()
+	mockStub.On("GetState", revocationRequestKey).Return(pendingRequestJSON("did:key:holder", "did:key:issuer"), nil)
+
+	smartContract := new(cuckoofilter.SmartContract)
+	_, err := smartContract.GetRevocationCertificate(mockTxContext, "tx1")
+	require.Error(t, err)
+}
+
+func TestGetRevocationRequest_NotFound(t *testing.T) {
+	mockStub, mockTxContext := newRevocationRequestContext()
+	mockStub.On("GetState", revocationRequestKey).Return(([]byte)(nil), nil)
+
+	smartContract := new(cuckoofilter.SmartContract)
+	_, err := smartContract.GetRevocationRequest(mockTxContext, "tx1")
+	require.Error(t, err)
+}
+
+func TestRejectRevocationRequest_ApproveDecision(t *testing.T) {
+	useStakeholderDir(t)
+	f := newRevocationRequestFixture(t)
+	request := f.request(t)
+
+	decisionToken := signWithDID(t, f.issuer, jwt.MapClaims{"iss": f.issuer.DID, "requestID": request.ID, "decision": cuckoofilter.DecisionApprove})
+	_, err := f.sim.Submit(f.issuerIdentity, "SmartContract:RejectRevocationRequest", request.ID, decisionToken)
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+}
@@ -0,0 +1,96 @@
+package cuckoofilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"strings"
+	"time"
+)
+
+// MaxRevocationsPageSize bounds the page size of ListRevocations
+const MaxRevocationsPageSize = 1000
+
+// Revocation is a currently revoked registry entry and the issuer that revoked it
+type Revocation struct {
+	CredentialID string    `json:"credentialId"`
+	FilterID     string    `json:"filterId,omitempty" metadata:",optional"`  // Filter of the entry, empty for the default filter
+	Namespace    string    `json:"namespace,omitempty" metadata:",optional"` // Issuer namespace of entries of the sharded registry
+	Issuer       Inserter  `json:"issuer"`
+	RevokedAt    time.Time `json:"revokedAt"`                             // Zero for entries revoked before lifecycle events were recorded
+	Reason       string    `json:"reason,omitempty" metadata:",optional"` // Details of the revocation event
+}
+
+// RevocationsPage is one page of the revoked registry entries
+type RevocationsPage struct {
+	Revocations []Revocation `json:"revocations"`
+	Bookmark    string       `json:"bookmark"` // Passed to the next call, empty after the last page
+}
+
+// ListRevocations returns a page of the revoked registry entries with a recorded inserter, in key order
+func (s *SmartContract) ListRevocations(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*RevocationsPage, error) {
+	if pageSize <= 0 || pageSize > MaxRevocationsPageSize {
+		return nil, fmt.Errorf("page size must be between 1 and %d", MaxRevocationsPageSize)
+	}
+	iterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(inserterObjectType, []string{}, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("error reading revocations: %v", err)
+	}
+	defer iterator.Close()
+
+	page := &RevocationsPage{Revocations: []Revocation{}, Bookmark: metadata.GetBookmark()}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error reading revocations: %v", err)
+		}
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		revocation := Revocation{CredentialID: attributes[1]}
+		if strings.HasPrefix(attributes[0], namespaceScopePrefix) {
+			revocation.Namespace = strings.TrimPrefix(attributes[0], namespaceScopePrefix)
+		} else {
+			revocation.FilterID = strings.TrimPrefix(attributes[0], filterScopePrefix)
+		}
+		if err := json.Unmarshal(entry.Value, &revocation.Issuer); err != nil {
+			return nil, fmt.Errorf("error decoding inserter: %v", err)
+		}
+		event, err := lastLifecycleEvent(ctx, revocation.CredentialID, LifecycleRevoked)
+		if err != nil {
+			return nil, err
+		}
+		if event != nil {
+			revocation.RevokedAt = event.Timestamp
+			revocation.Reason = event.Details
+		}
+		page.Revocations = append(page.Revocations, revocation)
+	}
+	return page, nil
+}
+
+// lastLifecycleEvent returns the latest event of a kind in the timeline of a credential, if any
+func lastLifecycleEvent(ctx contractapi.TransactionContextInterface, credentialID string, kind string) (*LifecycleEvent, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(lifecycleEventObjectType, []string{credentialID})
+	if err != nil {
+		return nil, fmt.Errorf("error reading lifecycle events: %v", err)
+	}
+	defer iterator.Close()
+
+	var last *LifecycleEvent
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error reading lifecycle events: %v", err)
+		}
+		var event LifecycleEvent
+		if err := json.Unmarshal(entry.Value, &event); err != nil {
+			return nil, fmt.Errorf("error decoding lifecycle event: %v", err)
+		}
+		if event.Kind == kind {
+			last = &event
+		}
+	}
+	return last, nil
+}
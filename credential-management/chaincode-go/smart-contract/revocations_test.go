@@ -0,0 +1,45 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestListRevocations(t *testing.T) {
+	sim, _ := newRegistrySimulator(t)
+	issuer1 := newIssuerIdentity(t, "Org1MSP", "did:key:issuer1")
+	issuer2 := newIssuerIdentity(t, "Org2MSP", "did:key:issuer2")
+	_, err := sim.Submit(issuer1, "BatchInsert", "", `["credential-1","credential-2"]`)
+	require.NoError(t, err)
+	_, err = sim.Submit(issuer2, "Insert", "", "credential-3")
+	require.NoError(t, err)
+	_, err = sim.Submit(issuer1, "Delete", "", "credential-2")
+	require.NoError(t, err)
+
+	var revocations []cuckoofilter.Revocation
+	bookmark := ""
+	for {
+		pageJSON, err := sim.Evaluate(issuer1, "ListRevocations", "1", bookmark)
+		require.NoError(t, err)
+		var page cuckoofilter.RevocationsPage
+		require.NoError(t, json.Unmarshal(pageJSON, &page))
+		revocations = append(revocations, page.Revocations...)
+		if bookmark = page.Bookmark; bookmark == "" {
+			break
+		}
+	}
+
+	// Unrevoked entries are not listed
+	require.Len(t, revocations, 2)
+	require.Equal(t, "credential-1", revocations[0].CredentialID)
+	require.Equal(t, "did:key:issuer1", revocations[0].Issuer.DID)
+	require.Equal(t, "credential-3", revocations[1].CredentialID)
+	require.Equal(t, "did:key:issuer2", revocations[1].Issuer.DID)
+	require.Equal(t, cuckoofilter.DefaultFilterID, revocations[1].FilterID)
+	require.False(t, revocations[1].RevokedAt.IsZero())
+
+	_, err = sim.Evaluate(issuer1, "ListRevocations", "0", "")
+	require.Error(t, err)
+}
@@ -0,0 +1,88 @@
+package cuckoofilter
+
+import (
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ErrNotCredentialIssuer is returned by RevokeCredential when the submitting client did not issue the credential
+var ErrNotCredentialIssuer = fmt.Errorf("%w: only the issuer of a credential may revoke it", ErrUnauthorized)
+
+// RevokeCredential revokes a JWT credential by inserting its canonical fingerprint, derived with the
+// registry's normalizer as in GetTokenCredentialStatus, into the default filter. The submitting
+// client's DID must be the credential issuer. The token signature is not checked, the issuer is
+// trusted to revoke its own credentials.
+func (s *SmartContract) RevokeCredential(ctx contractapi.TransactionContextInterface, credentialJWT string, reason string) (*CredentialStatus, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	if err := checkCapability(ctx, CapabilityActionRevoke, DefaultFilterID); err != nil {
+		return nil, err
+	}
+	status, err := s.credentialStatusOfIssuer(ctx, credentialJWT)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := s.loadFilter(ctx, DefaultFilterID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading filter state: %v", err)
+	}
+	filter, inserted := insertGrowing(filter, []byte(status.Fingerprint))
+	if !inserted {
+		return nil, insertFailure(filter, []byte(status.Fingerprint), "fingerprint "+status.Fingerprint)
+	}
+	if err := recordInserter(ctx, filterScope(DefaultFilterID), status.Fingerprint); err != nil {
+		return nil, err
+	}
+	if err := recordLifecycleEvent(ctx, LifecycleRevoked, filterDetails(DefaultFilterID), status.Fingerprint); err != nil {
+		return nil, err
+	}
+	if err := recordRevocationAudit(ctx, LifecycleRevoked, reason, status.Fingerprint); err != nil {
+		return nil, err
+	}
+	if err := s.saveFilter(ctx, DefaultFilterID, filter); err != nil {
+		return nil, err
+	}
+	if err := emitFilterChanged(ctx, DefaultFilterID, FilterChangeInserted, filter, []string{status.Fingerprint}); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// IsCredentialRevoked checks whether the canonical fingerprint of a JWT credential is in the default filter
+func (s *SmartContract) IsCredentialRevoked(ctx contractapi.TransactionContextInterface, credentialJWT string) (bool, error) {
+	status, err := s.GetTokenCredentialStatus(ctx, credentialJWT)
+	if err != nil {
+		return false, err
+	}
+	return s.Lookup(ctx, DefaultFilterID, status.Fingerprint)
+}
+
+// credentialStatusOfIssuer returns the credentialStatus entry of a JWT credential issued by the submitting client
+func (s *SmartContract) credentialStatusOfIssuer(ctx contractapi.TransactionContextInterface, credentialJWT string) (*CredentialStatus, error) {
+	claims, err := parseUnverifiedClaims(credentialJWT)
+	if err != nil {
+		return nil, err
+	}
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	normalizer, err := LookupNormalizer(config.Normalizer)
+	if err != nil {
+		return nil, err
+	}
+	issuer, _, err := normalizer.Identity(claims)
+	if err != nil {
+		return nil, fmt.Errorf("error normalizing credential with %s: %v", normalizer.Name(), err)
+	}
+	client, _, err := clientInserter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if client.DID != normalizeFingerprintInput(issuer) {
+		return nil, ErrNotCredentialIssuer
+	}
+	return NewTokenCredentialStatus(normalizer, credentialJWT)
+}
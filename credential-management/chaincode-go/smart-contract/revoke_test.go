@@ -0,0 +1,52 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRevokeCredential(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	issuer := newIssuerIdentity(t, "Org1MSP", "did:key:issuer")
+	other := newIssuerIdentity(t, "Org2MSP", "did:key:other")
+	token := signToken(t, credentialClaims(1700000000, "proof-1"), nil)
+	// Re-signing the credential does not change its identity
+	resigned := signToken(t, credentialClaims(1700000100, "proof-1"), nil)
+
+	revoked, err := sim.Evaluate(admin, "IsCredentialRevoked", token)
+	require.NoError(t, err)
+	require.Equal(t, "false", string(revoked))
+
+	_, err = sim.Submit(other, "RevokeCredential", token, "key compromise")
+	require.ErrorContains(t, err, cuckoofilter.ErrNotCredentialIssuer.Error())
+	tx, err := sim.Submit(issuer, "RevokeCredential", token, "key compromise")
+	require.NoError(t, err)
+	var status cuckoofilter.CredentialStatus
+	require.NoError(t, json.Unmarshal(tx.Payload, &status))
+	expected, err := cuckoofilter.ComputeFingerprint(cuckoofilter.FingerprintV1, "did:key:issuer", "http://example.edu/credentials/3732")
+	require.NoError(t, err)
+	require.Equal(t, expected, status.Fingerprint)
+
+	revoked, err = sim.Evaluate(admin, "IsCredentialRevoked", resigned)
+	require.NoError(t, err)
+	require.Equal(t, "true", string(revoked))
+	requireStatus(t, sim, admin, expected, cuckoofilter.CredentialStatusRevoked)
+
+	historyJSON, err := sim.Evaluate(admin, "GetRevocationHistory", expected)
+	require.NoError(t, err)
+	var history []*cuckoofilter.RevocationAuditEntry
+	require.NoError(t, json.Unmarshal(historyJSON, &history))
+	require.Len(t, history, 1)
+	require.Equal(t, "key compromise", history[0].Reason)
+	require.Equal(t, "Org1MSP", history[0].MSPID)
+}
+
+func TestRevokeCredential_InvalidToken(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	_, err := sim.Submit(admin, "RevokeCredential", "not-a-jwt", "")
+	require.Error(t, err)
+	_, err = sim.Evaluate(admin, "IsCredentialRevoked", "not-a-jwt")
+	require.Error(t, err)
+}
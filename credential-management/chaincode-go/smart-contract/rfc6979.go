@@ -0,0 +1,152 @@
+package cuckoofilter
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"github.com/dgrijalva/jwt-go"
+	"math/big"
+)
+
+// Deterministic ECDSA, RFC 6979. The nonce is derived from the private key and the message hash with
+// HMAC-SHA256 instead of being drawn at random, so signing the same hash with the same key always gives
+// the same signature. Endorsing peers that sign a credential therefore produce matching write sets, and
+// signatures can be checked against fixed values. The signatures are plain ECDSA signatures; verifiers
+// do not need to know how the nonce was chosen.
+
+// SignDeterministic signs hash with privateKey using an RFC 6979 nonce. It supports any curve with
+// Params, such as elliptic.P256 and Secp256k1.
+func SignDeterministic(privateKey *ecdsa.PrivateKey, hash []byte) (*big.Int, *big.Int, error) {
+	curve := privateKey.Curve
+	n := curve.Params().N
+	d := privateKey.D
+	if d == nil || d.Sign() <= 0 || d.Cmp(n) >= 0 {
+		return nil, nil, errors.New("invalid private key")
+	}
+	e := bits2int(hash, n.BitLen())
+	nonces := newRFC6979Nonces(n, d, hash)
+	for {
+		k := nonces.next()
+		x, _ := curve.ScalarBaseMult(k.Bytes())
+		r := new(big.Int).Mod(x, n)
+		if r.Sign() == 0 {
+			continue
+		}
+		// s = k⁻¹(e + r·d) mod n
+		s := new(big.Int).Mul(r, d)
+		s.Add(s, e)
+		s.Mul(s, new(big.Int).ModInverse(k, n))
+		s.Mod(s, n)
+		if s.Sign() == 0 {
+			continue
+		}
+		return r, s, nil
+	}
+}
+
+// rfc6979Nonces generates the candidate nonces of RFC 6979 section 3.2 with HMAC-SHA256
+type rfc6979Nonces struct {
+	n    *big.Int
+	k, v []byte
+	used bool
+}
+
+func newRFC6979Nonces(n *big.Int, d *big.Int, hash []byte) *rfc6979Nonces {
+	rlen := (n.BitLen() + 7) / 8
+	x := int2octets(d, rlen)
+	h := bits2octets(hash, n, rlen)
+
+	g := &rfc6979Nonces{n: n, k: make([]byte, sha256.Size), v: make([]byte, sha256.Size)}
+	for i := range g.v {
+		g.v[i] = 0x01
+	}
+	g.k = g.mac(g.k, g.v, []byte{0x00}, x, h)
+	g.v = g.mac(g.k, g.v)
+	g.k = g.mac(g.k, g.v, []byte{0x01}, x, h)
+	g.v = g.mac(g.k, g.v)
+	return g
+}
+
+// next returns the next nonce in [1, n-1]; every call after the first is a retry of step h.3
+func (g *rfc6979Nonces) next() *big.Int {
+	qlen := g.n.BitLen()
+	for {
+		if g.used {
+			g.k = g.mac(g.k, g.v, []byte{0x00})
+			g.v = g.mac(g.k, g.v)
+		}
+		g.used = true
+		var t []byte
+		for len(t)*8 < qlen {
+			g.v = g.mac(g.k, g.v)
+			t = append(t, g.v...)
+		}
+		k := bits2int(t, qlen)
+		if k.Sign() > 0 && k.Cmp(g.n) < 0 {
+			return k
+		}
+	}
+}
+
+func (g *rfc6979Nonces) mac(key []byte, data ...[]byte) []byte {
+	m := hmac.New(sha256.New, key)
+	for _, d := range data {
+		m.Write(d)
+	}
+	return m.Sum(nil)
+}
+
+// bits2int takes the leftmost qlen bits of b as a number
+func bits2int(b []byte, qlen int) *big.Int {
+	v := new(big.Int).SetBytes(b)
+	if blen := len(b) * 8; blen > qlen {
+		v.Rsh(v, uint(blen-qlen))
+	}
+	return v
+}
+
+// int2octets encodes v big-endian in rlen bytes
+func int2octets(v *big.Int, rlen int) []byte {
+	out := make([]byte, rlen)
+	return v.FillBytes(out)
+}
+
+// bits2octets reduces a hash modulo n and encodes it in rlen bytes
+func bits2octets(hash []byte, n *big.Int, rlen int) []byte {
+	z := bits2int(hash, n.BitLen())
+	if z.Cmp(n) >= 0 {
+		z.Sub(z, n)
+	}
+	return int2octets(z, rlen)
+}
+
+// SigningMethodES256Deterministic signs ES256 JWTs with RFC 6979 nonces. Tokens carry the usual ES256
+// alg header and verify with jwt.SigningMethodES256.
+var SigningMethodES256Deterministic jwt.SigningMethod = &deterministicSigningMethod{SigningMethodECDSA: jwt.SigningMethodES256}
+
+type deterministicSigningMethod struct {
+	*jwt.SigningMethodECDSA
+}
+
+// Sign returns the base64url encoded r || s signature of signingString
+func (m *deterministicSigningMethod) Sign(signingString string, key interface{}) (string, error) {
+	privateKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+	if privateKey.Curve.Params().BitSize != m.CurveBits {
+		return "", jwt.ErrInvalidKey
+	}
+	hasher := m.Hash.New()
+	hasher.Write([]byte(signingString))
+	r, s, err := SignDeterministic(privateKey, hasher.Sum(nil))
+	if err != nil {
+		return "", err
+	}
+	keyBytes := (m.CurveBits + 7) / 8
+	signature := make([]byte, 2*keyBytes)
+	r.FillBytes(signature[:keyBytes])
+	s.FillBytes(signature[keyBytes:])
+	return jwt.EncodeSegment(signature), nil
+}
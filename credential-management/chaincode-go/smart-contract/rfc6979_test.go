@@ -0,0 +1,132 @@
+package cuckoofilter_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/dgrijalva/jwt-go"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func hexInt(t *testing.T, s string) *big.Int {
+	v, ok := new(big.Int).SetString(s, 16)
+	require.True(t, ok, s)
+	return v
+}
+
+func privateKeyFromD(curve elliptic.Curve, d *big.Int) *ecdsa.PrivateKey {
+	key := &ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: curve}, D: d}
+	key.PublicKey.X, key.PublicKey.Y = curve.ScalarBaseMult(d.Bytes())
+	return key
+}
+
+// RFC 6979 appendix A.2.5, ECDSA with P-256 and SHA-256
+func TestSignDeterministic_RFC6979P256Vectors(t *testing.T) {
+	key := privateKeyFromD(elliptic.P256(), hexInt(t, "C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721"))
+	require.Equal(t, hexInt(t, "60FED4BA255A9D31C961EB74C6356D68C049B8923B61FA6CE669622E60F29FB6"), key.X)
+	require.Equal(t, hexInt(t, "7903FE1008B8BC99A41AE9E95628BC64F2F1B20C2D7E9F5177A3C294D4462299"), key.Y)
+
+	vectors := []struct{ message, r, s string }{
+		{"sample", "EFD48B2AACB6A8FD1140DD9CD45E81D69D2C877B56AAF991C34D0EA84EAF3716", "F7CB1C942D657C41D436C7A1B6E29F65F3E900DBB9AFF4064DC4AB2F843ACDA8"},
+		{"test", "F1ABB023518351CD71D881567B1EA663ED3EFCF6C5132B354F28D3B0B7D38367", "019F4113742A2B14BD25926B49C649155F267E60D3814B4C0CC84250E46F0083"},
+	}
+	for _, v := range vectors {
+		hash := sha256.Sum256([]byte(v.message))
+		r, s, err := cuckoofilter.SignDeterministic(key, hash[:])
+		require.NoError(t, err)
+		require.Equal(t, hexInt(t, v.r), r, v.message)
+		require.Equal(t, hexInt(t, v.s), s, v.message)
+		require.True(t, ecdsa.Verify(&key.PublicKey, hash[:], r, s))
+	}
+}
+
+// Reference vector of the bitcoin libraries, which normalize s to the lower half of the order
+func TestSignDeterministic_Secp256k1Vector(t *testing.T) {
+	curve := cuckoofilter.Secp256k1()
+	key := privateKeyFromD(curve, big.NewInt(1))
+	require.True(t, curve.IsOnCurve(key.X, key.Y))
+
+	x, y := curve.ScalarBaseMult([]byte{2})
+	require.Equal(t, hexInt(t, "C6047F9441ED7D6D3045406E95C07CD85C778E4B8CEF3CA7ABAC09B95C709EE5"), x)
+	require.True(t, curve.IsOnCurve(x, y))
+
+	hash := sha256.Sum256([]byte("Satoshi Nakamoto"))
+	r, s, err := cuckoofilter.SignDeterministic(key, hash[:])
+	require.NoError(t, err)
+	require.Equal(t, hexInt(t, "934B1EA10A4B3C1757E2B0C017D0B6143CE3C9A7E6A4A49860D7A6AB210EE3D8"), r)
+	if half := new(big.Int).Rsh(curve.Params().N, 1); s.Cmp(half) > 0 {
+		s.Sub(curve.Params().N, s)
+	}
+	require.Equal(t, hexInt(t, "2442CE9D2B916064108014783E923EC36B49743E2FFA1C4496F01A512AAFD9E5"), s)
+	require.True(t, ecdsa.Verify(&key.PublicKey, hash[:], r, s))
+}
+
+func TestSignDeterministic_InvalidKey(t *testing.T) {
+	hash := sha256.Sum256([]byte("sample"))
+	_, _, err := cuckoofilter.SignDeterministic(&ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: elliptic.P256()}, D: big.NewInt(0)}, hash[:])
+	require.Error(t, err)
+}
+
+func TestSignCredential_Reproducible(t *testing.T) {
+	key := privateKeyFromD(elliptic.P256(), big.NewInt(42))
+	issuedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	first, err := cuckoofilter.CreateAndSignCredentialAt(issuedAt, "did:key:issuer", key, "did:key:holder", "-1")
+	require.NoError(t, err)
+	second, err := cuckoofilter.CreateAndSignCredentialAt(issuedAt, "did:key:issuer", key, "did:key:holder", "-1")
+	require.NoError(t, err)
+	require.Equal(t, first.Proof.JWS, second.Proof.JWS)
+
+	// The proof is a detached ES256 JWS over the canonical credential without its proof
+	parts := strings.Split(first.Proof.JWS, ".")
+	require.Len(t, parts, 3)
+	require.Empty(t, parts[1])
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	require.NoError(t, err)
+	require.JSONEq(t, `{"alg":"ES256","b64":false,"crit":["b64"]}`, string(header))
+	credentialJSON, err := json.Marshal(first)
+	require.NoError(t, err)
+	var document map[string]interface{}
+	require.NoError(t, json.Unmarshal(credentialJSON, &document))
+	delete(document, "proof")
+	payload := new(bytes.Buffer)
+	encoder := json.NewEncoder(payload)
+	encoder.SetEscapeHTML(false)
+	require.NoError(t, encoder.Encode(document))
+	hash := sha256.Sum256([]byte(parts[0] + "." + strings.TrimSuffix(payload.String(), "\n")))
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+	r, s, err := cuckoofilter.SignDeterministic(key, hash[:])
+	require.NoError(t, err)
+	require.Len(t, signature, 64)
+	require.Equal(t, r, new(big.Int).SetBytes(signature[:32]))
+	require.Equal(t, s, new(big.Int).SetBytes(signature[32:]))
+	require.NoError(t, cuckoofilter.VerifyProof(first, &key.PublicKey))
+}
+
+func TestSigningMethodES256Deterministic(t *testing.T) {
+	key := privateKeyFromD(elliptic.P256(), big.NewInt(42))
+	claims := jwt.MapClaims{"iss": "did:key:issuer", "jti": "urn:uuid:1"}
+	first, err := jwt.NewWithClaims(cuckoofilter.SigningMethodES256Deterministic, claims).SignedString(key)
+	require.NoError(t, err)
+	second, err := jwt.NewWithClaims(cuckoofilter.SigningMethodES256Deterministic, claims).SignedString(key)
+	require.NoError(t, err)
+	require.Equal(t, first, second)
+
+	token, err := jwt.Parse(first, func(token *jwt.Token) (interface{}, error) {
+		require.Equal(t, jwt.SigningMethodES256, token.Method)
+		return &key.PublicKey, nil
+	})
+	require.NoError(t, err)
+	require.True(t, token.Valid)
+
+	_, err = jwt.NewWithClaims(cuckoofilter.SigningMethodES256Deterministic, claims).SignedString(privateKeyFromD(elliptic.P384(), big.NewInt(42)))
+	require.ErrorIs(t, err, jwt.ErrInvalidKey)
+}
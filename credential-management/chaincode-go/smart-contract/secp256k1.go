@@ -0,0 +1,121 @@
+package cuckoofilter
+
+import (
+	"crypto/elliptic"
+	"math/big"
+	"sync"
+)
+
+// secp256k1 is the curve y² = x³ + 7 of SEC 2. The standard library only implements curves with a = -3,
+// so the arithmetic is done here in affine coordinates. It is not constant time; it is meant for signing
+// and verifying credentials, not for keys that share a machine with untrusted code.
+type secp256k1 struct {
+	params *elliptic.CurveParams
+}
+
+var (
+	secp256k1Once  sync.Once
+	secp256k1Curve *secp256k1
+)
+
+// Secp256k1 returns the secp256k1 curve
+func Secp256k1() elliptic.Curve {
+	secp256k1Once.Do(func() {
+		params := &elliptic.CurveParams{Name: "secp256k1", BitSize: 256}
+		params.P, _ = new(big.Int).SetString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC2F", 16)
+		params.N, _ = new(big.Int).SetString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141", 16)
+		params.B = big.NewInt(7)
+		params.Gx, _ = new(big.Int).SetString("79BE667EF9DCBBAC55A06295CE870B07029BFCDB2DCE28D959F2815B16F81798", 16)
+		params.Gy, _ = new(big.Int).SetString("483ADA7726A3C4655DA4FBFC0E1108A8FD17B448A68554199C47D08FFB10D4B8", 16)
+		secp256k1Curve = &secp256k1{params: params}
+	})
+	return secp256k1Curve
+}
+
+func (c *secp256k1) Params() *elliptic.CurveParams {
+	return c.params
+}
+
+func (c *secp256k1) IsOnCurve(x, y *big.Int) bool {
+	p := c.params.P
+	if x.Sign() < 0 || x.Cmp(p) >= 0 || y.Sign() < 0 || y.Cmp(p) >= 0 {
+		return false
+	}
+	y2 := new(big.Int).Mul(y, y)
+	y2.Mod(y2, p)
+	x3 := new(big.Int).Mul(x, x)
+	x3.Mul(x3, x)
+	x3.Add(x3, c.params.B)
+	x3.Mod(x3, p)
+	return y2.Cmp(x3) == 0
+}
+
+// Add returns the sum of two points, with (0, 0) as the point at infinity
+func (c *secp256k1) Add(x1, y1, x2, y2 *big.Int) (*big.Int, *big.Int) {
+	if x1.Sign() == 0 && y1.Sign() == 0 {
+		return new(big.Int).Set(x2), new(big.Int).Set(y2)
+	}
+	if x2.Sign() == 0 && y2.Sign() == 0 {
+		return new(big.Int).Set(x1), new(big.Int).Set(y1)
+	}
+	p := c.params.P
+	if x1.Cmp(x2) == 0 {
+		if y1.Cmp(y2) == 0 {
+			return c.Double(x1, y1)
+		}
+		return new(big.Int), new(big.Int)
+	}
+	// λ = (y2 - y1) / (x2 - x1)
+	lambda := new(big.Int).Sub(x2, x1)
+	lambda.ModInverse(lambda.Mod(lambda, p), p)
+	lambda.Mul(lambda, new(big.Int).Sub(y2, y1))
+	lambda.Mod(lambda, p)
+	return c.finish(lambda, x1, y1, x2)
+}
+
+// Double returns 2·(x, y)
+func (c *secp256k1) Double(x, y *big.Int) (*big.Int, *big.Int) {
+	if y.Sign() == 0 {
+		return new(big.Int), new(big.Int)
+	}
+	p := c.params.P
+	// λ = 3x² / 2y
+	lambda := new(big.Int).Lsh(y, 1)
+	lambda.ModInverse(lambda.Mod(lambda, p), p)
+	lambda.Mul(lambda, new(big.Int).Mul(big.NewInt(3), new(big.Int).Mul(x, x)))
+	lambda.Mod(lambda, p)
+	return c.finish(lambda, x, y, x)
+}
+
+// finish computes x3 = λ² - x1 - x2 and y3 = λ(x1 - x3) - y1
+func (c *secp256k1) finish(lambda, x1, y1, x2 *big.Int) (*big.Int, *big.Int) {
+	p := c.params.P
+	x3 := new(big.Int).Mul(lambda, lambda)
+	x3.Sub(x3, x1)
+	x3.Sub(x3, x2)
+	x3.Mod(x3, p)
+	y3 := new(big.Int).Sub(x1, x3)
+	y3.Mul(y3, lambda)
+	y3.Sub(y3, y1)
+	y3.Mod(y3, p)
+	return x3, y3
+}
+
+// ScalarMult returns k·(x, y) for a big-endian k
+func (c *secp256k1) ScalarMult(x, y *big.Int, k []byte) (*big.Int, *big.Int) {
+	rx, ry := new(big.Int), new(big.Int)
+	for _, b := range k {
+		for bit := 7; bit >= 0; bit-- {
+			rx, ry = c.Double(rx, ry)
+			if b>>uint(bit)&1 == 1 {
+				rx, ry = c.Add(rx, ry, x, y)
+			}
+		}
+	}
+	return rx, ry
+}
+
+// ScalarBaseMult returns k·G for a big-endian k
+func (c *secp256k1) ScalarBaseMult(k []byte) (*big.Int, *big.Int) {
+	return c.ScalarMult(c.params.Gx, c.params.Gy, k)
+}
@@ -0,0 +1,41 @@
+package cuckoofilter
+
+import (
+	"github.com/pherbke/credential-management/chaincode-go/core"
+)
+
+// Semi-sorted buckets are packed by core.PackBuckets, which describes the encoding
+
+// SemiSortedBucketSize is the bucket size filters with semi-sorted buckets must have
+const SemiSortedBucketSize = core.SemiSortedBucketSize
+
+// packBuckets encodes buckets of at most SemiSortedBucketSize fingerprints of fingerprintSize bytes
+func packBuckets(buckets []*bucket, fingerprintSize uint) ([]byte, error) {
+	slots := make([][][]byte, len(buckets))
+	for i, b := range buckets {
+		if b == nil {
+			continue
+		}
+		slots[i] = make([][]byte, len(b.Data))
+		for j, fp := range b.Data {
+			slots[i][j] = fp
+		}
+	}
+	return core.PackBuckets(slots, fingerprintSize)
+}
+
+// unpackBuckets decodes numBuckets buckets written by packBuckets
+func unpackBuckets(packed []byte, numBuckets uint64, fingerprintSize uint) ([]*bucket, error) {
+	slots, err := core.UnpackBuckets(packed, numBuckets, fingerprintSize)
+	if err != nil {
+		return nil, err
+	}
+	buckets := make([]*bucket, len(slots))
+	for i, b := range slots {
+		buckets[i] = NewBucket(SemiSortedBucketSize)
+		for j, fp := range b {
+			buckets[i].Data[j] = fp
+		}
+	}
+	return buckets, nil
+}
@@ -0,0 +1,88 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"fmt"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSemiSortedBuckets_RoundTrip(t *testing.T) {
+	for _, fingerprintSize := range []uint{1, 2, 8} {
+		t.Run(fmt.Sprintf("%d bytes", fingerprintSize), func(t *testing.T) {
+			filter, err := cuckoofilter.NewFilterWithOptions(
+				cuckoofilter.WithNumElements(64),
+				cuckoofilter.WithFingerprintSize(fingerprintSize),
+				cuckoofilter.WithSemiSortedBuckets(),
+			)
+			require.NoError(t, err)
+			for i := 0; i < 100; i++ {
+				filter.Insert([]byte(fmt.Sprintf("credential-%d", i)))
+			}
+			// Kicking can drop a fingerprint, compare against what the filter holds
+			var inserted [][]byte
+			for i := 0; i < 100; i++ {
+				if data := []byte(fmt.Sprintf("credential-%d", i)); filter.Lookup(data) {
+					inserted = append(inserted, data)
+				}
+			}
+
+			filterJSON, err := json.Marshal(filter)
+			require.NoError(t, err)
+			var loaded cuckoofilter.Filter
+			require.NoError(t, json.Unmarshal(filterJSON, &loaded))
+			require.True(t, loaded.SemiSorted)
+			require.Equal(t, filter.Count, loaded.Count)
+			for _, data := range inserted {
+				require.True(t, loaded.Lookup(data), "%s", data)
+			}
+
+			// Loaded buckets keep their slots for further inserts
+			require.True(t, loaded.Insert([]byte("credential-new")))
+			require.True(t, loaded.Delete([]byte("credential-new")))
+		})
+	}
+}
+
+func TestSemiSortedBuckets_Size(t *testing.T) {
+	plain := cuckoofilter.NewFilter(256, cuckoofilter.DefaultBucketSize, 1)
+	packed, err := cuckoofilter.NewFilterWithOptions(
+		cuckoofilter.WithNumElements(256),
+		cuckoofilter.WithFingerprintSize(1),
+		cuckoofilter.WithSemiSortedBuckets(),
+	)
+	require.NoError(t, err)
+	for i := 0; i < 900; i++ {
+		data := []byte(fmt.Sprintf("credential-%d", i))
+		plain.Insert(data)
+		packed.Insert(data)
+	}
+
+	plainJSON, err := json.Marshal(plain)
+	require.NoError(t, err)
+	packedJSON, err := json.Marshal(packed)
+	require.NoError(t, err)
+	require.Less(t, len(packedJSON), len(plainJSON)/2)
+}
+
+func TestSemiSortedBuckets_RequiresBucketSize(t *testing.T) {
+	_, err := cuckoofilter.NewFilterWithOptions(cuckoofilter.WithBucketSize(2), cuckoofilter.WithSemiSortedBuckets())
+	require.Error(t, err)
+}
+
+func TestSemiSortedBuckets_Truncated(t *testing.T) {
+	filter, err := cuckoofilter.NewFilterWithOptions(cuckoofilter.WithNumElements(4), cuckoofilter.WithSemiSortedBuckets())
+	require.NoError(t, err)
+	require.True(t, filter.Insert([]byte("credential-1")))
+	filterJSON, err := json.Marshal(filter)
+	require.NoError(t, err)
+
+	var state map[string]interface{}
+	require.NoError(t, json.Unmarshal(filterJSON, &state))
+	state["BucketIndexMask"] = 7
+	filterJSON, err = json.Marshal(state)
+	require.NoError(t, err)
+	var loaded cuckoofilter.Filter
+	require.ErrorIs(t, json.Unmarshal(filterJSON, &loaded), cuckoofilter.ErrCorruptFilter)
+}
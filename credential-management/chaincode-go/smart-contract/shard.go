@@ -0,0 +1,552 @@
+package cuckoofilter
+
+import (
+	"encoding/json"
+	"fmt"
+	metro "github.com/dgryski/go-metro"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"sort"
+	"strconv"
+)
+
+// shardConfigKey is the ledger key holding the shard configuration
+const shardConfigKey = "ShardConfig"
+
+// Composite key prefixes of the sharded registry
+const (
+	filterShardObjectType         = "filterShard"         // filterShard~<shard> holds the filter of a shard
+	namespaceAssignmentObjectType = "namespaceAssignment" // namespaceAssignment~<namespace> holds the shard of a namespace
+	namespaceEntryObjectType      = "namespaceEntry"      // namespaceEntry~<namespace>~<data> holds the shard the entry is stored in
+)
+
+// NamespaceMigrationProgressEvent is the name of the chaincode event emitted by every rebalance step.
+// Its payload is the NamespaceAssignment after the step.
+const NamespaceMigrationProgressEvent = "NamespaceMigrationProgress"
+
+// ShardConfig describes the filter shards issuer namespaces are spread over
+type ShardConfig struct {
+	Shards       uint `json:"shards"`
+	VirtualNodes uint `json:"virtualNodes"` // Points per shard on the hash ring
+	NumElements  uint `json:"numElements"`  // Capacity of each shard filter
+	BucketSize   uint `json:"bucketSize"`
+}
+
+// NamespaceAssignment records the shard holding an issuer namespace and the progress of its migration
+type NamespaceAssignment struct {
+	Namespace   string `json:"namespace"`
+	Shard       uint   `json:"shard"`
+	TargetShard uint   `json:"targetShard"`
+	Migrating   bool   `json:"migrating"`
+	Moved       uint   `json:"moved"`     // Entries moved to the target shard so far
+	Remaining   uint   `json:"remaining"` // Entries still in the source shard
+}
+
+// ShardForNamespace places a namespace on the consistent hash ring of the given shards.
+// Adding a shard only moves the namespaces that land on the new shard's points.
+func ShardForNamespace(namespace string, shards uint, virtualNodes uint) uint {
+	if virtualNodes == 0 {
+		virtualNodes = 1
+	}
+	type point struct {
+		hash  uint64
+		shard uint
+	}
+	ring := make([]point, 0, shards*virtualNodes)
+	for shard := uint(0); shard < shards; shard++ {
+		for node := uint(0); node < virtualNodes; node++ {
+			ring = append(ring, point{hash: metro.Hash64([]byte(fmt.Sprintf("shard-%d-%d", shard, node)), 1337), shard: shard})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool {
+		return ring[i].hash < ring[j].hash
+	})
+
+	hash := metro.Hash64([]byte(namespace), 1337)
+	i := sort.Search(len(ring), func(i int) bool {
+		return ring[i].hash >= hash
+	})
+	if i == len(ring) {
+		i = 0
+	}
+	return ring[i].shard
+}
+
+// InitShards creates the shard filters and stores the shard configuration. Shards cannot be created while
+// keyed fingerprints are enabled, see SetKeyedFingerprints. Registry admins only.
+func (s *SmartContract) InitShards(ctx contractapi.TransactionContextInterface, shards uint, virtualNodes uint, numElements uint, bucketSize uint) error {
+	if err := checkWritable(ctx); err != nil {
+		return err
+	}
+	if err := checkRegistryAdmin(ctx, "initialize shards"); err != nil {
+		return err
+	}
+	if err := checkUnkeyed(ctx, "shards"); err != nil {
+		return err
+	}
+	if shards == 0 || virtualNodes == 0 {
+		return fmt.Errorf("shards and virtual nodes must be positive")
+	}
+	existing, err := ctx.GetStub().GetState(shardConfigKey)
+	if err != nil {
+		return fmt.Errorf("error loading shard config: %v", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("shards are already initialized")
+	}
+
+	config := &ShardConfig{Shards: shards, VirtualNodes: virtualNodes, NumElements: numElements, BucketSize: bucketSize}
+	for shard := uint(0); shard < shards; shard++ {
+		if err := saveShardFilter(ctx, shard, NewFilter(numElements, bucketSize, FingerPrintSize)); err != nil {
+			return err
+		}
+	}
+	return saveShardConfig(ctx, config)
+}
+
+// AddShard adds an empty shard. Namespaces that move to it on the hash ring are migrated with RebalanceNamespace.
+// Registry admins only.
+func (s *SmartContract) AddShard(ctx contractapi.TransactionContextInterface) (*ShardConfig, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	if err := checkRegistryAdmin(ctx, "add shards"); err != nil {
+		return nil, err
+	}
+	config, err := loadShardConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := saveShardFilter(ctx, config.Shards, NewFilter(config.NumElements, config.BucketSize, FingerPrintSize)); err != nil {
+		return nil, err
+	}
+	config.Shards++
+	if err := saveShardConfig(ctx, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// GetShardConfig returns the shard configuration
+func (s *SmartContract) GetShardConfig(ctx contractapi.TransactionContextInterface) (*ShardConfig, error) {
+	return loadShardConfig(ctx)
+}
+
+// GetNamespaceAssignment returns the shard of a namespace, placing new namespaces on the hash ring
+func (s *SmartContract) GetNamespaceAssignment(ctx contractapi.TransactionContextInterface, namespace string) (*NamespaceAssignment, error) {
+	config, err := loadShardConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return loadNamespaceAssignment(ctx, config, namespace)
+}
+
+// InsertInNamespace adds data to the shard of an issuer namespace - Revoke a credential of the namespace.
+// Data already in the namespace fails with ErrAlreadyRevoked.
+func (s *SmartContract) InsertInNamespace(ctx contractapi.TransactionContextInterface, namespace string, data string) error {
+	if err := checkWritable(ctx); err != nil {
+		return err
+	}
+	if err := checkCapability(ctx, CapabilityActionRevoke, namespace); err != nil {
+		return err
+	}
+	if err := checkStrictMode(ctx, data); err != nil {
+		return err
+	}
+	config, err := loadShardConfig(ctx)
+	if err != nil {
+		return err
+	}
+	assignment, err := loadNamespaceAssignment(ctx, config, namespace)
+	if err != nil {
+		return err
+	}
+	// An entry records one fingerprint, so a second insert would leave a fingerprint rebalancing never moves
+	if exists, err := namespaceEntryExists(ctx, namespace, data); err != nil {
+		return err
+	} else if exists {
+		return fmt.Errorf("%w: data '%s' is already in namespace %s", ErrAlreadyRevoked, data, namespace)
+	}
+
+	// During a migration new entries go straight to the target shard
+	shard := assignment.Shard
+	if assignment.Migrating {
+		shard = assignment.TargetShard
+	}
+	filter, err := loadShardFilter(ctx, shard)
+	if err != nil {
+		return err
+	}
+	if !filter.Insert(namespaceValue(namespace, data)) {
+		return fmt.Errorf("%w: failed to insert data '%s' into shard %d", ErrFilterFull, data, shard)
+	}
+	if err := saveShardFilter(ctx, shard, filter); err != nil {
+		return err
+	}
+	if err := saveNamespaceEntry(ctx, namespace, data, shard); err != nil {
+		return err
+	}
+	if err := recordInserter(ctx, namespaceScope(namespace), data); err != nil {
+		return err
+	}
+	if err := recordLifecycleEvent(ctx, LifecycleRevoked, namespaceDetails(namespace), data); err != nil {
+		return err
+	}
+	if err := recordTransientRevocationAudit(ctx, LifecycleRevoked, data); err != nil {
+		return err
+	}
+	if err := saveNamespaceAssignment(ctx, assignment); err != nil {
+		return err
+	}
+	return emitNamespaceChanged(ctx, namespace, FilterChangeInserted, filter, []string{data})
+}
+
+// DeleteFromNamespace removes data from the shard of an issuer namespace - Unrevoke a credential of the
+// namespace. Like Delete, only the client that inserted it or a registry admin may remove it.
+func (s *SmartContract) DeleteFromNamespace(ctx contractapi.TransactionContextInterface, namespace string, data string) error {
+	if err := checkWritable(ctx); err != nil {
+		return err
+	}
+	if err := checkCapability(ctx, CapabilityActionUnrevoke, namespace); err != nil {
+		return err
+	}
+	config, err := loadShardConfig(ctx)
+	if err != nil {
+		return err
+	}
+	assignment, err := loadNamespaceAssignment(ctx, config, namespace)
+	if err != nil {
+		return err
+	}
+	shard, ok, err := loadNamespaceEntry(ctx, namespace, data)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: data '%s' is not in namespace %s", ErrNotFound, data, namespace)
+	}
+	if err := authorizeDelete(ctx, namespaceScope(namespace), data); err != nil {
+		return err
+	}
+
+	filter, err := loadShardFilter(ctx, shard)
+	if err != nil {
+		return err
+	}
+	if !filter.Delete(namespaceValue(namespace, data)) {
+		return fmt.Errorf("%w: failed to delete data '%s' from shard %d", ErrNotFound, data, shard)
+	}
+	if err := saveShardFilter(ctx, shard, filter); err != nil {
+		return err
+	}
+	if err := deleteNamespaceEntry(ctx, namespace, data); err != nil {
+		return err
+	}
+	if err := recordLifecycleEvent(ctx, LifecycleUnrevoked, namespaceDetails(namespace), data); err != nil {
+		return err
+	}
+	if err := recordTransientRevocationAudit(ctx, LifecycleUnrevoked, data); err != nil {
+		return err
+	}
+	// Writing the assignment puts the delete under the endorsement policy of the namespace
+	if err := saveNamespaceAssignment(ctx, assignment); err != nil {
+		return err
+	}
+	return emitNamespaceChanged(ctx, namespace, FilterChangeDeleted, filter, []string{data})
+}
+
+// LookupInNamespace checks if data is present in the shard of an issuer namespace,
+// and in both shards while the namespace is migrating
+func (s *SmartContract) LookupInNamespace(ctx contractapi.TransactionContextInterface, namespace string, data string) (bool, error) {
+	config, err := loadShardConfig(ctx)
+	if err != nil {
+		return false, err
+	}
+	assignment, err := loadNamespaceAssignment(ctx, config, namespace)
+	if err != nil {
+		return false, err
+	}
+
+	shards := []uint{assignment.Shard}
+	if assignment.Migrating {
+		shards = append(shards, assignment.TargetShard)
+	}
+	for _, shard := range shards {
+		filter, err := loadShardFilter(ctx, shard)
+		if err != nil {
+			return false, err
+		}
+		if filter.Lookup(namespaceValue(namespace, data)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RebalanceNamespace moves up to batchSize entries of a namespace to the shard the hash ring assigns it to,
+// emitting a NamespaceMigrationProgress event. It is repeated until the returned assignment is no longer migrating;
+// lookups keep working in between. Registry admins only.
+func (s *SmartContract) RebalanceNamespace(ctx contractapi.TransactionContextInterface, namespace string, batchSize uint) (*NamespaceAssignment, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	if err := checkRegistryAdmin(ctx, "rebalance namespaces"); err != nil {
+		return nil, err
+	}
+	if batchSize == 0 {
+		return nil, fmt.Errorf("batch size must be positive")
+	}
+	if err := checkBatchSize(ctx, int(batchSize)); err != nil {
+		return nil, err
+	}
+	config, err := loadShardConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	assignment, err := loadNamespaceAssignment(ctx, config, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	if !assignment.Migrating {
+		target := ShardForNamespace(namespace, config.Shards, config.VirtualNodes)
+		if target == assignment.Shard {
+			return assignment, nil
+		}
+		assignment.Migrating = true
+		assignment.TargetShard = target
+		assignment.Moved = 0
+	}
+
+	entries, err := namespaceEntriesInShard(ctx, namespace, assignment.Shard)
+	if err != nil {
+		return nil, err
+	}
+	batch := entries
+	if uint(len(batch)) > batchSize {
+		batch = batch[:batchSize]
+	}
+
+	source, err := loadShardFilter(ctx, assignment.Shard)
+	if err != nil {
+		return nil, err
+	}
+	target, err := loadShardFilter(ctx, assignment.TargetShard)
+	if err != nil {
+		return nil, err
+	}
+	for _, data := range batch {
+		if !target.Insert(namespaceValue(namespace, data)) {
+			return nil, fmt.Errorf("%w: failed to insert data '%s' into shard %d", ErrFilterFull, data, assignment.TargetShard)
+		}
+		source.Delete(namespaceValue(namespace, data))
+		if err := saveNamespaceEntry(ctx, namespace, data, assignment.TargetShard); err != nil {
+			return nil, err
+		}
+	}
+	if err := saveShardFilter(ctx, assignment.Shard, source); err != nil {
+		return nil, err
+	}
+	if err := saveShardFilter(ctx, assignment.TargetShard, target); err != nil {
+		return nil, err
+	}
+
+	assignment.Moved += uint(len(batch))
+	assignment.Remaining = uint(len(entries) - len(batch))
+	if assignment.Remaining == 0 {
+		assignment.Shard = assignment.TargetShard
+		assignment.Migrating = false
+	}
+	if err := saveNamespaceAssignment(ctx, assignment); err != nil {
+		return nil, err
+	}
+
+	progressJSON, err := json.Marshal(assignment)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.GetStub().SetEvent(NamespaceMigrationProgressEvent, progressJSON); err != nil {
+		return nil, fmt.Errorf("error emitting %s event: %v", NamespaceMigrationProgressEvent, err)
+	}
+	return assignment, nil
+}
+
+func loadShardConfig(ctx contractapi.TransactionContextInterface) (*ShardConfig, error) {
+	configJSON, err := ctx.GetStub().GetState(shardConfigKey)
+	if err != nil {
+		return nil, fmt.Errorf("error loading shard config: %v", err)
+	}
+	if configJSON == nil {
+		return nil, fmt.Errorf("shards are not initialized")
+	}
+	var config ShardConfig
+	if err := json.Unmarshal(configJSON, &config); err != nil {
+		return nil, fmt.Errorf("error decoding shard config: %v", err)
+	}
+	return &config, nil
+}
+
+func saveShardConfig(ctx contractapi.TransactionContextInterface, config *ShardConfig) error {
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(shardConfigKey, configJSON)
+}
+
+func loadShardFilter(ctx contractapi.TransactionContextInterface, shard uint) (*Filter, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(filterShardObjectType, []string{strconv.FormatUint(uint64(shard), 10)})
+	if err != nil {
+		return nil, fmt.Errorf("error creating shard key: %v", err)
+	}
+	filterJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("error loading shard %d: %v", shard, err)
+	}
+	if filterJSON == nil {
+		return nil, fmt.Errorf("%w: shard %d not found", ErrNotFound, shard)
+	}
+	if err := verifyStateHash(ctx, shardStateName(shard), filterJSON); err != nil {
+		return nil, err
+	}
+	var filter Filter
+	if err := json.Unmarshal(filterJSON, &filter); err != nil {
+		return nil, fmt.Errorf("error decoding shard %d: %v", shard, err)
+	}
+	return &filter, nil
+}
+
+func saveShardFilter(ctx contractapi.TransactionContextInterface, shard uint, filter *Filter) error {
+	key, err := ctx.GetStub().CreateCompositeKey(filterShardObjectType, []string{strconv.FormatUint(uint64(shard), 10)})
+	if err != nil {
+		return fmt.Errorf("error creating shard key: %v", err)
+	}
+	filterJSON, err := json.Marshal(filter)
+	if err != nil {
+		return err
+	}
+	return putStateWithHash(ctx, key, shardStateName(shard), filterJSON)
+}
+
+// shardStateName names a shard filter in its state hash key
+func shardStateName(shard uint) string {
+	return filterShardObjectType + "-" + strconv.FormatUint(uint64(shard), 10)
+}
+
+// loadNamespaceAssignment returns the stored assignment of a namespace or its place on the hash ring
+func loadNamespaceAssignment(ctx contractapi.TransactionContextInterface, config *ShardConfig, namespace string) (*NamespaceAssignment, error) {
+	if namespace == "" {
+		return nil, fmt.Errorf("namespace must not be empty")
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(namespaceAssignmentObjectType, []string{namespace})
+	if err != nil {
+		return nil, fmt.Errorf("error creating namespace key: %v", err)
+	}
+	assignmentJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("error loading namespace assignment: %v", err)
+	}
+	if assignmentJSON == nil {
+		shard := ShardForNamespace(namespace, config.Shards, config.VirtualNodes)
+		return &NamespaceAssignment{Namespace: namespace, Shard: shard, TargetShard: shard}, nil
+	}
+	var assignment NamespaceAssignment
+	if err := json.Unmarshal(assignmentJSON, &assignment); err != nil {
+		return nil, fmt.Errorf("error decoding namespace assignment: %v", err)
+	}
+	return &assignment, nil
+}
+
+func saveNamespaceAssignment(ctx contractapi.TransactionContextInterface, assignment *NamespaceAssignment) error {
+	key, err := ctx.GetStub().CreateCompositeKey(namespaceAssignmentObjectType, []string{assignment.Namespace})
+	if err != nil {
+		return fmt.Errorf("error creating namespace key: %v", err)
+	}
+	assignmentJSON, err := json.Marshal(assignment)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(key, assignmentJSON)
+}
+
+func saveNamespaceEntry(ctx contractapi.TransactionContextInterface, namespace string, data string, shard uint) error {
+	key, err := ctx.GetStub().CreateCompositeKey(namespaceEntryObjectType, []string{namespace, data})
+	if err != nil {
+		return fmt.Errorf("error creating namespace entry key: %v", err)
+	}
+	return ctx.GetStub().PutState(key, []byte(strconv.FormatUint(uint64(shard), 10)))
+}
+
+// namespaceEntryExists reports whether data was inserted into a namespace
+func namespaceEntryExists(ctx contractapi.TransactionContextInterface, namespace string, data string) (bool, error) {
+	_, ok, err := loadNamespaceEntry(ctx, namespace, data)
+	return ok, err
+}
+
+// loadNamespaceEntry returns the shard an entry of a namespace is stored in, and whether there is such an entry
+func loadNamespaceEntry(ctx contractapi.TransactionContextInterface, namespace string, data string) (uint, bool, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(namespaceEntryObjectType, []string{namespace, data})
+	if err != nil {
+		return 0, false, fmt.Errorf("error creating namespace entry key: %v", err)
+	}
+	entry, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return 0, false, fmt.Errorf("error reading namespace entry: %v", err)
+	}
+	if entry == nil {
+		return 0, false, nil
+	}
+	shard, err := strconv.ParseUint(string(entry), 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("%w: namespace entry of '%s' holds no shard: %v", ErrStateCorrupt, data, err)
+	}
+	return uint(shard), true, nil
+}
+
+func deleteNamespaceEntry(ctx contractapi.TransactionContextInterface, namespace string, data string) error {
+	key, err := ctx.GetStub().CreateCompositeKey(namespaceEntryObjectType, []string{namespace, data})
+	if err != nil {
+		return fmt.Errorf("error creating namespace entry key: %v", err)
+	}
+	if err := ctx.GetStub().DelState(key); err != nil {
+		return fmt.Errorf("error deleting namespace entry: %v", err)
+	}
+	return nil
+}
+
+// namespaceValue is what a shard filter stores for data of a namespace. Namespaces share the filter of
+// their shard, so the value is bound to the namespace to keep their entries apart.
+func namespaceValue(namespace string, data string) []byte {
+	return []byte(namespace + "\x00" + data)
+}
+
+// namespaceDetails describes the namespace of a change in lifecycle events
+func namespaceDetails(namespace string) string {
+	return "namespace " + namespace
+}
+
+// namespaceEntriesInShard returns the data of a namespace's entries stored in the given shard
+func namespaceEntriesInShard(ctx contractapi.TransactionContextInterface, namespace string, shard uint) ([]string, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(namespaceEntryObjectType, []string{namespace})
+	if err != nil {
+		return nil, fmt.Errorf("error reading namespace entries: %v", err)
+	}
+	defer iterator.Close()
+
+	shardValue := strconv.FormatUint(uint64(shard), 10)
+	var entries []string
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error reading namespace entries: %v", err)
+		}
+		if string(entry.Value) != shardValue {
+			continue
+		}
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, attributes[1])
+	}
+	return entries, nil
+}
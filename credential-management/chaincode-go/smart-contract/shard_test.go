@@ -0,0 +1,221 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func newShardSimulator(t *testing.T, shards uint) (*simulator.Simulator, *simulator.Identity) {
+	sim, err := simulator.New("credential-management", &cuckoofilter.SmartContract{})
+	require.NoError(t, err)
+	admin, err := simulator.NewIdentity("Org1MSP", "registry-admin")
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "InitShards", fmt.Sprint(shards), "16", "100", "4")
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+	_, err = sim.Submit(newRegistryAdmin(t), "InitShards", fmt.Sprint(shards), "16", "100", "4")
+	require.NoError(t, err)
+	return sim, admin
+}
+
+func TestShardForNamespace_Balanced(t *testing.T) {
+	counts := make(map[uint]int)
+	for i := 0; i < 1000; i++ {
+		counts[cuckoofilter.ShardForNamespace(fmt.Sprintf("did:web:issuer-%d.example", i), 4, 64)]++
+	}
+	require.Len(t, counts, 4)
+	for shard, count := range counts {
+		require.Greater(t, count, 150, "shard %d is underloaded", shard)
+	}
+}
+
+func TestShardForNamespace_AddingShardMovesFewNamespaces(t *testing.T) {
+	moved := 0
+	for i := 0; i < 1000; i++ {
+		namespace := fmt.Sprintf("did:web:issuer-%d.example", i)
+		before := cuckoofilter.ShardForNamespace(namespace, 4, 64)
+		after := cuckoofilter.ShardForNamespace(namespace, 5, 64)
+		if before != after {
+			require.Equal(t, uint(4), after, "namespaces only move to the new shard")
+			moved++
+		}
+	}
+	require.Greater(t, moved, 0)
+	require.Less(t, moved, 400)
+}
+
+func TestInsertAndLookupInNamespace(t *testing.T) {
+	sim, admin := newShardSimulator(t, 2)
+
+	_, err := sim.Submit(admin, "InsertInNamespace", "did:web:issuer-a.example", "credential-1")
+	require.NoError(t, err)
+
+	found, err := sim.Evaluate(admin, "LookupInNamespace", "did:web:issuer-a.example", "credential-1")
+	require.NoError(t, err)
+	require.Equal(t, "true", string(found))
+	found, err = sim.Evaluate(admin, "LookupInNamespace", "did:web:issuer-a.example", "credential-2")
+	require.NoError(t, err)
+	require.Equal(t, "false", string(found))
+}
+
+func TestInsertInNamespace_NamespacesOnSameShard(t *testing.T) {
+	// Find two namespaces that share a shard
+	namespaceA, namespaceB := "did:web:issuer-0.example", ""
+	for i := 1; namespaceB == ""; i++ {
+		candidate := fmt.Sprintf("did:web:issuer-%d.example", i)
+		if cuckoofilter.ShardForNamespace(candidate, 2, 16) == cuckoofilter.ShardForNamespace(namespaceA, 2, 16) {
+			namespaceB = candidate
+		}
+	}
+
+	sim, admin := newShardSimulator(t, 2)
+	_, err := sim.Submit(admin, "InsertInNamespace", namespaceA, "credential-1")
+	require.NoError(t, err)
+
+	// A revocation by one issuer does not show up in the namespace of another
+	found, err := sim.Evaluate(admin, "LookupInNamespace", namespaceB, "credential-1")
+	require.NoError(t, err)
+	require.Equal(t, "false", string(found))
+
+	_, err = sim.Submit(admin, "InsertInNamespace", namespaceB, "credential-1")
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "DeleteFromNamespace", namespaceA, "credential-1")
+	require.NoError(t, err)
+
+	found, err = sim.Evaluate(admin, "LookupInNamespace", namespaceA, "credential-1")
+	require.NoError(t, err)
+	require.Equal(t, "false", string(found))
+	found, err = sim.Evaluate(admin, "LookupInNamespace", namespaceB, "credential-1")
+	require.NoError(t, err)
+	require.Equal(t, "true", string(found))
+}
+
+func TestDeleteFromNamespace_OnlyByInserter(t *testing.T) {
+	sim, _ := newShardSimulator(t, 2)
+	issuer1 := newIssuerIdentity(t, "Org1MSP", "did:key:issuer1")
+	issuer2 := newIssuerIdentity(t, "Org2MSP", "did:key:issuer2")
+
+	tx, err := sim.Submit(issuer1, "InsertInNamespace", "did:web:issuer-a.example", "credential-1")
+	require.NoError(t, err)
+	var change cuckoofilter.FilterChange
+	require.NoError(t, json.Unmarshal(tx.Event.Payload, &change))
+	require.Equal(t, cuckoofilter.FilterChange{
+		Namespace:    "did:web:issuer-a.example",
+		Action:       cuckoofilter.FilterChangeInserted,
+		Fingerprints: []string{"credential-1"},
+		Count:        1,
+	}, change)
+
+	// The inserter of the namespace entry does not own the same value in a filter of the same name
+	_, err = sim.Submit(issuer2, "DeleteFromNamespace", "did:web:issuer-a.example", "credential-1")
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+	_, err = sim.Submit(issuer1, "DeleteFromNamespace", "did:web:issuer-a.example", "credential-2")
+	require.ErrorContains(t, err, cuckoofilter.NotFoundErrorCode)
+
+	tx, err = sim.Submit(issuer1, "DeleteFromNamespace", "did:web:issuer-a.example", "credential-1")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(tx.Event.Payload, &change))
+	require.Equal(t, cuckoofilter.FilterChangeDeleted, change.Action)
+	found, err := sim.Evaluate(issuer1, "LookupInNamespace", "did:web:issuer-a.example", "credential-1")
+	require.NoError(t, err)
+	require.Equal(t, "false", string(found))
+
+	// The entry can be inserted again, now by another issuer
+	_, err = sim.Submit(issuer2, "InsertInNamespace", "did:web:issuer-a.example", "credential-1")
+	require.NoError(t, err)
+}
+
+func TestInsertInNamespace_StrictMode(t *testing.T) {
+	sim, admin := newShardSimulator(t, 2)
+	_, err := sim.Submit(newRegistryAdmin(t), "SetStrictMode", "true")
+	require.NoError(t, err)
+
+	_, err = sim.Submit(admin, "InsertInNamespace", "did:web:issuer-a.example", "credential-1")
+	require.Error(t, err)
+	_, err = sim.Submit(admin, "InsertInNamespace", "did:web:issuer-a.example", "00112233445566778899aabbccddeeff")
+	require.NoError(t, err)
+}
+
+func TestInsertInNamespace_NotInitialized(t *testing.T) {
+	sim, err := simulator.New("credential-management", &cuckoofilter.SmartContract{})
+	require.NoError(t, err)
+	admin, err := simulator.NewIdentity("Org1MSP", "registry-admin")
+	require.NoError(t, err)
+
+	_, err = sim.Submit(admin, "InsertInNamespace", "did:web:issuer-a.example", "credential-1")
+	require.Error(t, err)
+}
+
+func TestRebalanceNamespace(t *testing.T) {
+	// Find a namespace the new shard takes over
+	namespace := ""
+	for i := 0; namespace == ""; i++ {
+		candidate := fmt.Sprintf("did:web:issuer-%d.example", i)
+		if cuckoofilter.ShardForNamespace(candidate, 2, 16) != cuckoofilter.ShardForNamespace(candidate, 3, 16) {
+			namespace = candidate
+		}
+	}
+
+	sim, admin := newShardSimulator(t, 2)
+	registryAdmin := newRegistryAdmin(t)
+	for i := 0; i < 5; i++ {
+		_, err := sim.Submit(admin, "InsertInNamespace", namespace, fmt.Sprintf("credential-%d", i))
+		require.NoError(t, err)
+	}
+	// A repeated insert leaves no second fingerprint behind in the source shard
+	_, err := sim.Submit(admin, "InsertInNamespace", namespace, "credential-0")
+	require.ErrorContains(t, err, cuckoofilter.AlreadyRevokedErrorCode)
+	_, err = sim.Submit(admin, "AddShard")
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+	_, err = sim.Submit(admin, "RebalanceNamespace", namespace, "2")
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+	_, err = sim.Submit(registryAdmin, "AddShard")
+	require.NoError(t, err)
+	// The new shard already holds the same values for another namespace
+	other := ""
+	for i := 0; other == ""; i++ {
+		candidate := fmt.Sprintf("did:web:other-%d.example", i)
+		if cuckoofilter.ShardForNamespace(candidate, 3, 16) == 2 {
+			other = candidate
+		}
+	}
+	for i := 0; i < 5; i++ {
+		_, err := sim.Submit(admin, "InsertInNamespace", other, fmt.Sprintf("credential-%d", i))
+		require.NoError(t, err)
+	}
+
+	var assignment cuckoofilter.NamespaceAssignment
+	for step := 0; step < 3; step++ {
+		tx, err := sim.Submit(registryAdmin, "RebalanceNamespace", namespace, "2")
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(tx.Payload, &assignment))
+		require.Equal(t, cuckoofilter.NamespaceMigrationProgressEvent, tx.Event.EventName)
+
+		// Entries stay visible while the namespace is split over two shards
+		for i := 0; i < 5; i++ {
+			found, err := sim.Evaluate(admin, "LookupInNamespace", namespace, fmt.Sprintf("credential-%d", i))
+			require.NoError(t, err)
+			require.Equal(t, "true", string(found), "step %d credential-%d", step, i)
+		}
+	}
+
+	require.False(t, assignment.Migrating)
+	require.Equal(t, uint(2), assignment.Shard)
+	require.Equal(t, uint(5), assignment.Moved)
+	require.Equal(t, uint(0), assignment.Remaining)
+	migrationEvents := 0
+	for _, event := range sim.Events() {
+		if event.EventName == cuckoofilter.NamespaceMigrationProgressEvent {
+			migrationEvents++
+		}
+	}
+	require.Equal(t, 3, migrationEvents)
+
+	// Nothing left to move
+	tx, err := sim.Submit(registryAdmin, "RebalanceNamespace", namespace, "2")
+	require.NoError(t, err)
+	require.Nil(t, tx.Event)
+}
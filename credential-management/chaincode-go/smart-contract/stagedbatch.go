@@ -0,0 +1,279 @@
+package cuckoofilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"time"
+)
+
+// Composite key prefixes of staged batches, stagedBatch~<id> and stagedBatchChunk~<id>~<index>
+const (
+	stagedBatchObjectType      = "stagedBatch"
+	stagedBatchChunkObjectType = "stagedBatchChunk"
+)
+
+// Status values of a staged batch
+const (
+	StagedBatchOpen      = "open"
+	StagedBatchCommitted = "committed"
+	StagedBatchAborted   = "aborted"
+)
+
+// ErrNotBatchOwner is returned when a client other than the one that began a staged batch changes it
+var ErrNotBatchOwner = fmt.Errorf("%w: only the client that began a staged batch may change it", ErrUnauthorized)
+
+// StagedBatch is a batch insert spread over several transactions, for batches that exceed the
+// transaction size limit. The chunks are staged by AppendBatchChunk and inserted at once by CommitBatch.
+type StagedBatch struct {
+	ID       string    `json:"id"` // ID of the transaction that began the batch
+	FilterID string    `json:"filterId"`
+	Owner    Inserter  `json:"owner"`
+	Status   string    `json:"status"`
+	Chunks   int       `json:"chunks"`
+	Items    int       `json:"items"`
+	BegunAt  time.Time `json:"begunAt"`
+	ClosedAt time.Time `json:"closedAt"`                                // Time of the commit or abort
+	ClosedTx string    `json:"closedTx,omitempty" metadata:",optional"` // ID of the transaction that committed or aborted the batch
+}
+
+// BeginBatch starts a staged batch insert into a filter. The transaction ID becomes the batch ID.
+func (s *SmartContract) BeginBatch(ctx contractapi.TransactionContextInterface, filterID string) (*StagedBatch, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	if err := checkCapability(ctx, CapabilityActionRevoke, filterID); err != nil {
+		return nil, err
+	}
+	if _, err := s.loadFilter(ctx, filterID); err != nil {
+		return nil, fmt.Errorf("error loading filter state: %v", err)
+	}
+	client, _, err := clientInserter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	begunAt, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := &StagedBatch{
+		ID:       ctx.GetStub().GetTxID(),
+		FilterID: filterID,
+		Owner:    *client,
+		Status:   StagedBatchOpen,
+		BegunAt:  begunAt,
+	}
+	if err := saveStagedBatch(ctx, batch); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// AppendBatchChunk stages the data items of one chunk of an open batch. Every chunk is subject to the
+// configured batch limit and strict mode.
+func (s *SmartContract) AppendBatchChunk(ctx contractapi.TransactionContextInterface, batchID string, dataItems []string) (*StagedBatch, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	batch, err := loadOwnStagedBatch(ctx, batchID, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(dataItems) == 0 {
+		return nil, fmt.Errorf("%w: chunk must not be empty", ErrInvalidArgument)
+	}
+	if err := checkBatchSize(ctx, len(dataItems)); err != nil {
+		return nil, err
+	}
+	if err := checkStrictMode(ctx, dataItems...); err != nil {
+		return nil, err
+	}
+
+	chunkJSON, err := json.Marshal(dataItems)
+	if err != nil {
+		return nil, err
+	}
+	key, err := stagedBatchChunkKey(ctx, batchID, batch.Chunks)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.GetStub().PutState(key, chunkJSON); err != nil {
+		return nil, fmt.Errorf("error staging batch chunk: %v", err)
+	}
+	batch.Chunks++
+	batch.Items += len(dataItems)
+	if err := saveStagedBatch(ctx, batch); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// CommitBatch inserts all staged items of an open batch in one transaction, like BatchInsert with the
+// same transient flags, and removes the staged chunks. A failed commit leaves the batch open.
+func (s *SmartContract) CommitBatch(ctx contractapi.TransactionContextInterface, batchID string) (*BatchInsertResult, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	batch, err := loadOwnStagedBatch(ctx, batchID, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCapability(ctx, CapabilityActionRevoke, batch.FilterID); err != nil {
+		return nil, err
+	}
+	reason, err := transientReason(ctx)
+	if err != nil {
+		return nil, err
+	}
+	idempotent, err := transientIdempotent(ctx)
+	if err != nil {
+		return nil, err
+	}
+	partial, err := transientPartial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dataItems := make([]string, 0, batch.Items)
+	for index := 0; index < batch.Chunks; index++ {
+		chunk, err := loadStagedBatchChunk(ctx, batchID, index)
+		if err != nil {
+			return nil, err
+		}
+		dataItems = append(dataItems, chunk...)
+	}
+	// Strict mode may have been switched on since the chunks were staged
+	if err := checkStrictMode(ctx, dataItems...); err != nil {
+		return nil, err
+	}
+	result, err := s.insertItems(ctx, batch.FilterID, reason, idempotent, partial, dataItems)
+	if err != nil {
+		return nil, err
+	}
+	if err := closeStagedBatch(ctx, batch, StagedBatchCommitted); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// AbortBatch discards the staged chunks of an open batch. Registry admins may abort the batches of
+// other clients, e.g. ones abandoned by a failed issuer job.
+func (s *SmartContract) AbortBatch(ctx contractapi.TransactionContextInterface, batchID string) (*StagedBatch, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	batch, err := loadOwnStagedBatch(ctx, batchID, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := closeStagedBatch(ctx, batch, StagedBatchAborted); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// GetStagedBatch returns a staged batch
+func (s *SmartContract) GetStagedBatch(ctx contractapi.TransactionContextInterface, batchID string) (*StagedBatch, error) {
+	return loadStagedBatch(ctx, batchID)
+}
+
+// loadOwnStagedBatch returns an open batch of the submitting client, or of any client for admins if adminAllowed
+func loadOwnStagedBatch(ctx contractapi.TransactionContextInterface, batchID string, adminAllowed bool) (*StagedBatch, error) {
+	batch, err := loadStagedBatch(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+	client, admin, err := clientInserter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if *client != batch.Owner && !(adminAllowed && admin) {
+		return nil, ErrNotBatchOwner
+	}
+	if batch.Status != StagedBatchOpen {
+		return nil, fmt.Errorf("%w: staged batch %s is already %s", ErrFailedPrecondition, batchID, batch.Status)
+	}
+	return batch, nil
+}
+
+// closeStagedBatch deletes the staged chunks and records the final status of the batch
+func closeStagedBatch(ctx contractapi.TransactionContextInterface, batch *StagedBatch, status string) error {
+	for index := 0; index < batch.Chunks; index++ {
+		key, err := stagedBatchChunkKey(ctx, batch.ID, index)
+		if err != nil {
+			return err
+		}
+		if err := ctx.GetStub().DelState(key); err != nil {
+			return fmt.Errorf("error deleting batch chunk: %v", err)
+		}
+	}
+	closedAt, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	batch.Status = status
+	batch.ClosedAt = closedAt
+	batch.ClosedTx = ctx.GetStub().GetTxID()
+	return saveStagedBatch(ctx, batch)
+}
+
+// stagedBatchChunkKey returns the key of a chunk, with the index zero-padded so chunks list in order
+func stagedBatchChunkKey(ctx contractapi.TransactionContextInterface, batchID string, index int) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(stagedBatchChunkObjectType, []string{batchID, fmt.Sprintf("%08d", index)})
+	if err != nil {
+		return "", fmt.Errorf("error creating batch chunk key: %v", err)
+	}
+	return key, nil
+}
+
+func loadStagedBatchChunk(ctx contractapi.TransactionContextInterface, batchID string, index int) ([]string, error) {
+	key, err := stagedBatchChunkKey(ctx, batchID, index)
+	if err != nil {
+		return nil, err
+	}
+	chunkJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("error loading batch chunk: %v", err)
+	}
+	if chunkJSON == nil {
+		return nil, fmt.Errorf("%w: chunk %d of staged batch %s is missing", ErrStateCorrupt, index, batchID)
+	}
+	var chunk []string
+	if err := json.Unmarshal(chunkJSON, &chunk); err != nil {
+		return nil, fmt.Errorf("error decoding batch chunk: %v", err)
+	}
+	return chunk, nil
+}
+
+func saveStagedBatch(ctx contractapi.TransactionContextInterface, batch *StagedBatch) error {
+	key, err := ctx.GetStub().CreateCompositeKey(stagedBatchObjectType, []string{batch.ID})
+	if err != nil {
+		return fmt.Errorf("error creating staged batch key: %v", err)
+	}
+	batchJSON, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(key, batchJSON)
+}
+
+func loadStagedBatch(ctx contractapi.TransactionContextInterface, batchID string) (*StagedBatch, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(stagedBatchObjectType, []string{batchID})
+	if err != nil {
+		return nil, fmt.Errorf("error creating staged batch key: %v", err)
+	}
+	batchJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("error loading staged batch: %v", err)
+	}
+	if batchJSON == nil {
+		return nil, fmt.Errorf("%w: staged batch %s not found", ErrNotFound, batchID)
+	}
+
+	var batch StagedBatch
+	if err := json.Unmarshal(batchJSON, &batch); err != nil {
+		return nil, fmt.Errorf("error decoding staged batch: %v", err)
+	}
+	return &batch, nil
+}
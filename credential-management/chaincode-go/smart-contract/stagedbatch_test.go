@@ -0,0 +1,107 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func requireStagedBatch(t *testing.T, payload []byte) *cuckoofilter.StagedBatch {
+	var batch cuckoofilter.StagedBatch
+	require.NoError(t, json.Unmarshal(payload, &batch))
+	return &batch
+}
+
+func TestStagedBatch_Commit(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	// Chunks stay within the batch limit, the committed batch exceeds it
+	_, err := sim.Submit(newRegistryAdmin(t), "UpdateRegistryConfig", "2")
+	require.NoError(t, err)
+
+	tx, err := sim.Submit(admin, "BeginBatch", "")
+	require.NoError(t, err)
+	batchID := requireStagedBatch(t, tx.Payload).ID
+	for chunk := 0; chunk < 3; chunk++ {
+		chunkJSON := fmt.Sprintf(`["credential-%d-a","credential-%d-b"]`, chunk, chunk)
+		tx, err = sim.Submit(admin, "AppendBatchChunk", batchID, chunkJSON)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 6, requireStagedBatch(t, tx.Payload).Items)
+	_, err = sim.Submit(admin, "AppendBatchChunk", batchID, `["a","b","c"]`)
+	require.Error(t, err)
+	// Nothing is revoked before the commit
+	requireLookup(t, sim, admin, "", "credential-0-a", false)
+	chunkKey := "\x00stagedBatchChunk\x00" + batchID + "\x0000000000\x00"
+	require.NotNil(t, sim.GetState(chunkKey))
+
+	tx, err = sim.Submit(admin, "CommitBatch", batchID)
+	require.NoError(t, err)
+	var result cuckoofilter.BatchInsertResult
+	require.NoError(t, json.Unmarshal(tx.Payload, &result))
+	require.Equal(t, 6, result.Inserted)
+	require.NotNil(t, tx.Event)
+	for chunk := 0; chunk < 3; chunk++ {
+		requireLookup(t, sim, admin, "", fmt.Sprintf("credential-%d-b", chunk), true)
+	}
+
+	// The staged chunks are removed, the batch is kept as a record
+	require.Nil(t, sim.GetState(chunkKey))
+	batchJSON, err := sim.Evaluate(admin, "GetStagedBatch", batchID)
+	require.NoError(t, err)
+	require.Equal(t, cuckoofilter.StagedBatchCommitted, requireStagedBatch(t, batchJSON).Status)
+
+	_, err = sim.Submit(admin, "CommitBatch", batchID)
+	require.ErrorContains(t, err, cuckoofilter.FailedPreconditionErrorCode)
+}
+
+func TestStagedBatch_FailedCommitStaysOpen(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	_, err := sim.Submit(admin, "Insert", "", "credential-1")
+	require.NoError(t, err)
+	tx, err := sim.Submit(admin, "BeginBatch", "")
+	require.NoError(t, err)
+	batchID := requireStagedBatch(t, tx.Payload).ID
+	_, err = sim.Submit(admin, "AppendBatchChunk", batchID, `["credential-1","credential-2"]`)
+	require.NoError(t, err)
+
+	_, err = sim.Submit(admin, "CommitBatch", batchID)
+	require.ErrorContains(t, err, cuckoofilter.AlreadyRevokedErrorCode)
+	requireLookup(t, sim, admin, "", "credential-2", false)
+
+	idempotent := map[string][]byte{cuckoofilter.IdempotentTransientKey: []byte("true")}
+	tx, err = sim.SubmitTransient(admin, idempotent, "CommitBatch", batchID)
+	require.NoError(t, err)
+	var result cuckoofilter.BatchInsertResult
+	require.NoError(t, json.Unmarshal(tx.Payload, &result))
+	require.Equal(t, 1, result.Inserted)
+	require.Equal(t, 1, result.Duplicate)
+}
+
+func TestStagedBatch_Owner(t *testing.T) {
+	sim, _ := newRegistrySimulator(t)
+	issuer1 := newIssuerIdentity(t, "Org1MSP", "did:key:issuer1")
+	issuer2 := newIssuerIdentity(t, "Org2MSP", "did:key:issuer2")
+	tx, err := sim.Submit(issuer1, "BeginBatch", "")
+	require.NoError(t, err)
+	batchID := requireStagedBatch(t, tx.Payload).ID
+	_, err = sim.Submit(issuer1, "AppendBatchChunk", batchID, `["credential-1"]`)
+	require.NoError(t, err)
+
+	for _, function := range []string{"CommitBatch", "AbortBatch"} {
+		_, err = sim.Submit(issuer2, function, batchID)
+		require.ErrorContains(t, err, cuckoofilter.ErrNotBatchOwner.Error())
+	}
+
+	// Registry admins clean up abandoned batches
+	admin, err := simulator.NewIdentityWithAttributes("Org2MSP", "admin", map[string]string{cuckoofilter.RegistryAdminAttribute: "true"})
+	require.NoError(t, err)
+	tx, err = sim.Submit(admin, "AbortBatch", batchID)
+	require.NoError(t, err)
+	require.Equal(t, cuckoofilter.StagedBatchAborted, requireStagedBatch(t, tx.Payload).Status)
+	_, err = sim.Submit(issuer1, "CommitBatch", batchID)
+	require.ErrorContains(t, err, cuckoofilter.FailedPreconditionErrorCode)
+	requireLookup(t, sim, issuer1, "", "credential-1", false)
+}
@@ -1,16 +1,13 @@
 package cuckoofilter
 
 import (
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
-	"encoding/base64"
+	"crypto"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
-	"github.com/multiformats/go-multibase"
-	"math/big"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
 	"os"
 	"time"
 )
@@ -18,49 +15,56 @@ import (
 // StakeholderManagementContract struct for handling stakeholder-related transactions
 type StakeholderManagementContract struct {
 	contractapi.Contract
+	Clock clock.Clock // Time of issuance and expiry checks, the transaction timestamp if nil
+	// Filter VerifyingCredential checks revocations against, e.g. a snapshot held by an off-chain
+	// verifier, the default filter on the ledger if nil
+	RevocationFilter *Filter
+}
+
+// ErrCredentialRevoked is returned by VerifyingCredential for credentials whose fingerprint is in the revocation filter
+var ErrCredentialRevoked = fmt.Errorf("%w: credential is revoked", ErrAlreadyRevoked)
+
+// now returns the current time of the contract
+func (s *StakeholderManagementContract) now(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	if s.Clock != nil {
+		return s.Clock.Now(), nil
+	}
+	return txTimestamp(ctx)
 }
 
 // DIDResponse is a response structure for GenerateDID function
 type DIDResponse struct {
 	DID        string `json:"did"`
 	PrivateKey string `json:"privateKey"`
+	KeyType    string `json:"keyType"`
 }
 
-// GenerateDID creates a new decentralized identifier (DID) and associated private key
+// GenerateDID creates a new decentralized identifier (DID) and associated P-256 private key
 func (s *StakeholderManagementContract) GenerateDID(ctx contractapi.TransactionContextInterface, role string) (*DIDResponse, error) {
-	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	return s.GenerateDIDWithKeyType(ctx, role, KeyTypeP256)
+}
+
+// GenerateDIDWithKeyType creates a new DID with a private key of the key type: KeyTypeP256,
+// KeyTypeSecp256k1 or KeyTypeEd25519
+func (s *StakeholderManagementContract) GenerateDIDWithKeyType(ctx contractapi.TransactionContextInterface, role string, keyType string) (*DIDResponse, error) {
+	privateKey, err := generateKey(keyType)
 	if err != nil {
 		return nil, fmt.Errorf("error generating key: %v", err)
 	}
-
-	// Serialize the public key X and Y coordinates
-	publicKeyBytes, err := json.Marshal(struct {
-		X, Y *big.Int
-	}{X: privateKey.PublicKey.X, Y: privateKey.PublicKey.Y})
+	publicKey, err := publicKeyOf(privateKey)
 	if err != nil {
-		return nil, fmt.Errorf("error marshalling public key: %v", err)
+		return nil, err
 	}
-	publicKeyString := base64.StdEncoding.EncodeToString(publicKeyBytes)
-
-	// Prepend the Multicodec identifier for P-256
-	multicodecValue := []byte{0x12, 0x00}
-	combinedBytes := append(multicodecValue, privateKey.PublicKey.X.Bytes()...)
-	combinedBytes = append(combinedBytes, privateKey.PublicKey.Y.Bytes()...)
-
-	// Encode with Multibase (base58-btc)
-	encodedValue, err := multibase.Encode(multibase.Base58BTC, combinedBytes)
+	did, err := didKey(publicKey)
 	if err != nil {
-		return nil, fmt.Errorf("error encoding public key: %v", err)
+		return nil, err
 	}
 
-	did := "did:key:" + encodedValue
-
-	// Encode the private key as well
-	privateKeyBytes, err := json.Marshal(privateKey)
+	// Encode the private and public key
+	privateKeyString, publicKeyString, err := encodeKeyPair(privateKey)
 	if err != nil {
-		return nil, fmt.Errorf("error marshalling private key: %v", err)
+		return nil, err
 	}
-	privateKeyString := base64.StdEncoding.EncodeToString(privateKeyBytes)
 
 	// Determine the filename based on the role
 	var filename string
@@ -80,6 +84,7 @@ func (s *StakeholderManagementContract) GenerateDID(ctx contractapi.TransactionC
 		"DID":        did,
 		"PrivateKey": privateKeyString,
 		"PublicKey":  publicKeyString,
+		"KeyType":    keyType,
 	}
 
 	// Convert the map to JSON
@@ -94,33 +99,49 @@ func (s *StakeholderManagementContract) GenerateDID(ctx contractapi.TransactionC
 		return nil, fmt.Errorf("error writing key data to file: %v", err)
 	}
 
+	// Register the public key on the ledger, requests and decisions signed by the DID are verified against it
+	if _, err := registerDIDKey(ctx, did, keyType, publicKeyString); err != nil {
+		return nil, err
+	}
+
 	return &DIDResponse{
 		DID:        did,
 		PrivateKey: privateKeyString,
+		KeyType:    keyType,
 	}, nil
 }
 
 // IssuingCredential creates and signs a new credential
 func (s *StakeholderManagementContract) IssuingCredential(ctx contractapi.TransactionContextInterface, issuerDID string, holderDID string) (*VerifiableCredential, error) {
+	// Deactivated DIDs can neither issue nor receive credentials
+	if err := checkDIDsActive(ctx, issuerDID, holderDID); err != nil {
+		return nil, err
+	}
+
 	// Load the issuer's private key from the ledger
 	privateKey, err := s.loadPrivateKey(ctx, "issuer", issuerDID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load private key: %v", err)
 	}
 
+	issuedAt, err := s.now(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create and sign the credential
-	credential, err := CreateAndSignCredential(issuerDID, privateKey, holderDID)
+	credential, err := CreateAndSignCredentialAt(issuedAt, issuerDID, privateKey, holderDID, "")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create and sign credential: %v", err)
 	}
 
 	// Convert the credential to a JWT
-	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
-		"credential": credential,
-	})
-
-	// Sign and get the complete encoded token as a string using the secret
-	tokenString, err := token.SignedString(privateKey)
+	claims, err := credential.ToJWTClaims()
+	if err != nil {
+		return nil, err
+	}
+	// Sign with the algorithm of the issuer key and get the complete encoded token as a string
+	tokenString, err := signJWT(claims, privateKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to sign JWT: %v", err)
 	}
@@ -144,19 +165,29 @@ func (s *StakeholderManagementContract) IssuingCredential(ctx contractapi.Transa
 
 func (s *StakeholderManagementContract) IssuingBatchCredentials(ctx contractapi.TransactionContextInterface, issuerDID, holderDID string, numCredentials int) ([]string, error) {
 	var issuedCredentials []string
+	if err := checkDIDsActive(ctx, issuerDID, holderDID); err != nil {
+		return nil, err
+	}
 	privateKey, err := s.loadPrivateKey(ctx, "issuer", issuerDID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load private key: %v", err)
 	}
+	issuedAt, err := s.now(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	for i := 0; i < numCredentials; i++ {
 		credentialID := fmt.Sprintf("%s_%d", holderDID, i)
-		credential, err := CreateAndSignBatchCredential(issuerDID, privateKey, holderDID, credentialID)
+		credential, err := CreateAndSignCredentialAt(issuedAt, issuerDID, privateKey, holderDID, credentialID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create and sign credential: %v", err)
 		}
-		token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{"credential": credential})
-		tokenString, err := token.SignedString(privateKey)
+		claims, err := credential.ToJWTClaims()
+		if err != nil {
+			return nil, err
+		}
+		tokenString, err := signJWT(claims, privateKey)
 		if err != nil {
 			return nil, fmt.Errorf("failed to sign JWT: %v", err)
 		}
@@ -171,7 +202,8 @@ func (s *StakeholderManagementContract) IssuingBatchCredentials(ctx contractapi.
 	return issuedCredentials, nil
 }
 
-// VerifyingCredential verifies the signature of a given credential
+// VerifyingCredential verifies the signature of a given credential. Only credentials of issuers in the
+// trust registry, see TrustRegistryContract, validate.
 func (s *StakeholderManagementContract) VerifyingCredential(ctx contractapi.TransactionContextInterface, jwtString string, role string, holderDID string, issuerDID string) (bool, error) {
 	// Determine the filename based on the role
 	if jwtString == "" {
@@ -197,16 +229,17 @@ func (s *StakeholderManagementContract) VerifyingCredential(ctx contractapi.Tran
 
 	// Parse the JWT
 	token, err := jwt.Parse(jwtString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodECDSA); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-
 		// Load the issuer's public key from the ledger (folder ./keys/issuer_keys.json)
 		publicKey, err := s.loadPublicKey(ctx, "issuer", issuerDID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to load public key: %v", err)
 		}
 
+		// Only accept the algorithm of the issuer's key type
+		if err := checkJWTAlgorithm(token, publicKey); err != nil {
+			return nil, err
+		}
+
 		return publicKey, nil
 	})
 
@@ -225,54 +258,73 @@ func (s *StakeholderManagementContract) VerifyingCredential(ctx contractapi.Tran
 		return false, fmt.Errorf("failed to get claims from JWT")
 	}
 
-	credential, ok := claims["credential"].(map[string]interface{})
-	if !ok {
-		return false, fmt.Errorf("failed to get credential from claims")
+	var credential VerifiableCredential
+	if err := credential.FromJWTClaims(claims); err != nil {
+		return false, err
 	}
 
 	// Check the credential fields
-	issuer, ok := credential["issuer"].(string)
-	if !ok {
-		return false, fmt.Errorf("credential issuer is not a string")
-	}
-
-	if issuer != issuerDID {
+	if credential.Issuer != issuerDID {
 		return false, fmt.Errorf("credential issuer does not match role")
 	}
-
-	credentialSubject, ok := credential["credentialSubject"].(map[string]interface{})
-	if !ok {
-		return false, fmt.Errorf("credential subject is not present")
-	}
-
-	subjectID, ok := credentialSubject["id"].(string)
-	if !ok {
-		return false, fmt.Errorf("credential subject ID is not present")
+	if err := checkTrustedIssuer(ctx, credential.Issuer); err != nil {
+		return false, err
 	}
-
-	if subjectID != holderDID {
+	if credential.CredentialSubject.ID != holderDID {
 		return false, fmt.Errorf("credential subject ID does not match holderDID")
 	}
-
-	expirationDateString, ok := credential["expirationDate"].(string)
-	if !ok {
+	if err := checkDIDsActive(ctx, credential.Issuer, credential.CredentialSubject.ID); err != nil {
+		return false, err
+	}
+	if credential.ExpirationDate.IsZero() {
 		return false, fmt.Errorf("credential expiration date is not present")
 	}
 
-	expirationDate, err := time.Parse(time.RFC3339, expirationDateString)
+	now, err := s.now(ctx)
 	if err != nil {
-		return false, fmt.Errorf("expiration date is not a valid time.Time")
+		return false, err
 	}
-
-	if expirationDate.Before(time.Now()) {
+	if credential.ExpirationDate.Before(now) {
 		return false, fmt.Errorf("credential is expired")
 	}
+	if err := s.checkNotRevoked(ctx, jwtString); err != nil {
+		return false, err
+	}
 	// fmt.Println("Credential is valid ", jwtString[0:10])
 	return true, nil
 }
 
+// checkNotRevoked looks the canonical fingerprint of a credential up in the revocation filter. Before
+// the registry is initialized nothing is revoked.
+func (s *StakeholderManagementContract) checkNotRevoked(ctx contractapi.TransactionContextInterface, jwtString string) error {
+	registry := new(SmartContract)
+	status, err := registry.GetTokenCredentialStatus(ctx, jwtString)
+	if err != nil {
+		return err
+	}
+	filter := s.RevocationFilter
+	if filter == nil {
+		initialized, err := ctx.GetStub().GetState("Initialized")
+		if err != nil {
+			return fmt.Errorf("error reading registry state: %v", err)
+		}
+		if initialized == nil {
+			return nil
+		}
+		if filter, err = registry.LoadFilterState(ctx); errors.Is(err, errFilterStateNotFound) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("error loading filter state: %v", err)
+		}
+	}
+	if filter.Lookup([]byte(status.Fingerprint)) {
+		return ErrCredentialRevoked
+	}
+	return nil
+}
+
 // loadPrivateKey loads the private key of the role from the ledger
-func (s *StakeholderManagementContract) loadPrivateKey(ctx contractapi.TransactionContextInterface, role string, did string) (*ecdsa.PrivateKey, error) {
+func (s *StakeholderManagementContract) loadPrivateKey(ctx contractapi.TransactionContextInterface, role string, did string) (crypto.PrivateKey, error) {
 	// Determine the filename based on the role
 	filename := "./keys/" + role + "_keys.json"
 
@@ -302,41 +354,12 @@ func (s *StakeholderManagementContract) loadPrivateKey(ctx contractapi.Transacti
 		return nil, fmt.Errorf("private key not found in JSON")
 	}
 
-	// Base64 decode the private key string
-	privateKeyBytes, err := base64.StdEncoding.DecodeString(privateKeyString)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode base64 private key: %v", err)
-	}
-
-	// Define a temporary struct to unmarshal the private key
-	type tempPrivateKey struct {
-		D *big.Int
-		X *big.Int
-		Y *big.Int
-	}
-
-	// Unmarshal the private key into the temporary struct
-	var tempKey tempPrivateKey
-	err = json.Unmarshal(privateKeyBytes, &tempKey)
-	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal private key: %v", err)
-	}
-
-	// Create a new ecdsa.PrivateKey and manually set the Curve field
-	privateKey := &ecdsa.PrivateKey{
-		PublicKey: ecdsa.PublicKey{
-			Curve: elliptic.P256(),
-			X:     tempKey.X,
-			Y:     tempKey.Y,
-		},
-		D: tempKey.D,
-	}
-
-	return privateKey, nil
+	// Decode the private key of the stored key type, keys written before key types were stored are P-256
+	return decodePrivateKey(storedKeyType(keyData), privateKeyString)
 }
 
 // loadPublicKey loads the public key of the role from the ledger
-func (s *StakeholderManagementContract) loadPublicKey(ctx contractapi.TransactionContextInterface, role string, did string) (*ecdsa.PublicKey, error) {
+func (s *StakeholderManagementContract) loadPublicKey(ctx contractapi.TransactionContextInterface, role string, did string) (crypto.PublicKey, error) {
 	// Determine the filename based on the role
 	filename := "./keys/" + role + "_keys.json"
 
@@ -355,39 +378,27 @@ func (s *StakeholderManagementContract) loadPublicKey(ctx contractapi.Transactio
 		return nil, fmt.Errorf("failed to decode JSON: %v", err)
 	}
 
+	// Check if the DID matches
+	if keyData["DID"] != did {
+		return nil, fmt.Errorf("DID does not match")
+	}
+
 	// Get the public key string
 	publicKeyString, ok := keyData["PublicKey"]
 	if !ok {
 		return nil, fmt.Errorf("public key not found in JSON")
 	}
 
-	// Decode the public key string
-	publicKeyBytes, err := base64.StdEncoding.DecodeString(publicKeyString)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode base64 public key: %v", err)
-	}
-
-	// Define a temporary struct to unmarshal the public key
-	type tempPublicKey struct {
-		X *big.Int
-		Y *big.Int
-	}
-
-	// Unmarshal the public key into the temporary struct
-	var tempKey tempPublicKey
-	err = json.Unmarshal(publicKeyBytes, &tempKey)
-	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal public key: %v", err)
-	}
+	// Decode the public key of the stored key type
+	return decodePublicKey(storedKeyType(keyData), publicKeyString)
+}
 
-	// Create a new ecdsa.PublicKey and manually set the Curve field
-	publicKey := &ecdsa.PublicKey{
-		Curve: elliptic.P256(),
-		X:     tempKey.X,
-		Y:     tempKey.Y,
+// storedKeyType returns the key type of a key file
+func storedKeyType(keyData map[string]string) string {
+	if keyType, ok := keyData["KeyType"]; ok {
+		return keyType
 	}
-
-	return publicKey, nil
+	return KeyTypeP256
 }
 
 // TODO: DEPLOYMENT TO HL FABRIC
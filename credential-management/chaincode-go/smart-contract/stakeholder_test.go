@@ -3,16 +3,44 @@ package cuckoofilter_test
 import (
 	"encoding/base64"
 	"github.com/multiformats/go-multibase"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
 	"github.com/pherbke/credential-management/chaincode-go/mocks"
 	stakeholder "github.com/pherbke/credential-management/chaincode-go/smart-contract"
 	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
+// newStakeholderContext returns a mock context for the stakeholder contract in which no DID is deactivated
+func newStakeholderContext() *mocks.MockTransactionContext {
+	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockRegistryDefaults(mockStub)
+	mockCtx := new(mocks.MockTransactionContext)
+	mockCtx.On("GetStub").Return(mockStub)
+	mockCtx.Stub = mockStub
+	return mockCtx
+}
+
+// useStakeholderDir runs the test in a temporary working directory holding the key and credential
+// folders the stakeholder contract writes to, so tests leave the checked in key files alone
+func useStakeholderDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, folder := range []string{"keys", "issuedCredentials", "holderCredentials"} {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, folder), 0700))
+	}
+	workDir, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { require.NoError(t, os.Chdir(workDir)) })
+}
+
 func TestGenerateDID(t *testing.T) {
+	useStakeholderDir(t)
 	contract := new(stakeholder.StakeholderManagementContract)
-	mockCtx := new(mocks.MockTransactionContext)
+	mockCtx := newStakeholderContext()
 
 	// Call the GenerateDID function
 	didResponse, err := contract.GenerateDID(mockCtx, "issuer")
@@ -53,8 +81,9 @@ func TestGenerateDID(t *testing.T) {
 // test if the credential is stored in the wallet
 
 func TestCredentialLifecycle(t *testing.T) {
-	contract := new(stakeholder.StakeholderManagementContract)
-	mockCtx := new(mocks.MockTransactionContext)
+	useStakeholderDir(t)
+	contract := &stakeholder.StakeholderManagementContract{Clock: clock.System}
+	mockCtx := newStakeholderContext()
 
 	// Generate a DID for the issuer
 	issuerDIDResponse, err := contract.GenerateDID(mockCtx, "issuer")
@@ -76,8 +105,6 @@ func TestCredentialLifecycle(t *testing.T) {
 	require.NoError(t, err, "IssuingCredential should not return an error")
 	require.NotNil(t, credential, "IssuingCredential should return a credential")
 
-	// TODO: Revoke credential from holder, pass filter to tests for revocation, verif
-
 	// Verify the credential from the issuer's perspective
 	isValid, err := contract.VerifyingCredential(mockCtx, "", "issuer", holderDIDResponse.DID, issuerDIDResponse.DID)
 	require.NoError(t, err, "VerifyingCredential should not return an error")
@@ -93,9 +120,44 @@ func TestCredentialLifecycle(t *testing.T) {
 	require.NoError(t, err, "VerifyingCredential should not return an error")
 	require.True(t, isValid, "VerifyingCredential should return true for a valid credential")
 
-	// Verify credential content and signature and check if the credential is revoked or not
+	// Revoke the credential in a filter passed to the contract
+	issued, err := os.ReadFile("./holderCredentials/" + holderDIDResponse.DID + ".jwt")
+	require.NoError(t, err)
+	normalizer, err := stakeholder.LookupNormalizer("")
+	require.NoError(t, err)
+	status, err := stakeholder.NewTokenCredentialStatus(normalizer, string(issued))
+	require.NoError(t, err)
+	contract.RevocationFilter = stakeholder.NewFilter(100, 4, stakeholder.FingerPrintSize)
+	isValid, err = contract.VerifyingCredential(mockCtx, "", "verifier", holderDIDResponse.DID, issuerDIDResponse.DID)
+	require.NoError(t, err, "VerifyingCredential should not return an error before the credential is revoked")
+	require.True(t, isValid)
+
+	require.True(t, contract.RevocationFilter.Insert([]byte(status.Fingerprint)))
+	isValid, err = contract.VerifyingCredential(mockCtx, "", "verifier", holderDIDResponse.DID, issuerDIDResponse.DID)
+	require.ErrorIs(t, err, stakeholder.ErrCredentialRevoked, "VerifyingCredential should fail for a revoked credential")
+	require.False(t, isValid)
+}
+
+func TestVerifyingCredential_Expiry(t *testing.T) {
+	useStakeholderDir(t)
+	now := clock.NewManual(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	contract := &stakeholder.StakeholderManagementContract{Clock: now}
+	mockCtx := newStakeholderContext()
+
+	issuerDIDResponse, err := contract.GenerateDID(mockCtx, "issuer")
+	require.NoError(t, err)
+	holderDIDResponse, err := contract.GenerateDID(mockCtx, "holder")
+	require.NoError(t, err)
+	credential, err := contract.IssuingCredential(mockCtx, issuerDIDResponse.DID, holderDIDResponse.DID)
+	require.NoError(t, err)
+	require.True(t, credential.IssuanceDate.Equal(now.Now()))
 
-	// Revoke credential from holder and verify if the credential is revoked or not
+	now.Set(credential.ExpirationDate.Add(-time.Second))
+	isValid, err := contract.VerifyingCredential(mockCtx, "", "verifier", holderDIDResponse.DID, issuerDIDResponse.DID)
+	require.NoError(t, err)
+	require.True(t, isValid)
 
-	// Verify credential content and signature and check if the credential is revoked or not
+	now.Advance(2 * time.Second)
+	_, err = contract.VerifyingCredential(mockCtx, "", "verifier", holderDIDResponse.DID, issuerDIDResponse.DID)
+	require.EqualError(t, err, "credential is expired")
 }
@@ -0,0 +1,123 @@
+package cuckoofilter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// stateHashObjectType is the composite key prefix of the hashes stored next to filter states
+const stateHashObjectType = "stateHash"
+
+// ErrCorruptState is returned when a filter state does not match the hash stored with it,
+// e.g. after a torn or partial write
+var ErrCorruptState = fmt.Errorf("%w: filter state does not match its stored hash", ErrStateCorrupt)
+
+// stateHashKey returns the key of the hash stored with the filter state of the given name
+func stateHashKey(ctx contractapi.TransactionContextInterface, name string) (string, error) {
+	hashKey, err := ctx.GetStub().CreateCompositeKey(stateHashObjectType, []string{name})
+	if err != nil {
+		return "", fmt.Errorf("error creating state hash key: %v", err)
+	}
+	return hashKey, nil
+}
+
+// putStateWithHash writes a filter state and the SHA-256 of it under a separate state hash key.
+// The name identifies the state in the hash key, as composite keys cannot be nested.
+func putStateWithHash(ctx contractapi.TransactionContextInterface, key string, name string, payload []byte) error {
+	return putCollectionStateWithHash(ctx, "", key, name, payload)
+}
+
+// putCollectionStateWithHash is putStateWithHash for a private data collection, empty for the public state
+func putCollectionStateWithHash(ctx contractapi.TransactionContextInterface, collection string, key string, name string, payload []byte) error {
+	hashKey, err := ctx.GetStub().CreateCompositeKey(stateHashObjectType, []string{name})
+	if err != nil {
+		return fmt.Errorf("error creating state hash key: %v", err)
+	}
+	if err := putCollectionState(ctx, collection, key, payload); err != nil {
+		return err
+	}
+	hash := sha256.Sum256(payload)
+	if err := putCollectionState(ctx, collection, hashKey, hash[:]); err != nil {
+		return fmt.Errorf("error saving state hash of %s: %v", name, err)
+	}
+	return nil
+}
+
+// verifyStateHash checks a filter state read from the ledger against its stored hash.
+// States written before hashes were stored have no hash and are not checked.
+func verifyStateHash(ctx contractapi.TransactionContextInterface, name string, payload []byte) error {
+	return verifyCollectionStateHash(ctx, "", name, payload)
+}
+
+// verifyCollectionStateHash is verifyStateHash for a private data collection, empty for the public state
+func verifyCollectionStateHash(ctx contractapi.TransactionContextInterface, collection string, name string, payload []byte) error {
+	storedHash, err := storedStateHash(ctx, collection, name)
+	if err != nil {
+		return err
+	}
+	return checkStateHash(storedHash, payload)
+}
+
+// storedStateHash returns the hash stored with a filter state, nil for states written before hashes were stored
+func storedStateHash(ctx contractapi.TransactionContextInterface, collection string, name string) ([]byte, error) {
+	hashKey, err := ctx.GetStub().CreateCompositeKey(stateHashObjectType, []string{name})
+	if err != nil {
+		return nil, fmt.Errorf("error creating state hash key: %v", err)
+	}
+	storedHash, err := getCollectionState(ctx, collection, hashKey)
+	if err != nil {
+		return nil, fmt.Errorf("error loading state hash of %s: %v", name, err)
+	}
+	return storedHash, nil
+}
+
+// checkStateHash compares a filter state with its stored hash, if there is one
+func checkStateHash(storedHash []byte, payload []byte) error {
+	if storedHash == nil {
+		return nil
+	}
+	hash := sha256.Sum256(payload)
+	if string(hash[:]) != string(storedHash) {
+		return ErrCorruptState
+	}
+	return nil
+}
+
+// FilterStateHash is the result of checking the registry filter state against its stored hash
+type FilterStateHash struct {
+	Hash   string `json:"hash"`   // Hex encoded SHA-256 of the filter state as stored
+	Stored string `json:"stored"` // Hex encoded hash stored with the state, empty for states written before hashes were stored
+	Match  bool   `json:"match"`
+}
+
+// GetFilterStateHash recomputes the hash of the registry filter state and compares it with the hash
+// stored with it, e.g. to verify the state after a resize. With delta persistence, the base state is hashed.
+// In the sharded layout, the header is hashed and the match includes the shards.
+func (s *SmartContract) GetFilterStateHash(ctx contractapi.TransactionContextInterface) (*FilterStateHash, error) {
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if config.StateLayout != StateLayoutSingle {
+		return shardedFilterStateHash(ctx)
+	}
+	filterJSON, err := getCollectionState(ctx, config.PrivateCollection, filterStateKey)
+	if err != nil {
+		return nil, fmt.Errorf("error loading filter state: %v", err)
+	}
+	if filterJSON == nil {
+		return nil, errFilterStateNotFound
+	}
+	storedHash, err := storedStateHash(ctx, config.PrivateCollection, filterStateKey)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(filterJSON)
+	return &FilterStateHash{
+		Hash:   hex.EncodeToString(hash[:]),
+		Stored: hex.EncodeToString(storedHash),
+		Match:  storedHash != nil && string(hash[:]) == string(storedHash),
+	}, nil
+}
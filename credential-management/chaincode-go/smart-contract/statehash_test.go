@@ -0,0 +1,87 @@
+package cuckoofilter_test
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"github.com/pherbke/credential-management/chaincode-go/mocks"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSaveFilterState_WritesStateHash(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	_, err := sim.Submit(admin, "Insert", "", "credential-1")
+	require.NoError(t, err)
+
+	hash := sha256.Sum256(sim.GetState("CuckooFilterState"))
+	require.Equal(t, hash[:], sim.GetState("\x00stateHash\x00CuckooFilterState\x00"))
+
+	revoked, err := sim.Evaluate(admin, "Lookup", "", "credential-1")
+	require.NoError(t, err)
+	require.Equal(t, "true", string(revoked))
+}
+
+func loadFilterStateContext(filterJSON []byte, storedHash []byte) *mocks.MockTransactionContext {
+	mockStub := new(mocks.MockChaincodeStubInterface)
+	mockStub.On("GetState", "CuckooFilterState").Return(filterJSON, nil)
+	mockStub.On("CreateCompositeKey", "stateHash", []string{"CuckooFilterState"}).Return(stateHashKey, nil)
+	mockStub.On("GetState", stateHashKey).Return(storedHash, nil)
+	mockStub.On("GetState", "RegistryConfig").Return(([]byte)(nil), nil)
+
+	mockTxContext := new(mocks.MockTransactionContext)
+	mockTxContext.On("GetStub").Return(mockStub)
+	mockTxContext.Stub = mockStub
+	return mockTxContext
+}
+
+func TestLoadFilterState_CorruptState(t *testing.T) {
+	filterJSON, err := json.Marshal(cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize))
+	require.NoError(t, err)
+	// Hash of a state whose write was torn halfway
+	staleHash := sha256.Sum256(filterJSON[:len(filterJSON)/2])
+
+	_, err = new(cuckoofilter.SmartContract).LoadFilterState(loadFilterStateContext(filterJSON, staleHash[:]))
+	require.Equal(t, cuckoofilter.ErrCorruptState, err)
+}
+
+func TestLoadFilterState_VerifiesStateHash(t *testing.T) {
+	filterJSON, err := json.Marshal(cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize))
+	require.NoError(t, err)
+	hash := sha256.Sum256(filterJSON)
+
+	filter, err := new(cuckoofilter.SmartContract).LoadFilterState(loadFilterStateContext(filterJSON, hash[:]))
+	require.NoError(t, err)
+	require.NotNil(t, filter)
+}
+
+func TestLoadFilterState_WithoutStateHash(t *testing.T) {
+	// States saved before state hashes were written load unchecked
+	filterJSON, err := json.Marshal(cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize))
+	require.NoError(t, err)
+
+	_, err = new(cuckoofilter.SmartContract).LoadFilterState(loadFilterStateContext(filterJSON, nil))
+	require.NoError(t, err)
+}
+
+func TestGetFilterStateHash(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	_, err := sim.Submit(admin, "Insert", "", "credential-1")
+	require.NoError(t, err)
+
+	result, err := sim.Evaluate(admin, "GetFilterStateHash")
+	require.NoError(t, err)
+	var hash cuckoofilter.FilterStateHash
+	require.NoError(t, json.Unmarshal(result, &hash))
+	require.True(t, hash.Match)
+	require.Equal(t, hash.Hash, hash.Stored)
+
+	// A torn write no longer matches
+	filterJSON := sim.GetState("CuckooFilterState")
+	sim.SetState("CuckooFilterState", filterJSON[:len(filterJSON)/2])
+	result, err = sim.Evaluate(admin, "GetFilterStateHash")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(result, &hash))
+	require.False(t, hash.Match)
+	require.NotEqual(t, hash.Hash, hash.Stored)
+}
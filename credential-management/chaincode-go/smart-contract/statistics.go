@@ -0,0 +1,58 @@
+package cuckoofilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"sort"
+)
+
+// StatisticsDayFormat is the layout of RevocationStatistic.Day
+const StatisticsDayFormat = "2006-01-02"
+
+// RevocationStatistic is the number of revocations of one issuer on one day (UTC)
+type RevocationStatistic struct {
+	IssuerDID   string `json:"issuerDID"`
+	Day         string `json:"day"`
+	Revocations int    `json:"revocations"`
+}
+
+// GetRevocationStatistics returns the exact number of approved revocation requests per issuer and day,
+// sorted by day and issuer. The values are not meant for public endpoints, which should add noise
+// with the statistics package.
+func (s *SmartContract) GetRevocationStatistics(ctx contractapi.TransactionContextInterface) ([]RevocationStatistic, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(revocationRequestObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("error reading revocation requests: %v", err)
+	}
+	defer iterator.Close()
+
+	type cell struct{ issuerDID, day string }
+	counts := make(map[cell]int)
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error reading revocation requests: %v", err)
+		}
+		var request RevocationRequest
+		if err := json.Unmarshal(entry.Value, &request); err != nil {
+			return nil, fmt.Errorf("error decoding revocation request: %v", err)
+		}
+		if request.Status != RevocationRequestApproved {
+			continue
+		}
+		counts[cell{request.IssuerDID, request.DecidedAt.UTC().Format(StatisticsDayFormat)}]++
+	}
+
+	statistics := make([]RevocationStatistic, 0, len(counts))
+	for c, revocations := range counts {
+		statistics = append(statistics, RevocationStatistic{IssuerDID: c.issuerDID, Day: c.day, Revocations: revocations})
+	}
+	sort.Slice(statistics, func(i, j int) bool {
+		if statistics[i].Day != statistics[j].Day {
+			return statistics[i].Day < statistics[j].Day
+		}
+		return statistics[i].IssuerDID < statistics[j].IssuerDID
+	})
+	return statistics, nil
+}
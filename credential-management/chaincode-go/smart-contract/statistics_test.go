@@ -0,0 +1,44 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestGetRevocationStatistics(t *testing.T) {
+	stub := shimtest.NewMockStub("credential-management", nil)
+	day1 := time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)
+	day2 := day1.Add(2 * time.Hour)
+	requests := []cuckoofilter.RevocationRequest{
+		{ID: "tx1", IssuerDID: "did:key:issuer2", Status: cuckoofilter.RevocationRequestApproved, DecidedAt: day1},
+		{ID: "tx2", IssuerDID: "did:key:issuer1", Status: cuckoofilter.RevocationRequestApproved, DecidedAt: day1},
+		{ID: "tx3", IssuerDID: "did:key:issuer1", Status: cuckoofilter.RevocationRequestApproved, DecidedAt: day1},
+		{ID: "tx4", IssuerDID: "did:key:issuer1", Status: cuckoofilter.RevocationRequestApproved, DecidedAt: day2},
+		{ID: "tx5", IssuerDID: "did:key:issuer1", Status: cuckoofilter.RevocationRequestRejected, DecidedAt: day2},
+		{ID: "tx6", IssuerDID: "did:key:issuer1", Status: cuckoofilter.RevocationRequestPending},
+	}
+	stub.MockTransactionStart("setup")
+	for _, request := range requests {
+		key, err := stub.CreateCompositeKey("revocationRequest", []string{request.ID})
+		require.NoError(t, err)
+		requestJSON, err := json.Marshal(request)
+		require.NoError(t, err)
+		require.NoError(t, stub.PutState(key, requestJSON))
+	}
+	stub.MockTransactionEnd("setup")
+
+	ctx := new(contractapi.TransactionContext)
+	ctx.SetStub(stub)
+	statistics, err := new(cuckoofilter.SmartContract).GetRevocationStatistics(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []cuckoofilter.RevocationStatistic{
+		{IssuerDID: "did:key:issuer1", Day: "2024-03-01", Revocations: 2},
+		{IssuerDID: "did:key:issuer2", Day: "2024-03-01", Revocations: 1},
+		{IssuerDID: "did:key:issuer1", Day: "2024-03-02", Revocations: 1},
+	}, statistics)
+}
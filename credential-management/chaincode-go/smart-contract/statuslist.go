@@ -0,0 +1,367 @@
+package cuckoofilter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Composite key prefixes of status lists, statusList~<listID>, and of allocated indices,
+// statusListIndex~<listID>~<credentialID>
+const (
+	statusListObjectType      = "statusList"
+	statusListIndexObjectType = "statusListIndex"
+)
+
+// Status purposes of a StatusList2021 list
+const (
+	StatusPurposeRevocation = "revocation"
+	StatusPurposeSuspension = "suspension"
+)
+
+// Types of the W3C StatusList2021 data model
+const (
+	StatusList2021Context        = "https://w3id.org/vc/status-list/2021/v1"
+	StatusList2021Type           = "StatusList2021"
+	StatusList2021EntryType      = "StatusList2021Entry"
+	StatusList2021CredentialType = "StatusList2021Credential"
+)
+
+// DefaultStatusListSize is the minimum list length StatusList2021 recommends for herd privacy, 16KB of bits
+const DefaultStatusListSize = 131072
+
+// StatusList is a StatusList2021 bitstring kept on the ledger next to the cuckoo filter, for verifiers
+// that check credentials against a status list credential. Bit i is the status of the credential
+// holding index i; index 0 is the most significant bit of the first byte. The bitstring is stored
+// in the encodedList format of the status list credential, see EncodeStatusList.
+type StatusList struct {
+	ID            string    `json:"id"`
+	CredentialURL string    `json:"credentialURL"` // Where the status list credential is published
+	Purpose       string    `json:"purpose"`
+	Owner         Inserter  `json:"owner"`
+	Size          uint      `json:"size"`
+	NextIndex     uint      `json:"nextIndex"`
+	EncodedList   string    `json:"encodedList"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// StatusListEntry is the credentialStatus of a credential tracked in a status list
+type StatusListEntry struct {
+	ID                   string `json:"id"`
+	Type                 string `json:"type"`
+	StatusPurpose        string `json:"statusPurpose"`
+	StatusListIndex      string `json:"statusListIndex"`
+	StatusListCredential string `json:"statusListCredential"`
+}
+
+// CreateStatusList creates an empty status list owned by the submitting issuer. A size of 0 selects
+// DefaultStatusListSize; other sizes must be a multiple of 8.
+func (s *SmartContract) CreateStatusList(ctx contractapi.TransactionContextInterface, listID string, credentialURL string, purpose string, size uint) (*StatusList, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	if listID == "" || credentialURL == "" {
+		return nil, fmt.Errorf("status list ID and credential URL must not be empty")
+	}
+	if purpose != StatusPurposeRevocation && purpose != StatusPurposeSuspension {
+		return nil, fmt.Errorf("invalid status purpose: %s", purpose)
+	}
+	if size == 0 {
+		size = DefaultStatusListSize
+	}
+	if size%8 != 0 {
+		return nil, fmt.Errorf("status list size %d is not a multiple of 8", size)
+	}
+	existing, err := loadStatusList(ctx, listID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("status list %s already exists", listID)
+	}
+	owner, _, err := clientInserter(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	encodedList, err := EncodeStatusList(make([]byte, size/8))
+	if err != nil {
+		return nil, err
+	}
+
+	list := &StatusList{
+		ID:            listID,
+		CredentialURL: credentialURL,
+		Purpose:       purpose,
+		Owner:         *owner,
+		Size:          size,
+		EncodedList:   encodedList,
+	}
+	if err := saveStatusList(ctx, list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// AllocateStatusListIndex assigns the next free index of a status list to a credential and returns
+// the credentialStatus entry to embed in it. Allocating again for the same credential returns its index.
+func (s *SmartContract) AllocateStatusListIndex(ctx contractapi.TransactionContextInterface, listID string, credentialID string) (*StatusListEntry, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	if credentialID == "" {
+		return nil, fmt.Errorf("credential ID must not be empty")
+	}
+	list, err := loadOwnedStatusList(ctx, listID)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(statusListIndexObjectType, []string{listID, credentialID})
+	if err != nil {
+		return nil, fmt.Errorf("error creating status list index key: %v", err)
+	}
+	indexBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("error loading status list index: %v", err)
+	}
+	if indexBytes != nil {
+		index, err := strconv.ParseUint(string(indexBytes), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding status list index: %v", err)
+		}
+		return list.entry(uint(index)), nil
+	}
+
+	if list.NextIndex >= list.Size {
+		return nil, fmt.Errorf("status list %s is full", listID)
+	}
+	index := list.NextIndex
+	list.NextIndex++
+	if err := ctx.GetStub().PutState(key, []byte(strconv.FormatUint(uint64(index), 10))); err != nil {
+		return nil, fmt.Errorf("error saving status list index: %v", err)
+	}
+	if err := saveStatusList(ctx, list); err != nil {
+		return nil, err
+	}
+	return list.entry(index), nil
+}
+
+// SetStatusListStatus sets (true) or clears (false) the status bit of an allocated index.
+// Only the list owner and registry admins can change statuses.
+func (s *SmartContract) SetStatusListStatus(ctx contractapi.TransactionContextInterface, listID string, index uint, status bool) error {
+	if err := checkWritable(ctx); err != nil {
+		return err
+	}
+	list, err := loadOwnedStatusList(ctx, listID)
+	if err != nil {
+		return err
+	}
+	if index >= list.NextIndex {
+		return fmt.Errorf("index %d of status list %s is not allocated", index, listID)
+	}
+	updatedAt, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	bits, err := DecodeStatusList(list.EncodedList)
+	if err != nil {
+		return err
+	}
+	mask := byte(0x80) >> (index % 8)
+	if status {
+		bits[index/8] |= mask
+	} else {
+		bits[index/8] &^= mask
+	}
+	if list.EncodedList, err = EncodeStatusList(bits); err != nil {
+		return err
+	}
+	list.UpdatedAt = updatedAt
+	return saveStatusList(ctx, list)
+}
+
+// GetStatusListStatus returns the status bit of an index
+func (s *SmartContract) GetStatusListStatus(ctx contractapi.TransactionContextInterface, listID string, index uint) (bool, error) {
+	list, err := loadStatusList(ctx, listID)
+	if err != nil {
+		return false, err
+	}
+	if list == nil {
+		return false, fmt.Errorf("%w: status list %s not found", ErrNotFound, listID)
+	}
+	if index >= list.Size {
+		return false, fmt.Errorf("index %d is out of range of status list %s", index, listID)
+	}
+	bits, err := DecodeStatusList(list.EncodedList)
+	if err != nil {
+		return false, err
+	}
+	return bits[index/8]&(0x80>>(index%8)) != 0, nil
+}
+
+// GetStatusList returns a status list
+func (s *SmartContract) GetStatusList(ctx contractapi.TransactionContextInterface, listID string) (*StatusList, error) {
+	list, err := loadStatusList(ctx, listID)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		return nil, fmt.Errorf("%w: status list %s not found", ErrNotFound, listID)
+	}
+	return list, nil
+}
+
+// GenerateStatusListCredential issues the StatusList2021Credential of a status list as a JWT signed
+// with the issuer's key. The issuer must own the list.
+func (s *StakeholderManagementContract) GenerateStatusListCredential(ctx contractapi.TransactionContextInterface, listID string, issuerDID string) (string, error) {
+	list, err := loadStatusList(ctx, listID)
+	if err != nil {
+		return "", err
+	}
+	if list == nil {
+		return "", fmt.Errorf("%w: status list %s not found", ErrNotFound, listID)
+	}
+	if list.Owner.DID != issuerDID {
+		return "", fmt.Errorf("%w: status list %s is not owned by %s", ErrUnauthorized, listID, issuerDID)
+	}
+	if err := checkDIDsActive(ctx, issuerDID); err != nil {
+		return "", err
+	}
+	privateKey, err := s.loadPrivateKey(ctx, "issuer", issuerDID)
+	if err != nil {
+		return "", fmt.Errorf("failed to load private key: %v", err)
+	}
+	issuedAt, err := s.now(ctx)
+	if err != nil {
+		return "", err
+	}
+	vc := map[string]interface{}{
+		"@context":     []string{"https://www.w3.org/2018/credentials/v1", StatusList2021Context},
+		"id":           list.CredentialURL,
+		"type":         []string{"VerifiableCredential", StatusList2021CredentialType},
+		"issuer":       issuerDID,
+		"issuanceDate": issuedAt,
+		"credentialSubject": map[string]interface{}{
+			"id":            list.CredentialURL + "#list",
+			"type":          StatusList2021Type,
+			"statusPurpose": list.Purpose,
+			"encodedList":   list.EncodedList,
+		},
+	}
+	claims := jwt.MapClaims{
+		ClaimCredential: vc,
+		"iss":           issuerDID,
+		"sub":           list.CredentialURL + "#list",
+		"jti":           list.CredentialURL,
+		"nbf":           issuedAt.Unix(),
+	}
+	tokenString, err := signJWT(claims, privateKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign JWT: %v", err)
+	}
+	return tokenString, nil
+}
+
+// EncodeStatusList encodes a bitstring as the encodedList of a StatusList2021 credential,
+// GZIP-compressed and base64url-encoded without padding
+func EncodeStatusList(bits []byte) (string, error) {
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	if _, err := writer.Write(bits); err != nil {
+		return "", fmt.Errorf("error compressing status list: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("error compressing status list: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(compressed.Bytes()), nil
+}
+
+// DecodeStatusList decodes the encodedList of a StatusList2021 credential into its bitstring
+func DecodeStatusList(encodedList string) ([]byte, error) {
+	compressed, err := base64.RawURLEncoding.DecodeString(encodedList)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding status list: %v", err)
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing status list: %v", err)
+	}
+	defer reader.Close()
+	bits, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing status list: %v", err)
+	}
+	return bits, nil
+}
+
+// entry returns the credentialStatus entry of an index
+func (l *StatusList) entry(index uint) *StatusListEntry {
+	i := strconv.FormatUint(uint64(index), 10)
+	return &StatusListEntry{
+		ID:                   l.CredentialURL + "#" + i,
+		Type:                 StatusList2021EntryType,
+		StatusPurpose:        l.Purpose,
+		StatusListIndex:      i,
+		StatusListCredential: l.CredentialURL,
+	}
+}
+
+// loadOwnedStatusList loads a status list the submitting client owns or administers
+func loadOwnedStatusList(ctx contractapi.TransactionContextInterface, listID string) (*StatusList, error) {
+	list, err := loadStatusList(ctx, listID)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		return nil, fmt.Errorf("%w: status list %s not found", ErrNotFound, listID)
+	}
+	client, admin, err := clientInserter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !admin && *client != list.Owner {
+		return nil, fmt.Errorf("%w: status list %s is owned by %s", ErrUnauthorized, listID, list.Owner.DID)
+	}
+	return list, nil
+}
+
+func saveStatusList(ctx contractapi.TransactionContextInterface, list *StatusList) error {
+	key, err := ctx.GetStub().CreateCompositeKey(statusListObjectType, []string{list.ID})
+	if err != nil {
+		return fmt.Errorf("error creating status list key: %v", err)
+	}
+	listJSON, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(key, listJSON)
+}
+
+// loadStatusList returns nil if the list does not exist
+func loadStatusList(ctx contractapi.TransactionContextInterface, listID string) (*StatusList, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(statusListObjectType, []string{listID})
+	if err != nil {
+		return nil, fmt.Errorf("error creating status list key: %v", err)
+	}
+	listJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("error loading status list: %v", err)
+	}
+	if listJSON == nil {
+		return nil, nil
+	}
+	var list StatusList
+	if err := json.Unmarshal(listJSON, &list); err != nil {
+		return nil, fmt.Errorf("error decoding status list: %v", err)
+	}
+	return &list, nil
+}
@@ -0,0 +1,131 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"testing"
+)
+
+func TestStatusList_AllocateAndSet(t *testing.T) {
+	sim, _ := newRegistrySimulator(t)
+	issuer1 := newIssuerIdentity(t, "Org1MSP", "did:key:issuer1")
+	issuer2 := newIssuerIdentity(t, "Org2MSP", "did:key:issuer2")
+	admin, err := simulator.NewIdentityWithAttributes("RegistryMSP", "registry-admin", map[string]string{
+		cuckoofilter.DIDAttribute:           "did:key:admin",
+		cuckoofilter.RegistryAdminAttribute: "true",
+	})
+	require.NoError(t, err)
+
+	_, err = sim.Submit(issuer1, "CreateStatusList", "list-1", "https://issuer1.example/status/1", cuckoofilter.StatusPurposeRevocation, "16")
+	require.NoError(t, err)
+	_, err = sim.Submit(issuer1, "CreateStatusList", "list-1", "https://issuer1.example/status/1", cuckoofilter.StatusPurposeRevocation, "16")
+	require.Error(t, err)
+	_, err = sim.Submit(issuer1, "CreateStatusList", "list-2", "https://issuer1.example/status/2", cuckoofilter.StatusPurposeRevocation, "12")
+	require.Error(t, err)
+
+	// Indices are allocated in order, once per credential
+	for i, credentialID := range []string{"credential-0", "credential-1", "credential-0"} {
+		tx, err := sim.Submit(issuer1, "AllocateStatusListIndex", "list-1", credentialID)
+		require.NoError(t, err)
+		var entry cuckoofilter.StatusListEntry
+		require.NoError(t, json.Unmarshal(tx.Payload, &entry))
+		require.Equal(t, strconv.Itoa(i%2), entry.StatusListIndex)
+		require.Equal(t, cuckoofilter.StatusList2021EntryType, entry.Type)
+		require.Equal(t, "https://issuer1.example/status/1", entry.StatusListCredential)
+	}
+	_, err = sim.Submit(issuer2, "AllocateStatusListIndex", "list-1", "credential-2")
+	require.Error(t, err)
+
+	// Only allocated indices can be set, by the owner or an admin
+	_, err = sim.Submit(issuer1, "SetStatusListStatus", "list-1", "2", "true")
+	require.Error(t, err)
+	_, err = sim.Submit(issuer2, "SetStatusListStatus", "list-1", "1", "true")
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+	sameMSP, err := simulator.NewIdentity("Org1MSP", "issuer1-operator")
+	require.NoError(t, err)
+	_, err = sim.Submit(sameMSP, "SetStatusListStatus", "list-1", "1", "true")
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+	_, err = sim.Submit(issuer1, "SetStatusListStatus", "list-1", "1", "true")
+	require.NoError(t, err)
+	status, err := sim.Evaluate(issuer2, "GetStatusListStatus", "list-1", "1")
+	require.NoError(t, err)
+	require.Equal(t, "true", string(status))
+
+	listJSON, err := sim.Evaluate(issuer2, "GetStatusList", "list-1")
+	require.NoError(t, err)
+	var list cuckoofilter.StatusList
+	require.NoError(t, json.Unmarshal(listJSON, &list))
+	bits, err := cuckoofilter.DecodeStatusList(list.EncodedList)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x40, 0x00}, bits)
+	require.Equal(t, uint(2), list.NextIndex)
+
+	_, err = sim.Submit(admin, "SetStatusListStatus", "list-1", "1", "false")
+	require.NoError(t, err)
+	status, err = sim.Evaluate(issuer2, "GetStatusListStatus", "list-1", "1")
+	require.NoError(t, err)
+	require.Equal(t, "false", string(status))
+}
+
+func TestStatusList_Full(t *testing.T) {
+	sim, _ := newRegistrySimulator(t)
+	issuer := newIssuerIdentity(t, "Org1MSP", "did:key:issuer1")
+	_, err := sim.Submit(issuer, "CreateStatusList", "list-1", "https://issuer1.example/status/1", cuckoofilter.StatusPurposeSuspension, "8")
+	require.NoError(t, err)
+	for i := 0; i < 8; i++ {
+		_, err = sim.Submit(issuer, "AllocateStatusListIndex", "list-1", "credential-"+strconv.Itoa(i))
+		require.NoError(t, err)
+	}
+	_, err = sim.Submit(issuer, "AllocateStatusListIndex", "list-1", "credential-8")
+	require.ErrorContains(t, err, "is full")
+}
+
+func TestGenerateStatusListCredential(t *testing.T) {
+	useStakeholderDir(t)
+	sim, err := simulator.New("credential-management", &cuckoofilter.SmartContract{}, &cuckoofilter.StakeholderManagementContract{})
+	require.NoError(t, err)
+	admin, err := simulator.NewIdentity("Org1MSP", "registry-admin")
+	require.NoError(t, err)
+	_, err = sim.Submit(newRegistryAdmin(t), "SmartContract:Init", "", "100", "4", "0")
+	require.NoError(t, err)
+
+	tx, err := sim.Submit(admin, "StakeholderManagementContract:GenerateDID", "issuer")
+	require.NoError(t, err)
+	var did cuckoofilter.DIDResponse
+	require.NoError(t, json.Unmarshal(tx.Payload, &did))
+	issuer := newIssuerIdentity(t, "Org1MSP", did.DID)
+
+	_, err = sim.Submit(issuer, "SmartContract:CreateStatusList", "list-1", "https://issuer.example/status/1", cuckoofilter.StatusPurposeRevocation, "0")
+	require.NoError(t, err)
+	for i := 0; i < 4; i++ {
+		_, err = sim.Submit(issuer, "SmartContract:AllocateStatusListIndex", "list-1", "credential-"+strconv.Itoa(i))
+		require.NoError(t, err)
+	}
+	_, err = sim.Submit(issuer, "SmartContract:SetStatusListStatus", "list-1", "3", "true")
+	require.NoError(t, err)
+
+	_, err = sim.Evaluate(issuer, "StakeholderManagementContract:GenerateStatusListCredential", "list-1", "did:key:other")
+	require.Error(t, err)
+	token, err := sim.Evaluate(issuer, "StakeholderManagementContract:GenerateStatusListCredential", "list-1", did.DID)
+	require.NoError(t, err)
+
+	// A verifier decodes the list from the credential without knowing about cuckoo filters
+	claims := jwt.MapClaims{}
+	_, _, err = new(jwt.Parser).ParseUnverified(string(token), claims)
+	require.NoError(t, err)
+	require.Equal(t, did.DID, claims["iss"])
+	vc := claims[cuckoofilter.ClaimCredential].(map[string]interface{})
+	require.Contains(t, vc["type"], cuckoofilter.StatusList2021CredentialType)
+	subject := vc["credentialSubject"].(map[string]interface{})
+	require.Equal(t, cuckoofilter.StatusList2021Type, subject["type"])
+	require.Equal(t, cuckoofilter.StatusPurposeRevocation, subject["statusPurpose"])
+
+	bits, err := cuckoofilter.DecodeStatusList(subject["encodedList"].(string))
+	require.NoError(t, err)
+	require.Len(t, bits, cuckoofilter.DefaultStatusListSize/8)
+	require.Equal(t, byte(0x10), bits[0])
+}
@@ -0,0 +1,66 @@
+package cuckoofilter
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ErrRawCredentialNotAllowed is returned in strict mode when a payload longer than a canonical fingerprint,
+// such as a raw JWT, is submitted for revocation
+var ErrRawCredentialNotAllowed = fmt.Errorf("%w: raw credentials must not be submitted to the registry, submit the fingerprint", ErrInvalidArgument)
+
+// SetStrictMode switches strict mode on or off. In strict mode Insert and BatchInsert only accept canonical
+// FingerprintV1 fingerprints, so credentials and personal data cannot end up on the ledger. Registry
+// admins only.
+func (s *SmartContract) SetStrictMode(ctx contractapi.TransactionContextInterface, enabled bool) (*RegistryConfig, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	if err := checkRegistryAdmin(ctx, "change strict mode"); err != nil {
+		return nil, err
+	}
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if config.StrictMode == enabled {
+		return config, nil
+	}
+
+	config.Version++
+	config.StrictMode = enabled
+	if err := saveRegistryConfig(ctx, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// checkStrictMode rejects data items that are not canonical fingerprints while strict mode is enabled
+func checkStrictMode(ctx contractapi.TransactionContextInterface, dataItems ...string) error {
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if !config.StrictMode {
+		return nil
+	}
+	for _, data := range dataItems {
+		if err := checkCanonicalFingerprint(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkCanonicalFingerprint checks that data is a FingerprintV1 fingerprint: lowercase hex of fingerprintV1Length bytes
+func checkCanonicalFingerprint(data string) error {
+	if len(data) > 2*fingerprintV1Length {
+		return ErrRawCredentialNotAllowed
+	}
+	decoded, err := hex.DecodeString(data)
+	if err != nil || len(decoded) != fingerprintV1Length || hex.EncodeToString(decoded) != data {
+		return fmt.Errorf("'%s' is not a canonical %s fingerprint of %d lowercase hex characters", data, FingerprintV1, 2*fingerprintV1Length)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package cuckoofilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"time"
+)
+
+// subjectObjectType is the composite key prefix of the subject index, subject~<did>~<credentialID>,
+// so the credentials of a subject are found without scanning the whole registry
+const subjectObjectType = "subject"
+
+// MaxSubjectPageSize bounds the page size of GetCredentialsBySubject
+const MaxSubjectPageSize = 1000
+
+// SubjectEntry links a credential's registry entry to the DID of its subject
+type SubjectEntry struct {
+	SubjectDID   string    `json:"subjectDID"`
+	CredentialID string    `json:"credentialId"`
+	IndexedAt    time.Time `json:"indexedAt"`
+	IndexedBy    string    `json:"indexedBy,omitempty" metadata:",optional"` // DID of the issuer that recorded the entry
+}
+
+// SubjectCredentialsPage is one page of the credentials of a subject
+type SubjectCredentialsPage struct {
+	Entries  []SubjectEntry `json:"entries"`
+	Bookmark string         `json:"bookmark"` // Passed to the next call, empty after the last page
+}
+
+// SubjectRevocationResult reports the outcome of RevokeAllForSubject
+type SubjectRevocationResult struct {
+	Revoked        []string `json:"revoked"`
+	AlreadyRevoked int      `json:"alreadyRevoked"`
+}
+
+// InsertForSubject revokes a credential like Insert and records it in the index of its subject,
+// together with the DID of the submitting client
+func (s *SmartContract) InsertForSubject(ctx contractapi.TransactionContextInterface, subjectDID string, data string) error {
+	if subjectDID == "" {
+		return fmt.Errorf("subject DID must not be empty")
+	}
+	if err := s.Insert(ctx, DefaultFilterID, data); err != nil {
+		return err
+	}
+	client, _, err := clientInserter(ctx)
+	if err != nil {
+		return err
+	}
+	return indexSubject(ctx, subjectDID, data, client.DID)
+}
+
+// GetCredentialsBySubject returns a page of the indexed credentials of a subject DID,
+// e.g. to answer GDPR access requests
+func (s *SmartContract) GetCredentialsBySubject(ctx contractapi.TransactionContextInterface, subjectDID string, pageSize int32, bookmark string) (*SubjectCredentialsPage, error) {
+	if pageSize <= 0 || pageSize > MaxSubjectPageSize {
+		return nil, fmt.Errorf("page size must be between 1 and %d", MaxSubjectPageSize)
+	}
+	iterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(subjectObjectType, []string{subjectDID}, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("error reading subject index: %v", err)
+	}
+	defer iterator.Close()
+
+	page := &SubjectCredentialsPage{Entries: []SubjectEntry{}, Bookmark: metadata.GetBookmark()}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error reading subject index: %v", err)
+		}
+		var subjectEntry SubjectEntry
+		if err := json.Unmarshal(entry.Value, &subjectEntry); err != nil {
+			return nil, fmt.Errorf("error decoding subject entry: %v", err)
+		}
+		page.Entries = append(page.Entries, subjectEntry)
+	}
+	return page, nil
+}
+
+// RevokeAllForSubject revokes every indexed credential of a subject DID that is not revoked yet.
+// The number of credentials to revoke is bounded by the configured batch limit. Issuers may only
+// revoke the entries they recorded, registry admins revoke every entry.
+func (s *SmartContract) RevokeAllForSubject(ctx contractapi.TransactionContextInterface, subjectDID string) (*SubjectRevocationResult, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	if err := checkCapability(ctx, CapabilityActionRevoke, ""); err != nil {
+		return nil, err
+	}
+	client, admin, err := clientInserter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(subjectObjectType, []string{subjectDID})
+	if err != nil {
+		return nil, fmt.Errorf("error reading subject index: %v", err)
+	}
+	defer iterator.Close()
+
+	filter, err := s.LoadFilterState(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error loading filter state: %v", err)
+	}
+	result := &SubjectRevocationResult{Revoked: []string{}}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error reading subject index: %v", err)
+		}
+		var subjectEntry SubjectEntry
+		if err := json.Unmarshal(entry.Value, &subjectEntry); err != nil {
+			return nil, fmt.Errorf("error decoding subject entry: %v", err)
+		}
+		data := subjectEntry.CredentialID
+		if filter.Lookup([]byte(data)) {
+			result.AlreadyRevoked++
+			continue
+		}
+		// Entries without a recorded issuer predate issuer tracking and are left to admins
+		if !admin && subjectEntry.IndexedBy != client.DID {
+			return nil, fmt.Errorf("%w: credential '%s' of %s was not recorded by %s", ErrUnauthorized, data, subjectDID, client.DID)
+		}
+		result.Revoked = append(result.Revoked, data)
+	}
+	if err := checkBatchSize(ctx, len(result.Revoked)); err != nil {
+		return nil, err
+	}
+	if err := checkStrictMode(ctx, result.Revoked...); err != nil {
+		return nil, err
+	}
+
+	for _, data := range result.Revoked {
+		var inserted bool
+		if filter, inserted = insertGrowing(filter, []byte(data)); !inserted {
+			return nil, insertFailure(filter, []byte(data), fmt.Sprintf("data '%s'", data))
+		}
+	}
+	if err := recordInserter(ctx, filterScope(DefaultFilterID), result.Revoked...); err != nil {
+		return nil, err
+	}
+	if err := recordLifecycleEvent(ctx, LifecycleRevoked, "all credentials of "+subjectDID, result.Revoked...); err != nil {
+		return nil, err
+	}
+	if err := recordTransientRevocationAudit(ctx, LifecycleRevoked, result.Revoked...); err != nil {
+		return nil, err
+	}
+	if err := s.SaveFilterState(ctx, filter); err != nil {
+		return nil, err
+	}
+	if err := emitFilterChanged(ctx, DefaultFilterID, FilterChangeInserted, filter, result.Revoked); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// indexSubject records a credential in the index of its subject, keeping the first indexing time and issuer
+func indexSubject(ctx contractapi.TransactionContextInterface, subjectDID string, data string, issuerDID string) error {
+	key, err := ctx.GetStub().CreateCompositeKey(subjectObjectType, []string{subjectDID, data})
+	if err != nil {
+		return fmt.Errorf("error creating subject key: %v", err)
+	}
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("error loading subject entry: %v", err)
+	}
+	if existing != nil {
+		return nil
+	}
+	indexedAt, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	entryJSON, err := json.Marshal(SubjectEntry{SubjectDID: subjectDID, CredentialID: data, IndexedAt: indexedAt, IndexedBy: issuerDID})
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(key, entryJSON); err != nil {
+		return fmt.Errorf("error saving subject entry: %v", err)
+	}
+	return nil
+}
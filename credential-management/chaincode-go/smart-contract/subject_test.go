@@ -0,0 +1,124 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"testing"
+)
+
+func subjectPage(t *testing.T, sim *simulator.Simulator, identity *simulator.Identity, subjectDID string, pageSize int, bookmark string) cuckoofilter.SubjectCredentialsPage {
+	pageJSON, err := sim.Evaluate(identity, "GetCredentialsBySubject", subjectDID, strconv.Itoa(pageSize), bookmark)
+	require.NoError(t, err)
+	var page cuckoofilter.SubjectCredentialsPage
+	require.NoError(t, json.Unmarshal(pageJSON, &page))
+	return page
+}
+
+func TestGetCredentialsBySubject_PaginatesLargeSubjects(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	for i := 0; i < 25; i++ {
+		_, err := sim.Submit(admin, "InsertForSubject", "did:key:holder", fmt.Sprintf("credential-%02d", i))
+		require.NoError(t, err)
+	}
+	_, err := sim.Submit(admin, "InsertForSubject", "did:key:other", "credential-other")
+	require.NoError(t, err)
+
+	var credentials []string
+	bookmark := ""
+	pages := 0
+	for {
+		page := subjectPage(t, sim, admin, "did:key:holder", 10, bookmark)
+		pages++
+		for _, entry := range page.Entries {
+			require.Equal(t, "did:key:holder", entry.SubjectDID)
+			credentials = append(credentials, entry.CredentialID)
+		}
+		if page.Bookmark == "" {
+			break
+		}
+		bookmark = page.Bookmark
+	}
+	require.Equal(t, 3, pages)
+	require.Len(t, credentials, 25)
+	require.Equal(t, "credential-00", credentials[0])
+	require.Equal(t, "credential-24", credentials[24])
+
+	_, err = sim.Evaluate(admin, "GetCredentialsBySubject", "did:key:holder", "0", "")
+	require.Error(t, err)
+}
+
+func TestRevokeAllForSubject(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	for _, credential := range []string{"credential-1", "credential-2", "credential-3"} {
+		_, err := sim.Submit(admin, "InsertForSubject", "did:key:holder", credential)
+		require.NoError(t, err)
+	}
+	for _, credential := range []string{"credential-2", "credential-3"} {
+		_, err := sim.Submit(admin, "Delete", "", credential)
+		require.NoError(t, err)
+	}
+	_, err := sim.Submit(admin, "Delete", "", "credential-1")
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "Insert", "", "credential-1")
+	require.NoError(t, err)
+
+	tx, err := sim.Submit(admin, "RevokeAllForSubject", "did:key:holder")
+	require.NoError(t, err)
+	var result cuckoofilter.SubjectRevocationResult
+	require.NoError(t, json.Unmarshal(tx.Payload, &result))
+	require.Equal(t, []string{"credential-2", "credential-3"}, result.Revoked)
+	require.Equal(t, 1, result.AlreadyRevoked)
+	require.Equal(t, cuckoofilter.FilterChangedEvent, tx.Event.EventName)
+	var change cuckoofilter.FilterChange
+	require.NoError(t, json.Unmarshal(tx.Event.Payload, &change))
+	require.Equal(t, cuckoofilter.FilterChange{
+		Action:       cuckoofilter.FilterChangeInserted,
+		Fingerprints: []string{"credential-2", "credential-3"},
+		Count:        3,
+	}, change)
+
+	requireStatus(t, sim, admin, "credential-2", cuckoofilter.CredentialStatusRevoked)
+	requireStatus(t, sim, admin, "credential-3", cuckoofilter.CredentialStatusRevoked)
+}
+
+func TestRevokeAllForSubject_OnlyRecordingIssuers(t *testing.T) {
+	sim, _ := newRegistrySimulator(t)
+	issuer1 := newIssuerIdentity(t, "Org1MSP", "did:key:issuer1")
+	issuer2 := newIssuerIdentity(t, "Org2MSP", "did:key:issuer2")
+	for issuer, credential := range map[*simulator.Identity]string{issuer1: "credential-1", issuer2: "credential-2"} {
+		_, err := sim.Submit(issuer, "InsertForSubject", "did:key:holder", credential)
+		require.NoError(t, err)
+		_, err = sim.Submit(issuer, "Delete", "", credential)
+		require.NoError(t, err)
+	}
+	page := subjectPage(t, sim, issuer1, "did:key:holder", 10, "")
+	require.Equal(t, "did:key:issuer1", page.Entries[0].IndexedBy)
+
+	_, err := sim.Submit(issuer1, "RevokeAllForSubject", "did:key:holder")
+	require.ErrorContains(t, err, cuckoofilter.UnauthorizedErrorCode)
+	requireStatus(t, sim, issuer1, "credential-1", cuckoofilter.CredentialStatusActive)
+
+	tx, err := sim.Submit(newRegistryAdmin(t), "RevokeAllForSubject", "did:key:holder")
+	require.NoError(t, err)
+	var result cuckoofilter.SubjectRevocationResult
+	require.NoError(t, json.Unmarshal(tx.Payload, &result))
+	require.Equal(t, []string{"credential-1", "credential-2"}, result.Revoked)
+}
+
+func TestRevokeAllForSubject_StrictMode(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	_, err := sim.Submit(admin, "InsertForSubject", "did:key:holder", "credential-1")
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "Delete", "", "credential-1")
+	require.NoError(t, err)
+	_, err = sim.Submit(newRegistryAdmin(t), "SetStrictMode", "true")
+	require.NoError(t, err)
+
+	_, err = sim.Submit(admin, "RevokeAllForSubject", "did:key:holder")
+	require.ErrorContains(t, err, "canonical")
+	requireStatus(t, sim, admin, "credential-1", cuckoofilter.CredentialStatusActive)
+}
@@ -0,0 +1,278 @@
+package cuckoofilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"sort"
+	"time"
+)
+
+// suspensionObjectType is the composite key prefix of the suspensions of a filter, suspension~<state name>~<fingerprint>.
+// Suspensions of the default filter are kept in its private data collection if one is configured.
+const suspensionObjectType = "suspension"
+
+// SuspendedUntilTransientKey is the transient data key of an RFC 3339 time that makes the items of
+// Insert and BatchInsert suspensions: they are revoked until then and removed by SweepExpired after
+const SuspendedUntilTransientKey = "suspendedUntil"
+
+// CredentialStatusSuspended is returned by LookupStatus and LookupRevocation for suspended credentials
+const CredentialStatusSuspended = "suspended"
+
+// Suspension is a time-bounded revocation
+type Suspension struct {
+	FilterID    string    `json:"filterId"`
+	Fingerprint string    `json:"fingerprint"`
+	Until       time.Time `json:"until"`
+	TxID        string    `json:"txId"` // Transaction that inserted the fingerprint
+}
+
+// RevocationLookup is the result of LookupRevocation
+type RevocationLookup struct {
+	Status         string    `json:"status"`         // CredentialStatusRevoked, CredentialStatusSuspended or CredentialStatusActive
+	SuspendedUntil time.Time `json:"suspendedUntil"` // End of the suspension of a suspended credential, zero otherwise
+}
+
+// SweepResult lists the suspensions SweepExpired lifted
+type SweepResult struct {
+	Lifted []string `json:"lifted"` // Fingerprints removed from the filter
+}
+
+// LookupRevocation checks data in a filter and tells a permanent revocation from a suspension. A
+// suspension that has lapsed but was not swept yet is reported as active, although Lookup still finds
+// it in the filter.
+func (s *SmartContract) LookupRevocation(ctx contractapi.TransactionContextInterface, filterID string, data string) (*RevocationLookup, error) {
+	revoked, err := s.Lookup(ctx, filterID, data)
+	if err != nil {
+		return nil, err
+	}
+	if !revoked {
+		return &RevocationLookup{Status: CredentialStatusActive}, nil
+	}
+	suspension, err := loadSuspension(ctx, filterID, data)
+	if err != nil || suspension == nil {
+		return &RevocationLookup{Status: CredentialStatusRevoked}, err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !now.Before(suspension.Until) {
+		return &RevocationLookup{Status: CredentialStatusActive}, nil
+	}
+	return &RevocationLookup{Status: CredentialStatusSuspended, SuspendedUntil: suspension.Until}, nil
+}
+
+// SweepExpired removes the suspensions of a filter that lapsed before the transaction timestamp from
+// the filter. Clients need the unrevoke capability of the filter, as the timestamp is set by the client.
+// Issuers sweep the suspensions they inserted, registry admins those of every issuer; the suspensions
+// of other issuers are left for them.
+func (s *SmartContract) SweepExpired(ctx contractapi.TransactionContextInterface, filterID string) (*SweepResult, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	if err := checkCapability(ctx, CapabilityActionUnrevoke, filterID); err != nil {
+		return nil, err
+	}
+	client, admin, err := clientInserter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	collection, err := suspensionCollection(ctx, filterID)
+	if err != nil {
+		return nil, err
+	}
+	lapsed, err := lapsedSuspensions(ctx, collection, filterID, now)
+	if err != nil {
+		return nil, err
+	}
+	result := &SweepResult{Lifted: []string{}}
+	if len(lapsed) == 0 {
+		return result, nil
+	}
+
+	filter, err := s.loadFilter(ctx, filterID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading filter state: %v", err)
+	}
+	keys := make([]string, 0, len(lapsed))
+	for key := range lapsed {
+		keys = append(keys, key)
+	}
+	// Delete in a fixed order, so every peer writes the same
+	sort.Strings(keys)
+	for _, key := range keys {
+		suspension := lapsed[key]
+		current, err := currentSuspension(ctx, suspension)
+		if err != nil {
+			return nil, err
+		}
+		if current && !admin {
+			inserter, err := loadInserter(ctx, filterScope(filterID), suspension.Fingerprint)
+			if err != nil {
+				return nil, err
+			}
+			if inserter == nil || *inserter != *client {
+				continue
+			}
+		}
+		if current && filter.Delete([]byte(suspension.Fingerprint)) {
+			if err := deleteInserter(ctx, filterScope(filterID), suspension.Fingerprint); err != nil {
+				return nil, err
+			}
+			result.Lifted = append(result.Lifted, suspension.Fingerprint)
+		}
+		if err := delCollectionState(ctx, collection, key); err != nil {
+			return nil, fmt.Errorf("error deleting suspension: %v", err)
+		}
+	}
+	if len(result.Lifted) == 0 {
+		return result, nil
+	}
+	if err := recordLifecycleEvent(ctx, LifecycleUnrevoked, filterDetails(filterID), result.Lifted...); err != nil {
+		return nil, err
+	}
+	if err := recordRevocationAudit(ctx, LifecycleUnrevoked, "suspension lapsed", result.Lifted...); err != nil {
+		return nil, err
+	}
+	if err := s.saveFilter(ctx, filterID, filter); err != nil {
+		return nil, fmt.Errorf("error saving filter state: %v", err)
+	}
+	if err := emitFilterChanged(ctx, filterID, FilterChangeDeleted, filter, result.Lifted); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// lapsedSuspensions returns the suspensions of a filter that lapsed before now by key
+func lapsedSuspensions(ctx contractapi.TransactionContextInterface, collection string, filterID string, now time.Time) (map[string]*Suspension, error) {
+	iterator, err := getCollectionStateByPartialCompositeKey(ctx, collection, suspensionObjectType, []string{filterStateName(filterID)})
+	if err != nil {
+		return nil, fmt.Errorf("error reading suspensions: %v", err)
+	}
+	defer iterator.Close()
+
+	lapsed := make(map[string]*Suspension)
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error reading suspensions: %v", err)
+		}
+		var suspension Suspension
+		if err := json.Unmarshal(result.Value, &suspension); err != nil {
+			return nil, fmt.Errorf("error decoding suspension: %v", err)
+		}
+		if !now.Before(suspension.Until) {
+			lapsed[result.Key] = &suspension
+		}
+	}
+	return lapsed, nil
+}
+
+// transientSuspension returns the end of the suspension passed as transient data, nil for a permanent
+// revocation. It must be after the transaction timestamp.
+func transientSuspension(ctx contractapi.TransactionContextInterface) (*time.Time, error) {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("error reading transient data: %v", err)
+	}
+	value := transient[SuspendedUntilTransientKey]
+	if len(value) == 0 {
+		return nil, nil
+	}
+	until, err := time.Parse(time.RFC3339, string(value))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s must be an RFC 3339 time: %v", ErrInvalidArgument, SuspendedUntilTransientKey, err)
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !until.After(now) {
+		return nil, fmt.Errorf("%w: suspension must end after the transaction timestamp", ErrInvalidArgument)
+	}
+	until = until.UTC()
+	return &until, nil
+}
+
+// recordSuspensions records the fingerprints inserted by the current transaction as suspended until until
+func recordSuspensions(ctx contractapi.TransactionContextInterface, filterID string, until time.Time, fingerprints ...string) error {
+	collection, err := suspensionCollection(ctx, filterID)
+	if err != nil {
+		return err
+	}
+	for _, fingerprint := range fingerprints {
+		suspension := &Suspension{FilterID: filterID, Fingerprint: fingerprint, Until: until, TxID: ctx.GetStub().GetTxID()}
+		suspensionJSON, err := json.Marshal(suspension)
+		if err != nil {
+			return err
+		}
+		key, err := ctx.GetStub().CreateCompositeKey(suspensionObjectType, []string{filterStateName(filterID), fingerprint})
+		if err != nil {
+			return fmt.Errorf("error creating suspension key: %v", err)
+		}
+		if err := putCollectionState(ctx, collection, key, suspensionJSON); err != nil {
+			return fmt.Errorf("error saving suspension: %v", err)
+		}
+	}
+	return nil
+}
+
+// loadSuspension returns the suspension of a fingerprint, nil if it is not suspended
+func loadSuspension(ctx contractapi.TransactionContextInterface, filterID string, fingerprint string) (*Suspension, error) {
+	collection, err := suspensionCollection(ctx, filterID)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(suspensionObjectType, []string{filterStateName(filterID), fingerprint})
+	if err != nil {
+		return nil, fmt.Errorf("error creating suspension key: %v", err)
+	}
+	suspensionJSON, err := getCollectionState(ctx, collection, key)
+	if err != nil {
+		return nil, fmt.Errorf("error loading suspension: %v", err)
+	}
+	if suspensionJSON == nil {
+		return nil, nil
+	}
+	var suspension Suspension
+	if err := json.Unmarshal(suspensionJSON, &suspension); err != nil {
+		return nil, fmt.Errorf("error decoding suspension: %v", err)
+	}
+	current, err := currentSuspension(ctx, &suspension)
+	if err != nil || !current {
+		return nil, err
+	}
+	return &suspension, nil
+}
+
+// currentSuspension reports whether a suspension belongs to the last revocation of its fingerprint.
+// Suspensions are not removed when their fingerprint is deleted, so the revocation audit trail tells
+// them apart from a later permanent revocation.
+func currentSuspension(ctx contractapi.TransactionContextInterface, suspension *Suspension) (bool, error) {
+	history, err := revocationHistory(ctx, suspension.Fingerprint)
+	if err != nil {
+		return false, err
+	}
+	if len(history) == 0 {
+		return false, nil
+	}
+	last := history[len(history)-1]
+	return last.Action == LifecycleRevoked && last.TxID == suspension.TxID, nil
+}
+
+// suspensionCollection returns the private data collection of the suspensions of a filter, empty for the public state
+func suspensionCollection(ctx contractapi.TransactionContextInterface, filterID string) (string, error) {
+	if filterID != DefaultFilterID {
+		return "", nil
+	}
+	config, err := loadRegistryConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	return config.PrivateCollection, nil
+}
@@ -0,0 +1,129 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// suspendedUntil returns the transient data suspending the inserted items until until
+func suspendedUntil(until time.Time) map[string][]byte {
+	return map[string][]byte{cuckoofilter.SuspendedUntilTransientKey: []byte(until.Format(time.RFC3339))}
+}
+
+// requireRevocation checks the result of LookupRevocation
+func requireRevocation(t *testing.T, sim *simulator.Simulator, identity *simulator.Identity, filterID string, data string, expected string) *cuckoofilter.RevocationLookup {
+	resultJSON, err := sim.Evaluate(identity, "LookupRevocation", filterID, data)
+	require.NoError(t, err)
+	var result cuckoofilter.RevocationLookup
+	require.NoError(t, json.Unmarshal(resultJSON, &result))
+	require.Equal(t, expected, result.Status, data)
+	return &result
+}
+
+func TestSuspension(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	sim.SetTime(now)
+	until := now.Add(24 * time.Hour)
+
+	_, err := sim.SubmitTransient(admin, suspendedUntil(until), "Insert", "", "credential-1")
+	require.NoError(t, err)
+	_, err = sim.SubmitTransient(admin, suspendedUntil(until.Add(time.Hour)), "BatchInsert", "", `["credential-2"]`)
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "Insert", "", "credential-3")
+	require.NoError(t, err)
+
+	result := requireRevocation(t, sim, admin, "", "credential-1", cuckoofilter.CredentialStatusSuspended)
+	require.True(t, until.Equal(result.SuspendedUntil))
+	requireRevocation(t, sim, admin, "", "credential-3", cuckoofilter.CredentialStatusRevoked)
+	requireRevocation(t, sim, admin, "", "credential-4", cuckoofilter.CredentialStatusActive)
+	status, err := sim.Evaluate(admin, "LookupStatus", "credential-1")
+	require.NoError(t, err)
+	require.Equal(t, cuckoofilter.CredentialStatusSuspended, string(status))
+
+	// Nothing has lapsed yet
+	tx, err := sim.Submit(admin, "SweepExpired", "")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"lifted":[]}`, string(tx.Payload))
+
+	// A lapsed suspension no longer counts, and the sweep removes it from the filter
+	sim.SetTime(until.Add(time.Minute))
+	requireRevocation(t, sim, admin, "", "credential-1", cuckoofilter.CredentialStatusActive)
+	requireLookup(t, sim, admin, "", "credential-1", true)
+	tx, err = sim.Submit(admin, "SweepExpired", "")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"lifted":["credential-1"]}`, string(tx.Payload))
+	requireLookup(t, sim, admin, "", "credential-1", false)
+	requireRevocation(t, sim, admin, "", "credential-2", cuckoofilter.CredentialStatusSuspended)
+	requireRevocation(t, sim, admin, "", "credential-3", cuckoofilter.CredentialStatusRevoked)
+
+	sim.SetTime(until.Add(2 * time.Hour))
+	tx, err = sim.Submit(admin, "SweepExpired", "")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"lifted":["credential-2"]}`, string(tx.Payload))
+	requireLookup(t, sim, admin, "", "credential-3", true)
+}
+
+func TestSuspension_PermanentRevocationAfterUnsuspend(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	sim.SetTime(now)
+	_, err := sim.SubmitTransient(admin, suspendedUntil(now.Add(time.Hour)), "Insert", "", "credential-1")
+	require.NoError(t, err)
+	// The suspension is lifted early and the credential revoked for good
+	_, err = sim.Submit(admin, "Delete", "", "credential-1")
+	require.NoError(t, err)
+	_, err = sim.Submit(admin, "Insert", "", "credential-1")
+	require.NoError(t, err)
+	requireRevocation(t, sim, admin, "", "credential-1", cuckoofilter.CredentialStatusRevoked)
+
+	sim.SetTime(now.Add(2 * time.Hour))
+	tx, err := sim.Submit(admin, "SweepExpired", "")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"lifted":[]}`, string(tx.Payload))
+	requireLookup(t, sim, admin, "", "credential-1", true)
+}
+
+func TestSuspension_InvalidExpiry(t *testing.T) {
+	sim, admin := newRegistrySimulator(t)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	sim.SetTime(now)
+	_, err := sim.SubmitTransient(admin, suspendedUntil(now.Add(-time.Hour)), "Insert", "", "credential-1")
+	require.ErrorContains(t, err, cuckoofilter.InvalidArgumentErrorCode)
+	_, err = sim.SubmitTransient(admin, map[string][]byte{cuckoofilter.SuspendedUntilTransientKey: []byte("tomorrow")}, "BatchInsert", "", `["credential-1"]`)
+	require.ErrorContains(t, err, cuckoofilter.InvalidArgumentErrorCode)
+	requireLookup(t, sim, admin, "", "credential-1", false)
+}
+
+func TestSweepExpired_IssuerOrAdmin(t *testing.T) {
+	sim, client := newRegistrySimulator(t)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	sim.SetTime(now)
+	issuer1 := newIssuerIdentity(t, "Org1MSP", "did:key:issuer1")
+	issuer2 := newIssuerIdentity(t, "Org2MSP", "did:key:issuer2")
+	_, err := sim.SubmitTransient(issuer1, suspendedUntil(now.Add(time.Hour)), "Insert", "", "credential-1")
+	require.NoError(t, err)
+	_, err = sim.SubmitTransient(issuer2, suspendedUntil(now.Add(time.Hour)), "Insert", "", "credential-2")
+	require.NoError(t, err)
+	sim.SetTime(now.Add(2 * time.Hour))
+
+	// Clients only lift the suspensions they inserted
+	tx, err := sim.Submit(client, "SweepExpired", "")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"lifted":[]}`, string(tx.Payload))
+	tx, err = sim.Submit(issuer2, "SweepExpired", "")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"lifted":["credential-2"]}`, string(tx.Payload))
+	requireLookup(t, sim, client, "", "credential-1", true)
+	_, err = sim.Evaluate(client, "GetInserter", "", "credential-2")
+	require.Error(t, err)
+
+	tx, err = sim.Submit(newRegistryAdmin(t), "SweepExpired", "")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"lifted":["credential-1"]}`, string(tx.Payload))
+	requireLookup(t, sim, client, "", "credential-1", false)
+}
@@ -0,0 +1,163 @@
+package cuckoofilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"time"
+)
+
+// trustedIssuerObjectType is the composite key prefix of the issuer trust registry, trustedIssuer~<did>
+const trustedIssuerObjectType = "trustedIssuer"
+
+var (
+	// ErrUntrustedIssuer is returned when a credential is verified whose issuer is not in the trust registry
+	ErrUntrustedIssuer = fmt.Errorf("%w: issuer is not in the trust registry", ErrFailedPrecondition)
+	// ErrNotTrustRegistryAdmin is returned when a client that is not a trust registry admin changes the registry
+	ErrNotTrustRegistryAdmin = fmt.Errorf("%w: only a trust registry admin may change the trusted issuers", ErrUnauthorized)
+)
+
+// TrustRegistryContract keeps the DIDs of the issuers whose credentials VerifyingCredential accepts
+type TrustRegistryContract struct {
+	contractapi.Contract
+	// MSPs whose clients may add and remove trusted issuers. If empty, clients with the registry admin
+	// attribute may.
+	AdminMSPs []string
+}
+
+// TrustedIssuer is an entry of the issuer trust registry
+type TrustedIssuer struct {
+	DID     string    `json:"did"`
+	AddedAt time.Time `json:"addedAt"`
+	AddedBy Inserter  `json:"addedBy"`
+	TxID    string    `json:"txId"`
+}
+
+// AddTrustedIssuer registers an issuer DID, so VerifyingCredential accepts the credentials it issued
+func (s *TrustRegistryContract) AddTrustedIssuer(ctx contractapi.TransactionContextInterface, issuerDID string) (*TrustedIssuer, error) {
+	if err := checkWritable(ctx); err != nil {
+		return nil, err
+	}
+	if issuerDID == "" {
+		return nil, fmt.Errorf("issuer DID must not be empty")
+	}
+	client, err := s.checkAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return addTrustedIssuer(ctx, issuerDID, client)
+}
+
+// addTrustedIssuer registers an issuer DID added by a client
+func addTrustedIssuer(ctx contractapi.TransactionContextInterface, issuerDID string, client *Inserter) (*TrustedIssuer, error) {
+	addedAt, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	issuer := &TrustedIssuer{DID: issuerDID, AddedAt: addedAt, AddedBy: *client, TxID: ctx.GetStub().GetTxID()}
+	issuerJSON, err := json.Marshal(issuer)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(trustedIssuerObjectType, []string{issuerDID})
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.GetStub().PutState(key, issuerJSON); err != nil {
+		return nil, fmt.Errorf("error saving trusted issuer: %v", err)
+	}
+	return issuer, nil
+}
+
+// RemoveTrustedIssuer removes an issuer DID from the trust registry. Credentials it issued no longer
+// verify, already issued ones as well.
+func (s *TrustRegistryContract) RemoveTrustedIssuer(ctx contractapi.TransactionContextInterface, issuerDID string) error {
+	if err := checkWritable(ctx); err != nil {
+		return err
+	}
+	if _, err := s.checkAdmin(ctx); err != nil {
+		return err
+	}
+	return removeTrustedIssuer(ctx, issuerDID)
+}
+
+// removeTrustedIssuer removes an issuer DID from the trust registry
+func removeTrustedIssuer(ctx contractapi.TransactionContextInterface, issuerDID string) error {
+	issuer, err := loadTrustedIssuer(ctx, issuerDID)
+	if err != nil {
+		return err
+	}
+	if issuer == nil {
+		return fmt.Errorf("%w: %s", ErrUntrustedIssuer, issuerDID)
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(trustedIssuerObjectType, []string{issuerDID})
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().DelState(key); err != nil {
+		return fmt.Errorf("error removing trusted issuer: %v", err)
+	}
+	return nil
+}
+
+// IsTrustedIssuer reports whether the issuer DID is in the trust registry
+func (s *TrustRegistryContract) IsTrustedIssuer(ctx contractapi.TransactionContextInterface, issuerDID string) (bool, error) {
+	issuer, err := loadTrustedIssuer(ctx, issuerDID)
+	if err != nil {
+		return false, err
+	}
+	return issuer != nil, nil
+}
+
+// checkAdmin returns the client if it may change the trust registry
+func (s *TrustRegistryContract) checkAdmin(ctx contractapi.TransactionContextInterface) (*Inserter, error) {
+	client, admin, err := clientInserter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.AdminMSPs) == 0 {
+		if !admin {
+			return nil, ErrNotTrustRegistryAdmin
+		}
+		return client, nil
+	}
+	for _, mspID := range s.AdminMSPs {
+		if client.MSPID == mspID {
+			return client, nil
+		}
+	}
+	return nil, ErrNotTrustRegistryAdmin
+}
+
+// loadTrustedIssuer returns the trust registry entry of an issuer, nil if it is not registered
+func loadTrustedIssuer(ctx contractapi.TransactionContextInterface, issuerDID string) (*TrustedIssuer, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(trustedIssuerObjectType, []string{issuerDID})
+	if err != nil {
+		return nil, err
+	}
+	issuerJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("error loading trusted issuer: %v", err)
+	}
+	if issuerJSON == nil {
+		return nil, nil
+	}
+	var issuer TrustedIssuer
+	if err := json.Unmarshal(issuerJSON, &issuer); err != nil {
+		return nil, fmt.Errorf("error decoding trusted issuer: %v", err)
+	}
+	return &issuer, nil
+}
+
+// checkTrustedIssuer fails with ErrUntrustedIssuer if the issuer is not in the trust registry
+func checkTrustedIssuer(ctx contractapi.TransactionContextInterface, issuerDID string) error {
+	issuer, err := loadTrustedIssuer(ctx, issuerDID)
+	if err != nil {
+		return err
+	}
+	if issuer == nil {
+		return fmt.Errorf("%w: %s", ErrUntrustedIssuer, issuerDID)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package cuckoofilter_test
+
+import (
+	"encoding/json"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
+	"github.com/pherbke/credential-management/chaincode-go/simulator"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+func TestTrustRegistry_VerifyingCredential(t *testing.T) {
+	useStakeholderDir(t)
+	sim, admin, issuer, holder := newDIDSimulator(t)
+	verifier := newIssuerIdentity(t, "Org2MSP", "did:key:verifier")
+	_, err := sim.Submit(newIssuerIdentity(t, "Org1MSP", issuer.DID), "StakeholderManagementContract:IssuingCredential", issuer.DID, holder.DID)
+	require.NoError(t, err)
+	issued, err := os.ReadFile("./holderCredentials/" + holder.DID + ".jwt")
+	require.NoError(t, err)
+
+	trusted, err := sim.Evaluate(verifier, "TrustRegistryContract:IsTrustedIssuer", issuer.DID)
+	require.NoError(t, err)
+	require.Equal(t, "true", string(trusted))
+	valid, err := sim.Evaluate(verifier, "StakeholderManagementContract:VerifyingCredential", string(issued), "verifier", holder.DID, issuer.DID)
+	require.NoError(t, err)
+	require.Equal(t, "true", string(valid))
+
+	// Once removed, already issued credentials of the issuer no longer validate
+	_, err = sim.Submit(admin, "TrustRegistryContract:RemoveTrustedIssuer", issuer.DID)
+	require.NoError(t, err)
+	trusted, err = sim.Evaluate(verifier, "TrustRegistryContract:IsTrustedIssuer", issuer.DID)
+	require.NoError(t, err)
+	require.Equal(t, "false", string(trusted))
+	_, err = sim.Evaluate(verifier, "StakeholderManagementContract:VerifyingCredential", string(issued), "verifier", holder.DID, issuer.DID)
+	require.ErrorContains(t, err, cuckoofilter.ErrUntrustedIssuer.Error())
+
+	_, err = sim.Submit(admin, "TrustRegistryContract:RemoveTrustedIssuer", issuer.DID)
+	require.ErrorContains(t, err, cuckoofilter.ErrUntrustedIssuer.Error())
+}
+
+func TestTrustRegistry_AddTrustedIssuer(t *testing.T) {
+	useStakeholderDir(t)
+	sim, _, issuer, _ := newDIDSimulator(t)
+
+	// Clients without the admin attribute cannot change the registry
+	_, err := sim.Submit(newIssuerIdentity(t, "Org1MSP", issuer.DID), "TrustRegistryContract:AddTrustedIssuer", "did:key:other")
+	require.ErrorContains(t, err, cuckoofilter.ErrNotTrustRegistryAdmin.Error())
+
+	admin, err := simulator.NewIdentityWithAttributes("RegistryMSP", "registry-admin", map[string]string{
+		cuckoofilter.DIDAttribute:           "did:key:admin",
+		cuckoofilter.RegistryAdminAttribute: "true",
+	})
+	require.NoError(t, err)
+	tx, err := sim.Submit(admin, "TrustRegistryContract:AddTrustedIssuer", "did:key:other")
+	require.NoError(t, err)
+	var entry cuckoofilter.TrustedIssuer
+	require.NoError(t, json.Unmarshal(tx.Payload, &entry))
+	require.Equal(t, "did:key:other", entry.DID)
+	require.Equal(t, tx.Timestamp, entry.AddedAt)
+	require.Equal(t, cuckoofilter.Inserter{MSPID: "RegistryMSP", DID: "did:key:admin"}, entry.AddedBy)
+	require.Equal(t, tx.ID, entry.TxID)
+}
+
+func TestTrustRegistry_AdminMSPs(t *testing.T) {
+	sim, err := simulator.New("credential-management", &cuckoofilter.StakeholderManagementContract{Clock: clock.System}, &cuckoofilter.TrustRegistryContract{AdminMSPs: []string{"GovernanceMSP"}})
+	require.NoError(t, err)
+
+	// With admin MSPs configured, the MSP decides and the admin attribute does not
+	attributeAdmin, err := simulator.NewIdentityWithAttributes("RegistryMSP", "registry-admin", map[string]string{
+		cuckoofilter.RegistryAdminAttribute: "true",
+	})
+	require.NoError(t, err)
+	_, err = sim.Submit(attributeAdmin, "TrustRegistryContract:AddTrustedIssuer", "did:key:issuer")
+	require.ErrorContains(t, err, cuckoofilter.ErrNotTrustRegistryAdmin.Error())
+
+	governance, err := simulator.NewIdentity("GovernanceMSP", "governance")
+	require.NoError(t, err)
+	_, err = sim.Submit(governance, "TrustRegistryContract:AddTrustedIssuer", "did:key:issuer")
+	require.NoError(t, err)
+	trusted, err := sim.Evaluate(attributeAdmin, "TrustRegistryContract:IsTrustedIssuer", "did:key:issuer")
+	require.NoError(t, err)
+	require.Equal(t, "true", string(trusted))
+}
+
+func TestVerifyingCredential_Revoked(t *testing.T) {
+	useStakeholderDir(t)
+	sim, admin, issuer, holder := newDIDSimulator(t)
+	verifier := newIssuerIdentity(t, "Org2MSP", "did:key:verifier")
+	issuerIdentity := newIssuerIdentity(t, "Org1MSP", issuer.DID)
+	_, err := sim.Submit(issuerIdentity, "StakeholderManagementContract:IssuingCredential", issuer.DID, holder.DID)
+	require.NoError(t, err)
+	issued, err := os.ReadFile("./holderCredentials/" + holder.DID + ".jwt")
+	require.NoError(t, err)
+
+	// Nothing is revoked before the registry is initialized
+	valid, err := sim.Evaluate(verifier, "StakeholderManagementContract:VerifyingCredential", string(issued), "verifier", holder.DID, issuer.DID)
+	require.NoError(t, err)
+	require.Equal(t, "true", string(valid))
+
+	_, err = sim.Submit(admin, "Init", "", "100", "4", "0")
+	require.NoError(t, err)
+	valid, err = sim.Evaluate(verifier, "StakeholderManagementContract:VerifyingCredential", string(issued), "verifier", holder.DID, issuer.DID)
+	require.NoError(t, err)
+	require.Equal(t, "true", string(valid))
+
+	_, err = sim.Submit(issuerIdentity, "RevokeCredential", string(issued), "key compromise")
+	require.NoError(t, err)
+	_, err = sim.Evaluate(verifier, "StakeholderManagementContract:VerifyingCredential", string(issued), "verifier", holder.DID, issuer.DID)
+	require.ErrorContains(t, err, cuckoofilter.ErrCredentialRevoked.Error())
+}
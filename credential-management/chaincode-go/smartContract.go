@@ -6,19 +6,71 @@ package main
 
 import (
 	"log"
+	"os"
 
+	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 
+	"github.com/pherbke/credential-management/chaincode-go/config"
 	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
 )
 
 func main() {
-	cuckooSmartContract, err := contractapi.NewChaincode(&cuckoofilter.SmartContract{})
+	cfg, err := config.Load(os.Getenv("CREDENTIAL_MANAGEMENT_CONFIG"))
+	if err != nil {
+		log.Panicf("Error loading configuration: %v", err)
+	}
+
+	cuckooSmartContract, err := contractapi.NewChaincode(cuckoofilter.Contracts(cfg.Chaincode.TrustRegistryAdminMSPs)...)
 	if err != nil {
 		log.Panicf("Error creating cuckoo filter chaincode: %v", err)
 	}
 
+	// Run as chaincode-as-a-service when the external builder provides a server address
+	if cfg.Chaincode.ServerAddress != "" {
+		server := &shim.ChaincodeServer{
+			CCID:     cfg.Chaincode.ID,
+			Address:  cfg.Chaincode.ServerAddress,
+			CC:       cuckooSmartContract,
+			TLSProps: getTLSProperties(cfg.Chaincode.TLS),
+		}
+		if err := server.Start(); err != nil {
+			log.Panicf("Error starting cuckoo filter chaincode server: %v", err)
+		}
+		return
+	}
+
 	if err := cuckooSmartContract.Start(); err != nil {
 		log.Panicf("Error starting cuckoo filter chaincode: %v", err)
 	}
 }
+
+// getTLSProperties reads the chaincode server TLS key and certificates
+func getTLSProperties(tls config.TLSConfig) shim.TLSProperties {
+	if !tls.Enabled {
+		return shim.TLSProperties{Disabled: true}
+	}
+
+	key, err := os.ReadFile(tls.KeyFile)
+	if err != nil {
+		log.Panicf("Error reading chaincode TLS key: %v", err)
+	}
+	cert, err := os.ReadFile(tls.CertFile)
+	if err != nil {
+		log.Panicf("Error reading chaincode TLS certificate: %v", err)
+	}
+
+	var clientCACert []byte
+	if tls.ClientCACertFile != "" {
+		clientCACert, err = os.ReadFile(tls.ClientCACertFile)
+		if err != nil {
+			log.Panicf("Error reading chaincode client CA certificate: %v", err)
+		}
+	}
+
+	return shim.TLSProperties{
+		Key:           key,
+		Cert:          cert,
+		ClientCACerts: clientCACert,
+	}
+}
@@ -0,0 +1,222 @@
+package snapshot
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Names of the codecs, as used in Accept-Encoding style preferences and in the manifest
+const (
+	CodecNone = "none" // The uncompressed file, "identity" in HTTP terms
+	CodecGzip = "gzip"
+	CodecZstd = "zstd"
+)
+
+// ErrNoAcceptableCodec is returned when none of the published encodings is acceptable to a consumer
+var ErrNoAcceptableCodec = errors.New("no acceptable snapshot encoding")
+
+// Codec compresses snapshot files
+type Codec interface {
+	Name() string
+	Extension() string // Suffix of the file names
+	Encode(data []byte) ([]byte, error)
+	Decode(data []byte, maxSize int) ([]byte, error) // Fails on output larger than maxSize
+}
+
+// Encoding is a compressed copy of a snapshot file listed in the manifest
+type Encoding struct {
+	Codec  string `json:"codec"`
+	File   string `json:"file"`
+	SHA256 string `json:"sha256"` // Hex encoded hash of the compressed file
+	Size   int    `json:"size"`
+}
+
+var codecs = map[string]Codec{
+	CodecNone: noneCodec{},
+	CodecGzip: gzipCodec{},
+	CodecZstd: &zstdCodec{},
+}
+
+// LookupCodec returns the codec of a name
+func LookupCodec(name string) (Codec, error) {
+	codec, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown snapshot codec %q", name)
+	}
+	return codec, nil
+}
+
+// implicitQuality ranks the uncompressed file below every codec a preference names
+const implicitQuality = 0.0001
+
+// Negotiate picks the codec to download from the offered ones according to an Accept-Encoding style
+// preference such as "zstd, gzip;q=0.5". The codec with the highest quality wins, ties go to the one
+// the preference names first. As in HTTP, the uncompressed file is acceptable unless it is refused
+// with "none;q=0" (or "identity;q=0") or "*;q=0"; unless named, it is only taken when no named codec
+// is offered, e.g. for an empty preference.
+func Negotiate(accept string, offered []string) (string, error) {
+	type preference struct {
+		quality  float64
+		position int
+	}
+	preferences := make(map[string]preference)
+	for position, entry := range strings.Split(accept, ",") {
+		parts := strings.Split(entry, ";")
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		if name == "" {
+			continue
+		}
+		if name == "identity" {
+			name = CodecNone
+		}
+		quality := 1.0
+		for _, parameter := range parts[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(parameter), "=")
+			if strings.TrimSpace(key) != "q" {
+				continue
+			}
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || q < 0 || q > 1 {
+				return "", fmt.Errorf("invalid quality in encoding preference %q", entry)
+			}
+			quality = q
+		}
+		preferences[name] = preference{quality: quality, position: position}
+	}
+
+	best, bestPreference := "", preference{}
+	for _, name := range offered {
+		p, ok := preferences[name]
+		if !ok {
+			p, ok = preferences["*"]
+		}
+		if !ok && name == CodecNone {
+			p, ok = preference{quality: implicitQuality, position: strings.Count(accept, ",") + 1}, true
+		}
+		if !ok || p.quality == 0 {
+			continue
+		}
+		if best == "" || p.quality > bestPreference.quality || (p.quality == bestPreference.quality && p.position < bestPreference.position) {
+			best, bestPreference = name, p
+		}
+	}
+	if best == "" {
+		offeredSorted := append([]string{}, offered...)
+		sort.Strings(offeredSorted)
+		return "", fmt.Errorf("%w: %q accepts none of %s", ErrNoAcceptableCodec, accept, strings.Join(offeredSorted, ", "))
+	}
+	return best, nil
+}
+
+type noneCodec struct{}
+
+func (noneCodec) Name() string      { return CodecNone }
+func (noneCodec) Extension() string { return "" }
+
+func (noneCodec) Encode(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+func (noneCodec) Decode(data []byte, maxSize int) ([]byte, error) {
+	if len(data) > maxSize {
+		return nil, fmt.Errorf("snapshot is larger than %d bytes", maxSize)
+	}
+	return data, nil
+}
+
+// gzipCodec writes gzip streams without a modification time, so the same snapshot always compresses
+// to the same file
+type gzipCodec struct{}
+
+func (gzipCodec) Name() string      { return CodecGzip }
+func (gzipCodec) Extension() string { return ".gz" }
+
+func (gzipCodec) Encode(data []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&buffer, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (gzipCodec) Decode(data []byte, maxSize int) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	decoded, err := io.ReadAll(io.LimitReader(reader, int64(maxSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) > maxSize {
+		return nil, fmt.Errorf("snapshot is larger than %d bytes", maxSize)
+	}
+	return decoded, nil
+}
+
+// zstdCodec shares one encoder and decoder, both are safe for concurrent EncodeAll and DecodeAll calls
+type zstdCodec struct {
+	once    sync.Once
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+	err     error
+}
+
+func (*zstdCodec) Name() string      { return CodecZstd }
+func (*zstdCodec) Extension() string { return ".zst" }
+
+func (c *zstdCodec) init() error {
+	c.once.Do(func() {
+		c.encoder, c.err = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+		if c.err == nil {
+			c.decoder, c.err = zstd.NewReader(nil)
+		}
+	})
+	return c.err
+}
+
+func (c *zstdCodec) Encode(data []byte) ([]byte, error) {
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+	return c.encoder.EncodeAll(data, nil), nil
+}
+
+func (c *zstdCodec) Decode(data []byte, maxSize int) ([]byte, error) {
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+	// Frames declare their content size, so oversized snapshots fail before they are decoded
+	var header zstd.Header
+	if err := header.Decode(data); err != nil {
+		return nil, err
+	}
+	if !header.HasFCS || header.FrameContentSize > uint64(maxSize) {
+		return nil, fmt.Errorf("snapshot is larger than %d bytes or does not declare its size", maxSize)
+	}
+	decoded, err := c.decoder.DecodeAll(data, make([]byte, 0, header.FrameContentSize))
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) > maxSize {
+		return nil, fmt.Errorf("snapshot is larger than %d bytes", maxSize)
+	}
+	return decoded, nil
+}
@@ -0,0 +1,133 @@
+package snapshot_test
+
+import (
+	"bytes"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/pherbke/credential-management/chaincode-go/snapshot"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	offered := []string{snapshot.CodecZstd, snapshot.CodecGzip, snapshot.CodecNone}
+	for accept, expected := range map[string]string{
+		"":                         snapshot.CodecNone,
+		"zstd":                     snapshot.CodecZstd,
+		"gzip, zstd":               snapshot.CodecGzip,
+		"zstd;q=0.5, gzip":         snapshot.CodecGzip,
+		"GZIP;q=0.8, br":           snapshot.CodecGzip,
+		"br":                       snapshot.CodecNone,
+		"*":                        snapshot.CodecZstd,
+		"identity, gzip;q=0.9":     snapshot.CodecNone,
+		"gzip;q=0.1, identity;q=0": snapshot.CodecGzip,
+	} {
+		codec, err := snapshot.Negotiate(accept, offered)
+		require.NoError(t, err, accept)
+		require.Equal(t, expected, codec, accept)
+	}
+
+	_, err := snapshot.Negotiate("zstd, *;q=0", []string{snapshot.CodecGzip, snapshot.CodecNone})
+	require.ErrorIs(t, err, snapshot.ErrNoAcceptableCodec)
+	_, err = snapshot.Negotiate("gzip;q=2", offered)
+	require.Error(t, err)
+}
+
+func TestCodecs_RoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte(`{"Buckets":[{"Data":["abc","def"]}]}`), 100)
+	for _, name := range []string{snapshot.CodecNone, snapshot.CodecGzip, snapshot.CodecZstd} {
+		codec, err := snapshot.LookupCodec(name)
+		require.NoError(t, err)
+		encoded, err := codec.Encode(data)
+		require.NoError(t, err)
+		again, err := codec.Encode(data)
+		require.NoError(t, err)
+		require.Equal(t, encoded, again, "%s output must be reproducible", name)
+
+		decoded, err := codec.Decode(encoded, len(data))
+		require.NoError(t, err)
+		require.Equal(t, data, decoded)
+		_, err = codec.Decode(encoded, len(data)-1)
+		require.Error(t, err, "%s must refuse oversized output", name)
+	}
+	_, err := snapshot.LookupCodec("br")
+	require.Error(t, err)
+}
+
+func TestPublishAndDownload_Codecs(t *testing.T) {
+	key := newKey(t)
+	dir := snapshot.Dir(t.TempDir())
+	filter := cuckoofilter.NewFilter(1000, 4, cuckoofilter.FingerPrintSize)
+	require.True(t, filter.Insert([]byte("revoked")))
+	publisher := &snapshot.Publisher{Source: filterSource(t, filter), Store: dir, PrivateKey: key, Codecs: []string{snapshot.CodecZstd, snapshot.CodecGzip}}
+	manifest, _, err := publisher.Publish()
+	require.NoError(t, err)
+
+	// The signed manifest lists every compressed copy
+	require.Equal(t, []string{snapshot.CodecZstd, snapshot.CodecGzip, snapshot.CodecNone}, manifest.Codecs())
+	for _, encoding := range manifest.Encodings {
+		require.True(t, strings.HasSuffix(encoding.File, map[string]string{snapshot.CodecZstd: ".json.zst", snapshot.CodecGzip: ".json.gz"}[encoding.Codec]))
+		require.Less(t, encoding.Size, manifest.Size)
+		_, err := dir.Get(encoding.File)
+		require.NoError(t, err)
+	}
+
+	// Each consumer downloads in its preferred codec
+	fetched := map[string][]string{}
+	for _, accept := range []string{"zstd", "gzip", ""} {
+		downloader := &snapshot.Downloader{Fetcher: recordingFetcher{dir, fetched, accept}, PublicKey: &key.PublicKey, AcceptEncoding: accept}
+		downloaded, _, err := downloader.Download()
+		require.NoError(t, err)
+		require.True(t, downloaded.Lookup([]byte("revoked")))
+	}
+	require.Equal(t, manifest.Encodings[0].File, fetched["zstd"][2])
+	require.Equal(t, manifest.Encodings[1].File, fetched["gzip"][2])
+	require.Equal(t, manifest.File, fetched[""][2])
+
+	history := &snapshot.History{Fetcher: dir, PublicKey: &key.PublicKey, AcceptEncoding: "gzip"}
+	result, err := history.LookupAt(1, "revoked")
+	require.NoError(t, err)
+	require.True(t, result.Revoked)
+
+	// A consumer refusing every published codec gets a typed error
+	downloader := &snapshot.Downloader{Fetcher: dir, PublicKey: &key.PublicKey, AcceptEncoding: "br, *;q=0"}
+	_, _, err = downloader.Download()
+	require.ErrorIs(t, err, snapshot.ErrNoAcceptableCodec)
+}
+
+func TestDownload_TamperedCompressedSnapshot(t *testing.T) {
+	key := newKey(t)
+	dir := snapshot.Dir(t.TempDir())
+	publisher := &snapshot.Publisher{Source: filterSource(t, cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize)), Store: dir, PrivateKey: key, Codecs: []string{snapshot.CodecGzip}}
+	manifest, _, err := publisher.Publish()
+	require.NoError(t, err)
+
+	codec, err := snapshot.LookupCodec(snapshot.CodecGzip)
+	require.NoError(t, err)
+	tampered, err := codec.Encode([]byte(`{"Buckets":[]}`))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(string(dir), manifest.Encodings[0].File), tampered, 0644))
+
+	downloader := &snapshot.Downloader{Fetcher: dir, PublicKey: &key.PublicKey, AcceptEncoding: "gzip"}
+	_, _, err = downloader.Download()
+	require.ErrorContains(t, err, "does not match the manifest")
+
+	// The uncompressed file is unaffected
+	downloader = &snapshot.Downloader{Fetcher: dir, PublicKey: &key.PublicKey}
+	_, _, err = downloader.Download()
+	require.NoError(t, err)
+}
+
+// recordingFetcher records the names fetched per preference
+type recordingFetcher struct {
+	snapshot.Fetcher
+	fetched map[string][]string
+	accept  string
+}
+
+func (f recordingFetcher) Get(name string) ([]byte, error) {
+	f.fetched[f.accept] = append(f.fetched[f.accept], name)
+	return f.Fetcher.Get(name)
+}
@@ -0,0 +1,113 @@
+package snapshot
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+)
+
+// Downloader fetches published snapshots and verifies them before they are loaded
+type Downloader struct {
+	Fetcher   Fetcher
+	PublicKey *ecdsa.PublicKey // Key of the publisher
+	// Codecs to download the snapshot in, as an Accept-Encoding header, e.g. "zstd, gzip;q=0.5".
+	// Empty downloads the uncompressed file.
+	AcceptEncoding string
+
+	mu      sync.Mutex
+	version uint64
+}
+
+// Download fetches the current snapshot. It returns a nil filter when the published version is
+// not newer than the last one downloaded, and refuses manifests that roll back to an older version.
+func (d *Downloader) Download() (*cuckoofilter.Filter, *Manifest, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	manifest, err := fetchManifest(d.Fetcher, d.PublicKey, ManifestFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	if manifest.Version < d.version {
+		return nil, nil, fmt.Errorf("manifest version %d is older than the loaded version %d", manifest.Version, d.version)
+	}
+	if manifest.Version == d.version {
+		return nil, manifest, nil
+	}
+
+	filter, err := fetchFilter(d.Fetcher, manifest, d.AcceptEncoding)
+	if err != nil {
+		return nil, nil, err
+	}
+	d.version = manifest.Version
+	return filter, manifest, nil
+}
+
+// fetchManifest fetches a manifest and verifies its detached signature
+func fetchManifest(fetcher Fetcher, publicKey *ecdsa.PublicKey, name string) (*Manifest, error) {
+	manifestJSON, err := fetcher.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching manifest: %v", err)
+	}
+	signature, err := fetcher.Get(name + ".sig")
+	if err != nil {
+		return nil, fmt.Errorf("error fetching manifest signature: %v", err)
+	}
+	manifestHash := sha256.Sum256(manifestJSON)
+	if !ecdsa.VerifyASN1(publicKey, manifestHash[:], signature) {
+		return nil, fmt.Errorf("invalid manifest signature")
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		return nil, fmt.Errorf("error decoding manifest: %v", err)
+	}
+	return &manifest, nil
+}
+
+// fetchFilter fetches the snapshot of a manifest in the codec negotiated with acceptEncoding and checks
+// the file and its decompressed content match the manifest
+func fetchFilter(fetcher Fetcher, manifest *Manifest, acceptEncoding string) (*cuckoofilter.Filter, error) {
+	name, err := Negotiate(acceptEncoding, manifest.Codecs())
+	if err != nil {
+		return nil, err
+	}
+	file, sha, size := manifest.File, manifest.SHA256, manifest.Size
+	for _, encoding := range manifest.Encodings {
+		if encoding.Codec == name {
+			file, sha, size = encoding.File, encoding.SHA256, encoding.Size
+		}
+	}
+	codec, err := LookupCodec(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := fetcher.Get(file)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching snapshot: %v", err)
+	}
+	hash := sha256.Sum256(data)
+	if len(data) != size || hex.EncodeToString(hash[:]) != sha {
+		return nil, fmt.Errorf("snapshot %s does not match the manifest", file)
+	}
+	filterJSON, err := codec.Decode(data, manifest.Size)
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing snapshot %s: %v", file, err)
+	}
+	hash = sha256.Sum256(filterJSON)
+	if len(filterJSON) != manifest.Size || hex.EncodeToString(hash[:]) != manifest.SHA256 {
+		return nil, fmt.Errorf("decompressed snapshot %s does not match the manifest", file)
+	}
+
+	var filter cuckoofilter.Filter
+	if err := json.Unmarshal(filterJSON, &filter); err != nil {
+		return nil, fmt.Errorf("error decoding snapshot: %v", err)
+	}
+	return &filter, nil
+}
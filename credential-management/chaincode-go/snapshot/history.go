@@ -0,0 +1,118 @@
+package snapshot
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"sync"
+
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+)
+
+// PinnedResult is the revocation status of a fingerprint as of a block height.
+// The manifest identifies the exact snapshot used, so the result can be reproduced later.
+type PinnedResult struct {
+	BlockHeight uint64 `json:"blockHeight"` // Requested block height
+	Revoked     bool   `json:"revoked"`
+	// Exact is set if the snapshot was read at the requested height. Otherwise the status is the one
+	// at Manifest.BlockHeight and misses revocations between that height and the requested one.
+	Exact    bool      `json:"exact"`
+	Manifest *Manifest `json:"manifest"` // Latest snapshot at or below the requested height
+}
+
+// History looks up fingerprints in the published snapshots as of a past block height
+type History struct {
+	Fetcher        Fetcher
+	PublicKey      *ecdsa.PublicKey
+	AcceptEncoding string // Codecs to download snapshots in, as for Downloader
+	// RequireExact makes LookupAt fail unless a snapshot was published at exactly the requested height,
+	// for audits that must not rely on an earlier snapshot
+	RequireExact bool
+
+	mu        sync.Mutex
+	manifests map[uint64]*Manifest
+	filters   map[string]*cuckoofilter.Filter
+}
+
+// LookupAt returns the revocation status of the fingerprint in the latest snapshot read at or below the block height
+func (h *History) LookupAt(blockHeight uint64, fingerprint string) (*PinnedResult, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	manifest, err := h.manifestAt(blockHeight)
+	if err != nil {
+		return nil, err
+	}
+	if h.RequireExact && manifest.BlockHeight != blockHeight {
+		return nil, fmt.Errorf("no snapshot at block height %d, the latest below is at %d", blockHeight, manifest.BlockHeight)
+	}
+	filter, ok := h.filters[manifest.SHA256]
+	if !ok {
+		filter, err = fetchFilter(h.Fetcher, manifest, h.AcceptEncoding)
+		if err != nil {
+			return nil, err
+		}
+		if h.filters == nil {
+			h.filters = make(map[string]*cuckoofilter.Filter)
+		}
+		h.filters[manifest.SHA256] = filter
+	}
+
+	return &PinnedResult{
+		BlockHeight: blockHeight,
+		Revoked:     filter.Lookup([]byte(fingerprint)),
+		Exact:       manifest.BlockHeight == blockHeight,
+		Manifest:    manifest,
+	}, nil
+}
+
+// manifestAt finds the latest manifest at or below the block height with a binary search over the
+// versions. It relies on block heights never decreasing with versions, which Publisher enforces. The
+// caller must hold h.mu.
+func (h *History) manifestAt(blockHeight uint64) (*Manifest, error) {
+	current, err := fetchManifest(h.Fetcher, h.PublicKey, ManifestFile)
+	if err != nil {
+		return nil, err
+	}
+	if current.BlockHeight <= blockHeight {
+		return current, nil
+	}
+
+	var found *Manifest
+	low, high := uint64(1), current.Version-1
+	for low <= high && high > 0 {
+		middle := low + (high-low)/2
+		manifest, err := h.manifest(middle)
+		if err != nil {
+			return nil, err
+		}
+		if manifest.BlockHeight <= blockHeight {
+			found = manifest
+			low = middle + 1
+		} else {
+			high = middle - 1
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no snapshot at or below block height %d", blockHeight)
+	}
+	return found, nil
+}
+
+// manifest returns a verified manifest version, the caller must hold h.mu
+func (h *History) manifest(version uint64) (*Manifest, error) {
+	if manifest, ok := h.manifests[version]; ok {
+		return manifest, nil
+	}
+	manifest, err := fetchManifest(h.Fetcher, h.PublicKey, ManifestVersionFile(version))
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Version != version {
+		return nil, fmt.Errorf("%s holds manifest version %d", ManifestVersionFile(version), manifest.Version)
+	}
+	if h.manifests == nil {
+		h.manifests = make(map[uint64]*Manifest)
+	}
+	h.manifests[version] = manifest
+	return manifest, nil
+}
@@ -0,0 +1,80 @@
+package snapshot_test
+
+import (
+	"encoding/json"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/pherbke/credential-management/chaincode-go/snapshot"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestHistory_LookupAt(t *testing.T) {
+	key := newKey(t)
+	dir := snapshot.Dir(t.TempDir())
+	filter := cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize)
+	height := uint64(0)
+	publisher := &snapshot.Publisher{
+		Source: func() ([]byte, uint64, error) {
+			filterJSON, err := json.Marshal(filter)
+			return filterJSON, height, err
+		},
+		Store:      dir,
+		PrivateKey: key,
+	}
+
+	// Snapshots at heights 10 (empty), 20 (first revoked) and 30 (second revoked)
+	for i, fingerprint := range []string{"", "first", "second"} {
+		if fingerprint != "" {
+			require.True(t, filter.Insert([]byte(fingerprint)))
+		}
+		height = uint64(10 * (i + 1))
+		_, published, err := publisher.Publish()
+		require.NoError(t, err)
+		require.True(t, published)
+	}
+
+	history := &snapshot.History{Fetcher: dir, PublicKey: &key.PublicKey}
+	tests := []struct {
+		height  uint64
+		revoked bool
+		version uint64
+	}{
+		{height: 10, revoked: false, version: 1},
+		{height: 19, revoked: false, version: 1},
+		{height: 20, revoked: true, version: 2},
+		{height: 29, revoked: true, version: 2},
+		{height: 100, revoked: true, version: 3},
+	}
+	exact := &snapshot.History{Fetcher: dir, PublicKey: &key.PublicKey, RequireExact: true}
+	for _, test := range tests {
+		result, err := history.LookupAt(test.height, "first")
+		require.NoError(t, err)
+		require.Equal(t, test.revoked, result.Revoked, "height %d", test.height)
+		require.Equal(t, test.version, result.Manifest.Version, "height %d", test.height)
+		require.Equal(t, test.height, result.BlockHeight)
+		require.Equal(t, test.height%10 == 0 && test.height <= 30, result.Exact, "height %d", test.height)
+
+		// Only snapshots read at the requested height are accepted in exact mode
+		exactResult, err := exact.LookupAt(test.height, "first")
+		if result.Exact {
+			require.NoError(t, err)
+			require.Equal(t, result, exactResult)
+		} else {
+			require.Error(t, err)
+		}
+	}
+
+	result, err := history.LookupAt(25, "second")
+	require.NoError(t, err)
+	require.False(t, result.Revoked)
+	require.False(t, result.Exact)
+
+	_, err = history.LookupAt(5, "first")
+	require.Error(t, err)
+
+	// A snapshot read below the published height would break the search by height
+	height = 25
+	require.True(t, filter.Insert([]byte("third")))
+	_, _, err = publisher.Publish()
+	require.Error(t, err)
+}
@@ -0,0 +1,180 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+)
+
+// Flat filter files hold the buckets of a filter as one array of fixed-size slots, so they can be
+// memory-mapped read-only and shared between verifier processes on a host. The layout is a header of
+//
+//	magic "CMCFLAT2" | fingerprint size uint32 | slots per bucket uint32 | buckets uint64 | index mask uint64 | count uint64
+//
+// in little endian, followed by the buckets. Each bucket starts with an occupancy bitmap of one bit per
+// slot, lowest bit first, followed by its slots. Fingerprints may be all zero, short fingerprints
+// often are, so only the bitmap tells occupied and empty slots apart.
+const (
+	flatMagic      = "CMCFLAT2"
+	flatHeaderSize = 40
+)
+
+// WriteFlatFile writes the filter as a flat filter file, replacing the file atomically
+func WriteFlatFile(path string, filter *cuckoofilter.Filter) error {
+	slots := 0
+	for _, b := range filter.Buckets {
+		if b != nil && len(b.Data) > slots {
+			slots = len(b.Data)
+		}
+	}
+
+	fpSize := int(filter.FingerprintSize)
+	if fpSize == 0 {
+		fpSize = cuckoofilter.FingerPrintSize
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(flatMagic)
+	header := make([]byte, flatHeaderSize-len(flatMagic))
+	binary.LittleEndian.PutUint32(header[0:], uint32(fpSize))
+	binary.LittleEndian.PutUint32(header[4:], uint32(slots))
+	binary.LittleEndian.PutUint64(header[8:], uint64(len(filter.Buckets)))
+	binary.LittleEndian.PutUint64(header[16:], uint64(filter.BucketIndexMask))
+	binary.LittleEndian.PutUint64(header[24:], uint64(filter.Count))
+	buf.Write(header)
+
+	bucket := make([]byte, flatBucketSize(slots, fpSize))
+	for i, b := range filter.Buckets {
+		for k := range bucket {
+			bucket[k] = 0
+		}
+		occupancy, slotData := bucket[:flatOccupancySize(slots)], bucket[flatOccupancySize(slots):]
+		for j := 0; b != nil && j < len(b.Data); j++ {
+			if len(b.Data[j]) == 0 {
+				continue
+			}
+			if len(b.Data[j]) != fpSize {
+				return fmt.Errorf("bucket %d holds a fingerprint that cannot be stored in a flat file", i)
+			}
+			occupancy[j/8] |= 1 << (j % 8)
+			copy(slotData[j*fpSize:], b.Data[j])
+		}
+		buf.Write(bucket)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// FlatFilter is a read-only filter backed by a memory-mapped flat filter file
+type FlatFilter struct {
+	data      []byte
+	slots     []byte
+	fpSize    int
+	perBucket int
+	buckets   uint64
+	indexMask uint
+	count     uint64
+}
+
+// OpenFlatFile maps a flat filter file written by WriteFlatFile
+func OpenFlatFile(path string) (*FlatFilter, error) {
+	data, err := mapFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error mapping flat filter file: %v", err)
+	}
+	filter, err := parseFlat(data)
+	if err != nil {
+		unmapFile(data)
+		return nil, err
+	}
+	return filter, nil
+}
+
+func parseFlat(data []byte) (*FlatFilter, error) {
+	if len(data) < flatHeaderSize || string(data[:len(flatMagic)]) != flatMagic {
+		return nil, fmt.Errorf("not a flat filter file")
+	}
+	header := data[len(flatMagic):flatHeaderSize]
+	filter := &FlatFilter{
+		data:      data,
+		fpSize:    int(binary.LittleEndian.Uint32(header[0:])),
+		perBucket: int(binary.LittleEndian.Uint32(header[4:])),
+		buckets:   binary.LittleEndian.Uint64(header[8:]),
+		indexMask: uint(binary.LittleEndian.Uint64(header[16:])),
+		count:     binary.LittleEndian.Uint64(header[24:]),
+	}
+	if filter.fpSize <= 0 || filter.fpSize > cuckoofilter.FingerPrintSize {
+		return nil, fmt.Errorf("unsupported fingerprint size %d", filter.fpSize)
+	}
+	// The header is untrusted, so the bucket count is checked by division rather than multiplying it out
+	bucketSize := uint64(flatBucketSize(filter.perBucket, filter.fpSize))
+	body := uint64(len(data) - flatHeaderSize)
+	if (bucketSize == 0 && body != 0) || (bucketSize != 0 && (body%bucketSize != 0 || body/bucketSize != filter.buckets)) {
+		return nil, fmt.Errorf("flat filter file is truncated")
+	}
+	filter.slots = data[flatHeaderSize:]
+	return filter, nil
+}
+
+// Lookup checks if the data is present in the filter
+func (f *FlatFilter) Lookup(data []byte) bool {
+	if f.buckets == 0 {
+		return false
+	}
+	i1, fp := cuckoofilter.GetIndexAndFingerprint(data, f.indexMask, uint(f.fpSize))
+	i2 := cuckoofilter.GetAltIndex(fp, i1, f.indexMask)
+	return f.bucketContains(i1, fp) || f.bucketContains(i2, fp)
+}
+
+func (f *FlatFilter) bucketContains(index uint, fp []byte) bool {
+	if uint64(index) >= f.buckets {
+		return false
+	}
+	bucketSize := flatBucketSize(f.perBucket, f.fpSize)
+	bucket := f.slots[int(index)*bucketSize : (int(index)+1)*bucketSize]
+	occupancy, slotData := bucket[:flatOccupancySize(f.perBucket)], bucket[flatOccupancySize(f.perBucket):]
+	for j := 0; j < f.perBucket; j++ {
+		if occupancy[j/8]&(1<<(j%8)) != 0 && bytes.Equal(slotData[j*f.fpSize:(j+1)*f.fpSize], fp) {
+			return true
+		}
+	}
+	return false
+}
+
+// flatOccupancySize returns the size of the occupancy bitmap of a bucket
+func flatOccupancySize(perBucket int) int {
+	return (perBucket + 7) / 8
+}
+
+// flatBucketSize returns the size of a bucket, its occupancy bitmap and slots
+func flatBucketSize(perBucket int, fpSize int) int {
+	return flatOccupancySize(perBucket) + perBucket*fpSize
+}
+
+// Count returns the number of fingerprints in the filter
+func (f *FlatFilter) Count() uint64 {
+	return f.count
+}
+
+// Close unmaps the file, the filter must not be used afterwards
+func (f *FlatFilter) Close() error {
+	data := f.data
+	f.data, f.slots, f.buckets = nil, nil, 0
+	return unmapFile(data)
+}
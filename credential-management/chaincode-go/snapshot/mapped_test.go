@@ -0,0 +1,96 @@
+package snapshot_test
+
+import (
+	"encoding/binary"
+	"fmt"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/pherbke/credential-management/chaincode-go/snapshot"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFlatFile_MatchesFilter(t *testing.T) {
+	// 1-byte fingerprints are zero for about one item in 256
+	for _, fingerprintSize := range []uint{cuckoofilter.FingerPrintSize, 4, 1} {
+		t.Run(fmt.Sprintf("%d-byte fingerprints", fingerprintSize), func(t *testing.T) {
+			filter := cuckoofilter.NewFilter(1024, 4, fingerprintSize)
+			for i := 0; i < 500; i++ {
+				require.True(t, filter.Insert([]byte(fmt.Sprintf("revoked-%d", i))))
+			}
+
+			path := filepath.Join(t.TempDir(), "filter.flat")
+			require.NoError(t, snapshot.WriteFlatFile(path, filter))
+			flat, err := snapshot.OpenFlatFile(path)
+			require.NoError(t, err)
+			defer flat.Close()
+
+			require.Equal(t, uint64(filter.Count), flat.Count())
+			for i := 0; i < 1000; i++ {
+				data := []byte(fmt.Sprintf("revoked-%d", i))
+				require.Equal(t, filter.Lookup(data), flat.Lookup(data), "lookup of %s", data)
+			}
+		})
+	}
+}
+
+func TestFlatFile_Empty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filter.flat")
+	require.NoError(t, snapshot.WriteFlatFile(path, cuckoofilter.NewFilter(16, 4, cuckoofilter.FingerPrintSize)))
+	flat, err := snapshot.OpenFlatFile(path)
+	require.NoError(t, err)
+	defer flat.Close()
+	require.False(t, flat.Lookup([]byte("anything")))
+}
+
+func TestOpenFlatFile_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	notFlat := filepath.Join(dir, "filter.json")
+	require.NoError(t, os.WriteFile(notFlat, []byte(`{"Buckets":[]}`), 0644))
+	_, err := snapshot.OpenFlatFile(notFlat)
+	require.Error(t, err)
+
+	path := filepath.Join(dir, "filter.flat")
+	require.NoError(t, snapshot.WriteFlatFile(path, cuckoofilter.NewFilter(16, 4, cuckoofilter.FingerPrintSize)))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data[:len(data)-1], 0644))
+	_, err = snapshot.OpenFlatFile(path)
+	require.Error(t, err)
+
+	// A bucket count whose size overflows is rejected rather than wrapping around
+	binary.LittleEndian.PutUint32(data[12:], 0xffffffff)
+	binary.LittleEndian.PutUint64(data[16:], 1<<62)
+	require.NoError(t, os.WriteFile(path, data, 0644))
+	_, err = snapshot.OpenFlatFile(path)
+	require.Error(t, err)
+}
+
+func TestFlatFile_ZeroFingerprint(t *testing.T) {
+	// Find an item with a zero 1-byte fingerprint
+	filter := cuckoofilter.NewFilter(16, 4, 1)
+	var zero []byte
+	for i := 0; zero == nil; i++ {
+		data := []byte(fmt.Sprintf("revoked-%d", i))
+		if _, fp := cuckoofilter.GetIndexAndFingerprint(data, filter.BucketIndexMask, 1); fp[0] == 0 {
+			zero = data
+		}
+	}
+
+	// An empty slot must not match it
+	path := filepath.Join(t.TempDir(), "filter.flat")
+	require.NoError(t, snapshot.WriteFlatFile(path, filter))
+	flat, err := snapshot.OpenFlatFile(path)
+	require.NoError(t, err)
+	require.False(t, flat.Lookup(zero))
+	require.NoError(t, flat.Close())
+
+	// and once inserted it is stored and found
+	require.True(t, filter.Insert(zero))
+	require.NoError(t, snapshot.WriteFlatFile(path, filter))
+	flat, err = snapshot.OpenFlatFile(path)
+	require.NoError(t, err)
+	defer flat.Close()
+	require.True(t, flat.Lookup(zero))
+}
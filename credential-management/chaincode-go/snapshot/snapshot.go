@@ -0,0 +1,252 @@
+// Package snapshot distributes signed cuckoo filter snapshots as static files, so verifier fleets can
+// sync the revocation registry from a directory, bucket or CDN without connecting to Fabric.
+//
+// A published snapshot consists of these files:
+//   - filter-<sha256>.json: the serialized filter, content-addressed and therefore cacheable forever
+//   - filter-<sha256>.json.gz, filter-<sha256>.json.zst: compressed copies in the codecs the publisher
+//     offers, named after the hash of the compressed file, from which consumers pick one by preference
+//   - manifest.json: version, block height and hash of the current snapshot, to be served with a short cache lifetime
+//   - manifest.json.sig: ASN.1 ECDSA P-256 signature over the SHA-256 of manifest.json
+//   - manifest-<version>.json and manifest-<version>.json.sig: immutable copies of the manifest and its
+//     signature, used to look up the registry as of an earlier block height
+package snapshot
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// File names of a published snapshot
+const (
+	ManifestFile          = "manifest.json"
+	ManifestSignatureFile = "manifest.json.sig"
+)
+
+// ManifestVersionFile returns the name of the immutable copy of a manifest version
+func ManifestVersionFile(version uint64) string {
+	return "manifest-" + strconv.FormatUint(version, 10) + ".json"
+}
+
+// Manifest describes the current snapshot
+type Manifest struct {
+	Version     uint64     `json:"version"`     // Incremented on every published snapshot
+	BlockHeight uint64     `json:"blockHeight"` // Height of the block the snapshot was read at
+	File        string     `json:"file"`        // Name of the snapshot file
+	SHA256      string     `json:"sha256"`      // Hex encoded hash of the snapshot file
+	Size        int        `json:"size"`
+	Encodings   []Encoding `json:"encodings,omitempty"` // Compressed copies of the snapshot file
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// Codecs returns the names of the codecs the snapshot is published in, the uncompressed file last
+func (m *Manifest) Codecs() []string {
+	names := make([]string, 0, len(m.Encodings)+1)
+	for _, encoding := range m.Encodings {
+		names = append(names, encoding.Codec)
+	}
+	return append(names, CodecNone)
+}
+
+// Source reads the serialized filter and the block height it was read at, e.g. by querying the chaincode
+type Source func() (filterJSON []byte, blockHeight uint64, err error)
+
+// Store writes published files and reads them back, e.g. a local directory or an S3-compatible bucket.
+// Get must return an error matching fs.ErrNotExist for a file that was never written.
+type Store interface {
+	Fetcher
+	Put(name string, data []byte) error
+}
+
+// Publisher signs and writes filter snapshots to a store
+type Publisher struct {
+	Source     Source
+	Store      Store
+	PrivateKey *ecdsa.PrivateKey
+	Codecs     []string // Compressed copies to publish besides the uncompressed file, e.g. CodecZstd and CodecGzip
+
+	mu       sync.Mutex
+	loaded   bool
+	manifest *Manifest
+}
+
+// Publish reads the current filter and publishes it if it changed since the last published snapshot.
+// It returns the current manifest and whether a new snapshot was written.
+func (p *Publisher) Publish() (*Manifest, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.loaded {
+		if err := p.load(); err != nil {
+			return nil, false, err
+		}
+	}
+	filterJSON, blockHeight, err := p.Source()
+	if err != nil {
+		return nil, false, fmt.Errorf("error reading filter snapshot: %v", err)
+	}
+	hash := sha256.Sum256(filterJSON)
+	hashHex := hex.EncodeToString(hash[:])
+	if p.manifest != nil && p.manifest.SHA256 == hashHex {
+		return p.manifest, false, nil
+	}
+	// Snapshots are looked up by height with a search over the versions, see History
+	if p.manifest != nil && blockHeight < p.manifest.BlockHeight {
+		return nil, false, fmt.Errorf("snapshot was read at block height %d, below the published height %d", blockHeight, p.manifest.BlockHeight)
+	}
+
+	manifest := &Manifest{
+		Version:     1,
+		BlockHeight: blockHeight,
+		File:        "filter-" + hashHex + ".json",
+		SHA256:      hashHex,
+		Size:        len(filterJSON),
+		CreatedAt:   time.Now().UTC(),
+	}
+	if p.manifest != nil {
+		manifest.Version = p.manifest.Version + 1
+	}
+	encoded := make(map[string][]byte, len(p.Codecs))
+	for _, name := range p.Codecs {
+		codec, err := LookupCodec(name)
+		if err != nil {
+			return nil, false, err
+		}
+		data, err := codec.Encode(filterJSON)
+		if err != nil {
+			return nil, false, fmt.Errorf("error compressing snapshot with %s: %v", name, err)
+		}
+		encodedHash := sha256.Sum256(data)
+		encodedHashHex := hex.EncodeToString(encodedHash[:])
+		encoding := Encoding{Codec: name, File: "filter-" + encodedHashHex + ".json" + codec.Extension(), SHA256: encodedHashHex, Size: len(data)}
+		manifest.Encodings = append(manifest.Encodings, encoding)
+		encoded[encoding.File] = data
+	}
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, false, err
+	}
+	manifestHash := sha256.Sum256(manifestJSON)
+	signature, err := ecdsa.SignASN1(rand.Reader, p.PrivateKey, manifestHash[:])
+	if err != nil {
+		return nil, false, fmt.Errorf("error signing manifest: %v", err)
+	}
+
+	// The snapshot goes first so a manifest never points to a missing file
+	if err := p.Store.Put(manifest.File, filterJSON); err != nil {
+		return nil, false, fmt.Errorf("error writing snapshot: %v", err)
+	}
+	for _, encoding := range manifest.Encodings {
+		if err := p.Store.Put(encoding.File, encoded[encoding.File]); err != nil {
+			return nil, false, fmt.Errorf("error writing %s snapshot: %v", encoding.Codec, err)
+		}
+	}
+	// Published versions are immutable, finding one means another publisher writes to the store
+	versionFile := ManifestVersionFile(manifest.Version)
+	if _, err := p.Store.Get(versionFile); !errors.Is(err, fs.ErrNotExist) {
+		p.loaded = false
+		if err != nil {
+			return nil, false, fmt.Errorf("error checking for %s: %v", versionFile, err)
+		}
+		return nil, false, fmt.Errorf("%s was already published", versionFile)
+	}
+	if err := p.Store.Put(versionFile+".sig", signature); err != nil {
+		return nil, false, fmt.Errorf("error writing manifest signature: %v", err)
+	}
+	if err := p.Store.Put(versionFile, manifestJSON); err != nil {
+		return nil, false, fmt.Errorf("error writing manifest: %v", err)
+	}
+	if err := p.Store.Put(ManifestSignatureFile, signature); err != nil {
+		return nil, false, fmt.Errorf("error writing manifest signature: %v", err)
+	}
+	if err := p.Store.Put(ManifestFile, manifestJSON); err != nil {
+		return nil, false, fmt.Errorf("error writing manifest: %v", err)
+	}
+
+	p.manifest = manifest
+	return manifest, true, nil
+}
+
+// load continues from the snapshot last published to the store, so a restarted publisher neither
+// restarts the versions at 1 nor republishes an unchanged filter. A version published after
+// manifest.json, by a publisher that stopped before updating it, becomes the current manifest.
+func (p *Publisher) load() error {
+	manifest, err := p.loadManifest(ManifestFile)
+	if err != nil {
+		return err
+	}
+	next := uint64(1)
+	if manifest != nil {
+		next = manifest.Version + 1
+	}
+	for updated := false; ; next, updated = next+1, true {
+		newer, err := p.loadManifest(ManifestVersionFile(next))
+		if err != nil {
+			return err
+		}
+		if newer == nil {
+			if updated {
+				if err := p.putCurrent(manifest); err != nil {
+					return err
+				}
+			}
+			break
+		}
+		manifest = newer
+	}
+	p.manifest, p.loaded = manifest, true
+	return nil
+}
+
+// loadManifest reads a published manifest and checks it is signed with the publisher's key, it
+// returns nil if the manifest does not exist
+func (p *Publisher) loadManifest(name string) (*Manifest, error) {
+	if _, err := p.Store.Get(name); errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	manifest, err := fetchManifest(p.Store, &p.PrivateKey.PublicKey, name)
+	if err != nil {
+		return nil, fmt.Errorf("error loading published %s: %v", name, err)
+	}
+	return manifest, nil
+}
+
+// putCurrent makes a published manifest version the current one
+func (p *Publisher) putCurrent(manifest *Manifest) error {
+	versionFile := ManifestVersionFile(manifest.Version)
+	for _, name := range []string{versionFile + ".sig", versionFile} {
+		data, err := p.Store.Get(name)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %v", name, err)
+		}
+		if err := p.Store.Put(strings.Replace(name, versionFile, ManifestFile, 1), data); err != nil {
+			return fmt.Errorf("error writing manifest: %v", err)
+		}
+	}
+	return nil
+}
+
+// Run publishes every interval until stop is closed, reporting errors to onError
+func (p *Publisher) Run(interval time.Duration, stop <-chan struct{}, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, _, err := p.Publish(); err != nil && onError != nil {
+			onError(err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
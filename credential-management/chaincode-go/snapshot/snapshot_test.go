@@ -0,0 +1,169 @@
+package snapshot_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/pherbke/credential-management/chaincode-go/snapshot"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+// filterSource serves the given filter at an increasing block height
+func filterSource(t *testing.T, filter *cuckoofilter.Filter) snapshot.Source {
+	height := uint64(0)
+	return func() ([]byte, uint64, error) {
+		height++
+		filterJSON, err := json.Marshal(filter)
+		require.NoError(t, err)
+		return filterJSON, height, nil
+	}
+}
+
+func TestPublishAndDownload(t *testing.T) {
+	key := newKey(t)
+	dir := snapshot.Dir(t.TempDir())
+	filter := cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize)
+	require.True(t, filter.Insert([]byte("revoked")))
+
+	publisher := &snapshot.Publisher{Source: filterSource(t, filter), Store: dir, PrivateKey: key}
+	manifest, published, err := publisher.Publish()
+	require.NoError(t, err)
+	require.True(t, published)
+	require.Equal(t, uint64(1), manifest.Version)
+	require.Equal(t, uint64(1), manifest.BlockHeight)
+
+	downloader := &snapshot.Downloader{Fetcher: dir, PublicKey: &key.PublicKey}
+	downloaded, downloadedManifest, err := downloader.Download()
+	require.NoError(t, err)
+	require.Equal(t, manifest.SHA256, downloadedManifest.SHA256)
+	require.True(t, downloaded.Lookup([]byte("revoked")))
+
+	// Nothing new to load until the filter changes
+	_, published, err = publisher.Publish()
+	require.NoError(t, err)
+	require.False(t, published)
+	downloaded, _, err = downloader.Download()
+	require.NoError(t, err)
+	require.Nil(t, downloaded)
+
+	require.True(t, filter.Insert([]byte("revoked-later")))
+	manifest, published, err = publisher.Publish()
+	require.NoError(t, err)
+	require.True(t, published)
+	require.Equal(t, uint64(2), manifest.Version)
+	downloaded, _, err = downloader.Download()
+	require.NoError(t, err)
+	require.True(t, downloaded.Lookup([]byte("revoked-later")))
+}
+
+func TestPublish_Restart(t *testing.T) {
+	key := newKey(t)
+	dir := snapshot.Dir(t.TempDir())
+	filter := cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize)
+	publish := func() (*snapshot.Manifest, bool) {
+		// Every publish runs in a new publisher, as after a restart
+		publisher := &snapshot.Publisher{Source: filterSource(t, filter), Store: dir, PrivateKey: key}
+		manifest, published, err := publisher.Publish()
+		require.NoError(t, err)
+		return manifest, published
+	}
+
+	manifest, published := publish()
+	require.True(t, published)
+	require.Equal(t, uint64(1), manifest.Version)
+	first, err := dir.Get(snapshot.ManifestVersionFile(1))
+	require.NoError(t, err)
+
+	// An unchanged filter is not published again, a changed one continues the versions
+	manifest, published = publish()
+	require.False(t, published)
+	require.Equal(t, uint64(1), manifest.Version)
+	require.True(t, filter.Insert([]byte("revoked")))
+	manifest, published = publish()
+	require.True(t, published)
+	require.Equal(t, uint64(2), manifest.Version)
+	unchanged, err := dir.Get(snapshot.ManifestVersionFile(1))
+	require.NoError(t, err)
+	require.Equal(t, first, unchanged)
+
+	// A publisher that stopped before updating manifest.json is completed on restart
+	firstSignature, err := dir.Get(snapshot.ManifestVersionFile(1) + ".sig")
+	require.NoError(t, err)
+	require.NoError(t, dir.Put(snapshot.ManifestSignatureFile, firstSignature))
+	require.NoError(t, dir.Put(snapshot.ManifestFile, first))
+	manifest, published = publish()
+	require.False(t, published)
+	require.Equal(t, uint64(2), manifest.Version)
+	downloader := &snapshot.Downloader{Fetcher: dir, PublicKey: &key.PublicKey}
+	_, manifest, err = downloader.Download()
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), manifest.Version)
+
+	// Published versions are never overwritten, e.g. by a second publisher writing to the store
+	publisher := &snapshot.Publisher{Source: filterSource(t, filter), Store: dir, PrivateKey: key}
+	_, published, err = publisher.Publish()
+	require.NoError(t, err)
+	require.False(t, published)
+	require.NoError(t, dir.Put(snapshot.ManifestVersionFile(3), []byte("published elsewhere")))
+	require.True(t, filter.Insert([]byte("revoked again")))
+	_, _, err = publisher.Publish()
+	require.ErrorContains(t, err, "already published")
+	foreign, err := dir.Get(snapshot.ManifestVersionFile(3))
+	require.NoError(t, err)
+	require.Equal(t, []byte("published elsewhere"), foreign)
+}
+
+func TestDownload_WrongKey(t *testing.T) {
+	dir := snapshot.Dir(t.TempDir())
+	publisher := &snapshot.Publisher{Source: filterSource(t, cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize)), Store: dir, PrivateKey: newKey(t)}
+	_, _, err := publisher.Publish()
+	require.NoError(t, err)
+
+	downloader := &snapshot.Downloader{Fetcher: dir, PublicKey: &newKey(t).PublicKey}
+	_, _, err = downloader.Download()
+	require.Error(t, err)
+}
+
+func TestDownload_TamperedSnapshot(t *testing.T) {
+	key := newKey(t)
+	dir := snapshot.Dir(t.TempDir())
+	publisher := &snapshot.Publisher{Source: filterSource(t, cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize)), Store: dir, PrivateKey: key}
+	manifest, _, err := publisher.Publish()
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(string(dir), manifest.File), []byte(`{"Buckets":[]}`), 0644))
+	downloader := &snapshot.Downloader{Fetcher: dir, PublicKey: &key.PublicKey}
+	_, _, err = downloader.Download()
+	require.Error(t, err)
+}
+
+func TestDownload_HTTP(t *testing.T) {
+	key := newKey(t)
+	root := t.TempDir()
+	filter := cuckoofilter.NewFilter(100, 4, cuckoofilter.FingerPrintSize)
+	require.True(t, filter.Insert([]byte("revoked")))
+	publisher := &snapshot.Publisher{Source: filterSource(t, filter), Store: snapshot.Dir(root), PrivateKey: key}
+	_, _, err := publisher.Publish()
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.FileServer(http.Dir(root)))
+	defer server.Close()
+
+	downloader := &snapshot.Downloader{Fetcher: &snapshot.HTTPFetcher{BaseURL: server.URL}, PublicKey: &key.PublicKey}
+	downloaded, _, err := downloader.Download()
+	require.NoError(t, err)
+	require.True(t, downloaded.Lookup([]byte("revoked")))
+}
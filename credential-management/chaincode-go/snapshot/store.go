@@ -0,0 +1,71 @@
+package snapshot
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Dir is a local directory used as Store and Fetcher, e.g. the document root of a web server or CDN origin
+type Dir string
+
+// Put writes the file atomically so readers never see a partial file
+func (d Dir) Put(name string, data []byte) error {
+	if err := os.MkdirAll(string(d), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(string(d), "."+name+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(string(d), name))
+}
+
+// Get reads a published file
+func (d Dir) Get(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(string(d), filepath.Base(name)))
+}
+
+// Fetcher reads published files, e.g. from a directory or over HTTP from a CDN
+type Fetcher interface {
+	Get(name string) ([]byte, error)
+}
+
+// HTTPFetcher downloads published files relative to a base URL
+type HTTPFetcher struct {
+	BaseURL string
+	Client  *http.Client // Optional, defaults to http.DefaultClient
+}
+
+// Get downloads a published file
+func (f *HTTPFetcher) Get(name string) ([]byte, error) {
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	url := strings.TrimSuffix(f.BaseURL, "/") + "/" + name
+	response, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error downloading %s: %s", url, response.Status)
+	}
+	return io.ReadAll(response.Body)
+}
@@ -0,0 +1,117 @@
+// Package statistics publishes registry statistics with differential privacy. Public endpoints return
+// revocation counts with Laplace noise calibrated to a privacy budget epsilon, authorized auditors
+// get the exact values.
+//
+// Noise is drawn off-chain: chaincode must be deterministic across endorsers, so the ledger only
+// ever returns exact values.
+package statistics
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pherbke/credential-management/chaincode-go/gateway"
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+)
+
+// Laplace draws a sample from a Laplace distribution centered at 0 with the given scale
+func Laplace(r *rand.Rand, scale float64) float64 {
+	u := r.Float64() - 0.5
+	if u < 0 {
+		return scale * math.Log(1+2*u)
+	}
+	return -scale * math.Log(1-2*u)
+}
+
+// Mechanism adds Laplace noise with scale Sensitivity/Epsilon to revocation counts.
+//
+// The noisy value of a count is remembered and released again as long as the exact count does not
+// change, so repeated queries cannot average the noise away.
+type Mechanism struct {
+	Epsilon     float64    // Privacy budget per count, smaller values add more noise
+	Sensitivity float64    // Change of one count by adding or removing one revocation, defaults to 1
+	Rand        *rand.Rand // Optional, defaults to a time-seeded source
+
+	mu       sync.Mutex
+	released map[releasedKey]int
+}
+
+type releasedKey struct {
+	issuerDID, day string
+	revocations    int
+}
+
+// Apply returns a copy of the statistics with noisy counts. Noisy counts are rounded and never negative.
+func (m *Mechanism) Apply(statistics []cuckoofilter.RevocationStatistic) ([]cuckoofilter.RevocationStatistic, error) {
+	if m.Epsilon <= 0 {
+		return nil, fmt.Errorf("epsilon must be positive")
+	}
+	sensitivity := m.Sensitivity
+	if sensitivity == 0 {
+		sensitivity = 1
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Rand == nil {
+		m.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	if m.released == nil {
+		m.released = make(map[releasedKey]int)
+	}
+
+	noisy := make([]cuckoofilter.RevocationStatistic, len(statistics))
+	for i, statistic := range statistics {
+		key := releasedKey{statistic.IssuerDID, statistic.Day, statistic.Revocations}
+		revocations, ok := m.released[key]
+		if !ok {
+			revocations = int(math.Max(0, math.Round(float64(statistic.Revocations)+Laplace(m.Rand, sensitivity/m.Epsilon))))
+			m.released[key] = revocations
+		}
+		noisy[i] = statistic
+		noisy[i].Revocations = revocations
+	}
+	return noisy, nil
+}
+
+// Response is the body returned by Handler
+type Response struct {
+	Exact      bool                               `json:"exact"`             // False when noise was added
+	Epsilon    float64                            `json:"epsilon,omitempty"` // Privacy budget of noisy values
+	Statistics []cuckoofilter.RevocationStatistic `json:"statistics"`
+}
+
+// Handler serves revocation statistics over HTTP, adding noise for everyone but auditors
+type Handler struct {
+	Source    func() ([]cuckoofilter.RevocationStatistic, error) // Reads the exact values, e.g. by evaluating GetRevocationStatistics
+	Mechanism *Mechanism                                         // Nil serves exact values to everyone
+	Auditor   func(r *http.Request) bool                         // Optional, reports whether the caller may see exact values
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	statistics, err := h.Source()
+	if err != nil {
+		gateway.WriteProblem(w, fmt.Errorf("error reading statistics: %w", err), "")
+		return
+	}
+
+	response := Response{Exact: true, Statistics: statistics}
+	if h.Mechanism != nil && (h.Auditor == nil || !h.Auditor(r)) {
+		noisy, err := h.Mechanism.Apply(statistics)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		response = Response{Epsilon: h.Mechanism.Epsilon, Statistics: noisy}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
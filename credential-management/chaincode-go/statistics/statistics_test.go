@@ -0,0 +1,86 @@
+package statistics_test
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	cuckoofilter "github.com/pherbke/credential-management/chaincode-go/smart-contract"
+	"github.com/pherbke/credential-management/chaincode-go/statistics"
+)
+
+func exactStatistics() []cuckoofilter.RevocationStatistic {
+	return []cuckoofilter.RevocationStatistic{
+		{IssuerDID: "did:key:issuer1", Day: "2024-03-01", Revocations: 120},
+		{IssuerDID: "did:key:issuer2", Day: "2024-03-01", Revocations: 3},
+	}
+}
+
+func TestLaplace_Distribution(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	const samples, scale = 100000, 2.0
+	var sum, absSum float64
+	for i := 0; i < samples; i++ {
+		x := statistics.Laplace(r, scale)
+		sum += x
+		absSum += math.Abs(x)
+	}
+	// A Laplace distribution has mean 0 and mean absolute deviation equal to its scale
+	require.InDelta(t, 0, sum/samples, 0.05)
+	require.InDelta(t, scale, absSum/samples, 0.05)
+}
+
+func TestMechanism_Apply(t *testing.T) {
+	m := &statistics.Mechanism{Epsilon: 0.5, Rand: rand.New(rand.NewSource(1))}
+	noisy, err := m.Apply(exactStatistics())
+	require.NoError(t, err)
+	require.Len(t, noisy, 2)
+	for i, statistic := range noisy {
+		require.Equal(t, exactStatistics()[i].IssuerDID, statistic.IssuerDID)
+		require.GreaterOrEqual(t, statistic.Revocations, 0)
+	}
+
+	// Repeating the query releases the same noisy values
+	again, err := m.Apply(exactStatistics())
+	require.NoError(t, err)
+	require.Equal(t, noisy, again)
+}
+
+func TestMechanism_NoBudget(t *testing.T) {
+	_, err := (&statistics.Mechanism{}).Apply(exactStatistics())
+	require.Error(t, err)
+}
+
+func TestHandler(t *testing.T) {
+	handler := &statistics.Handler{
+		Source:    func() ([]cuckoofilter.RevocationStatistic, error) { return exactStatistics(), nil },
+		Mechanism: &statistics.Mechanism{Epsilon: 0.01, Rand: rand.New(rand.NewSource(1))},
+		Auditor:   func(r *http.Request) bool { return r.Header.Get("X-Auditor") == "yes" },
+	}
+	get := func(auditor bool) statistics.Response {
+		request := httptest.NewRequest(http.MethodGet, "/statistics", nil)
+		if auditor {
+			request.Header.Set("X-Auditor", "yes")
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		var response statistics.Response
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+		return response
+	}
+
+	public := get(false)
+	require.False(t, public.Exact)
+	require.Equal(t, 0.01, public.Epsilon)
+	require.NotEqual(t, exactStatistics(), public.Statistics)
+
+	audited := get(true)
+	require.True(t, audited.Exact)
+	require.Equal(t, exactStatistics(), audited.Statistics)
+}
@@ -0,0 +1,107 @@
+package verifier
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCacheStale is returned by a CachedRegistry that is too stale to answer and has no live fallback
+var ErrCacheStale = errors.New("revocation cache is stale")
+
+// FilterLookup is a local copy of the revocation filter, e.g. a *cuckoofilter.Filter or *snapshot.FlatFilter
+type FilterLookup interface {
+	Lookup(data []byte) bool
+}
+
+// CacheStats reports how a CachedRegistry answered lookups, e.g. to export them as metrics
+type CacheStats struct {
+	Stale         bool          `json:"stale"`
+	Staleness     time.Duration `json:"staleness"`     // Time since the last sync
+	StaleLookups  uint64        `json:"staleLookups"`  // Lookups made while the cache was stale
+	LiveLookups   uint64        `json:"liveLookups"`   // Stale lookups answered by the live ledger
+	FailedClosed  uint64        `json:"failedClosed"`  // Stale lookups refused without a live fallback
+	CachedLookups uint64        `json:"cachedLookups"` // Lookups answered from the cache
+}
+
+// CachedRegistry answers lookups from a local filter as long as it was synced within MaxStaleness.
+// Once the sync falls behind, lookups go to the live ledger or, without a live lookup, fail closed,
+// so recently revoked credentials are never accepted from an outdated cache.
+type CachedRegistry struct {
+	MaxStaleness time.Duration
+	Live         RegistryLookup   // Optional live ledger query used while the cache is stale
+	OnStale      func(CacheStats) // Optional alert hook, called when the cache turns stale
+
+	mu       sync.Mutex
+	filter   FilterLookup
+	syncedAt time.Time
+	stale    bool
+	stats    CacheStats
+}
+
+// Update replaces the cached filter with one synced at the given time, e.g. after a snapshot download or event
+func (c *CachedRegistry) Update(filter FilterLookup, syncedAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.filter = filter
+	c.syncedAt = syncedAt
+	c.stale = false
+}
+
+// Touch records that the cache is up to date without replacing the filter, e.g. when a sync found no changes
+func (c *CachedRegistry) Touch(syncedAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.syncedAt = syncedAt
+	c.stale = false
+}
+
+// Lookup is a RegistryLookup that enforces the staleness bound
+func (c *CachedRegistry) Lookup(fingerprint string) (bool, error) {
+	c.mu.Lock()
+	if c.filter != nil && time.Since(c.syncedAt) <= c.MaxStaleness {
+		c.stats.CachedLookups++
+		filter := c.filter
+		c.mu.Unlock()
+		return filter.Lookup([]byte(fingerprint)), nil
+	}
+
+	c.stats.StaleLookups++
+	var alert func(CacheStats)
+	if !c.stale {
+		c.stale = true
+		alert = c.OnStale
+	}
+	if c.Live == nil {
+		c.stats.FailedClosed++
+	} else {
+		c.stats.LiveLookups++
+	}
+	stats := c.statsLocked()
+	c.mu.Unlock()
+
+	if alert != nil {
+		alert(stats)
+	}
+	if c.Live == nil {
+		return false, ErrCacheStale
+	}
+	return c.Live(fingerprint)
+}
+
+// Stats returns the current staleness and lookup counters
+func (c *CachedRegistry) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.statsLocked()
+}
+
+// statsLocked returns the stats, the caller must hold c.mu
+func (c *CachedRegistry) statsLocked() CacheStats {
+	stats := c.stats
+	if c.filter != nil {
+		stats.Staleness = time.Since(c.syncedAt)
+	}
+	stats.Stale = c.filter == nil || stats.Staleness > c.MaxStaleness
+	return stats
+}
@@ -0,0 +1,80 @@
+package verifier_test
+
+import (
+	"github.com/pherbke/credential-management/chaincode-go/verifier"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// filterOf is a FilterLookup holding a fixed set of revoked fingerprints
+type filterOf map[string]bool
+
+func (f filterOf) Lookup(data []byte) bool {
+	return f[string(data)]
+}
+
+func TestCachedRegistry_Fresh(t *testing.T) {
+	calls := 0
+	cache := &verifier.CachedRegistry{MaxStaleness: time.Minute, Live: registry(false, &calls)}
+	cache.Update(filterOf{"revoked": true}, time.Now())
+
+	revoked, err := cache.Lookup("revoked")
+	require.NoError(t, err)
+	require.True(t, revoked)
+	require.Equal(t, 0, calls)
+	require.Equal(t, uint64(1), cache.Stats().CachedLookups)
+	require.False(t, cache.Stats().Stale)
+}
+
+func TestCachedRegistry_StaleFallsThroughToLive(t *testing.T) {
+	calls := 0
+	var alerts []verifier.CacheStats
+	cache := &verifier.CachedRegistry{
+		MaxStaleness: 30 * time.Second,
+		Live:         registry(true, &calls),
+		OnStale:      func(stats verifier.CacheStats) { alerts = append(alerts, stats) },
+	}
+	cache.Update(filterOf{}, time.Now().Add(-time.Minute))
+
+	for i := 0; i < 2; i++ {
+		revoked, err := cache.Lookup("recently-revoked")
+		require.NoError(t, err)
+		require.True(t, revoked)
+	}
+	require.Equal(t, 2, calls)
+	require.Len(t, alerts, 1, "the alert fires once per stale period")
+	require.True(t, alerts[0].Stale)
+
+	stats := cache.Stats()
+	require.Equal(t, uint64(2), stats.StaleLookups)
+	require.Equal(t, uint64(2), stats.LiveLookups)
+
+	// A sync brings the cache back
+	cache.Touch(time.Now())
+	_, err := cache.Lookup("recently-revoked")
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+}
+
+func TestCachedRegistry_FailsClosed(t *testing.T) {
+	cache := &verifier.CachedRegistry{MaxStaleness: 30 * time.Second}
+	cache.Update(filterOf{}, time.Now().Add(-time.Minute))
+
+	_, err := cache.Lookup("credential")
+	require.ErrorIs(t, err, verifier.ErrCacheStale)
+	require.Equal(t, uint64(1), cache.Stats().FailedClosed)
+
+	// A verifier using the stale cache rejects instead of accepting
+	v := &verifier.Verifier{Registry: cache.Lookup}
+	decision, err := v.Check(testCredential)
+	require.Error(t, err)
+	require.False(t, decision.Accepted)
+}
+
+func TestCachedRegistry_NeverSynced(t *testing.T) {
+	cache := &verifier.CachedRegistry{MaxStaleness: time.Minute}
+	_, err := cache.Lookup("credential")
+	require.ErrorIs(t, err, verifier.ErrCacheStale)
+	require.True(t, cache.Stats().Stale)
+}
@@ -0,0 +1,67 @@
+package verifier
+
+import (
+	"sync"
+	"time"
+)
+
+// Failure modes of a Verifier, deciding what happens when the registry lookup fails
+const (
+	FailHard            = "hard-fail"         // Reject the credential, the default
+	FailSoft            = "soft-fail"         // Accept the credential, flagged as degraded
+	FailCachedWithinSLA = "cached-within-sla" // Answer from the cache if it was synced within the SLA, else reject
+)
+
+// Outcome codes of decisions taken while the registry was unreachable
+const (
+	OutcomeRegistryUnavailable = "registry_unavailable" // Rejected, hard-fail or cache outside the SLA
+	OutcomeAcceptedUnchecked   = "accepted_unchecked"   // Accepted without a revocation check, soft-fail
+	OutcomeAcceptedCached      = "accepted_cached"      // Accepted on the cached status, cached-within-sla
+)
+
+// CheckCache is the name of the cache lookup recorded in a decision
+const CheckCache = "cache"
+
+// FailureMetrics counts how often a Verifier decided without a registry answer, e.g. to export them
+// as metrics and alert when degraded decisions become frequent
+type FailureMetrics struct {
+	RegistryFailures uint64 `json:"registryFailures"` // Failed registry lookups
+	HardFailures     uint64 `json:"hardFailures"`     // Credentials rejected because of a failed lookup
+	SoftFailures     uint64 `json:"softFailures"`     // Credentials accepted without a revocation check
+	CachedDecisions  uint64 `json:"cachedDecisions"`  // Decisions taken on the cached status
+	CacheOutsideSLA  uint64 `json:"cacheOutsideSLA"`  // Failed lookups the cache could not answer within the SLA
+}
+
+// failureCounters holds the FailureMetrics of a Verifier
+type failureCounters struct {
+	mu      sync.Mutex
+	metrics FailureMetrics
+}
+
+func (c *failureCounters) count(update func(*FailureMetrics)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	update(&c.metrics)
+}
+
+func (c *failureCounters) get() FailureMetrics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.metrics
+}
+
+// LookupWithin answers from the cached filter only, and only if it was synced within maxAge. It never
+// falls through to the live ledger, so a Verifier can use it when the ledger is the part that failed.
+func (c *CachedRegistry) LookupWithin(fingerprint string, maxAge time.Duration) (revoked bool, ok bool) {
+	c.mu.Lock()
+	filter := c.filter
+	fresh := filter != nil && time.Since(c.syncedAt) <= maxAge
+	if fresh {
+		c.stats.CachedLookups++
+	}
+	c.mu.Unlock()
+	if !fresh {
+		return false, false
+	}
+	return filter.Lookup([]byte(fingerprint)), true
+}
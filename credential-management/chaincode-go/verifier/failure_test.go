@@ -0,0 +1,95 @@
+package verifier_test
+
+import (
+	"errors"
+	"github.com/pherbke/credential-management/chaincode-go/verifier"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// unreachable is a registry lookup that always fails
+func unreachable(string) (bool, error) {
+	return false, errors.New("peer unavailable")
+}
+
+func TestFailureMode_HardFail(t *testing.T) {
+	var records []verifier.DecisionRecord
+	v := &verifier.Verifier{Registry: unreachable, FailureMode: verifier.FailHard, Telemetry: recordingSink(&records)}
+
+	decision, err := v.Check(testCredential)
+	require.Error(t, err)
+	require.False(t, decision.Accepted)
+	require.True(t, decision.Degraded)
+	require.Equal(t, verifier.OutcomeRegistryUnavailable, decision.Outcome)
+	require.Equal(t, verifier.FailureMetrics{RegistryFailures: 1, HardFailures: 1}, v.FailureMetrics())
+	require.True(t, records[0].Degraded)
+
+	// Hard-fail is the default
+	v = &verifier.Verifier{Registry: unreachable}
+	decision, err = v.Check(testCredential)
+	require.Error(t, err)
+	require.Equal(t, verifier.OutcomeRegistryUnavailable, decision.Outcome)
+}
+
+func TestFailureMode_SoftFail(t *testing.T) {
+	v := &verifier.Verifier{Registry: unreachable, FailureMode: verifier.FailSoft}
+
+	decision, err := v.Check(testCredential)
+	require.NoError(t, err)
+	require.True(t, decision.Accepted)
+	require.True(t, decision.Degraded)
+	require.False(t, decision.RegistryChecked)
+	require.Equal(t, verifier.OutcomeAcceptedUnchecked, decision.Outcome)
+	require.Contains(t, decision.Warning, "peer unavailable")
+	require.Equal(t, verifier.FailureMetrics{RegistryFailures: 1, SoftFailures: 1}, v.FailureMetrics())
+
+	// Policy rejections come first and are not degraded
+	v.Policies = &verifier.Policy{DenyIssuers: []string{testCredential.Issuer}}
+	decision, err = v.Check(testCredential)
+	require.NoError(t, err)
+	require.False(t, decision.Degraded)
+	require.Equal(t, verifier.OutcomeDeniedByPolicy, decision.Outcome)
+}
+
+func TestFailureMode_CachedWithinSLA(t *testing.T) {
+	cache := &verifier.CachedRegistry{MaxStaleness: time.Hour}
+	cache.Update(filterOf{"revoked": true}, time.Now().Add(-10*time.Minute))
+	v := &verifier.Verifier{Registry: unreachable, FailureMode: verifier.FailCachedWithinSLA, Cache: cache}
+
+	decision, err := v.Check(testCredential)
+	require.NoError(t, err)
+	require.True(t, decision.Accepted)
+	require.True(t, decision.Degraded)
+	require.Equal(t, verifier.OutcomeAcceptedCached, decision.Outcome)
+	require.Equal(t, []string{verifier.CheckDenyList, verifier.CheckAllowList, verifier.CheckRegistry, verifier.CheckCache}, decision.Checks)
+
+	revokedCredential := testCredential
+	revokedCredential.Fingerprint = "revoked"
+	decision, err = v.Check(revokedCredential)
+	require.NoError(t, err)
+	require.False(t, decision.Accepted)
+	require.True(t, decision.Degraded)
+	require.Equal(t, verifier.OutcomeRevoked, decision.Outcome)
+	require.Equal(t, verifier.FailureMetrics{RegistryFailures: 2, CachedDecisions: 2}, v.FailureMetrics())
+
+	// Outside the SLA the cache is not used, even though the cache itself would still answer
+	v.CacheSLA = 5 * time.Minute
+	decision, err = v.Check(testCredential)
+	require.Error(t, err)
+	require.False(t, decision.Accepted)
+	require.Equal(t, verifier.OutcomeRegistryUnavailable, decision.Outcome)
+	require.Equal(t, uint64(1), v.FailureMetrics().CacheOutsideSLA)
+	require.Equal(t, uint64(1), v.FailureMetrics().HardFailures)
+}
+
+func TestFailureMode_RegistryAvailable(t *testing.T) {
+	calls := 0
+	v := &verifier.Verifier{Registry: registry(false, &calls), FailureMode: verifier.FailSoft}
+
+	decision, err := v.Check(testCredential)
+	require.NoError(t, err)
+	require.False(t, decision.Degraded)
+	require.Equal(t, verifier.OutcomeAccepted, decision.Outcome)
+	require.Equal(t, verifier.FailureMetrics{}, v.FailureMetrics())
+}
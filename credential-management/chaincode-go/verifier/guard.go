@@ -0,0 +1,172 @@
+package verifier
+
+import (
+	"crypto/sha256"
+	"errors"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
+	"math/bits"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Lookup modes of a LookupGuard
+const (
+	LookupOpen          = "open"          // Any client may look up fingerprints
+	LookupProofOfWork   = "proof-of-work" // Each lookup must carry a proof-of-work nonce
+	LookupAuthenticated = "authenticated" // Only authenticated clients may look up fingerprints
+)
+
+// Errors returned by a LookupGuard when it refuses a lookup
+var (
+	ErrRateLimited        = errors.New("lookup rate limit exceeded")
+	ErrUnauthenticated    = errors.New("lookup requires an authenticated client")
+	ErrInvalidProofOfWork = errors.New("invalid proof of work")
+)
+
+// LookupRequest is a fingerprint lookup received by a public endpoint
+type LookupRequest struct {
+	ClientID      string // Client identity or, for anonymous clients, the source address
+	Authenticated bool   // Whether the endpoint authenticated the client
+	Fingerprint   string
+	Nonce         string // Proof-of-work nonce, see SolveProofOfWork
+}
+
+// ClientAudit holds the lookup counters of one client
+type ClientAudit struct {
+	ClientID    string `json:"clientId"`
+	Lookups     uint64 `json:"lookups"`
+	Revoked     uint64 `json:"revoked"`
+	RateLimited uint64 `json:"rateLimited"`
+	Rejected    uint64 `json:"rejected"`  // Refused for missing authentication or proof of work
+	Anomalous   bool   `json:"anomalous"` // Lookups reached the anomaly threshold
+}
+
+// LookupGuard protects a registry lookup exposed to untrusted clients against fingerprint enumeration.
+// It applies the lookup mode, a per-client token bucket and keeps audit counters per client.
+type LookupGuard struct {
+	Registry         RegistryLookup
+	Mode             string      // One of the Lookup* modes, empty means LookupOpen
+	Rate             float64     // Sustained lookups per second per client, 0 disables rate limiting
+	Burst            int         // Lookups a client may make at once
+	Difficulty       uint        // Leading zero bits required in LookupProofOfWork mode
+	AnomalyThreshold uint64      // Lookups after which a client is reported as anomalous, 0 disables the check
+	Clock            clock.Clock // Optional, defaults to clock.System
+
+	mu      sync.Mutex
+	clients map[string]*clientState
+}
+
+type clientState struct {
+	audit    ClientAudit
+	tokens   float64
+	lastSeen time.Time
+}
+
+// Lookup checks the request against the guard and forwards it to the registry
+func (g *LookupGuard) Lookup(request LookupRequest) (bool, error) {
+	g.mu.Lock()
+	client := g.client(request.ClientID)
+	if err := g.admit(client, request); err != nil {
+		g.mu.Unlock()
+		return false, err
+	}
+	client.audit.Lookups++
+	g.mu.Unlock()
+
+	revoked, err := g.Registry(request.Fingerprint)
+	if err != nil {
+		return false, err
+	}
+	if revoked {
+		g.mu.Lock()
+		client.audit.Revoked++
+		g.mu.Unlock()
+	}
+	return revoked, nil
+}
+
+// Audit returns the counters of all clients sorted by client ID
+func (g *LookupGuard) Audit() []ClientAudit {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	audits := make([]ClientAudit, 0, len(g.clients))
+	for _, client := range g.clients {
+		audit := client.audit
+		audit.Anomalous = g.AnomalyThreshold > 0 && audit.Lookups >= g.AnomalyThreshold
+		audits = append(audits, audit)
+	}
+	sort.Slice(audits, func(i, j int) bool {
+		return audits[i].ClientID < audits[j].ClientID
+	})
+	return audits
+}
+
+// client returns the state of a client, the caller must hold g.mu
+func (g *LookupGuard) client(clientID string) *clientState {
+	if g.clients == nil {
+		g.clients = make(map[string]*clientState)
+	}
+	client, ok := g.clients[clientID]
+	if !ok {
+		client = &clientState{audit: ClientAudit{ClientID: clientID}, tokens: float64(g.Burst), lastSeen: clock.Or(g.Clock).Now()}
+		g.clients[clientID] = client
+	}
+	return client
+}
+
+// admit applies the lookup mode and rate limit, the caller must hold g.mu
+func (g *LookupGuard) admit(client *clientState, request LookupRequest) error {
+	switch g.Mode {
+	case LookupAuthenticated:
+		if !request.Authenticated {
+			client.audit.Rejected++
+			return ErrUnauthenticated
+		}
+	case LookupProofOfWork:
+		if !VerifyProofOfWork(request.Fingerprint, request.Nonce, g.Difficulty) {
+			client.audit.Rejected++
+			return ErrInvalidProofOfWork
+		}
+	}
+
+	if g.Rate > 0 {
+		now := clock.Or(g.Clock).Now()
+		client.tokens += now.Sub(client.lastSeen).Seconds() * g.Rate
+		if client.tokens > float64(g.Burst) {
+			client.tokens = float64(g.Burst)
+		}
+		client.lastSeen = now
+		if client.tokens < 1 {
+			client.audit.RateLimited++
+			return ErrRateLimited
+		}
+		client.tokens--
+	}
+	return nil
+}
+
+// VerifyProofOfWork reports whether SHA-256(fingerprint + ":" + nonce) starts with difficulty zero bits
+func VerifyProofOfWork(fingerprint string, nonce string, difficulty uint) bool {
+	hash := sha256.Sum256([]byte(fingerprint + ":" + nonce))
+	var zeros uint
+	for _, b := range hash {
+		zeros += uint(bits.LeadingZeros8(b))
+		if b != 0 || zeros >= difficulty {
+			break
+		}
+	}
+	return zeros >= difficulty
+}
+
+// SolveProofOfWork finds a nonce for a lookup of the fingerprint at the given difficulty
+func SolveProofOfWork(fingerprint string, difficulty uint) string {
+	for i := uint64(0); ; i++ {
+		nonce := strconv.FormatUint(i, 10)
+		if VerifyProofOfWork(fingerprint, nonce, difficulty) {
+			return nonce
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package verifier_test
+
+import (
+	"github.com/pherbke/credential-management/chaincode-go/verifier"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestLookupGuard_Open(t *testing.T) {
+	calls := 0
+	guard := &verifier.LookupGuard{Registry: registry(true, &calls)}
+
+	revoked, err := guard.Lookup(verifier.LookupRequest{ClientID: "10.0.0.1", Fingerprint: "fp"})
+	require.NoError(t, err)
+	require.True(t, revoked)
+	require.Equal(t, 1, calls)
+}
+
+func TestLookupGuard_RateLimit(t *testing.T) {
+	calls := 0
+	guard := &verifier.LookupGuard{Registry: registry(false, &calls), Rate: 0.001, Burst: 2}
+
+	for i := 0; i < 2; i++ {
+		_, err := guard.Lookup(verifier.LookupRequest{ClientID: "10.0.0.1", Fingerprint: "fp"})
+		require.NoError(t, err)
+	}
+	_, err := guard.Lookup(verifier.LookupRequest{ClientID: "10.0.0.1", Fingerprint: "fp"})
+	require.ErrorIs(t, err, verifier.ErrRateLimited)
+
+	// Other clients have their own bucket
+	_, err = guard.Lookup(verifier.LookupRequest{ClientID: "10.0.0.2", Fingerprint: "fp"})
+	require.NoError(t, err)
+	require.Equal(t, 3, calls)
+}
+
+func TestLookupGuard_Authenticated(t *testing.T) {
+	calls := 0
+	guard := &verifier.LookupGuard{Registry: registry(false, &calls), Mode: verifier.LookupAuthenticated}
+
+	_, err := guard.Lookup(verifier.LookupRequest{ClientID: "10.0.0.1", Fingerprint: "fp"})
+	require.ErrorIs(t, err, verifier.ErrUnauthenticated)
+	_, err = guard.Lookup(verifier.LookupRequest{ClientID: "verifier-1", Authenticated: true, Fingerprint: "fp"})
+	require.NoError(t, err)
+	require.Equal(t, 1, calls)
+}
+
+func TestLookupGuard_ProofOfWork(t *testing.T) {
+	calls := 0
+	guard := &verifier.LookupGuard{Registry: registry(false, &calls), Mode: verifier.LookupProofOfWork, Difficulty: 8}
+
+	nonce := verifier.SolveProofOfWork("fp", 8)
+	require.True(t, verifier.VerifyProofOfWork("fp", nonce, 8))
+	_, err := guard.Lookup(verifier.LookupRequest{ClientID: "10.0.0.1", Fingerprint: "fp", Nonce: nonce})
+	require.NoError(t, err)
+
+	// A nonce is only valid for the fingerprint it was solved for
+	require.False(t, verifier.VerifyProofOfWork("other", nonce, 8))
+	_, err = guard.Lookup(verifier.LookupRequest{ClientID: "10.0.0.1", Fingerprint: "other", Nonce: nonce})
+	require.ErrorIs(t, err, verifier.ErrInvalidProofOfWork)
+	require.Equal(t, 1, calls)
+}
+
+func TestLookupGuard_Audit(t *testing.T) {
+	calls := 0
+	guard := &verifier.LookupGuard{
+		Registry:         registry(true, &calls),
+		Mode:             verifier.LookupAuthenticated,
+		AnomalyThreshold: 3,
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err := guard.Lookup(verifier.LookupRequest{ClientID: "scanner", Authenticated: true, Fingerprint: "fp"})
+		require.NoError(t, err)
+	}
+	_, err := guard.Lookup(verifier.LookupRequest{ClientID: "anonymous", Fingerprint: "fp"})
+	require.Error(t, err)
+
+	require.Equal(t, []verifier.ClientAudit{
+		{ClientID: "anonymous", Rejected: 1},
+		{ClientID: "scanner", Lookups: 3, Revoked: 3, Anomalous: true},
+	}, guard.Audit())
+}
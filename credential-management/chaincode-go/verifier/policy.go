@@ -0,0 +1,120 @@
+package verifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Policy is a verifier-local allow/deny policy layered over the revocation registry.
+// Empty allow-lists do not restrict anything.
+type Policy struct {
+	Version          string   `json:"version"`
+	AllowIssuers     []string `json:"allowIssuers"`
+	DenyIssuers      []string `json:"denyIssuers"`
+	AllowTypes       []string `json:"allowTypes"`
+	DenyTypes        []string `json:"denyTypes"`
+	AllowCredentials []string `json:"allowCredentials"` // Accepted even when the registry reports them revoked, e.g. known filter false positives
+	DenyCredentials  []string `json:"denyCredentials"`
+}
+
+// PolicySource provides the policy to apply to the next verification
+type PolicySource interface {
+	Policy() *Policy
+}
+
+// Policy lets a static policy act as its own source
+func (p *Policy) Policy() *Policy {
+	return p
+}
+
+// PolicyFile is a policy loaded from a JSON file that can be reloaded while the verifier runs
+type PolicyFile struct {
+	path    string
+	mu      sync.RWMutex
+	policy  *Policy
+	modTime time.Time
+}
+
+// LoadPolicyFile reads the policy from the given JSON file
+func LoadPolicyFile(path string) (*PolicyFile, error) {
+	f := &PolicyFile{path: path}
+	if _, err := f.Reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Policy returns the most recently loaded policy
+func (f *PolicyFile) Policy() *Policy {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.policy
+}
+
+// Reload reads the file again if it changed since the last load and reports whether the policy was replaced.
+// On error the previous policy stays active.
+func (f *PolicyFile) Reload() (bool, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return false, fmt.Errorf("error reading policy file: %v", err)
+	}
+
+	f.mu.RLock()
+	unchanged := f.policy != nil && info.ModTime().Equal(f.modTime)
+	f.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	policyJSON, err := os.ReadFile(f.path)
+	if err != nil {
+		return false, fmt.Errorf("error reading policy file: %v", err)
+	}
+	var policy Policy
+	if err := json.Unmarshal(policyJSON, &policy); err != nil {
+		return false, fmt.Errorf("error decoding policy file: %v", err)
+	}
+
+	f.mu.Lock()
+	f.policy = &policy
+	f.modTime = info.ModTime()
+	f.mu.Unlock()
+	return true, nil
+}
+
+// Watch reloads the policy file every interval until stop is closed, reporting reload errors to onError
+func (f *PolicyFile) Watch(interval time.Duration, stop <-chan struct{}, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if _, err := f.Reload(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(list []string, values []string) bool {
+	for _, v := range values {
+		if contains(list, v) {
+			return true
+		}
+	}
+	return false
+}
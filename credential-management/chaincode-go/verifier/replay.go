@@ -0,0 +1,145 @@
+package verifier
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
+	"sync"
+	"time"
+)
+
+// DefaultReplayWindow is how long a ReplayGuard remembers accepted presentations by default
+const DefaultReplayWindow = 10 * time.Minute
+
+// Outcome codes of decisions rejected by the replay guard
+const (
+	OutcomeReplayed          = "replayed"           // The presentation was already accepted within the window
+	OutcomeReplayUnavailable = "replay_unavailable" // The replay storage failed, the presentation is rejected
+)
+
+// CheckReplay is the name of the replay check recorded in a decision
+const CheckReplay = "replay"
+
+// ErrReplayed is returned by a ReplayGuard for a presentation it already accepted within its window
+var ErrReplayed = errors.New("presentation was already accepted")
+
+// Storage holds short-lived keys shared by all verifier replicas, e.g. backed by Redis SET NX with a TTL,
+// so a presentation replayed against another replica is detected as well
+type Storage interface {
+	// PutIfAbsent stores key for ttl and reports false, without changing it, if key is already stored
+	PutIfAbsent(key string, ttl time.Duration) (bool, error)
+}
+
+// MemoryStorage is a Storage for a single verifier instance and for tests
+type MemoryStorage struct {
+	Clock clock.Clock // Optional, defaults to clock.System
+
+	mu      sync.Mutex
+	expires map[string]time.Time
+}
+
+// PutIfAbsent stores key for ttl unless it is stored and not yet expired. Expired keys are dropped
+// when a key is stored, so the storage holds at most the keys of one window.
+func (s *MemoryStorage) PutIfAbsent(key string, ttl time.Duration) (bool, error) {
+	now := clock.Or(s.Clock).Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if expiresAt, ok := s.expires[key]; ok && now.Before(expiresAt) {
+		return false, nil
+	}
+	if s.expires == nil {
+		s.expires = map[string]time.Time{}
+	}
+	for stored, expiresAt := range s.expires {
+		if !now.Before(expiresAt) {
+			delete(s.expires, stored)
+		}
+	}
+	s.expires[key] = now.Add(ttl)
+	return true, nil
+}
+
+// Len returns the number of stored keys, including expired ones not yet dropped
+func (s *MemoryStorage) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.expires)
+}
+
+// Presentation is a credential presented by a holder in response to a verifier challenge
+type Presentation struct {
+	Credential Credential
+	Holder     string // DID of the holder
+	Challenge  string // Nonce or challenge the presentation was created for
+	Payload    []byte // The presentation as received, e.g. the signed JWT
+}
+
+// ReplayGuard rejects exact replays of accepted presentations. It stores a hash of the challenge,
+// holder and payload of every accepted presentation for Window; the presentation itself is not stored.
+type ReplayGuard struct {
+	Storage Storage
+	Window  time.Duration // Defaults to DefaultReplayWindow
+}
+
+// Accept records the presentation and returns ErrReplayed if it was already accepted within the window
+func (g *ReplayGuard) Accept(presentation Presentation) error {
+	window := g.Window
+	if window <= 0 {
+		window = DefaultReplayWindow
+	}
+	stored, err := g.Storage.PutIfAbsent(presentationKey(presentation), window)
+	if err != nil {
+		return fmt.Errorf("error recording presentation: %v", err)
+	}
+	if !stored {
+		return ErrReplayed
+	}
+	return nil
+}
+
+// presentationKey hashes the length-prefixed challenge, holder and payload, so no two different
+// presentations share a key by moving bytes between the fields
+func presentationKey(presentation Presentation) string {
+	hash := sha256.New()
+	for _, field := range [][]byte{[]byte(presentation.Challenge), []byte(presentation.Holder), presentation.Payload} {
+		var length [8]byte
+		binary.BigEndian.PutUint64(length[:], uint64(len(field)))
+		hash.Write(length[:])
+		hash.Write(field)
+	}
+	return "presentation:" + hex.EncodeToString(hash.Sum(nil))
+}
+
+// CheckPresentation checks the presented credential like Check and, if Replay is set, rejects exact
+// replays of presentations it accepted before. Only accepted presentations are recorded. A failure of
+// the replay storage rejects the presentation with OutcomeReplayUnavailable and returns the error.
+func (v *Verifier) CheckPresentation(presentation Presentation) (Decision, error) {
+	started := time.Now()
+	decision, err := v.check(presentation.Credential)
+	if err == nil && decision.Accepted && v.Replay != nil {
+		decision, err = v.checkReplay(decision, presentation)
+	}
+	if v.Telemetry != nil {
+		_ = v.Telemetry.Emit(newDecisionRecord(v.TelemetryKey, presentation.Credential, decision, err, started, time.Since(started)))
+	}
+	return decision, err
+}
+
+func (v *Verifier) checkReplay(decision Decision, presentation Presentation) (Decision, error) {
+	decision.Checks = append(decision.Checks, CheckReplay)
+	err := v.Replay.Accept(presentation)
+	if err == nil {
+		return decision, nil
+	}
+	decision.Accepted = false
+	decision.Overridden = false
+	if errors.Is(err, ErrReplayed) {
+		decision.Outcome = OutcomeReplayed
+		return decision, nil
+	}
+	decision.Outcome = OutcomeReplayUnavailable
+	return decision, err
+}
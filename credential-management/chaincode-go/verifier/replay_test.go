@@ -0,0 +1,103 @@
+package verifier_test
+
+import (
+	"errors"
+	"github.com/pherbke/credential-management/chaincode-go/clock"
+	"github.com/pherbke/credential-management/chaincode-go/verifier"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+var testPresentation = verifier.Presentation{
+	Credential: testCredential,
+	Holder:     "did:key:holder",
+	Challenge:  "challenge-1",
+	Payload:    []byte("eyJhbGciOiJFUzI1NiJ9.presentation.signature"),
+}
+
+// failingStorage is a Storage whose backend is unreachable
+type failingStorage struct{}
+
+func (failingStorage) PutIfAbsent(key string, ttl time.Duration) (bool, error) {
+	return false, errors.New("connection refused")
+}
+
+func TestReplayGuard_RejectsReplaysWithinWindow(t *testing.T) {
+	now := clock.NewManual(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	storage := &verifier.MemoryStorage{Clock: now}
+	guard := &verifier.ReplayGuard{Storage: storage, Window: time.Minute}
+
+	require.NoError(t, guard.Accept(testPresentation))
+	require.ErrorIs(t, guard.Accept(testPresentation), verifier.ErrReplayed)
+
+	// The same credential presented for another challenge or by another holder is no replay
+	otherChallenge := testPresentation
+	otherChallenge.Challenge = "challenge-2"
+	require.NoError(t, guard.Accept(otherChallenge))
+	otherHolder := testPresentation
+	otherHolder.Holder = "did:key:other"
+	require.NoError(t, guard.Accept(otherHolder))
+	// Bytes moved between fields give another hash
+	shifted := testPresentation
+	shifted.Challenge, shifted.Holder = "challenge-1did:key:", "holder"
+	require.NoError(t, guard.Accept(shifted))
+
+	now.Advance(time.Minute)
+	require.NoError(t, guard.Accept(testPresentation))
+	// Keys of the past window were dropped
+	require.Equal(t, 1, storage.Len())
+}
+
+func TestCheckPresentation_Replay(t *testing.T) {
+	calls := 0
+	v := &verifier.Verifier{
+		Registry: registry(false, &calls),
+		Replay:   &verifier.ReplayGuard{Storage: &verifier.MemoryStorage{}},
+	}
+	decision, err := v.CheckPresentation(testPresentation)
+	require.NoError(t, err)
+	require.True(t, decision.Accepted)
+	require.Contains(t, decision.Checks, verifier.CheckReplay)
+
+	decision, err = v.CheckPresentation(testPresentation)
+	require.NoError(t, err)
+	require.False(t, decision.Accepted)
+	require.Equal(t, verifier.OutcomeReplayed, decision.Outcome)
+}
+
+func TestCheckPresentation_RecordsAcceptedOnly(t *testing.T) {
+	revoked := true
+	v := &verifier.Verifier{
+		Registry: func(fingerprint string) (bool, error) { return revoked, nil },
+		Replay:   &verifier.ReplayGuard{Storage: &verifier.MemoryStorage{}},
+	}
+	decision, err := v.CheckPresentation(testPresentation)
+	require.NoError(t, err)
+	require.Equal(t, verifier.OutcomeRevoked, decision.Outcome)
+	require.NotContains(t, decision.Checks, verifier.CheckReplay)
+
+	// A rejected presentation may be retried once the rejection no longer applies
+	revoked = false
+	decision, err = v.CheckPresentation(testPresentation)
+	require.NoError(t, err)
+	require.True(t, decision.Accepted)
+}
+
+func TestCheckPresentation_StorageFailure(t *testing.T) {
+	calls := 0
+	v := &verifier.Verifier{
+		Registry: registry(false, &calls),
+		Replay:   &verifier.ReplayGuard{Storage: failingStorage{}},
+	}
+	decision, err := v.CheckPresentation(testPresentation)
+	require.ErrorContains(t, err, "connection refused")
+	require.False(t, decision.Accepted)
+	require.Equal(t, verifier.OutcomeReplayUnavailable, decision.Outcome)
+
+	// Without a replay guard presentations are only checked like credentials
+	v.Replay = nil
+	decision, err = v.CheckPresentation(testPresentation)
+	require.NoError(t, err)
+	require.True(t, decision.Accepted)
+}
@@ -0,0 +1,111 @@
+package verifier
+
+import (
+	"hash/maphash"
+	"sync"
+	"sync/atomic"
+)
+
+// cacheShards is the number of independently locked shards of a ShardedCache
+const cacheShards = 64
+
+// ShardedCache is an in-memory set of revoked fingerprints for verifiers serving high lookup rates while
+// applying event-driven updates. Fingerprints are spread over independently locked shards: an update
+// holds each shard's write lock only while changing that shard's part of the batch, so lookups never
+// wait for a whole bulk update and lookups of other shards do not wait at all.
+// It is a FilterLookup, so it can back a CachedRegistry.
+type ShardedCache struct {
+	seed   maphash.Seed
+	shards [cacheShards]cacheShard
+	count  atomic.Int64
+}
+
+type cacheShard struct {
+	mu      sync.RWMutex
+	revoked map[string]struct{}
+}
+
+// NewShardedCache returns an empty cache
+func NewShardedCache() *ShardedCache {
+	c := &ShardedCache{seed: maphash.MakeSeed()}
+	for i := range c.shards {
+		c.shards[i].revoked = make(map[string]struct{})
+	}
+	return c
+}
+
+func (c *ShardedCache) shardIndex(fingerprint string) int {
+	return int(maphash.String(c.seed, fingerprint) % cacheShards)
+}
+
+// Lookup reports whether a fingerprint is revoked
+func (c *ShardedCache) Lookup(data []byte) bool {
+	shard := &c.shards[c.shardIndex(string(data))]
+	shard.mu.RLock()
+	_, revoked := shard.revoked[string(data)]
+	shard.mu.RUnlock()
+	return revoked
+}
+
+// Len returns the number of revoked fingerprints
+func (c *ShardedCache) Len() int {
+	return int(c.count.Load())
+}
+
+// Apply adds revoked and removes unrevoked fingerprints, e.g. from FilterChanged chaincode events.
+// Each shard is locked once for its part of the batch. Lookups made during Apply may see part of it.
+func (c *ShardedCache) Apply(revoked []string, unrevoked []string) {
+	var inserts, deletes [cacheShards][]string
+	for _, fingerprint := range revoked {
+		i := c.shardIndex(fingerprint)
+		inserts[i] = append(inserts[i], fingerprint)
+	}
+	for _, fingerprint := range unrevoked {
+		i := c.shardIndex(fingerprint)
+		deletes[i] = append(deletes[i], fingerprint)
+	}
+
+	for i := range c.shards {
+		if len(inserts[i]) == 0 && len(deletes[i]) == 0 {
+			continue
+		}
+		shard := &c.shards[i]
+		var delta int64
+		shard.mu.Lock()
+		for _, fingerprint := range inserts[i] {
+			if _, ok := shard.revoked[fingerprint]; !ok {
+				shard.revoked[fingerprint] = struct{}{}
+				delta++
+			}
+		}
+		for _, fingerprint := range deletes[i] {
+			if _, ok := shard.revoked[fingerprint]; ok {
+				delete(shard.revoked, fingerprint)
+				delta--
+			}
+		}
+		shard.mu.Unlock()
+		c.count.Add(delta)
+	}
+}
+
+// Replace replaces the whole set, e.g. after a snapshot download. The new shards are built without
+// holding any lock and swapped in one at a time.
+func (c *ShardedCache) Replace(revoked []string) {
+	var shards [cacheShards]map[string]struct{}
+	for i := range shards {
+		shards[i] = make(map[string]struct{})
+	}
+	for _, fingerprint := range revoked {
+		shards[c.shardIndex(fingerprint)][fingerprint] = struct{}{}
+	}
+
+	for i := range c.shards {
+		shard := &c.shards[i]
+		shard.mu.Lock()
+		delta := int64(len(shards[i]) - len(shard.revoked))
+		shard.revoked = shards[i]
+		shard.mu.Unlock()
+		c.count.Add(delta)
+	}
+}
@@ -0,0 +1,170 @@
+package verifier_test
+
+import (
+	"fmt"
+	"github.com/pherbke/credential-management/chaincode-go/verifier"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func fingerprints(prefix string, n int) []string {
+	items := make([]string, n)
+	for i := range items {
+		items[i] = fmt.Sprintf("%s-%d", prefix, i)
+	}
+	return items
+}
+
+func TestShardedCache_ApplyAndReplace(t *testing.T) {
+	cache := verifier.NewShardedCache()
+	cache.Apply(fingerprints("revoked", 100), nil)
+	require.Equal(t, 100, cache.Len())
+	require.True(t, cache.Lookup([]byte("revoked-42")))
+
+	cache.Apply([]string{"revoked-42", "new"}, []string{"revoked-1", "revoked-2", "unknown"})
+	require.Equal(t, 99, cache.Len())
+	require.True(t, cache.Lookup([]byte("new")))
+	require.False(t, cache.Lookup([]byte("revoked-1")))
+
+	cache.Replace([]string{"a", "b"})
+	require.Equal(t, 2, cache.Len())
+	require.True(t, cache.Lookup([]byte("a")))
+	require.False(t, cache.Lookup([]byte("new")))
+
+	// The cache backs a CachedRegistry
+	registry := &verifier.CachedRegistry{MaxStaleness: time.Minute}
+	registry.Update(cache, time.Now())
+	cache.Apply([]string{"event-revoked"}, nil)
+	revoked, err := registry.Lookup("event-revoked")
+	require.NoError(t, err)
+	require.True(t, revoked)
+}
+
+// TestShardedCache_ConcurrentUpdates runs lookups against bulk updates, run it with -race
+func TestShardedCache_ConcurrentUpdates(t *testing.T) {
+	cache := verifier.NewShardedCache()
+	stable := fingerprints("stable", 1000)
+	cache.Apply(stable, nil)
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			churn := fingerprints(fmt.Sprintf("churn%d", w), 500)
+			for i := 0; i < 50; i++ {
+				cache.Apply(churn, nil)
+				cache.Apply(nil, churn)
+			}
+		}(w)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			cache.Replace(stable)
+		}
+	}()
+
+	var readers sync.WaitGroup
+	for r := 0; r < 8; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				for _, fingerprint := range stable[:50] {
+					if !cache.Lookup([]byte(fingerprint)) {
+						t.Errorf("stable fingerprint %s not found during updates", fingerprint)
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(stop)
+	readers.Wait()
+	require.Equal(t, len(stable), cache.Len())
+}
+
+// mutexCache is the single-lock baseline the sharded cache is benchmarked against
+type mutexCache struct {
+	mu      sync.RWMutex
+	revoked map[string]struct{}
+}
+
+func (c *mutexCache) Lookup(data []byte) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.revoked[string(data)]
+	return ok
+}
+
+func (c *mutexCache) Apply(revoked []string, unrevoked []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, fingerprint := range revoked {
+		c.revoked[fingerprint] = struct{}{}
+	}
+	for _, fingerprint := range unrevoked {
+		delete(c.revoked, fingerprint)
+	}
+}
+
+type updatableCache interface {
+	Lookup(data []byte) bool
+	Apply(revoked []string, unrevoked []string)
+}
+
+// BenchmarkCacheMixedLoad measures lookups of parallel readers while one writer keeps applying
+// bulk updates of 1000 fingerprints, comparing the sharded cache with a single RWMutex
+func BenchmarkCacheMixedLoad(b *testing.B) {
+	caches := map[string]func() updatableCache{
+		"Sharded": func() updatableCache { return verifier.NewShardedCache() },
+		"Mutex":   func() updatableCache { return &mutexCache{revoked: make(map[string]struct{})} },
+	}
+	for _, name := range []string{"Sharded", "Mutex"} {
+		b.Run(name, func(b *testing.B) {
+			cache := caches[name]()
+			stable := fingerprints("stable", 100000)
+			cache.Apply(stable, nil)
+			churn := fingerprints("churn", 1000)
+
+			stop := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+						cache.Apply(churn, nil)
+						cache.Apply(nil, churn)
+					}
+				}
+			}()
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					cache.Lookup([]byte(stable[i%len(stable)]))
+					i++
+				}
+			})
+			b.StopTimer()
+			close(stop)
+			<-done
+		})
+	}
+}
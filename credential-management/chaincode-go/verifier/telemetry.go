@@ -0,0 +1,246 @@
+package verifier
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OutcomeError marks decision records of verifications that failed with an error
+const OutcomeError = "error"
+
+// DecisionRecord is the structured telemetry event emitted for every verification decision.
+// It identifies the credential only by a keyed hash so no personal data leaves the verifier.
+type DecisionRecord struct {
+	Time           time.Time `json:"time"`
+	CredentialHash string    `json:"credentialHash,omitempty"` // See CredentialHash, empty without a TelemetryKey
+	Issuer         string    `json:"issuer"`
+	Checks         []string  `json:"checks"`
+	Outcome        string    `json:"outcome"`
+	Overridden     bool      `json:"overridden"`
+	Degraded       bool      `json:"degraded"`
+	LatencyMicros  int64     `json:"latencyMicros"`
+	PolicyVersion  string    `json:"policyVersion"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// CredentialHash returns the hash identifying a credential in decision records, the hex encoded
+// HMAC-SHA256 of its ID under the telemetry key. Credential IDs are often guessable, e.g. sequential
+// URNs, so an unkeyed hash could be reversed by hashing candidate IDs; only holders of the key can
+// link a record to a credential.
+func CredentialHash(key []byte, credentialID string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(credentialID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// DecisionSink receives decision records, e.g. to forward them to a log pipeline or message bus
+type DecisionSink interface {
+	Emit(record DecisionRecord) error
+}
+
+// JSONSink writes each decision record as one JSON line, e.g. to stdout
+type JSONSink struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewJSONSink creates a sink writing JSON lines to w
+func NewJSONSink(w io.Writer) *JSONSink {
+	return &JSONSink{encoder: json.NewEncoder(w)}
+}
+
+// Emit writes the record as a single JSON line
+func (s *JSONSink) Emit(record DecisionRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.encoder.Encode(record)
+}
+
+// SinkFunc adapts a function to the DecisionSink interface
+type SinkFunc func(record DecisionRecord) error
+
+// Emit calls f(record)
+func (f SinkFunc) Emit(record DecisionRecord) error {
+	return f(record)
+}
+
+// defaultSinkTimeout bounds the requests of sinks that do not set a client. Records are emitted
+// while the verification waits, so the bound is short.
+const defaultSinkTimeout = 2 * time.Second
+
+// KafkaSink produces decision records to a Kafka topic through a Kafka REST proxy (the Confluent REST
+// API v2). The credential hash is the record key, so the records of one credential share a partition.
+type KafkaSink struct {
+	ProxyURL string // Base URL of the REST proxy
+	Topic    string
+	Client   *http.Client // Optional, defaults to a client with a 2s timeout
+}
+
+// Emit produces the record as JSON
+func (s *KafkaSink) Emit(record DecisionRecord) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"records": []interface{}{map[string]interface{}{"key": record.CredentialHash, "value": record}},
+	})
+	if err != nil {
+		return err
+	}
+	topicURL := strings.TrimSuffix(s.ProxyURL, "/") + "/topics/" + url.PathEscape(s.Topic)
+	if err := post(s.Client, topicURL, "application/vnd.kafka.json.v2+json", body, nil); err != nil {
+		return fmt.Errorf("error producing to Kafka topic %s: %v", s.Topic, err)
+	}
+	return nil
+}
+
+// OTLPSink exports decision records as OpenTelemetry log records over OTLP/HTTP with JSON encoding.
+// Verifications that failed with an error are logged with severity ERROR, all others with INFO.
+type OTLPSink struct {
+	Endpoint    string            // Base URL of the OTLP/HTTP receiver, e.g. http://collector:4318
+	ServiceName string            // service.name resource attribute, defaults to "verifier"
+	Headers     map[string]string // Optional, e.g. an authorization header of the collector
+	Client      *http.Client      // Optional, defaults to a client with a 2s timeout
+}
+
+// OTLP severity numbers of the log records
+const (
+	otlpSeverityInfo  = 9
+	otlpSeverityError = 17
+)
+
+// Emit exports the record as one log record
+func (s *OTLPSink) Emit(record DecisionRecord) error {
+	serviceName := s.ServiceName
+	if serviceName == "" {
+		serviceName = "verifier"
+	}
+	severityNumber, severityText := otlpSeverityInfo, "INFO"
+	if record.Outcome == OutcomeError {
+		severityNumber, severityText = otlpSeverityError, "ERROR"
+	}
+	checks := make([]otlpValue, 0, len(record.Checks))
+	for i := range record.Checks {
+		checks = append(checks, otlpValue{StringValue: &record.Checks[i]})
+	}
+	attributes := []otlpAttribute{
+		otlpString("credential.hash", record.CredentialHash),
+		otlpString("credential.issuer", record.Issuer),
+		{Key: "decision.checks", Value: otlpValue{ArrayValue: &otlpArray{Values: checks}}},
+		otlpString("decision.outcome", record.Outcome),
+		otlpBool("decision.overridden", record.Overridden),
+		otlpBool("decision.degraded", record.Degraded),
+		otlpInt("decision.latency_micros", record.LatencyMicros),
+		otlpString("policy.version", record.PolicyVersion),
+	}
+	if record.Error != "" {
+		attributes = append(attributes, otlpString("error.message", record.Error))
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"resourceLogs": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{"attributes": []otlpAttribute{otlpString("service.name", serviceName)}},
+			"scopeLogs": []interface{}{map[string]interface{}{
+				"scope": map[string]string{"name": "github.com/pherbke/credential-management/chaincode-go/verifier"},
+				"logRecords": []interface{}{map[string]interface{}{
+					"timeUnixNano":   strconv.FormatInt(record.Time.UnixNano(), 10),
+					"severityNumber": severityNumber,
+					"severityText":   severityText,
+					"body":           otlpValue{StringValue: &record.Outcome},
+					"attributes":     attributes,
+				}},
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	logsURL := strings.TrimSuffix(s.Endpoint, "/") + "/v1/logs"
+	if err := post(s.Client, logsURL, "application/json", body, s.Headers); err != nil {
+		return fmt.Errorf("error exporting to OTLP endpoint %s: %v", s.Endpoint, err)
+	}
+	return nil
+}
+
+// otlpAttribute is a key value pair of the OTLP JSON encoding
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+// otlpValue is an AnyValue of the OTLP JSON encoding, exactly one field is set
+type otlpValue struct {
+	StringValue *string    `json:"stringValue,omitempty"`
+	BoolValue   *bool      `json:"boolValue,omitempty"`
+	IntValue    string     `json:"intValue,omitempty"` // 64-bit integers are encoded as strings
+	ArrayValue  *otlpArray `json:"arrayValue,omitempty"`
+}
+
+type otlpArray struct {
+	Values []otlpValue `json:"values"`
+}
+
+func otlpString(key string, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{StringValue: &value}}
+}
+
+func otlpBool(key string, value bool) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{BoolValue: &value}}
+}
+
+func otlpInt(key string, value int64) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{IntValue: strconv.FormatInt(value, 10)}}
+}
+
+// post sends a request body and fails on a non-2xx status
+func post(client *http.Client, target string, contentType string, body []byte, headers map[string]string) error {
+	if client == nil {
+		client = &http.Client{Timeout: defaultSinkTimeout}
+	}
+	request, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", contentType)
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", response.Status)
+	}
+	return nil
+}
+
+// newDecisionRecord builds the telemetry record of a finished verification
+func newDecisionRecord(key []byte, credential Credential, decision Decision, err error, started time.Time, latency time.Duration) DecisionRecord {
+	record := DecisionRecord{
+		Time:          started.UTC(),
+		Issuer:        credential.Issuer,
+		Checks:        decision.Checks,
+		Outcome:       decision.Outcome,
+		Overridden:    decision.Overridden,
+		Degraded:      decision.Degraded,
+		LatencyMicros: latency.Microseconds(),
+		PolicyVersion: decision.PolicyVersion,
+	}
+	if len(key) > 0 {
+		record.CredentialHash = CredentialHash(key, credential.ID)
+	}
+	if err != nil {
+		record.Outcome = OutcomeError
+		record.Error = err.Error()
+	}
+	return record
+}
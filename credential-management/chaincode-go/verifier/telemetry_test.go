@@ -0,0 +1,217 @@
+package verifier_test
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/pherbke/credential-management/chaincode-go/verifier"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var telemetryKey = []byte("telemetry-key-of-the-verifier")
+
+func recordingSink(records *[]verifier.DecisionRecord) verifier.SinkFunc {
+	return func(record verifier.DecisionRecord) error {
+		*records = append(*records, record)
+		return nil
+	}
+}
+
+func TestTelemetry_RecordPerDecision(t *testing.T) {
+	var records []verifier.DecisionRecord
+	calls := 0
+	v := &verifier.Verifier{
+		Policies:     &verifier.Policy{Version: "7"},
+		Registry:     registry(true, &calls),
+		Telemetry:    recordingSink(&records),
+		TelemetryKey: telemetryKey,
+	}
+
+	decision, err := v.Check(testCredential)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+
+	mac := hmac.New(sha256.New, telemetryKey)
+	mac.Write([]byte(testCredential.ID))
+	record := records[0]
+	require.Equal(t, hex.EncodeToString(mac.Sum(nil)), record.CredentialHash)
+	require.Equal(t, testCredential.Issuer, record.Issuer)
+	require.Equal(t, verifier.OutcomeRevoked, record.Outcome)
+	require.Equal(t, decision.Checks, record.Checks)
+	require.Equal(t, []string{verifier.CheckDenyList, verifier.CheckAllowList, verifier.CheckRegistry, verifier.CheckCredentialAllowList}, record.Checks)
+	require.Equal(t, "7", record.PolicyVersion)
+	require.GreaterOrEqual(t, record.LatencyMicros, int64(0))
+	require.False(t, record.Time.IsZero())
+}
+
+func TestTelemetry_NoPersonalData(t *testing.T) {
+	var buf bytes.Buffer
+	calls := 0
+	v := &verifier.Verifier{Registry: registry(false, &calls), Telemetry: verifier.NewJSONSink(&buf), TelemetryKey: telemetryKey}
+
+	_, err := v.Check(testCredential)
+	require.NoError(t, err)
+	require.NotContains(t, buf.String(), testCredential.ID)
+	require.NotContains(t, buf.String(), testCredential.Fingerprint)
+	// The unkeyed hash of the ID would let anyone confirm a guessed credential ID
+	hash := sha256.Sum256([]byte(testCredential.ID))
+	require.NotContains(t, buf.String(), hex.EncodeToString(hash[:]))
+}
+
+func TestCredentialHash_Keyed(t *testing.T) {
+	hash := verifier.CredentialHash(telemetryKey, testCredential.ID)
+	require.Equal(t, hash, verifier.CredentialHash(telemetryKey, testCredential.ID))
+	require.NotEqual(t, hash, verifier.CredentialHash([]byte("another-key"), testCredential.ID))
+
+	// Without a key records carry no credential hash
+	var records []verifier.DecisionRecord
+	calls := 0
+	v := &verifier.Verifier{Registry: registry(false, &calls), Telemetry: recordingSink(&records)}
+	_, err := v.Check(testCredential)
+	require.NoError(t, err)
+	require.Empty(t, records[0].CredentialHash)
+}
+
+func TestTelemetry_JSONSinkWritesLines(t *testing.T) {
+	var buf bytes.Buffer
+	calls := 0
+	v := &verifier.Verifier{
+		Policies:  &verifier.Policy{DenyIssuers: []string{testCredential.Issuer}},
+		Registry:  registry(false, &calls),
+		Telemetry: verifier.NewJSONSink(&buf),
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err := v.Check(testCredential)
+		require.NoError(t, err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	var record verifier.DecisionRecord
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	require.Equal(t, verifier.OutcomeDeniedByPolicy, record.Outcome)
+	require.Equal(t, []string{verifier.CheckDenyList}, record.Checks)
+}
+
+func TestTelemetry_ErrorOutcome(t *testing.T) {
+	var records []verifier.DecisionRecord
+	v := &verifier.Verifier{
+		Registry: func(string) (bool, error) {
+			return false, errors.New("peer unavailable")
+		},
+		Telemetry: recordingSink(&records),
+	}
+
+	_, err := v.Check(testCredential)
+	require.Error(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, verifier.OutcomeError, records[0].Outcome)
+	require.Contains(t, records[0].Error, "peer unavailable")
+}
+
+func TestTelemetry_SinkFailureDoesNotChangeDecision(t *testing.T) {
+	calls := 0
+	v := &verifier.Verifier{
+		Registry: registry(false, &calls),
+		Telemetry: verifier.SinkFunc(func(verifier.DecisionRecord) error {
+			return errors.New("sink unavailable")
+		}),
+	}
+
+	decision, err := v.Check(testCredential)
+	require.NoError(t, err)
+	require.True(t, decision.Accepted)
+}
+
+var testRecord = verifier.DecisionRecord{
+	Time:           time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+	CredentialHash: "4a5b",
+	Issuer:         "did:key:issuer",
+	Checks:         []string{verifier.CheckDenyList, verifier.CheckAllowList, verifier.CheckRegistry},
+	Outcome:        verifier.OutcomeRevoked,
+	LatencyMicros:  42,
+	PolicyVersion:  "7",
+}
+
+func TestKafkaSink(t *testing.T) {
+	var request struct {
+		Records []struct {
+			Key   string                  `json:"key"`
+			Value verifier.DecisionRecord `json:"value"`
+		} `json:"records"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/topics/verifier.decisions", r.URL.Path)
+		require.Equal(t, "application/vnd.kafka.json.v2+json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+	}))
+	defer server.Close()
+
+	require.NoError(t, (&verifier.KafkaSink{ProxyURL: server.URL, Topic: "verifier.decisions"}).Emit(testRecord))
+	require.Len(t, request.Records, 1)
+	require.Equal(t, "4a5b", request.Records[0].Key)
+	require.Equal(t, testRecord, request.Records[0].Value)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	require.Error(t, (&verifier.KafkaSink{ProxyURL: failing.URL, Topic: "verifier.decisions"}).Emit(testRecord))
+}
+
+func TestOTLPSink(t *testing.T) {
+	var request struct {
+		ResourceLogs []struct {
+			Resource struct {
+				Attributes []map[string]interface{} `json:"attributes"`
+			} `json:"resource"`
+			ScopeLogs []struct {
+				LogRecords []struct {
+					TimeUnixNano   string                   `json:"timeUnixNano"`
+					SeverityNumber int                      `json:"severityNumber"`
+					SeverityText   string                   `json:"severityText"`
+					Attributes     []map[string]interface{} `json:"attributes"`
+				} `json:"logRecords"`
+			} `json:"scopeLogs"`
+		} `json:"resourceLogs"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/logs", r.URL.Path)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+	}))
+	defer server.Close()
+
+	sink := &verifier.OTLPSink{Endpoint: server.URL + "/", Headers: map[string]string{"Authorization": "Bearer token"}}
+	require.NoError(t, sink.Emit(testRecord))
+	require.Len(t, request.ResourceLogs, 1)
+	require.Equal(t, map[string]interface{}{"key": "service.name", "value": map[string]interface{}{"stringValue": "verifier"}},
+		request.ResourceLogs[0].Resource.Attributes[0])
+	logRecord := request.ResourceLogs[0].ScopeLogs[0].LogRecords[0]
+	require.Equal(t, "1709294400000000000", logRecord.TimeUnixNano)
+	require.Equal(t, 9, logRecord.SeverityNumber)
+	attributes := map[string]interface{}{}
+	for _, attribute := range logRecord.Attributes {
+		attributes[attribute["key"].(string)] = attribute["value"]
+	}
+	require.Equal(t, map[string]interface{}{"stringValue": "4a5b"}, attributes["credential.hash"])
+	require.Equal(t, map[string]interface{}{"stringValue": verifier.OutcomeRevoked}, attributes["decision.outcome"])
+	require.Equal(t, map[string]interface{}{"intValue": "42"}, attributes["decision.latency_micros"])
+	require.Equal(t, map[string]interface{}{"boolValue": false}, attributes["decision.degraded"])
+	require.Len(t, attributes["decision.checks"].(map[string]interface{})["arrayValue"].(map[string]interface{})["values"], 3)
+
+	failed := testRecord
+	failed.Outcome, failed.Error = verifier.OutcomeError, "peer unavailable"
+	require.NoError(t, sink.Emit(failed))
+	require.Equal(t, "ERROR", request.ResourceLogs[0].ScopeLogs[0].LogRecords[0].SeverityText)
+}
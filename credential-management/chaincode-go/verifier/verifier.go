@@ -0,0 +1,175 @@
+package verifier
+
+import (
+	"fmt"
+	"time"
+)
+
+// Outcome codes of a verification decision
+const (
+	OutcomeAccepted           = "accepted"
+	OutcomeRevoked            = "revoked"
+	OutcomeDeniedByPolicy     = "denied_by_policy"
+	OutcomeNotAllowedByPolicy = "not_allowed_by_policy"
+)
+
+// Names of the checks recorded in a decision
+const (
+	CheckDenyList            = "deny_list"
+	CheckAllowList           = "allow_list"
+	CheckRegistry            = "registry"
+	CheckCredentialAllowList = "credential_allow_list"
+)
+
+// Credential holds the parts of a credential the verifier's revocation decision depends on
+type Credential struct {
+	ID          string
+	Issuer      string
+	Types       []string
+	Fingerprint string // Fingerprint looked up in the revocation registry
+}
+
+// RegistryLookup reports whether a fingerprint is present in the revocation registry
+type RegistryLookup func(fingerprint string) (bool, error)
+
+// Decision is the result of checking a credential against the local policy and the registry
+type Decision struct {
+	Outcome         string   `json:"outcome"`
+	Accepted        bool     `json:"accepted"`
+	RegistryChecked bool     `json:"registryChecked"`
+	Overridden      bool     `json:"overridden"` // Registry reported revoked but the policy allows the credential
+	Degraded        bool     `json:"degraded"`   // Decided without a registry answer, see FailureMode
+	Warning         string   `json:"warning,omitempty"`
+	PolicyVersion   string   `json:"policyVersion"`
+	Checks          []string `json:"checks"` // Checks performed, in evaluation order
+}
+
+// Verifier checks credentials against a verifier-local policy and the revocation registry.
+// Policies are evaluated in a fixed order:
+//  1. deny-lists of credential IDs, issuers and types reject without a registry lookup
+//  2. non-empty issuer and type allow-lists reject credentials that match neither
+//  3. the registry lookup rejects revoked credentials
+//  4. the credential allow-list overrides a revoked registry status
+//
+// When the registry lookup fails, FailureMode decides: FailHard rejects with OutcomeRegistryUnavailable
+// and returns the error, FailSoft accepts with OutcomeAcceptedUnchecked, and FailCachedWithinSLA uses the
+// status in Cache if it was synced within CacheSLA and rejects like FailHard otherwise. Decisions taken
+// without a registry answer are flagged as degraded and counted in FailureMetrics.
+type Verifier struct {
+	Policies     PolicySource
+	Registry     RegistryLookup
+	Telemetry    DecisionSink    // Optional; sink failures never change a decision
+	TelemetryKey []byte          // HMAC key of the credential hashes in decision records, none are recorded without it
+	FailureMode  string          // Defaults to FailHard
+	Cache        *CachedRegistry // Used by FailCachedWithinSLA
+	CacheSLA     time.Duration   // Maximum age of the cache for FailCachedWithinSLA, defaults to Cache.MaxStaleness
+	Replay       *ReplayGuard    // Optional, rejects replayed presentations in CheckPresentation
+
+	failures failureCounters
+}
+
+// Check decides whether the credential is acceptable and emits a decision record to the telemetry sink
+func (v *Verifier) Check(credential Credential) (Decision, error) {
+	started := time.Now()
+	decision, err := v.check(credential)
+	if v.Telemetry != nil {
+		_ = v.Telemetry.Emit(newDecisionRecord(v.TelemetryKey, credential, decision, err, started, time.Since(started)))
+	}
+	return decision, err
+}
+
+func (v *Verifier) check(credential Credential) (Decision, error) {
+	policy := v.policy()
+	decision := Decision{PolicyVersion: policy.Version}
+
+	decision.Checks = append(decision.Checks, CheckDenyList)
+	if contains(policy.DenyCredentials, credential.ID) ||
+		contains(policy.DenyIssuers, credential.Issuer) ||
+		containsAny(policy.DenyTypes, credential.Types) {
+		decision.Outcome = OutcomeDeniedByPolicy
+		return decision, nil
+	}
+
+	decision.Checks = append(decision.Checks, CheckAllowList)
+	if (len(policy.AllowIssuers) > 0 && !contains(policy.AllowIssuers, credential.Issuer)) ||
+		(len(policy.AllowTypes) > 0 && !containsAny(policy.AllowTypes, credential.Types)) {
+		decision.Outcome = OutcomeNotAllowedByPolicy
+		return decision, nil
+	}
+
+	if v.Registry == nil {
+		return decision, fmt.Errorf("no revocation registry configured")
+	}
+	decision.Checks = append(decision.Checks, CheckRegistry)
+	revoked, err := v.Registry(credential.Fingerprint)
+	if err != nil {
+		return v.registryFailed(decision, policy, credential, err)
+	}
+	decision.RegistryChecked = true
+	return v.decide(decision, policy, credential, revoked), nil
+}
+
+// decide applies the credential allow-list to the revocation status
+func (v *Verifier) decide(decision Decision, policy *Policy, credential Credential, revoked bool) Decision {
+	if revoked {
+		decision.Checks = append(decision.Checks, CheckCredentialAllowList)
+	}
+	if revoked && !contains(policy.AllowCredentials, credential.ID) {
+		decision.Outcome = OutcomeRevoked
+		return decision
+	}
+
+	decision.Outcome = OutcomeAccepted
+	decision.Accepted = true
+	decision.Overridden = revoked
+	return decision
+}
+
+// registryFailed decides according to the failure mode after the registry lookup failed
+func (v *Verifier) registryFailed(decision Decision, policy *Policy, credential Credential, lookupErr error) (Decision, error) {
+	err := fmt.Errorf("error looking up revocation status: %v", lookupErr)
+	decision.Degraded = true
+	decision.Warning = err.Error()
+
+	switch v.FailureMode {
+	case FailSoft:
+		v.failures.count(func(m *FailureMetrics) { m.RegistryFailures++; m.SoftFailures++ })
+		decision.Outcome = OutcomeAcceptedUnchecked
+		decision.Accepted = true
+		return decision, nil
+	case FailCachedWithinSLA:
+		if v.Cache != nil {
+			sla := v.CacheSLA
+			if sla <= 0 {
+				sla = v.Cache.MaxStaleness
+			}
+			decision.Checks = append(decision.Checks, CheckCache)
+			if revoked, ok := v.Cache.LookupWithin(credential.Fingerprint, sla); ok {
+				v.failures.count(func(m *FailureMetrics) { m.RegistryFailures++; m.CachedDecisions++ })
+				decision = v.decide(decision, policy, credential, revoked)
+				if decision.Outcome == OutcomeAccepted {
+					decision.Outcome = OutcomeAcceptedCached
+				}
+				return decision, nil
+			}
+		}
+		v.failures.count(func(m *FailureMetrics) { m.RegistryFailures++; m.CacheOutsideSLA++; m.HardFailures++ })
+	default:
+		v.failures.count(func(m *FailureMetrics) { m.RegistryFailures++; m.HardFailures++ })
+	}
+	decision.Outcome = OutcomeRegistryUnavailable
+	return decision, err
+}
+
+// FailureMetrics returns the counters of decisions taken without a registry answer
+func (v *Verifier) FailureMetrics() FailureMetrics {
+	return v.failures.get()
+}
+
+// policy returns the policy to apply, an empty one if there is none
+func (v *Verifier) policy() *Policy {
+	if v.Policies != nil && v.Policies.Policy() != nil {
+		return v.Policies.Policy()
+	}
+	return &Policy{}
+}
@@ -0,0 +1,156 @@
+package verifier_test
+
+import (
+	"errors"
+	"github.com/pherbke/credential-management/chaincode-go/verifier"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testCredential = verifier.Credential{
+	ID:          "urn:did:1234560",
+	Issuer:      "did:ebsi:issuer",
+	Types:       []string{"VerifiableCredential", "VerifiableId"},
+	Fingerprint: "0a1b2c3d4e5f6a7b",
+}
+
+// registry returns a lookup reporting the given status and counting its calls
+func registry(revoked bool, calls *int) verifier.RegistryLookup {
+	return func(fingerprint string) (bool, error) {
+		*calls++
+		return revoked, nil
+	}
+}
+
+func TestCheck_EvaluationOrder(t *testing.T) {
+	tests := []struct {
+		name            string
+		policy          verifier.Policy
+		revoked         bool
+		outcome         string
+		registryChecked bool
+		overridden      bool
+	}{
+		{"no policy, not revoked", verifier.Policy{}, false, verifier.OutcomeAccepted, true, false},
+		{"no policy, revoked", verifier.Policy{}, true, verifier.OutcomeRevoked, true, false},
+		{"denied credential", verifier.Policy{DenyCredentials: []string{testCredential.ID}}, false, verifier.OutcomeDeniedByPolicy, false, false},
+		{"denied issuer", verifier.Policy{DenyIssuers: []string{testCredential.Issuer}}, false, verifier.OutcomeDeniedByPolicy, false, false},
+		{"denied type", verifier.Policy{DenyTypes: []string{"VerifiableId"}}, false, verifier.OutcomeDeniedByPolicy, false, false},
+		{"deny wins over allow", verifier.Policy{DenyIssuers: []string{testCredential.Issuer}, AllowCredentials: []string{testCredential.ID}}, false, verifier.OutcomeDeniedByPolicy, false, false},
+		{"issuer not allowed", verifier.Policy{AllowIssuers: []string{"did:ebsi:other"}}, false, verifier.OutcomeNotAllowedByPolicy, false, false},
+		{"type not allowed", verifier.Policy{AllowTypes: []string{"DiplomaCredential"}}, false, verifier.OutcomeNotAllowedByPolicy, false, false},
+		{"issuer allowed, revoked", verifier.Policy{AllowIssuers: []string{testCredential.Issuer}}, true, verifier.OutcomeRevoked, true, false},
+		{"type allowed", verifier.Policy{AllowTypes: []string{"VerifiableId"}}, false, verifier.OutcomeAccepted, true, false},
+		{"allowed credential overrides revocation", verifier.Policy{AllowCredentials: []string{testCredential.ID}}, true, verifier.OutcomeAccepted, true, true},
+		{"allowed credential, not revoked", verifier.Policy{AllowCredentials: []string{testCredential.ID}}, false, verifier.OutcomeAccepted, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			policy := tt.policy
+			v := &verifier.Verifier{Policies: &policy, Registry: registry(tt.revoked, &calls)}
+
+			decision, err := v.Check(testCredential)
+			require.NoError(t, err)
+			require.Equal(t, tt.outcome, decision.Outcome)
+			require.Equal(t, tt.outcome == verifier.OutcomeAccepted, decision.Accepted)
+			require.Equal(t, tt.registryChecked, decision.RegistryChecked)
+			require.Equal(t, tt.registryChecked, calls == 1, "Registry should only be consulted when the policy does not decide first")
+			require.Equal(t, tt.overridden, decision.Overridden)
+		})
+	}
+}
+
+func TestCheck_RegistryError(t *testing.T) {
+	v := &verifier.Verifier{Registry: func(string) (bool, error) {
+		return false, errors.New("peer unavailable")
+	}}
+
+	decision, err := v.Check(testCredential)
+	require.Error(t, err)
+	require.False(t, decision.Accepted)
+}
+
+func TestCheck_NoRegistry(t *testing.T) {
+	v := &verifier.Verifier{}
+	_, err := v.Check(testCredential)
+	require.Error(t, err)
+}
+
+func writePolicyFile(t *testing.T, path string, content string, modTime time.Time) {
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestPolicyFile_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	start := time.Now().Add(-time.Hour)
+	writePolicyFile(t, path, `{"version":"1","denyIssuers":["did:ebsi:issuer"]}`, start)
+
+	policyFile, err := verifier.LoadPolicyFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "1", policyFile.Policy().Version)
+
+	calls := 0
+	v := &verifier.Verifier{Policies: policyFile, Registry: registry(false, &calls)}
+	decision, err := v.Check(testCredential)
+	require.NoError(t, err)
+	require.Equal(t, verifier.OutcomeDeniedByPolicy, decision.Outcome)
+
+	// Unchanged file is not reloaded
+	reloaded, err := policyFile.Reload()
+	require.NoError(t, err)
+	require.False(t, reloaded)
+
+	// A changed file replaces the policy without restarting the verifier
+	writePolicyFile(t, path, `{"version":"2"}`, start.Add(time.Minute))
+	reloaded, err = policyFile.Reload()
+	require.NoError(t, err)
+	require.True(t, reloaded)
+
+	decision, err = v.Check(testCredential)
+	require.NoError(t, err)
+	require.Equal(t, verifier.OutcomeAccepted, decision.Outcome)
+	require.Equal(t, "2", decision.PolicyVersion)
+}
+
+func TestPolicyFile_InvalidReloadKeepsPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	start := time.Now().Add(-time.Hour)
+	writePolicyFile(t, path, `{"version":"1"}`, start)
+
+	policyFile, err := verifier.LoadPolicyFile(path)
+	require.NoError(t, err)
+
+	writePolicyFile(t, path, `{"version":`, start.Add(time.Minute))
+	_, err = policyFile.Reload()
+	require.Error(t, err)
+	require.Equal(t, "1", policyFile.Policy().Version)
+}
+
+func TestPolicyFile_Watch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	start := time.Now().Add(-time.Hour)
+	writePolicyFile(t, path, `{"version":"1"}`, start)
+
+	policyFile, err := verifier.LoadPolicyFile(path)
+	require.NoError(t, err)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go policyFile.Watch(10*time.Millisecond, stop, nil)
+
+	writePolicyFile(t, path, `{"version":"2"}`, start.Add(time.Minute))
+	require.Eventually(t, func() bool {
+		return policyFile.Policy().Version == "2"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestLoadPolicyFile_Missing(t *testing.T) {
+	_, err := verifier.LoadPolicyFile(filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, err)
+}